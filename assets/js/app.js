@@ -1,10 +1,61 @@
 const API_URL = '/api/analyze';
+const VALIDATE_URL = '/api/validate';
+const DEMO_URL = '/api/demo/intakes';
 const analyzeBtn = document.getElementById('analyzeBtn');
 const analyzeDefaultLabel = analyzeBtn.textContent;
 
+// Buttons name their handler in data-action rather than an inline onclick, so
+// the Content-Security-Policy can forbid inline script.
+const actions = { prefillSample, prefillHighRisk, addMedication, analyzePatient, goToReview, finalizeReview, startOver, showSection };
+document.querySelectorAll('[data-action]').forEach(btn => {
+    btn.addEventListener('click', () => actions[btn.dataset.action](btn.dataset.section));
+});
+
 document.getElementById('weight').addEventListener('input', calculateBMI);
 document.getElementById('height').addEventListener('input', calculateBMI);
 
+// Server-side feedback as each field loses focus; validateForm still gates
+// submission.
+['patientName', 'age', 'weight', 'height', 'bp', 'complaint'].forEach(id => {
+    document.getElementById(id).addEventListener('blur', () => validateField(id));
+});
+
+// Demo mode lists its example intakes; elsewhere the endpoint is not found and
+// the picker stays hidden.
+const demoPicker = document.getElementById('demoCase');
+let demoCases = [];
+fetch(DEMO_URL)
+    .then(resp => resp.ok ? resp.json() : [])
+    .then(cases => {
+        demoCases = cases;
+        cases.forEach(c => demoPicker.add(new Option(c.title, c.id)));
+        demoPicker.hidden = cases.length === 0;
+    })
+    .catch(() => {});
+demoPicker.addEventListener('change', () => {
+    const c = demoCases.find(c => c.id === demoPicker.value);
+    if (c) prefillDemo(c.intake);
+    demoPicker.value = '';
+});
+
+function validateField(id) {
+    fetch(VALIDATE_URL, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(getFormData())
+    })
+    .then(resp => resp.ok ? resp.json() : null)
+    .then(report => {
+        if (!report) return;
+        document.getElementById(id).classList.remove('input-error');
+        const slot = document.querySelector(`[data-error-for="${id}"]`);
+        if (slot) slot.textContent = '';
+        const hit = report.errors.find(e => e.field === id) || report.warnings.find(w => w.field === id);
+        if (hit) setFieldError(id, hit.message);
+    })
+    .catch(() => {});
+}
+
 function calculateBMI() {
     const weight = parseFloat(document.getElementById('weight').value);
     const height = parseFloat(document.getElementById('height').value);
@@ -37,11 +88,13 @@ function prefillSample() {
     document.getElementById('weight').value = '78';
     document.getElementById('height').value = '175';
     document.getElementById('bp').value = '135/88';
-    document.getElementById('allergies').value = 'None';
+    document.getElementById('allergies').value = '';
+    document.getElementById('confirmedNoAllergies').checked = true;
     document.getElementById('smoking').value = 'Former';
     document.getElementById('alcohol').value = 'Occasional';
-    document.getElementById('exercise').value = '1-2x/week';
+    document.getElementById('exercise').value = 'occasional';
     document.getElementById('complaint').value = 'ED';
+    document.getElementById('consentGiven').checked = true;
     
     document.querySelector('#conditions input[value="Hypertension"]').checked = true;
     
@@ -63,8 +116,9 @@ function prefillHighRisk() {
     document.getElementById('allergies').value = 'Sulfa';
     document.getElementById('smoking').value = 'Current';
     document.getElementById('alcohol').value = 'Moderate';
-    document.getElementById('exercise').value = 'None';
+    document.getElementById('exercise').value = 'none';
     document.getElementById('complaint').value = 'ED';
+    document.getElementById('consentGiven').checked = true;
     
     document.querySelectorAll('#conditions input').forEach(cb => cb.checked = false);
     document.querySelector('#conditions input[value="Heart Disease"]').checked = true;
@@ -78,6 +132,60 @@ function prefillHighRisk() {
     calculateBMI();
 }
 
+// setChoice selects the option of a select matching value regardless of case,
+// keeping the current one when none does.
+function setChoice(id, value) {
+    const el = document.getElementById(id);
+    const opt = Array.from(el.options).find(o => o.value.toLowerCase() === String(value || '').toLowerCase());
+    if (opt) el.value = opt.value;
+}
+
+function prefillDemo(intake) {
+    startOver();
+    const labs = intake.labs || {};
+    const fields = {
+        patientName: intake.patientName, userId: 'demo-clinician', age: intake.age,
+        weight: intake.weight, height: intake.height, bp: intake.bp,
+        waistCircumferenceCm: intake.waistCircumferenceCm,
+        triglyceridesMgDl: labs.triglyceridesMgDl, hdlMgDl: labs.hdlMgDl, a1cPercent: labs.a1cPercent,
+        allergies: (intake.allergies || []).join(', '),
+        familyHistory: (intake.familyHistory || []).join(', '),
+        smokingPackYears: intake.smokingPackYears, smokingQuitYearsAgo: intake.smokingQuitYearsAgo,
+        alcoholDrinksPerWeek: intake.alcoholDrinksPerWeek, sleepHours: intake.sleepHours
+    };
+    Object.entries(fields).forEach(([id, value]) => {
+        document.getElementById(id).value = value ?? '';
+    });
+    setChoice('smoking', intake.smoking);
+    setChoice('alcohol', intake.alcohol);
+    setChoice('exercise', intake.exercise);
+    setChoice('complaint', intake.complaint);
+    const consent = intake.consent || {};
+    document.getElementById('consentGiven').checked = Boolean(consent.given);
+    setChoice('consentMethod', consent.method);
+
+    const conditions = (intake.conditions || []).map(c => c.toLowerCase());
+    document.querySelectorAll('#conditions input').forEach(cb => {
+        cb.checked = conditions.includes(cb.value.toLowerCase());
+    });
+    document.getElementById('confirmedNoConditions').checked = Boolean(intake.confirmedNoConditions);
+    document.getElementById('confirmedNoMedications').checked = Boolean(intake.confirmedNoMedications);
+    document.getElementById('confirmedNoAllergies').checked = Boolean(intake.confirmedNoAllergies);
+
+    const meds = intake.medications || [];
+    meds.slice(1).forEach(() => addMedication());
+    document.querySelectorAll('#medications .medication-entry').forEach((entry, i) => {
+        if (!meds[i]) return;
+        entry.querySelector('.med-name').value = meds[i].name;
+        entry.querySelector('.med-dose').value = meds[i].dosage;
+        const freq = entry.querySelector('.med-freq');
+        const opt = Array.from(freq.options).find(o => o.value.toLowerCase() === meds[i].frequency.toLowerCase());
+        if (opt) freq.value = opt.value;
+    });
+
+    calculateBMI();
+}
+
 function showSection(sectionId) {
     document.querySelectorAll('.section').forEach(s => s.classList.remove('active'));
     document.getElementById(sectionId).classList.add('active');
@@ -99,7 +207,7 @@ function setFieldError(fieldId, message) {
 function validateForm(data) {
     clearErrors();
     const errors = [];
-    const bpPattern = /^\s*\d{2,3}\s*\/\s*\d{2,3}\s*$/;
+    const bpPattern = /^\s*(?:bp:?\s*)?\d{2,3}\s*(?:\/|over)\s*\d{2,3}(?:\s*mm\s*hg)?\s*$/i;
 
     if (!data.patientName) {
         errors.push('Patient name is required.');
@@ -117,21 +225,65 @@ function validateForm(data) {
         errors.push('Height must be greater than 0.');
         setFieldError('height', 'Enter valid height');
     }
-    if (!data.bp || !bpPattern.test(data.bp)) {
+    // Hair loss and weight loss plans do not read blood pressure, so the
+    // server accepts those intakes without one.
+    const bpOptional = data.complaint === 'Hair Loss' || data.complaint === 'Weight Loss';
+    if (data.bp ? !bpPattern.test(data.bp) : !bpOptional) {
         errors.push('Blood pressure must be in ##/## format.');
         setFieldError('bp', 'Format e.g. 120/80');
     }
+    if (data.complaint === 'ED' && data.conditions.length === 0 && !data.confirmedNoConditions) {
+        errors.push('List existing conditions or confirm there are none.');
+        setFieldError('confirmedNoConditions', 'Confirm no conditions');
+    }
+    if (data.complaint === 'ED' && data.medications.length === 0 && !data.confirmedNoMedications) {
+        errors.push('List current medications or confirm there are none.');
+        setFieldError('confirmedNoMedications', 'Confirm no medications');
+    }
+    if (data.smokingQuitYearsAgo !== undefined && data.smoking !== 'Former') {
+        errors.push('Years since quitting applies to former smokers only.');
+        setFieldError('smokingQuitYearsAgo', 'Only for former smokers');
+    }
+    if (data.smokingPackYears > 0 && data.smoking === 'Never') {
+        errors.push('Pack-years cannot be recorded for a never-smoker.');
+        setFieldError('smokingPackYears', 'Not for never-smokers');
+    }
+    if (data.alcoholDrinksPerWeek !== undefined && data.alcoholDrinksPerWeek < 0) {
+        errors.push('Drinks per week cannot be negative.');
+        setFieldError('alcoholDrinksPerWeek', 'Enter 0 or more');
+    }
+    if (data.sleepHours !== undefined && (data.sleepHours < 0 || data.sleepHours > 24)) {
+        errors.push('Sleep hours must be between 0 and 24.');
+        setFieldError('sleepHours', 'Enter 0-24');
+    }
     if (!data.complaint) {
         errors.push('Chief complaint is required.');
         setFieldError('complaint', 'Select a complaint');
     }
+    if (!data.consent.given) {
+        errors.push('Patient consent is required.');
+        setFieldError('consentGiven', 'Confirm consent');
+    }
 
     return { valid: errors.length === 0, errors };
 }
 
+// optionalNumber parses an optional numeric input; a blank one is undefined
+// so it is left out of the request rather than sent as zero.
+function optionalNumber(value) {
+    const n = parseFloat(value);
+    return Number.isNaN(n) ? undefined : n;
+}
+
 function getFormData() {
     const conditions = Array.from(document.querySelectorAll('#conditions input:checked')).map(cb => cb.value);
     const allergies = document.getElementById('allergies').value.split(',').map(a => a.trim()).filter(Boolean);
+    const familyHistory = document.getElementById('familyHistory').value.split(',').map(f => f.trim()).filter(Boolean);
+    const labs = {
+        triglyceridesMgDl: optionalNumber(document.getElementById('triglyceridesMgDl').value),
+        hdlMgDl: optionalNumber(document.getElementById('hdlMgDl').value),
+        a1cPercent: optionalNumber(document.getElementById('a1cPercent').value)
+    };
     const medications = Array.from(document.querySelectorAll('#medications .medication-entry'))
         .map(entry => ({
             name: entry.querySelector('.med-name').value.trim(),
@@ -148,13 +300,28 @@ function getFormData() {
         height: parseFloat(document.getElementById('height').value) || 0,
         bp: document.getElementById('bp').value.trim(),
         bmi: parseFloat(document.getElementById('bmi').value) || 0,
+        waistCircumferenceCm: parseFloat(document.getElementById('waistCircumferenceCm').value) || 0,
+        labs: Object.values(labs).some(v => v !== undefined) ? labs : undefined,
         conditions,
+        confirmedNoConditions: conditions.length === 0 && document.getElementById('confirmedNoConditions').checked,
         allergies,
+        confirmedNoAllergies: allergies.length === 0 && document.getElementById('confirmedNoAllergies').checked,
+        familyHistory,
         medications,
+        confirmedNoMedications: medications.length === 0 && document.getElementById('confirmedNoMedications').checked,
         smoking: document.getElementById('smoking').value,
+        smokingPackYears: parseFloat(document.getElementById('smokingPackYears').value) || 0,
+        smokingQuitYearsAgo: optionalNumber(document.getElementById('smokingQuitYearsAgo').value),
         alcohol: document.getElementById('alcohol').value,
+        alcoholDrinksPerWeek: optionalNumber(document.getElementById('alcoholDrinksPerWeek').value),
+        sleepHours: optionalNumber(document.getElementById('sleepHours').value),
         exercise: document.getElementById('exercise').value,
-        complaint: document.getElementById('complaint').value
+        complaint: document.getElementById('complaint').value,
+        consent: {
+            given: document.getElementById('consentGiven').checked,
+            timestamp: new Date().toISOString(),
+            method: document.getElementById('consentMethod').value
+        }
     };
 }
 
@@ -181,8 +348,10 @@ function analyzePatient() {
     .then(async resp => {
         const data = await resp.json().catch(() => null);
         if (!resp.ok) {
-            const message = (data && data.error) ? data.error : `Server returned ${resp.status}`;
-            const details = (data && data.details) ? data.details : [];
+            // Errors are RFC 7807 problem details; validation failures list
+            // each failure in errors.
+            const message = (data && data.title) ? data.title : `Server returned ${resp.status}`;
+            const details = (data && data.errors) ? data.errors : (data && data.detail ? [data.detail] : []);
             throw { message, details };
         }
         return data;
@@ -210,11 +379,17 @@ function setAnalyzing(isLoading) {
     analyzeBtn.textContent = isLoading ? 'Analyzing...' : analyzeDefaultLabel;
 }
 
+// escapeHtml makes a value safe to interpolate into innerHTML; intake text
+// comes back in plans, issues, and validation errors.
+function escapeHtml(value) {
+    return String(value ?? '').replace(/[&<>"']/g, c => ({ '&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;' })[c]);
+}
+
 function showError(message, details = []) {
     const card = document.getElementById('errorCard');
     document.getElementById('errorText').textContent = message;
     const list = document.getElementById('errorList');
-    list.innerHTML = details.map(d => `<li>${d}</li>`).join('');
+    list.innerHTML = details.map(d => `<li>${escapeHtml(d)}</li>`).join('');
     card.style.display = 'block';
     document.getElementById('results').style.display = 'none';
 }
@@ -235,7 +410,9 @@ function renderResults(data) {
     banner.querySelector('.risk-score').textContent = `Risk Score: ${data.riskScore} • ${suitability}`;
     document.getElementById('auditMeta').textContent = data.auditId
         ? `Audit ID: ${data.auditId}${data.auditAt ? ' • ' + data.auditAt : ''}`
-        : '';
+        : data.auditRecorded === false
+            ? 'Not audited: the audit log could not be written'
+            : '';
 
     const issuesList = document.getElementById('issuesList');
     const issues = Array.isArray(data.flaggedIssues) ? data.flaggedIssues : [];
@@ -245,12 +422,16 @@ function renderResults(data) {
         issuesList.innerHTML = issues.map(issue => {
             const icons = { danger: '🔴', warning: '🟠', info: '🔵' };
             const labels = { danger: 'SEVERE', warning: 'MODERATE', info: 'INFO' };
+            const ack = issue.acknowledged && issue.acknowledgment
+                ? `<div class="issue-ack">Acknowledged${issue.acknowledgment.userId ? ' by ' + escapeHtml(issue.acknowledgment.userId) : ''} on ${escapeHtml(issue.acknowledgment.at)}: ${escapeHtml(issue.acknowledgment.reason)}</div>`
+                : '';
             return `
-                <div class="issue-item ${issue.severity}">
+                <div class="issue-item ${escapeHtml(issue.severity)}${issue.acknowledged ? ' acknowledged' : ''}">
                     <div class="issue-icon">${icons[issue.severity]}</div>
                     <div>
-                        <div class="issue-title">${labels[issue.severity]}: ${issue.type.replace('_', ' ').toUpperCase()}</div>
-                        <div class="issue-desc">${issue.description}</div>
+                        <div class="issue-title">${labels[issue.severity]}: ${escapeHtml(issue.type.replace('_', ' ').toUpperCase())}</div>
+                        <div class="issue-desc">${escapeHtml(issue.description)}</div>
+                        ${ack}
                     </div>
                 </div>
             `;
@@ -260,37 +441,59 @@ function renderResults(data) {
     const plan = data.recommendedPlan || {};
     document.getElementById('treatmentPlan').innerHTML = `
         <div class="treatment-row">
-            <span class="treatment-label">Medication</span>
-            <span class="treatment-value">${plan.medication || '—'}</span>
+            <span class="treatment-label">${plan.review ? 'Review current' : 'Medication'}</span>
+            <span class="treatment-value">${escapeHtml(plan.medication || '—')}</span>
         </div>
         <div class="treatment-row">
             <span class="treatment-label">Dosage</span>
-            <span class="treatment-value">${plan.dosage || '—'}</span>
+            <span class="treatment-value">${escapeHtml(plan.dosage || '—')}</span>
         </div>
         <div class="treatment-row">
             <span class="treatment-label">Frequency</span>
-            <span class="treatment-value">${plan.frequency || '—'}</span>
+            <span class="treatment-value">${escapeHtml(plan.frequency || '—')}</span>
         </div>
         <div class="treatment-row">
             <span class="treatment-label">Duration</span>
-            <span class="treatment-value">${plan.duration || '—'}</span>
+            <span class="treatment-value">${escapeHtml(plan.duration || '—')}</span>
         </div>
     `;
     
-    document.getElementById('rationale').innerHTML = `<strong>Clinical Rationale:</strong><br>${plan.rationale || '—'}<br><br><strong>Confidence:</strong> ${data.planConfidence ? (data.planConfidence * 100).toFixed(0) + '%' : '—'}`;
+    document.getElementById('rationale').innerHTML = `<strong>Clinical Rationale:</strong><br>${escapeHtml(plan.rationale || '—')}<br><br><strong>Confidence:</strong> ${data.planConfidence ? (data.planConfidence * 100).toFixed(0) + '%' : '—'}`;
+
+    const monitoring = Array.isArray(plan.monitoring) ? plan.monitoring : [];
+    const followUp = plan.followUp;
+    document.getElementById('planFollowUp').innerHTML = monitoring.length === 0 && !followUp ? '' : `
+        <strong>Monitoring &amp; Follow-up:</strong>
+        <ul class="checklist">
+            ${monitoring.map(m => `<li><label><input type="checkbox"> ${escapeHtml(m)}</label></li>`).join('')}
+            ${followUp ? `<li><label><input type="checkbox"> Follow-up in ${escapeHtml(followUp.intervalDays)} days: ${escapeHtml(followUp.instructions)}</label></li>` : ''}
+        </ul>
+    `;
+
+    const education = Array.isArray(data.education) ? data.education : [];
+    document.getElementById('planEducation').innerHTML = education.length === 0 ? '' : `
+        <strong>Patient Education:</strong>
+        <ul class="checklist">
+            ${education.map(r => `<li><a href="${escapeHtml(r.url)}" target="_blank" rel="noopener noreferrer">${escapeHtml(r.title)}</a></li>`).join('')}
+        </ul>
+    `;
 
     const alternatives = Array.isArray(data.alternatives) ? data.alternatives : [];
     document.getElementById('alternatives').innerHTML = alternatives.length === 0
         ? '<p style="color: var(--color-text-secondary);">No alternatives provided.</p>'
         : alternatives.map(alt => `
             <div class="alternative-item">
-                <div class="alt-name">${alt.medication}</div>
-                <div class="alt-pros">✓ Pros: ${alt.pros.join(' • ')}</div>
-                <div class="alt-cons">✗ Cons: ${alt.cons.join(' • ')}</div>
+                <div class="alt-name">${escapeHtml(alt.medication)}</div>
+                <div class="alt-pros">✓ Pros: ${escapeHtml(alt.pros.join(' • '))}</div>
+                <div class="alt-cons">✗ Cons: ${escapeHtml(alt.cons.join(' • '))}</div>
                 <div style="margin-top: 6px; color: var(--color-text-secondary); font-size: 13px;">Confidence: ${alt.confidence ? (alt.confidence * 100).toFixed(0) + '%' : '—'}</div>
+                ${alt.suitability ? `<div style="margin-top: 4px; color: var(--color-text-secondary); font-size: 13px;">${escapeHtml(alt.suitability)}</div>` : ''}
             </div>
         `).join('');
 
+    const disclaimers = Array.isArray(data.disclaimers) ? data.disclaimers : [];
+    document.getElementById('disclaimers').innerHTML = disclaimers.map(d => `<p>${escapeHtml(d)}</p>`).join('');
+
     window.__lastResults = data;
 }
 
@@ -333,8 +536,19 @@ function startOver() {
     document.getElementById('height').value = '';
     document.getElementById('bp').value = '';
     document.getElementById('bmi').value = '';
+    ['waistCircumferenceCm', 'triglyceridesMgDl', 'hdlMgDl', 'a1cPercent'].forEach(id => {
+        document.getElementById(id).value = '';
+    });
     document.getElementById('allergies').value = '';
+    document.getElementById('familyHistory').value = '';
+    document.getElementById('smokingPackYears').value = '';
+    document.getElementById('smokingQuitYearsAgo').value = '';
+    document.getElementById('alcoholDrinksPerWeek').value = '';
+    document.getElementById('sleepHours').value = '';
     document.querySelectorAll('#conditions input').forEach(cb => cb.checked = false);
+    document.getElementById('confirmedNoConditions').checked = false;
+    document.getElementById('confirmedNoMedications').checked = false;
+    document.getElementById('confirmedNoAllergies').checked = false;
     document.getElementById('medications').innerHTML = `
         <div class="medication-entry">
             <input type="text" class="form-input med-name" placeholder="Drug name">
@@ -0,0 +1,477 @@
+// Package client calls the Clinical AI Assistant analyze and audit APIs.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/signing"
+	"github.com/Skufu/Clinical-AI-Assistant/types"
+)
+
+const (
+	defaultMaxRetries = 3
+	defaultBackoff    = 200 * time.Millisecond
+)
+
+// ErrNotFound is returned by GetAudit for an unknown audit ID.
+var ErrNotFound = errors.New("client: audit not found")
+
+// ErrSigningOff is returned by PublicKey when the server does not sign
+// responses.
+var ErrSigningOff = errors.New("client: response signing is off")
+
+// ErrUnsigned is returned by Verify for a response without a signature.
+var ErrUnsigned = errors.New("client: response is not signed")
+
+// ErrUnknownKey is returned by Verify when the response names a key other
+// than the one given, as after a key rotation; fetch PublicKey again.
+var ErrUnknownKey = errors.New("client: response signed with another key")
+
+// ErrBadSignature is returned by Verify when the signature does not match.
+var ErrBadSignature = signing.ErrBadSignature
+
+// ErrDecisionExists is returned by RecordDecision when the audit already has a
+// decision and the server does not accept revisions.
+var ErrDecisionExists = errors.New("client: decision already recorded")
+
+// ErrAlreadyAcknowledged is returned by AcknowledgeIssue when the issue of
+// that audit was acknowledged before.
+var ErrAlreadyAcknowledged = errors.New("client: issue already acknowledged")
+
+// Client calls the backend at BaseURL. The zero values of the other fields
+// are usable: no API key, http.DefaultClient, and three retries.
+type Client struct {
+	BaseURL string
+	// APIKey, when set, is sent as a bearer token.
+	APIKey     string
+	HTTPClient *http.Client
+	// MaxRetries bounds retries of 429 and 503 responses; negative disables them.
+	MaxRetries int
+	// Backoff is the base delay before the first retry; it doubles per attempt
+	// with jitter, and a longer Retry-After wins.
+	Backoff time.Duration
+}
+
+// ValidationError is returned when the server rejects an intake.
+type ValidationError struct {
+	Details []string
+	// AuditID references the server's record of a rejected analysis, if it
+	// keeps one.
+	AuditID string
+}
+
+func (e *ValidationError) Error() string {
+	return "client: validation failed: " + strings.Join(e.Details, "; ")
+}
+
+// HasCode reports whether any detail carries a validation code such as
+// types.ConsentRequired.
+func (e *ValidationError) HasCode(code string) bool {
+	for _, d := range e.Details {
+		if strings.HasPrefix(d, code+": ") {
+			return true
+		}
+	}
+	return false
+}
+
+// PatchError is returned by WhatIf when the server rejects a patch operation.
+type PatchError struct {
+	Index   int
+	Details []string
+}
+
+func (e *PatchError) Error() string {
+	return "client: invalid patch: " + strings.Join(e.Details, "; ")
+}
+
+// StatusError is returned for any other non-2xx response.
+type StatusError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration
+	// Problem is the decoded problem details body; nil when the response was
+	// not application/problem+json.
+	Problem *types.Problem
+}
+
+func (e *StatusError) Error() string {
+	if e.Problem != nil && e.Problem.Detail != "" {
+		return fmt.Sprintf("client: unexpected status %d: %s", e.StatusCode, e.Problem.Detail)
+	}
+	return fmt.Sprintf("client: unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+// AuditOptions filters LatestAudits; zero values use the server defaults.
+type AuditOptions struct {
+	Limit int
+	// RulesetVersion lists only analyses produced under that version.
+	RulesetVersion string
+	// Timezone is the IANA zone of each summary's LocalTime; empty is UTC.
+	Timezone string
+}
+
+// AnalyzeOptions adjusts a single analysis.
+type AnalyzeOptions struct {
+	// DryRun analyzes without writing an audit record; the Response has no
+	// AuditID.
+	DryRun bool
+	// IncludeNormalized asks for Response.NormalizedIntake, which shows how
+	// the rules read the intake.
+	IncludeNormalized bool
+}
+
+// Analyze submits an intake for analysis.
+func (c *Client) Analyze(ctx context.Context, in types.Intake) (types.Response, error) {
+	return c.AnalyzeWithOptions(ctx, in, AnalyzeOptions{})
+}
+
+// AnalyzeWithOptions submits an intake for analysis with opts applied.
+func (c *Client) AnalyzeWithOptions(ctx context.Context, in types.Intake, opts AnalyzeOptions) (types.Response, error) {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return types.Response{}, fmt.Errorf("client: marshal intake: %w", err)
+	}
+	q := url.Values{}
+	if opts.DryRun {
+		q.Set("dryRun", "true")
+	}
+	if opts.IncludeNormalized {
+		q.Set("includeNormalized", "true")
+	}
+	var out types.Response
+	err = c.do(ctx, http.MethodPost, "/api/analyze", q, body, &out)
+	return out, err
+}
+
+// AnalyzeBatch submits several intakes at once. Invalid items come back with
+// ValidationErrors set rather than failing the batch.
+func (c *Client) AnalyzeBatch(ctx context.Context, req types.BatchRequest) (types.BatchResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return types.BatchResponse{}, fmt.Errorf("client: marshal batch: %w", err)
+	}
+	var out types.BatchResponse
+	err = c.do(ctx, http.MethodPost, "/api/analyze/batch", nil, body, &out)
+	return out, err
+}
+
+// ValidateIntake checks an intake without analyzing it and returns field
+// errors, plausibility warnings, and a preview of the parsed values.
+func (c *Client) ValidateIntake(ctx context.Context, in types.Intake) (types.ValidationReport, error) {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return types.ValidationReport{}, fmt.Errorf("client: marshal intake: %w", err)
+	}
+	var out types.ValidationReport
+	err = c.do(ctx, http.MethodPost, "/api/validate", nil, body, &out)
+	return out, err
+}
+
+// Triage lists the follow-up questions a partial intake still needs answered
+// before analysis, blocking ones first.
+func (c *Client) Triage(ctx context.Context, in types.Intake) (types.TriageReport, error) {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return types.TriageReport{}, fmt.Errorf("client: marshal intake: %w", err)
+	}
+	var out types.TriageReport
+	err = c.do(ctx, http.MethodPost, "/api/triage", nil, body, &out)
+	return out, err
+}
+
+// CheckInteractions checks a medication list without a patient intake.
+// Findings come back grouped by the drugs involved.
+func (c *Client) CheckInteractions(ctx context.Context, meds []types.Medication) (types.InteractionReport, error) {
+	body, err := json.Marshal(types.InteractionRequest{Medications: meds})
+	if err != nil {
+		return types.InteractionReport{}, fmt.Errorf("client: marshal medications: %w", err)
+	}
+	var out types.InteractionReport
+	err = c.do(ctx, http.MethodPost, "/api/interactions", nil, body, &out)
+	return out, err
+}
+
+// PublicKey fetches the key the server signs responses with. It returns
+// ErrSigningOff when the server signs nothing.
+func (c *Client) PublicKey(ctx context.Context) (types.PublicKey, error) {
+	var out types.PublicKey
+	err := c.do(ctx, http.MethodGet, "/api/keys/public", nil, nil, &out)
+	var se *StatusError
+	if errors.As(err, &se) && se.StatusCode == http.StatusNotFound {
+		return types.PublicKey{}, ErrSigningOff
+	}
+	return out, err
+}
+
+// Verify checks that body, a response as received from /api/analyze or
+// /api/audit/{id}, was signed by key. keyID and signature are the values of
+// the types.SignatureKeyIDHeader and types.SignatureHeader headers. The
+// body may have been re-encoded since, with keys reordered or whitespace
+// changed, but not its values.
+func Verify(key types.PublicKey, body []byte, keyID, signature string) error {
+	if signature == "" {
+		return ErrUnsigned
+	}
+	if keyID != key.KeyID {
+		return fmt.Errorf("%w: %s, not %s", ErrUnknownKey, keyID, key.KeyID)
+	}
+	if key.Algorithm != signing.Algorithm {
+		return fmt.Errorf("client: unsupported signature algorithm %q", key.Algorithm)
+	}
+	pub, err := base64.StdEncoding.DecodeString(key.PublicKey)
+	if err != nil {
+		return fmt.Errorf("client: decode public key: %w", err)
+	}
+	return signing.Verify(pub, body, signature)
+}
+
+// LatestAudits lists recent audit summaries, newest last.
+func (c *Client) LatestAudits(ctx context.Context, opts AuditOptions) ([]types.AuditSummary, error) {
+	q := url.Values{}
+	if opts.Limit > 0 {
+		q.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.RulesetVersion != "" {
+		q.Set("rulesetVersion", opts.RulesetVersion)
+	}
+	if opts.Timezone != "" {
+		q.Set("tz", opts.Timezone)
+	}
+	var out []types.AuditSummary
+	err := c.do(ctx, http.MethodGet, "/api/audit", q, nil, &out)
+	return out, err
+}
+
+// GetAudit returns the response recorded for an audit ID, or ErrNotFound.
+func (c *Client) GetAudit(ctx context.Context, id string) (types.Response, error) {
+	var out types.Response
+	err := c.do(ctx, http.MethodGet, "/api/audit/"+url.PathEscape(id), nil, nil, &out)
+	var se *StatusError
+	if errors.As(err, &se) && se.StatusCode == http.StatusNotFound {
+		return types.Response{}, ErrNotFound
+	}
+	return out, err
+}
+
+// PatientAnalyses lists a patient's recent analyses, oldest first, with risk
+// trends. patientRef is the pseudonymous reference from AuditSummary.
+func (c *Client) PatientAnalyses(ctx context.Context, patientRef string, opts AuditOptions) (types.PatientHistory, error) {
+	q := url.Values{}
+	if opts.Limit > 0 {
+		q.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Timezone != "" {
+		q.Set("tz", opts.Timezone)
+	}
+	var out types.PatientHistory
+	err := c.do(ctx, http.MethodGet, "/api/patients/"+url.PathEscape(patientRef)+"/analyses", q, nil, &out)
+	return out, err
+}
+
+// WhatIf re-analyzes a stored audit's intake with req.Patch applied. It returns
+// ErrNotFound for an unknown audit and a *PatchError for a rejected operation.
+func (c *Client) WhatIf(ctx context.Context, req types.WhatIfRequest) (types.WhatIfResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return types.WhatIfResponse{}, fmt.Errorf("client: marshal what-if request: %w", err)
+	}
+	var out types.WhatIfResponse
+	err = c.do(ctx, http.MethodPost, "/api/analyze/whatif", nil, body, &out)
+	var se *StatusError
+	if errors.As(err, &se) && se.StatusCode == http.StatusNotFound {
+		return types.WhatIfResponse{}, ErrNotFound
+	}
+	return out, err
+}
+
+// Reanalyze replays the intake stored with auditID under the server's current
+// rules and returns the comparison with the stored response, or ErrNotFound.
+// The original audit is not modified.
+func (c *Client) Reanalyze(ctx context.Context, auditID string) (types.Reanalysis, error) {
+	var out types.Reanalysis
+	err := c.do(ctx, http.MethodPost, "/api/audit/"+url.PathEscape(auditID)+"/reanalyze", nil, nil, &out)
+	var se *StatusError
+	if errors.As(err, &se) && se.StatusCode == http.StatusNotFound {
+		return types.Reanalysis{}, ErrNotFound
+	}
+	return out, err
+}
+
+// RecordDecision records a clinician's approval, modification, or rejection of
+// the plan in an audited analysis. A rejected request comes back as a
+// *ValidationError with the reason.
+func (c *Client) RecordDecision(ctx context.Context, auditID string, req types.DecisionRequest) (types.Decision, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return types.Decision{}, fmt.Errorf("client: marshal decision: %w", err)
+	}
+	var out types.Decision
+	err = c.do(ctx, http.MethodPost, "/api/analyze/"+url.PathEscape(auditID)+"/decision", nil, body, &out)
+	var se *StatusError
+	if errors.As(err, &se) {
+		switch se.StatusCode {
+		case http.StatusNotFound:
+			return types.Decision{}, ErrNotFound
+		case http.StatusConflict:
+			return types.Decision{}, ErrDecisionExists
+		}
+	}
+	return out, err
+}
+
+// AcknowledgeIssue records that a clinician reviewed warning code on an
+// audited analysis; later analyses of the patient mark it acknowledged. It
+// returns ErrNotFound for an unknown audit or an issue the audit did not
+// flag, and a *StatusError with 422 for a danger issue.
+func (c *Client) AcknowledgeIssue(ctx context.Context, auditID, code string, req types.IssueAckRequest) (types.IssueAcknowledgment, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return types.IssueAcknowledgment{}, fmt.Errorf("client: marshal acknowledgment: %w", err)
+	}
+	var out types.IssueAcknowledgment
+	err = c.do(ctx, http.MethodPost, "/api/audit/"+url.PathEscape(auditID)+"/issues/"+url.PathEscape(code)+"/ack", nil, body, &out)
+	var se *StatusError
+	if errors.As(err, &se) {
+		switch se.StatusCode {
+		case http.StatusNotFound:
+			return types.IssueAcknowledgment{}, ErrNotFound
+		case http.StatusConflict:
+			return types.IssueAcknowledgment{}, ErrAlreadyAcknowledged
+		}
+	}
+	return out, err
+}
+
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable
+}
+
+// do sends the request, retrying 429 and 503 responses, and decodes a 2xx body into out.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body []byte, out any) error {
+	retries := c.MaxRetries
+	if retries == 0 {
+		retries = defaultMaxRetries
+	} else if retries < 0 {
+		retries = 0
+	}
+	var lastErr error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			delay := c.backoff(attempt)
+			var se *StatusError
+			if errors.As(lastErr, &se) && se.RetryAfter > delay {
+				delay = se.RetryAfter
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+		err := c.send(ctx, method, path, query, body, out)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		var se *StatusError
+		if !errors.As(err, &se) || !retryable(se.StatusCode) {
+			return err
+		}
+	}
+	return fmt.Errorf("client: retries exhausted: %w", lastErr)
+}
+
+func (c *Client) backoff(attempt int) time.Duration {
+	base := c.Backoff
+	if base <= 0 {
+		base = defaultBackoff
+	}
+	base <<= attempt - 1
+	return base + time.Duration(rand.Int63n(int64(base)))
+}
+
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body []byte, out any) error {
+	u := strings.TrimRight(c.BaseURL, "/") + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return fmt.Errorf("client: build request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json, application/problem+json")
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("client: request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return statusError(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("client: decode response: %w", err)
+	}
+	return nil
+}
+
+// statusError maps a non-2xx response to ValidationError, PatchError, or
+// StatusError by its problem type.
+func statusError(resp *http.Response) error {
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var problem *types.Problem
+	if mediaType, _, _ := strings.Cut(resp.Header.Get("Content-Type"), ";"); strings.TrimSpace(mediaType) == "application/problem+json" {
+		var p types.Problem
+		if json.Unmarshal(raw, &p) == nil {
+			problem = &p
+		}
+	}
+	if problem != nil {
+		switch problem.Type {
+		case types.ProblemValidationFailed:
+			return &ValidationError{Details: problem.Errors, AuditID: problem.AuditID}
+		case types.ProblemInvalidPatch:
+			pe := &PatchError{Details: problem.Errors}
+			if problem.Op != nil {
+				pe.Index = *problem.Op
+			}
+			return pe
+		}
+	}
+	se := &StatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(raw)), Problem: problem}
+	if len(se.Body) > 512 {
+		se.Body = se.Body[:512]
+	}
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+		se.RetryAfter = time.Duration(secs) * time.Second
+	}
+	return se
+}
@@ -0,0 +1,283 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/server"
+	"github.com/Skufu/Clinical-AI-Assistant/types"
+)
+
+var sampleIntake = types.Intake{PatientName: "Jane", Age: 45, WeightKg: 70, HeightCm: 170, BP: "120/80", Complaint: "ED"}
+
+// newTestClient serves the real API handlers, optionally wrapped.
+func newTestClient(t *testing.T, wrap func(http.Handler) http.Handler) *Client {
+	t.Helper()
+	var h http.Handler = server.New(server.Config{Analyzer: analysis.New()})
+	if wrap != nil {
+		h = wrap(h)
+	}
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return &Client{BaseURL: srv.URL, HTTPClient: srv.Client(), Backoff: time.Millisecond}
+}
+
+func TestClient_AnalyzeAndReadAudits(t *testing.T) {
+	c := newTestClient(t, nil)
+	resp, err := c.Analyze(t.Context(), sampleIntake)
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	if resp.AuditID == "" || resp.RiskLevel == "" {
+		t.Fatalf("incomplete response: %+v", resp)
+	}
+
+	audits, err := c.LatestAudits(t.Context(), AuditOptions{Limit: 5})
+	if err != nil {
+		t.Fatalf("latest audits: %v", err)
+	}
+	if len(audits) != 1 || audits[0].AuditID != resp.AuditID {
+		t.Fatalf("audits = %+v, want %s", audits, resp.AuditID)
+	}
+	local, err := c.LatestAudits(t.Context(), AuditOptions{Limit: 5, Timezone: "Asia/Manila"})
+	if err != nil || len(local) != 1 || !strings.HasSuffix(local[0].LocalTime, "+08:00") {
+		t.Fatalf("audits in Asia/Manila = %+v, %v", local, err)
+	}
+
+	stored, err := c.GetAudit(t.Context(), resp.AuditID)
+	if err != nil {
+		t.Fatalf("get audit: %v", err)
+	}
+	if !reflect.DeepEqual(stored, resp) {
+		t.Fatalf("stored response differs:\n got %+v\nwant %+v", stored, resp)
+	}
+
+	if _, err := c.GetAudit(t.Context(), "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("missing audit: %v, want ErrNotFound", err)
+	}
+
+	history, err := c.PatientAnalyses(t.Context(), audits[0].PatientRef, AuditOptions{Limit: 5})
+	if err != nil {
+		t.Fatalf("patient analyses: %v", err)
+	}
+	if len(history.Analyses) != 1 || history.Analyses[0].AuditID != resp.AuditID || history.Trend != nil {
+		t.Fatalf("history = %+v", history)
+	}
+
+	whatIf, err := c.WhatIf(t.Context(), types.WhatIfRequest{AuditID: resp.AuditID, Patch: []types.PatchOp{{Op: "replace", Path: "/bp", Value: "170/105"}}})
+	if err != nil || whatIf.Diff.RiskScoreDelta <= 0 {
+		t.Fatalf("what-if: %+v (err %v)", whatIf.Diff, err)
+	}
+	var pe *PatchError
+	if _, err := c.WhatIf(t.Context(), types.WhatIfRequest{AuditID: resp.AuditID, Patch: []types.PatchOp{{Op: "move", Path: "/bp"}}}); !errors.As(err, &pe) || pe.Index != 0 {
+		t.Fatalf("bad patch: %v, want *PatchError", err)
+	}
+	d, err := c.RecordDecision(t.Context(), resp.AuditID, types.DecisionRequest{Decision: "approved", UserID: "dr-a"})
+	if err != nil || d.Revision != 1 {
+		t.Fatalf("decision: %+v (err %v)", d, err)
+	}
+	if _, err := c.RecordDecision(t.Context(), resp.AuditID, types.DecisionRequest{Decision: "rejected", Reason: "x"}); !errors.Is(err, ErrDecisionExists) {
+		t.Fatalf("second decision: %v, want ErrDecisionExists", err)
+	}
+	if _, err := c.AcknowledgeIssue(t.Context(), resp.AuditID, "NOT_FLAGGED", types.IssueAckRequest{Reason: "seen"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("ack of an unflagged issue: %v, want ErrNotFound", err)
+	}
+	vr, err := c.ValidateIntake(t.Context(), types.Intake{PatientName: "Jane", Age: 45, WeightKg: 70, HeightCm: 170, BP: "120/80"})
+	if err != nil || vr.Valid || len(vr.Errors) != 1 || vr.Errors[0].Field != "complaint" || vr.Preview.Systolic != 120 {
+		t.Fatalf("validate: %+v (err %v)", vr, err)
+	}
+	tr, err := c.Triage(t.Context(), types.Intake{Complaint: "ED"})
+	if err != nil || len(tr.Questions) == 0 || !tr.Questions[0].Blocking {
+		t.Fatalf("triage: %+v (err %v)", tr, err)
+	}
+	report, err := c.CheckInteractions(t.Context(), []types.Medication{{Name: "tadalafil"}, {Name: "nitroglycerin"}})
+	if err != nil || len(report.Pairs) != 1 || report.Pairs[0].Issues[0].Code != "CI_NITRATE_PDE5" {
+		t.Fatalf("interactions: %+v (err %v)", report, err)
+	}
+	var ve *ValidationError
+	if _, err := c.RecordDecision(t.Context(), resp.AuditID, types.DecisionRequest{Decision: "maybe"}); !errors.As(err, &ve) {
+		t.Fatalf("invalid decision: %v, want *ValidationError", err)
+	}
+}
+
+func TestClient_VerifySignedResponse(t *testing.T) {
+	if _, err := newTestClient(t, nil).PublicKey(t.Context()); !errors.Is(err, ErrSigningOff) {
+		t.Fatalf("unsigned server: %v, want ErrSigningOff", err)
+	}
+
+	a := analysis.New()
+	_, priv, _ := ed25519.GenerateKey(nil)
+	if err := a.SetSigningKey("", priv); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(server.New(server.Config{Analyzer: a}))
+	t.Cleanup(srv.Close)
+	c := &Client{BaseURL: srv.URL, HTTPClient: srv.Client()}
+	key, err := c.PublicKey(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := json.Marshal(sampleIntake)
+	res, err := srv.Client().Post(srv.URL+"/api/analyze", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	raw, _ := io.ReadAll(res.Body)
+	keyID, sig := res.Header.Get(types.SignatureKeyIDHeader), res.Header.Get(types.SignatureHeader)
+	if err := Verify(key, raw, keyID, sig); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	// A consumer that stored the decoded response re-encodes it differently.
+	var resp types.Response
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		t.Fatal(err)
+	}
+	reencoded, _ := json.MarshalIndent(resp, "", "  ")
+	if err := Verify(key, reencoded, keyID, sig); err != nil {
+		t.Fatalf("verify re-encoded: %v", err)
+	}
+	resp.RiskScore++
+	altered, _ := json.Marshal(resp)
+	if err := Verify(key, altered, keyID, sig); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("altered response: %v, want ErrBadSignature", err)
+	}
+	if err := Verify(key, raw, "retired", sig); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("other key ID: %v, want ErrUnknownKey", err)
+	}
+	if err := Verify(key, raw, "", ""); !errors.Is(err, ErrUnsigned) {
+		t.Fatalf("no signature: %v, want ErrUnsigned", err)
+	}
+}
+
+func TestClient_ValidationError(t *testing.T) {
+	c := newTestClient(t, nil)
+	_, err := c.Analyze(t.Context(), types.Intake{PatientName: "Jane"})
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("err = %v, want ValidationError", err)
+	}
+	if len(verr.Details) == 0 {
+		t.Fatal("validation error has no details")
+	}
+}
+
+func TestClient_ProblemDetails(t *testing.T) {
+	c := newTestClient(t, nil)
+	var out any
+	err := c.do(t.Context(), http.MethodGet, "/api/nope", nil, nil, &out)
+	var se *StatusError
+	if !errors.As(err, &se) || se.Problem == nil {
+		t.Fatalf("err = %v, want *StatusError with a problem", err)
+	}
+	if se.StatusCode != http.StatusNotFound || se.Problem.Status != se.StatusCode || se.Problem.Instance == "" {
+		t.Fatalf("problem = %+v", se.Problem)
+	}
+}
+
+func TestClient_RetriesThrottledRequests(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch calls.Add(1) {
+			case 1:
+				w.WriteHeader(http.StatusTooManyRequests)
+			case 2:
+				w.WriteHeader(http.StatusServiceUnavailable)
+			default:
+				next.ServeHTTP(w, r)
+			}
+		})
+	})
+	if _, err := c.Analyze(t.Context(), sampleIntake); err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Fatalf("calls = %d, want 3", got)
+	}
+
+	calls.Store(0)
+	c.MaxRetries = -1
+	var se *StatusError
+	if _, err := c.Analyze(t.Context(), sampleIntake); !errors.As(err, &se) || se.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("err = %v, want 429 without retries", err)
+	}
+}
+
+func TestClient_HonorsContext(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	c := newTestClient(t, func(http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		})
+	})
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.Analyze(ctx, sampleIntake); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestClient_DryRun(t *testing.T) {
+	c := newTestClient(t, nil)
+	resp, err := c.AnalyzeWithOptions(t.Context(), sampleIntake, AnalyzeOptions{DryRun: true})
+	if err != nil || !resp.DryRun || resp.AuditID != "" {
+		t.Fatalf("dry run: %+v (err %v)", resp, err)
+	}
+
+	record := false
+	batch, err := c.AnalyzeBatch(t.Context(), types.BatchRequest{DryRun: true, Items: []types.BatchItem{
+		{Intake: sampleIntake},
+		{Intake: sampleIntake, DryRun: &record},
+	}})
+	if err != nil || len(batch.Results) != 2 {
+		t.Fatalf("batch: %+v (err %v)", batch, err)
+	}
+	if !batch.Results[0].DryRun || batch.Results[1].AuditID == "" {
+		t.Fatalf("batch results: %+v", batch.Results)
+	}
+	if audits, err := c.LatestAudits(t.Context(), AuditOptions{}); err != nil || len(audits) != 1 {
+		t.Fatalf("audits = %+v (err %v), want only the recorded item", audits, err)
+	}
+}
+
+func TestClient_ConsentRequired(t *testing.T) {
+	a := analysis.New()
+	a.SetConsentRequired(true)
+	srv := httptest.NewServer(server.New(server.Config{Analyzer: a}))
+	t.Cleanup(srv.Close)
+	c := &Client{BaseURL: srv.URL, HTTPClient: srv.Client()}
+
+	_, err := c.Analyze(t.Context(), sampleIntake)
+	var verr *ValidationError
+	if !errors.As(err, &verr) || !verr.HasCode(types.ConsentRequired) {
+		t.Fatalf("err = %v, want %s", err, types.ConsentRequired)
+	}
+
+	in := sampleIntake
+	in.Consent = &types.Consent{Given: true, Timestamp: "2025-03-01T09:00:00Z", Method: "electronic"}
+	if resp, err := c.Analyze(t.Context(), in); err != nil || resp.AuditID == "" {
+		t.Fatalf("analyze with consent: %+v (err %v)", resp, err)
+	}
+	audits, err := c.LatestAudits(t.Context(), AuditOptions{})
+	if err != nil || len(audits) != 1 || audits[0].Consent == nil || audits[0].Consent.Method != "electronic" {
+		t.Fatalf("audits = %+v (err %v)", audits, err)
+	}
+}
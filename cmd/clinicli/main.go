@@ -0,0 +1,404 @@
+// Command clinicli runs the rules engine on intake JSON files without the HTTP
+// server.
+//
+// Usage:
+//
+//	clinicli analyze [--format json|table] file.json
+//	clinicli analyze [--format json|table] --batch dir/
+//	clinicli validate [--format json|table] file.json
+//	clinicli bundle apply [--force] --trust-key key[,key] --into path bundle.tar.gz
+//
+// Batch mode writes <name>.result.json next to each input. bundle apply
+// checks a bundle exported by GET /api/admin/bundle against the trusted
+// public keys and validates it, then atomically installs it at the
+// server's BUNDLE_PATH; --force allows a lower version than the one
+// installed. The exit status is 0 on success, 1 when any analysis is HIGH or
+// CRITICAL risk or any intake is invalid, and 2 on usage or I/O errors or a
+// refused bundle.
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/signing"
+)
+
+const (
+	exitOK      = 0
+	exitFlagged = 1
+	exitError   = 2
+
+	resultSuffix = ".result.json"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+const usage = `usage:
+  clinicli analyze [--format json|table] file.json
+  clinicli analyze [--format json|table] --batch dir/
+  clinicli validate [--format json|table] file.json
+  clinicli bundle apply [--force] --trust-key key[,key] --into path bundle.tar.gz
+`
+
+// cli holds the output settings shared by the subcommands.
+type cli struct {
+	a      *analysis.Analyzer
+	stdout io.Writer
+	stderr io.Writer
+	format string
+	color  bool
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitError
+	}
+	fs := flag.NewFlagSet("clinicli "+args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	format := fs.String("format", "table", "output format: json or table")
+	batch := fs.String("batch", "", "analyze every *.json file in this directory")
+	force := fs.Bool("force", false, "install a bundle older than the installed one")
+	into := fs.String("into", "", "where bundle apply installs the bundle")
+	trustKeys := fs.String("trust-key", "", "comma-separated Ed25519 public keys, base64 or hex, that may sign bundles")
+	rest, err := parseInterleaved(fs, args[1:])
+	if err != nil {
+		return exitError
+	}
+	if args[0] != "bundle" && (*force || *into != "" || *trustKeys != "") {
+		fmt.Fprint(stderr, usage)
+		return exitError
+	}
+	if *format != "json" && *format != "table" {
+		fmt.Fprintf(stderr, "unknown --format %q; want json or table\n", *format)
+		return exitError
+	}
+	c := &cli{a: analysis.New(), stdout: stdout, stderr: stderr, format: *format, color: colorEnabled(stdout)}
+
+	switch args[0] {
+	case "analyze":
+		if *batch != "" {
+			if len(rest) != 0 {
+				fmt.Fprint(stderr, usage)
+				return exitError
+			}
+			return c.analyzeBatch(*batch)
+		}
+		if len(rest) != 1 {
+			fmt.Fprint(stderr, usage)
+			return exitError
+		}
+		return c.analyzeFile(rest[0])
+	case "validate":
+		if len(rest) != 1 || *batch != "" {
+			fmt.Fprint(stderr, usage)
+			return exitError
+		}
+		return c.validateFile(rest[0])
+	case "bundle":
+		if len(rest) != 2 || rest[0] != "apply" || *into == "" || *batch != "" {
+			fmt.Fprint(stderr, usage)
+			return exitError
+		}
+		return c.applyBundle(rest[1], *into, *trustKeys, *force)
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n%s", args[0], usage)
+		return exitError
+	}
+}
+
+// parseInterleaved lets flags follow positional arguments, which flag.Parse
+// stops at.
+func parseInterleaved(fs *flag.FlagSet, args []string) ([]string, error) {
+	var rest []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return rest, nil
+		}
+		rest = append(rest, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func readIntake(path string) (analysis.Intake, error) {
+	var in analysis.Intake
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return in, err
+	}
+	if err := json.Unmarshal(raw, &in); err != nil {
+		return in, fmt.Errorf("%s: invalid intake JSON: %w", path, err)
+	}
+	return in, nil
+}
+
+// flagged reports whether resp should fail a pipeline.
+func flagged(resp analysis.Response) bool {
+	switch resp.RiskLevel {
+	case analysis.RiskHigh, analysis.RiskCritical, analysis.RiskInvalid:
+		return true
+	}
+	return len(resp.ValidationErrors) > 0
+}
+
+func (c *cli) analyzeFile(path string) int {
+	in, err := readIntake(path)
+	if err != nil {
+		fmt.Fprintln(c.stderr, err)
+		return exitError
+	}
+	resp := c.a.Analyze(in)
+	if c.format == "json" {
+		if err := writeJSON(c.stdout, resp); err != nil {
+			fmt.Fprintln(c.stderr, err)
+			return exitError
+		}
+	} else {
+		c.printResponse(path, resp)
+	}
+	if flagged(resp) {
+		return exitFlagged
+	}
+	return exitOK
+}
+
+// batchResult is one line of the JSON batch summary.
+type batchResult struct {
+	File             string             `json:"file"`
+	Output           string             `json:"output"`
+	RiskLevel        analysis.RiskLevel `json:"riskLevel"`
+	RiskScore        int                `json:"riskScore"`
+	AuditID          string             `json:"auditId,omitempty"`
+	ValidationErrors []string           `json:"validationErrors,omitempty"`
+}
+
+func (c *cli) analyzeBatch(dir string) int {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		fmt.Fprintln(c.stderr, err)
+		return exitError
+	}
+	sort.Strings(paths)
+
+	code := exitOK
+	results := []batchResult{}
+	for _, path := range paths {
+		if strings.HasSuffix(path, resultSuffix) {
+			continue
+		}
+		in, err := readIntake(path)
+		if err != nil {
+			fmt.Fprintln(c.stderr, err)
+			code = exitError
+			continue
+		}
+		resp := c.a.Analyze(in)
+		out := strings.TrimSuffix(path, ".json") + resultSuffix
+		if err := writeFileJSON(out, resp); err != nil {
+			fmt.Fprintln(c.stderr, err)
+			code = exitError
+			continue
+		}
+		if flagged(resp) && code == exitOK {
+			code = exitFlagged
+		}
+		if c.format == "table" {
+			c.printSummary(path, resp)
+		}
+		results = append(results, batchResult{
+			File:             path,
+			Output:           out,
+			RiskLevel:        resp.RiskLevel,
+			RiskScore:        resp.RiskScore,
+			AuditID:          resp.AuditID,
+			ValidationErrors: resp.ValidationErrors,
+		})
+	}
+	if c.format == "json" {
+		if err := writeJSON(c.stdout, results); err != nil {
+			fmt.Fprintln(c.stderr, err)
+			return exitError
+		}
+	} else if len(results) == 0 {
+		fmt.Fprintf(c.stdout, "no intake files in %s\n", dir)
+	}
+	return code
+}
+
+func (c *cli) validateFile(path string) int {
+	in, err := readIntake(path)
+	if err != nil {
+		fmt.Fprintln(c.stderr, err)
+		return exitError
+	}
+	errs := c.a.Validate(in)
+	if c.format == "json" {
+		if errs == nil {
+			errs = []string{}
+		}
+		if err := writeJSON(c.stdout, map[string]any{"valid": len(errs) == 0, "errors": errs}); err != nil {
+			fmt.Fprintln(c.stderr, err)
+			return exitError
+		}
+	} else if len(errs) == 0 {
+		fmt.Fprintf(c.stdout, "%s: valid\n", path)
+	} else {
+		fmt.Fprintf(c.stdout, "%s: %d validation error(s)\n", path, len(errs))
+		for _, e := range errs {
+			fmt.Fprintf(c.stdout, "  - %s\n", e)
+		}
+	}
+	if len(errs) > 0 {
+		return exitFlagged
+	}
+	return exitOK
+}
+
+func (c *cli) applyBundle(path, into, trustKeys string, force bool) int {
+	var trusted []ed25519.PublicKey
+	for _, s := range strings.Split(trustKeys, ",") {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		pub, err := signing.ParsePublicKey(s)
+		if err != nil {
+			fmt.Fprintf(c.stderr, "invalid --trust-key: %v\n", err)
+			return exitError
+		}
+		trusted = append(trusted, pub)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(c.stderr, err)
+		return exitError
+	}
+	m, err := analysis.InstallBundleFile(into, data, trusted, force)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "%s: %v\n", path, err)
+		if errors.Is(err, analysis.ErrBundleDowngrade) {
+			fmt.Fprintln(c.stderr, "pass --force to install it anyway")
+		}
+		return exitError
+	}
+	if c.format == "json" {
+		if err := writeJSON(c.stdout, m); err != nil {
+			fmt.Fprintln(c.stderr, err)
+			return exitError
+		}
+		return exitOK
+	}
+	fmt.Fprintf(c.stdout, "%s: installed bundle version %d (%s) at %s; restart the server to apply it\n", path, m.Version, m.Hash, into)
+	return exitOK
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func writeFileJSON(path string, v any) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeJSON(f, v); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// colorEnabled reports whether w is a terminal and NO_COLOR is unset.
+func colorEnabled(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+var severityColors = map[string]string{
+	"danger":   "\033[31m",
+	"warning":  "\033[33m",
+	"info":     "\033[36m",
+	"HIGH":     "\033[31m",
+	"CRITICAL": "\033[1;31m",
+	"MEDIUM":   "\033[33m",
+	"LOW":      "\033[32m",
+	"INVALID":  "\033[31m",
+}
+
+func (c *cli) paint(key, text string) string {
+	if !c.color || severityColors[key] == "" {
+		return text
+	}
+	return severityColors[key] + text + "\033[0m"
+}
+
+func (c *cli) printSummary(path string, resp analysis.Response) {
+	fmt.Fprintf(c.stdout, "%s: risk=%s score=%d issues=%d\n", path, c.paint(string(resp.RiskLevel), string(resp.RiskLevel)), resp.RiskScore, len(resp.FlaggedIssues))
+}
+
+func (c *cli) printResponse(path string, resp analysis.Response) {
+	w := c.stdout
+	if len(resp.ValidationErrors) > 0 && resp.RiskLevel == analysis.RiskInvalid {
+		fmt.Fprintf(w, "%s: %s\n", path, c.paint("INVALID", "INVALID"))
+		for _, e := range resp.ValidationErrors {
+			fmt.Fprintf(w, "  - %s\n", e)
+		}
+		return
+	}
+	fmt.Fprintf(w, "%s\n", path)
+	fmt.Fprintf(w, "  Risk:        %s (score %d, normalized %d)\n", c.paint(string(resp.RiskLevel), string(resp.RiskLevel)), resp.RiskScore, resp.RiskScoreNormalized)
+	fmt.Fprintf(w, "  BMI:         %.1f\n", resp.ComputedBMI)
+	p := resp.RecommendedPlan
+	fmt.Fprintf(w, "  Plan:        %s %s %s (%s)\n", p.Medication, p.Dosage, p.Frequency, p.Duration)
+	for _, m := range p.Monitoring {
+		fmt.Fprintf(w, "    [ ] %s\n", m)
+	}
+	if p.FollowUp != nil {
+		fmt.Fprintf(w, "  Follow-up:   %d days, %s\n", p.FollowUp.IntervalDays, p.FollowUp.Instructions)
+	}
+	if resp.PlanConfidence > 0 {
+		fmt.Fprintf(w, "  Confidence:  %.2f\n", resp.PlanConfidence)
+	}
+	if len(resp.FlaggedIssues) > 0 {
+		fmt.Fprintf(w, "  Issues:\n")
+		for _, is := range resp.FlaggedIssues {
+			sev := fmt.Sprintf("%-7s", strings.ToUpper(string(is.Severity)))
+			fmt.Fprintf(w, "    %s %s: %s\n", c.paint(string(is.Severity), sev), is.Code, is.Description)
+		}
+	}
+	if len(resp.Alternatives) > 0 {
+		fmt.Fprintf(w, "  Alternatives:\n")
+		for _, alt := range resp.Alternatives {
+			fmt.Fprintf(w, "    - %s %s\n", alt.Medication, alt.Dosage)
+		}
+	}
+	for _, e := range resp.ValidationErrors {
+		fmt.Fprintf(w, "  Error:       %s\n", e)
+	}
+	if resp.AuditID != "" {
+		fmt.Fprintf(w, "  Audit:       %s\n", resp.AuditID)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+)
+
+const (
+	lowIntake  = `{"patientName":"Jane","age":45,"weight":70,"height":170,"bp":"120/80","complaint":"Hair Loss"}`
+	highIntake = `{"patientName":"High Risk","age":68,"weight":90,"height":170,"bp":"168/102",
+		"conditions":["Heart Disease","Hypertension"],
+		"medications":[{"name":"Nitroglycerin","dosage":"0.4mg","frequency":"PRN"}],"complaint":"ED"}`
+)
+
+func writeFile(t *testing.T, dir, name, body string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func runCLI(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestAnalyze_ExitCodeGatesHighRisk(t *testing.T) {
+	dir := t.TempDir()
+	low := writeFile(t, dir, "low.json", lowIntake)
+	high := writeFile(t, dir, "high.json", highIntake)
+
+	code, out, _ := runCLI("analyze", low, "--format", "json")
+	if code != exitOK {
+		t.Fatalf("low risk exit = %d, want %d", code, exitOK)
+	}
+	var resp struct {
+		RiskLevel string `json:"riskLevel"`
+	}
+	if err := json.Unmarshal([]byte(out), &resp); err != nil || resp.RiskLevel != "LOW" {
+		t.Fatalf("json output = %q (%v)", out, err)
+	}
+
+	code, out, _ = runCLI("analyze", "--format", "table", high)
+	if code != exitFlagged {
+		t.Fatalf("high risk exit = %d, want %d", code, exitFlagged)
+	}
+	if !strings.Contains(out, "HIGH") || !strings.Contains(out, "CI_NITRATE_PRN_PDE5") {
+		t.Fatalf("table output missing risk or issue:\n%s", out)
+	}
+}
+
+func TestAnalyze_BatchWritesResultsAlongsideInputs(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.json", lowIntake)
+	writeFile(t, dir, "b.json", highIntake)
+
+	code, _, stderr := runCLI("analyze", "--batch", dir)
+	if code != exitFlagged {
+		t.Fatalf("exit = %d, want %d (stderr %q)", code, exitFlagged, stderr)
+	}
+	for _, name := range []string{"a.result.json", "b.result.json"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("missing %s: %v", name, err)
+		}
+	}
+
+	// Re-running must not treat earlier results as inputs.
+	code, out, _ := runCLI("analyze", "--batch", dir, "--format", "json")
+	var results []batchResult
+	if err := json.Unmarshal([]byte(out), &results); err != nil || len(results) != 2 {
+		t.Fatalf("batch summary = %q (%v)", out, err)
+	}
+	if code != exitFlagged {
+		t.Fatalf("rerun exit = %d, want %d", code, exitFlagged)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	if code, _, _ := runCLI("validate", writeFile(t, dir, "ok.json", lowIntake)); code != exitOK {
+		t.Fatalf("valid intake exit = %d", code)
+	}
+	code, out, _ := runCLI("validate", writeFile(t, dir, "bad.json", `{"patientName":"Jane"}`))
+	if code != exitFlagged || !strings.Contains(out, "age must be greater than 0") {
+		t.Fatalf("invalid intake: exit %d, output %q", code, out)
+	}
+	if code, _, _ := runCLI("validate", filepath.Join(dir, "missing.json")); code != exitError {
+		t.Fatalf("missing file exit = %d, want %d", code, exitError)
+	}
+	if code, _, _ := runCLI("analyze", "--format", "xml", "x.json"); code != exitError {
+		t.Fatalf("bad format exit = %d, want %d", code, exitError)
+	}
+}
+
+func TestBundleApply(t *testing.T) {
+	dir := t.TempDir()
+	priv := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{7}, ed25519.SeedSize))
+	a := analysis.New()
+	if err := a.SetSigningKey("", priv); err != nil {
+		t.Fatal(err)
+	}
+	export := func(version int) string {
+		data, _, err := a.ExportBundle(version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return writeFile(t, dir, fmt.Sprintf("v%d.tar.gz", version), string(data))
+	}
+	key := base64.StdEncoding.EncodeToString(priv.Public().(ed25519.PublicKey))
+	into := filepath.Join(dir, "installed.tar.gz")
+
+	code, out, stderr := runCLI("bundle", "apply", export(2), "--trust-key", key, "--into", into)
+	if code != exitOK || !strings.Contains(out, "installed bundle version 2") {
+		t.Fatalf("apply: exit %d, stdout %q, stderr %q", code, out, stderr)
+	}
+	older := export(1)
+	code, _, stderr = runCLI("bundle", "apply", older, "--trust-key", key, "--into", into)
+	if code != exitError || !strings.Contains(stderr, "--force") {
+		t.Fatalf("downgrade: exit %d, stderr %q", code, stderr)
+	}
+	code, out, _ = runCLI("bundle", "apply", "--force", "--format", "json", older, "--trust-key", key, "--into", into)
+	var m analysis.BundleManifest
+	if err := json.Unmarshal([]byte(out), &m); code != exitOK || err != nil || m.Version != 1 {
+		t.Fatalf("forced downgrade: exit %d, output %q", code, out)
+	}
+
+	if code, _, _ := runCLI("bundle", "apply", older, "--into", into); code != exitError {
+		t.Fatalf("no trusted key exit = %d, want %d", code, exitError)
+	}
+	if code, _, _ := runCLI("bundle", "apply", older); code != exitError {
+		t.Fatalf("no --into exit = %d, want %d", code, exitError)
+	}
+	if code, _, _ := runCLI("validate", "--force", "x.json"); code != exitError {
+		t.Fatalf("bundle flag on validate exit = %d, want %d", code, exitError)
+	}
+}
@@ -0,0 +1,375 @@
+// Command drugsync rebuilds the drug classes of a ruleset from the RxNorm
+// RXNCONSO.RRF and RXNREL.RRF flat files, so generic and brand names track
+// the current RxNorm release without the analysis package reading it.
+//
+// Usage:
+//
+//	drugsync --rxnorm dir/ [--rules rules.json] [--out synced.json]
+//
+// The active taxonomy is the ruleset at --rules, or the built-in one. Each
+// class keeps its generic members, gains the RxNorm ingredients under the
+// class's ATC codes, and has its brand names rebuilt from the tradename links
+// of those ingredients. Brands of several ingredients are left to the
+// combination table and listed in the report. The ruleset is written to
+// --out (stdout by default) in the form RULES_PATH loads, and the diff against
+// the active taxonomy goes to stderr. The exit status is 0 when the taxonomy
+// is unchanged, 1 when it changed, and 2 on usage or I/O errors.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+)
+
+const (
+	exitOK      = 0
+	exitChanged = 1
+	exitError   = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+const usage = `usage:
+  drugsync --rxnorm dir/ [--rules rules.json] [--out synced.json]
+`
+
+// classATC lists the ATC codes whose ingredients belong to each built-in
+// class, by lowercased class name. A code matches by prefix. Classes not
+// listed keep their own members and only gain brand names.
+var classATC = map[string][]string{
+	"pde5 inhibitor":               {"G04BE03", "G04BE08", "G04BE09", "G04BE10"},
+	"alpha-blocker":                {"G04CA", "C02CA"},
+	"nitrate":                      {"C01DA"},
+	"statin":                       {"C10AA"},
+	"calcium channel blocker":      {"C08"},
+	"ace inhibitor":                {"C09AA"},
+	"angiotensin receptor blocker": {"C09CA"},
+	"5-alpha-reductase inhibitor":  {"G04CB"},
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("drugsync", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dir := fs.String("rxnorm", "", "directory holding RXNCONSO.RRF and RXNREL.RRF")
+	rulesPath := fs.String("rules", "", "active ruleset JSON (default: the built-in ruleset)")
+	out := fs.String("out", "", "write the synced ruleset here instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if *dir == "" || fs.NArg() != 0 {
+		fmt.Fprint(stderr, usage)
+		return exitError
+	}
+
+	a := analysis.New()
+	if *rulesPath != "" {
+		if err := a.LoadRulesFile(*rulesPath); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+	}
+	active := a.Rules().Ruleset
+
+	rx, err := loadRxNorm(*dir)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	synced, rep := rx.sync(active.DrugClasses)
+	next := active
+	next.DrugClasses = synced
+	if errs := next.Validate(); len(errs) > 0 {
+		fmt.Fprintln(stderr, (&analysis.RulesetError{Errors: errs}).Error())
+		return exitError
+	}
+
+	body, err := json.MarshalIndent(next, "", "  ")
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	body = append(body, '\n')
+	if *out == "" {
+		_, err = stdout.Write(body)
+	} else {
+		err = os.WriteFile(*out, body, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	changes := diffClasses(active.DrugClasses, synced)
+	printReport(stderr, changes, rep)
+	if len(changes) > 0 {
+		return exitChanged
+	}
+	return exitOK
+}
+
+// rxnorm is the part of an RxNorm release the sync reads: English,
+// unsuppressed RxNorm ingredients and brand names, the tradename links
+// between them, and the ATC codes of each ingredient.
+type rxnorm struct {
+	ingredients map[string]string // RXCUI to lowercased name
+	brands      map[string]string
+	byName      map[string]string // ingredient name to RXCUI
+	brandNames  map[string]bool
+	atc         map[string][]string // ingredient RXCUI to ATC codes
+	brandOf     map[string][]string // brand RXCUI to ingredient RXCUIs
+	linked      map[[2]string]bool  // brand and ingredient RXCUI pairs seen
+	tradenames  map[string][]string // ingredient RXCUI to brand RXCUIs
+}
+
+// RRF column positions; see the RxNorm technical documentation.
+const (
+	consoRXCUI    = 0
+	consoLAT      = 1
+	consoSAB      = 11
+	consoTTY      = 12
+	consoCODE     = 13
+	consoSTR      = 14
+	consoSUPPRESS = 16
+	consoFields   = 18
+
+	relRXCUI1   = 0
+	relRXCUI2   = 4
+	relRELA     = 7
+	relSAB      = 10
+	relSUPPRESS = 14
+	relFields   = 16
+)
+
+func loadRxNorm(dir string) (*rxnorm, error) {
+	rx := &rxnorm{
+		ingredients: map[string]string{},
+		brands:      map[string]string{},
+		byName:      map[string]string{},
+		brandNames:  map[string]bool{},
+		atc:         map[string][]string{},
+		brandOf:     map[string][]string{},
+		linked:      map[[2]string]bool{},
+		tradenames:  map[string][]string{},
+	}
+	var atc [][2]string
+	err := scanRRF(filepath.Join(dir, "RXNCONSO.RRF"), consoFields, func(f []string) {
+		if f[consoLAT] != "ENG" || f[consoSUPPRESS] != "N" {
+			return
+		}
+		name := strings.ToLower(strings.TrimSpace(f[consoSTR]))
+		switch {
+		case f[consoSAB] == "ATC" && len(f[consoCODE]) == 7:
+			atc = append(atc, [2]string{f[consoRXCUI], f[consoCODE]})
+		case f[consoSAB] != "RXNORM":
+		case f[consoTTY] == "IN":
+			rx.ingredients[f[consoRXCUI]] = name
+			rx.byName[name] = f[consoRXCUI]
+		case f[consoTTY] == "BN":
+			rx.brands[f[consoRXCUI]] = name
+			rx.brandNames[name] = true
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	// ATC atoms share the RXCUI of the ingredient they classify; codes on
+	// concepts that are not RxNorm ingredients are dropped.
+	for _, a := range atc {
+		if _, ok := rx.ingredients[a[0]]; ok && !slices.Contains(rx.atc[a[0]], a[1]) {
+			rx.atc[a[0]] = append(rx.atc[a[0]], a[1])
+		}
+	}
+	err = scanRRF(filepath.Join(dir, "RXNREL.RRF"), relFields, func(f []string) {
+		if f[relSAB] != "RXNORM" || f[relSUPPRESS] != "N" {
+			return
+		}
+		if f[relRELA] != "tradename_of" && f[relRELA] != "has_tradename" {
+			return
+		}
+		// Releases list each link in both directions; which side is the
+		// brand is read from the concepts rather than the relation.
+		brand, ingredient := f[relRXCUI1], f[relRXCUI2]
+		if _, ok := rx.brands[brand]; !ok {
+			brand, ingredient = ingredient, brand
+		}
+		_, isBrand := rx.brands[brand]
+		_, isIngredient := rx.ingredients[ingredient]
+		if !isBrand || !isIngredient || rx.linked[[2]string{brand, ingredient}] {
+			return
+		}
+		rx.linked[[2]string{brand, ingredient}] = true
+		rx.brandOf[brand] = append(rx.brandOf[brand], ingredient)
+		rx.tradenames[ingredient] = append(rx.tradenames[ingredient], brand)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rx, nil
+}
+
+// scanRRF calls fn with the fields of every line of the pipe-delimited file
+// at path. Lines with fewer than fields columns are rejected.
+func scanRRF(path string, fields int, fn func([]string)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if sc.Text() == "" {
+			continue
+		}
+		cols := strings.Split(sc.Text(), "|")
+		if len(cols) < fields {
+			return fmt.Errorf("%s:%d: %d columns, want %d", path, line, len(cols), fields)
+		}
+		fn(cols)
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// report lists what the sync could not map: active members RxNorm does not
+// know, and brands of several ingredients that were not added to any class.
+type report struct {
+	Unknown      map[string][]string // class name to members
+	Combinations map[string][]string // brand to ingredient names
+}
+
+// sync rebuilds classes from rx. Generic members stay, in order, followed by
+// the new ingredients and the brands of every ingredient of the class, each
+// sorted. Names a kept member already matches by substring are not added.
+func (rx *rxnorm) sync(classes []analysis.DrugClass) ([]analysis.DrugClass, report) {
+	rep := report{Unknown: map[string][]string{}, Combinations: map[string][]string{}}
+	out := make([]analysis.DrugClass, 0, len(classes))
+	for _, c := range classes {
+		var kept []string
+		ingredients := map[string]bool{}
+		for _, m := range c.Members {
+			if rx.brandNames[m] {
+				continue
+			}
+			kept = append(kept, m)
+			if cui, ok := rx.byName[m]; ok {
+				ingredients[cui] = true
+			} else {
+				rep.Unknown[c.Name] = append(rep.Unknown[c.Name], m)
+			}
+		}
+		for cui, codes := range rx.atc {
+			for _, prefix := range classATC[strings.ToLower(c.Name)] {
+				if slices.ContainsFunc(codes, func(code string) bool { return strings.HasPrefix(code, prefix) }) {
+					ingredients[cui] = true
+				}
+			}
+		}
+
+		var generics, brands []string
+		for cui := range ingredients {
+			generics = append(generics, rx.ingredients[cui])
+			for _, b := range rx.tradenames[cui] {
+				if len(rx.brandOf[b]) == 1 {
+					brands = append(brands, rx.brands[b])
+					continue
+				}
+				var parts []string
+				for _, ing := range rx.brandOf[b] {
+					parts = append(parts, rx.ingredients[ing])
+				}
+				sort.Strings(parts)
+				rep.Combinations[rx.brands[b]] = parts
+			}
+		}
+		sort.Strings(generics)
+		sort.Strings(brands)
+
+		members := kept
+		for _, name := range append(generics, brands...) {
+			if !slices.ContainsFunc(members, func(m string) bool { return strings.Contains(name, m) }) {
+				members = append(members, name)
+			}
+		}
+		out = append(out, analysis.DrugClass{Name: c.Name, Members: members})
+	}
+	return out, rep
+}
+
+// classChange is the difference in one class between two taxonomies.
+type classChange struct {
+	Class   string
+	Added   []string
+	Removed []string
+}
+
+func diffClasses(before, after []analysis.DrugClass) []classChange {
+	old := map[string][]string{}
+	for _, c := range before {
+		old[c.Name] = c.Members
+	}
+	var changes []classChange
+	for _, c := range after {
+		ch := classChange{Class: c.Name}
+		for _, m := range c.Members {
+			if !slices.Contains(old[c.Name], m) {
+				ch.Added = append(ch.Added, m)
+			}
+		}
+		for _, m := range old[c.Name] {
+			if !slices.Contains(c.Members, m) {
+				ch.Removed = append(ch.Removed, m)
+			}
+		}
+		if len(ch.Added)+len(ch.Removed) > 0 {
+			changes = append(changes, ch)
+		}
+	}
+	return changes
+}
+
+func printReport(w io.Writer, changes []classChange, rep report) {
+	if len(changes) == 0 {
+		fmt.Fprintln(w, "drug taxonomy unchanged")
+	} else {
+		fmt.Fprintf(w, "drug taxonomy: %d classes changed\n", len(changes))
+	}
+	for _, ch := range changes {
+		fmt.Fprintf(w, "%s\n", ch.Class)
+		for _, m := range ch.Added {
+			fmt.Fprintf(w, "  + %s\n", m)
+		}
+		for _, m := range ch.Removed {
+			fmt.Fprintf(w, "  - %s\n", m)
+		}
+	}
+	for _, class := range sortedKeys(rep.Unknown) {
+		fmt.Fprintf(w, "not in RxNorm: %s (%s)\n", strings.Join(rep.Unknown[class], ", "), class)
+	}
+	for _, brand := range sortedKeys(rep.Combinations) {
+		fmt.Fprintf(w, "combination brand not classed: %s (%s)\n", brand, strings.Join(rep.Combinations[brand], " + "))
+	}
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
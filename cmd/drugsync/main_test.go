@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+)
+
+const fixture = "testdata/rrf"
+
+func runSync(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestSync_BuildsLoadableTaxonomy(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "synced.json")
+	code, _, report := runSync("--rxnorm", fixture, "--out", out)
+	if code != exitChanged {
+		t.Fatalf("exit = %d, want %d (report %q)", code, exitChanged, report)
+	}
+
+	a := analysis.New()
+	if err := a.LoadRulesFile(out); err != nil {
+		t.Fatal(err)
+	}
+	classes := map[string][]string{}
+	for _, c := range a.Rules().DrugClasses {
+		classes[c.Name] = c.Members
+	}
+	pde5 := classes["PDE5 inhibitor"]
+	for _, want := range []string{"sildenafil", "tadalafil", "avanafil", "viagra", "revatio", "cialis", "levitra"} {
+		if !slices.Contains(pde5, want) {
+			t.Errorf("PDE5 members %q lack %s", pde5, want)
+		}
+	}
+	// Suppressed brands, non-English synonyms, and names a member already
+	// matches by substring stay out.
+	for _, unwanted := range []string{"zestra", "sildenafilo", "sildenafil citrate"} {
+		if slices.Contains(pde5, unwanted) {
+			t.Errorf("PDE5 members %q include %s", pde5, unwanted)
+		}
+	}
+	if nitrates := classes["nitrate"]; slices.Contains(nitrates, "isosorbide mononitrate") {
+		t.Errorf("nitrate members %q repeat a name isosorbide already matches", nitrates)
+	}
+	for class, members := range classes {
+		if slices.Contains(members, "caduet") {
+			t.Errorf("combination brand caduet classed as %s", class)
+		}
+	}
+	for _, want := range []string{"+ avanafil", "+ flomax", "combination brand not classed: caduet (amlodipine + atorvastatin)"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report lacks %q:\n%s", want, report)
+		}
+	}
+
+	// Brand names now count toward duplicate therapy.
+	rep := a.CheckInteractions(analysis.InteractionRequest{Medications: []analysis.Medication{{Name: "Cialis"}, {Name: "Viagra"}}}, analysis.Options{})
+	var dup bool
+	for _, p := range rep.Pairs {
+		for _, is := range p.Issues {
+			dup = dup || is.Code == "DUP_THERAPY"
+		}
+	}
+	if !dup {
+		t.Fatalf("Cialis with Viagra not flagged as duplicate therapy: %+v", rep.Pairs)
+	}
+
+	// Syncing the synced ruleset again changes nothing.
+	code, _, report = runSync("--rxnorm", fixture, "--rules", out)
+	if code != exitOK || !strings.Contains(report, "drug taxonomy unchanged") {
+		t.Fatalf("resync exit = %d, report:\n%s", code, report)
+	}
+}
+
+func TestSync_Errors(t *testing.T) {
+	if code, _, _ := runSync(); code != exitError {
+		t.Fatalf("no --rxnorm exit = %d, want %d", code, exitError)
+	}
+	if code, _, stderr := runSync("--rxnorm", t.TempDir()); code != exitError || !strings.Contains(stderr, "RXNCONSO.RRF") {
+		t.Fatalf("missing files exit = %d, stderr %q", code, stderr)
+	}
+}
@@ -0,0 +1,214 @@
+// Command loadgen sends generated intakes to an analyze endpoint at a fixed
+// rate and reports latency percentiles and error rates.
+//
+// Usage:
+//
+//	loadgen [--url http://localhost:8080/api/analyze] [--rps 20] [--duration 30s]
+//	        [--concurrency 64] [--seed 1] [--typo-rate 0.05] [--timeout 10s]
+//
+// Requests that would exceed --concurrency in flight are counted as dropped
+// rather than queued, so a slow server shows up as drops instead of skewing
+// the offered rate. The exit status is 1 when any request failed or was
+// dropped and 2 on usage errors.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/testgen"
+)
+
+const (
+	exitOK     = 0
+	exitErrors = 1
+	exitUsage  = 2
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+type config struct {
+	url         string
+	rps         float64
+	duration    time.Duration
+	concurrency int
+	seed        int64
+	typoRate    float64
+	timeout     time.Duration
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("loadgen", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var cfg config
+	fs.StringVar(&cfg.url, "url", "http://localhost:8080/api/analyze", "analyze endpoint")
+	fs.Float64Var(&cfg.rps, "rps", 20, "requests per second")
+	fs.DurationVar(&cfg.duration, "duration", 30*time.Second, "how long to send requests")
+	fs.IntVar(&cfg.concurrency, "concurrency", 64, "maximum requests in flight")
+	fs.Int64Var(&cfg.seed, "seed", 1, "generator seed")
+	fs.Float64Var(&cfg.typoRate, "typo-rate", testgen.DefaultTypoRate, "share of misspelled medication and condition names")
+	fs.DurationVar(&cfg.timeout, "timeout", 10*time.Second, "per-request timeout")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 0 || cfg.rps <= 0 || cfg.duration <= 0 || cfg.concurrency <= 0 {
+		fmt.Fprintln(stderr, "loadgen: --rps, --duration, and --concurrency must be positive")
+		return exitUsage
+	}
+
+	rep := generate(ctx, cfg, &http.Client{Timeout: cfg.timeout})
+	rep.print(stdout)
+	if rep.failed() > 0 || rep.dropped > 0 {
+		return exitErrors
+	}
+	return exitOK
+}
+
+// report aggregates the outcome of a run.
+type report struct {
+	mu        sync.Mutex
+	elapsed   time.Duration
+	latencies []time.Duration
+	statuses  map[int]int
+	transport int
+	dropped   int
+}
+
+func (r *report) record(status int, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if status == 0 {
+		r.transport++
+		return
+	}
+	r.statuses[status]++
+	r.latencies = append(r.latencies, d)
+}
+
+func (r *report) sent() int {
+	return len(r.latencies) + r.transport
+}
+
+// failed counts transport errors and non-2xx responses. A 400 is a failure
+// too: generated intakes are always valid.
+func (r *report) failed() int {
+	n := r.transport
+	for status, c := range r.statuses {
+		if status < 200 || status > 299 {
+			n += c
+		}
+	}
+	return n
+}
+
+func generate(ctx context.Context, cfg config, hc *http.Client) *report {
+	rep := &report{statuses: map[int]int{}}
+	gen := testgen.New(cfg.seed)
+	gen.TypoRate = cfg.typoRate
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.duration)
+	defer cancel()
+	interval := time.Duration(float64(time.Second) / cfg.rps)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	slots := make(chan struct{}, cfg.concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+
+	fire := func() {
+		body, _ := json.Marshal(gen.Intake())
+		select {
+		case slots <- struct{}{}:
+		default:
+			rep.mu.Lock()
+			rep.dropped++
+			rep.mu.Unlock()
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			status, d := send(hc, cfg.url, body)
+			rep.record(status, d)
+		}()
+	}
+
+	fire()
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-ticker.C:
+			fire()
+		}
+	}
+	wg.Wait()
+	rep.elapsed = time.Since(start)
+	return rep
+}
+
+// send posts one intake; status 0 means the request never got a response.
+// In-flight requests are not tied to the run's deadline so the tail of the
+// run is measured rather than cancelled.
+func send(hc *http.Client, url string, body []byte) (int, time.Duration) {
+	start := time.Now()
+	resp, err := hc.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return 0, time.Since(start)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp.StatusCode, time.Since(start)
+}
+
+func (r *report) print(w io.Writer) {
+	sent := r.sent()
+	fmt.Fprintf(w, "requests:   %d sent, %d dropped in %s (%.1f req/s)\n", sent, r.dropped, r.elapsed.Round(time.Millisecond), float64(sent)/r.elapsed.Seconds())
+	if sent > 0 {
+		fmt.Fprintf(w, "errors:     %d (%.2f%%), %d transport\n", r.failed(), 100*float64(r.failed())/float64(sent), r.transport)
+	}
+	codes := make([]int, 0, len(r.statuses))
+	for c := range r.statuses {
+		codes = append(codes, c)
+	}
+	sort.Ints(codes)
+	for _, c := range codes {
+		fmt.Fprintf(w, "status %d: %d\n", c, r.statuses[c])
+	}
+	if len(r.latencies) == 0 {
+		return
+	}
+	sort.Slice(r.latencies, func(i, j int) bool { return r.latencies[i] < r.latencies[j] })
+	fmt.Fprintf(w, "latency:    p50 %s  p90 %s  p99 %s  max %s\n",
+		percentile(r.latencies, 50), percentile(r.latencies, 90), percentile(r.latencies, 99), r.latencies[len(r.latencies)-1])
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1].Round(time.Microsecond)
+}
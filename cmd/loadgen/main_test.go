@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/server"
+)
+
+func TestRun_AgainstServer(t *testing.T) {
+	srv := httptest.NewServer(server.New(server.Config{Analyzer: analysis.New()}))
+	defer srv.Close()
+
+	var stdout, stderr bytes.Buffer
+	code := run(t.Context(), []string{"--url", srv.URL + "/api/analyze", "--rps", "200", "--duration", "250ms"}, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("exit %d\nstdout: %s\nstderr: %s", code, stdout.String(), stderr.String())
+	}
+	out := stdout.String()
+	for _, want := range []string{"status 200:", "errors:     0 (0.00%)", "latency:    p50"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRun_ReportsErrorsAndDrops(t *testing.T) {
+	var n atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n.Add(1) == 1 {
+			<-release
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	time.AfterFunc(200*time.Millisecond, func() { close(release) })
+
+	var stdout, stderr bytes.Buffer
+	code := run(t.Context(), []string{"--url", srv.URL, "--rps", "100", "--duration", "150ms", "--concurrency", "1"}, &stdout, &stderr)
+	if code != exitErrors {
+		t.Fatalf("exit %d, want %d\n%s", code, exitErrors, stdout.String())
+	}
+	if out := stdout.String(); !strings.Contains(out, "status 503:") || strings.Contains(out, " 0 dropped") {
+		t.Fatalf("expected 503s and drops:\n%s", out)
+	}
+}
+
+func TestRun_Usage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run(t.Context(), []string{"--rps", "0"}, &stdout, &stderr); code != exitUsage {
+		t.Fatalf("exit %d, want %d", code, exitUsage)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var d []time.Duration
+	for i := 1; i <= 100; i++ {
+		d = append(d, time.Duration(i)*time.Millisecond)
+	}
+	for p, want := range map[float64]time.Duration{50: 50 * time.Millisecond, 99: 99 * time.Millisecond, 100: 100 * time.Millisecond, 0: time.Millisecond} {
+		if got := percentile(d, p); got != want {
+			t.Errorf("p%v = %s, want %s", p, got, want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+	"github.com/Skufu/Clinical-AI-Assistant/types"
+)
+
+// Issue acknowledgment types.
+type (
+	IssueAcknowledgment = types.IssueAcknowledgment
+	IssueAckRequest     = types.IssueAckRequest
+)
+
+// ErrAcksUnsupported is returned when the audit store does not keep issue
+// acknowledgments.
+var ErrAcksUnsupported = errors.New("audit store does not support issue acknowledgments")
+
+// ErrAckReasonRequired is returned by AcknowledgeIssue for a blank reason.
+var ErrAckReasonRequired = errors.New("reason is required to acknowledge an issue")
+
+// ErrIssueNotFound is returned by AcknowledgeIssue when the audited analysis
+// did not flag the issue.
+var ErrIssueNotFound = errors.New("issue not flagged on the audit")
+
+// ErrDangerNotAcknowledgeable is returned by AcknowledgeIssue for a danger
+// issue, which must be seen on every analysis.
+var ErrDangerNotAcknowledgeable = errors.New("danger issues cannot be acknowledged")
+
+// AcknowledgeIssue records that a clinician reviewed and accepted issue code
+// on audit auditID. Later analyses of the same patient that raise the issue
+// with the same related medications mark it acknowledged; it is still listed
+// and scored. Errors are ErrAckReasonRequired, audit.ErrNotFound,
+// ErrIssueNotFound, ErrDangerNotAcknowledgeable, audit.ErrAlreadyAcknowledged,
+// ErrResponsesUnsupported, or ErrAcksUnsupported.
+func (a *Analyzer) AcknowledgeIssue(ctx context.Context, auditID, code string, req IssueAckRequest) (IssueAcknowledgment, error) {
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return IssueAcknowledgment{}, ErrAckReasonRequired
+	}
+	store, ok := a.settings().store.(audit.IssueAckStore)
+	if !ok {
+		return IssueAcknowledgment{}, ErrAcksUnsupported
+	}
+	resp, err := a.AuditResponse(ctx, auditID)
+	if err != nil {
+		return IssueAcknowledgment{}, err
+	}
+	i := slices.IndexFunc(resp.FlaggedIssues, func(is Issue) bool { return is.Code == code })
+	if i < 0 {
+		return IssueAcknowledgment{}, ErrIssueNotFound
+	}
+	issue := resp.FlaggedIssues[i]
+	if issue.Severity == SeverityDanger {
+		return IssueAcknowledgment{}, ErrDangerNotAcknowledgeable
+	}
+	ack, err := store.InsertIssueAck(ctx, audit.IssueAck{
+		AuditID:     auditID,
+		IssueCode:   code,
+		Medications: issue.RelatedMedications,
+		UserID:      req.UserID,
+		Reason:      reason,
+		At:          a.now().UTC(),
+	})
+	if err != nil {
+		return IssueAcknowledgment{}, err
+	}
+	return acknowledgmentOf(ack), nil
+}
+
+func AcknowledgeIssue(ctx context.Context, auditID, code string, req IssueAckRequest) (IssueAcknowledgment, error) {
+	return defaultAnalyzer.AcknowledgeIssue(ctx, auditID, code, req)
+}
+
+// withAcknowledgments returns issues with those ref has acknowledged before
+// marked, copying the slice rather than changing a cached response's. A
+// danger issue is never marked, nor one whose related medications changed
+// since it was acknowledged.
+func withAcknowledgments(ctx context.Context, s settings, ref string, issues []Issue) []Issue {
+	store, ok := s.store.(audit.IssueAckStore)
+	if !ok || ref == "" || len(issues) == 0 {
+		return issues
+	}
+	acks, err := store.IssueAcks(ctx, ref)
+	if err != nil {
+		// The issues stand unmarked; nothing is hidden by a failed lookup.
+		log.Printf("issue acknowledgments unavailable patient=%s: %v", ref, err)
+		return issues
+	}
+	if len(acks) == 0 {
+		return issues
+	}
+	out := slices.Clone(issues)
+	for i, is := range out {
+		if is.Severity == SeverityDanger {
+			continue
+		}
+		// Acks are newest first, so the latest matching one is shown.
+		j := slices.IndexFunc(acks, func(k audit.IssueAck) bool {
+			return k.IssueCode == is.Code && sameMedications(k.Medications, is.RelatedMedications)
+		})
+		if j >= 0 {
+			ack := acknowledgmentOf(acks[j])
+			out[i].Acknowledged, out[i].Acknowledgment = true, &ack
+		}
+	}
+	return out
+}
+
+// sameMedications reports whether a and b name the same medications,
+// ignoring order and case.
+func sameMedications(a, b []string) bool {
+	norm := func(names []string) []string {
+		out := make([]string, 0, len(names))
+		for _, n := range names {
+			out = append(out, strings.ToLower(strings.TrimSpace(n)))
+		}
+		slices.Sort(out)
+		return slices.Compact(out)
+	}
+	return slices.Equal(norm(a), norm(b))
+}
+
+func acknowledgmentOf(k audit.IssueAck) IssueAcknowledgment {
+	return IssueAcknowledgment{
+		AuditID:            k.AuditID,
+		IssueCode:          k.IssueCode,
+		RelatedMedications: k.Medications,
+		UserID:             k.UserID,
+		Reason:             k.Reason,
+		At:                 k.At.UTC().Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,105 @@
+package analysis
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+func TestAcknowledgeIssue_Resurfaces(t *testing.T) {
+	a := New(WithAuditStore(audit.NewMemoryStore()), WithClock(fixedClock))
+	in := Intake{
+		PatientName: "Juan Dela Cruz", Age: 45, WeightKg: 78, HeightCm: 175, BP: "135/88",
+		Conditions:  []string{"Hypertension"},
+		Medications: []Medication{{Name: "Amlodipine", Dosage: "5mg", Frequency: "Daily"}},
+		Complaint:   "ED",
+	}
+	first := a.AnalyzeContext(t.Context(), in, Options{})
+	i := slices.IndexFunc(first.FlaggedIssues, func(is Issue) bool { return is.Code == "DDI_PDE5_AMLODIPINE" })
+	if first.AuditID == "" || i < 0 || first.FlaggedIssues[i].Acknowledged {
+		t.Fatalf("first analysis = %+v", first.FlaggedIssues)
+	}
+
+	req := IssueAckRequest{UserID: "dr-a", Reason: "BP stable on amlodipine; counselled"}
+	for _, c := range []struct {
+		audit, code string
+		req         IssueAckRequest
+		want        error
+	}{
+		{first.AuditID, "DDI_PDE5_AMLODIPINE", IssueAckRequest{Reason: " "}, ErrAckReasonRequired},
+		{first.AuditID, "CI_NITRATE_PDE5", req, ErrIssueNotFound},
+		{"missing", "DDI_PDE5_AMLODIPINE", req, audit.ErrNotFound},
+	} {
+		if _, err := a.AcknowledgeIssue(t.Context(), c.audit, c.code, c.req); !errors.Is(err, c.want) {
+			t.Errorf("ack %s on %s: %v, want %v", c.code, c.audit, err, c.want)
+		}
+	}
+	ack, err := a.AcknowledgeIssue(t.Context(), first.AuditID, "DDI_PDE5_AMLODIPINE", req)
+	if err != nil || ack.UserID != "dr-a" || !slices.Equal(ack.RelatedMedications, first.FlaggedIssues[i].RelatedMedications) {
+		t.Fatalf("ack = %+v (err %v)", ack, err)
+	}
+	if _, err := a.AcknowledgeIssue(t.Context(), first.AuditID, "DDI_PDE5_AMLODIPINE", req); !errors.Is(err, audit.ErrAlreadyAcknowledged) {
+		t.Fatalf("second ack: %v", err)
+	}
+
+	next := a.AnalyzeContext(t.Context(), in, Options{DryRun: true})
+	j := slices.IndexFunc(next.FlaggedIssues, func(is Issue) bool { return is.Code == "DDI_PDE5_AMLODIPINE" })
+	if j < 0 || !next.FlaggedIssues[j].Acknowledged || next.FlaggedIssues[j].Acknowledgment == nil ||
+		next.FlaggedIssues[j].Acknowledgment.AuditID != first.AuditID || next.FlaggedIssues[j].Acknowledgment.Reason != req.Reason {
+		t.Fatalf("follow-up issues = %+v", next.FlaggedIssues)
+	}
+	if next.RiskScore != first.RiskScore || len(next.FlaggedIssues) != len(first.FlaggedIssues) {
+		t.Fatalf("acknowledgment changed scoring: %d/%d issues, score %d/%d", len(next.FlaggedIssues), len(first.FlaggedIssues), next.RiskScore, first.RiskScore)
+	}
+
+	other := in
+	other.PatientName = "Maria Santos"
+	for _, is := range a.AnalyzeContext(t.Context(), other, Options{DryRun: true}).FlaggedIssues {
+		if is.Acknowledged {
+			t.Fatalf("another patient's issue marked acknowledged: %+v", is)
+		}
+	}
+}
+
+func TestAcknowledgeIssue_DangerRefused(t *testing.T) {
+	a := New(WithAuditStore(audit.NewMemoryStore()))
+	resp := a.AnalyzeContext(t.Context(), nitratePatient(Medication{Name: "Isosorbide mononitrate", Dosage: "30mg", Frequency: "Daily"}), Options{})
+	i := slices.IndexFunc(resp.FlaggedIssues, func(is Issue) bool { return is.Severity == SeverityDanger })
+	if i < 0 {
+		t.Fatalf("no danger issue: %+v", resp.FlaggedIssues)
+	}
+	_, err := a.AcknowledgeIssue(t.Context(), resp.AuditID, resp.FlaggedIssues[i].Code, IssueAckRequest{Reason: "cardiology aware"})
+	if !errors.Is(err, ErrDangerNotAcknowledgeable) {
+		t.Fatalf("err = %v, want ErrDangerNotAcknowledgeable", err)
+	}
+}
+
+func TestWithAcknowledgments(t *testing.T) {
+	store := audit.NewMemoryStore()
+	if _, err := store.Insert(t.Context(), audit.Entry{ID: "a1", PatientRef: "p_1"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []audit.IssueAck{
+		{AuditID: "a1", IssueCode: "DDI_PDE5_ALPHA_BLOCKER", Medications: []string{"Tadalafil", "tamsulosin"}, Reason: "seated dosing"},
+		{AuditID: "a1", IssueCode: "CI_NITRATE_PDE5", Reason: "acked through the store"},
+	} {
+		if _, err := store.InsertIssueAck(t.Context(), k); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := New(WithAuditStore(store)).settings()
+	issues := []Issue{
+		{Code: "DDI_PDE5_ALPHA_BLOCKER", Severity: SeverityWarning, RelatedMedications: []string{"tamsulosin", "tadalafil"}},
+		{Code: "DDI_PDE5_ALPHA_BLOCKER", Severity: SeverityWarning, RelatedMedications: []string{"tadalafil", "doxazosin"}},
+		{Code: "CI_NITRATE_PDE5", Severity: SeverityDanger},
+	}
+	got := withAcknowledgments(t.Context(), s, "p_1", issues)
+	if !got[0].Acknowledged || got[1].Acknowledged || got[2].Acknowledged {
+		t.Fatalf("acknowledged = %v %v %v, want true false false", got[0].Acknowledged, got[1].Acknowledged, got[2].Acknowledged)
+	}
+	if issues[0].Acknowledged {
+		t.Fatal("input issues were changed")
+	}
+}
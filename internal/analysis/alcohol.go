@@ -0,0 +1,78 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// heavyDrinksPerWeek is the weekly standard-drink count above which
+	// drinking counts as heavy (NIAAA).
+	heavyDrinksPerWeek = 14
+	// moderateDrinksPerWeek is where the milder info issue starts.
+	moderateDrinksPerWeek = 8
+	// maxDrinksPerWeek bounds a plausible weekly count.
+	maxDrinksPerWeek = 200
+)
+
+// heavyAlcohol reports whether the intake records heavy drinking. A drink
+// count decides when there is one; otherwise any alcohol label mentioning
+// "heavy" does, so free text such as "heavy drinker" counts.
+func heavyAlcohol(in Intake) bool {
+	if d := in.AlcoholDrinksPerWeek; d != nil {
+		return *d > heavyDrinksPerWeek
+	}
+	return strings.Contains(normalizeName(in.Alcohol), "heavy")
+}
+
+// assessAlcohol scores heavy drinking and flags 8 to 14 drinks a week with
+// an info issue. When the label and the drink count disagree, the count is
+// used and an info issue says so.
+func assessAlcohol(in Intake, risk *riskAccumulator, l localizer) []Issue {
+	var issues []Issue
+	// Empty values render as absent in the issue templates.
+	data := map[string]any{"Drinks": "", "Label": strings.TrimSpace(in.Alcohol)}
+	d := in.AlcoholDrinksPerWeek
+	if d != nil {
+		data["Drinks"] = formatQuantity(*d)
+		if alcoholLabelConflicts(in.Alcohol, *d) {
+			issues = append(issues, newIssue("ALCOHOL_LABEL_CONFLICT", SeverityInfo, l.issue("ALCOHOL_LABEL_CONFLICT", data)))
+		}
+	}
+	switch {
+	case risk.check("alcohol_heavy", "LIFESTYLE_ALCOHOL_HEAVY", heavyAlcohol(in), func() map[string]any {
+		out := map[string]any{"label": normalizeName(in.Alcohol)}
+		if d != nil {
+			out["drinksPerWeek"] = *d
+		}
+		return out
+	}):
+		risk.add("alcohol_heavy", "Heavy alcohol use")
+		issues = append(issues, newIssue("LIFESTYLE_ALCOHOL_HEAVY", SeverityInfo, l.issue("LIFESTYLE_ALCOHOL_HEAVY", data)))
+	case d != nil && *d >= moderateDrinksPerWeek:
+		issues = append(issues, newIssue("LIFESTYLE_ALCOHOL_MODERATE", SeverityInfo, l.issue("LIFESTYLE_ALCOHOL_MODERATE", data)))
+	}
+	return issues
+}
+
+// alcoholLabelConflicts reports whether the alcohol label contradicts a
+// weekly drink count: a heavy label with 14 drinks or fewer, a lighter label
+// with more, or none with any.
+func alcoholLabelConflicts(label string, drinks float64) bool {
+	label = normalizeName(label)
+	switch {
+	case label == "":
+		return false
+	case label == "none" || label == "never":
+		return drinks > 0
+	}
+	return strings.Contains(label, "heavy") != (drinks > heavyDrinksPerWeek)
+}
+
+// alcoholErrors rejects an implausible weekly drink count.
+func alcoholErrors(in Intake) []FieldError {
+	if d := in.AlcoholDrinksPerWeek; d != nil && (*d < 0 || *d > maxDrinksPerWeek) {
+		return []FieldError{{Field: "alcoholDrinksPerWeek", Code: "out_of_range", Message: fmt.Sprintf("alcoholDrinksPerWeek must be between 0 and %d, not %s", maxDrinksPerWeek, formatQuantity(*d))}}
+	}
+	return nil
+}
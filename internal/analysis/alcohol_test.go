@@ -0,0 +1,82 @@
+package analysis
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAnalyze_AlcoholDrinksPerWeek(t *testing.T) {
+	drinks := func(v float64) *float64 { return &v }
+	cases := []struct {
+		name   string
+		label  string
+		drinks *float64
+		heavy  bool
+		issues []string
+	}{
+		{"label only", "Heavy", nil, true, []string{"DDI_PDE5_ALCOHOL"}},
+		{"free-text label", "heavy drinker", nil, true, []string{"DDI_PDE5_ALCOHOL"}},
+		{"count over 14", "", drinks(21), true, []string{"DDI_PDE5_ALCOHOL"}},
+		{"count 8 to 14", "Moderate", drinks(10), false, []string{"LIFESTYLE_ALCOHOL_MODERATE"}},
+		{"light count", "Occasional", drinks(3), false, nil},
+		{"heavy label, light count", "Heavy", drinks(4), false, []string{"ALCOHOL_LABEL_CONFLICT"}},
+		{"light label, heavy count", "Occasional", drinks(30), true, []string{"ALCOHOL_LABEL_CONFLICT", "DDI_PDE5_ALCOHOL"}},
+		{"none with drinks", "None", drinks(9), false, []string{"ALCOHOL_LABEL_CONFLICT", "LIFESTYLE_ALCOHOL_MODERATE"}},
+	}
+	// An ED plan is a PDE5 inhibitor, so LIFESTYLE_ALCOHOL_HEAVY folds into
+	// DDI_PDE5_ALCOHOL.
+	a := New()
+	for _, tc := range cases {
+		in := llmIntake
+		in.Complaint, in.ConfirmedNoMedications, in.ConfirmedNoConditions = "ED", true, true
+		in.Alcohol, in.AlcoholDrinksPerWeek = tc.label, tc.drinks
+		resp := a.Analyze(in)
+		if len(resp.ValidationErrors) > 0 {
+			t.Fatalf("%s: %v", tc.name, resp.ValidationErrors)
+		}
+		heavy := slices.ContainsFunc(resp.RiskFactors, func(f RiskFactor) bool { return f.Code == "alcohol_heavy" })
+		if heavy != tc.heavy {
+			t.Errorf("%s: alcohol_heavy scored %t, want %t", tc.name, heavy, tc.heavy)
+		}
+		var codes []string
+		for _, i := range resp.FlaggedIssues {
+			if strings.Contains(i.Code, "ALCOHOL") {
+				codes = append(codes, i.Code)
+			}
+		}
+		if !slices.Equal(codes, tc.issues) {
+			t.Errorf("%s: alcohol issues %v, want %v", tc.name, codes, tc.issues)
+		}
+	}
+
+	in := llmIntake
+	in.Alcohol, in.AlcoholDrinksPerWeek = "Heavy", drinks(4)
+	in.AlcoholDrinksPerWeek = drinks(20)
+	resp := a.Analyze(in)
+	if i := slices.IndexFunc(resp.FlaggedIssues, func(i Issue) bool { return i.Code == "LIFESTYLE_ALCOHOL_HEAVY" }); i < 0 || !strings.Contains(resp.FlaggedIssues[i].Description, "(20 standard drinks a week)") {
+		t.Fatalf("heavy issue: %+v", resp.FlaggedIssues)
+	}
+	in.AlcoholDrinksPerWeek = drinks(4)
+	resp = a.Analyze(in)
+	i := slices.IndexFunc(resp.FlaggedIssues, func(i Issue) bool { return i.Code == "ALCOHOL_LABEL_CONFLICT" })
+	if i < 0 || !strings.Contains(resp.FlaggedIssues[i].Description, "Heavy but as 4 standard drinks") {
+		t.Fatalf("conflict issue: %+v", resp.FlaggedIssues)
+	}
+}
+
+func TestAlcoholErrors(t *testing.T) {
+	for _, d := range []float64{-1, maxDrinksPerWeek + 1} {
+		in := llmIntake
+		in.AlcoholDrinksPerWeek = &d
+		if errs := alcoholErrors(in); len(errs) != 1 || errs[0].Field != "alcoholDrinksPerWeek" || errs[0].Code != "out_of_range" {
+			t.Errorf("%v drinks: errors %+v", d, errs)
+		}
+	}
+	zero := 0.0
+	in := llmIntake
+	in.AlcoholDrinksPerWeek = &zero
+	if errs := alcoholErrors(in); len(errs) != 0 {
+		t.Fatalf("zero drinks: %+v", errs)
+	}
+}
@@ -1,409 +1,986 @@
 package analysis
 
 import (
+	"context"
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"maps"
+	"math"
 	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/metrics"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/trace"
+	"github.com/Skufu/Clinical-AI-Assistant/types"
 	"github.com/xeipuuv/gojsonschema"
 )
 
-type Intake struct {
-	PatientName string       `json:"patientName"`
-	Age         int          `json:"age"`
-	WeightKg    float64      `json:"weight"`
-	HeightCm    float64      `json:"height"`
-	BP          string       `json:"bp"`
-	BMI         float64      `json:"bmi"`
-	Conditions  []string     `json:"conditions"`
-	Allergies   []string     `json:"allergies"`
-	Medications []Medication `json:"medications"`
-	Smoking     string       `json:"smoking"`
-	Alcohol     string       `json:"alcohol"`
-	Exercise    string       `json:"exercise"`
-	Complaint   string       `json:"complaint"`
-	UserID      string       `json:"userId,omitempty"`
-}
-
-type Medication struct {
-	Name      string `json:"name"`
-	Dosage    string `json:"dosage"`
-	Frequency string `json:"frequency"`
-}
-
-type Issue struct {
-	Type        string `json:"type"`
-	Severity    string `json:"severity"` // danger | warning | info
-	Description string `json:"description"`
-}
-
-type Plan struct {
-	Medication string `json:"medication"`
-	Dosage     string `json:"dosage"`
-	Frequency  string `json:"frequency"`
-	Duration   string `json:"duration"`
-	Rationale  string `json:"rationale"`
-}
-
-type Alternative struct {
-	Medication string   `json:"medication"`
-	Dosage     string   `json:"dosage"`
-	Pros       []string `json:"pros"`
-	Cons       []string `json:"cons"`
-	Confidence float64  `json:"confidence,omitempty"`
-}
-
-type Response struct {
-	RiskLevel        string        `json:"riskLevel"`
-	RiskScore        int           `json:"riskScore"`
-	FlaggedIssues    []Issue       `json:"flaggedIssues"`
-	RecommendedPlan  Plan          `json:"recommendedPlan"`
-	PlanConfidence   float64       `json:"planConfidence,omitempty"`
-	Alternatives     []Alternative `json:"alternatives"`
-	ComputedBMI      float64       `json:"computedBmi"`
-	ValidationErrors []string      `json:"validationErrors,omitempty"`
-	AuditID          string        `json:"auditId,omitempty"`
-	AuditAt          string        `json:"auditAt,omitempty"`
-}
+// The request and response types live in the public types package so the
+// client SDK can share them.
+type (
+	Intake             = types.Intake
+	Allergy            = types.Allergy
+	Medication         = types.Medication
+	Issue              = types.Issue
+	Plan               = types.Plan
+	FollowUp           = types.FollowUp
+	PriorTreatment     = types.PriorTreatment
+	Labs               = types.Labs
+	Resource           = types.Resource
+	AssessedMedication = types.AssessedMedication
+	Alternative        = types.Alternative
+	ComplaintPlan      = types.ComplaintPlan
+	Response           = types.Response
+	RiskFactor         = types.RiskFactor
+	RuleTrace          = types.RuleTrace
+	ConfidenceFactors  = types.ConfidenceFactors
+	AuditSummary       = types.AuditSummary
+	RiskTrend          = types.RiskTrend
+	PatientAnalysis    = types.PatientAnalysis
+	PatientHistory     = types.PatientHistory
+	PatchOp            = types.PatchOp
+	WhatIfRequest      = types.WhatIfRequest
+	WhatIfDiff         = types.WhatIfDiff
+	AnalysisDiff       = types.AnalysisDiff
+	IssueChange        = types.IssueChange
+	BMIDelta           = types.BMIDelta
+	BPDelta            = types.BPDelta
+	WhatIfResponse     = types.WhatIfResponse
+	ReanalysisDiff     = types.ReanalysisDiff
+	ComparedAudit      = types.ComparedAudit
+	AuditComparison    = types.AuditComparison
+	Reanalysis         = types.Reanalysis
+	StoredIntake       = types.StoredIntake
+	AuditDetail        = types.AuditDetail
+	AuditDeletion      = types.AuditDeletion
+	DeleteRequest      = types.DeleteRequest
+	Decision           = types.Decision
+	Consent            = types.Consent
+	DecisionRequest    = types.DecisionRequest
+	BatchRequest       = types.BatchRequest
+	BatchItem          = types.BatchItem
+	BatchResponse      = types.BatchResponse
+	BatchFailure       = types.BatchFailure
+	ImportResult       = types.ImportResult
+	InteractionRequest = types.InteractionRequest
+	InteractionPair    = types.InteractionPair
+	InteractionReport  = types.InteractionReport
+	FieldError         = types.FieldError
+	FieldCode          = types.FieldCode
+	IntakePreview      = types.IntakePreview
+	ValidationReport   = types.ValidationReport
+	PartialResult      = types.PartialResult
+	TriageReport       = types.TriageReport
+	FollowUpQuestion   = types.FollowUpQuestion
+	Signature          = types.Signature
+	PublicKey          = types.PublicKey
+	LiveMessage        = types.LiveMessage
+	LiveReply          = types.LiveReply
+)
+
+// The normalized intake types; see Options.IncludeNormalized.
+type (
+	NormalizedIntake     = types.NormalizedIntake
+	NormalizedBP         = types.NormalizedBP
+	NormalizedBMI        = types.NormalizedBMI
+	NormalizedCondition  = types.NormalizedCondition
+	NormalizedMedication = types.NormalizedMedication
+	NormalizedComponent  = types.NormalizedComponent
+	NormalizedComplaint  = types.NormalizedComplaint
+)
+
+// SchemaVersion is stamped on every Response.
+const SchemaVersion = types.SchemaVersion
+
+// Issue severities and risk levels; see types.Severity and types.RiskLevel.
+type (
+	Severity  = types.Severity
+	RiskLevel = types.RiskLevel
+)
+
+const (
+	SeverityDanger  = types.SeverityDanger
+	SeverityWarning = types.SeverityWarning
+	SeverityInfo    = types.SeverityInfo
+
+	RiskLow      = types.RiskLow
+	RiskMedium   = types.RiskMedium
+	RiskHigh     = types.RiskHigh
+	RiskCritical = types.RiskCritical
+	RiskInvalid  = types.RiskInvalid
+)
+
+// AuditTypeValidationFailed is the AuditSummary type of a rejected analysis
+// attempt; see LatestAuditsWithFailures.
+const AuditTypeValidationFailed = types.AuditTypeValidationFailed
 
 //go:embed schema/response.schema.json
 var responseSchema []byte
 
-var auditStore audit.Store = audit.NewMemoryStore()
-
-func SetAuditStore(store audit.Store) {
-	if store != nil {
-		auditStore = store
-	}
+// Options tunes a single analysis call.
+type Options struct {
+	// Debug includes diagnostic fields (e.g. confidenceFactors) in the Response.
+	Debug bool
+	// Locale selects the language of issue descriptions and plan rationales;
+	// unknown or empty locales use DefaultLocale. See MatchLocale.
+	Locale string
+	// DryRun runs the whole pipeline, validation and schema checks included,
+	// but skips the audit write: the Response has no auditId and dryRun set.
+	DryRun bool
+	// IncludeNormalized adds NormalizedIntake to the Response: the parsed
+	// blood pressure, computed BMI, canonical conditions, medication names
+	// and doses, and recognized complaints, each beside its raw value.
+	IncludeNormalized bool
+	// RuleTrace adds RuleTrace to the Response: every risk rule evaluated,
+	// with its inputs, whether it matched, and the points it added. It
+	// bypasses the result cache so the trace is of this evaluation.
+	RuleTrace bool
+
+	// patientRef replaces the reference derived from the intake's name, for
+	// re-analysis of a stored intake.
+	patientRef string
 }
 
-var systemPrompt = `
-You are a clinical decision support assistant. Apply conservative, guideline-informed rules:
-- Flag contraindications: nitrates + PDE5 inhibitors, uncontrolled hypertension (>160/100), severe hepatic/renal disease with dose adjustments, cardiac clearance for sexual activity in CAD/heart disease.
-- Flag interactions: amlodipine + PDE5 (hypotension), tamsulosin + PDE5 (hypotension), alcohol + PDE5 (hypotension/dizziness).
-- Check dosing: PDE5 starting doses 5-10mg (tadalafil) or 25-50mg (sildenafil); warn >20mg tadalafil single dose.
-- Consider comorbidities: BMI >27 elevated risk; BMI >=30 obesity. Diabetes, hypertension, heart/kidney/liver disease increase risk.
-- Always include rationale and alternatives with pros/cons and confidence 0-1.
-- Safety > everything: prefer flagging potential risks.
-Return structured JSON per schema: riskLevel, riskScore, flaggedIssues, recommendedPlan, planConfidence, alternatives, computedBmi, auditId, validationErrors (if any).
-`
+func (a *Analyzer) Analyze(in Intake) Response {
+	return a.AnalyzeContext(context.Background(), in, Options{})
+}
 
 func Analyze(in Intake) Response {
-	if errs := Validate(in); len(errs) > 0 {
-		return Response{
-			RiskLevel:        "INVALID",
-			RiskScore:        0,
-			FlaggedIssues:    nil,
-			RecommendedPlan:  Plan{},
-			Alternatives:     nil,
-			ComputedBMI:      0,
-			ValidationErrors: errs,
-		}
-	}
+	return defaultAnalyzer.Analyze(in)
+}
 
-	var issues []Issue
-	riskScore := 1 // start with a small baseline
+// AnalyzeWithOptions runs the analysis pipeline with per-call options.
+func (a *Analyzer) AnalyzeWithOptions(in Intake, opts Options) Response {
+	return a.AnalyzeContext(context.Background(), in, opts)
+}
 
-	bmi := in.BMI
-	if bmi == 0 {
-		bmi = computeBMI(in.WeightKg, in.HeightCm)
-	}
+func AnalyzeWithOptions(in Intake, opts Options) Response {
+	return defaultAnalyzer.AnalyzeWithOptions(in, opts)
+}
 
-	if bmi >= 30 {
-		riskScore += 2
-		issues = append(issues, Issue{
-			Type:        "bmi",
-			Severity:    "warning",
-			Description: fmt.Sprintf("BMI %.1f indicates obesity; consider dose adjustments and monitor cardiovascular risk.", bmi),
-		})
-	} else if bmi >= 27 {
-		riskScore++
-		issues = append(issues, Issue{
-			Type:        "bmi",
-			Severity:    "info",
-			Description: fmt.Sprintf("BMI %.1f is elevated; encourage lifestyle optimization alongside therapy.", bmi),
-		})
+func AnalyzeContext(ctx context.Context, in Intake, opts Options) Response {
+	return defaultAnalyzer.AnalyzeContext(ctx, in, opts)
+}
+
+var analysesRun = metrics.NewCounter("analyses_total", "Analyses by mode (recorded, dry_run) and result (ok, invalid, error).", "mode", "result")
+
+var auditWriteFailures = metrics.NewCounter("audit_write_failures_total", "Analyses returned without an audit because the audit entry could not be written.")
+
+// AnalyzeContext runs the analysis pipeline; ctx bounds the LLM scoring call.
+func (a *Analyzer) AnalyzeContext(ctx context.Context, in Intake, opts Options) Response {
+	// Span attributes stay clinical and never identify the patient.
+	ctx, span := trace.Start(ctx, "analysis.analyze", trace.String("analysis.complaint", in.Complaint), trace.Bool("analysis.dry_run", opts.DryRun))
+	defer span.End()
+	start := a.now()
+	resp := a.analyze(ctx, in, opts)
+	span.SetAttributes(trace.String("analysis.risk_level", string(resp.RiskLevel)), trace.String("analysis.ruleset_version", resp.RulesetVersion))
+	countAnalysis(resp, opts)
+	a.observeLatency(in, resp, a.now().Sub(start))
+	return resp
+}
+
+func countAnalysis(resp Response, opts Options) {
+	mode, result := "recorded", "ok"
+	if opts.DryRun {
+		mode = "dry_run"
 	}
+	switch {
+	case resp.RiskLevel == RiskInvalid:
+		result = "invalid"
+	case len(resp.ValidationErrors) > 0:
+		result = "error"
+	}
+	analysesRun.Inc(mode, result)
+}
 
-	systolic, diastolic := parseBP(in.BP)
-	if systolic >= 160 || diastolic >= 100 {
-		riskScore += 3
-		issues = append(issues, Issue{
-			Type:        "blood_pressure",
-			Severity:    "danger",
-			Description: fmt.Sprintf("Blood pressure %s suggests uncontrolled hypertension. Optimize BP before initiating risk-increasing meds.", in.BP),
-		})
-	} else if systolic >= 140 || diastolic >= 90 {
-		riskScore += 2
-		issues = append(issues, Issue{
-			Type:        "blood_pressure",
-			Severity:    "warning",
-			Description: fmt.Sprintf("Blood pressure %s is elevated; monitor closely when adjusting vasoactive medications.", in.BP),
-		})
+func (a *Analyzer) analyze(ctx context.Context, in Intake, opts Options) Response {
+	s := a.settingsFor(ctx)
+	run := a.evaluate(ctx, s, in, opts, nil)
+	if run.entry != nil {
+		stop := run.timer.begin(stageAuditInsert)
+		sum, err := insertAudit(ctx, s, *run.entry)
+		stop()
+		run.recorded(ctx, sum, err)
+	}
+	if run.failure != nil {
+		run.recordFailure(ctx, s)
 	}
+	return run.finish(ctx)
+}
 
-	cond := toSet(in.Conditions)
-	if cond["heart disease"] {
-		riskScore += 3
-		issues = append(issues, Issue{
-			Type:        "cardiac_history",
-			Severity:    "danger",
-			Description: "History of heart disease—ensure cardiac clearance before vasoactive or androgen-modifying therapy.",
-		})
+// analysisRun is an analysis evaluated up to its audit write, which a batch
+// defers so it can write every entry in one transaction.
+type analysisRun struct {
+	resp  Response
+	opts  Options
+	timer *stageTimer
+	// ref is the patient reference the analysis is audited under.
+	ref string
+	// entry is the audit entry to write; nil for dry runs, invalid intakes,
+	// and entries that could not be built.
+	entry *audit.Entry
+	// failure is the rejected attempt to record; nil for dry runs, valid
+	// intakes, and stores that do not keep validation failures.
+	failure *audit.ValidationFailure
+	// shadow starts shadow scoring once the entry has an audit ID.
+	shadow func(ctx context.Context, auditID string)
+	// notify tells the notifiers about a danger flag once the entry is
+	// written.
+	notify func(ctx context.Context, sum audit.Summary)
+	// remind schedules the plan's follow-up once the entry is written.
+	remind func(ctx context.Context, sum audit.Summary)
+	// forward hands the written entry to the audit forwarders.
+	forward func(ctx context.Context, sum audit.Summary)
+	// auditErr is why the entry was not written, if it was not.
+	auditErr error
+	// done marks a response that is final as it stands.
+	done bool
+}
+
+// recorded applies the outcome of writing the run's audit entry. A failed
+// write still returns the analysis, marked as not recorded.
+func (r *analysisRun) recorded(ctx context.Context, sum audit.Summary, err error) {
+	if err != nil {
+		r.auditErr = err
+		r.resp.AuditRecorded = new(bool)
+		auditWriteFailures.Inc()
+		// The store's error names no patient data; the reference is a pseudonym.
+		log.Printf("audit write failed, analysis returned unaudited patient=%s risk=%s: %v", r.ref, r.resp.RiskLevel, err)
+		return
 	}
-	if cond["kidney disease"] {
-		riskScore += 2
-		issues = append(issues, Issue{
-			Type:        "renal_impairment",
-			Severity:    "warning",
-			Description: "Kidney disease—prefer conservative dosing and avoid nephrotoxic combinations.",
-		})
+	recorded := true
+	r.resp.AuditRecorded = &recorded
+	r.resp.AuditID = sum.AuditID
+	r.resp.AuditAt = sum.At
+	if r.shadow != nil {
+		r.shadow(ctx, sum.AuditID)
 	}
-	if cond["liver disease"] {
-		riskScore += 2
-		issues = append(issues, Issue{
-			Type:        "hepatic_impairment",
-			Severity:    "warning",
-			Description: "Liver disease—consider lower starting doses and monitor LFTs where applicable.",
-		})
+	if r.notify != nil {
+		r.notify(ctx, sum)
 	}
-	if cond["diabetes"] {
-		riskScore++
-		issues = append(issues, Issue{
-			Type:        "metabolic_risk",
-			Severity:    "info",
-			Description: "Diabetes increases cardiovascular risk; reinforce glycemic and lifestyle control.",
-		})
+	if r.remind != nil {
+		r.remind(ctx, sum)
 	}
-	if cond["hypertension"] {
-		riskScore++
+	if r.forward != nil {
+		r.forward(ctx, sum)
 	}
+}
 
-	if in.Age > 65 {
-		riskScore += 2
-		issues = append(issues, Issue{
-			Type:        "age_related",
-			Severity:    "info",
-			Description: "Age >65—start low, go slow with vasoactive agents; monitor for orthostatic changes.",
-		})
-	} else if in.Age >= 55 {
-		riskScore++
+// finish checks the response against its schema once the audit fields are
+// final.
+func (r *analysisRun) finish(ctx context.Context) Response {
+	if r.done {
+		return r.resp
+	}
+	_, span := trace.Start(ctx, "analysis.validate_response")
+	stop := r.timer.begin(stageSchemaValidation)
+	verrs := ValidateResponse(r.resp)
+	stop()
+	span.End()
+	if len(verrs) > 0 {
+		r.resp.ValidationErrors = append(r.resp.ValidationErrors, verrs...)
 	}
+	if r.opts.Debug {
+		r.resp.Timings = r.timer.millis()
+	}
+	return r.resp
+}
 
-	if strings.EqualFold(in.Smoking, "current") {
-		riskScore++
-		issues = append(issues, Issue{
-			Type:        "lifestyle",
-			Severity:    "info",
-			Description: "Current smoker—encourage cessation; adds cardiovascular risk.",
-		})
+// evaluate runs the pipeline up to the audit write. pending holds the
+// analyses of earlier items of the same batch by patient reference, which
+// count as previous analyses although they are not written yet.
+func (a *Analyzer) evaluate(ctx context.Context, s settings, in Intake, opts Options, pending map[string]audit.Summary) *analysisRun {
+	in = normalizeText(in)
+	timer := newStageTimer(a.now)
+	_, span := trace.Start(ctx, "analysis.validate")
+	stop := timer.begin(stageValidation)
+	fieldErrs, fieldWarnings := s.fieldErrors(in)
+	errs := errorTexts(fieldErrs)
+	stop()
+	span.End()
+	if len(errs) > 0 {
+		resp := Response{
+			SchemaVersion:    SchemaVersion,
+			RiskLevel:        RiskInvalid,
+			RiskScore:        0,
+			FlaggedIssues:    []Issue{},
+			RecommendedPlan:  Plan{},
+			Alternatives:     []Alternative{},
+			ComputedBMI:      0,
+			ValidationErrors: errs,
+			DryRun:           opts.DryRun,
+			Disclaimers:      s.stampedDisclaimers(),
+		}
+		if opts.Debug {
+			resp.Timings = timer.millis()
+		}
+		countValidationFailure(fieldErrs)
+		run := &analysisRun{resp: resp, opts: opts, timer: timer, done: true}
+		if _, ok := s.store.(audit.ValidationFailureStore); ok && !opts.DryRun {
+			run.failure = a.validationFailure(in, fieldErrs)
+		}
+		return run
 	}
-	if strings.EqualFold(in.Alcohol, "Heavy") {
-		riskScore++
-		issues = append(issues, Issue{
-			Type:        "alcohol",
-			Severity:    "info",
-			Description: "Heavy alcohol use—counsel moderation; may worsen BP and medication tolerance.",
-		})
+
+	var consentWarnings []FieldError
+	for _, w := range fieldWarnings {
+		if w.Code == ConsentRequired || w.Code == ConsentInvalid {
+			consentWarnings = append(consentWarnings, w)
+		}
 	}
+	if len(consentWarnings) > 0 {
+		log.Printf("consent grace mode: analyzing despite %s", strings.Join(errorTexts(consentWarnings), "; "))
+	}
+
+	var sr scoredResponse
+	cached := false
+	if s.results != nil && !opts.RuleTrace {
+		key := resultCacheKey(ctx, in, opts)
+		version := s.resultVersion()
+		if sr.resp, cached = s.results.get(version, key); cached {
+			resultCacheLookups.Inc("hit")
+			sr.resp.Cached = true
+		} else {
+			resultCacheLookups.Inc("miss")
+			sr = respond(ctx, s, in, opts, fieldWarnings, timer)
+			if !sr.degraded {
+				s.results.put(version, key, sr.resp)
+			}
+		}
+	} else {
+		sr = respond(ctx, s, in, opts, fieldWarnings, timer)
+	}
+	resp, llm, scoreReq := sr.resp, sr.llm, sr.req
+
+	ref := opts.patientRef
+	if ref == "" {
+		ref = s.pseudonymizer.PatientRef(in.PatientName)
+	}
+	prev, ok := pending[ref]
+	if !ok {
+		prev, ok = previousAnalysis(ctx, s, ref)
+	}
+	if ok {
+		resp.PreviousRiskScore = &prev.RiskScore
+		resp.RiskTrend = riskTrend(prev.RiskScore, resp.RiskScore)
+	}
+	resp.FlaggedIssues = withAcknowledgments(ctx, s, ref, resp.FlaggedIssues)
+
+	run := &analysisRun{resp: resp, opts: opts, timer: timer, ref: ref}
+	if !opts.DryRun {
+		stop = timer.begin(stageAuditInsert)
+		entry, err := a.auditEntry(s, in, ref, resp, llm.Usage, timer)
+		stop()
+		if err != nil {
+			run.recorded(ctx, audit.Summary{}, err)
+		} else {
+			run.entry = &entry
+			run.notify = a.notifier(s, resp)
+			run.remind = a.reminder(s, resp)
+			run.forward = forwarder(s)
+			if s.shadow && !cached {
+				run.shadow = func(ctx context.Context, auditID string) {
+					a.startShadow(ctx, s, scoreReq, llm, auditID)
+				}
+			}
+		}
+	}
+	// Set after the audit entry is built: the section echoes intake values,
+	// which are only stored when intake storage is enabled.
+	if opts.IncludeNormalized {
+		run.resp.NormalizedIntake = normalizeIntake(in, s.drugClasses)
+	}
+	run.resp.RuleTrace = sr.trace
+	return run
+}
 
-	meds := normalizeMeds(in.Medications)
-	hasNitrate := meds["nitroglycerin"] || meds["isosorbide"] || containsAnyMedication(meds, []string{"nitrate"})
-	if hasNitrate {
-		riskScore += 5
-		issues = append(issues, Issue{
-			Type:        "contraindication",
-			Severity:    "danger",
-			Description: "Nitrate therapy—PDE5 inhibitors are contraindicated. Avoid tadalafil/sildenafil and coordinate cardiology care.",
-		})
+// scoredResponse is a response as respond computes it, before the patient's
+// trend and the audit fields, with the scoring call it was built from.
+type scoredResponse struct {
+	resp     Response
+	llm      LLMResult
+	req      ScoreRequest
+	degraded bool
+	// trace is the rule trace for Options.RuleTrace, kept out of resp until
+	// the audit entry is built.
+	trace []RuleTrace
+}
+
+// respond runs the rules and the LLM scoring on a validated intake.
+func respond(ctx context.Context, s settings, in Intake, opts Options, fieldWarnings []FieldError, timer *stageTimer) scoredResponse {
+	l := s.localizer(opts.Locale)
+	assessed := assessIntake(in, newRiskAccumulator(s.riskWeights, opts.RuleTrace), l)
+	issues, risk := assessed.Issues, assessed.Risk
+	for _, w := range fieldWarnings {
+		if w.Code == "recommended" {
+			data := map[string]any{"Field": w.Field, "Complaint": w.Complaint}
+			issues = append(issues, newIssue("INTAKE_FIELD_MISSING", SeverityInfo, l.issue("INTAKE_FIELD_MISSING", data)))
+		}
 	}
+	unconfirmed := unconfirmedSections(in)
+	if len(unconfirmed) > 0 {
+		data := map[string]any{"Sections": strings.Join(unconfirmed, ", ")}
+		issues = append(issues, newIssue("HISTORY_INCOMPLETE", SeverityInfo, l.issue("HISTORY_INCOMPLETE", data)))
+	}
+	bmi, cond, meds, hasNitrate := assessed.BMI, assessed.Conditions, assessed.Meds, assessed.HasNitrate
+	unmappedCodes := assessed.UnmappedCodes
 
-	plan, alts := buildPlan(in, buildPlanContext{
+	_, span := trace.Start(ctx, "analysis.build_plan")
+	stop := timer.begin(stagePlanBuild)
+	complaints := intakeComplaints(in)
+	plans := buildPlans(complaints, buildPlanContext{
 		BMI:        bmi,
 		HasNitrate: hasNitrate,
-		HasHeartDz: cond["heart disease"],
-		HasRenal:   cond["kidney disease"],
-		HasHepatic: cond["liver disease"],
+		NitratePRN: assessed.NitratePRN,
+		HasHeartDz: cond[condHeartDisease],
+		HasRenal:   cond[condKidneyDisease],
+		HasHepatic: cond[condLiverDisease],
+		Localizer:  l,
+
+		Severity:      normalizeName(in.ComplaintSeverity),
+		DurationWeeks: in.ComplaintDurationWeeks,
+		Prior:         normalizePriorTreatments(in.PriorTreatments),
+		Sedentary:     sedentary(in),
+		ShortSleep:    shortSleep(in),
+
+		MetabolicSyndrome: assessed.Metabolic.Present,
+		CurrentPDE5:       currentPDE5(in.Medications),
 	})
+	plan, alts := plans[0].Plan, plans[0].Alternatives
+	stop()
+	span.End()
+	if weeks := in.ComplaintDurationWeeks; weeks > 0 && weeks < watchfulWaitingWeeks && slices.Contains(watchfulWaitingComplaints, strings.ToLower(plans[0].Complaint)) {
+		data := map[string]any{"Complaint": plans[0].Complaint, "Weeks": weeks}
+		issues = append(issues, newIssue("COMPLAINT_WATCHFUL_WAITING", SeverityInfo, l.issue("COMPLAINT_WATCHFUL_WAITING", data)))
+	}
+
+	// Every plan is checked as part of one regimen with the patient's
+	// medications, so plans for separate complaints are checked against each
+	// other too; the accumulator counts a rule tripped by several plans once.
+	_, span = trace.Start(ctx, "analysis.interactions")
+	stop = timer.begin(stageRules)
+	regimen := maps.Clone(meds)
+	planMeds := make([]string, 0, len(plans))
+	for _, cp := range plans {
+		name := normalizeName(cp.Plan.Medication)
+		regimen[name] = true
+		planMeds = append(planMeds, name)
+	}
+	for i, cp := range plans {
+		p := cp.Plan
+		pde5 := usesPDE5(p.Medication)
+		if risk.check("pde5_amlodipine", "DDI_PDE5_AMLODIPINE", pde5 && regimen["amlodipine"], func() map[string]any {
+			return map[string]any{"plan": planMeds[i], "pde5": pde5, "amlodipine": regimen["amlodipine"]}
+		}) {
+			risk.addKeyed(issueKey("DDI_PDE5_AMLODIPINE", p.Medication, "amlodipine"), "pde5_amlodipine", "PDE5 inhibitor with amlodipine")
+			issues = append(issues, newIssue("DDI_PDE5_AMLODIPINE", SeverityWarning, l.issue("DDI_PDE5_AMLODIPINE", nil), p.Medication, "amlodipine"))
+		}
 
-	if usesPDE5(plan.Medication) && meds["amlodipine"] {
-		riskScore++
-		issues = append(issues, Issue{
-			Type:        "drug_interaction",
-			Severity:    "warning",
-			Description: "PDE5 inhibitor may enhance the hypotensive effect of amlodipine. Monitor BP closely during initiation.",
-		})
-	}
-
-	if usesPDE5(plan.Medication) && meds["tamsulosin"] {
-		riskScore++
-		issues = append(issues, Issue{
-			Type:        "drug_interaction",
-			Severity:    "warning",
-			Description: "PDE5 inhibitor plus tamsulosin may increase hypotension risk. Consider spacing doses and monitoring.",
-		})
-	}
+		if risk.check("pde5_tamsulosin", "DDI_PDE5_TAMSULOSIN", pde5 && regimen["tamsulosin"], func() map[string]any {
+			return map[string]any{"plan": planMeds[i], "pde5": pde5, "tamsulosin": regimen["tamsulosin"]}
+		}) {
+			risk.addKeyed(issueKey("DDI_PDE5_TAMSULOSIN", p.Medication, "tamsulosin"), "pde5_tamsulosin", "PDE5 inhibitor with tamsulosin")
+			issues = append(issues, newIssue("DDI_PDE5_TAMSULOSIN", SeverityWarning, l.issue("DDI_PDE5_TAMSULOSIN", nil), p.Medication, "tamsulosin"))
+		}
 
-	if usesPDE5(plan.Medication) && cond["heart disease"] {
-		issues = append(issues, Issue{
-			Type:        "cardiac_clearance",
-			Severity:    "warning",
-			Description: "Cardiac history—confirm patient is cleared for sexual activity before PDE5 use.",
-		})
-	}
+		if pde5 && cond[condHeartDisease] {
+			issues = append(issues, newIssue("CARDIAC_CLEARANCE_PDE5", SeverityWarning, l.issue("CARDIAC_CLEARANCE_PDE5", nil), p.Medication))
+		}
 
-	if usesPDE5(plan.Medication) && strings.EqualFold(in.Alcohol, "heavy") {
-		issues = append(issues, Issue{
-			Type:        "alcohol",
-			Severity:    "info",
-			Description: "Heavy alcohol use with PDE5 inhibitors can worsen hypotension and dizziness. Counsel moderation.",
-		})
+		if pde5 && heavyAlcohol(in) {
+			issues = append(issues, newIssue("DDI_PDE5_ALCOHOL", SeverityInfo, l.issue("DDI_PDE5_ALCOHOL", nil), p.Medication))
+		}
 	}
 
 	// Additional interaction datasource checks (local ruleset).
-	issues = append(issues, interactionIssues(meds)...)
-
-	// Allergy cross-checks against plan and alternatives.
-	if allergy := intersectsAllergy(in.Allergies, plan.Medication); allergy != "" {
-		riskScore += 3
-		issues = append(issues, Issue{
-			Type:        "allergy",
-			Severity:    "danger",
-			Description: fmt.Sprintf("Allergy match detected for planned medication (%s).", allergy),
-		})
+	rules := s.rules.InteractionRules()
+	for _, rule := range rules {
+		if risk.check(strings.ToLower(rule.Code), rule.Code, rule.matches(regimen), func() map[string]any {
+			return map[string]any{"drug": normalizeName(rule.Drug), "with": normalizeName(rule.With)}
+		}) {
+			risk.addPointsKeyed(issueKey(rule.Code, rule.Drug, rule.With), strings.ToLower(rule.Code), fmt.Sprintf("%s with %s (%s)", rule.Drug, rule.With, rule.Code), rule.RiskDelta)
+		}
+	}
+	issues = append(issues, interactionIssues(regimen, rules, l)...)
+	// A Review plan continues a medication the patient already takes, so it
+	// adds no duplicate therapy of its own.
+	var started []string
+	for i, cp := range plans {
+		if !cp.Plan.Review {
+			started = append(started, planMeds[i])
+		}
 	}
+	issues = append(issues, planDuplicates(regimen, started, s.drugClasses, l)...)
+	issues = append(issues, familyPlanIssues(assessed.FamilyHistory, plans, l)...)
+	span.End()
+
+	// Allergy cross-checks against plans and alternatives.
+	allergies := intakeAllergies(in)
+	planAllergic := false
+	for i, cp := range plans {
+		p := cp.Plan
+		allergy, ok := intersectsAllergy(allergies, p.Medication)
+		factor := "allergy_plan"
+		if allergy.Severity != "" {
+			factor += "_" + allergy.Severity
+		}
+		if risk.check(factor, "ALLERGY_PLAN", ok, func() map[string]any {
+			return map[string]any{"plan": planMeds[i], "allergy": normalizeName(allergy.Substance), "severity": allergy.Severity}
+		}) {
+			planAllergic = true
+			if allergy.Severity == "" {
+				risk.add(factor, fmt.Sprintf("Planned medication matches allergy (%s)", allergy.Substance))
+			} else {
+				risk.add(factor, fmt.Sprintf("Planned medication matches allergy (%s, %s)", allergy.Substance, allergy.Severity))
+			}
+			issues = append(issues, newIssue("ALLERGY_PLAN", SeverityDanger, l.issue("ALLERGY_PLAN", map[string]any{"Allergy": allergy.Substance}), p.Medication))
+		}
 
-	for _, alt := range alts {
-		if allergy := intersectsAllergy(in.Allergies, alt.Medication); allergy != "" {
-			issues = append(issues, Issue{
-				Type:        "allergy",
-				Severity:    "warning",
-				Description: fmt.Sprintf("Alternative %s conflicts with allergy (%s).", alt.Medication, allergy),
-			})
+		for _, alt := range cp.Alternatives {
+			if allergy, ok := intersectsAllergy(allergies, alt.Medication); ok {
+				issues = append(issues, newIssue("ALLERGY_ALTERNATIVE", SeverityWarning, l.issue("ALLERGY_ALTERNATIVE", map[string]any{"Medication": alt.Medication, "Allergy": allergy.Substance}), alt.Medication))
+			}
+		}
+
+		if risk.check("dose_cap", "DOSE_CAP_PDE5", !p.Review && exceedsDose(s.doseCaps, p.Medication, p.Dosage), func() map[string]any {
+			return map[string]any{"plan": planMeds[i], "dosage": p.Dosage, "review": p.Review}
+		}) {
+			risk.add("dose_cap", fmt.Sprintf("Dosage %s for %s exceeds starting cap", p.Dosage, p.Medication))
+			issues = append(issues, newIssue("DOSE_CAP_PDE5", SeverityWarning, l.issue("DOSE_CAP_PDE5", map[string]any{"Dosage": p.Dosage, "Medication": p.Medication}), p.Medication))
+		}
+	}
+	// A current medication matching a listed allergy is a conflict in the
+	// intake for the clinician to resolve, not a risk of the plan.
+	for _, m := range in.Medications {
+		if allergy, ok := intersectsAllergy(allergies, m.Name); ok && normalizeName(m.Name) != "" {
+			issues = append(issues, newIssue("ALLERGY_MEDICATION", SeverityWarning, l.issue("ALLERGY_MEDICATION", map[string]any{"Medication": m.Name, "Allergy": allergy.Substance}), m.Name))
 		}
 	}
 
-	if exceedsDose(plan.Medication, plan.Dosage) {
-		riskScore += 2
-		issues = append(issues, Issue{
-			Type:        "dose_cap",
-			Severity:    "warning",
-			Description: fmt.Sprintf("Dosage %s for %s may exceed common starting caps. Consider reducing.", plan.Dosage, plan.Medication),
+	// Alternatives get the same checks as the plan they would replace; those
+	// with a danger-level conflict are dropped and the rest ranked.
+	for i := range plans {
+		// Alternatives replace the plan medication, including one the
+		// patient takes when the plan reviews it.
+		others := maps.Clone(regimen)
+		if !meds[planMeds[i]] || plans[i].Plan.Review {
+			delete(others, planMeds[i])
+		}
+		plans[i].Alternatives = rankAlternatives(plans[i].Alternatives, suitabilityChecks{
+			Regimen:      others,
+			Conditions:   cond,
+			Allergies:    allergies,
+			HasNitrate:   hasNitrate,
+			NitratePRN:   assessed.NitratePRN,
+			HeavyAlcohol: heavyAlcohol(in),
+			Rules:        rules,
+			Classes:      s.drugClasses,
+			Localizer:    l,
 		})
 	}
+	alts = plans[0].Alternatives
+	stop()
+
+	riskScore := risk.score
+	riskLevel := classifyRisk(riskScore, s.thresholds)
+	riskNormalized := normalizeRiskScore(riskScore, s.maxRiskScore())
 
-	riskLevel := classifyRisk(riskScore)
+	issues = finalizeIssues(issues)
 
-	llm := callLLMStub(in, plan, alts)
+	scoreReq := ScoreRequest{
+		Intake:          in,
+		Plan:            plan,
+		Alternatives:    append([]Alternative(nil), alts...),
+		RiskScore:       riskScore,
+		RiskLevel:       riskLevel,
+		Issues:          append([]Issue(nil), issues...),
+		PlanSubstituted: hasNitrate || planAllergic,
+		SystemPrompt:    s.promptInfo.Prompt,
+		historyCeiling:  s.historyCeiling,
+	}
+	var llm LLMResult
+	var degraded bool
+	llmCtx, span := trace.Start(ctx, "analysis.llm_score", trace.Bool("llm.shadow", s.shadow))
+	stop = timer.begin(stageLLMScoring)
+	if s.shadow {
+		llm = callLLMStub(scoreReq)
+	} else {
+		llm, degraded = scorePlan(llmCtx, s, scoreReq)
+	}
+	stop()
+	span.SetAttributes(trace.Bool("llm.cache_hit", llm.Cached), trace.Bool("llm.degraded", degraded))
+	span.End()
+	if degraded {
+		issues = finalizeIssues(append(issues, newIssue("LLM_SCORING_DEGRADED", SeverityInfo, l.issue("LLM_SCORING_DEGRADED", nil))))
+	}
 	planConfidence := llm.PlanConfidence
 	alts = mergeAltConfidence(alts, llm.AlternativeConf)
-
-	if issues == nil {
-		issues = []Issue{}
+	// A sparse history reads as uncertainty whichever scorer ran.
+	if len(unconfirmed) > 0 {
+		planConfidence = min(planConfidence, s.historyCeiling)
+		for i := range alts {
+			alts[i].Confidence = min(alts[i].Confidence, s.historyCeiling)
+		}
 	}
+
 	if alts == nil {
 		alts = []Alternative{}
 	}
 
 	resp := Response{
-		RiskLevel:       riskLevel,
-		RiskScore:       riskScore,
-		FlaggedIssues:   issues,
-		RecommendedPlan: plan,
-		PlanConfidence:  planConfidence,
-		Alternatives:    alts,
-		ComputedBMI:     bmi,
+		SchemaVersion:       SchemaVersion,
+		RiskLevel:           riskLevel,
+		RiskScore:           riskScore,
+		RiskScoreNormalized: riskNormalized,
+		RiskFactors:         risk.factors,
+		FlaggedIssues:       issues,
+		RecommendedPlan:     plan,
+		PlanConfidence:      planConfidence,
+		Alternatives:        alts,
+		ComputedBMI:         bmi,
+		ProvidedBMI:         in.BMI,
+		Conditions:          slices.Sorted(maps.Keys(cond)),
+		LLMCacheHit:         llm.Cached,
+		PromptVersion:       s.promptInfo.Version,
+		RulesetVersion:      s.rulesetVersion(),
+		DryRun:              opts.DryRun,
+		Disclaimers:         s.stampedDisclaimers(),
+	}
+	resp.UnmappedConditionCodes = unmappedCodes
+	resp.FamilyHistory = slices.Sorted(maps.Keys(assessed.FamilyHistory))
+	plans[0].Alternatives = alts
+	resp.Plans = plans
+	resp.ComplaintSeverity = normalizeName(in.ComplaintSeverity)
+	resp.ComplaintDurationWeeks = in.ComplaintDurationWeeks
+	resp.Education = s.education.match(complaints, planMeds, issues, l.locale)
+	resp.MedicationsAssessed = s.assessMedications(in.Medications, issues)
+	if opts.Debug && llm.Factors != nil {
+		f := *llm.Factors
+		if len(unconfirmed) > 0 {
+			f.UnconfirmedSections, f.HistoryCeiling = unconfirmed, s.historyCeiling
+		}
+		resp.ConfidenceFactors = &f
 	}
+	return scoredResponse{resp: resp, llm: llm, req: scoreReq, degraded: degraded, trace: risk.trace}
+}
 
-	if auditID, auditAt, err := recordAudit(in, riskLevel, riskScore); err != nil {
-		resp.ValidationErrors = append(resp.ValidationErrors, "failed to persist audit log")
-	} else {
-		resp.AuditID = auditID
-		resp.AuditAt = auditAt
+type buildPlanContext struct {
+	BMI        float64
+	HasNitrate bool
+	NitratePRN bool
+	HasHeartDz bool
+	HasRenal   bool
+	HasHepatic bool
+	Localizer  localizer
+	// Severity and DurationWeeks describe the complaint being planned; they
+	// are only set for the primary complaint.
+	Severity      string
+	DurationWeeks int
+	// Prior is the normalized treatment history; see ruledOut.
+	Prior []PriorTreatment
+	// Sedentary and ShortSleep strengthen the lifestyle advice of the ED
+	// and weight loss rationales.
+	Sedentary  bool
+	ShortSleep bool
+	// MetabolicSyndrome strengthens the weight loss rationales.
+	MetabolicSyndrome bool
+	// CurrentPDE5 is the PDE5 inhibitor the patient already takes, if any;
+	// the ED plan then reviews it instead of starting another.
+	CurrentPDE5 *Medication
+}
+
+// Prior treatment outcomes accepted in PriorTreatment.Outcome.
+var priorOutcomes = []string{"effective", "ineffective", "intolerant"}
+
+// normalizePriorTreatments lowercases medications and outcomes so they
+// compare against plan medications.
+func normalizePriorTreatments(prior []PriorTreatment) []PriorTreatment {
+	out := make([]PriorTreatment, 0, len(prior))
+	for _, p := range prior {
+		p.Medication = normalizeName(p.Medication)
+		p.MaxDose = strings.TrimSpace(p.MaxDose)
+		p.Outcome = normalizeName(p.Outcome)
+		out = append(out, p)
 	}
+	return out
+}
 
-	if verrs := ValidateResponse(resp); len(verrs) > 0 {
-		resp.ValidationErrors = append(resp.ValidationErrors, verrs...)
+// ruledOut returns the prior treatment that rules out med: one whose
+// medication med names and that was ineffective or not tolerated.
+func (ctx buildPlanContext) ruledOut(med string) (PriorTreatment, bool) {
+	name := normalizeName(med)
+	for _, p := range ctx.Prior {
+		if p.Medication != "" && p.Outcome != "effective" && strings.Contains(name, p.Medication) {
+			return p, true
+		}
 	}
+	return PriorTreatment{}, false
+}
 
-	return resp
+// untried drops the alternatives a prior treatment rules out.
+func (ctx buildPlanContext) untried(alts []Alternative) []Alternative {
+	out := make([]Alternative, 0, len(alts))
+	for _, alt := range alts {
+		if _, ruled := ctx.ruledOut(alt.Medication); !ruled {
+			out = append(out, alt)
+		}
+	}
+	return out
 }
 
-type llmResult struct {
-	PlanConfidence  float64
-	AlternativeConf []float64
+// Complaint severities accepted in Intake.ComplaintSeverity.
+var complaintSeverities = []string{"mild", "moderate", "severe"}
+
+const (
+	// maxComplaintDurationWeeks bounds a plausible complaint duration
+	// (50 years).
+	maxComplaintDurationWeeks = 2600
+	// chronicComplaintWeeks marks a long-standing complaint.
+	chronicComplaintWeeks = 52
+	// shortComplaintWeeks marks a recent complaint, for which lifestyle
+	// measures come first.
+	shortComplaintWeeks = 12
+	// watchfulWaitingWeeks is the duration below which self-limiting
+	// complaints get a watchful-waiting suggestion.
+	watchfulWaitingWeeks = 2
+)
+
+// watchfulWaitingComplaints can resolve on their own when recent.
+var watchfulWaitingComplaints = []string{"ed", "hair loss"}
+
+// complaintPriority lists the complaints with a dedicated plan, highest
+// priority first; the first one an intake names becomes recommendedPlan.
+var complaintPriority = []string{"ed", "weight loss", "hair loss"}
+
+// intakeAssessment is what assessIntake reads from an intake alone.
+type intakeAssessment struct {
+	Issues        []Issue
+	Risk          *riskAccumulator
+	BMI           float64
+	Conditions    map[string]bool
+	UnmappedCodes []string
+	FamilyHistory map[string]bool
+	Metabolic     metabolicSyndrome
+	Meds          map[string]bool
+	HasNitrate    bool
+	// NitratePRN is set when every nitrate is taken as needed.
+	NitratePRN bool
 }
 
-// callLLMStub simulates an LLM scoring step while keeping deterministic guardrails.
-func callLLMStub(in Intake, plan Plan, alts []Alternative) llmResult {
-	// Simple heuristic confidence based on risk and completeness of intake.
-	coverage := 0.6
-	if in.BP != "" {
-		coverage += 0.05
+// assessIntake runs the risk factors and issues that need no plan: BMI,
+// blood pressure, conditions, metabolic syndrome, family history, age,
+// lifestyle, and nitrate therapy. Analyze
+// builds on it, and CheckPartialIntake reports it for intakes still being
+// filled in.
+func assessIntake(in Intake, risk *riskAccumulator, l localizer) intakeAssessment {
+	var issues []Issue
+	risk.check("baseline", "", true, nil)
+	risk.add("baseline", "Baseline risk applied to every analysis")
+
+	bmi, bmiIssue := effectiveBMI(in, l)
+	if bmiIssue != nil {
+		issues = append(issues, *bmiIssue)
+	}
+
+	bmiInputs := func() map[string]any { return map[string]any{"bmi": math.Round(bmi*10) / 10} }
+	if risk.check("bmi_obesity", "BMI_OBESITY", bmi >= bmiObesity, bmiInputs) {
+		risk.add("bmi_obesity", fmt.Sprintf("BMI %.1f (obesity)", bmi))
+		issues = append(issues, newIssue("BMI_OBESITY", SeverityWarning, l.issue("BMI_OBESITY", map[string]any{"BMI": fmt.Sprintf("%.1f", bmi)})))
+	} else if risk.check("bmi_elevated", "BMI_ELEVATED", bmi >= bmiElevated, bmiInputs) {
+		risk.add("bmi_elevated", fmt.Sprintf("BMI %.1f (elevated)", bmi))
+		issues = append(issues, newIssue("BMI_ELEVATED", SeverityInfo, l.issue("BMI_ELEVATED", map[string]any{"BMI": fmt.Sprintf("%.1f", bmi)})))
+	}
+
+	systolic, diastolic, _ := parseBP(in.BP)
+	bpInputs := func() map[string]any { return map[string]any{"systolic": systolic, "diastolic": diastolic} }
+	if risk.check("bp_uncontrolled", "BP_UNCONTROLLED", systolic >= bpUncontrolledSystolic || diastolic >= bpUncontrolledDiastolic, bpInputs) {
+		risk.add("bp_uncontrolled", fmt.Sprintf("Uncontrolled blood pressure %s", in.BP))
+		issues = append(issues, newIssue("BP_UNCONTROLLED", SeverityDanger, l.issue("BP_UNCONTROLLED", map[string]any{"BP": in.BP})))
+	} else if risk.check("bp_elevated", "BP_ELEVATED", systolic >= bpElevatedSystolic || diastolic >= bpElevatedDiastolic, bpInputs) {
+		risk.add("bp_elevated", fmt.Sprintf("Elevated blood pressure %s", in.BP))
+		issues = append(issues, newIssue("BP_ELEVATED", SeverityWarning, l.issue("BP_ELEVATED", map[string]any{"BP": in.BP})))
+	}
+
+	cond, unmapped := normalizeConditions(in.Conditions)
+	unmappedCodes := mergeConditionCodes(cond, in.ConditionCodes)
+	if len(unmapped) > 0 {
+		issues = append(issues, newIssue("CONDITION_UNMAPPED", SeverityInfo, l.issue("CONDITION_UNMAPPED", map[string]any{"Conditions": strings.Join(unmapped, ", ")})))
 	}
-	if len(in.Conditions) > 0 {
-		coverage += 0.05
+	condInputs := func() map[string]any { return map[string]any{"conditions": slices.Sorted(maps.Keys(cond))} }
+	if risk.check("heart_disease", "COND_HEART_DISEASE", cond[condHeartDisease], condInputs) {
+		risk.add("heart_disease", "History of heart disease")
+		issues = append(issues, newIssue("COND_HEART_DISEASE", SeverityDanger, l.issue("COND_HEART_DISEASE", nil)))
 	}
-	if len(in.Medications) > 0 {
-		coverage += 0.05
+	if risk.check("kidney_disease", "COND_KIDNEY_DISEASE", cond[condKidneyDisease], condInputs) {
+		risk.add("kidney_disease", "Kidney disease")
+		issues = append(issues, newIssue("COND_KIDNEY_DISEASE", SeverityWarning, l.issue("COND_KIDNEY_DISEASE", nil)))
 	}
-	if in.Allergies != nil {
-		coverage += 0.05
+	if risk.check("liver_disease", "COND_LIVER_DISEASE", cond[condLiverDisease], condInputs) {
+		risk.add("liver_disease", "Liver disease")
+		issues = append(issues, newIssue("COND_LIVER_DISEASE", SeverityWarning, l.issue("COND_LIVER_DISEASE", nil)))
 	}
+	if risk.check("diabetes", "COND_DIABETES", cond[condDiabetes], condInputs) {
+		risk.add("diabetes", "Diabetes")
+		issues = append(issues, newIssue("COND_DIABETES", SeverityInfo, l.issue("COND_DIABETES", nil)))
+	}
+	if risk.check("hypertension", "", cond[condHypertension], condInputs) {
+		risk.add("hypertension", "Hypertension history")
+	}
+
+	metabolic, metabolicIssues := assessMetabolicSyndrome(in, cond, risk, l)
+	issues = append(issues, metabolicIssues...)
 
-	planConfidence := clamp(0.55+coverage*0.3, 0, 0.95)
-	altConf := make([]float64, len(alts))
-	for i := range alts {
-		altConf[i] = clamp(planConfidence-0.05*float64(i+1), 0.4, 0.9)
+	family, unmappedFamily := normalizeFamilyHistory(in.FamilyHistory)
+	issues = append(issues, assessFamilyHistory(family, unmappedFamily, in, risk, l)...)
+
+	ageInputs := func() map[string]any { return map[string]any{"age": in.Age} }
+	if risk.check("age_over_65", "AGE_OVER_65", in.Age > 65, ageInputs) {
+		risk.add("age_over_65", fmt.Sprintf("Age %d (>65)", in.Age))
+		issues = append(issues, newIssue("AGE_OVER_65", SeverityInfo, l.issue("AGE_OVER_65", nil)))
+	} else if risk.check("age_55_to_65", "", in.Age >= 55, ageInputs) {
+		risk.add("age_55_to_65", fmt.Sprintf("Age %d (55-65)", in.Age))
 	}
-	return llmResult{
-		PlanConfidence:  planConfidence,
-		AlternativeConf: altConf,
+
+	issues = append(issues, assessSmoking(in, risk, l)...)
+	issues = append(issues, assessAlcohol(in, risk, l)...)
+	issues = append(issues, assessLifestyle(in, risk, l)...)
+
+	meds := normalizeMeds(in.Medications)
+	prnNitrates, scheduledNitrates := nitrateUse(in.Medications)
+	hasNitrate := len(prnNitrates)+len(scheduledNitrates) > 0
+	nitrateCode := "CI_NITRATE_PDE5"
+	if len(scheduledNitrates) == 0 {
+		nitrateCode = "CI_NITRATE_PRN_PDE5"
+	}
+	if risk.check("nitrate_therapy", nitrateCode, hasNitrate, func() map[string]any {
+		return map[string]any{"scheduledNitrates": len(scheduledNitrates), "asNeededNitrates": len(prnNitrates)}
+	}) {
+		risk.add("nitrate_therapy", "Nitrate therapy (PDE5 contraindication)")
+	}
+	if len(scheduledNitrates) > 0 {
+		issues = append(issues, nitrateIssue(false, l, scheduledNitrates...))
+	}
+	if len(prnNitrates) > 0 {
+		issues = append(issues, nitrateIssue(true, l, prnNitrates...))
+	}
+	return intakeAssessment{
+		Issues:        issues,
+		Risk:          risk,
+		BMI:           bmi,
+		Conditions:    cond,
+		UnmappedCodes: unmappedCodes,
+		FamilyHistory: family,
+		Metabolic:     metabolic,
+		Meds:          meds,
+		HasNitrate:    hasNitrate,
+		NitratePRN:    hasNitrate && len(scheduledNitrates) == 0,
 	}
 }
 
-func mergeAltConfidence(alts []Alternative, conf []float64) []Alternative {
-	for i := range alts {
-		if i < len(conf) {
-			alts[i].Confidence = conf[i]
+// intakeComplaints merges Complaint and Complaints, trimmed and de-duplicated
+// case-insensitively, with recognized complaints first in priority order and
+// the rest in the order given.
+func intakeComplaints(in Intake) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, c := range append([]string{in.Complaint}, in.Complaints...) {
+		c = strings.TrimSpace(c)
+		if key := strings.ToLower(c); c != "" && !seen[key] {
+			seen[key] = true
+			out = append(out, c)
+		}
+	}
+	rank := func(c string) int {
+		if i := slices.Index(complaintPriority, strings.ToLower(c)); i >= 0 {
+			return i
 		}
+		return len(complaintPriority)
 	}
-	return alts
+	slices.SortStableFunc(out, func(a, b string) int { return rank(a) - rank(b) })
+	return out
 }
 
-type buildPlanContext struct {
-	BMI        float64
-	HasNitrate bool
-	HasHeartDz bool
-	HasRenal   bool
-	HasHepatic bool
+// buildPlans builds a plan for each recognized complaint, in the order given;
+// ctx's severity and duration apply to the first only. Builders skip
+// medications prior treatment rules out, and such alternatives are dropped. Unrecognized
+// complaints share the general wellness plan, which is only built when no
+// complaint is recognized. The result is never empty.
+func buildPlans(complaints []string, ctx buildPlanContext) []ComplaintPlan {
+	var out []ComplaintPlan
+	for _, c := range complaints {
+		if slices.Contains(complaintPriority, strings.ToLower(c)) {
+			plan, alts := buildPlan(c, ctx)
+			out = append(out, ComplaintPlan{Complaint: c, Plan: plan, Alternatives: ctx.untried(alts)})
+			ctx.Severity, ctx.DurationWeeks = "", 0
+		}
+	}
+	if len(out) == 0 {
+		complaint := ""
+		if len(complaints) > 0 {
+			complaint = complaints[0]
+		}
+		plan, alts := generalWellnessPlan(ctx)
+		out = append(out, ComplaintPlan{Complaint: complaint, Plan: plan, Alternatives: ctx.untried(alts)})
+	}
+	return out
+}
+
+// planDuplicates flags duplicate therapy within a drug class where at least
+// one of the pair is a planned medication, so a plan never doubles up on a
+// class the patient or another plan already covers.
+func planDuplicates(regimen map[string]bool, planMeds []string, classes []DrugClass, l localizer) []Issue {
+	var out []Issue
+	for _, c := range classes {
+		members := matchingMedications(regimen, c.Members)
+		for i := range members {
+			for _, other := range members[i+1:] {
+				if !slices.Contains(planMeds, members[i]) && !slices.Contains(planMeds, other) {
+					continue
+				}
+				data := map[string]any{"First": members[i], "Second": other, "Class": c.Name}
+				out = append(out, newIssue("DUP_THERAPY", SeverityWarning, l.issue("DUP_THERAPY", data), members[i], other))
+			}
+		}
+	}
+	return out
 }
 
-func buildPlan(in Intake, ctx buildPlanContext) (Plan, []Alternative) {
-	switch strings.ToLower(in.Complaint) {
+func buildPlan(complaint string, ctx buildPlanContext) (Plan, []Alternative) {
+	switch strings.ToLower(complaint) {
 	case "ed":
 		return edPlan(ctx)
 	case "hair loss":
-		return hairLossPlan()
+		return hairLossPlan(ctx)
 	case "weight loss":
 		return weightLossPlan(ctx)
 	default:
-		return generalWellnessPlan()
+		return generalWellnessPlan(ctx)
 	}
 }
 
 func edPlan(ctx buildPlanContext) (Plan, []Alternative) {
 	if ctx.HasNitrate {
+		followUp := "Reassess ED options once cardiology has reviewed nitrate therapy"
+		if ctx.NitratePRN {
+			followUp = "Ask cardiology whether the as-needed nitrate can be stopped, then reassess ED options"
+		}
 		return Plan{
 				Medication: "Hold PDE5 inhibitors",
 				Dosage:     "N/A",
 				Frequency:  "Avoid until nitrates stopped",
 				Duration:   "Reassess after nitrate-free period",
-				Rationale:  "Nitrate therapy makes PDE5 inhibitors unsafe. Prioritize cardiology review and lifestyle optimization for ED.",
+				Rationale:  ctx.Localizer.text("rationale.ed_nitrate", map[string]any{"PRN": ctx.NitratePRN}, ""),
+				Monitoring: []string{"Cardiology review of nitrate therapy"},
+				FollowUp:   &FollowUp{IntervalDays: 28, Instructions: followUp},
 			}, []Alternative{
 				{
 					Medication: "Lifestyle & psychosexual therapy",
@@ -420,17 +997,34 @@ func edPlan(ctx buildPlanContext) (Plan, []Alternative) {
 			}
 	}
 
+	if m := ctx.CurrentPDE5; m != nil {
+		if _, out := ctx.ruledOut(m.Name); !out {
+			return edReviewPlan(ctx, *m)
+		}
+	}
+
+	if _, out := ctx.ruledOut("tadalafil"); out {
+		if _, out := ctx.ruledOut("sildenafil"); out {
+			return edReferralPlan(ctx)
+		}
+		return sildenafilPlan(ctx)
+	}
+
 	dose := "10mg"
 	if ctx.HasRenal || ctx.HasHepatic {
 		dose = "5mg (start low due to renal/hepatic risk)"
 	}
-	rationale := "First-line PDE5 inhibitor; long half-life for flexibility. Start low to minimize hypotension risk; reinforce BP monitoring."
-	if ctx.HasHeartDz {
-		rationale += " Cardiac history—ensure clearance before sexual activity."
-	}
-	if ctx.BMI >= 27 {
-		rationale += " Encourage weight and activity changes to improve ED and cardiometabolic profile."
+	prior := ""
+	if p, out := ctx.ruledOut("sildenafil"); out {
+		prior = priorTreatmentLabel(p)
 	}
+	rationale := ctx.Localizer.text("rationale.ed_pde5", map[string]any{
+		"CardiacHistory":  ctx.HasHeartDz,
+		"ElevatedBMI":     ctx.BMI >= bmiElevated,
+		"SevereOrChronic": ctx.Severity == "severe" || ctx.DurationWeeks >= chronicComplaintWeeks,
+		"PriorPDE5":       prior,
+		"Sedentary":       ctx.Sedentary,
+	}, "")
 
 	return Plan{
 			Medication: "Tadalafil",
@@ -438,6 +1032,8 @@ func edPlan(ctx buildPlanContext) (Plan, []Alternative) {
 			Frequency:  "As needed, 30-60 minutes before sexual activity",
 			Duration:   "30-day supply, renew after follow-up",
 			Rationale:  rationale,
+			Monitoring: []string{"Blood pressure check at 2-4 weeks", "Dizziness or hypotension after doses"},
+			FollowUp:   &FollowUp{IntervalDays: 28, Instructions: "Recheck blood pressure and review response and side effects within 2-4 weeks"},
 		}, []Alternative{
 			{
 				Medication: "Sildenafil",
@@ -454,13 +1050,152 @@ func edPlan(ctx buildPlanContext) (Plan, []Alternative) {
 		}
 }
 
-func hairLossPlan() (Plan, []Alternative) {
+// priorTreatmentLabel names a prior treatment with the highest dose tried.
+func priorTreatmentLabel(p PriorTreatment) string {
+	if p.MaxDose == "" {
+		return p.Medication
+	}
+	return p.Medication + " " + p.MaxDose
+}
+
+// currentPDE5 returns the first medication that is a PDE5 inhibitor, or nil.
+func currentPDE5(meds []Medication) *Medication {
+	for i, m := range meds {
+		if usesPDE5(m.Name) {
+			return &meds[i]
+		}
+	}
+	return nil
+}
+
+// edReviewPlan is the ED plan for a patient already taking a PDE5
+// inhibitor: the current agent is reviewed, with switching as the
+// alternative. The plan names the medication as the intake does, so it is
+// one regimen entry with it, and analyze skips the duplicate-therapy and
+// starting-dose checks for a Review plan.
+func edReviewPlan(ctx buildPlanContext, current Medication) (Plan, []Alternative) {
+	label := strings.TrimSpace(current.Name)
+	if d := strings.TrimSpace(current.Dosage); d != "" {
+		label += " " + d
+	}
+	dosage := strings.TrimSpace(current.Dosage)
+	if dosage == "" {
+		dosage = "Current dose"
+	}
+	frequency := strings.TrimSpace(current.Frequency)
+	if frequency == "" {
+		frequency = "As currently taken"
+	}
+	switchTo := Alternative{
+		Medication: "Tadalafil",
+		Dosage:     "10mg as needed, once the current agent is stopped",
+		Pros:       []string{"Longer window (up to 36h)", "Less timing around meals"},
+		Cons:       []string{"Longer-lasting side effects"},
+	}
+	if strings.Contains(normalizeName(current.Name), "tadalafil") {
+		switchTo = Alternative{
+			Medication: "Sildenafil",
+			Dosage:     "50mg as needed, once tadalafil is stopped",
+			Pros:       []string{"Lower cost", "Shorter duration if side effects occur"},
+			Cons:       []string{"Shorter window (4-6h)", "Requires timing around meals"},
+		}
+	}
+	return Plan{
+			Medication: strings.TrimSpace(current.Name),
+			Dosage:     dosage,
+			Frequency:  frequency,
+			Duration:   "Continue until reviewed",
+			Rationale: ctx.Localizer.text("rationale.ed_review", map[string]any{
+				"Current":        label,
+				"CardiacHistory": ctx.HasHeartDz,
+				"Sedentary":      ctx.Sedentary,
+			}, ""),
+			Monitoring: []string{"Response, adherence, and dose timing at the current agent", "Blood pressure and side effects"},
+			FollowUp:   &FollowUp{IntervalDays: 28, Instructions: "Review response at an optimized dose before switching agents"},
+			Review:     true,
+		}, []Alternative{
+			switchTo,
+			{
+				Medication: "Urology referral",
+				Dosage:     "N/A",
+				Pros:       []string{"Access to second-line therapies"},
+				Cons:       []string{"Wait for specialist appointment"},
+			},
+		}
+}
+
+// sildenafilPlan is the ED plan when tadalafil was ineffective or not
+// tolerated.
+func sildenafilPlan(ctx buildPlanContext) (Plan, []Alternative) {
+	dose := "50mg as needed (25mg if sensitive)"
+	if ctx.HasRenal || ctx.HasHepatic {
+		dose = "25mg (start low due to renal/hepatic risk)"
+	}
+	return Plan{
+			Medication: "Sildenafil",
+			Dosage:     dose,
+			Frequency:  "As needed, about 1 hour before sexual activity",
+			Duration:   "30-day supply, renew after follow-up",
+			Rationale:  ctx.Localizer.text("rationale.ed_sildenafil", map[string]any{"CardiacHistory": ctx.HasHeartDz, "Sedentary": ctx.Sedentary}, ""),
+			Monitoring: []string{"Blood pressure check at 2-4 weeks", "Dizziness or hypotension after doses"},
+			FollowUp:   &FollowUp{IntervalDays: 28, Instructions: "Review response within 2-4 weeks; refer to urology if sildenafil also fails"},
+		}, []Alternative{
+			{
+				Medication: "Vacuum erection device",
+				Dosage:     "Device-assisted",
+				Pros:       []string{"Non-pharmacologic", "No drug interactions"},
+				Cons:       []string{"Less spontaneity", "Training required"},
+			},
+			{
+				Medication: "Urology referral",
+				Dosage:     "N/A",
+				Pros:       []string{"Access to second-line therapies"},
+				Cons:       []string{"Wait for specialist appointment"},
+			},
+		}
+}
+
+// edReferralPlan is the ED plan for PDE5 non-responders: both PDE5
+// inhibitors the engine offers were ineffective or not tolerated.
+func edReferralPlan(ctx buildPlanContext) (Plan, []Alternative) {
+	return Plan{
+			Medication: "Urology referral",
+			Dosage:     "N/A",
+			Frequency:  "Specialist assessment",
+			Duration:   "Until specialist review",
+			Rationale:  ctx.Localizer.text("rationale.ed_referral", nil, ""),
+			FollowUp:   &FollowUp{IntervalDays: 28, Instructions: "Confirm the urology appointment and review interim measures"},
+		}, []Alternative{
+			{
+				Medication: "Vacuum erection device",
+				Dosage:     "Device-assisted",
+				Pros:       []string{"Non-pharmacologic", "No drug interactions"},
+				Cons:       []string{"Less spontaneity", "Training required"},
+			},
+			{
+				Medication: "Lifestyle & psychosexual therapy",
+				Dosage:     "N/A",
+				Pros:       []string{"No hemodynamic risk", "Addresses vascular + psychogenic factors"},
+				Cons:       []string{"Slower onset of benefit"},
+			},
+		}
+}
+
+func hairLossPlan(ctx buildPlanContext) (Plan, []Alternative) {
+	if _, out := ctx.ruledOut("finasteride"); out {
+		if _, out := ctx.ruledOut("minoxidil"); out {
+			return hairLossReferralPlan(ctx)
+		}
+		return minoxidilPlan(ctx)
+	}
 	return Plan{
 			Medication: "Finasteride",
 			Dosage:     "1mg orally once daily",
 			Frequency:  "Daily",
 			Duration:   "3-6 months before full effect",
-			Rationale:  "DHT blocker with best evidence for male pattern hair loss. Monitor for sexual side effects; avoid if trying to conceive.",
+			Rationale:  ctx.Localizer.text("rationale.hair_loss", nil, ""),
+			Monitoring: []string{"Sexual side effects (libido, erectile function) at 3 months", "Mood changes"},
+			FollowUp:   &FollowUp{IntervalDays: 90, Instructions: "Review sexual side effects and early response at 3 months"},
 		}, []Alternative{
 			{
 				Medication: "Topical Minoxidil 5%",
@@ -477,11 +1212,70 @@ func hairLossPlan() (Plan, []Alternative) {
 		}
 }
 
+// minoxidilPlan is the hair loss plan when finasteride was ineffective or
+// not tolerated.
+func minoxidilPlan(ctx buildPlanContext) (Plan, []Alternative) {
+	return Plan{
+			Medication: "Topical Minoxidil 5%",
+			Dosage:     "Apply 1ml to scalp twice daily",
+			Frequency:  "Twice daily",
+			Duration:   "4-6 months before assessing effect",
+			Rationale:  ctx.Localizer.text("rationale.hair_loss_minoxidil", nil, ""),
+			Monitoring: []string{"Scalp irritation", "Shedding in the first 2-8 weeks"},
+			FollowUp:   &FollowUp{IntervalDays: 120, Instructions: "Review response at 4 months"},
+		}, []Alternative{
+			{
+				Medication: "Low-level laser therapy",
+				Dosage:     "Per device guidance",
+				Pros:       []string{"Non-drug option"},
+				Cons:       []string{"Variable evidence", "Cost"},
+			},
+			{
+				Medication: "Dermatology referral",
+				Dosage:     "N/A",
+				Pros:       []string{"Confirms diagnosis", "Access to further options"},
+				Cons:       []string{"Wait for specialist appointment"},
+			},
+		}
+}
+
+// hairLossReferralPlan is the hair loss plan when finasteride and minoxidil
+// were both ineffective or not tolerated.
+func hairLossReferralPlan(ctx buildPlanContext) (Plan, []Alternative) {
+	return Plan{
+			Medication: "Dermatology referral",
+			Dosage:     "N/A",
+			Frequency:  "Specialist assessment",
+			Duration:   "Until specialist review",
+			Rationale:  ctx.Localizer.text("rationale.hair_loss_referral", nil, ""),
+			FollowUp:   &FollowUp{IntervalDays: 60, Instructions: "Confirm the dermatology appointment"},
+		}, []Alternative{
+			{
+				Medication: "Low-level laser therapy",
+				Dosage:     "Per device guidance",
+				Pros:       []string{"Non-drug option"},
+				Cons:       []string{"Variable evidence", "Cost"},
+			},
+		}
+}
+
 func weightLossPlan(ctx buildPlanContext) (Plan, []Alternative) {
-	rationale := "Calorie deficit with structured activity. Metformin aids insulin sensitivity; start low to reduce GI effects."
-	if ctx.BMI >= 35 {
-		rationale += " Consider GLP-1 RA if no contraindications and coverage allows."
+	recent := ctx.DurationWeeks > 0 && ctx.DurationWeeks < shortComplaintWeeks && ctx.Severity != "severe"
+	if (ctx.Severity == "mild" || recent) && ctx.BMI < 35 {
+		return lifestyleWeightLossPlan(ctx)
 	}
+	if _, out := ctx.ruledOut("metformin"); out {
+		if _, out := ctx.ruledOut("glp-1"); out {
+			return lifestyleWeightLossPlan(ctx)
+		}
+		return glp1Plan(ctx)
+	}
+	rationale := ctx.Localizer.text("rationale.weight_loss", map[string]any{
+		"SevereObesity": ctx.BMI >= 35,
+		"Sedentary":     ctx.Sedentary,
+		"ShortSleep":    ctx.ShortSleep,
+		"Metabolic":     ctx.MetabolicSyndrome,
+	}, "")
 
 	return Plan{
 			Medication: "Metformin",
@@ -489,6 +1283,8 @@ func weightLossPlan(ctx buildPlanContext) (Plan, []Alternative) {
 			Frequency:  "Once daily start; can increase to BID",
 			Duration:   "12-week trial with reassessment",
 			Rationale:  rationale,
+			Monitoring: []string{"Renal function (eGFR) annually", "Vitamin B12 annually", "GI tolerance during titration"},
+			FollowUp:   &FollowUp{IntervalDays: 84, Instructions: "Reassess weight, tolerance, and dose at the end of the 12-week trial"},
 		}, []Alternative{
 			{
 				Medication: "GLP-1 receptor agonist",
@@ -505,13 +1301,70 @@ func weightLossPlan(ctx buildPlanContext) (Plan, []Alternative) {
 		}
 }
 
-func generalWellnessPlan() (Plan, []Alternative) {
+// glp1Plan is the weight loss plan when metformin was ineffective or not
+// tolerated.
+func glp1Plan(ctx buildPlanContext) (Plan, []Alternative) {
+	return Plan{
+			Medication: "GLP-1 receptor agonist",
+			Dosage:     "Per product labeling (e.g., weekly titration)",
+			Frequency:  "Weekly injection",
+			Duration:   "12-week trial with reassessment",
+			Rationale:  ctx.Localizer.text("rationale.weight_loss_glp1", map[string]any{"Metabolic": ctx.MetabolicSyndrome}, ""),
+			Monitoring: []string{"GI tolerance during titration", "Weight monthly"},
+			FollowUp:   &FollowUp{IntervalDays: 84, Instructions: "Reassess weight, tolerance, and dose at the end of the 12-week trial"},
+		}, []Alternative{
+			{
+				Medication: "Intensive lifestyle program",
+				Dosage:     "Nutrition + activity + sleep plan",
+				Pros:       []string{"Foundational", "No drug interactions"},
+				Cons:       []string{"Requires adherence", "Slower results"},
+			},
+		}
+}
+
+// lifestyleWeightLossPlan defers metformin for a mild or recent weight
+// concern without severe obesity, and is the fallback when metformin and
+// GLP-1 receptor agonists were both ineffective or not tolerated.
+func lifestyleWeightLossPlan(ctx buildPlanContext) (Plan, []Alternative) {
+	_, metforminTried := ctx.ruledOut("metformin")
+	return Plan{
+			Medication: "Intensive lifestyle program",
+			Dosage:     "Nutrition + activity + sleep plan",
+			Frequency:  "Daily habits, weekly check-ins",
+			Duration:   "12 weeks before considering medication",
+			Rationale:  ctx.Localizer.text("rationale.weight_loss_lifestyle", map[string]any{
+				"MetforminTried": metforminTried,
+				"Sedentary":      ctx.Sedentary,
+				"ShortSleep":     ctx.ShortSleep,
+				"Metabolic":      ctx.MetabolicSyndrome,
+			}, ""),
+			Monitoring: []string{"Weight and waist circumference monthly"},
+			FollowUp:   &FollowUp{IntervalDays: 84, Instructions: "Reassess progress at 12 weeks and consider metformin if it stalls"},
+		}, []Alternative{
+			{
+				Medication: "Metformin",
+				Dosage:     "500mg with dinner, uptitrate as tolerated",
+				Pros:       []string{"Aids insulin sensitivity", "Low cost"},
+				Cons:       []string{"GI side effects", "Modest weight effect"},
+			},
+			{
+				Medication: "GLP-1 receptor agonist",
+				Dosage:     "Per product labeling (e.g., weekly titration)",
+				Pros:       []string{"Robust weight loss", "Cardiometabolic benefit"},
+				Cons:       []string{"Cost/coverage", "GI side effects", "Avoid in medullary thyroid cancer history"},
+			},
+		}
+}
+
+func generalWellnessPlan(ctx buildPlanContext) (Plan, []Alternative) {
 	return Plan{
 			Medication: "Preventive care focus",
 			Dosage:     "N/A",
 			Frequency:  "Per guideline schedule",
 			Duration:   "Ongoing",
-			Rationale:  "No specific complaint provided. Recommend preventive screening, lifestyle optimization, and targeted labs based on history.",
+			Rationale:  ctx.Localizer.text("rationale.general", nil, ""),
+			Monitoring: []string{"Age-appropriate screening per guideline"},
+			FollowUp:   &FollowUp{IntervalDays: 365, Instructions: "Annual preventive visit"},
 		}, []Alternative{
 			{
 				Medication: "Lifestyle coaching",
@@ -522,15 +1375,24 @@ func generalWellnessPlan() (Plan, []Alternative) {
 		}
 }
 
-func classifyRisk(score int) string {
+// effectiveBMI returns the BMI to score with. A supplied BMI is used unless it
+// disagrees with weight and height by more than plausibleBMIDrift, in which
+// case the computed value wins; either that or a supplied BMI that cannot be
+// checked is reported as an info issue.
+func effectiveBMI(in Intake, l localizer) (float64, *Issue) {
+	computed := computeBMI(in.WeightKg, in.HeightCm)
 	switch {
-	case score >= 8:
-		return "HIGH"
-	case score >= 4:
-		return "MEDIUM"
-	default:
-		return "LOW"
-	}
+	case in.BMI == 0:
+		return computed, nil
+	case computed == 0:
+		is := newIssue("BMI_UNVERIFIABLE", SeverityInfo, l.issue("BMI_UNVERIFIABLE", map[string]any{"Provided": fmt.Sprintf("%.1f", in.BMI)}))
+		return in.BMI, &is
+	case math.Abs(in.BMI-computed) > plausibleBMIDrift:
+		data := map[string]any{"Provided": fmt.Sprintf("%.1f", in.BMI), "Computed": fmt.Sprintf("%.1f", computed)}
+		is := newIssue("BMI_INCONSISTENT", SeverityInfo, l.issue("BMI_INCONSISTENT", data))
+		return computed, &is
+	}
+	return in.BMI, nil
 }
 
 func computeBMI(weightKg, heightCm float64) float64 {
@@ -541,68 +1403,82 @@ func computeBMI(weightKg, heightCm float64) float64 {
 	return weightKg / (m * m)
 }
 
-var bpPattern = regexp.MustCompile(`(?i)(\d{2,3})\s*/\s*(\d{2,3})`)
-
-func parseBP(bp string) (int, int) {
-	m := bpPattern.FindStringSubmatch(bp)
+// bpPattern matches a systolic/diastolic reading as clinicians type it:
+// "120/80", "120 / 80", "120 over 80", optionally labelled "BP" and followed
+// by "mmHg".
+var bpPattern = regexp.MustCompile(`(?i)^(?:bp:?\s*)?(\d{2,3})\s*(?:/|over)\s*(\d{2,3})(?:\s*mm\s*hg)?$`)
+
+// parseBP reads a blood pressure reading. A string that is not a reading
+// returns 0, 0 and an invalid_format problem; a reading outside the
+// plausibility bounds or with diastolic not below systolic returns the
+// parsed values and an out_of_range problem.
+func parseBP(bp string) (int, int, *FieldError) {
+	m := bpPattern.FindStringSubmatch(strings.Join(strings.Fields(bp), " "))
 	if len(m) != 3 {
-		return 0, 0
+		return 0, 0, &FieldError{Field: "bp", Code: "invalid_format", Message: fmt.Sprintf("bp %q is not in systolic/diastolic form, e.g. 120/80", bp)}
 	}
 	s, _ := strconv.Atoi(m[1])
 	d, _ := strconv.Atoi(m[2])
-	return s, d
-}
-
-func toSet(values []string) map[string]bool {
-	out := make(map[string]bool, len(values))
-	for _, v := range values {
-		key := strings.ToLower(strings.TrimSpace(v))
-		if key != "" {
-			out[key] = true
-		}
+	outOfRange := func(format string, args ...any) (int, int, *FieldError) {
+		return s, d, &FieldError{Field: "bp", Code: "out_of_range", Message: fmt.Sprintf(format, args...)}
 	}
-	return out
+	switch {
+	case s < plausibleMinSystolic || s > plausibleMaxSystolic:
+		return outOfRange("bp systolic %d is outside %d-%d", s, plausibleMinSystolic, plausibleMaxSystolic)
+	case d < plausibleMinDiastolic || d > plausibleMaxDiastolic:
+		return outOfRange("bp diastolic %d is outside %d-%d", d, plausibleMinDiastolic, plausibleMaxDiastolic)
+	case d >= s:
+		return outOfRange("bp diastolic %d is not below systolic %d", d, s)
+	}
+	return s, d, nil
 }
 
+// normalizeMeds returns the lower-cased medication names the rules match
+// on, with combination products expanded into their components.
 func normalizeMeds(meds []Medication) map[string]bool {
 	out := make(map[string]bool, len(meds))
 	for _, m := range meds {
-		name := strings.ToLower(strings.TrimSpace(m.Name))
-		if name != "" {
-			out[name] = true
+		for _, c := range medicationComponents(m) {
+			out[c.Name] = true
 		}
 	}
 	return out
 }
 
 func usesPDE5(name string) bool {
-	n := strings.ToLower(name)
-	return strings.Contains(n, "tadalafil") || strings.Contains(n, "sildenafil") || strings.Contains(n, "vardenafil")
+	return classPDE5.has(name)
 }
 
-func containsAnyMedication(meds map[string]bool, needles []string) bool {
+// matchingMedications returns the normalized medication names containing any needle, sorted for stable output.
+func matchingMedications(meds map[string]bool, needles []string) []string {
+	var out []string
 	for med := range meds {
 		for _, n := range needles {
 			if strings.Contains(med, n) {
-				return true
+				out = append(out, med)
+				break
 			}
 		}
 	}
-	return false
+	sort.Strings(out)
+	return out
 }
 
-func exceedsDose(medication, dose string) bool {
-	// Simple guard: flag PDE5 doses >20mg.
-	if !usesPDE5(medication) {
-		return false
+// exceedsDose reports whether dose is above the first cap matching medication.
+func exceedsDose(caps []DoseCap, medication, dose string) bool {
+	name := strings.ToLower(medication)
+	for _, c := range caps {
+		if strings.Contains(name, c.Medication) {
+			return extractMg(dose) > c.MaxMg
+		}
 	}
-	num := extractMg(dose)
-	return num > 20
+	return false
 }
 
+var mgPattern = regexp.MustCompile(`(?i)([\d.]+)\s*mg`)
+
 func extractMg(dose string) float64 {
-	re := regexp.MustCompile(`([\d.]+)\s*mg`)
-	m := re.FindStringSubmatch(strings.ToLower(dose))
+	m := mgPattern.FindStringSubmatch(dose)
 	if len(m) < 2 {
 		return 0
 	}
@@ -610,49 +1486,132 @@ func extractMg(dose string) float64 {
 	return val
 }
 
-func intersectsAllergy(allergies []string, medication string) string {
+// allergySeverities are the accepted Allergy.Severity values, most severe
+// first.
+var allergySeverities = []string{"anaphylaxis", "severe", "moderate", "mild", "intolerance"}
+
+// intakeAllergies merges the detailed and plain allergy lists, detailed first
+// so a substance listed in both is matched with its severity. Prior
+// treatments the patient did not tolerate count as intolerance-severity
+// allergies, so a plan that still names one is flagged.
+func intakeAllergies(in Intake) []Allergy {
+	out := make([]Allergy, 0, len(in.AllergyDetails)+len(in.Allergies))
+	for _, a := range in.AllergyDetails {
+		a.Substance = strings.TrimSpace(a.Substance)
+		a.Severity = strings.ToLower(strings.TrimSpace(a.Severity))
+		out = append(out, a)
+	}
+	for _, a := range in.Allergies {
+		out = append(out, Allergy{Substance: strings.TrimSpace(a)})
+	}
+	for _, p := range in.PriorTreatments {
+		if normalizeName(p.Outcome) == "intolerant" {
+			out = append(out, Allergy{Substance: strings.TrimSpace(p.Medication), Severity: "intolerance"})
+		}
+	}
+	return out
+}
+
+func intersectsAllergy(allergies []Allergy, medication string) (Allergy, bool) {
 	med := strings.ToLower(medication)
 	for _, a := range allergies {
-		if strings.Contains(med, strings.ToLower(strings.TrimSpace(a))) && strings.TrimSpace(a) != "" {
-			return strings.TrimSpace(a)
+		if a.Substance != "" && strings.Contains(med, strings.ToLower(a.Substance)) {
+			return a, true
 		}
 	}
-	return ""
+	return Allergy{}, false
 }
 
 // Validate performs basic intake validation before deeper analysis.
+func (a *Analyzer) Validate(in Intake) []string {
+	errs, _ := a.settings().validate(in)
+	return errs
+}
+
 func Validate(in Intake) []string {
-	var errs []string
+	return defaultAnalyzer.Validate(in)
+}
+
+// intakeErrors checks the fields every analysis needs and the format of
+// the optional ones.
+func intakeErrors(in Intake) []FieldError {
+	var errs []FieldError
 	if strings.TrimSpace(in.PatientName) == "" {
-		errs = append(errs, "patientName is required")
+		errs = append(errs, FieldError{Field: "patientName", Code: "required", Message: "patientName is required"})
 	}
 	if in.Age <= 0 {
-		errs = append(errs, "age must be greater than 0")
+		errs = append(errs, FieldError{Field: "age", Code: "not_positive", Message: "age must be greater than 0"})
+	}
+	// Whether weight, height, and bp must be present depends on the
+	// complaint; see requirementErrors.
+	if in.WeightKg < 0 {
+		errs = append(errs, FieldError{Field: "weight", Code: "not_positive", Message: "weight must be greater than 0"})
 	}
-	if in.WeightKg <= 0 {
-		errs = append(errs, "weight must be greater than 0")
+	if in.HeightCm < 0 {
+		errs = append(errs, FieldError{Field: "height", Code: "not_positive", Message: "height must be greater than 0"})
 	}
-	if in.HeightCm <= 0 {
-		errs = append(errs, "height must be greater than 0")
+	if strings.TrimSpace(in.BP) != "" {
+		if _, _, problem := parseBP(in.BP); problem != nil {
+			errs = append(errs, *problem)
+		}
+	}
+	if len(intakeComplaints(in)) == 0 {
+		errs = append(errs, FieldError{Field: "complaint", Code: "required", Message: "complaint is required"})
+	}
+	for i, code := range in.ConditionCodes {
+		if _, ok := normalizeICD10(code); !ok {
+			field := fmt.Sprintf("conditionCodes[%d]", i)
+			errs = append(errs, FieldError{Field: field, Code: "invalid_format", Message: fmt.Sprintf("%s %q is not an ICD-10 code, e.g. I25.10", field, code)})
+		}
 	}
-	if strings.TrimSpace(in.BP) == "" {
-		errs = append(errs, "bp is required")
+	if sev := normalizeName(in.ComplaintSeverity); sev != "" && !slices.Contains(complaintSeverities, sev) {
+		errs = append(errs, FieldError{Field: "complaintSeverity", Code: "invalid_format", Message: fmt.Sprintf("complaintSeverity must be one of %s, not %q", strings.Join(complaintSeverities, ", "), in.ComplaintSeverity)})
 	}
-	if strings.TrimSpace(in.Complaint) == "" {
-		errs = append(errs, "complaint is required")
+	if w := in.ComplaintDurationWeeks; w < 0 || w > maxComplaintDurationWeeks {
+		errs = append(errs, FieldError{Field: "complaintDurationWeeks", Code: "out_of_range", Message: fmt.Sprintf("complaintDurationWeeks must be between 1 and %d weeks, not %d", maxComplaintDurationWeeks, w)})
+	}
+	errs = append(errs, smokingErrors(in)...)
+	errs = append(errs, alcoholErrors(in)...)
+	errs = append(errs, lifestyleErrors(in)...)
+	errs = append(errs, metabolicErrors(in)...)
+	for i, p := range in.PriorTreatments {
+		field := fmt.Sprintf("priorTreatments[%d]", i)
+		if strings.TrimSpace(p.Medication) == "" {
+			errs = append(errs, FieldError{Field: field + ".medication", Code: "required", Message: field + ".medication is required"})
+		}
+		if !slices.Contains(priorOutcomes, normalizeName(p.Outcome)) {
+			errs = append(errs, FieldError{Field: field + ".outcome", Code: "invalid_format", Message: fmt.Sprintf("%s.outcome must be one of %s, not %q", field, strings.Join(priorOutcomes, ", "), p.Outcome)})
+		}
+	}
+	for i, a := range in.AllergyDetails {
+		field := fmt.Sprintf("allergyDetails[%d]", i)
+		if strings.TrimSpace(a.Substance) == "" {
+			errs = append(errs, FieldError{Field: field + ".substance", Code: "required", Message: field + ".substance is required"})
+		}
+		if sev := strings.ToLower(strings.TrimSpace(a.Severity)); sev != "" && !slices.Contains(allergySeverities, sev) {
+			errs = append(errs, FieldError{Field: field + ".severity", Code: "invalid_format", Message: fmt.Sprintf("%s.severity must be one of %s, not %q", field, strings.Join(allergySeverities, ", "), a.Severity)})
+		}
 	}
 	return errs
 }
 
+// compiledResponseSchema parses the embedded schema once; compiling it per
+// response dominated Analyze's allocations.
+var compiledResponseSchema = sync.OnceValues(func() (*gojsonschema.Schema, error) {
+	return gojsonschema.NewSchema(gojsonschema.NewBytesLoader(responseSchema))
+})
+
 // ValidateResponse ensures responses conform to schema before returning.
 func ValidateResponse(resp Response) []string {
 	body, err := json.Marshal(resp)
 	if err != nil {
 		return []string{"failed to marshal response"}
 	}
-	schemaLoader := gojsonschema.NewBytesLoader(responseSchema)
-	docLoader := gojsonschema.NewBytesLoader(body)
-	result, err := gojsonschema.Validate(schemaLoader, docLoader)
+	schema, err := compiledResponseSchema()
+	if err != nil {
+		return []string{"schema validation error: " + err.Error()}
+	}
+	result, err := schema.Validate(gojsonschema.NewBytesLoader(body))
 	if err != nil {
 		return []string{"schema validation error: " + err.Error()}
 	}
@@ -666,57 +1625,154 @@ func ValidateResponse(resp Response) []string {
 	return out
 }
 
-func recordAudit(in Intake, risk string, score int) (string, string, error) {
-	ref := patientRef(in.PatientName)
-	sum, err := auditStore.Insert(audit.Entry{
-		PatientRef: ref,
-		Complaint:  in.Complaint,
-		RiskLevel:  risk,
-		RiskScore:  score,
-		UserID:     in.UserID,
-	})
+// auditEntry builds the audit entry for resp. The entry's duration is the
+// time timer has seen so far, which excludes the write itself and the
+// response schema check that follows it.
+func (a *Analyzer) auditEntry(s settings, in Intake, ref string, resp Response, usage audit.LLMUsage, timer *stageTimer) (audit.Entry, error) {
+	took := timer.elapsed()
+	id := a.ids.NewID()
+	at := a.now().UTC()
+	// Persist the response as the caller will see it, audit fields included.
+	recorded := true
+	resp.AuditID, resp.AuditAt, resp.AuditRecorded = id, at.Format(time.RFC3339), &recorded
+	body, err := json.Marshal(resp)
 	if err != nil {
-		return "", "", err
+		return audit.Entry{}, err
 	}
-	return sum.AuditID, sum.At, nil
+	sig, err := auditSignature(s, body)
+	if err != nil {
+		return audit.Entry{}, err
+	}
+	var intake json.RawMessage
+	if s.storeIntakes {
+		// Redact before marshalling so the name is never serialized.
+		if intake, err = json.Marshal(newStoredIntake(in, ref)); err != nil {
+			return audit.Entry{}, err
+		}
+	}
+	return audit.Entry{
+		ID:             id,
+		At:             at,
+		PatientRef:     ref,
+		Complaint:      strings.Join(intakeComplaints(in), ", "),
+		RiskLevel:      string(resp.RiskLevel),
+		RiskScore:      resp.RiskScore,
+		UserID:         in.UserID,
+		LLM:            usage,
+		PromptVersion:  resp.PromptVersion,
+		RulesetVersion: resp.RulesetVersion,
+		Response:       body,
+		Intake:         intake,
+		Consent:        auditConsent(in.Consent),
+		Duration:       took,
+		LLMDuration:    timer.stages[stageLLMScoring],
+		Signature:      sig,
+	}, nil
 }
 
-func patientRef(name string) string {
-	ref := strings.TrimSpace(name)
-	if len(ref) > 2 {
-		return ref[:1] + "***"
+// insertAudit writes one audit entry.
+func insertAudit(ctx context.Context, s settings, entry audit.Entry) (audit.Summary, error) {
+	ctx, span := trace.Start(ctx, "audit.insert")
+	defer span.End()
+	sum, err := s.store.Insert(ctx, entry)
+	if err != nil {
+		span.SetError("audit insert failed")
 	}
-	return ref
+	return sum, err
+}
+
+func LatestAudits(limit int) []AuditSummary {
+	return defaultAnalyzer.LatestAudits(limit)
 }
 
-type AuditSummary struct {
-	AuditID    string `json:"auditId"`
-	PatientRef string `json:"patientRef"`
-	Complaint  string `json:"complaint"`
-	RiskLevel  string `json:"riskLevel"`
-	RiskScore  int    `json:"riskScore"`
-	At         string `json:"at"`
+// LatestAudits returns up to limit recent audit summaries, newest last.
+func (a *Analyzer) LatestAudits(limit int) []AuditSummary {
+	return a.LatestAuditsContext(context.Background(), limit)
 }
 
-func LatestAudits(limit int) []AuditSummary {
-	summaries, err := auditStore.Latest(limit)
+func LatestAuditsContext(ctx context.Context, limit int) []AuditSummary {
+	return defaultAnalyzer.LatestAuditsContext(ctx, limit)
+}
+
+// LatestAuditsContext is LatestAudits bounded by ctx; a failed or cancelled
+// read returns an empty list.
+func (a *Analyzer) LatestAuditsContext(ctx context.Context, limit int) []AuditSummary {
+	summaries, err := a.settings().store.Latest(ctx, limit)
 	if err != nil {
 		return []AuditSummary{}
 	}
 	out := make([]AuditSummary, 0, len(summaries))
 	for _, a := range summaries {
-		out = append(out, AuditSummary{
-			AuditID:    a.AuditID,
-			PatientRef: a.PatientRef,
-			Complaint:  a.Complaint,
-			RiskLevel:  a.RiskLevel,
-			RiskScore:  a.RiskScore,
-			At:         a.At,
-		})
+		out = append(out, auditSummary(a))
 	}
 	return out
 }
 
+func auditSummary(a audit.Summary) AuditSummary {
+	sum := AuditSummary{
+		AuditID:        a.AuditID,
+		PatientRef:     a.PatientRef,
+		Complaint:      a.Complaint,
+		RiskLevel:      RiskLevel(a.RiskLevel),
+		RiskScore:      a.RiskScore,
+		At:             a.At,
+		PromptVersion:  a.PromptVersion,
+		RulesetVersion: a.RulesetVersion,
+		DurationMs:     a.DurationMs,
+		LLMDurationMs:  a.LLMDurationMs,
+		DeletedAt:      a.DeletedAt,
+	}
+	if g := a.Signature; g != nil {
+		sum.Signature = &Signature{KeyID: g.KeyID, Algorithm: g.Algorithm, Value: g.Value}
+	}
+	if c := a.Consent; c != nil {
+		sum.Consent = &Consent{Given: c.Given, Timestamp: c.Timestamp, Method: c.Method}
+	}
+	if a.Decision != nil {
+		d := decisionOf(*a.Decision)
+		sum.Decision = &d
+	}
+	return sum
+}
+
+// ErrResponsesUnsupported is returned when the audit store does not keep responses.
+var ErrResponsesUnsupported = errors.New("audit store does not keep responses")
+
+func AuditResponse(ctx context.Context, id string) (Response, error) {
+	return defaultAnalyzer.AuditResponse(ctx, id)
+}
+
+// AuditResponse returns the response stored with audit id, with any clinician
+// decisions attached, or audit.ErrNotFound.
+func (a *Analyzer) AuditResponse(ctx context.Context, id string) (Response, error) {
+	reader, ok := a.settings().store.(audit.ResponseReader)
+	if !ok {
+		return Response{}, ErrResponsesUnsupported
+	}
+	raw, err := reader.Response(ctx, id)
+	if err != nil {
+		return Response{}, err
+	}
+	if len(raw) == 0 {
+		// Rows written before responses were stored.
+		return Response{}, audit.ErrNotFound
+	}
+	var resp Response
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return Response{}, fmt.Errorf("decode audit %s response: %w", id, err)
+	}
+	if store, ok := reader.(audit.DecisionStore); ok {
+		decisions, err := store.Decisions(ctx, id)
+		if err != nil {
+			return Response{}, err
+		}
+		for _, d := range decisions {
+			resp.Decisions = append(resp.Decisions, decisionOf(d))
+		}
+	}
+	return resp, nil
+}
+
 func clamp(val, min, max float64) float64 {
 	if val < min {
 		return min
@@ -727,48 +1783,81 @@ func clamp(val, min, max float64) float64 {
 	return val
 }
 
-type interactionRule struct {
-	Drug      string
-	With      string
-	Severity  string
-	Desc      string
-	RiskDelta int
+// InteractionRule flags an issue when both Drug and With appear in the
+// medication list. Code should be registered in the issue catalog.
+type InteractionRule struct {
+	Code      string   `json:"code"`
+	Drug      string   `json:"drug"`
+	With      string   `json:"with"`
+	Severity  Severity `json:"severity"`
+	Desc      string   `json:"description"`
+	RiskDelta int      `json:"riskDelta"`
+}
+
+func (r InteractionRule) matches(meds map[string]bool) bool {
+	return meds[r.Drug] && meds[r.With]
+}
+
+// RulesSource supplies the interaction rules for each analysis. Implementations
+// must be safe for concurrent use.
+type RulesSource interface {
+	InteractionRules() []InteractionRule
+}
+
+// StaticRules is a fixed RulesSource.
+type StaticRules []InteractionRule
+
+func (r StaticRules) InteractionRules() []InteractionRule {
+	return r
 }
 
-var interactionRules = []interactionRule{
+// DefaultRules returns a copy of the built-in interaction ruleset.
+func DefaultRules() StaticRules {
+	return append(StaticRules(nil), interactionRules...)
+}
+
+var interactionRules = []InteractionRule{
 	{
+		Code:      "DDI_AMLODIPINE_SIMVASTATIN",
 		Drug:      "amlodipine",
 		With:      "simvastatin",
-		Severity:  "warning",
+		Severity:  SeverityWarning,
 		Desc:      "Amlodipine can raise simvastatin levels; consider limiting simvastatin to 20mg/day.",
 		RiskDelta: 1,
 	},
 	{
+		Code:      "DDI_METFORMIN_CONTRAST",
 		Drug:      "metformin",
 		With:      "contrast",
-		Severity:  "info",
+		Severity:  SeverityInfo,
 		Desc:      "Hold metformin around iodinated contrast if eGFR is low to reduce lactic acidosis risk.",
 		RiskDelta: 0,
 	},
 	{
+		Code:      "CI_FINASTERIDE_PREGNANCY",
 		Drug:      "finasteride",
 		With:      "pregnancy",
-		Severity:  "warning",
+		Severity:  SeverityWarning,
 		Desc:      "Finasteride is teratogenic; avoid handling in pregnancy.",
 		RiskDelta: 1,
 	},
 }
 
-func interactionIssues(meds map[string]bool) []Issue {
+// interactionIssues describes matches with the rule's own text in English and
+// with the catalog's issue.<Code> message in other locales.
+func interactionIssues(meds map[string]bool, rules []InteractionRule, l localizer) []Issue {
 	var out []Issue
-	for _, rule := range interactionRules {
-		if meds[rule.Drug] && meds[rule.With] {
-			out = append(out, Issue{
-				Type:        "drug_interaction",
-				Severity:    rule.Severity,
-				Description: rule.Desc,
-			})
+	for _, rule := range rules {
+		if rule.matches(meds) {
+			out = append(out, newIssue(rule.Code, rule.Severity, ruleText(rule, l), rule.Drug, rule.With))
 		}
 	}
 	return out
 }
+
+func ruleText(rule InteractionRule, l localizer) string {
+	if l.locale != "" && l.locale != DefaultLocale {
+		return l.text("issue."+rule.Code, nil, rule.Desc)
+	}
+	return rule.Desc
+}
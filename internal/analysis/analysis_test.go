@@ -1,6 +1,9 @@
 package analysis
 
 import (
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
@@ -72,6 +75,61 @@ func TestAnalyze_NitrateContraindication(t *testing.T) {
 	}
 }
 
+func nitratePatient(nitrate Medication) Intake {
+	return Intake{
+		PatientName: "Nitrate", Age: 60, WeightKg: 82, HeightCm: 176, BP: "128/80", Complaint: "ED",
+		Conditions: []string{"Heart Disease"}, Medications: []Medication{nitrate},
+	}
+}
+
+func TestAnalyze_PRNNitrate(t *testing.T) {
+	for _, m := range []Medication{
+		{Name: "Nitroglycerin", Dosage: "0.4mg SL", Frequency: "as needed for chest pain"},
+		{Name: "Nitroglycerin 0.4mg SL p.r.n."},
+	} {
+		resp := Analyze(nitratePatient(m))
+		i := slices.IndexFunc(resp.FlaggedIssues, func(i Issue) bool { return i.Code == "CI_NITRATE_PRN_PDE5" })
+		if i < 0 || resp.FlaggedIssues[i].Severity != SeverityDanger || !strings.Contains(resp.FlaggedIssues[i].Description, "within 24 hours of the last nitrate") {
+			t.Fatalf("%+v: issues %+v", m, resp.FlaggedIssues)
+		}
+		if slices.Contains(issueCodes(resp.FlaggedIssues), "CI_NITRATE_PDE5") {
+			t.Errorf("%+v: scheduled nitrate issue too", m)
+		}
+		if !slices.ContainsFunc(resp.RiskFactors, func(f RiskFactor) bool { return f.Code == "nitrate_therapy" && f.Points == 5 }) {
+			t.Errorf("%+v: risk factors %+v", m, resp.RiskFactors)
+		}
+		p := resp.RecommendedPlan
+		if usesPDE5(p.Medication) || !strings.Contains(p.Rationale, "taken only as needed") || !strings.Contains(p.FollowUp.Instructions, "can be stopped") {
+			t.Errorf("%+v: plan %+v", m, p)
+		}
+	}
+}
+
+func TestAnalyze_ScheduledNitrate(t *testing.T) {
+	resp := Analyze(nitratePatient(Medication{Name: "Isosorbide mononitrate", Dosage: "30mg", Frequency: "Daily"}))
+	i := slices.IndexFunc(resp.FlaggedIssues, func(i Issue) bool { return i.Code == "CI_NITRATE_PDE5" })
+	if i < 0 || resp.FlaggedIssues[i].Severity != SeverityDanger || !strings.Contains(resp.FlaggedIssues[i].Description, "do not combine") {
+		t.Fatalf("issues %+v", resp.FlaggedIssues)
+	}
+	if slices.Contains(issueCodes(resp.FlaggedIssues), "CI_NITRATE_PRN_PDE5") {
+		t.Errorf("PRN nitrate issue for a scheduled nitrate")
+	}
+	if !slices.ContainsFunc(resp.RiskFactors, func(f RiskFactor) bool { return f.Code == "nitrate_therapy" && f.Points == 5 }) {
+		t.Errorf("risk factors %+v", resp.RiskFactors)
+	}
+	if p := resp.RecommendedPlan; strings.Contains(p.Rationale, "taken only as needed") || strings.Contains(p.FollowUp.Instructions, "as-needed") {
+		t.Errorf("plan %+v", p)
+	}
+
+	// A scheduled nitrate alongside an as-needed one keeps the firm plan.
+	in := nitratePatient(Medication{Name: "Isosorbide mononitrate", Frequency: "Daily"})
+	in.Medications = append(in.Medications, Medication{Name: "Nitroglycerin", Frequency: "PRN"})
+	resp = Analyze(in)
+	if codes := issueCodes(resp.FlaggedIssues); !slices.Contains(codes, "CI_NITRATE_PDE5") || !slices.Contains(codes, "CI_NITRATE_PRN_PDE5") || strings.Contains(resp.RecommendedPlan.Rationale, "taken only as needed") {
+		t.Errorf("mixed nitrates: issues %v, plan %+v", codes, resp.RecommendedPlan)
+	}
+}
+
 func TestAnalyze_WeightLossRiskStratification(t *testing.T) {
 	input := Intake{
 		PatientName: "Weight Loss",
@@ -142,6 +200,322 @@ func TestAnalyze_AllergyCrossCheck(t *testing.T) {
 	}
 }
 
+func TestAnalyze_AllergySeverityScalesRisk(t *testing.T) {
+	input := Intake{
+		PatientName: "Allergy",
+		Age:         40,
+		WeightKg:    70,
+		HeightCm:    170,
+		BP:          "120/80",
+		Complaint:   "ED",
+	}
+	points := func(details ...Allergy) (int, string) {
+		in := input
+		in.AllergyDetails = details
+		resp := Analyze(in)
+		if len(resp.ValidationErrors) > 0 {
+			t.Fatalf("unexpected validation errors: %v", resp.ValidationErrors)
+		}
+		for _, f := range resp.RiskFactors {
+			if strings.HasPrefix(f.Code, "allergy_plan") {
+				return f.Points, f.Code
+			}
+		}
+		return 0, ""
+	}
+
+	anaphylaxis, code := points(Allergy{Substance: "Tadalafil", Severity: "Anaphylaxis"})
+	if code != "allergy_plan_anaphylaxis" {
+		t.Fatalf("factor code = %q", code)
+	}
+	intolerance, _ := points(Allergy{Substance: "tadalafil", Severity: "intolerance"})
+	unspecified, _ := points(Allergy{Substance: "tadalafil"})
+	if !(anaphylaxis > unspecified && unspecified > intolerance && intolerance > 0) {
+		t.Fatalf("allergy points anaphylaxis=%d unspecified=%d intolerance=%d", anaphylaxis, unspecified, intolerance)
+	}
+
+	input.AllergyDetails = []Allergy{{Substance: " ", Severity: "deadly"}}
+	errs := strings.Join(Validate(input), "\n")
+	if !strings.Contains(errs, "allergyDetails[0].substance") || !strings.Contains(errs, "allergyDetails[0].severity") {
+		t.Fatalf("validation errors = %s", errs)
+	}
+}
+
+func TestAnalyze_PlanMonitoringAndFollowUp(t *testing.T) {
+	tests := []struct {
+		complaint string
+		monitor   string
+		days      int
+	}{
+		{"ED", "Blood pressure check at 2-4 weeks", 28},
+		{"Hair Loss", "Sexual side effects", 90},
+		{"Weight Loss", "Vitamin B12 annually", 84},
+		{"General", "screening", 365},
+	}
+	for _, tt := range tests {
+		resp := Analyze(Intake{
+			PatientName: "Follow Up",
+			Age:         40,
+			WeightKg:    80,
+			HeightCm:    175,
+			BP:          "120/80",
+			Complaint:   tt.complaint,
+		})
+		plan := resp.RecommendedPlan
+		if !slices.ContainsFunc(plan.Monitoring, func(m string) bool { return strings.Contains(m, tt.monitor) }) {
+			t.Errorf("%s: monitoring = %v, want %q", tt.complaint, plan.Monitoring, tt.monitor)
+		}
+		if plan.FollowUp == nil || plan.FollowUp.IntervalDays != tt.days || plan.FollowUp.Instructions == "" {
+			t.Errorf("%s: followUp = %+v, want %d days", tt.complaint, plan.FollowUp, tt.days)
+		}
+		if errs := ValidateResponse(resp); len(errs) > 0 {
+			t.Errorf("%s: schema errors: %v", tt.complaint, errs)
+		}
+	}
+}
+
+func TestAnalyze_MultipleComplaints(t *testing.T) {
+	a := New()
+	r := a.Rules().Ruleset
+	r.Interactions = append(r.Interactions, InteractionRule{Code: "DDI_FINASTERIDE_TADALAFIL", Drug: "finasteride", With: "tadalafil", Severity: "info", Desc: "Combined regimen.", RiskDelta: 2})
+	if err := a.SetRuleset(r, "test"); err != nil {
+		t.Fatal(err)
+	}
+	in := Intake{
+		PatientName: "Two Complaints",
+		Age:         50,
+		WeightKg:    80,
+		HeightCm:    175,
+		BP:          "125/80",
+		Medications: []Medication{{Name: "Amlodipine", Dosage: "5mg"}},
+		Complaints:  []string{"Hair Loss", "ED", "ed", "back pain"},
+	}
+	single := in
+	single.Complaints = nil
+	single.Complaint = "ED"
+	edOnly := a.Analyze(single)
+
+	resp := a.Analyze(in)
+	if len(resp.ValidationErrors) > 0 {
+		t.Fatalf("unexpected validation errors: %v", resp.ValidationErrors)
+	}
+	if len(resp.Plans) != 2 || resp.Plans[0].Complaint != "ED" || resp.Plans[1].Plan.Medication != "Finasteride" {
+		t.Fatalf("plans = %+v", resp.Plans)
+	}
+	if resp.RecommendedPlan.Medication != "Tadalafil" || !reflect.DeepEqual(resp.Plans[0].Plan, resp.RecommendedPlan) {
+		t.Fatalf("recommendedPlan = %+v, want the ED plan", resp.RecommendedPlan)
+	}
+	if !slices.Contains(issueCodes(resp.FlaggedIssues), "DDI_FINASTERIDE_TADALAFIL") {
+		t.Fatalf("cross-plan interaction not flagged: %v", issueCodes(resp.FlaggedIssues))
+	}
+	seen := map[string]bool{}
+	for _, f := range resp.RiskFactors {
+		if seen[f.Code] {
+			t.Fatalf("factor %s counted twice: %+v", f.Code, resp.RiskFactors)
+		}
+		seen[f.Code] = true
+	}
+	if resp.RiskScore != edOnly.RiskScore+2 {
+		t.Fatalf("score %d, want the ED score %d plus the cross-plan delta", resp.RiskScore, edOnly.RiskScore)
+	}
+	var urls []string
+	for _, e := range resp.Education {
+		urls = append(urls, e.URL)
+	}
+	if !slices.Contains(urls, "https://medlineplus.gov/erectiledysfunction.html") || !slices.Contains(urls, "https://medlineplus.gov/hairloss.html") {
+		t.Fatalf("education = %v", urls)
+	}
+
+	// A current PDE5 inhibitor is reviewed rather than duplicated.
+	single.Medications = []Medication{{Name: "Sildenafil", Dosage: "50mg"}}
+	resp = a.Analyze(single)
+	if p := resp.Plans[0].Plan; !p.Review || p.Medication != "Sildenafil" {
+		t.Fatalf("ED plan with a current PDE5 inhibitor = %+v, want a review", p)
+	}
+	if codes := issueCodes(resp.FlaggedIssues); slices.Contains(codes, "DUP_THERAPY") {
+		t.Fatalf("review plan flagged as duplicate therapy: %v", codes)
+	}
+}
+
+func TestAnalyze_ComplaintSeverityAndDuration(t *testing.T) {
+	base := Intake{PatientName: "Severity", Age: 45, WeightKg: 85, HeightCm: 175, BP: "124/80", Complaint: "ED"}
+	with := func(complaint, severity string, weeks int) Response {
+		in := base
+		in.Complaint, in.ComplaintSeverity, in.ComplaintDurationWeeks = complaint, severity, weeks
+		resp := Analyze(in)
+		if len(resp.ValidationErrors) > 0 {
+			t.Fatalf("unexpected validation errors: %v", resp.ValidationErrors)
+		}
+		return resp
+	}
+
+	if r := with("ED", "Severe", 0); !strings.Contains(r.RecommendedPlan.Rationale, "daily tadalafil") || r.ComplaintSeverity != "severe" {
+		t.Fatalf("severe ED rationale = %q (severity %q)", r.RecommendedPlan.Rationale, r.ComplaintSeverity)
+	}
+	if r := with("ED", "", 156); !strings.Contains(r.RecommendedPlan.Rationale, "refer to urology") || r.ComplaintDurationWeeks != 156 {
+		t.Fatalf("long-standing ED rationale = %q", r.RecommendedPlan.Rationale)
+	}
+	if r := with("ED", "mild", 8); strings.Contains(r.RecommendedPlan.Rationale, "daily tadalafil") {
+		t.Fatalf("mild ED rationale = %q", r.RecommendedPlan.Rationale)
+	}
+	if r := with("Weight Loss", "mild", 0); r.RecommendedPlan.Medication != "Intensive lifestyle program" {
+		t.Fatalf("mild weight loss plan = %q", r.RecommendedPlan.Medication)
+	}
+	if r := with("Weight Loss", "", 4); r.RecommendedPlan.Medication != "Intensive lifestyle program" {
+		t.Fatalf("recent weight loss plan = %q", r.RecommendedPlan.Medication)
+	}
+	if r := with("Weight Loss", "severe", 4); r.RecommendedPlan.Medication != "Metformin" {
+		t.Fatalf("severe weight loss plan = %q", r.RecommendedPlan.Medication)
+	}
+	if codes := issueCodes(with("Hair Loss", "", 1).FlaggedIssues); !slices.Contains(codes, "COMPLAINT_WATCHFUL_WAITING") {
+		t.Fatalf("one-week hair loss issues = %v", codes)
+	}
+	if codes := issueCodes(with("Weight Loss", "", 1).FlaggedIssues); slices.Contains(codes, "COMPLAINT_WATCHFUL_WAITING") {
+		t.Fatalf("watchful waiting suggested for weight loss: %v", codes)
+	}
+
+	in := base
+	in.ComplaintSeverity, in.ComplaintDurationWeeks = "extreme", 5000
+	errs := strings.Join(Validate(in), "\n")
+	if !strings.Contains(errs, "complaintSeverity") || !strings.Contains(errs, "complaintDurationWeeks") {
+		t.Fatalf("validation errors = %s", errs)
+	}
+}
+
+func TestAnalyze_PriorTreatments(t *testing.T) {
+	base := Intake{PatientName: "Prior", Age: 50, WeightKg: 95, HeightCm: 175, BP: "124/80", Complaint: "ED"}
+	with := func(complaint string, prior ...PriorTreatment) Response {
+		in := base
+		in.Complaint, in.PriorTreatments = complaint, prior
+		resp := Analyze(in)
+		if len(resp.ValidationErrors) > 0 {
+			t.Fatalf("unexpected validation errors: %v", resp.ValidationErrors)
+		}
+		return resp
+	}
+
+	r := with("ED", PriorTreatment{Medication: "Sildenafil", MaxDose: "100mg", Outcome: "ineffective"})
+	if r.RecommendedPlan.Medication != "Tadalafil" || !strings.Contains(r.RecommendedPlan.Rationale, "sildenafil 100mg was ineffective") {
+		t.Fatalf("sildenafil non-responder plan = %+v", r.RecommendedPlan)
+	}
+	for _, alt := range r.Alternatives {
+		if strings.Contains(strings.ToLower(alt.Medication), "sildenafil") {
+			t.Fatalf("ruled-out alternative kept: %+v", r.Alternatives)
+		}
+	}
+	if r := with("ED", PriorTreatment{Medication: "tadalafil", Outcome: "intolerant"}); r.RecommendedPlan.Medication != "Sildenafil" {
+		t.Fatalf("tadalafil intolerant plan = %q", r.RecommendedPlan.Medication)
+	}
+	r = with("ED", PriorTreatment{Medication: "tadalafil", Outcome: "ineffective"}, PriorTreatment{Medication: "sildenafil", Outcome: "ineffective"})
+	if r.RecommendedPlan.Medication != "Urology referral" {
+		t.Fatalf("PDE5 non-responder plan = %q", r.RecommendedPlan.Medication)
+	}
+	if r := with("ED", PriorTreatment{Medication: "sildenafil", Outcome: "effective"}); strings.Contains(r.RecommendedPlan.Rationale, "was ineffective") {
+		t.Fatalf("effective treatment ruled out: %q", r.RecommendedPlan.Rationale)
+	}
+	if r := with("Hair Loss", PriorTreatment{Medication: "Finasteride", Outcome: "intolerant"}); r.RecommendedPlan.Medication != "Topical Minoxidil 5%" {
+		t.Fatalf("finasteride intolerant plan = %q", r.RecommendedPlan.Medication)
+	}
+	if r := with("Weight Loss", PriorTreatment{Medication: "metformin", Outcome: "ineffective"}); r.RecommendedPlan.Medication != "GLP-1 receptor agonist" {
+		t.Fatalf("metformin non-responder plan = %q", r.RecommendedPlan.Medication)
+	}
+
+	in := base
+	in.PriorTreatments = []PriorTreatment{{Medication: "Finasteride", Outcome: "Intolerant"}}
+	if got := intakeAllergies(in); len(got) != 1 || got[0] != (Allergy{Substance: "Finasteride", Severity: "intolerance"}) {
+		t.Fatalf("intolerant treatment allergies = %+v", got)
+	}
+	in.PriorTreatments = []PriorTreatment{{Medication: "", Outcome: "worse"}}
+	errs := strings.Join(Validate(in), "\n")
+	if !strings.Contains(errs, "priorTreatments[0].medication") || !strings.Contains(errs, "priorTreatments[0].outcome") {
+		t.Fatalf("validation errors = %s", errs)
+	}
+}
+
+func TestAnalyze_CurrentPDE5Review(t *testing.T) {
+	base := Intake{PatientName: "Current", Age: 50, WeightKg: 80, HeightCm: 178, BP: "122/78", Complaint: "ED", ConfirmedNoConditions: true}
+	in := base
+	in.Medications = []Medication{{Name: "Sildenafil 100mg PRN"}}
+	resp := Analyze(in)
+	p := resp.RecommendedPlan
+	if !p.Review || p.Medication != "Sildenafil 100mg PRN" || !strings.Contains(p.Rationale, "Already taking Sildenafil 100mg PRN") || strings.Contains(p.Rationale, "Start low") {
+		t.Fatalf("review plan = %+v", p)
+	}
+	if codes := issueCodes(resp.FlaggedIssues); slices.Contains(codes, "DUP_THERAPY") || slices.Contains(codes, "DOSE_CAP_PDE5") {
+		t.Fatalf("review plan issues = %v", codes)
+	}
+	if len(resp.Alternatives) == 0 || resp.Alternatives[0].Medication != "Tadalafil" || strings.Contains(resp.Alternatives[0].Suitability, "PDE5 inhibitor") {
+		t.Fatalf("switch alternative = %+v", resp.Alternatives)
+	}
+
+	in.Medications = []Medication{{Name: "Tadalafil", Dosage: "5mg", Frequency: "Daily"}}
+	resp = Analyze(in)
+	if p := resp.RecommendedPlan; p.Dosage != "5mg" || p.Frequency != "Daily" || resp.Alternatives[0].Medication != "Sildenafil" {
+		t.Fatalf("tadalafil review = %+v, alternatives %+v", p, resp.Alternatives)
+	}
+
+	// A current agent that already failed is switched, not reviewed.
+	in.PriorTreatments = []PriorTreatment{{Medication: "tadalafil", Outcome: "ineffective"}}
+	if p := Analyze(in).RecommendedPlan; p.Review || p.Medication != "Sildenafil" {
+		t.Fatalf("failed current agent plan = %+v", p)
+	}
+}
+
+func TestAnalyze_AlternativeSuitability(t *testing.T) {
+	nitrate := Analyze(Intake{
+		PatientName: "Suitability",
+		Age:         62,
+		WeightKg:    80,
+		HeightCm:    175,
+		BP:          "130/85",
+		Medications: []Medication{{Name: "Isosorbide mononitrate", Dosage: "30mg"}},
+		Complaint:   "ED",
+		Complaints:  []string{"Hair Loss"},
+	})
+	for _, cp := range nitrate.Plans {
+		for _, alt := range cp.Alternatives {
+			if usesPDE5(alt.Medication) {
+				t.Fatalf("nitrate patient offered %s for %s", alt.Medication, cp.Complaint)
+			}
+			if alt.Confidence <= 0 || alt.Suitability == "" {
+				t.Fatalf("alternative not scored: %+v", alt)
+			}
+		}
+	}
+	_, pde5Alts := edPlan(buildPlanContext{})
+	if got := rankAlternatives(pde5Alts, suitabilityChecks{HasNitrate: true}); len(got) != 0 {
+		t.Fatalf("PDE5 alternatives kept with nitrates: %+v", got)
+	}
+	ritonavir := suitabilityChecks{
+		Regimen: map[string]bool{"ritonavir": true},
+		Rules:   []InteractionRule{{Code: "CI_SILDENAFIL_RITONAVIR", Drug: "sildenafil", With: "ritonavir", Severity: "danger"}},
+	}
+	if got := rankAlternatives(pde5Alts, ritonavir); len(got) != 1 || got[0].Medication != "Tadalafil (daily)" {
+		t.Fatalf("alternatives with ritonavir = %+v", got)
+	}
+
+	in := Intake{PatientName: "Suitability", Age: 45, WeightKg: 90, HeightCm: 175, BP: "124/80", Complaint: "Weight Loss", ComplaintSeverity: "mild"}
+	names := func(alts []Alternative) []string {
+		var out []string
+		for _, a := range alts {
+			out = append(out, a.Medication)
+		}
+		return out
+	}
+	healthy := Analyze(in)
+	in.Conditions = []string{"CKD stage 3"}
+	renal := Analyze(in)
+	if want := []string{"Metformin", "GLP-1 receptor agonist"}; !slices.Equal(names(healthy.Alternatives), want) {
+		t.Fatalf("alternatives = %v, want %v", names(healthy.Alternatives), want)
+	}
+	if want := []string{"GLP-1 receptor agonist", "Metformin"}; !slices.Equal(names(renal.Alternatives), want) {
+		t.Fatalf("renal alternatives = %v, want %v", names(renal.Alternatives), want)
+	}
+	if metformin := renal.Alternatives[1]; !strings.Contains(metformin.Suitability, "eGFR") || metformin.Confidence > 0.8 {
+		t.Fatalf("renal metformin = %+v", metformin)
+	}
+}
+
 func TestAnalyze_AuditAndSchema(t *testing.T) {
 	input := Intake{
 		PatientName: "Schema",
@@ -187,6 +561,16 @@ func TestLatestAuditsLimit(t *testing.T) {
 	if len(audits) != 50 {
 		t.Fatalf("expected 50 audits returned, got %d", len(audits))
 	}
+
+	// An unbounded store keeps every audit; listings stay capped.
+	store := audit.NewMemoryStore(audit.WithCapacity(0))
+	a := New(WithAuditStore(store))
+	for range 55 {
+		a.Analyze(input)
+	}
+	if store.Len() != 55 || len(a.LatestAudits(50)) != 50 {
+		t.Fatalf("unbounded store holds %d audits", store.Len())
+	}
 }
 func TestAnalyze_Validation(t *testing.T) {
 	input := Intake{}
@@ -207,3 +591,373 @@ func hasIssue(issues []Issue, issueType string) bool {
 	}
 	return false
 }
+
+func TestAnalyze_RiskFactorsSumToScore(t *testing.T) {
+	inputs := []Intake{
+		{
+			PatientName: "High Risk",
+			Age:         68,
+			WeightKg:    110,
+			HeightCm:    170,
+			BP:          "168/102",
+			Conditions:  []string{"Heart Disease", "Hypertension", "Diabetes", "Kidney Disease"},
+			Medications: []Medication{
+				{Name: "Nitroglycerin", Dosage: "0.4mg", Frequency: "PRN"},
+			},
+			Smoking:   "Current",
+			Alcohol:   "Heavy",
+			Complaint: "ED",
+		},
+		{
+			PatientName: "Alpha Blocker",
+			Age:         58,
+			WeightKg:    82,
+			HeightCm:    178,
+			BP:          "142/90",
+			Allergies:   []string{"tadalafil"},
+			Medications: []Medication{
+				{Name: "Amlodipine", Dosage: "5mg", Frequency: "Daily"},
+				{Name: "Tamsulosin", Dosage: "0.4mg", Frequency: "Daily"},
+			},
+			Complaint: "ED",
+		},
+		{
+			PatientName: "Low Risk",
+			Age:         30,
+			WeightKg:    70,
+			HeightCm:    175,
+			BP:          "118/76",
+			Complaint:   "Hair Loss",
+		},
+	}
+
+	for _, in := range inputs {
+		resp := Analyze(in)
+		if len(resp.ValidationErrors) > 0 {
+			t.Fatalf("%s: unexpected validation errors: %v", in.PatientName, resp.ValidationErrors)
+		}
+		if len(resp.RiskFactors) == 0 {
+			t.Fatalf("%s: expected risk factors to be populated", in.PatientName)
+		}
+		sum := 0
+		for _, f := range resp.RiskFactors {
+			if f.Code == "" {
+				t.Fatalf("%s: risk factor missing code: %+v", in.PatientName, f)
+			}
+			sum += f.Points
+		}
+		if sum != resp.RiskScore {
+			t.Fatalf("%s: risk factors sum to %d, risk score is %d", in.PatientName, sum, resp.RiskScore)
+		}
+	}
+}
+
+func TestAnalyze_RiskScoreNormalized(t *testing.T) {
+	input := Intake{
+		PatientName: "High Risk",
+		Age:         68,
+		WeightKg:    90,
+		HeightCm:    170,
+		BP:          "168/102",
+		Conditions:  []string{"Heart Disease", "Hypertension"},
+		Medications: []Medication{
+			{Name: "Nitroglycerin", Dosage: "0.4mg", Frequency: "PRN"},
+		},
+		Complaint: "ED",
+	}
+
+	resp := Analyze(input)
+	if resp.RiskScoreNormalized <= 0 || resp.RiskScoreNormalized > 100 {
+		t.Fatalf("expected normalized score in (0,100], got %d", resp.RiskScoreNormalized)
+	}
+	want := normalizeRiskScore(resp.RiskScore, MaxRiskScore())
+	if resp.RiskScoreNormalized != want {
+		t.Fatalf("expected normalized score %d, got %d", want, resp.RiskScoreNormalized)
+	}
+	if normalizeRiskScore(MaxRiskScore(), MaxRiskScore()) != 100 {
+		t.Fatalf("max risk score should normalize to 100")
+	}
+	if normalizeRiskScore(MaxRiskScore()+10, MaxRiskScore()) != 100 {
+		t.Fatalf("scores above max should clamp to 100")
+	}
+}
+
+func TestRiskThresholds_CriticalTier(t *testing.T) {
+	t.Cleanup(func() { _ = SetRiskThresholds(DefaultRiskThresholds) })
+
+	if err := SetRiskThresholds(RiskThresholds{Medium: 4, High: 8, Critical: 12}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	input := Intake{
+		PatientName: "High Risk",
+		Age:         68,
+		WeightKg:    90,
+		HeightCm:    170,
+		BP:          "168/102",
+		Conditions:  []string{"Heart Disease", "Hypertension"},
+		Medications: []Medication{
+			{Name: "Nitroglycerin", Dosage: "0.4mg", Frequency: "PRN"},
+		},
+		Complaint: "ED",
+	}
+
+	resp := Analyze(input)
+	if resp.RiskLevel != "CRITICAL" {
+		t.Fatalf("expected CRITICAL risk, got %s (score %d)", resp.RiskLevel, resp.RiskScore)
+	}
+	if errs := ValidateResponse(resp); len(errs) > 0 {
+		t.Fatalf("response should satisfy schema, got: %v", errs)
+	}
+
+	for score, want := range map[int]RiskLevel{3: "LOW", 4: "MEDIUM", 8: "HIGH", 11: "HIGH", 12: "CRITICAL"} {
+		if got := classifyRisk(score, Default().RiskThresholds()); got != want {
+			t.Fatalf("score %d: expected %s, got %s", score, want, got)
+		}
+	}
+}
+
+func TestRiskThresholds_Validate(t *testing.T) {
+	bad := []RiskThresholds{
+		{Medium: 0, High: 8},
+		{Medium: 8, High: 4},
+		{Medium: 4, High: 8, Critical: 8},
+	}
+	for _, th := range bad {
+		if err := SetRiskThresholds(th); err == nil {
+			t.Fatalf("expected error for thresholds %+v", th)
+		}
+	}
+	if Default().RiskThresholds() != DefaultRiskThresholds {
+		t.Fatalf("invalid thresholds should not replace the active ones")
+	}
+}
+
+func TestAnalyze_IssuesMergedAndSortedBySeverity(t *testing.T) {
+	input := Intake{
+		PatientName: "Heavy Drinker",
+		Age:         50,
+		WeightKg:    80,
+		HeightCm:    178,
+		BP:          "165/100",
+		Medications: []Medication{
+			{Name: "Amlodipine", Dosage: "5mg", Frequency: "Daily"},
+		},
+		Smoking:   "Current",
+		Alcohol:   "Heavy",
+		Complaint: "ED",
+	}
+
+	resp := Analyze(input)
+	if len(resp.ValidationErrors) > 0 {
+		t.Fatalf("unexpected validation errors: %v", resp.ValidationErrors)
+	}
+
+	alcohol := 0
+	alcoholIdx := -1
+	for i, issue := range resp.FlaggedIssues {
+		if issue.Type == "alcohol" {
+			alcohol++
+			alcoholIdx = i
+		}
+	}
+	if alcohol != 1 {
+		t.Fatalf("expected a single combined alcohol issue, got %d", alcohol)
+	}
+	merged := resp.FlaggedIssues[alcoholIdx]
+	if merged.Code != "DDI_PDE5_ALCOHOL" {
+		t.Fatalf("expected merged alcohol issue to use DDI_PDE5_ALCOHOL, got %s", merged.Code)
+	}
+	if !strings.Contains(merged.Description, "counsel moderation") || !strings.Contains(merged.Description, "hypotension and dizziness") {
+		t.Fatalf("expected merged description to combine both alcohol messages, got %q", merged.Description)
+	}
+
+	// The merged issue keeps the lifestyle issue's slot: after the smoking note within the info tier.
+	if alcoholIdx == 0 || resp.FlaggedIssues[alcoholIdx-1].Code != "LIFESTYLE_SMOKING" {
+		t.Fatalf("expected merged alcohol issue to follow the smoking issue, got order %v", issueCodes(resp.FlaggedIssues))
+	}
+
+	for i := 1; i < len(resp.FlaggedIssues); i++ {
+		prev, cur := resp.FlaggedIssues[i-1], resp.FlaggedIssues[i]
+		if severityRank[prev.Severity] > severityRank[cur.Severity] {
+			t.Fatalf("issues not sorted by severity: %v", issueCodes(resp.FlaggedIssues))
+		}
+	}
+	if resp.FlaggedIssues[0].Severity != "danger" {
+		t.Fatalf("expected danger issue first, got %s", resp.FlaggedIssues[0].Severity)
+	}
+}
+
+func issueCodes(issues []Issue) []string {
+	out := make([]string, 0, len(issues))
+	for _, i := range issues {
+		out = append(out, i.Code)
+	}
+	return out
+}
+
+func TestConfidence_DangerIssueNeverIncreases(t *testing.T) {
+	plan, alts := hairLossPlan(buildPlanContext{})
+	base := ScoreRequest{
+		Intake:       Intake{BP: "120/80", Conditions: []string{"Diabetes"}},
+		Plan:         plan,
+		Alternatives: alts,
+		RiskScore:    2,
+		RiskLevel:    "LOW",
+		Issues:       []Issue{newIssue("COND_DIABETES", "info", "diabetes")},
+	}
+	prev := callLLMStub(base).PlanConfidence
+
+	sc := base
+	for i := 0; i < 6; i++ {
+		sc.Issues = append(append([]Issue{}, sc.Issues...), newIssue("BP_UNCONTROLLED", "danger", "bp"))
+		sc.RiskScore += 3
+		sc.RiskLevel = classifyRisk(sc.RiskScore, DefaultRiskThresholds)
+		got := callLLMStub(sc).PlanConfidence
+		if got > prev {
+			t.Fatalf("adding danger issue %d increased confidence from %.3f to %.3f", i+1, prev, got)
+		}
+		prev = got
+	}
+}
+
+func TestConfidence_PopulatedListEarnsCoverage(t *testing.T) {
+	skipped := callLLMStub(ScoreRequest{Intake: Intake{BP: "120/80"}, RiskLevel: "LOW"})
+	populated := callLLMStub(ScoreRequest{Intake: Intake{BP: "120/80", Conditions: []string{"asthma"}}, RiskLevel: "LOW"})
+	empty := callLLMStub(ScoreRequest{Intake: Intake{BP: "120/80", Conditions: []string{}}, RiskLevel: "LOW"})
+	if empty.Factors.Coverage != skipped.Factors.Coverage {
+		t.Fatalf("empty unconfirmed conditions coverage %.2f, want the skipped list's %.2f", empty.Factors.Coverage, skipped.Factors.Coverage)
+	}
+	if populated.Factors.Coverage <= skipped.Factors.Coverage {
+		t.Fatalf("populated conditions coverage %.2f, want above the skipped list's %.2f", populated.Factors.Coverage, skipped.Factors.Coverage)
+	}
+	if populated.Factors.FactorCap == 0 || populated.PlanConfidence > skipped.PlanConfidence {
+		t.Fatalf("adding a condition raised confidence from %.3f to %.3f (factors %+v)", skipped.PlanConfidence, populated.PlanConfidence, *populated.Factors)
+	}
+}
+
+func TestAnalyze_ConfidenceReflectsRisk(t *testing.T) {
+	clean := Intake{
+		PatientName: "Clean",
+		Age:         35,
+		WeightKg:    72,
+		HeightCm:    178,
+		BP:          "118/76",
+		Complaint:   "ED",
+	}
+	nitrate := clean
+	nitrate.Age = 68
+	nitrate.BP = "168/102"
+	nitrate.Conditions = []string{"Heart Disease"}
+	nitrate.Medications = []Medication{{Name: "Nitroglycerin", Dosage: "0.4mg", Frequency: "PRN"}}
+
+	low := AnalyzeWithOptions(clean, Options{Debug: true})
+	high := AnalyzeWithOptions(nitrate, Options{Debug: true})
+	if high.RiskLevel != "HIGH" {
+		t.Fatalf("expected HIGH risk, got %s", high.RiskLevel)
+	}
+	if high.PlanConfidence > confidenceBands["HIGH"].Ceiling {
+		t.Fatalf("HIGH risk confidence %.2f exceeds ceiling", high.PlanConfidence)
+	}
+	if high.PlanConfidence >= low.PlanConfidence {
+		t.Fatalf("expected contraindicated case (%.2f) below clean case (%.2f)", high.PlanConfidence, low.PlanConfidence)
+	}
+	if high.ConfidenceFactors == nil || high.ConfidenceFactors.SubstitutionPenalty == 0 {
+		t.Fatalf("expected debug confidence factors with substitution penalty, got %+v", high.ConfidenceFactors)
+	}
+	if errs := ValidateResponse(high); len(errs) > 0 {
+		t.Fatalf("response should satisfy schema, got: %v", errs)
+	}
+	if Analyze(clean).ConfidenceFactors != nil {
+		t.Fatalf("confidence factors should be omitted without debug")
+	}
+}
+
+func TestAnalyze_BMIConsistency(t *testing.T) {
+	base := Intake{PatientName: "BMI Check", Age: 40, WeightKg: 120, HeightCm: 170, BP: "120/80", Complaint: "ED"}
+
+	bogus := base
+	bogus.BMI = 22
+	resp := Analyze(bogus)
+	if len(resp.ValidationErrors) > 0 {
+		t.Fatalf("unexpected validation errors: %v", resp.ValidationErrors)
+	}
+	if resp.ProvidedBMI != 22 || resp.ComputedBMI < 41 || resp.ComputedBMI > 42 {
+		t.Fatalf("provided %.1f computed %.1f, want 22 and the value from weight and height", resp.ProvidedBMI, resp.ComputedBMI)
+	}
+	if codes := issueCodes(resp.FlaggedIssues); !slices.Contains(codes, "BMI_INCONSISTENT") || !slices.Contains(codes, "BMI_OBESITY") {
+		t.Fatalf("issues = %v, want BMI_INCONSISTENT and BMI_OBESITY", codes)
+	}
+
+	near := base
+	near.BMI = 41
+	resp = Analyze(near)
+	if resp.ComputedBMI != 41 || slices.Contains(issueCodes(resp.FlaggedIssues), "BMI_INCONSISTENT") {
+		t.Fatalf("BMI within 1.0 should be used as supplied: %.1f %v", resp.ComputedBMI, issueCodes(resp.FlaggedIssues))
+	}
+
+	unverifiable := base
+	unverifiable.WeightKg, unverifiable.HeightCm, unverifiable.BMI = 0, 0, 31
+	resp = Analyze(unverifiable)
+	if len(resp.ValidationErrors) > 0 {
+		t.Fatalf("BMI without weight and height should be accepted: %v", resp.ValidationErrors)
+	}
+	if codes := issueCodes(resp.FlaggedIssues); resp.ComputedBMI != 31 || !slices.Contains(codes, "BMI_UNVERIFIABLE") {
+		t.Fatalf("unverifiable: bmi %.1f issues %v", resp.ComputedBMI, codes)
+	}
+
+	base.WeightKg = 0
+	if resp := Analyze(base); len(resp.ValidationErrors) == 0 {
+		t.Fatal("missing weight without a BMI should fail validation")
+	}
+}
+
+func TestParseBP(t *testing.T) {
+	tests := []struct {
+		in       string
+		sys, dia int
+		code     string
+	}{
+		{"120/80", 120, 80, ""},
+		{" 135 / 88 ", 135, 88, ""},
+		{"150/95 mmHg", 150, 95, ""},
+		{"150/95mmHg", 150, 95, ""},
+		{"120 over 80", 120, 80, ""},
+		{"120 OVER 80 mm Hg", 120, 80, ""},
+		{"BP: 142/91", 142, 91, ""},
+		{"98/60", 98, 60, ""},
+		{"I don't know", 0, 0, "invalid_format"},
+		{"12/8", 0, 0, "invalid_format"},
+		{"120-80", 0, 0, "invalid_format"},
+		{"120/80/60", 0, 0, "invalid_format"},
+		{"normal", 0, 0, "invalid_format"},
+		{"80/120", 80, 120, "out_of_range"},
+		{"120/120", 120, 120, "out_of_range"},
+		{"300/80", 300, 80, "out_of_range"},
+		{"120/20", 120, 20, "out_of_range"},
+	}
+	for _, tt := range tests {
+		checkBPInvariants(t, tt.in)
+		sys, dia, problem := parseBP(tt.in)
+		code := ""
+		if problem != nil {
+			code = problem.Code
+		}
+		if sys != tt.sys || dia != tt.dia || code != tt.code {
+			t.Errorf("parseBP(%q) = %d, %d, %q; want %d, %d, %q", tt.in, sys, dia, code, tt.sys, tt.dia, tt.code)
+		}
+	}
+}
+
+func TestAnalyze_UnparseableBPFailsValidation(t *testing.T) {
+	resp := Analyze(Intake{PatientName: "BP Check", Age: 40, WeightKg: 80, HeightCm: 175, BP: "120 over 80 mmHg", Complaint: "ED"})
+	if len(resp.ValidationErrors) > 0 {
+		t.Fatalf("unexpected validation errors: %v", resp.ValidationErrors)
+	}
+	for _, bp := range []string{"I don't know", "12/8", "80/120"} {
+		resp := Analyze(Intake{PatientName: "BP Check", Age: 40, WeightKg: 80, HeightCm: 175, BP: bp, Complaint: "ED"})
+		if resp.RiskLevel != "INVALID" || len(resp.ValidationErrors) != 1 || !strings.Contains(resp.ValidationErrors[0], "bp") {
+			t.Errorf("bp %q: risk %s, errors %v", bp, resp.RiskLevel, resp.ValidationErrors)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package analysis
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"slices"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/signing"
+)
+
+// Analyzer runs the analysis pipeline against its own audit store, LLM client,
+// rules, clock, and thresholds, so differently configured instances can share
+// a process. The package-level functions delegate to Default().
+type Analyzer struct {
+	mu sync.RWMutex
+	s  settings
+	// rulesMu serializes ReplaceRules so each audited change sees the
+	// version it replaces.
+	rulesMu sync.Mutex
+
+	now      func() time.Time
+	ids      audit.IDGenerator
+	shadowWG sync.WaitGroup
+	notifyWG sync.WaitGroup
+	// shadowSlots holds a token per shadow scoring call in flight.
+	shadowSlots chan struct{}
+	// notifyBackoff is the delay before a notification's first retry.
+	notifyBackoff time.Duration
+	latency       *latencyTracker
+}
+
+// settings is the mutable configuration of an Analyzer. It is copied under the
+// lock so each analysis sees one consistent view even while setters run.
+type settings struct {
+	store      audit.Store
+	llm        LLMClient
+	llmTimeout time.Duration
+	shadow     bool
+	// decisionRevisions records further decisions on an audit as revisions
+	// instead of rejecting them.
+	decisionRevisions bool
+	// consentRequired rejects intakes without documented consent;
+	// consentGrace downgrades that to a logged warning.
+	consentRequired bool
+	consentGrace    bool
+	rules           RulesSource
+	doseCaps        []DoseCap
+	drugClasses     []DrugClass
+	riskWeights     map[string]int
+	rulesSource     string
+	thresholds      RiskThresholds
+	prompt          *template.Template
+	promptInfo      PromptInfo
+	// promptText is the source of prompt, which bundles carry.
+	promptText string
+	locales    catalog
+	education  EducationCatalog
+	triage     TriageCatalog
+	// signer signs audited responses; nil when signing is off.
+	signer *signing.Key
+
+	pseudonymizer Pseudonymizer
+	// storeIntakes keeps the redacted intake with each audit entry.
+	storeIntakes bool
+	// disclaimers are stamped on every Response; demo puts DemoDisclaimer
+	// ahead of them.
+	disclaimers []string
+	demo        bool
+	// requirements are the fields each complaint requires or recommends;
+	// listConfirmation enforces the required medication and condition lists
+	// rather than warning about them.
+	requirements     map[string]ComplaintRequirements
+	listConfirmation bool
+	// historyCeiling caps plan confidence while a history section is
+	// unconfirmed.
+	historyCeiling float64
+	// noteHeaders head the sections of a visit note.
+	noteHeaders NoteHeaders
+	// orgs are the per-org overrides by org ID and orgKeys the org of each
+	// API key by its hash; both empty when the deployment is not partitioned.
+	orgs    map[string]OrgConfig
+	orgKeys map[[sha256.Size]byte]string
+	// notifiers are told about audited analyses that flag a danger issue,
+	// and about overdue reminders when reminderNotify is set.
+	notifiers      []Notifier
+	reminderNotify bool
+	// forwarders are given every written audit.
+	forwarders []AuditForwarder
+	// slo is the latency objective CheckLatency alerts on; zero when none.
+	slo SLO
+	// bundle describes the configuration bundle last applied; nil when none
+	// has been.
+	bundle *BundleManifest
+	// results caches responses by intake; nil when caching is off.
+	results *resultCache
+	// generation counts committed updates, so cached results computed
+	// under earlier settings are told apart.
+	generation uint64
+}
+
+// Option configures an Analyzer built by New.
+type Option func(*Analyzer)
+
+// WithAuditStore sets the audit store; nil keeps the in-memory default.
+func WithAuditStore(store audit.Store) Option {
+	return func(a *Analyzer) {
+		if store != nil {
+			a.s.store = store
+		}
+	}
+}
+
+// WithLLMClient sets the confidence scoring client; nil keeps StubLLM.
+func WithLLMClient(client LLMClient) Option {
+	return func(a *Analyzer) {
+		if client != nil {
+			a.s.llm = client
+		}
+	}
+}
+
+// WithLLMTimeout bounds each scoring call; non-positive values keep the default.
+func WithLLMTimeout(d time.Duration) Option {
+	return func(a *Analyzer) {
+		if d > 0 {
+			a.s.llmTimeout = d
+		}
+	}
+}
+
+// WithRules sets the source of drug interaction rules; nil keeps DefaultRules.
+func WithRules(src RulesSource) Option {
+	return func(a *Analyzer) {
+		if src != nil {
+			a.s.rules = src
+			a.s.rulesSource = "custom"
+		}
+	}
+}
+
+// WithClock sets the time source for audit timestamps.
+func WithClock(now func() time.Time) Option {
+	return func(a *Analyzer) {
+		if now != nil {
+			a.now = now
+		}
+	}
+}
+
+// WithIDGenerator sets how audit IDs are issued; the default is UUIDv4.
+func WithIDGenerator(ids audit.IDGenerator) Option {
+	return func(a *Analyzer) {
+		if ids != nil {
+			a.ids = ids
+		}
+	}
+}
+
+// WithRiskThresholds sets the risk tier cut points. It panics on invalid
+// thresholds; validate untrusted configuration with RiskThresholds.Validate first.
+func WithRiskThresholds(t RiskThresholds) Option {
+	return func(a *Analyzer) {
+		if err := t.Validate(); err != nil {
+			panic(fmt.Sprintf("analysis: %v", err))
+		}
+		a.s.thresholds = t
+	}
+}
+
+// New builds an Analyzer; unset dependencies use the same defaults as the
+// package-level API.
+func New(opts ...Option) *Analyzer {
+	a := &Analyzer{
+		shadowSlots: make(chan struct{}, maxShadowCalls),
+		s: settings{
+			store:       audit.NewMemoryStore(),
+			llm:         StubLLM{},
+			llmTimeout:  defaultLLMTimeout,
+			rules:       DefaultRules(),
+			doseCaps:    defaultDoseCaps(),
+			drugClasses: drugClasses,
+			riskWeights: defaultRiskWeights(),
+			rulesSource: "embedded",
+			thresholds:  DefaultRiskThresholds,
+			locales:     embeddedCatalog,
+			education:   embeddedEducationCatalog,
+			triage:      embeddedTriageCatalog,
+
+			pseudonymizer:  ephemeralPseudonymizer(),
+			storeIntakes:   true,
+			disclaimers:    slices.Clone(DefaultDisclaimers),
+			requirements:   complaintRequirements,
+			historyCeiling: DefaultHistoryConfidenceCeiling,
+			noteHeaders:    DefaultNoteHeaders,
+		},
+		now:           time.Now,
+		ids:           audit.UUIDGenerator{},
+		notifyBackoff: defaultNotifyBackoff,
+		latency:       newLatencyTracker(),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	if err := a.update(func(s *settings) error {
+		return s.setPrompt(embeddedPrompt, "embedded")
+	}); err != nil {
+		panic(fmt.Sprintf("analysis: embedded system prompt: %v", err))
+	}
+	return a
+}
+
+var defaultAnalyzer = New()
+
+// Default returns the Analyzer behind the package-level functions.
+func Default() *Analyzer {
+	return defaultAnalyzer
+}
+
+func (a *Analyzer) settings() settings {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.s
+}
+
+// update applies fn to a copy of the settings and commits it only on success.
+func (a *Analyzer) update(fn func(*settings) error) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	next := a.s
+	if err := fn(&next); err != nil {
+		return err
+	}
+	next.generation++
+	a.s = next
+	return nil
+}
+
+// SetAuditStore replaces the audit store; nil is ignored.
+func (a *Analyzer) SetAuditStore(store audit.Store) {
+	if store == nil {
+		return
+	}
+	_ = a.update(func(s *settings) error {
+		s.store = store
+		return nil
+	})
+}
+
+func SetAuditStore(store audit.Store) {
+	defaultAnalyzer.SetAuditStore(store)
+}
+
+// PingAuditStore checks that the active audit store is reachable.
+func (a *Analyzer) PingAuditStore(ctx context.Context) error {
+	return a.settings().store.Ping(ctx)
+}
+
+func PingAuditStore(ctx context.Context) error {
+	return defaultAnalyzer.PingAuditStore(ctx)
+}
@@ -0,0 +1,162 @@
+package analysis
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+	"github.com/google/uuid"
+)
+
+func TestAnalyzer_InstancesAreIndependent(t *testing.T) {
+	storeA, storeB := audit.NewMemoryStore(), audit.NewMemoryStore()
+	strict := New(WithAuditStore(storeA), WithRiskThresholds(RiskThresholds{Medium: 2, High: 3}))
+	lenient := New(WithAuditStore(storeB))
+
+	in := Intake{PatientName: "Split", Age: 60, WeightKg: 80, HeightCm: 178, BP: "128/82", Complaint: "Hair Loss"}
+	if got := strict.Analyze(in).RiskLevel; got != "MEDIUM" {
+		t.Fatalf("strict analyzer: expected MEDIUM, got %s", got)
+	}
+	if got := lenient.Analyze(in).RiskLevel; got != "LOW" {
+		t.Fatalf("default analyzer: expected LOW, got %s", got)
+	}
+	if strict.PromptVersion() == lenient.PromptVersion() {
+		t.Fatalf("prompt versions should reflect each analyzer's thresholds")
+	}
+
+	a, _ := storeA.Latest(t.Context(), 10)
+	b, _ := storeB.Latest(t.Context(), 10)
+	if len(a) != 1 || len(b) != 1 {
+		t.Fatalf("expected one audit per store, got %d and %d", len(a), len(b))
+	}
+}
+
+func TestAnalyzer_ClockAndIDsAreInjectable(t *testing.T) {
+	n := 0
+	a := New(WithClock(fixedClock), WithIDGenerator(audit.IDGeneratorFunc(func() string {
+		n++
+		return fmt.Sprintf("audit-test-%d", n)
+	})))
+	first := a.Analyze(llmIntake)
+	second := a.Analyze(llmIntake)
+
+	if first.AuditAt != "2025-01-02T03:04:05Z" {
+		t.Fatalf("expected audit timestamp from injected clock, got %s", first.AuditAt)
+	}
+	if first.AuditID != "audit-test-1" || second.AuditID != "audit-test-2" {
+		t.Fatalf("unexpected audit IDs %s, %s", first.AuditID, second.AuditID)
+	}
+}
+
+func TestAnalyzer_DefaultAuditIDsAreUUIDs(t *testing.T) {
+	id := New().Analyze(llmIntake).AuditID
+	if _, err := uuid.Parse(id); err != nil {
+		t.Fatalf("expected UUID audit ID, got %q: %v", id, err)
+	}
+}
+
+func TestAnalyzer_WithRules(t *testing.T) {
+	rules := StaticRules{{
+		Code:     "DDI_AMLODIPINE_SIMVASTATIN",
+		Drug:     "minoxidil",
+		With:     "amlodipine",
+		Severity: "danger",
+		Desc:     "Test rule.",
+	}}
+	a := New(WithRules(rules))
+	in := llmIntake
+	in.Medications = []Medication{{Name: "Minoxidil"}, {Name: "Amlodipine"}}
+
+	resp := a.Analyze(in)
+	if !hasIssue(resp.FlaggedIssues, "drug_interaction") {
+		t.Fatalf("expected custom rule to fire, got %v", issueCodes(resp.FlaggedIssues))
+	}
+	if resp := Analyze(in); hasIssue(resp.FlaggedIssues, "drug_interaction") {
+		t.Fatalf("custom rules must not leak into the default analyzer")
+	}
+}
+
+func TestAnalyzer_StoresFullResponse(t *testing.T) {
+	store := audit.NewMemoryStore()
+	resp := New(WithAuditStore(store)).Analyze(llmIntake)
+
+	raw, err := store.Response(t.Context(), resp.AuditID)
+	if err != nil {
+		t.Fatalf("response: %v", err)
+	}
+	var stored Response
+	if err := json.Unmarshal(raw, &stored); err != nil {
+		t.Fatalf("stored response is not JSON: %v", err)
+	}
+	if stored.AuditID != resp.AuditID || !reflect.DeepEqual(stored.RecommendedPlan, resp.RecommendedPlan) {
+		t.Fatalf("stored response differs from returned one: %+v", stored)
+	}
+}
+
+func TestAnalyzer_DryRun(t *testing.T) {
+	store := audit.NewMemoryStore()
+	a := New(WithAuditStore(store))
+	before := analysesRun.Value("dry_run", "ok")
+
+	resp := a.AnalyzeContext(t.Context(), llmIntake, Options{DryRun: true})
+	if !resp.DryRun || resp.AuditID != "" || resp.AuditAt != "" {
+		t.Fatalf("dry run should be flagged and unaudited: dryRun=%t auditId=%q", resp.DryRun, resp.AuditID)
+	}
+	if resp.RiskLevel == "INVALID" || len(resp.ValidationErrors) > 0 {
+		t.Fatalf("dry run should still analyze: %+v", resp)
+	}
+	if got := analysesRun.Value("dry_run", "ok") - before; got != 1 {
+		t.Fatalf("dry_run counter moved by %v, want 1", got)
+	}
+	if audits, err := store.Latest(t.Context(), 10); err != nil || len(audits) != 0 {
+		t.Fatalf("dry run wrote to the audit log: %v %v", audits, err)
+	}
+
+	if resp := a.AnalyzeContext(t.Context(), Intake{}, Options{DryRun: true}); !resp.DryRun || resp.RiskLevel != "INVALID" {
+		t.Fatalf("invalid dry run: %+v", resp)
+	}
+	if resp := a.Analyze(llmIntake); resp.DryRun || resp.AuditID == "" {
+		t.Fatalf("normal run: dryRun=%t auditId=%q", resp.DryRun, resp.AuditID)
+	}
+}
+
+// failingStore rejects every audit write.
+type failingStore struct{ *audit.MemoryStore }
+
+func (failingStore) Insert(context.Context, audit.Entry) (audit.Summary, error) {
+	return audit.Summary{}, errors.New("disk I/O error")
+}
+
+func TestAnalyzer_AuditWriteFailure(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	before := auditWriteFailures.Value()
+
+	resp := New(WithAuditStore(failingStore{audit.NewMemoryStore()})).Analyze(llmIntake)
+	if resp.AuditRecorded == nil || *resp.AuditRecorded || resp.AuditID != "" {
+		t.Fatalf("auditRecorded = %v, auditId %q; want false and none", resp.AuditRecorded, resp.AuditID)
+	}
+	if resp.RiskLevel == RiskInvalid || len(resp.ValidationErrors) > 0 {
+		t.Fatalf("the analysis should still be returned: %+v", resp.ValidationErrors)
+	}
+	if got := auditWriteFailures.Value() - before; got != 1 {
+		t.Errorf("failure counter moved by %v, want 1", got)
+	}
+	if out := logs.String(); !strings.Contains(out, "audit write failed") || strings.Contains(out, "patient="+llmIntake.PatientName) {
+		t.Errorf("log = %q, want a warning without the patient name", out)
+	}
+
+	ok := New(WithAuditStore(audit.NewMemoryStore())).Analyze(llmIntake)
+	if ok.AuditRecorded == nil || !*ok.AuditRecorded || ok.AuditID == "" {
+		t.Fatalf("recorded analysis: auditRecorded = %v, auditId %q", ok.AuditRecorded, ok.AuditID)
+	}
+}
@@ -0,0 +1,26 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+// ErrBackupUnsupported is returned when the audit store cannot be backed up
+// while serving.
+var ErrBackupUnsupported = errors.New("audit store does not support online backups")
+
+// BackupAuditStore snapshots the audit store into dir, keeping the keep newest
+// backups there; keep <= 0 keeps them all.
+func (a *Analyzer) BackupAuditStore(ctx context.Context, dir string, keep int) (audit.BackupInfo, error) {
+	store, ok := a.settings().store.(audit.BackupStore)
+	if !ok {
+		return audit.BackupInfo{}, ErrBackupUnsupported
+	}
+	return store.Backup(ctx, dir, keep)
+}
+
+func BackupAuditStore(ctx context.Context, dir string, keep int) (audit.BackupInfo, error) {
+	return defaultAnalyzer.BackupAuditStore(ctx, dir, keep)
+}
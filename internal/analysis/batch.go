@@ -0,0 +1,102 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/trace"
+)
+
+// AnalyzeBatch analyzes the items of req in order and writes their audit
+// entries together, in one transaction when the store is an
+// audit.BatchInserter. opts applies to every item except DryRun, which
+// comes from req and the item. An item counts the ones before it for the
+// same patient as previous analyses, as if they had been sent one by one.
+func (a *Analyzer) AnalyzeBatch(ctx context.Context, req BatchRequest, opts Options) BatchResponse {
+	ctx, span := trace.Start(ctx, "analysis.analyze_batch", trace.Int("analysis.batch_size", len(req.Items)))
+	defer span.End()
+	s := a.settingsFor(ctx)
+	_, history := s.store.(audit.PatientHistory)
+	pending := map[string]audit.Summary{}
+	runs := make([]*analysisRun, 0, len(req.Items))
+	var audited []*analysisRun
+	for _, item := range req.Items {
+		o := opts
+		o.DryRun = req.DryRun
+		if item.DryRun != nil {
+			o.DryRun = *item.DryRun
+		}
+		run := a.evaluate(ctx, s, item.Intake, o, pending)
+		runs = append(runs, run)
+		if run.failure != nil {
+			run.recordFailure(ctx, s)
+		}
+		if run.entry == nil {
+			continue
+		}
+		audited = append(audited, run)
+		if history && run.ref != "" {
+			pending[run.ref] = audit.Summary{RiskScore: run.resp.RiskScore}
+		}
+	}
+
+	if len(audited) > 0 {
+		entries := make([]audit.Entry, len(audited))
+		stops := make([]func(), len(audited))
+		for i, run := range audited {
+			entries[i] = *run.entry
+			stops[i] = run.timer.begin(stageAuditInsert)
+		}
+		sums, errs := insertAudits(ctx, s, entries)
+		for i, run := range audited {
+			stops[i]()
+			run.recorded(ctx, sums[i], errs[i])
+		}
+	}
+
+	out := BatchResponse{Results: make([]Response, 0, len(runs))}
+	for i, run := range runs {
+		resp := run.finish(ctx)
+		countAnalysis(resp, run.opts)
+		out.Results = append(out.Results, resp)
+		if run.auditErr != nil {
+			out.AuditFailures = append(out.AuditFailures, BatchFailure{Index: i, Error: "failed to persist audit log"})
+		}
+	}
+	return out
+}
+
+func AnalyzeBatch(ctx context.Context, req BatchRequest, opts Options) BatchResponse {
+	return defaultAnalyzer.AnalyzeBatch(ctx, req, opts)
+}
+
+// insertAudits writes entries, in one call when the store supports it, and
+// returns one Summary and one error per entry.
+func insertAudits(ctx context.Context, s settings, entries []audit.Entry) ([]audit.Summary, []error) {
+	errs := make([]error, len(entries))
+	store, ok := s.store.(audit.BatchInserter)
+	if !ok {
+		sums := make([]audit.Summary, len(entries))
+		for i, e := range entries {
+			sums[i], errs[i] = insertAudit(ctx, s, e)
+		}
+		return sums, errs
+	}
+	ctx, span := trace.Start(ctx, "audit.insert_batch", trace.Int("audit.batch_size", len(entries)))
+	defer span.End()
+	sums, err := store.InsertBatch(ctx, entries)
+	var batchErr *audit.BatchInsertError
+	switch {
+	case errors.As(err, &batchErr):
+		span.SetError("audit batch partially failed")
+		return sums, batchErr.Errs
+	case err != nil:
+		span.SetError("audit batch insert failed")
+		for i := range errs {
+			errs[i] = err
+		}
+		return make([]audit.Summary, len(entries)), errs
+	}
+	return sums, errs
+}
@@ -0,0 +1,94 @@
+package analysis
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+func TestAnalyzeBatch_MatchesAnalyze(t *testing.T) {
+	want := responseJSON(t, New(WithClock(fixedClock), WithIDGenerator(fixedID)).Analyze(localeIntake))
+	out := New(WithClock(fixedClock), WithIDGenerator(fixedID)).AnalyzeBatch(t.Context(), BatchRequest{Items: []BatchItem{{Intake: localeIntake}}}, Options{})
+	if len(out.Results) != 1 || len(out.AuditFailures) != 0 {
+		t.Fatalf("batch = %+v", out)
+	}
+	if got := responseJSON(t, out.Results[0]); !bytes.Equal(got, want) {
+		t.Fatalf("batch item differs from Analyze:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestAnalyzeBatch_PreviousInBatch(t *testing.T) {
+	a := New()
+	second := localeIntake
+	second.BP = "120/80"
+	dry := true
+	out := a.AnalyzeBatch(t.Context(), BatchRequest{Items: []BatchItem{
+		{Intake: localeIntake},
+		{Intake: second},
+		{Intake: localeIntake, DryRun: &dry},
+	}}, Options{})
+	first := out.Results[0]
+	if first.AuditID == "" || first.PreviousRiskScore != nil {
+		t.Fatalf("first item = %+v", first)
+	}
+	// Each item follows the one before it for the same patient.
+	for i := 1; i < len(out.Results); i++ {
+		prev, r := out.Results[i-1], out.Results[i]
+		if r.PreviousRiskScore == nil || *r.PreviousRiskScore != prev.RiskScore || len(r.ValidationErrors) != 0 {
+			t.Errorf("item %d: previous %v, errors %v; want previous %d", i, r.PreviousRiskScore, r.ValidationErrors, prev.RiskScore)
+		}
+	}
+	if out.Results[1].RiskScore == first.RiskScore {
+		t.Fatal("items should score differently")
+	}
+	if out.Results[2].AuditID != "" {
+		t.Error("dry-run item was audited")
+	}
+	if got := a.LatestAudits(10); len(got) != 2 {
+		t.Fatalf("audited %d items, want 2", len(got))
+	}
+}
+
+// failingBatchStore fails the batch entry at index fail.
+type failingBatchStore struct {
+	*audit.MemoryStore
+	fail int
+}
+
+func (s failingBatchStore) InsertBatch(ctx context.Context, entries []audit.Entry) ([]audit.Summary, error) {
+	sums := make([]audit.Summary, len(entries))
+	errs := make([]error, len(entries))
+	for i, e := range entries {
+		if i == s.fail {
+			errs[i] = errors.New("disk full")
+			continue
+		}
+		sums[i], errs[i] = s.Insert(ctx, e)
+	}
+	return sums, &audit.BatchInsertError{Errs: errs}
+}
+
+func TestAnalyzeBatch_PartialFailure(t *testing.T) {
+	store := failingBatchStore{MemoryStore: audit.NewMemoryStore(), fail: 1}
+	a := New(WithAuditStore(store))
+	invalid := Intake{PatientName: "Invalid"}
+	out := a.AnalyzeBatch(t.Context(), BatchRequest{Items: []BatchItem{
+		{Intake: invalid}, {Intake: localeIntake}, {Intake: localeIntake}, {Intake: localeIntake},
+	}}, Options{})
+	// Batch entries skip the invalid item, so entry 1 is item 2.
+	if len(out.AuditFailures) != 1 || out.AuditFailures[0].Index != 2 {
+		t.Fatalf("audit failures = %+v", out.AuditFailures)
+	}
+	if r := out.Results[2]; r.AuditID != "" || r.AuditRecorded == nil || *r.AuditRecorded || len(r.ValidationErrors) != 0 {
+		t.Fatalf("failed item = %+v", r)
+	}
+	if out.Results[1].AuditID == "" || out.Results[3].AuditID == "" {
+		t.Fatal("the other items were not audited")
+	}
+	if got, _ := store.Latest(t.Context(), 10); len(got) != 2 {
+		t.Fatalf("stored %d audits, want 2", len(got))
+	}
+}
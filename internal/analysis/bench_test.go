@@ -0,0 +1,67 @@
+package analysis
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Results are recorded in testdata/benchmarks.txt; refresh it when changing
+// the parsing helpers:
+//
+//	go test ./internal/analysis -run '^$' -bench . -benchmem
+
+func BenchmarkParseBP(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		parseBP("150 / 95 mmHg")
+	}
+}
+
+func BenchmarkExtractMg(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		extractMg("5mg (start low due to renal/hepatic risk)")
+	}
+}
+
+func BenchmarkNormalizeMeds(b *testing.B) {
+	meds := benchIntake().Medications
+	b.ReportAllocs()
+	for b.Loop() {
+		normalizeMeds(meds)
+	}
+}
+
+func BenchmarkAnalyze20Meds(b *testing.B) {
+	a := New(WithClock(fixedClock), WithIDGenerator(fixedID))
+	in := benchIntake()
+	b.ReportAllocs()
+	for b.Loop() {
+		a.Analyze(in)
+	}
+}
+
+func benchIntake() Intake {
+	in := Intake{
+		PatientName: "Bench",
+		Age:         67,
+		WeightKg:    98,
+		HeightCm:    175,
+		BP:          "152/94",
+		Conditions:  []string{"Heart Disease", "diabetes", " hypertension "},
+		Allergies:   []string{"sulfa"},
+		Smoking:     "current",
+		Alcohol:     "heavy",
+		Complaint:   "ED",
+		Medications: []Medication{
+			{Name: "Amlodipine", Dosage: "10mg"},
+			{Name: "simvastatin", Dosage: "40mg"},
+			{Name: "Metformin", Dosage: "500mg"},
+			{Name: "tamsulosin", Dosage: "0.4mg"},
+		},
+	}
+	for i := len(in.Medications); i < 20; i++ {
+		in.Medications = append(in.Medications, Medication{Name: fmt.Sprintf(" Med-%02d ", i), Dosage: "1mg"})
+	}
+	return in
+}
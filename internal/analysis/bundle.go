@@ -0,0 +1,488 @@
+package analysis
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/signing"
+)
+
+// BundleFormat is the layout of the bundles ExportBundle writes; other
+// layouts are refused.
+const BundleFormat = 1
+
+// maxBundleBytes bounds a bundle archive, compressed and unpacked.
+const maxBundleBytes = 8 << 20
+
+// Bundle members.
+const (
+	bundleManifest  = "manifest.json"
+	bundleSignature = "manifest.sig"
+	bundleRules     = "rules.json"
+	bundlePrompt    = "prompt.tmpl"
+	bundleEducation = "education.json"
+	bundleTriage    = "triage.json"
+	bundleConfig    = "config.json"
+)
+
+// bundleContents are the members a manifest covers; bundleMembers adds the
+// manifest and its signature, in archive order.
+var (
+	bundleContents = []string{bundleRules, bundlePrompt, bundleEducation, bundleTriage, bundleConfig}
+	bundleMembers  = append([]string{bundleManifest, bundleSignature}, bundleContents...)
+)
+
+var (
+	// ErrInvalidBundle is returned for an archive that is not a well-formed
+	// bundle or whose contents fail validation.
+	ErrInvalidBundle = errors.New("invalid configuration bundle")
+	// ErrBundleUntrusted is returned for a bundle no trusted key signed.
+	ErrBundleUntrusted = errors.New("configuration bundle is not signed by a trusted key")
+	// ErrBundleDowngrade is returned for a bundle older than the one in
+	// place when it is not forced.
+	ErrBundleDowngrade = errors.New("configuration bundle is older than the one in place")
+	// ErrBundleUnsigned is returned by ExportBundle when signing is off.
+	ErrBundleUnsigned = errors.New("configuration bundles need a signing key")
+)
+
+// BundleManifest describes a configuration bundle. Files holds the SHA-256
+// of each member, and Hash, a short hash over them, is folded into
+// RulesetVersion while the bundle is applied. Version orders bundles.
+type BundleManifest struct {
+	Format    int       `json:"format"`
+	Version   int       `json:"version"`
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Build and RulesetVersion are those of the exporting deployment.
+	Build          string            `json:"build"`
+	RulesetVersion string            `json:"rulesetVersion"`
+	Files          map[string]string `json:"files"`
+}
+
+// BundleConfig is the deployment configuration a bundle carries beside the
+// ruleset, prompt, and catalogs. It holds nothing secret: keys, tokens, org
+// API keys, and stores stay with each deployment.
+type BundleConfig struct {
+	RiskThresholds           RiskThresholds                   `json:"riskThresholds"`
+	ConsentRequired          bool                             `json:"consentRequired"`
+	ConsentGrace             bool                             `json:"consentGrace"`
+	ListConfirmationRequired bool                             `json:"listConfirmationRequired"`
+	DecisionRevisions        bool                             `json:"decisionRevisions"`
+	StoreIntakes             bool                             `json:"storeIntakes"`
+	HistoryConfidenceCeiling float64                          `json:"historyConfidenceCeiling"`
+	Disclaimers              []string                         `json:"disclaimers"`
+	NoteHeaders              NoteHeaders                      `json:"noteHeaders"`
+	Requirements             map[string]ComplaintRequirements `json:"complaintRequirements"`
+}
+
+// ExportBundle writes the active ruleset with its drug classes, the system
+// prompt template, the education and triage catalogs, and the BundleConfig
+// as a gzipped tar whose manifest is signed with the signing key. A version
+// of zero or less is one past the applied bundle's. It fails with
+// ErrBundleUnsigned when signing is off.
+func (a *Analyzer) ExportBundle(version int) ([]byte, BundleManifest, error) {
+	s := a.settings()
+	if s.signer == nil {
+		return nil, BundleManifest{}, ErrBundleUnsigned
+	}
+	if version <= 0 {
+		version = 1
+		if s.bundle != nil {
+			version = s.bundle.Version + 1
+		}
+	}
+	files := map[string][]byte{bundlePrompt: []byte(s.promptText)}
+	for name, v := range map[string]any{
+		bundleRules:     s.rulesInfo().Ruleset,
+		bundleEducation: s.education,
+		bundleTriage:    s.triage,
+		bundleConfig:    s.bundleConfig(),
+	} {
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return nil, BundleManifest{}, err
+		}
+		files[name] = append(b, '\n')
+	}
+	m := BundleManifest{
+		Format:         BundleFormat,
+		Version:        version,
+		CreatedAt:      a.now().UTC(),
+		Build:          BuildVersion(),
+		RulesetVersion: s.rulesetVersion(),
+		Files:          map[string]string{},
+	}
+	for _, name := range bundleContents {
+		sum := sha256.Sum256(files[name])
+		m.Files[name] = hex.EncodeToString(sum[:])
+	}
+	m.Hash = bundleHash(m.Files)
+
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, BundleManifest{}, err
+	}
+	value, err := s.signer.Sign(manifest)
+	if err != nil {
+		return nil, BundleManifest{}, err
+	}
+	sig, err := json.Marshal(Signature{KeyID: s.signer.ID(), Algorithm: signing.Algorithm, Value: value})
+	if err != nil {
+		return nil, BundleManifest{}, err
+	}
+	files[bundleManifest], files[bundleSignature] = manifest, sig
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range bundleMembers {
+		hdr := &tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0o644, Size: int64(len(files[name])), ModTime: m.CreatedAt}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, BundleManifest{}, err
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			return nil, BundleManifest{}, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, BundleManifest{}, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, BundleManifest{}, err
+	}
+	return buf.Bytes(), m, nil
+}
+
+func ExportBundle(version int) ([]byte, BundleManifest, error) {
+	return defaultAnalyzer.ExportBundle(version)
+}
+
+// ApplyBundle checks that data is a bundle signed by one of trusted or by
+// the signing key, validates every member, and makes them all active in one
+// change; on error nothing changes. A bundle with a lower Version than the
+// applied one fails with ErrBundleDowngrade unless force is set.
+func (a *Analyzer) ApplyBundle(data []byte, trusted []ed25519.PublicKey, force bool) (BundleManifest, error) {
+	b, err := readBundle(data)
+	if err != nil {
+		return BundleManifest{}, err
+	}
+	err = a.update(func(s *settings) error {
+		keys := trusted
+		if s.signer != nil {
+			keys = append(slices.Clone(trusted), s.signer.Public())
+		}
+		if err := b.verify(keys); err != nil {
+			return err
+		}
+		if cur := s.bundle; cur != nil && b.manifest.Version < cur.Version && !force {
+			return fmt.Errorf("%w: version %d is older than the applied version %d", ErrBundleDowngrade, b.manifest.Version, cur.Version)
+		}
+		if err := b.apply(s); err != nil {
+			return err
+		}
+		m := b.manifest
+		s.bundle = &m
+		return nil
+	})
+	if err != nil {
+		return BundleManifest{}, err
+	}
+	return b.manifest, nil
+}
+
+func ApplyBundle(data []byte, trusted []ed25519.PublicKey, force bool) (BundleManifest, error) {
+	return defaultAnalyzer.ApplyBundle(data, trusted, force)
+}
+
+// LoadBundleFile applies the bundle at path, as InstallBundleFile writes it.
+func (a *Analyzer) LoadBundleFile(path string, trusted []ed25519.PublicKey) (BundleManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return BundleManifest{}, fmt.Errorf("read bundle: %w", err)
+	}
+	return a.ApplyBundle(data, trusted, false)
+}
+
+func LoadBundleFile(path string, trusted []ed25519.PublicKey) (BundleManifest, error) {
+	return defaultAnalyzer.LoadBundleFile(path, trusted)
+}
+
+// Bundle returns the manifest of the applied bundle, or false when none has
+// been applied.
+func (a *Analyzer) Bundle() (BundleManifest, bool) {
+	b := a.settings().bundle
+	if b == nil {
+		return BundleManifest{}, false
+	}
+	m := *b
+	m.Files = maps.Clone(b.Files)
+	return m, true
+}
+
+func Bundle() (BundleManifest, bool) {
+	return defaultAnalyzer.Bundle()
+}
+
+// ActiveConfig returns the BundleConfig a bundle exported now would carry.
+func (a *Analyzer) ActiveConfig() BundleConfig {
+	return a.settings().bundleConfig()
+}
+
+func ActiveConfig() BundleConfig {
+	return defaultAnalyzer.ActiveConfig()
+}
+
+// Overrides lists the JSON names of the fields of c that differ from prev,
+// in declaration order, so a deployment can report which of its settings a
+// bundle replaced.
+func (c BundleConfig) Overrides(prev BundleConfig) []string {
+	sameReqs := func(a, b ComplaintRequirements) bool {
+		return slices.Equal(a.Required, b.Required) && slices.Equal(a.Recommended, b.Recommended)
+	}
+	var names []string
+	for _, f := range []struct {
+		name    string
+		changed bool
+	}{
+		{"riskThresholds", c.RiskThresholds != prev.RiskThresholds},
+		{"consentRequired", c.ConsentRequired != prev.ConsentRequired},
+		{"consentGrace", c.ConsentGrace != prev.ConsentGrace},
+		{"listConfirmationRequired", c.ListConfirmationRequired != prev.ListConfirmationRequired},
+		{"decisionRevisions", c.DecisionRevisions != prev.DecisionRevisions},
+		{"storeIntakes", c.StoreIntakes != prev.StoreIntakes},
+		{"historyConfidenceCeiling", c.HistoryConfidenceCeiling != prev.HistoryConfidenceCeiling},
+		{"disclaimers", !slices.Equal(c.Disclaimers, prev.Disclaimers)},
+		{"noteHeaders", c.NoteHeaders != prev.NoteHeaders},
+		{"complaintRequirements", !maps.EqualFunc(c.Requirements, prev.Requirements, sameReqs)},
+	} {
+		if f.changed {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+// InstallBundleFile checks data as ApplyBundle would, without changing any
+// Analyzer, and atomically replaces path with it for LoadBundleFile. A
+// bundle with a lower Version than the one already at path fails with
+// ErrBundleDowngrade unless force is set.
+func InstallBundleFile(path string, data []byte, trusted []ed25519.PublicKey, force bool) (BundleManifest, error) {
+	m, err := New().ApplyBundle(data, trusted, true)
+	if err != nil {
+		return BundleManifest{}, err
+	}
+	prev, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return BundleManifest{}, fmt.Errorf("read installed bundle: %w", err)
+	case !force:
+		cur, err := readBundle(prev)
+		if err != nil {
+			return BundleManifest{}, fmt.Errorf("installed bundle %s: %w", path, err)
+		}
+		if m.Version < cur.manifest.Version {
+			return BundleManifest{}, fmt.Errorf("%w: version %d is older than the installed version %d", ErrBundleDowngrade, m.Version, cur.manifest.Version)
+		}
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return BundleManifest{}, fmt.Errorf("install bundle: %w", err)
+	}
+	return m, nil
+}
+
+// bundleHash is a short hash over the member digests of a manifest.
+func bundleHash(files map[string]string) string {
+	var b strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		fmt.Fprintf(&b, "%s %s\n", name, files[name])
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+func (s settings) bundleConfig() BundleConfig {
+	return BundleConfig{
+		RiskThresholds:           s.thresholds,
+		ConsentRequired:          s.consentRequired,
+		ConsentGrace:             s.consentGrace,
+		ListConfirmationRequired: s.listConfirmation,
+		DecisionRevisions:        s.decisionRevisions,
+		StoreIntakes:             s.storeIntakes,
+		HistoryConfidenceCeiling: s.historyCeiling,
+		Disclaimers:              append([]string{}, s.disclaimers...),
+		NoteHeaders:              s.noteHeaders,
+		Requirements:             s.requirements,
+	}
+}
+
+// bundle is a read archive whose members match its manifest.
+type bundle struct {
+	manifest BundleManifest
+	sig      Signature
+	files    map[string][]byte
+}
+
+// readBundle unpacks data and checks its members against the manifest. It
+// does not check the signature.
+func readBundle(data []byte) (*bundle, error) {
+	if len(data) > maxBundleBytes {
+		return nil, fmt.Errorf("%w: larger than %d bytes", ErrInvalidBundle, maxBundleBytes)
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	tr := tar.NewReader(gz)
+	files := map[string][]byte{}
+	size := 0
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+		}
+		if hdr.Typeflag != tar.TypeReg || !slices.Contains(bundleMembers, hdr.Name) {
+			return nil, fmt.Errorf("%w: unexpected member %q", ErrInvalidBundle, hdr.Name)
+		}
+		if _, dup := files[hdr.Name]; dup {
+			return nil, fmt.Errorf("%w: %s appears twice", ErrInvalidBundle, hdr.Name)
+		}
+		b, err := io.ReadAll(io.LimitReader(tr, int64(maxBundleBytes-size+1)))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+		}
+		if size += len(b); size > maxBundleBytes {
+			return nil, fmt.Errorf("%w: unpacks to more than %d bytes", ErrInvalidBundle, maxBundleBytes)
+		}
+		files[hdr.Name] = b
+	}
+	for _, name := range bundleMembers {
+		if _, ok := files[name]; !ok {
+			return nil, fmt.Errorf("%w: missing %s", ErrInvalidBundle, name)
+		}
+	}
+
+	b := &bundle{files: files}
+	if err := json.Unmarshal(files[bundleManifest], &b.manifest); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidBundle, bundleManifest, err)
+	}
+	m := b.manifest
+	switch {
+	case m.Format != BundleFormat:
+		return nil, fmt.Errorf("%w: format %d is not %d", ErrInvalidBundle, m.Format, BundleFormat)
+	case m.Version <= 0:
+		return nil, fmt.Errorf("%w: version must be positive", ErrInvalidBundle)
+	case len(m.Files) != len(bundleContents):
+		return nil, fmt.Errorf("%w: manifest lists %d files, want %d", ErrInvalidBundle, len(m.Files), len(bundleContents))
+	case m.Hash != bundleHash(m.Files):
+		return nil, fmt.Errorf("%w: manifest hash does not match its files", ErrInvalidBundle)
+	}
+	for _, name := range bundleContents {
+		sum := sha256.Sum256(files[name])
+		if m.Files[name] != hex.EncodeToString(sum[:]) {
+			return nil, fmt.Errorf("%w: %s does not match the manifest", ErrInvalidBundle, name)
+		}
+	}
+	if err := json.Unmarshal(files[bundleSignature], &b.sig); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidBundle, bundleSignature, err)
+	}
+	return b, nil
+}
+
+// verify checks the manifest signature against each of keys.
+func (b *bundle) verify(keys []ed25519.PublicKey) error {
+	if b.sig.Algorithm != signing.Algorithm {
+		return fmt.Errorf("%w: algorithm %q", ErrBundleUntrusted, b.sig.Algorithm)
+	}
+	for _, pub := range keys {
+		if signing.Verify(pub, b.files[bundleManifest], b.sig.Value) == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: signed by key %s", ErrBundleUntrusted, b.sig.KeyID)
+}
+
+// apply validates the members of b and installs them in s, which the caller
+// discards on error.
+func (b *bundle) apply(s *settings) error {
+	source := fmt.Sprintf("bundle v%d (%s)", b.manifest.Version, b.manifest.Hash)
+	invalid := func(name string, err error) error {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidBundle, name, err)
+	}
+
+	var cfg BundleConfig
+	if err := json.Unmarshal(b.files[bundleConfig], &cfg); err != nil {
+		return invalid(bundleConfig, err)
+	}
+	if err := cfg.RiskThresholds.Validate(); err != nil {
+		return invalid(bundleConfig, err)
+	}
+	if c := cfg.HistoryConfidenceCeiling; c <= 0 || c > 1 {
+		return invalid(bundleConfig, fmt.Errorf("history confidence ceiling %.2f must be within (0, 1]", c))
+	}
+	headers, err := cfg.NoteHeaders.withDefaults()
+	if err != nil {
+		return invalid(bundleConfig, err)
+	}
+	// Config without a field keeps the built-in value, as an unset
+	// environment variable does.
+	reqs := complaintRequirements
+	if cfg.Requirements != nil {
+		reqs = make(map[string]ComplaintRequirements, len(cfg.Requirements))
+	}
+	for complaint, r := range cfg.Requirements {
+		if err := r.Validate(); err != nil {
+			return invalid(bundleConfig, fmt.Errorf("complaint %q: %v", complaint, err))
+		}
+		reqs[normalizeName(complaint)] = r
+	}
+	if cfg.Disclaimers == nil {
+		cfg.Disclaimers = DefaultDisclaimers
+	}
+
+	var r Ruleset
+	if err := json.Unmarshal(b.files[bundleRules], &r); err != nil {
+		return invalid(bundleRules, err)
+	}
+	if err := s.setRuleset(r, source); err != nil {
+		return invalid(bundleRules, err)
+	}
+	// The prompt quotes the thresholds, so set them before rendering it.
+	s.thresholds = cfg.RiskThresholds
+	if err := s.setPrompt(string(b.files[bundlePrompt]), source); err != nil {
+		return invalid(bundlePrompt, err)
+	}
+	if s.education, err = parseEducation(b.files[bundleEducation], source); err != nil {
+		return invalid(bundleEducation, err)
+	}
+	if s.triage, err = parseTriage(b.files[bundleTriage], source); err != nil {
+		return invalid(bundleTriage, err)
+	}
+
+	s.consentRequired = cfg.ConsentRequired
+	s.consentGrace = cfg.ConsentGrace
+	s.listConfirmation = cfg.ListConfirmationRequired
+	s.decisionRevisions = cfg.DecisionRevisions
+	s.storeIntakes = cfg.StoreIntakes
+	s.historyCeiling = cfg.HistoryConfidenceCeiling
+	s.disclaimers = slices.Clone(cfg.Disclaimers)
+	s.noteHeaders = headers
+	s.requirements = reqs
+	return nil
+}
@@ -0,0 +1,276 @@
+package analysis
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/signing"
+)
+
+func bundleKey(seed byte) ed25519.PrivateKey {
+	return ed25519.NewKeyFromSeed(bytes.Repeat([]byte{seed}, ed25519.SeedSize))
+}
+
+// exporter returns an Analyzer that signs bundles with bundleKey(1).
+func exporter(t *testing.T) *Analyzer {
+	t.Helper()
+	a := New(WithClock(fixedClock))
+	if err := a.SetSigningKey("bundles", bundleKey(1)); err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func exportBundle(t *testing.T, a *Analyzer, version int) []byte {
+	t.Helper()
+	data, _, err := a.ExportBundle(version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// repack rewrites the members of bundle data with edit, updating the
+// manifest to match, and re-signs it with key unless key is nil.
+func repack(t *testing.T, data []byte, key ed25519.PrivateKey, edit func(files map[string][]byte)) []byte {
+	t.Helper()
+	b, err := readBundle(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edit(b.files)
+	for _, name := range bundleContents {
+		sum := sha256.Sum256(b.files[name])
+		b.manifest.Files[name] = hex.EncodeToString(sum[:])
+	}
+	b.manifest.Hash = bundleHash(b.manifest.Files)
+	if b.files[bundleManifest], err = json.Marshal(b.manifest); err != nil {
+		t.Fatal(err)
+	}
+	if key != nil {
+		k, _ := signing.NewKey("", key)
+		value, err := k.Sign(b.files[bundleManifest])
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.files[bundleSignature], _ = json.Marshal(Signature{KeyID: k.ID(), Algorithm: signing.Algorithm, Value: value})
+	}
+	return pack(b.files)
+}
+
+// pack writes files as a bundle archive, leaving the manifest as it is.
+func pack(files map[string][]byte) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range bundleMembers {
+		if _, ok := files[name]; !ok {
+			continue
+		}
+		tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0o644, Size: int64(len(files[name]))})
+		tw.Write(files[name])
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestBundle_RoundTrip(t *testing.T) {
+	src := exporter(t)
+	rules := src.Rules().Ruleset
+	rules.DrugClasses = append(rules.DrugClasses, DrugClass{Name: "test class", Members: []string{"examplazine"}})
+	if err := src.SetRuleset(rules, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.SetRiskThresholds(RiskThresholds{Medium: 5, High: 9}); err != nil {
+		t.Fatal(err)
+	}
+	src.SetDisclaimers([]string{"Reviewed by the clinic."})
+	src.SetListConfirmationRequired(true)
+
+	data, m, err := src.ExportBundle(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Version != 1 || m.Format != BundleFormat || len(m.Hash) != 12 || m.RulesetVersion != src.RulesetVersion() || !m.CreatedAt.Equal(fixedClock()) {
+		t.Fatalf("manifest = %+v", m)
+	}
+	if bytes.Contains(data, bundleKey(1).Seed()) {
+		t.Fatal("bundle carries the signing key")
+	}
+
+	dst := New()
+	before := dst.RulesetVersion()
+	got, err := dst.ApplyBundle(data, []ed25519.PublicKey{bundleKey(1).Public().(ed25519.PublicKey)}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Hash != m.Hash {
+		t.Fatalf("applied manifest = %+v", got)
+	}
+	if dst.Rules().Version != src.Rules().Version || !strings.HasPrefix(dst.Rules().Source, "bundle v1 ") {
+		t.Fatalf("rules = %s from %s, want %s", dst.Rules().Version, dst.Rules().Source, src.Rules().Version)
+	}
+	if dst.PromptVersion() != src.PromptVersion() || dst.RiskThresholds() != (RiskThresholds{Medium: 5, High: 9}) {
+		t.Fatalf("prompt %s thresholds %+v", dst.PromptVersion(), dst.RiskThresholds())
+	}
+	if !slices.Equal(dst.Disclaimers(), []string{"Reviewed by the clinic."}) || !dst.settings().listConfirmation {
+		t.Fatal("config not applied")
+	}
+	// The rules and prompt match the source, so only the bundle hash tells
+	// the two deployments apart.
+	if v := dst.RulesetVersion(); v == before || v != combinedVersion(src.Rules().Version, src.PromptVersion(), BuildVersion(), m.Hash) {
+		t.Fatalf("ruleset version = %s", v)
+	}
+	if b, ok := dst.Bundle(); !ok || b.Version != 1 || b.Hash != m.Hash {
+		t.Fatalf("Bundle() = %+v, %v", b, ok)
+	}
+}
+
+func TestApplyBundle_Downgrade(t *testing.T) {
+	src := exporter(t)
+	dst := New()
+	trusted := []ed25519.PublicKey{bundleKey(1).Public().(ed25519.PublicKey)}
+	if _, err := dst.ApplyBundle(exportBundle(t, src, 3), trusted, false); err != nil {
+		t.Fatal(err)
+	}
+	version := dst.RulesetVersion()
+
+	older := exportBundle(t, src, 2)
+	if _, err := dst.ApplyBundle(older, trusted, false); !errors.Is(err, ErrBundleDowngrade) {
+		t.Fatalf("older bundle: %v, want ErrBundleDowngrade", err)
+	}
+	if b, _ := dst.Bundle(); b.Version != 3 || dst.RulesetVersion() != version {
+		t.Fatalf("refused downgrade changed the bundle to %+v", b)
+	}
+	if _, err := dst.ApplyBundle(older, trusted, true); err != nil {
+		t.Fatalf("forced downgrade: %v", err)
+	}
+	if b, _ := dst.Bundle(); b.Version != 2 {
+		t.Fatalf("forced downgrade left version %d", b.Version)
+	}
+	// The next export from dst numbers itself past the applied bundle.
+	if err := dst.SetSigningKey("", bundleKey(2)); err != nil {
+		t.Fatal(err)
+	}
+	if _, m, err := dst.ExportBundle(0); err != nil || m.Version != 3 {
+		t.Fatalf("export after v2: version %d, %v", m.Version, err)
+	}
+}
+
+func TestApplyBundle_Rejects(t *testing.T) {
+	src := exporter(t)
+	data := exportBundle(t, src, 1)
+	trusted := []ed25519.PublicKey{bundleKey(1).Public().(ed25519.PublicKey)}
+	setThresholds := func(files map[string][]byte) {
+		files[bundleConfig] = bytes.Replace(files[bundleConfig], []byte(`"high": 8`), []byte(`"high": 2`), 1)
+	}
+	for _, c := range []struct {
+		name    string
+		data    []byte
+		trusted []ed25519.PublicKey
+		want    error
+	}{
+		{"not an archive", []byte("rules"), trusted, ErrInvalidBundle},
+		{"no trusted key", data, nil, ErrBundleUntrusted},
+		{"other key", data, []ed25519.PublicKey{bundleKey(3).Public().(ed25519.PublicKey)}, ErrBundleUntrusted},
+		{"edited after signing", repack(t, data, nil, setThresholds), trusted, ErrBundleUntrusted},
+		{"signed invalid config", repack(t, data, bundleKey(1), setThresholds), trusted, ErrInvalidBundle},
+		{"signed invalid prompt", repack(t, data, bundleKey(1), func(files map[string][]byte) {
+			files[bundlePrompt] = []byte("{{.Nope}}")
+		}), trusted, ErrInvalidBundle},
+	} {
+		dst := New()
+		version := dst.RulesetVersion()
+		if _, err := dst.ApplyBundle(c.data, c.trusted, false); !errors.Is(err, c.want) {
+			t.Errorf("%s: %v, want %v", c.name, err, c.want)
+		}
+		if _, ok := dst.Bundle(); ok || dst.RulesetVersion() != version || dst.RiskThresholds() != DefaultRiskThresholds {
+			t.Errorf("%s: rejected bundle changed the analyzer", c.name)
+		}
+	}
+
+	// The deployment's own signing key is trusted without being listed.
+	if _, err := src.ApplyBundle(data, nil, false); err != nil {
+		t.Fatalf("own bundle: %v", err)
+	}
+}
+
+func TestReadBundle_Digests(t *testing.T) {
+	data := exportBundle(t, exporter(t), 1)
+	b, _ := readBundle(data)
+	b.files[bundleRules] = append(b.files[bundleRules], ' ')
+	if _, err := readBundle(pack(b.files)); !errors.Is(err, ErrInvalidBundle) || !strings.Contains(err.Error(), bundleRules) {
+		t.Fatalf("tampered member: %v", err)
+	}
+	b, _ = readBundle(data)
+	delete(b.files, bundleTriage)
+	if _, err := readBundle(pack(b.files)); !errors.Is(err, ErrInvalidBundle) || !strings.Contains(err.Error(), "missing "+bundleTriage) {
+		t.Fatalf("missing member: %v", err)
+	}
+}
+
+func TestExportBundle_Unsigned(t *testing.T) {
+	if _, _, err := New().ExportBundle(1); !errors.Is(err, ErrBundleUnsigned) {
+		t.Fatalf("err = %v, want ErrBundleUnsigned", err)
+	}
+}
+
+func TestInstallBundleFile(t *testing.T) {
+	src := exporter(t)
+	trusted := []ed25519.PublicKey{bundleKey(1).Public().(ed25519.PublicKey)}
+	path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if _, err := InstallBundleFile(path, exportBundle(t, src, 2), trusted, false); err != nil {
+		t.Fatal(err)
+	}
+	installed, _ := os.ReadFile(path)
+
+	if _, err := InstallBundleFile(path, exportBundle(t, src, 1), trusted, false); !errors.Is(err, ErrBundleDowngrade) {
+		t.Fatalf("older bundle: %v, want ErrBundleDowngrade", err)
+	}
+	if _, err := InstallBundleFile(path, []byte("junk"), trusted, true); !errors.Is(err, ErrInvalidBundle) {
+		t.Fatalf("junk: %v", err)
+	}
+	if now, _ := os.ReadFile(path); !bytes.Equal(now, installed) {
+		t.Fatal("refused install replaced the file")
+	}
+
+	a := New()
+	m, err := a.LoadBundleFile(path, trusted)
+	if err != nil || m.Version != 2 {
+		t.Fatalf("load: %+v, %v", m, err)
+	}
+	if _, err := InstallBundleFile(path, exportBundle(t, src, 1), trusted, true); err != nil {
+		t.Fatalf("forced install: %v", err)
+	}
+	if m, _ := New().LoadBundleFile(path, trusted); m.Version != 1 {
+		t.Fatalf("forced install left version %d", m.Version)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Fatalf("install left %d files behind", len(entries))
+	}
+}
+
+func TestBundleConfig_Overrides(t *testing.T) {
+	a := New()
+	before := a.ActiveConfig()
+	if o := a.ActiveConfig().Overrides(before); o != nil {
+		t.Fatalf("unchanged config overrides %v", o)
+	}
+	a.SetConsentRequired(true)
+	a.SetDisclaimers([]string{})
+	if o := a.ActiveConfig().Overrides(before); !slices.Equal(o, []string{"consentRequired", "disclaimers"}) {
+		t.Fatalf("overrides = %v", o)
+	}
+}
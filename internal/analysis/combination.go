@@ -0,0 +1,135 @@
+package analysis
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// combinationBrands maps combination products sold under a brand name to
+// their component generics.
+var combinationBrands = map[string][]string{
+	"entresto":   {"sacubitril", "valsartan"},
+	"zestoretic": {"lisinopril", "hydrochlorothiazide"},
+	"hyzaar":     {"losartan", "hydrochlorothiazide"},
+	"exforge":    {"amlodipine", "valsartan"},
+	"lotrel":     {"amlodipine", "benazepril"},
+	"caduet":     {"amlodipine", "atorvastatin"},
+	"vytorin":    {"ezetimibe", "simvastatin"},
+	"janumet":    {"sitagliptin", "metformin"},
+	"jalyn":      {"dutasteride", "tamsulosin"},
+}
+
+// ingredientAliases expands abbreviations used inside combination names.
+var ingredientAliases = map[string]string{
+	"hctz": "hydrochlorothiazide",
+	"hct":  "hydrochlorothiazide",
+}
+
+// hyphenatedProducts are single products whose names contain a combination
+// separator, so they are never split.
+var hyphenatedProducts = []string{
+	"co-codamol", "co-amoxiclav", "co-trimoxazole", "co-careldopa", "co-beneldopa", "co-dydramol", "co-magaldrox",
+}
+
+// formulationSuffixes are release and salt qualifiers that follow a
+// separator without naming a second drug, as in "metformin-ER".
+var formulationSuffixes = []string{"er", "xr", "sr", "xl", "la", "cr", "dr", "ir", "cd", "odt", "hcl"}
+
+var (
+	combinationSeparator = regexp.MustCompile(`\s*[/+-]\s*`)
+	// combinationDose matches per-component milligram amounts, e.g.
+	// "20-12.5mg" or "49/51 mg".
+	combinationDose = regexp.MustCompile(`(?i)(\d+(?:\.\d+)?(?:\s*[/+-]\s*\d+(?:\.\d+)?)+)\s*mg`)
+)
+
+// medComponent is one generic in a medication entry. DoseMg is 0 when the
+// entry gives no milligram amount for it.
+type medComponent struct {
+	Name   string
+	DoseMg float64
+}
+
+// medicationComponents splits a combination product, named with /, -, or +
+// between generics or by a known brand, into its components, each with its
+// dose when the entry lists one amount per component. Any other entry is a
+// single component, its lower-cased name. A split is only made when every
+// part is a plausible drug name, at least three letters and not a
+// formulation suffix, and one of them is a generic the engine knows, so
+// "alpha-lipoic acid" stays whole.
+func medicationComponents(m Medication) []medComponent {
+	name := normalizeName(m.Name)
+	if name == "" {
+		return nil
+	}
+	single := []medComponent{{Name: name, DoseMg: extractMg(m.Dosage)}}
+	drug, dose := name, m.Dosage
+	if i := strings.IndexFunc(name, func(r rune) bool { return r >= '0' && r <= '9' }); i > 0 {
+		drug, dose = strings.TrimSpace(name[:i]), name[i:]+" "+m.Dosage
+	}
+	if slices.ContainsFunc(hyphenatedProducts, func(p string) bool { return strings.Contains(drug, p) }) {
+		return single
+	}
+	parts, ok := combinationBrands[strings.Fields(drug)[0]]
+	if !ok {
+		parts = combinationSeparator.Split(drug, -1)
+		if len(parts) < 2 || !slices.ContainsFunc(parts, knownIngredient) || slices.ContainsFunc(parts, func(p string) bool {
+			return len(p) < 3 || slices.Contains(formulationSuffixes, strings.Fields(p)[0])
+		}) {
+			return single
+		}
+	}
+	doses := componentDoses(dose, len(parts))
+	out := make([]medComponent, 0, len(parts))
+	for i, p := range parts {
+		p = strings.TrimSpace(p)
+		if alias, ok := ingredientAliases[p]; ok {
+			p = alias
+		}
+		c := medComponent{Name: p}
+		if doses != nil {
+			c.DoseMg = doses[i]
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// knownIngredient reports whether p names a member of a built-in drug class
+// or a combination component.
+func knownIngredient(p string) bool {
+	p = strings.TrimSpace(p)
+	if _, ok := ingredientAliases[p]; ok {
+		return true
+	}
+	for _, c := range drugClasses {
+		if slices.Contains(c.Members, p) {
+			return true
+		}
+	}
+	for _, parts := range combinationBrands {
+		if slices.Contains(parts, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// componentDoses parses n per-component milligram amounts from dose, or
+// returns nil when it does not list exactly n.
+func componentDoses(dose string, n int) []float64 {
+	m := combinationDose.FindStringSubmatch(dose)
+	if m == nil {
+		return nil
+	}
+	amounts := combinationSeparator.Split(m[1], -1)
+	if len(amounts) != n {
+		return nil
+	}
+	out := make([]float64, n)
+	for i, a := range amounts {
+		out[i], _ = strconv.ParseFloat(a, 64)
+	}
+	return out
+}
@@ -0,0 +1,71 @@
+package analysis
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestMedicationComponents(t *testing.T) {
+	tests := []struct {
+		med  Medication
+		want []medComponent
+	}{
+		{Medication{Name: "lisinopril/HCTZ 20-12.5mg"}, []medComponent{{"lisinopril", 20}, {"hydrochlorothiazide", 12.5}}},
+		{Medication{Name: "Sacubitril-Valsartan", Dosage: "49/51 mg"}, []medComponent{{"sacubitril", 49}, {"valsartan", 51}}},
+		{Medication{Name: "amlodipine + atorvastatin", Dosage: "5mg"}, []medComponent{{"amlodipine", 0}, {"atorvastatin", 0}}},
+		{Medication{Name: "Entresto", Dosage: "24/26mg"}, []medComponent{{"sacubitril", 24}, {"valsartan", 26}}},
+		{Medication{Name: "Hyzaar"}, []medComponent{{"losartan", 0}, {"hydrochlorothiazide", 0}}},
+		// Hyphenated single products and formulation suffixes stay whole.
+		{Medication{Name: "Co-codamol 30/500mg"}, []medComponent{{"co-codamol 30/500mg", 0}}},
+		{Medication{Name: "Co-amoxiclav"}, []medComponent{{"co-amoxiclav", 0}}},
+		{Medication{Name: "Metformin-ER", Dosage: "500mg"}, []medComponent{{"metformin-er", 500}}},
+		{Medication{Name: "alpha-lipoic acid"}, []medComponent{{"alpha-lipoic acid", 0}}},
+		{Medication{Name: "Vitamin B-12"}, []medComponent{{"vitamin b-12", 0}}},
+		{Medication{Name: " Sildenafil ", Dosage: "50mg"}, []medComponent{{"sildenafil", 50}}},
+		{Medication{Name: " "}, nil},
+	}
+	for _, tt := range tests {
+		if got := medicationComponents(tt.med); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("medicationComponents(%+v) = %+v, want %+v", tt.med, got, tt.want)
+		}
+	}
+}
+
+func TestAnalyze_CombinationComponentsChecked(t *testing.T) {
+	in := Intake{
+		PatientName: "Combo", Age: 55, WeightKg: 82, HeightCm: 178, BP: "128/82", Complaint: "ED", ConfirmedNoConditions: true,
+		Medications: []Medication{{Name: "amlodipine/valsartan 5/160mg"}, {Name: "Caduet"}, {Name: "simvastatin", Dosage: "20mg"}},
+	}
+	resp := AnalyzeWithOptions(in, Options{IncludeNormalized: true})
+	if len(resp.ValidationErrors) > 0 {
+		t.Fatal(resp.ValidationErrors)
+	}
+	codes := issueCodes(resp.FlaggedIssues)
+	for _, want := range []string{"DDI_PDE5_AMLODIPINE", "DDI_AMLODIPINE_SIMVASTATIN"} {
+		if !slices.Contains(codes, want) {
+			t.Errorf("%s not flagged: %v", want, codes)
+		}
+	}
+	m := resp.NormalizedIntake.Medications[0]
+	want := []NormalizedComponent{
+		{Name: "amlodipine", DoseMg: 5, Classes: []string{"calcium channel blocker"}},
+		{Name: "valsartan", DoseMg: 160, Classes: []string{"angiotensin receptor blocker"}},
+	}
+	if !reflect.DeepEqual(m.Components, want) {
+		t.Errorf("components = %+v", m.Components)
+	}
+	if c := resp.NormalizedIntake.Medications[2].Components; c != nil {
+		t.Errorf("single drug components = %+v", c)
+	}
+
+	r := New().CheckInteractions(InteractionRequest{Medications: in.Medications}, Options{})
+	if codes := pairCodes(r)["atorvastatin+simvastatin"]; !slices.Contains(codes, "DUP_THERAPY") {
+		t.Errorf("atorvastatin+simvastatin codes = %v, want DUP_THERAPY", codes)
+	}
+
+	p := previewIntake(in)
+	if want := []string{"amlodipine", "valsartan", "atorvastatin", "simvastatin"}; !slices.Equal(p.Medications, want) {
+		t.Errorf("preview medications = %v, want %v", p.Medications, want)
+	}
+}
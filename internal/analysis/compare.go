@@ -0,0 +1,132 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+// ErrPatientMismatch is returned by CompareAudits for audits of different
+// patients, or when either patient cannot be told, unless forced.
+var ErrPatientMismatch = errors.New("audits are not of the same patient")
+
+// CompareAudits reports how audit b differs from audit a, from their stored
+// responses and, when kept, intakes; an audit without a stored intake gets
+// no BP delta. Unless force, both audits must be of the same known patient.
+// Errors are audit.ErrNotFound, ErrPatientMismatch, or
+// ErrResponsesUnsupported.
+func (a *Analyzer) CompareAudits(ctx context.Context, auditA, auditB string, force bool) (AuditComparison, error) {
+	var sides [2]ComparedAudit
+	var resps [2]Response
+	var intakes [2]*Intake
+	for i, id := range []string{auditA, auditB} {
+		resp, err := a.AuditResponse(ctx, id)
+		if err != nil {
+			return AuditComparison{}, err
+		}
+		resps[i] = resp
+		sides[i] = ComparedAudit{AuditID: id, At: resp.AuditAt}
+		stored, err := a.storedIntake(ctx, id)
+		switch {
+		case err == nil:
+			intakes[i] = &stored.Intake
+			sides[i].PatientRef = stored.PatientRef
+		case !errors.Is(err, ErrNoIntake) && !errors.Is(err, ErrIntakesUnsupported):
+			return AuditComparison{}, err
+		}
+		if reader, ok := a.settings().store.(audit.SummaryReader); ok {
+			sum, err := reader.Summary(ctx, id)
+			if err != nil {
+				return AuditComparison{}, err
+			}
+			sides[i].PatientRef = sum.PatientRef
+		}
+	}
+	same := sides[0].PatientRef != "" && sides[0].PatientRef == sides[1].PatientRef
+	if !same && !force {
+		return AuditComparison{}, ErrPatientMismatch
+	}
+	return AuditComparison{
+		A:           sides[0],
+		B:           sides[1],
+		SamePatient: same,
+		Diff:        diffAnalyses(resps[0], resps[1], intakes[0], intakes[1]),
+	}, nil
+}
+
+func CompareAudits(ctx context.Context, auditA, auditB string, force bool) (AuditComparison, error) {
+	return defaultAnalyzer.CompareAudits(ctx, auditA, auditB, force)
+}
+
+// diffAnalyses reports how after differs from before, for the what-if,
+// re-analysis, and audit comparison endpoints. The intakes are optional and
+// only feed the BP delta.
+func diffAnalyses(before, after Response, beforeIn, afterIn *Intake) AnalysisDiff {
+	d := AnalysisDiff{
+		RiskScoreBefore: before.RiskScore,
+		RiskScoreAfter:  after.RiskScore,
+		RiskScoreDelta:  after.RiskScore - before.RiskScore,
+		RiskLevelBefore: before.RiskLevel,
+		RiskLevelAfter:  after.RiskLevel,
+		IssuesAdded:     []Issue{},
+		IssuesRemoved:   []Issue{},
+		IssuesChanged:   []IssueChange{},
+	}
+	prior := make(map[string]Issue, len(before.FlaggedIssues))
+	for _, is := range before.FlaggedIssues {
+		prior[diffKey(is)] = is
+	}
+	current := make(map[string]bool, len(after.FlaggedIssues))
+	for _, is := range after.FlaggedIssues {
+		k := diffKey(is)
+		current[k] = true
+		was, ok := prior[k]
+		switch {
+		case !ok:
+			d.IssuesAdded = append(d.IssuesAdded, is)
+		case was.Severity != is.Severity:
+			d.IssuesChanged = append(d.IssuesChanged, IssueChange{Before: was, After: is})
+		}
+	}
+	for _, is := range before.FlaggedIssues {
+		if !current[diffKey(is)] {
+			d.IssuesRemoved = append(d.IssuesRemoved, is)
+		}
+	}
+	if prescription(before.RecommendedPlan) != prescription(after.RecommendedPlan) {
+		d.PlanChanged = true
+		d.PlanBefore, d.PlanAfter = &before.RecommendedPlan, &after.RecommendedPlan
+	}
+	if before.ComputedBMI > 0 && after.ComputedBMI > 0 {
+		b, a := round1(before.ComputedBMI), round1(after.ComputedBMI)
+		d.BMI = &BMIDelta{Before: b, After: a, Delta: round1(a - b)}
+	}
+	if beforeIn != nil && afterIn != nil && beforeIn.BP != "" && afterIn.BP != "" {
+		bs, bd, errB := parseBP(beforeIn.BP)
+		as, ad, errA := parseBP(afterIn.BP)
+		if errB == nil && errA == nil {
+			d.BP = &BPDelta{Before: beforeIn.BP, After: afterIn.BP, SystolicDelta: as - bs, DiastolicDelta: ad - bd}
+		}
+	}
+	return d
+}
+
+// diffKey identifies an issue across analyses by code and related
+// medications, so a reworded description is not a change.
+func diffKey(is Issue) string {
+	return is.Code + "|" + strings.Join(is.RelatedMedications, ",")
+}
+
+// prescription strips the advice around a plan (rationale, monitoring, and
+// follow-up), leaving what counts as a plan change: audits stored before a
+// plan gained monitoring advice would otherwise all report one.
+func prescription(p Plan) [4]string {
+	return [4]string{p.Medication, p.Dosage, p.Frequency, p.Duration}
+}
+
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
@@ -0,0 +1,139 @@
+package analysis
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+func TestDiffAnalyses(t *testing.T) {
+	nitrate := Issue{Code: "CI_NITRATE_PDE5", Severity: SeverityDanger, RelatedMedications: []string{"nitroglycerin"}, Description: "before"}
+	bp := Issue{Code: "BP_STAGE2", Severity: SeverityDanger, Description: "Stage 2"}
+	bpMild := Issue{Code: "BP_STAGE2", Severity: SeverityWarning, Description: "Stage 2"}
+	reworded := nitrate
+	reworded.Description = "after"
+	age := Issue{Code: "AGE_65_PLUS", Severity: SeverityWarning}
+	plan := Plan{Medication: "Sildenafil", Dosage: "50mg", Rationale: "first-line"}
+	advised := plan
+	advised.Rationale, advised.Monitoring = "reworded", []string{"BP"}
+	tadalafil := Plan{Medication: "Tadalafil", Dosage: "10mg"}
+
+	for _, tt := range []struct {
+		name                    string
+		before, after           Response
+		added, removed, changed []string
+		planChanged             bool
+	}{
+		{
+			name:   "added",
+			before: Response{FlaggedIssues: []Issue{nitrate}},
+			after:  Response{FlaggedIssues: []Issue{nitrate, age}},
+			added:  []string{"AGE_65_PLUS"},
+		},
+		{
+			name:    "removed",
+			before:  Response{FlaggedIssues: []Issue{nitrate, bp}},
+			after:   Response{FlaggedIssues: []Issue{bp}},
+			removed: []string{"CI_NITRATE_PDE5"},
+		},
+		{
+			name:    "severity changed",
+			before:  Response{FlaggedIssues: []Issue{nitrate, bp}},
+			after:   Response{FlaggedIssues: []Issue{nitrate, bpMild}},
+			changed: []string{"BP_STAGE2"},
+		},
+		{
+			name:   "reworded is not a change",
+			before: Response{FlaggedIssues: []Issue{nitrate}, RecommendedPlan: plan},
+			after:  Response{FlaggedIssues: []Issue{reworded}, RecommendedPlan: advised},
+		},
+		{
+			name:        "plan changed",
+			before:      Response{RecommendedPlan: plan},
+			after:       Response{RecommendedPlan: tadalafil},
+			planChanged: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			d := diffAnalyses(tt.before, tt.after, nil, nil)
+			codes := func(issues []Issue) []string {
+				out := []string{}
+				for _, is := range issues {
+					out = append(out, is.Code)
+				}
+				return out
+			}
+			var changed []string
+			for _, c := range d.IssuesChanged {
+				if c.Before.Severity == c.After.Severity {
+					t.Errorf("change %s keeps severity %s", c.Before.Code, c.Before.Severity)
+				}
+				changed = append(changed, c.After.Code)
+			}
+			if got, want := codes(d.IssuesAdded), append([]string{}, tt.added...); !reflect.DeepEqual(got, want) {
+				t.Errorf("added = %v, want %v", got, want)
+			}
+			if got, want := codes(d.IssuesRemoved), append([]string{}, tt.removed...); !reflect.DeepEqual(got, want) {
+				t.Errorf("removed = %v, want %v", got, want)
+			}
+			if !reflect.DeepEqual(changed, tt.changed) {
+				t.Errorf("changed = %v, want %v", changed, tt.changed)
+			}
+			if d.PlanChanged != tt.planChanged || (d.PlanBefore != nil) != tt.planChanged {
+				t.Errorf("plan changed = %t (before %v), want %t", d.PlanChanged, d.PlanBefore, tt.planChanged)
+			}
+			if d.BMI != nil || d.BP != nil {
+				t.Errorf("BMI %v, BP %v without measurements", d.BMI, d.BP)
+			}
+		})
+	}
+}
+
+func TestDiffAnalyses_Vitals(t *testing.T) {
+	before, after := Response{ComputedBMI: 29.74}, Response{ComputedBMI: 27.66}
+	d := diffAnalyses(before, after, &Intake{BP: "150/95"}, &Intake{BP: "132 / 84"})
+	if want := (BMIDelta{Before: 29.7, After: 27.7, Delta: -2}); d.BMI == nil || *d.BMI != want {
+		t.Errorf("BMI = %+v, want %+v", d.BMI, want)
+	}
+	if want := (BPDelta{Before: "150/95", After: "132 / 84", SystolicDelta: -18, DiastolicDelta: -11}); d.BP == nil || *d.BP != want {
+		t.Errorf("BP = %+v, want %+v", d.BP, want)
+	}
+	if d := diffAnalyses(before, after, &Intake{BP: "150/95"}, &Intake{BP: "high"}); d.BP != nil {
+		t.Errorf("unreadable BP diffed: %+v", d.BP)
+	}
+}
+
+func TestCompareAudits(t *testing.T) {
+	a := New(WithAuditStore(audit.NewMemoryStore()))
+	first := a.Analyze(nitrateIntake)
+	later := nitrateIntake
+	later.BP = "130/85"
+	later.Medications = later.Medications[:1]
+	second := a.Analyze(later)
+	other := nitrateIntake
+	other.PatientName = "Someone Else"
+	stranger := a.Analyze(other)
+
+	got, err := a.CompareAudits(t.Context(), first.AuditID, second.AuditID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.SamePatient || got.A.PatientRef != a.PatientRef(nitrateIntake.PatientName) || got.A.At != first.AuditAt {
+		t.Errorf("sides = %+v, %+v", got.A, got.B)
+	}
+	if got.Diff.RiskScoreDelta >= 0 || len(got.Diff.IssuesRemoved) == 0 || got.Diff.BP == nil || got.Diff.BP.SystolicDelta != -20 {
+		t.Errorf("diff = %+v", got.Diff)
+	}
+
+	if _, err := a.CompareAudits(t.Context(), first.AuditID, stranger.AuditID, false); !errors.Is(err, ErrPatientMismatch) {
+		t.Errorf("different patients: %v, want ErrPatientMismatch", err)
+	}
+	if got, err := a.CompareAudits(t.Context(), first.AuditID, stranger.AuditID, true); err != nil || got.SamePatient {
+		t.Errorf("forced: %+v, %v", got, err)
+	}
+	if _, err := a.CompareAudits(t.Context(), first.AuditID, "missing", true); !errors.Is(err, audit.ErrNotFound) {
+		t.Errorf("missing audit: %v, want audit.ErrNotFound", err)
+	}
+}
@@ -0,0 +1,92 @@
+package analysis
+
+import (
+	"fmt"
+	"slices"
+)
+
+// RequireAllergies names the allergy list among the history sections; unlike
+// the lists in requirableFields no complaint requires it.
+const RequireAllergies = "allergies"
+
+// DefaultHistoryConfidenceCeiling caps plan confidence while a history
+// section is left empty without being confirmed as none.
+const DefaultHistoryConfidenceCeiling = 0.6
+
+// unconfirmedSections lists the history sections an intake leaves empty
+// without confirmedNoConditions, confirmedNoMedications, or
+// confirmedNoAllergies, in that order. An empty list alone does not tell a
+// patient with nothing to report from a question never asked.
+func unconfirmedSections(in Intake) []string {
+	var out []string
+	for _, section := range historySections {
+		if n, none := historyEntries(in, section); n == 0 && !none {
+			out = append(out, section)
+		}
+	}
+	return out
+}
+
+// historySections are the intake lists unconfirmedSections checks, in order.
+var historySections = []string{RequireConditions, RequireMedications, RequireAllergies}
+
+// historyEntries returns how many entries the intake names in a history
+// section and whether it confirms the section has none.
+func historyEntries(in Intake, section string) (n int, confirmedNone bool) {
+	switch section {
+	case RequireConditions:
+		return len(in.Conditions) + len(in.ConditionCodes), in.ConfirmedNoConditions
+	case RequireMedications:
+		return len(in.Medications), in.ConfirmedNoMedications
+	case RequireAllergies:
+		return len(in.Allergies) + len(in.AllergyDetails), in.ConfirmedNoAllergies
+	}
+	return 0, false
+}
+
+// confirmationHints tell the clinician how to confirm each history section.
+var confirmationHints = map[string]string{
+	RequireConditions:  "set confirmedNoConditions when the patient has none",
+	RequireMedications: "set confirmedNoMedications when the patient takes none",
+	RequireAllergies:   "set confirmedNoAllergies when the patient has none",
+}
+
+// historyWarnings warn about each unconfirmed history section the
+// requirement checks have not already reported.
+func historyWarnings(in Intake, reported []FieldError) []FieldError {
+	var out []FieldError
+	for _, section := range unconfirmedSections(in) {
+		if slices.ContainsFunc(reported, func(e FieldError) bool { return e.Field == section }) {
+			continue
+		}
+		out = append(out, FieldError{
+			Field:   section,
+			Code:    "unconfirmed",
+			Message: section + " not recorded; " + confirmationHints[section],
+		})
+	}
+	return out
+}
+
+// SetHistoryConfidenceCeiling caps plan and alternative confidence at
+// ceiling while any history section is unconfirmed, and such analyses carry
+// a HISTORY_INCOMPLETE issue whatever the ceiling. It must be within (0, 1];
+// 1 keeps the issue without lowering confidence.
+func (a *Analyzer) SetHistoryConfidenceCeiling(ceiling float64) error {
+	if ceiling <= 0 || ceiling > 1 {
+		return fmt.Errorf("history confidence ceiling %.2f must be within (0, 1]", ceiling)
+	}
+	return a.update(func(s *settings) error {
+		s.historyCeiling = ceiling
+		return nil
+	})
+}
+
+func SetHistoryConfidenceCeiling(ceiling float64) error {
+	return defaultAnalyzer.SetHistoryConfidenceCeiling(ceiling)
+}
+
+// HistoryConfidenceCeiling returns the cap SetHistoryConfidenceCeiling set.
+func (a *Analyzer) HistoryConfidenceCeiling() float64 {
+	return a.settings().historyCeiling
+}
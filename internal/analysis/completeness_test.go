@@ -0,0 +1,91 @@
+package analysis
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestHistoryIncomplete_CapsConfidence(t *testing.T) {
+	a := New()
+	sparse := llmIntake
+	sparse.ConfirmedNoConditions, sparse.ConfirmedNoMedications, sparse.ConfirmedNoAllergies = false, false, false
+
+	complete := a.AnalyzeWithOptions(llmIntake, Options{DryRun: true, Debug: true})
+	if slices.Contains(issueCodes(complete.FlaggedIssues), "HISTORY_INCOMPLETE") || complete.PlanConfidence <= DefaultHistoryConfidenceCeiling {
+		t.Fatalf("confirmed history: confidence %.2f, issues %+v", complete.PlanConfidence, complete.FlaggedIssues)
+	}
+	if f := complete.ConfidenceFactors; f == nil || f.UnconfirmedSections != nil || f.HistoryCeiling != 0 {
+		t.Fatalf("confirmed history factors = %+v", f)
+	}
+
+	resp := a.AnalyzeWithOptions(sparse, Options{DryRun: true, Debug: true})
+	if !slices.Contains(issueCodes(resp.FlaggedIssues), "HISTORY_INCOMPLETE") {
+		t.Fatalf("sparse intake lacks HISTORY_INCOMPLETE: %+v", resp.FlaggedIssues)
+	}
+	if resp.PlanConfidence != DefaultHistoryConfidenceCeiling {
+		t.Fatalf("sparse plan confidence %.3f, want the %.2f ceiling", resp.PlanConfidence, DefaultHistoryConfidenceCeiling)
+	}
+	for _, alt := range resp.Alternatives {
+		if alt.Confidence > DefaultHistoryConfidenceCeiling {
+			t.Errorf("alternative %s confidence %.2f above the ceiling", alt.Medication, alt.Confidence)
+		}
+	}
+	f := resp.ConfidenceFactors
+	if f == nil || !slices.Equal(f.UnconfirmedSections, []string{"conditions", "medications", "allergies"}) || f.HistoryCeiling != DefaultHistoryConfidenceCeiling {
+		t.Fatalf("sparse factors = %+v", f)
+	}
+
+	// Listing an allergy completes that section, and the cap follows the
+	// configured ceiling.
+	if err := a.SetHistoryConfidenceCeiling(0.4); err != nil {
+		t.Fatal(err)
+	}
+	sparse.Allergies = []string{"penicillin"}
+	resp = a.AnalyzeWithOptions(sparse, Options{DryRun: true, Debug: true})
+	if resp.PlanConfidence != 0.4 || !slices.Equal(resp.ConfidenceFactors.UnconfirmedSections, []string{"conditions", "medications"}) {
+		t.Fatalf("confidence %.2f, factors %+v", resp.PlanConfidence, resp.ConfidenceFactors)
+	}
+}
+
+func TestHistoryIncomplete_CapsLLMScores(t *testing.T) {
+	fake := &fakeLLM{t: t, wantPlan: "Finasteride", result: LLMResult{PlanConfidence: 0.9, AlternativeConf: []float64{0.85, 0.8}}}
+	a := New(WithLLMClient(fake))
+	sparse := llmIntake
+	sparse.ConfirmedNoAllergies = false
+	ctx := context.WithValue(context.Background(), ctxKey{}, "req-123")
+	if resp := a.AnalyzeContext(ctx, sparse, Options{DryRun: true}); resp.PlanConfidence != DefaultHistoryConfidenceCeiling {
+		t.Fatalf("LLM plan confidence %.2f not capped", resp.PlanConfidence)
+	}
+}
+
+func TestHistoryWarnings(t *testing.T) {
+	a := New()
+	r, err := a.CheckIntake([]byte(`{"patientName":"Form","age":45,"weight":70,"height":170,"bp":"120/80","complaint":"ED","confirmedNoConditions":true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes := map[string]string{}
+	for _, w := range r.Warnings {
+		if _, dup := codes[w.Field]; dup {
+			t.Errorf("%s warned twice: %+v", w.Field, r.Warnings)
+		}
+		codes[w.Field] = w.Code
+	}
+	// ED's own medication warning already covers that list.
+	if !r.Valid || codes["allergies"] != "unconfirmed" || codes["medications"] != "recommended" || codes["conditions"] != "" {
+		t.Fatalf("valid %t, warnings %+v", r.Valid, r.Warnings)
+	}
+}
+
+func TestSetHistoryConfidenceCeiling_Rejects(t *testing.T) {
+	a := New()
+	for _, c := range []float64{0, -0.1, 1.5} {
+		if err := a.SetHistoryConfidenceCeiling(c); err == nil {
+			t.Errorf("ceiling %.2f accepted", c)
+		}
+	}
+	if got := a.HistoryConfidenceCeiling(); got != DefaultHistoryConfidenceCeiling {
+		t.Fatalf("ceiling %.2f after rejected updates", got)
+	}
+}
@@ -0,0 +1,82 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+// countingStore records every inserted audit ID.
+type countingStore struct {
+	*audit.MemoryStore
+	inserts atomic.Int64
+	mu      sync.Mutex
+	ids     map[string]bool
+}
+
+func (c *countingStore) Insert(ctx context.Context, entry audit.Entry) (audit.Summary, error) {
+	sum, err := c.MemoryStore.Insert(ctx, entry)
+	if err == nil {
+		c.inserts.Add(1)
+		c.mu.Lock()
+		c.ids[sum.AuditID] = true
+		c.mu.Unlock()
+	}
+	return sum, err
+}
+
+func TestAnalyze_ConcurrentCallsRecordEveryAudit(t *testing.T) {
+	store := &countingStore{MemoryStore: audit.NewMemoryStore(), ids: map[string]bool{}}
+	a := New(WithAuditStore(store))
+
+	const calls = 100
+	complaints := []string{"ED", "Hair Loss", "Weight Loss", "General"}
+	var wg sync.WaitGroup
+	errs := make(chan string, calls)
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Reconfigure concurrently to exercise the settings lock.
+			switch i % 10 {
+			case 0:
+				a.SetLLMClient(StubLLM{})
+			case 1:
+				a.SetLLMTimeout(time.Second)
+			case 2:
+				_ = a.SetRiskThresholds(DefaultRiskThresholds)
+			case 3:
+				_ = a.LatestAudits(10)
+			}
+			in := Intake{
+				PatientName: fmt.Sprintf("Patient %d", i),
+				Age:         30 + i%40,
+				WeightKg:    80,
+				HeightCm:    178,
+				BP:          "128/82",
+				Complaint:   complaints[i%len(complaints)],
+			}
+			resp := a.Analyze(in)
+			if len(resp.ValidationErrors) > 0 || resp.AuditID == "" {
+				errs <- fmt.Sprintf("call %d: audit=%q errors=%v", i, resp.AuditID, resp.ValidationErrors)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for e := range errs {
+		t.Error(e)
+	}
+
+	if got := store.inserts.Load(); got != calls {
+		t.Fatalf("expected %d audits, got %d", calls, got)
+	}
+	if len(store.ids) != calls {
+		t.Fatalf("expected %d unique audit IDs, got %d", calls, len(store.ids))
+	}
+}
@@ -0,0 +1,160 @@
+package analysis
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Canonical conditions read by the rule engine.
+const (
+	condHeartDisease  = "heart disease"
+	condKidneyDisease = "kidney disease"
+	condLiverDisease  = "liver disease"
+	condDiabetes      = "diabetes"
+	condHypertension  = "hypertension"
+)
+
+// canonicalTerms lists the terms that stand for one canonical entry. A term
+// matches whole words anywhere in an entry, so staged or qualified entries
+// such as "CKD stage 3" or "type 2 diabetes mellitus" map too, and one entry
+// may name several canonical ones.
+type canonicalTerms struct {
+	Canonical string
+	Terms     []string
+}
+
+// diabetesTerms are shared by conditions and family history.
+var diabetesTerms = []string{
+	"diabetes", "diabetes mellitus", "dm", "t2dm", "t1dm", "dm2", "dm1", "t2d", "t1d", "niddm", "iddm",
+}
+
+// conditionTerms maps clinical shorthand and phrasings to the canonical
+// condition they stand for.
+var conditionTerms = []canonicalTerms{
+	{condHeartDisease, []string{
+		"heart disease", "cardiac disease", "coronary artery disease", "coronary heart disease", "cad", "chd",
+		"ischemic heart disease", "ischaemic heart disease", "ihd", "myocardial infarction", "mi", "nstemi", "stemi",
+		"heart attack", "angina", "heart failure", "chf", "hfref", "hfpef", "cardiomyopathy",
+	}},
+	{condKidneyDisease, []string{
+		"kidney disease", "renal disease", "ckd", "renal insufficiency", "renal impairment", "renal failure",
+		"kidney failure", "esrd", "eskd",
+	}},
+	{condLiverDisease, []string{
+		"liver disease", "hepatic disease", "cirrhosis", "hepatic impairment", "hepatic insufficiency",
+		"liver failure", "nafld", "nash", "hepatitis",
+	}},
+	{condDiabetes, diabetesTerms},
+	{condHypertension, []string{
+		"hypertension", "htn", "high blood pressure", "hbp",
+	}},
+}
+
+// normalizeConditions maps each entry to its canonical conditions. Entries
+// that match no term are returned in input order so they can be reported
+// instead of silently ignored.
+func normalizeConditions(values []string) (map[string]bool, []string) {
+	return matchTerms(values, conditionTerms)
+}
+
+// matchTerms maps each entry to the canonical entries of table whose terms
+// it contains, returning the entries that match none in input order.
+func matchTerms(values []string, table []canonicalTerms) (map[string]bool, []string) {
+	set := map[string]bool{}
+	var unmapped []string
+	for _, v := range values {
+		words := " " + strings.Join(strings.FieldsFunc(strings.ToLower(v), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}), " ") + " "
+		if strings.TrimSpace(words) == "" {
+			continue
+		}
+		matched := false
+		for _, c := range table {
+			for _, term := range c.Terms {
+				if strings.Contains(words, " "+term+" ") {
+					set[c.Canonical] = true
+					matched = true
+					break
+				}
+			}
+		}
+		if !matched {
+			unmapped = append(unmapped, strings.TrimSpace(v))
+		}
+	}
+	return set, unmapped
+}
+
+// icd10Pattern matches an ICD-10 code with or without the dot, e.g. I25.10,
+// N183, or E11.
+var icd10Pattern = regexp.MustCompile(`^([A-Z])(\d{2})(?:\.?[0-9A-Z]{1,4})?$`)
+
+// icd10Categories maps ICD-10 category ranges, by letter and the two digits
+// that follow it, to canonical conditions.
+var icd10Categories = []struct {
+	Letter    byte
+	From, To  int
+	Canonical string
+}{
+	{'I', 20, 25, condHeartDisease}, // ischemic heart diseases
+	{'I', 50, 50, condHeartDisease}, // heart failure
+	{'N', 18, 18, condKidneyDisease},
+	{'K', 70, 77, condLiverDisease},
+	{'E', 10, 11, condDiabetes},
+	{'I', 10, 16, condHypertension},
+}
+
+// normalizeICD10 upper-cases and trims code, reporting whether it is a
+// well-formed ICD-10 code.
+func normalizeICD10(code string) (string, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	return code, icd10Pattern.MatchString(code)
+}
+
+// icd10Condition returns the canonical condition for a well-formed ICD-10
+// code, or "" when no category covers it.
+func icd10Condition(code string) string {
+	m := icd10Pattern.FindStringSubmatch(code)
+	if m == nil {
+		return ""
+	}
+	n, _ := strconv.Atoi(m[2])
+	for _, c := range icd10Categories {
+		if m[1][0] == c.Letter && n >= c.From && n <= c.To {
+			return c.Canonical
+		}
+	}
+	return ""
+}
+
+// ICD10Condition returns the canonical condition the engine reads code as,
+// or "" when code is malformed or no category covers it.
+func ICD10Condition(code string) string {
+	code, ok := normalizeICD10(code)
+	if !ok {
+		return ""
+	}
+	return icd10Condition(code)
+}
+
+// mergeConditionCodes adds the conditions named by codes to set and returns
+// the well-formed codes no category covers. Malformed codes are rejected by
+// Validate before analysis.
+func mergeConditionCodes(set map[string]bool, codes []string) []string {
+	var unmapped []string
+	for _, raw := range codes {
+		code, ok := normalizeICD10(raw)
+		if !ok {
+			continue
+		}
+		if c := icd10Condition(code); c != "" {
+			set[c] = true
+		} else {
+			unmapped = append(unmapped, code)
+		}
+	}
+	return unmapped
+}
@@ -0,0 +1,133 @@
+package analysis
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeConditions(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"CAD", []string{condHeartDisease}},
+		{"Ischemic heart disease", []string{condHeartDisease}},
+		{"h/o MI", []string{condHeartDisease}},
+		{"CHF (HFrEF)", []string{condHeartDisease}},
+		{"CKD stage 3", []string{condKidneyDisease}},
+		{"chronic kidney disease", []string{condKidneyDisease}},
+		{"Renal insufficiency", []string{condKidneyDisease}},
+		{"T2DM", []string{condDiabetes}},
+		{"Type 2 diabetes mellitus", []string{condDiabetes}},
+		{"DM2", []string{condDiabetes}},
+		{"HTN", []string{condHypertension}},
+		{"Liver cirrhosis", []string{condLiverDisease}},
+		{"T2DM with CKD", []string{condKidneyDisease, condDiabetes}},
+		{"Heart Disease", []string{condHeartDisease}},
+		{"academic stress", nil},
+	}
+	for _, tt := range tests {
+		set, unmapped := normalizeConditions([]string{tt.in})
+		var got []string
+		for _, c := range conditionTerms {
+			if set[c.Canonical] {
+				got = append(got, c.Canonical)
+			}
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("normalizeConditions(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+		if (len(unmapped) == 1) != (tt.want == nil) {
+			t.Errorf("normalizeConditions(%q) unmapped = %v", tt.in, unmapped)
+		}
+	}
+}
+
+func TestAnalyze_ConditionSynonyms(t *testing.T) {
+	resp := Analyze(Intake{
+		PatientName: "Synonyms",
+		Age:         58,
+		WeightKg:    82,
+		HeightCm:    176,
+		BP:          "128/82",
+		Complaint:   "ED",
+		Conditions:  []string{"CAD", "CKD stage 3", "T2DM", "gout"},
+	})
+	if len(resp.ValidationErrors) > 0 {
+		t.Fatalf("unexpected validation errors: %v", resp.ValidationErrors)
+	}
+	if want := []string{condDiabetes, condHeartDisease, condKidneyDisease}; !slices.Equal(resp.Conditions, want) {
+		t.Fatalf("conditions = %v, want %v", resp.Conditions, want)
+	}
+	codes := issueCodes(resp.FlaggedIssues)
+	for _, code := range []string{"COND_HEART_DISEASE", "COND_KIDNEY_DISEASE", "COND_DIABETES", "CONDITION_UNMAPPED"} {
+		if !slices.Contains(codes, code) {
+			t.Errorf("issues = %v, missing %s", codes, code)
+		}
+	}
+}
+
+func TestICD10Condition(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+		ok   bool
+	}{
+		{"I25.10", condHeartDisease, true},
+		{"i21.4", condHeartDisease, true},
+		{"I50.9", condHeartDisease, true},
+		{"N18.3", condKidneyDisease, true},
+		{"N183", condKidneyDisease, true},
+		{"E11.9", condDiabetes, true},
+		{"E10", condDiabetes, true},
+		{"I10", condHypertension, true},
+		{"K74.60", condLiverDisease, true},
+		{"J45.909", "", true},
+		{"I26.99", "", true},
+		{"25.10", "", false},
+		{"I2", "", false},
+		{"diabetes", "", false},
+	}
+	for _, tt := range tests {
+		code, ok := normalizeICD10(tt.code)
+		if ok != tt.ok {
+			t.Errorf("normalizeICD10(%q) ok = %v, want %v", tt.code, ok, tt.ok)
+			continue
+		}
+		if got := icd10Condition(code); got != tt.want {
+			t.Errorf("icd10Condition(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestAnalyze_ConditionCodes(t *testing.T) {
+	in := Intake{
+		PatientName:    "Coded",
+		Age:            58,
+		WeightKg:       82,
+		HeightCm:       176,
+		BP:             "128/82",
+		Complaint:      "ED",
+		Conditions:     []string{"HTN"},
+		ConditionCodes: []string{"I25.10", "N18.3", "J45.909"},
+	}
+	resp := Analyze(in)
+	if len(resp.ValidationErrors) > 0 {
+		t.Fatalf("unexpected validation errors: %v", resp.ValidationErrors)
+	}
+	if want := []string{condHeartDisease, condHypertension, condKidneyDisease}; !slices.Equal(resp.Conditions, want) {
+		t.Fatalf("conditions = %v, want %v", resp.Conditions, want)
+	}
+	if !slices.Equal(resp.UnmappedConditionCodes, []string{"J45.909"}) {
+		t.Fatalf("unmapped codes = %v", resp.UnmappedConditionCodes)
+	}
+	if codes := issueCodes(resp.FlaggedIssues); !slices.Contains(codes, "COND_HEART_DISEASE") || !slices.Contains(codes, "COND_KIDNEY_DISEASE") {
+		t.Fatalf("issues = %v", codes)
+	}
+
+	in.ConditionCodes = []string{"I25.10", "heart"}
+	if resp := Analyze(in); len(resp.ValidationErrors) != 1 || !strings.Contains(resp.ValidationErrors[0], "conditionCodes[1]") {
+		t.Fatalf("malformed code: %v", resp.ValidationErrors)
+	}
+}
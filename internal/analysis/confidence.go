@@ -0,0 +1,141 @@
+package analysis
+
+// confidenceBand bounds plan confidence per risk level; riskier cases can never
+// report high certainty regardless of intake completeness.
+type confidenceBand struct {
+	Floor   float64
+	Ceiling float64
+}
+
+var confidenceBands = map[RiskLevel]confidenceBand{
+	RiskLow:      {Floor: 0.5, Ceiling: 0.95},
+	RiskMedium:   {Floor: 0.4, Ceiling: 0.85},
+	RiskHigh:     {Floor: 0.3, Ceiling: 0.75},
+	RiskCritical: {Floor: 0.2, Ceiling: 0.6},
+}
+
+// Per-unit penalties applied to the base confidence.
+const (
+	riskPointPenalty    = 0.01
+	dangerIssuePenalty  = 0.08
+	warningIssuePenalty = 0.03
+	infoIssuePenalty    = 0.01
+	substitutionPenalty = 0.1
+)
+
+// callLLMStub simulates an LLM scoring step while keeping deterministic guardrails.
+//
+// Plan confidence is computed as:
+//
+//	coverage  = 0.6 + 0.05 for a bp + 0.05 per confirmed list (conditions, medications, allergies)
+//	base      = 0.55 + 0.3*coverage
+//	factorCap = base less 0.3*0.05 per list that names an entry
+//	raw       = min(base, factorCap) - 0.01*riskScore - (0.08*danger + 0.03*warning + 0.01*info) - 0.1*substituted
+//	plan      = clamp(raw, band.Floor, band.Ceiling) for the case's risk level, then
+//	            at most the history ceiling while a list names entries without its confirmedNo flag
+//
+// Every penalty is non-negative and bands only tighten as risk rises, so a new
+// danger issue can never increase confidence. A list is confirmed once it
+// names an entry or carries its confirmedNo flag; an empty one without the
+// flag is left to unconfirmedSections, which caps plan confidence at the
+// history ceiling once scored. The coverage a list earns by naming entries
+// is capped away again, and a list filled only by them is held to the
+// history ceiling, since without them the list would be unconfirmed. So
+// adding a condition, medication, or allergy never raises the stub's
+// confidence, even when it adds no risk points. Alternatives step down 0.05 per rank from the plan confidence and never
+// exceed the band ceiling or a history ceiling held; ranks follow
+// suitability, see rankAlternatives.
+func callLLMStub(req ScoreRequest) LLMResult {
+	in := req.Intake
+	coverage := 0.6
+	if in.BP != "" {
+		coverage += 0.05
+	}
+	// capCoverage leaves out the credit of lists that name entries.
+	capCoverage := coverage
+	// named is set by a list naming entries; held when one of those lists
+	// would be unconfirmed without them.
+	named, held := false, false
+	for _, section := range historySections {
+		n, none := historyEntries(in, section)
+		switch {
+		case n > 0:
+			coverage += 0.05
+			named = true
+			held = held || (!none && req.historyCeiling > 0)
+		case none:
+			coverage += 0.05
+			capCoverage += 0.05
+		}
+	}
+
+	f := ConfidenceFactors{
+		Coverage:    coverage,
+		Base:        0.55 + coverage*0.3,
+		RiskPenalty: float64(req.RiskScore) * riskPointPenalty,
+	}
+	base := f.Base
+	if named {
+		f.FactorCap = 0.55 + capCoverage*0.3
+		base = min(base, f.FactorCap)
+	}
+	for _, issue := range req.Issues {
+		// The history ceiling prices an incomplete history; a penalty as
+		// well would fall away when a named entry completes it.
+		if issue.Code == "HISTORY_INCOMPLETE" {
+			continue
+		}
+		switch issue.Severity {
+		case SeverityDanger:
+			f.DangerIssues++
+		case SeverityWarning:
+			f.WarningIssues++
+		default:
+			f.InfoIssues++
+		}
+	}
+	f.IssuePenalty = float64(f.DangerIssues)*dangerIssuePenalty +
+		float64(f.WarningIssues)*warningIssuePenalty +
+		float64(f.InfoIssues)*infoIssuePenalty
+	if req.PlanSubstituted {
+		f.SubstitutionPenalty = substitutionPenalty
+	}
+
+	band, ok := confidenceBands[req.RiskLevel]
+	if !ok {
+		band = confidenceBands[RiskHigh]
+	}
+	f.Floor, f.Ceiling = band.Floor, band.Ceiling
+
+	raw := base - f.RiskPenalty - f.IssuePenalty - f.SubstitutionPenalty
+	planConfidence := clamp(raw, band.Floor, band.Ceiling)
+	// Applied after the band, as for unconfirmed lists, so the floor cannot
+	// lift confidence over the ceiling the list would have without entries.
+	ceiling := band.Ceiling
+	if held {
+		planConfidence = min(planConfidence, req.historyCeiling)
+		ceiling = min(ceiling, req.historyCeiling)
+		f.HistoryCeiling = req.historyCeiling
+	}
+
+	altConf := make([]float64, len(req.Alternatives))
+	for i := range req.Alternatives {
+		altConf[i] = min(clamp(planConfidence-0.05*float64(i+1), 0.1, band.Ceiling), ceiling)
+	}
+	return LLMResult{
+		PlanConfidence:  planConfidence,
+		AlternativeConf: altConf,
+		Factors:         &f,
+	}
+}
+
+// mergeAltConfidence applies the scorer's alternative confidences, capped by
+// the suitability score each alternative already carries.
+func mergeAltConfidence(alts []Alternative, conf []float64) []Alternative {
+	for i := range alts {
+		if i < len(conf) {
+			alts[i].Confidence = min(conf[i], alts[i].Confidence)
+		}
+	}
+	return alts
+}
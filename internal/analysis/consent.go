@@ -0,0 +1,110 @@
+package analysis
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+	"github.com/Skufu/Clinical-AI-Assistant/types"
+)
+
+// Validation codes for consent errors.
+const (
+	ConsentRequired = types.ConsentRequired
+	ConsentInvalid  = types.ConsentInvalid
+)
+
+// SetConsentRequired makes Validate reject intakes without documented patient
+// consent. It is off by default so existing embedders keep working.
+func (a *Analyzer) SetConsentRequired(enabled bool) {
+	_ = a.update(func(s *settings) error {
+		s.consentRequired = enabled
+		return nil
+	})
+}
+
+func SetConsentRequired(enabled bool) {
+	defaultAnalyzer.SetConsentRequired(enabled)
+}
+
+// SetConsentGrace logs missing or incomplete consent instead of rejecting the
+// analysis, for rolling out SetConsentRequired. It has no effect unless
+// consent is required.
+func (a *Analyzer) SetConsentGrace(enabled bool) {
+	_ = a.update(func(s *settings) error {
+		s.consentGrace = enabled
+		return nil
+	})
+}
+
+func SetConsentGrace(enabled bool) {
+	defaultAnalyzer.SetConsentGrace(enabled)
+}
+
+// consentErrors lists what is wrong with the intake's consent.
+func consentErrors(c *Consent) []FieldError {
+	if c == nil || !c.Given {
+		return []FieldError{{Field: "consent.given", Code: ConsentRequired, Message: "patient consent must be given before analysis"}}
+	}
+	var errs []FieldError
+	if _, err := time.Parse(time.RFC3339, strings.TrimSpace(c.Timestamp)); err != nil {
+		errs = append(errs, FieldError{Field: "consent.timestamp", Code: ConsentInvalid, Message: "consent.timestamp must be an RFC3339 time"})
+	}
+	if strings.TrimSpace(c.Method) == "" {
+		errs = append(errs, FieldError{Field: "consent.method", Code: ConsentInvalid, Message: "consent.method is required"})
+	}
+	return errs
+}
+
+// fieldErrors checks in against the configured rules. Fields the intake's
+// complaints only recommend, and history sections left unconfirmed, are
+// warnings. Consent problems are errors when
+// consent is required and warnings in grace mode.
+func (s settings) fieldErrors(in Intake) (errs, warnings []FieldError) {
+	// Oversized or malformed values are rejected before any other check so
+	// none of them is quoted into a further message.
+	if errs = limitErrors(in); len(errs) > 0 {
+		return errs, nil
+	}
+	errs, warnings = s.requirementErrors(in)
+	errs = append(intakeErrors(in), errs...)
+	warnings = append(warnings, historyWarnings(in, slices.Concat(errs, warnings))...)
+	if !s.consentRequired {
+		return errs, warnings
+	}
+	if cerrs := consentErrors(in.Consent); s.consentGrace {
+		warnings = append(warnings, cerrs...)
+	} else {
+		errs = append(errs, cerrs...)
+	}
+	return errs, warnings
+}
+
+// validate is fieldErrors as the messages Validate and Response carry.
+func (s settings) validate(in Intake) (errs, warnings []string) {
+	fe, fw := s.fieldErrors(normalizeText(in))
+	return errorTexts(fe), errorTexts(fw)
+}
+
+// errorTexts renders field errors as messages, prefixing consent codes so
+// callers can match them in plain validation errors.
+func errorTexts(errs []FieldError) []string {
+	var out []string
+	for _, e := range errs {
+		if e.Code == ConsentRequired || e.Code == ConsentInvalid {
+			out = append(out, e.Code+": "+e.Message)
+			continue
+		}
+		out = append(out, e.Message)
+	}
+	return out
+}
+
+// auditConsent is the consent metadata recorded with an audit entry.
+func auditConsent(c *Consent) *audit.Consent {
+	if c == nil {
+		return nil
+	}
+	return &audit.Consent{Given: c.Given, Timestamp: strings.TrimSpace(c.Timestamp), Method: strings.TrimSpace(c.Method)}
+}
@@ -0,0 +1,86 @@
+package analysis
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+var writtenConsent = &Consent{Given: true, Timestamp: "2025-03-01T09:00:00Z", Method: "written"}
+
+func TestConsentErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		consent *Consent
+		code    string
+	}{
+		{"documented", writtenConsent, ""},
+		{"missing", nil, ConsentRequired},
+		{"declined", &Consent{Given: false, Timestamp: "2025-03-01T09:00:00Z", Method: "verbal"}, ConsentRequired},
+		{"bad timestamp", &Consent{Given: true, Timestamp: "yesterday", Method: "verbal"}, ConsentInvalid},
+		{"no method", &Consent{Given: true, Timestamp: "2025-03-01T09:00:00Z"}, ConsentInvalid},
+	}
+	for _, tc := range cases {
+		errs := consentErrors(tc.consent)
+		if tc.code == "" {
+			if len(errs) != 0 {
+				t.Errorf("%s: unexpected errors %v", tc.name, errs)
+			}
+			continue
+		}
+		if len(errs) != 1 || errs[0].Code != tc.code {
+			t.Errorf("%s: errors %v, want one %s", tc.name, errs, tc.code)
+		}
+	}
+}
+
+func TestAnalyze_ConsentRequired(t *testing.T) {
+	store := audit.NewMemoryStore()
+	a := New(WithAuditStore(store))
+	if errs := a.Validate(llmIntake); len(errs) != 0 {
+		t.Fatalf("consent is optional by default: %v", errs)
+	}
+
+	a.SetConsentRequired(true)
+	resp := a.Analyze(llmIntake)
+	if resp.RiskLevel != "INVALID" || len(resp.ValidationErrors) != 1 || !strings.HasPrefix(resp.ValidationErrors[0], ConsentRequired) {
+		t.Fatalf("missing consent: %s %v", resp.RiskLevel, resp.ValidationErrors)
+	}
+
+	in := llmIntake
+	in.Consent = writtenConsent
+	if resp := a.Analyze(in); resp.AuditID == "" || len(resp.ValidationErrors) > 0 {
+		t.Fatalf("documented consent rejected: %v", resp.ValidationErrors)
+	}
+	audits, err := store.Latest(t.Context(), 10)
+	if err != nil || len(audits) != 1 || audits[0].Consent == nil || audits[0].Consent.Method != "written" {
+		t.Fatalf("consent not recorded in the audit: %+v (err %v)", audits, err)
+	}
+	if sum := a.LatestAudits(1); len(sum) != 1 || sum[0].Consent == nil || sum[0].Consent.Timestamp != writtenConsent.Timestamp {
+		t.Fatalf("audit summary consent: %+v", sum)
+	}
+}
+
+func TestAnalyze_ConsentGrace(t *testing.T) {
+	a := New()
+	a.SetConsentRequired(true)
+	a.SetConsentGrace(true)
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	resp := a.Analyze(llmIntake)
+	if resp.RiskLevel == "INVALID" || len(resp.ValidationErrors) > 0 {
+		t.Fatalf("grace mode should not reject: %v", resp.ValidationErrors)
+	}
+	if !strings.Contains(logs.String(), ConsentRequired) {
+		t.Fatalf("grace mode should log the missing consent, got:\n%s", logs.String())
+	}
+	if errs := a.Validate(llmIntake); len(errs) != 0 {
+		t.Fatalf("Validate in grace mode: %v", errs)
+	}
+}
@@ -0,0 +1,119 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+// ErrDecisionsUnsupported is returned when the audit store cannot record decisions.
+var ErrDecisionsUnsupported = errors.New("audit store does not support clinician decisions")
+
+// ErrInvalidDecision wraps the reason a decision request was rejected.
+var ErrInvalidDecision = errors.New("invalid decision")
+
+// SetDecisionRevisions controls what happens to a second decision on the same
+// audit: recorded as the next revision when enabled, rejected with
+// audit.ErrDecisionExists otherwise (the default).
+func (a *Analyzer) SetDecisionRevisions(enabled bool) {
+	_ = a.update(func(s *settings) error {
+		s.decisionRevisions = enabled
+		return nil
+	})
+}
+
+func SetDecisionRevisions(enabled bool) {
+	defaultAnalyzer.SetDecisionRevisions(enabled)
+}
+
+func validateDecision(req DecisionRequest) error {
+	switch req.Decision {
+	case audit.DecisionApproved, audit.DecisionRejected:
+		if req.ModifiedPlan != nil {
+			return fmt.Errorf("%w: modifiedPlan is only allowed when decision is modified", ErrInvalidDecision)
+		}
+	case audit.DecisionModified:
+		if req.ModifiedPlan == nil || strings.TrimSpace(req.ModifiedPlan.Medication) == "" {
+			return fmt.Errorf("%w: modifiedPlan with a medication is required when decision is modified", ErrInvalidDecision)
+		}
+	default:
+		return fmt.Errorf("%w: decision must be approved, modified, or rejected", ErrInvalidDecision)
+	}
+	if req.Decision != audit.DecisionApproved && strings.TrimSpace(req.Reason) == "" {
+		return fmt.Errorf("%w: reason is required when the plan is %s", ErrInvalidDecision, req.Decision)
+	}
+	return nil
+}
+
+// RecordDecision stores a clinician's decision on the analysis audited as
+// auditID. Errors are ErrInvalidDecision, audit.ErrNotFound,
+// audit.ErrDecisionExists, or ErrDecisionsUnsupported.
+func (a *Analyzer) RecordDecision(ctx context.Context, auditID string, req DecisionRequest) (Decision, error) {
+	if err := validateDecision(req); err != nil {
+		return Decision{}, err
+	}
+	s := a.settings()
+	store, ok := s.store.(audit.DecisionStore)
+	if !ok {
+		return Decision{}, ErrDecisionsUnsupported
+	}
+	d := audit.Decision{
+		AuditID:  auditID,
+		Decision: req.Decision,
+		Reason:   strings.TrimSpace(req.Reason),
+		UserID:   req.UserID,
+		At:       a.now().UTC(),
+	}
+	if req.ModifiedPlan != nil {
+		plan, err := json.Marshal(req.ModifiedPlan)
+		if err != nil {
+			return Decision{}, err
+		}
+		d.ModifiedPlan = plan
+	}
+	d, err := store.InsertDecision(ctx, d, s.decisionRevisions)
+	if err != nil {
+		return Decision{}, err
+	}
+	return decisionOf(d), nil
+}
+
+func RecordDecision(ctx context.Context, auditID string, req DecisionRequest) (Decision, error) {
+	return defaultAnalyzer.RecordDecision(ctx, auditID, req)
+}
+
+// DecisionStats reports approval and override rates per risk level.
+func (a *Analyzer) DecisionStats(ctx context.Context) (audit.DecisionStats, error) {
+	store, ok := a.settings().store.(audit.DecisionStore)
+	if !ok {
+		return audit.DecisionStats{}, ErrDecisionsUnsupported
+	}
+	return store.DecisionStats(ctx)
+}
+
+func DecisionStats(ctx context.Context) (audit.DecisionStats, error) {
+	return defaultAnalyzer.DecisionStats(ctx)
+}
+
+func decisionOf(d audit.Decision) Decision {
+	out := Decision{
+		AuditID:  d.AuditID,
+		Revision: d.Revision,
+		Decision: d.Decision,
+		Reason:   d.Reason,
+		UserID:   d.UserID,
+		At:       d.At.Format(time.RFC3339),
+	}
+	if len(d.ModifiedPlan) > 0 {
+		var plan Plan
+		if err := json.Unmarshal(d.ModifiedPlan, &plan); err == nil {
+			out.ModifiedPlan = &plan
+		}
+	}
+	return out
+}
@@ -0,0 +1,68 @@
+package analysis
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+func TestValidateDecision(t *testing.T) {
+	plan := &Plan{Medication: "Tadalafil 5mg"}
+	cases := []struct {
+		name string
+		req  DecisionRequest
+		ok   bool
+	}{
+		{"approved", DecisionRequest{Decision: "approved"}, true},
+		{"modified", DecisionRequest{Decision: "modified", ModifiedPlan: plan, Reason: "daily dosing"}, true},
+		{"rejected", DecisionRequest{Decision: "rejected", Reason: "refer"}, true},
+		{"unknown", DecisionRequest{Decision: "maybe"}, false},
+		{"modified without plan", DecisionRequest{Decision: "modified", Reason: "x"}, false},
+		{"approved with plan", DecisionRequest{Decision: "approved", ModifiedPlan: plan}, false},
+		{"rejected without reason", DecisionRequest{Decision: "rejected", Reason: " "}, false},
+	}
+	for _, tc := range cases {
+		err := validateDecision(tc.req)
+		if (err == nil) != tc.ok || (err != nil && !errors.Is(err, ErrInvalidDecision)) {
+			t.Errorf("%s: err = %v", tc.name, err)
+		}
+	}
+}
+
+func TestRecordDecision(t *testing.T) {
+	a := New(WithClock(fixedClock))
+	resp := a.Analyze(localeIntake)
+
+	d, err := a.RecordDecision(t.Context(), resp.AuditID, DecisionRequest{Decision: "approved", UserID: "dr-cruz"})
+	if err != nil || d.Revision != 1 || d.At != "2025-01-02T03:04:05Z" || d.UserID != "dr-cruz" {
+		t.Fatalf("decision = %+v (err %v)", d, err)
+	}
+	revise := DecisionRequest{Decision: "modified", ModifiedPlan: &Plan{Medication: "Tadalafil"}, Reason: "cost"}
+	if _, err := a.RecordDecision(t.Context(), resp.AuditID, revise); !errors.Is(err, audit.ErrDecisionExists) {
+		t.Fatalf("second decision: %v, want ErrDecisionExists", err)
+	}
+	a.SetDecisionRevisions(true)
+	if d, err = a.RecordDecision(t.Context(), resp.AuditID, revise); err != nil || d.Revision != 2 {
+		t.Fatalf("revision = %+v (err %v)", d, err)
+	}
+	if _, err := a.RecordDecision(t.Context(), "missing", revise); !errors.Is(err, audit.ErrNotFound) {
+		t.Fatalf("unknown audit: %v", err)
+	}
+
+	stored, err := a.AuditResponse(t.Context(), resp.AuditID)
+	if err != nil || len(stored.Decisions) != 2 || stored.Decisions[1].ModifiedPlan.Medication != "Tadalafil" {
+		t.Fatalf("stored decisions = %+v (err %v)", stored.Decisions, err)
+	}
+	if verrs := ValidateResponse(stored); len(verrs) > 0 {
+		t.Fatalf("stored response with decisions fails the schema: %v", verrs)
+	}
+	latest := a.LatestAudits(1)
+	if len(latest) != 1 || latest[0].Decision == nil || latest[0].Decision.Decision != "modified" {
+		t.Fatalf("listing = %+v", latest)
+	}
+	stats, err := a.DecisionStats(t.Context())
+	if err != nil || stats.ByRiskLevel[string(resp.RiskLevel)].OverrideRate != 1 {
+		t.Fatalf("stats = %+v (err %v)", stats, err)
+	}
+}
@@ -0,0 +1,120 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+// ErrSoftDeleteUnsupported is returned when the audit store cannot
+// soft-delete audits.
+var ErrSoftDeleteUnsupported = errors.New("audit store does not support soft deletion")
+
+// ErrDeletionReasonRequired is returned by DeleteAudit for a blank reason.
+var ErrDeletionReasonRequired = errors.New("reason is required to delete an audit")
+
+// DeleteAudit soft-deletes audit auditID: it drops out of listings and
+// statistics but stays readable by ID and can be restored. It returns the
+// event appended to the audit's deletion trail. Errors are
+// ErrDeletionReasonRequired, audit.ErrNotFound, audit.ErrAlreadyDeleted, or
+// ErrSoftDeleteUnsupported.
+func (a *Analyzer) DeleteAudit(ctx context.Context, auditID string, req DeleteRequest) (AuditDeletion, error) {
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return AuditDeletion{}, ErrDeletionReasonRequired
+	}
+	store, ok := a.settings().store.(audit.SoftDeleter)
+	if !ok {
+		return AuditDeletion{}, ErrSoftDeleteUnsupported
+	}
+	if err := store.SoftDelete(ctx, auditID, reason, req.UserID); err != nil {
+		return AuditDeletion{}, err
+	}
+	return lastDeletion(ctx, store, auditID)
+}
+
+func DeleteAudit(ctx context.Context, auditID string, req DeleteRequest) (AuditDeletion, error) {
+	return defaultAnalyzer.DeleteAudit(ctx, auditID, req)
+}
+
+// RestoreAudit undoes DeleteAudit and returns the restore event. Errors are
+// audit.ErrNotFound, audit.ErrNotDeleted, or ErrSoftDeleteUnsupported.
+func (a *Analyzer) RestoreAudit(ctx context.Context, auditID string) (AuditDeletion, error) {
+	store, ok := a.settings().store.(audit.SoftDeleter)
+	if !ok {
+		return AuditDeletion{}, ErrSoftDeleteUnsupported
+	}
+	if err := store.Restore(ctx, auditID); err != nil {
+		return AuditDeletion{}, err
+	}
+	return lastDeletion(ctx, store, auditID)
+}
+
+func RestoreAudit(ctx context.Context, auditID string) (AuditDeletion, error) {
+	return defaultAnalyzer.RestoreAudit(ctx, auditID)
+}
+
+func lastDeletion(ctx context.Context, store audit.SoftDeleter, auditID string) (AuditDeletion, error) {
+	trail, err := store.Deletions(ctx, auditID)
+	if err != nil {
+		return AuditDeletion{}, err
+	}
+	if len(trail) == 0 {
+		return AuditDeletion{}, audit.ErrNotFound
+	}
+	return deletionOf(trail[len(trail)-1]), nil
+}
+
+// LatestAuditsIncludingDeleted is LatestAuditsContext with soft-deleted
+// audits listed too, their DeletedAt set.
+func (a *Analyzer) LatestAuditsIncludingDeleted(ctx context.Context, limit int) ([]AuditSummary, error) {
+	store, ok := a.settings().store.(audit.SoftDeleter)
+	if !ok {
+		return nil, ErrSoftDeleteUnsupported
+	}
+	summaries, err := store.LatestIncludingDeleted(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]AuditSummary, 0, len(summaries))
+	for _, sum := range summaries {
+		out = append(out, auditSummary(sum))
+	}
+	return out, nil
+}
+
+func LatestAuditsIncludingDeleted(ctx context.Context, limit int) ([]AuditSummary, error) {
+	return defaultAnalyzer.LatestAuditsIncludingDeleted(ctx, limit)
+}
+
+// auditDeletions returns the deletion trail of auditID, or nil when the store
+// keeps none.
+func (a *Analyzer) auditDeletions(ctx context.Context, auditID string) ([]AuditDeletion, error) {
+	store, ok := a.settings().store.(audit.SoftDeleter)
+	if !ok {
+		return nil, nil
+	}
+	trail, err := store.Deletions(ctx, auditID)
+	if err != nil {
+		return nil, err
+	}
+	var out []AuditDeletion
+	for _, d := range trail {
+		out = append(out, deletionOf(d))
+	}
+	return out, nil
+}
+
+func deletionOf(d audit.Deletion) AuditDeletion {
+	return AuditDeletion{
+		AuditID: d.AuditID,
+		Seq:     d.Seq,
+		Action:  d.Action,
+		Reason:  d.Reason,
+		UserID:  d.UserID,
+		At:      d.At.UTC().Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,104 @@
+package analysis
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+//go:embed demo/intakes.json
+var embeddedDemo []byte
+
+// DemoDisclaimer heads the disclaimers of every Response while demo mode is
+// on, whatever disclaimers the deployment or its orgs configure.
+const DemoDisclaimer = "Demo data: fictional example patients for demonstration only. Not for clinical use."
+
+// DemoCase is one embedded example intake for demo mode, with the ID and
+// title the UI lists it under.
+type DemoCase struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Intake Intake `json:"intake"`
+}
+
+var demoCases = mustEmbeddedDemo()
+
+func mustEmbeddedDemo() []DemoCase {
+	dec := json.NewDecoder(bytes.NewReader(embeddedDemo))
+	dec.DisallowUnknownFields()
+	var cases []DemoCase
+	if err := dec.Decode(&cases); err != nil {
+		panic(fmt.Sprintf("analysis: embedded demo intakes: %v", err))
+	}
+	return cases
+}
+
+// DemoCases returns the embedded example intakes SeedDemo analyzes, in order.
+// Each call returns its own copy.
+func DemoCases() []DemoCase {
+	raw, _ := json.Marshal(demoCases)
+	var out []DemoCase
+	_ = json.Unmarshal(raw, &out)
+	return out
+}
+
+// SetDemoMode watermarks every Response, and so every visit note, with
+// DemoDisclaimer ahead of the configured disclaimers.
+func (a *Analyzer) SetDemoMode(enabled bool) {
+	_ = a.update(func(s *settings) error {
+		s.demo = enabled
+		return nil
+	})
+}
+
+func SetDemoMode(enabled bool) {
+	defaultAnalyzer.SetDemoMode(enabled)
+}
+
+// DemoMode reports whether SetDemoMode is on.
+func (a *Analyzer) DemoMode() bool {
+	return a.settings().demo
+}
+
+func DemoMode() bool {
+	return defaultAnalyzer.DemoMode()
+}
+
+// SeedDemo turns demo mode on and records an analysis of every DemoCase
+// through the full pipeline, so the audit list, patient trends, and reports
+// have content to show. The cases go through the configured rules, so an
+// LLM scorer set beforehand makes the seeded scores vary from boot to boot.
+// It fails on the first case that is invalid or not audited, and returns
+// the audit IDs in case order.
+func (a *Analyzer) SeedDemo(ctx context.Context) ([]string, error) {
+	a.SetDemoMode(true)
+	ids := make([]string, 0, len(demoCases))
+	for _, c := range DemoCases() {
+		resp := a.AnalyzeContext(ctx, c.Intake, Options{})
+		switch {
+		case resp.RiskLevel == RiskInvalid:
+			return ids, fmt.Errorf("demo case %s: invalid intake: %s", c.ID, strings.Join(resp.ValidationErrors, "; "))
+		case resp.AuditID == "":
+			return ids, fmt.Errorf("demo case %s: analysis not audited", c.ID)
+		}
+		ids = append(ids, resp.AuditID)
+	}
+	return ids, nil
+}
+
+func SeedDemo(ctx context.Context) ([]string, error) {
+	return defaultAnalyzer.SeedDemo(ctx)
+}
+
+// stampedDisclaimers are the disclaimers for a Response: the configured
+// ones, after DemoDisclaimer in demo mode.
+func (s settings) stampedDisclaimers() []string {
+	if !s.demo {
+		return slices.Clone(s.disclaimers)
+	}
+	return append([]string{DemoDisclaimer}, s.disclaimers...)
+}
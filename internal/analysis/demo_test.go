@@ -0,0 +1,79 @@
+package analysis
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+func TestSeedDemo(t *testing.T) {
+	store := audit.NewMemoryStore(audit.WithCapacity(0))
+	a := New(WithAuditStore(store))
+	a.SetConsentRequired(true)
+	a.SetListConfirmationRequired(true)
+
+	ids, err := a.SeedDemo(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != len(DemoCases()) || len(ids) < 10 {
+		t.Fatalf("seeded %d audits for %d cases", len(ids), len(DemoCases()))
+	}
+	if !a.DemoMode() {
+		t.Fatal("SeedDemo left demo mode off")
+	}
+	latest, err := store.Latest(t.Context(), 50)
+	if err != nil || len(latest) != len(ids) {
+		t.Fatalf("store holds %d audits (err %v), want %d", len(latest), err, len(ids))
+	}
+	levels := map[RiskLevel]bool{}
+	for _, sum := range latest {
+		levels[RiskLevel(sum.RiskLevel)] = true
+	}
+	if len(levels) < 3 {
+		t.Errorf("demo cases cover risk levels %v; want a representative spread", levels)
+	}
+
+	note, err := a.VisitNote(t.Context(), ids[0], "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(note, DemoDisclaimer) {
+		t.Errorf("visit note lacks the demo watermark:\n%s", note)
+	}
+}
+
+func TestDemoMode_Watermark(t *testing.T) {
+	a := New(WithDisclaimers([]string{}))
+	a.SetDemoMode(true)
+	if resp := a.Analyze(llmIntake); len(resp.Disclaimers) != 1 || resp.Disclaimers[0] != DemoDisclaimer {
+		t.Fatalf("disclaimers %q, want the demo watermark alone", resp.Disclaimers)
+	}
+	if resp := a.Analyze(Intake{}); len(resp.Disclaimers) == 0 || resp.Disclaimers[0] != DemoDisclaimer {
+		t.Fatalf("invalid response disclaimers %q, want the demo watermark first", resp.Disclaimers)
+	}
+
+	if err := a.SetOrgs(map[string]OrgConfig{"clinic": {APIKeys: []string{"k"}, Disclaimers: []string{"Clinic pilot."}}}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := audit.WithOrg(context.Background(), "clinic")
+	if resp := a.AnalyzeContext(ctx, llmIntake, Options{}); len(resp.Disclaimers) != 2 || resp.Disclaimers[0] != DemoDisclaimer {
+		t.Fatalf("org disclaimers %q, want the demo watermark first", resp.Disclaimers)
+	}
+
+	a.SetDemoMode(false)
+	if resp := a.Analyze(llmIntake); len(resp.Disclaimers) != 0 {
+		t.Fatalf("disclaimers %q after demo mode off", resp.Disclaimers)
+	}
+}
+
+func TestDemoCases_Copies(t *testing.T) {
+	cases := DemoCases()
+	cases[0].Intake.Conditions = append(cases[0].Intake.Conditions, "changed")
+	cases[0].Title = "changed"
+	if again := DemoCases(); again[0].Title == "changed" || len(again[0].Intake.Conditions) != 0 {
+		t.Fatalf("DemoCases shares state with its callers: %+v", again[0])
+	}
+}
@@ -0,0 +1,61 @@
+package analysis
+
+import (
+	"slices"
+	"strings"
+)
+
+// DefaultDisclaimers are stamped on every Response unless the deployment sets
+// its own: what the output is, and what the tool is meant to be used for.
+var DefaultDisclaimers = []string{
+	"Clinical decision support only, not a prescription. A licensed clinician must review every recommendation before it is acted on.",
+	"Scope of use: screening adult intakes for erectile dysfunction, weight loss, and hair loss treatment. Not for emergencies, pediatric patients, or other conditions.",
+}
+
+// WithDisclaimers sets the disclaimers stamped on every Response; nil keeps
+// DefaultDisclaimers and an empty slice stamps none.
+func WithDisclaimers(d []string) Option {
+	return func(a *Analyzer) {
+		if d != nil {
+			a.s.disclaimers = slices.Clone(d)
+		}
+	}
+}
+
+// SetDisclaimers replaces the disclaimers stamped on every Response; nil
+// restores DefaultDisclaimers and an empty slice stamps none.
+func (a *Analyzer) SetDisclaimers(d []string) {
+	if d == nil {
+		d = DefaultDisclaimers
+	}
+	_ = a.update(func(s *settings) error {
+		s.disclaimers = slices.Clone(d)
+		return nil
+	})
+}
+
+func SetDisclaimers(d []string) {
+	defaultAnalyzer.SetDisclaimers(d)
+}
+
+// Disclaimers returns the disclaimers stamped on every Response.
+func (a *Analyzer) Disclaimers() []string {
+	return slices.Clone(a.settings().disclaimers)
+}
+
+func Disclaimers() []string {
+	return defaultAnalyzer.Disclaimers()
+}
+
+// ParseDisclaimers reads one disclaimer per non-blank line of text. The result
+// is never nil, so text with no lines configures no disclaimers rather than
+// the defaults.
+func ParseDisclaimers(text string) []string {
+	out := []string{}
+	for line := range strings.Lines(text) {
+		if line = strings.TrimSpace(line); line != "" {
+			out = append(out, line)
+		}
+	}
+	return out
+}
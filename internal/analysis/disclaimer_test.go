@@ -0,0 +1,40 @@
+package analysis
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAnalyze_Disclaimers(t *testing.T) {
+	a := New()
+	if resp := a.Analyze(llmIntake); !slices.Equal(resp.Disclaimers, DefaultDisclaimers) {
+		t.Fatalf("disclaimers %q, want defaults", resp.Disclaimers)
+	}
+	if resp := a.Analyze(Intake{}); !slices.Equal(resp.Disclaimers, DefaultDisclaimers) {
+		t.Fatalf("invalid response disclaimers %q, want defaults", resp.Disclaimers)
+	}
+
+	a.SetDisclaimers([]string{"Pilot deployment."})
+	if resp := a.Analyze(llmIntake); !slices.Equal(resp.Disclaimers, []string{"Pilot deployment."}) {
+		t.Fatalf("disclaimers %q after SetDisclaimers", resp.Disclaimers)
+	}
+	a.SetDisclaimers(nil)
+	if got := a.Disclaimers(); !slices.Equal(got, DefaultDisclaimers) {
+		t.Fatalf("SetDisclaimers(nil) left %q, want defaults", got)
+	}
+
+	none := New(WithDisclaimers([]string{}))
+	if resp := none.Analyze(llmIntake); len(resp.Disclaimers) != 0 {
+		t.Fatalf("disclaimers %q, want none", resp.Disclaimers)
+	}
+}
+
+func TestParseDisclaimers(t *testing.T) {
+	got := ParseDisclaimers("  First line.\n\n\tSecond line.  \r\n")
+	if want := []string{"First line.", "Second line."}; !slices.Equal(got, want) {
+		t.Fatalf("ParseDisclaimers = %q, want %q", got, want)
+	}
+	if got := ParseDisclaimers("\n \n"); got == nil || len(got) != 0 {
+		t.Fatalf("ParseDisclaimers of blank text = %#v, want empty non-nil", got)
+	}
+}
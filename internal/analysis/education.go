@@ -0,0 +1,221 @@
+package analysis
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+//go:embed education/catalog.json
+var embeddedEducation []byte
+
+// EducationCatalog is the vetted patient education material Analyze attaches
+// to responses.
+type EducationCatalog struct {
+	Entries []EducationEntry `json:"entries"`
+}
+
+// EducationEntry is one piece of material with the cases it applies to: a
+// complaint (case-insensitive), a plan medication (matched by substring, as
+// dose caps are), or a flagged issue code. Links holds one translation per
+// language.
+type EducationEntry struct {
+	ID          string     `json:"id"`
+	Complaints  []string   `json:"complaints,omitempty"`
+	Medications []string   `json:"medications,omitempty"`
+	Issues      []string   `json:"issues,omitempty"`
+	Links       []Resource `json:"links"`
+}
+
+var embeddedEducationCatalog = mustEmbeddedEducation()
+
+func mustEmbeddedEducation() EducationCatalog {
+	c, err := parseEducation(embeddedEducation, "embedded")
+	if err != nil {
+		panic(fmt.Sprintf("analysis: %v", err))
+	}
+	return c
+}
+
+func parseEducation(raw []byte, source string) (EducationCatalog, error) {
+	var c EducationCatalog
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return EducationCatalog{}, fmt.Errorf("parse education catalog %s: %w", source, err)
+	}
+	if errs := c.Validate(); len(errs) > 0 {
+		return EducationCatalog{}, fmt.Errorf("invalid education catalog %s: %s", source, strings.Join(errs, "; "))
+	}
+	return c.normalized(), nil
+}
+
+// Validate reports every problem with c: entries without an id, duplicate
+// ids, entries that match nothing, unregistered issue codes, and links
+// without a title, an absolute http(s) URL, or a language, or with a
+// language listed twice.
+func (c EducationCatalog) Validate() []string {
+	var errs []string
+	ids := map[string]int{}
+	for i, e := range c.normalized().Entries {
+		field := fmt.Sprintf("entries[%d]", i)
+		switch j, dup := ids[e.ID]; {
+		case e.ID == "":
+			errs = append(errs, field+".id is required")
+		case dup:
+			errs = append(errs, fmt.Sprintf("%s duplicates the %s entry of entries[%d]", field, e.ID, j))
+		default:
+			ids[e.ID] = i
+		}
+		if len(e.Complaints)+len(e.Medications)+len(e.Issues) == 0 {
+			errs = append(errs, field+" must list complaints, medications, or issues")
+		}
+		for _, code := range e.Issues {
+			if _, ok := issueCatalog[code]; !ok {
+				errs = append(errs, fmt.Sprintf("%s.issues: %q is not a registered issue code", field, code))
+			}
+		}
+		if len(e.Links) == 0 {
+			errs = append(errs, field+".links must list at least one link")
+		}
+		langs := map[string]bool{}
+		for k, l := range e.Links {
+			link := fmt.Sprintf("%s.links[%d]", field, k)
+			if l.Title == "" {
+				errs = append(errs, link+".title is required")
+			}
+			if u, err := url.Parse(l.URL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+				errs = append(errs, fmt.Sprintf("%s.url must be an absolute http(s) URL, not %q", link, l.URL))
+			}
+			switch {
+			case l.Language == "":
+				errs = append(errs, link+".language is required")
+			case langs[l.Language]:
+				errs = append(errs, fmt.Sprintf("%s repeats language %s", link, l.Language))
+			}
+			langs[l.Language] = true
+		}
+	}
+	return errs
+}
+
+// normalized trims ids, titles, and URLs, lowercases complaints and
+// medications, upper-cases issue codes, and normalizes languages the way
+// locales are, so matching compares like with like.
+func (c EducationCatalog) normalized() EducationCatalog {
+	out := EducationCatalog{Entries: make([]EducationEntry, 0, len(c.Entries))}
+	for _, e := range c.Entries {
+		n := EducationEntry{ID: strings.TrimSpace(e.ID)}
+		for _, v := range e.Complaints {
+			n.Complaints = append(n.Complaints, normalizeName(v))
+		}
+		for _, v := range e.Medications {
+			n.Medications = append(n.Medications, normalizeName(v))
+		}
+		for _, v := range e.Issues {
+			n.Issues = append(n.Issues, strings.ToUpper(strings.TrimSpace(v)))
+		}
+		for _, l := range e.Links {
+			n.Links = append(n.Links, Resource{
+				Title:    strings.TrimSpace(l.Title),
+				URL:      strings.TrimSpace(l.URL),
+				Language: normalizeLocale(l.Language),
+			})
+		}
+		out.Entries = append(out.Entries, n)
+	}
+	return out
+}
+
+// match returns the link of every entry that applies, in catalog order. Each
+// entry is listed once however many complaints, plans, or issues point at it.
+func (c EducationCatalog) match(complaints, medications []string, issues []Issue, locale string) []Resource {
+	names := map[string]bool{}
+	for _, v := range complaints {
+		names[normalizeName(v)] = true
+	}
+	codes := map[string]bool{}
+	for _, is := range issues {
+		codes[is.Code] = true
+	}
+	var out []Resource
+	for _, e := range c.Entries {
+		if e.applies(names, medications, codes) {
+			out = append(out, e.link(locale))
+		}
+	}
+	return out
+}
+
+func (e EducationEntry) applies(complaints map[string]bool, medications []string, codes map[string]bool) bool {
+	for _, c := range e.Complaints {
+		if complaints[c] {
+			return true
+		}
+	}
+	for _, m := range e.Medications {
+		for _, med := range medications {
+			if strings.Contains(normalizeName(med), m) {
+				return true
+			}
+		}
+	}
+	for _, code := range e.Issues {
+		if codes[code] {
+			return true
+		}
+	}
+	return false
+}
+
+// link picks the translation for locale, then its base language, then
+// English, then the first listed.
+func (e EducationEntry) link(locale string) Resource {
+	base, _, _ := strings.Cut(locale, "-")
+	for _, want := range []string{locale, base, DefaultLocale} {
+		for _, l := range e.Links {
+			if l.Language == want {
+				return l
+			}
+		}
+	}
+	return e.Links[0]
+}
+
+// SetEducationCatalog validates c and makes it the material attached to
+// responses; on error the current catalog stays active.
+func (a *Analyzer) SetEducationCatalog(c EducationCatalog) error {
+	if errs := c.Validate(); len(errs) > 0 {
+		return fmt.Errorf("invalid education catalog: %s", strings.Join(errs, "; "))
+	}
+	return a.update(func(s *settings) error {
+		s.education = c.normalized()
+		return nil
+	})
+}
+
+func SetEducationCatalog(c EducationCatalog) error {
+	return defaultAnalyzer.SetEducationCatalog(c)
+}
+
+// LoadEducationFile replaces the embedded education catalog with the JSON
+// document at path.
+func (a *Analyzer) LoadEducationFile(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read education catalog: %w", err)
+	}
+	c, err := parseEducation(raw, path)
+	if err != nil {
+		return err
+	}
+	return a.update(func(s *settings) error {
+		s.education = c
+		return nil
+	})
+}
+
+func LoadEducationFile(path string) error {
+	return defaultAnalyzer.LoadEducationFile(path)
+}
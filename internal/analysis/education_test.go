@@ -0,0 +1,87 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEducation_EmbeddedNitrate(t *testing.T) {
+	resp := Analyze(Intake{
+		PatientName: "Education",
+		Age:         60,
+		WeightKg:    80,
+		HeightCm:    175,
+		BP:          "130/85",
+		Medications: []Medication{{Name: "Isosorbide mononitrate", Dosage: "30mg"}},
+		Complaint:   "ED",
+	})
+	urls := map[string]int{}
+	for _, r := range resp.Education {
+		urls[r.URL]++
+	}
+	if urls["https://medlineplus.gov/erectiledysfunction.html"] != 1 || urls["https://www.heart.org/en/health-topics/heart-attack/life-after-a-heart-attack/sex-and-heart-disease"] != 1 {
+		t.Fatalf("education = %+v", resp.Education)
+	}
+	if errs := ValidateResponse(resp); len(errs) > 0 {
+		t.Fatalf("schema errors: %v", errs)
+	}
+}
+
+func TestEducation_LocaleAndDedup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "education.json")
+	doc := `{"entries": [
+		{"id": "bp", "issues": ["BP_UNCONTROLLED", "AGE_OVER_65"], "medications": ["tadalafil"], "links": [
+			{"title": "High blood pressure", "url": "https://example.org/bp", "language": "en"},
+			{"title": "Altapresyon", "url": "https://example.org/tl/bp", "language": "tl"}
+		]},
+		{"id": "ed", "complaints": ["ed"], "links": [
+			{"title": "ED basics", "url": "https://example.org/ed", "language": "en"}
+		]},
+		{"id": "hair", "complaints": ["hair loss"], "links": [
+			{"title": "Hair loss", "url": "https://example.org/hair", "language": "en"}
+		]}
+	]}`
+	if err := os.WriteFile(path, []byte(doc), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	a := New()
+	if err := a.LoadEducationFile(path); err != nil {
+		t.Fatal(err)
+	}
+	in := Intake{PatientName: "Education", Age: 70, WeightKg: 80, HeightCm: 175, BP: "170/105", Complaint: "ED"}
+
+	resp := a.AnalyzeWithOptions(in, Options{Locale: "tl"})
+	if len(resp.Education) != 2 {
+		t.Fatalf("education = %+v, want bp once and ed", resp.Education)
+	}
+	if got := resp.Education[0]; got.URL != "https://example.org/tl/bp" || got.Language != "tl" {
+		t.Fatalf("tl link = %+v", got)
+	}
+	if got := resp.Education[1]; got.URL != "https://example.org/ed" || got.Language != "en" {
+		t.Fatalf("untranslated link = %+v, want English fallback", got)
+	}
+	if resp := a.Analyze(in); resp.Education[0].Language != "en" {
+		t.Fatalf("en link = %+v", resp.Education[0])
+	}
+}
+
+func TestEducationCatalogValidate(t *testing.T) {
+	if errs := embeddedEducationCatalog.Validate(); len(errs) > 0 {
+		t.Fatalf("embedded catalog invalid: %v", errs)
+	}
+	c := EducationCatalog{Entries: []EducationEntry{
+		{ID: "a", Issues: []string{"NOT_A_CODE"}, Links: []Resource{{Title: "A", URL: "/relative", Language: "en"}, {Title: "B", URL: "https://example.org", Language: "EN"}}},
+		{ID: "a", Links: nil},
+	}}
+	errs := strings.Join(c.Validate(), "\n")
+	for _, want := range []string{`"NOT_A_CODE" is not a registered issue code`, "links[0].url", "repeats language en", "duplicates the a entry", "entries[1] must list", "entries[1].links must list"} {
+		if !strings.Contains(errs, want) {
+			t.Errorf("errors missing %q:\n%s", want, errs)
+		}
+	}
+	if err := New().SetEducationCatalog(c); err == nil {
+		t.Fatal("invalid catalog accepted")
+	}
+}
@@ -0,0 +1,71 @@
+package analysis
+
+import "strings"
+
+// Canonical family history entries read by the rule engine.
+const (
+	famPrematureCAD       = "premature cad"
+	famSuddenCardiacDeath = "sudden cardiac death"
+	famDiabetes           = "diabetes"
+	famProstateCancer     = "prostate cancer"
+)
+
+// familyHistoryTerms maps family history phrasings to canonical entries,
+// matched like conditionTerms. Premature CAD means onset in a male
+// first-degree relative before 55 or a female one before 65; an entry must
+// say so, as plain "heart disease" in a relative is too common to score.
+var familyHistoryTerms = []canonicalTerms{
+	{famPrematureCAD, []string{
+		"premature cad", "premature coronary artery disease", "premature coronary heart disease", "premature chd",
+		"premature heart disease", "premature mi", "premature myocardial infarction", "premature heart attack",
+		"early cad", "early coronary artery disease", "early heart disease", "early mi", "early heart attack",
+	}},
+	{famSuddenCardiacDeath, []string{
+		"sudden cardiac death", "scd", "sudden death", "sudden cardiac arrest", "cardiac arrest",
+	}},
+	{famDiabetes, diabetesTerms},
+	{famProstateCancer, []string{
+		"prostate cancer", "prostatic cancer", "prostate ca", "prostate carcinoma", "prostatic carcinoma",
+		"prostate adenocarcinoma",
+	}},
+}
+
+// normalizeFamilyHistory maps each entry to its canonical family history
+// entries, returning those that match none in input order.
+func normalizeFamilyHistory(values []string) (map[string]bool, []string) {
+	return matchTerms(values, familyHistoryTerms)
+}
+
+// assessFamilyHistory adds a risk point and an info issue for a family
+// history of premature CAD when a complaint is cardiometabolic, and reports
+// entries no rule reads. The prostate cancer check runs against the plan;
+// see familyPlanIssues.
+func assessFamilyHistory(family map[string]bool, unmapped []string, in Intake, risk *riskAccumulator, l localizer) []Issue {
+	var issues []Issue
+	if len(unmapped) > 0 {
+		issues = append(issues, newIssue("FAMILY_HISTORY_UNMAPPED", SeverityInfo, l.issue("FAMILY_HISTORY_UNMAPPED", map[string]any{"Entries": strings.Join(unmapped, ", ")})))
+	}
+	if risk.check("family_premature_cad", "FAMILY_PREMATURE_CAD", family[famPrematureCAD] && cardiometabolicComplaint(in), func() map[string]any {
+		return map[string]any{"familyPrematureCad": family[famPrematureCAD], "cardiometabolicComplaint": cardiometabolicComplaint(in)}
+	}) {
+		risk.add("family_premature_cad", "Family history of premature CAD")
+		issues = append(issues, newIssue("FAMILY_PREMATURE_CAD", SeverityInfo, l.issue("FAMILY_PREMATURE_CAD", nil)))
+	}
+	return issues
+}
+
+// familyPlanIssues warns before finasteride for a patient with a family
+// history of prostate cancer: finasteride lowers PSA by about half, which
+// can mask a rising PSA.
+func familyPlanIssues(family map[string]bool, plans []ComplaintPlan, l localizer) []Issue {
+	if !family[famProstateCancer] {
+		return nil
+	}
+	var issues []Issue
+	for _, cp := range plans {
+		if normalizeName(cp.Plan.Medication) == "finasteride" {
+			issues = append(issues, newIssue("FAMILY_PROSTATE_CANCER_FINASTERIDE", SeverityWarning, l.issue("FAMILY_PROSTATE_CANCER_FINASTERIDE", nil), cp.Plan.Medication))
+		}
+	}
+	return issues
+}
@@ -0,0 +1,74 @@
+package analysis
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNormalizeFamilyHistory(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"Premature CAD", []string{famPrematureCAD}},
+		{"father: early MI at 48", []string{famPrematureCAD}},
+		{"brother - sudden cardiac death", []string{famSuddenCardiacDeath}},
+		{"mother T2DM", []string{famDiabetes}},
+		{"Prostate Ca (father)", []string{famProstateCancer}},
+		{"heart disease", nil},
+	}
+	for _, tt := range tests {
+		set, unmapped := normalizeFamilyHistory([]string{tt.in})
+		var got []string
+		for _, f := range familyHistoryTerms {
+			if set[f.Canonical] {
+				got = append(got, f.Canonical)
+			}
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("normalizeFamilyHistory(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+		if (len(unmapped) > 0) != (tt.want == nil) {
+			t.Errorf("normalizeFamilyHistory(%q) unmapped = %v", tt.in, unmapped)
+		}
+	}
+}
+
+func TestAnalyze_FamilyHistory(t *testing.T) {
+	has := func(resp Response, code string) bool {
+		return slices.ContainsFunc(resp.FlaggedIssues, func(i Issue) bool { return i.Code == code })
+	}
+	scored := func(resp Response) bool {
+		return slices.ContainsFunc(resp.RiskFactors, func(f RiskFactor) bool { return f.Code == "family_premature_cad" })
+	}
+	a := New()
+
+	in := llmIntake
+	in.Complaint = "Weight Loss"
+	in.FamilyHistory = []string{"premature CAD", "prostate cancer", "gout"}
+	resp := a.Analyze(in)
+	if !scored(resp) || !has(resp, "FAMILY_PREMATURE_CAD") || !has(resp, "FAMILY_HISTORY_UNMAPPED") {
+		t.Errorf("weight loss: factors %+v, issues %+v", resp.RiskFactors, resp.FlaggedIssues)
+	}
+	if has(resp, "FAMILY_PROSTATE_CANCER_FINASTERIDE") {
+		t.Errorf("weight loss plan flagged for finasteride: %+v", resp.FlaggedIssues)
+	}
+	if want := []string{famPrematureCAD, famProstateCancer}; !slices.Equal(resp.FamilyHistory, want) {
+		t.Errorf("familyHistory = %v, want %v", resp.FamilyHistory, want)
+	}
+
+	in.Complaint = "Hair Loss"
+	resp = a.Analyze(in)
+	if scored(resp) || has(resp, "FAMILY_PREMATURE_CAD") {
+		t.Errorf("hair loss scored premature CAD: %+v", resp.RiskFactors)
+	}
+	i := slices.IndexFunc(resp.FlaggedIssues, func(i Issue) bool { return i.Code == "FAMILY_PROSTATE_CANCER_FINASTERIDE" })
+	if i < 0 || resp.FlaggedIssues[i].Severity != SeverityWarning {
+		t.Fatalf("hair loss: issues %+v", resp.FlaggedIssues)
+	}
+
+	in.PriorTreatments = []PriorTreatment{{Medication: "finasteride", Outcome: "intolerant"}}
+	if resp := a.Analyze(in); has(resp, "FAMILY_PROSTATE_CANCER_FINASTERIDE") {
+		t.Errorf("flagged without a finasteride plan: %+v", resp.FlaggedIssues)
+	}
+}
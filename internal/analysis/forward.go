@@ -0,0 +1,65 @@
+package analysis
+
+import (
+	"context"
+	"slices"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+// ForwardedAudit is what an AuditForwarder is given for each written audit:
+// the audit's summary, which names the patient by pseudonymous reference
+// only, and the org it was written under.
+type ForwardedAudit struct {
+	AuditSummary
+	// Org is empty when the deployment is not partitioned.
+	Org string `json:"org,omitempty"`
+}
+
+// AuditForwarder ships audit records to an external log, e.g. syslog.
+// Forward is called on the request path once the audit is written and must
+// not block; forward.Forwarder queues records and delivers them in the
+// background.
+type AuditForwarder interface {
+	Forward(rec ForwardedAudit)
+}
+
+// WithAuditForwarder adds f to the forwarders given every written audit; nil
+// is ignored.
+func WithAuditForwarder(f AuditForwarder) Option {
+	return func(a *Analyzer) {
+		if f != nil {
+			a.s.forwarders = append(a.s.forwarders, f)
+		}
+	}
+}
+
+// AddAuditForwarder adds f to the forwarders given every written audit. nil
+// is ignored.
+func (a *Analyzer) AddAuditForwarder(f AuditForwarder) {
+	if f == nil {
+		return
+	}
+	_ = a.update(func(s *settings) error {
+		s.forwarders = append(slices.Clip(s.forwarders), f)
+		return nil
+	})
+}
+
+func AddAuditForwarder(f AuditForwarder) {
+	defaultAnalyzer.AddAuditForwarder(f)
+}
+
+// forwarder returns the hook that hands a written audit to s's forwarders, or
+// nil when there are none.
+func forwarder(s settings) func(ctx context.Context, sum audit.Summary) {
+	if len(s.forwarders) == 0 {
+		return nil
+	}
+	return func(ctx context.Context, sum audit.Summary) {
+		rec := ForwardedAudit{AuditSummary: auditSummary(sum), Org: audit.OrgFrom(ctx)}
+		for _, f := range s.forwarders {
+			f.Forward(rec)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package analysis
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+type recordingForwarder struct {
+	mu   sync.Mutex
+	recs []ForwardedAudit
+}
+
+func (f *recordingForwarder) Forward(rec ForwardedAudit) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.recs = append(f.recs, rec)
+}
+
+func TestAuditForwarder(t *testing.T) {
+	f := &recordingForwarder{}
+	a := New(WithAuditStore(audit.NewMemoryStore()), WithAuditForwarder(f))
+	in := Intake{PatientName: "Jane Roe", Age: 30, WeightKg: 70, HeightCm: 175, BP: "118/76", Complaint: "ED"}
+
+	resp := a.AnalyzeContext(audit.WithOrg(t.Context(), "clinic-a"), in, Options{})
+	a.AnalyzeContext(t.Context(), in, Options{DryRun: true})
+	a.AnalyzeContext(t.Context(), Intake{}, Options{})
+	if len(f.recs) != 1 {
+		t.Fatalf("forwarded %d records, want only the written audit's", len(f.recs))
+	}
+	rec := f.recs[0]
+	if rec.AuditID != resp.AuditID || rec.Org != "clinic-a" || rec.PatientRef == "" || rec.RiskLevel != resp.RiskLevel || rec.At != resp.AuditAt {
+		t.Fatalf("record = %+v", rec)
+	}
+	if body, _ := json.Marshal(rec); strings.Contains(string(body), "Jane") {
+		t.Fatalf("record names the patient: %s", body)
+	}
+}
@@ -0,0 +1,118 @@
+package analysis
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/testgen"
+)
+
+// Adversarial inputs found so far, seeding every target that reads them.
+var (
+	fuzzBPs = []string{
+		"120/80", " 135 / 88 ", "150/95mmHg", "120 OVER 80 mm Hg", "BP: 142/91",
+		"１２０/８０", "120 / 80", "120/80\x00", "0120/080", "999/1",
+		"120/80/60", "80/120", "12/8", "", " ", "abc",
+	}
+	fuzzDoses = []string{
+		"50mg", "50 MG", ".5mg", "5..0mg", "1e3mg", "10/20 mg", "-5mg", "mg",
+		"5 mg mg", "9999999999999999999999999999999mg", "5mg (start low due to renal/hepatic risk)",
+	}
+	fuzzMedNames = []string{
+		"Nitroglycerin", " NITROGLYCERIN ", "isosorbide mononitrate", "Sildenafil/Dapoxetine",
+		"amlodipine-benazepril", "sil\u200bdenafil", "a/   /b", "Viagra 50", "tadalafil 5mg", "",
+	}
+)
+
+func FuzzParseBP(f *testing.F) {
+	for _, bp := range fuzzBPs {
+		f.Add(bp)
+	}
+	f.Fuzz(func(t *testing.T, bp string) {
+		checkBPInvariants(t, bp)
+	})
+}
+
+func FuzzExtractDose(f *testing.F) {
+	for _, dose := range fuzzDoses {
+		f.Add(dose)
+	}
+	f.Fuzz(func(t *testing.T, dose string) {
+		checkDoseInvariants(t, dose)
+	})
+}
+
+func FuzzNormalizeMeds(f *testing.F) {
+	for i, name := range fuzzMedNames {
+		f.Add(name, fuzzDoses[i%len(fuzzDoses)], fuzzMedNames[(i+1)%len(fuzzMedNames)])
+	}
+	f.Fuzz(func(t *testing.T, name, dose, other string) {
+		checkMedsInvariants(t, []Medication{{Name: name, Dosage: dose}, {Name: other}})
+	})
+}
+
+// FuzzAnalyze decodes arbitrary JSON into an Intake and checks the response
+// invariants. The corpus is seeded with the golden intakes, generated ones,
+// and intakes carrying the adversarial strings above.
+func FuzzAnalyze(f *testing.F) {
+	goldens, _ := filepath.Glob(filepath.Join("testdata", "golden", "*.intake.json"))
+	for _, path := range goldens {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(raw)
+	}
+	add := func(in Intake) {
+		raw, err := json.Marshal(in)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(raw)
+	}
+	for seed := range int64(8) {
+		add(testgen.New(seed).Intake())
+	}
+	for i, name := range fuzzMedNames {
+		add(Intake{
+			PatientName: "Fuzz", Age: 60, WeightKg: 85, HeightCm: 175,
+			BP:          fuzzBPs[i%len(fuzzBPs)],
+			Complaint:   "ED",
+			Conditions:  []string{"Heart Disease"},
+			Medications: []Medication{{Name: name, Dosage: fuzzDoses[i%len(fuzzDoses)], Frequency: "PRN"}},
+			Allergies:   []string{fuzzMedNames[(i+3)%len(fuzzMedNames)]},
+		})
+	}
+	f.Add([]byte(`{"age":-1,"weight":1e308,"height":0.0001,"bp":"120/80","complaint":"ED"}`))
+	f.Add([]byte(`{"patientName":"x","age":50,"weight":80,"height":175,"bp":"120/80","complaint":"ED","medications":[{"name":"nitroglycerin"}],"complaints":["ED","hair loss","ED"]}`))
+	a := New(WithClock(fixedClock), WithIDGenerator(fixedID))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var in Intake
+		if err := json.Unmarshal(data, &in); err != nil {
+			return
+		}
+		checkResponseInvariants(t, in, a.AnalyzeWithOptions(in, Options{DryRun: true}))
+	})
+}
+
+// FuzzAnalyzeGenerated runs generated intakes, optionally with a fuzzed BP
+// string and complaint, through the same invariants.
+func FuzzAnalyzeGenerated(f *testing.F) {
+	f.Add(int64(1), "", "")
+	f.Add(int64(2), "150/95", "ED")
+	f.Add(int64(3), "999/1", "weight loss")
+	f.Add(int64(4), "abc", "")
+	a := New(WithClock(fixedClock), WithIDGenerator(fixedID))
+	f.Fuzz(func(t *testing.T, seed int64, bp, complaint string) {
+		in := testgen.New(seed).Intake()
+		if bp != "" {
+			in.BP = bp
+		}
+		if complaint != "" {
+			in.Complaint = complaint
+		}
+		checkResponseInvariants(t, in, a.Analyze(in))
+	})
+}
@@ -0,0 +1,155 @@
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// TestResponseGolden guards the Response contract for existing clients. Each
+// testdata/golden/<name>.intake.json is analyzed with a fixed clock and audit
+// ID and compared against <name>.response.json: every golden field must still
+// be present with the same JSON type and value. New fields are allowed. The
+// normalized intake is included so the goldens pin it too. Run with
+// UPDATE_GOLDEN=1 to accept an intentional change.
+func TestResponseGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "golden", "*.intake.json"))
+	if err != nil || len(inputs) == 0 {
+		t.Fatalf("no golden intakes: %v", err)
+	}
+	update := os.Getenv("UPDATE_GOLDEN") == "1"
+	for _, path := range inputs {
+		name := strings.TrimSuffix(filepath.Base(path), ".intake.json")
+		t.Run(name, func(t *testing.T) {
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var in Intake
+			if err := json.Unmarshal(raw, &in); err != nil {
+				t.Fatal(err)
+			}
+			a := New(WithClock(fixedClock), WithIDGenerator(fixedID))
+			resp := a.AnalyzeWithOptions(in, Options{IncludeNormalized: true})
+			checkResponseInvariants(t, in, resp)
+			got, err := json.MarshalIndent(resp, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			golden := filepath.Join("testdata", "golden", name+".response.json")
+			if update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			wantRaw, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("read golden (run with UPDATE_GOLDEN=1 to create): %v", err)
+			}
+			var want, have any
+			if err := json.Unmarshal(wantRaw, &want); err != nil {
+				t.Fatalf("%s: %v", golden, err)
+			}
+			if err := json.Unmarshal(got, &have); err != nil {
+				t.Fatal(err)
+			}
+			if diffs := compatDiff("$", want, have); len(diffs) > 0 {
+				t.Errorf("response no longer compatible with %s (UPDATE_GOLDEN=1 regenerates):\n  %s", golden, strings.Join(diffs, "\n  "))
+			}
+		})
+	}
+}
+
+// compatDiff lists the ways have breaks a client written against want. Keys
+// only in have are additions and are not reported.
+func compatDiff(path string, want, have any) []string {
+	switch w := want.(type) {
+	case map[string]any:
+		h, ok := have.(map[string]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: object became %s", path, jsonKind(have))}
+		}
+		keys := make([]string, 0, len(w))
+		for k := range w {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var out []string
+		for _, k := range keys {
+			hv, ok := h[k]
+			if !ok {
+				out = append(out, fmt.Sprintf("%s.%s: removed", path, k))
+				continue
+			}
+			out = append(out, compatDiff(path+"."+k, w[k], hv)...)
+		}
+		return out
+	case []any:
+		h, ok := have.([]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: array became %s", path, jsonKind(have))}
+		}
+		if len(h) != len(w) {
+			return []string{fmt.Sprintf("%s: length %d, was %d", path, len(h), len(w))}
+		}
+		var out []string
+		for i := range w {
+			out = append(out, compatDiff(fmt.Sprintf("%s[%d]", path, i), w[i], h[i])...)
+		}
+		return out
+	default:
+		if jsonKind(want) != jsonKind(have) {
+			return []string{fmt.Sprintf("%s: %s became %s", path, jsonKind(want), jsonKind(have))}
+		}
+		if !reflect.DeepEqual(want, have) {
+			return []string{fmt.Sprintf("%s: %v, was %v", path, have, want)}
+		}
+		return nil
+	}
+}
+
+func jsonKind(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func TestCompatDiff(t *testing.T) {
+	want := map[string]any{"a": "x", "b": []any{1.0, 2.0}, "c": map[string]any{"d": true}}
+	cases := []struct {
+		name string
+		have map[string]any
+		n    int
+	}{
+		{"identical", map[string]any{"a": "x", "b": []any{1.0, 2.0}, "c": map[string]any{"d": true}}, 0},
+		{"added field", map[string]any{"a": "x", "b": []any{1.0, 2.0}, "c": map[string]any{"d": true, "e": 1.0}, "z": "new"}, 0},
+		{"removed", map[string]any{"a": "x", "b": []any{1.0, 2.0}, "c": map[string]any{}}, 1},
+		{"type change", map[string]any{"a": 1.0, "b": []any{1.0, 2.0}, "c": map[string]any{"d": true}}, 1},
+		{"value change", map[string]any{"a": "y", "b": []any{1.0, 3.0}, "c": map[string]any{"d": true}}, 2},
+		{"array length", map[string]any{"a": "x", "b": []any{1.0}, "c": map[string]any{"d": true}}, 1},
+	}
+	for _, tc := range cases {
+		if got := compatDiff("$", want, tc.have); len(got) != tc.n {
+			t.Errorf("%s: got %d diffs %v, want %d", tc.name, len(got), got, tc.n)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+// ErrHistogramUnsupported is returned when the audit store cannot bucket
+// audits by time.
+var ErrHistogramUnsupported = errors.New("audit store does not support histograms")
+
+// Histogram counts the analyses audited in [from, to) per day or week, with
+// bucket boundaries at midnight in from's location.
+func (a *Analyzer) Histogram(ctx context.Context, from, to time.Time, bucket string) (audit.Histogram, error) {
+	store, ok := a.settings().store.(audit.HistogramStore)
+	if !ok {
+		return audit.Histogram{}, ErrHistogramUnsupported
+	}
+	return store.Histogram(ctx, from, to, bucket)
+}
+
+func Histogram(ctx context.Context, from, to time.Time, bucket string) (audit.Histogram, error) {
+	return defaultAnalyzer.Histogram(ctx, from, to, bucket)
+}
@@ -0,0 +1,68 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+// ErrHistoryUnsupported is returned when the audit store cannot list audits by patient.
+var ErrHistoryUnsupported = errors.New("audit store does not support patient history")
+
+// riskTrend describes the move from prev to cur.
+func riskTrend(prev, cur int) *RiskTrend {
+	t := &RiskTrend{Delta: cur - prev, Direction: "flat", Arrow: "→"}
+	switch {
+	case t.Delta > 0:
+		t.Direction, t.Arrow = "up", "↑"
+	case t.Delta < 0:
+		t.Direction, t.Arrow = "down", "↓"
+	}
+	return t
+}
+
+// previousAnalysis returns the patient's most recent audit. A store without
+// history or a failed lookup reports none; the trend is informational and
+// never blocks an analysis.
+func previousAnalysis(ctx context.Context, s settings, ref string) (audit.Summary, bool) {
+	store, ok := s.store.(audit.PatientHistory)
+	if !ok || ref == "" {
+		return audit.Summary{}, false
+	}
+	prev, err := store.ListByPatientRef(ctx, ref, 1)
+	if err != nil || len(prev) == 0 {
+		return audit.Summary{}, false
+	}
+	return prev[0], true
+}
+
+// PatientAnalyses returns up to limit of the most recent analyses for
+// patientRef, oldest first, each with its trend from the one before. A limit
+// of 0 uses the store default.
+func (a *Analyzer) PatientAnalyses(ctx context.Context, patientRef string, limit int) (PatientHistory, error) {
+	store, ok := a.settings().store.(audit.PatientHistory)
+	if !ok {
+		return PatientHistory{}, ErrHistoryUnsupported
+	}
+	summaries, err := store.ListByPatientRef(ctx, patientRef, limit)
+	if err != nil {
+		return PatientHistory{}, err
+	}
+	h := PatientHistory{PatientRef: patientRef, Analyses: make([]PatientAnalysis, 0, len(summaries))}
+	for i, sum := range summaries {
+		pa := PatientAnalysis{AuditSummary: auditSummary(sum)}
+		if i > 0 {
+			pa.Trend = riskTrend(summaries[i-1].RiskScore, sum.RiskScore)
+		}
+		h.Analyses = append(h.Analyses, pa)
+	}
+	if n := len(h.Analyses); n > 0 {
+		h.Trend = h.Analyses[n-1].Trend
+	}
+	return h, nil
+}
+
+func PatientAnalyses(ctx context.Context, patientRef string, limit int) (PatientHistory, error) {
+	return defaultAnalyzer.PatientAnalyses(ctx, patientRef, limit)
+}
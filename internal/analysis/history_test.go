@@ -0,0 +1,36 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+func TestRiskTrend(t *testing.T) {
+	cases := []struct {
+		prev, cur int
+		want      RiskTrend
+	}{
+		{3, 7, RiskTrend{Delta: 4, Direction: "up", Arrow: "↑"}},
+		{7, 3, RiskTrend{Delta: -4, Direction: "down", Arrow: "↓"}},
+		{5, 5, RiskTrend{Delta: 0, Direction: "flat", Arrow: "→"}},
+	}
+	for _, tc := range cases {
+		if got := riskTrend(tc.prev, tc.cur); *got != tc.want {
+			t.Errorf("riskTrend(%d, %d) = %+v, want %+v", tc.prev, tc.cur, *got, tc.want)
+		}
+	}
+}
+
+type latestOnlyStore struct{ audit.Store }
+
+func TestPatientAnalyses_Unsupported(t *testing.T) {
+	a := New(WithAuditStore(latestOnlyStore{audit.NewMemoryStore()}))
+	if _, err := a.PatientAnalyses(t.Context(), "p_x", 0); err != ErrHistoryUnsupported {
+		t.Fatalf("err = %v, want ErrHistoryUnsupported", err)
+	}
+	resp := a.Analyze(localeIntake)
+	if resp.AuditID == "" || resp.RiskTrend != nil {
+		t.Fatalf("analysis without history support: %+v", resp)
+	}
+}
@@ -0,0 +1,230 @@
+package analysis
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// DefaultLocale is the locale every message falls back to.
+const DefaultLocale = "en"
+
+//go:embed locales/*.json
+var embeddedLocales embed.FS
+
+// catalog maps locale to message key to template. Keys are "issue.<CODE>" for
+// issue descriptions and "rationale.<plan>" for plan rationales. A catalog is
+// never mutated once installed in settings; loaders build a new one.
+type catalog map[string]map[string]*template.Template
+
+var embeddedCatalog = mustEmbeddedCatalog()
+
+func mustEmbeddedCatalog() catalog {
+	c := catalog{}
+	files, err := embeddedLocales.ReadDir("locales")
+	if err != nil {
+		panic(fmt.Sprintf("analysis: embedded locales: %v", err))
+	}
+	for _, f := range files {
+		raw, err := embeddedLocales.ReadFile("locales/" + f.Name())
+		if err != nil {
+			panic(fmt.Sprintf("analysis: embedded locales: %v", err))
+		}
+		if err := c.add(f.Name(), raw); err != nil {
+			panic(fmt.Sprintf("analysis: embedded locales: %v", err))
+		}
+	}
+	if c[DefaultLocale] == nil {
+		panic("analysis: embedded locales: missing " + DefaultLocale + ".json")
+	}
+	return c
+}
+
+// clone copies the locale index so a loader can add locales without touching
+// the catalog a concurrent analysis is reading.
+func (c catalog) clone() catalog {
+	out := make(catalog, len(c))
+	for loc, msgs := range c {
+		out[loc] = msgs
+	}
+	return out
+}
+
+// add parses a <locale>.json file of key → template and merges it over any
+// messages already loaded for that locale.
+func (c catalog) add(name string, raw []byte) error {
+	locale := normalizeLocale(strings.TrimSuffix(filepath.Base(name), filepath.Ext(name)))
+	var texts map[string]string
+	if err := json.Unmarshal(raw, &texts); err != nil {
+		return fmt.Errorf("locale %s: %w", name, err)
+	}
+	msgs := make(map[string]*template.Template, len(c[locale])+len(texts))
+	for k, t := range c[locale] {
+		msgs[k] = t
+	}
+	for key, text := range texts {
+		if !knownMessageKey(key, c) {
+			return fmt.Errorf("locale %s: unknown message key %q", name, key)
+		}
+		t, err := template.New(key).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return fmt.Errorf("locale %s: %w", name, err)
+		}
+		msgs[key] = t
+	}
+	c[locale] = msgs
+	return nil
+}
+
+// knownMessageKey accepts issue keys for registered codes and any key the
+// default locale defines, so typos in translations fail at load time.
+func knownMessageKey(key string, c catalog) bool {
+	if code, ok := strings.CutPrefix(key, "issue."); ok {
+		if _, ok := issueCatalog[code]; ok {
+			return true
+		}
+	}
+	if c[DefaultLocale] == nil {
+		// Loading the default locale itself.
+		return strings.HasPrefix(key, "rationale.") || strings.HasPrefix(key, "suitability.")
+	}
+	_, ok := c[DefaultLocale][key]
+	return ok
+}
+
+// normalizeLocale lowercases a language tag and uses '-' as the separator.
+func normalizeLocale(tag string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+}
+
+// localeAliases maps tags to the catalog locale that serves them.
+var localeAliases = map[string]string{
+	"fil": "tl",
+}
+
+// LoadLocaleDir merges every <locale>.json file in dir into the message
+// catalog; files for a locale that already exists override it key by key. On
+// error the current catalog stays active.
+func (a *Analyzer) LoadLocaleDir(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return fmt.Errorf("load locales: %w", err)
+	}
+	return a.update(func(s *settings) error {
+		next := s.locales.clone()
+		for _, path := range paths {
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("load locales: %w", err)
+			}
+			if err := next.add(path, raw); err != nil {
+				return err
+			}
+		}
+		s.locales = next
+		return nil
+	})
+}
+
+func LoadLocaleDir(dir string) error {
+	return defaultAnalyzer.LoadLocaleDir(dir)
+}
+
+// Locales lists the loaded locales, sorted.
+func (a *Analyzer) Locales() []string {
+	c := a.settings().locales
+	out := make([]string, 0, len(c))
+	for loc := range c {
+		out = append(out, loc)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func Locales() []string {
+	return defaultAnalyzer.Locales()
+}
+
+// MatchLocale returns the first loaded locale matching prefs, in order. A tag
+// matches exactly or by its base language ("tl-PH" → "tl"); nothing matching
+// gives DefaultLocale.
+func (a *Analyzer) MatchLocale(prefs ...string) string {
+	c := a.settings().locales
+	for _, p := range prefs {
+		tag := normalizeLocale(p)
+		base, _, _ := strings.Cut(tag, "-")
+		for _, cand := range []string{tag, localeAliases[tag], base, localeAliases[base]} {
+			if _, ok := c[cand]; ok && cand != "" {
+				return cand
+			}
+		}
+	}
+	return DefaultLocale
+}
+
+func MatchLocale(prefs ...string) string {
+	return defaultAnalyzer.MatchLocale(prefs...)
+}
+
+// localizer renders messages for one analysis. The zero value renders
+// English from the embedded catalog.
+type localizer struct {
+	c      catalog
+	locale string
+}
+
+func (s settings) localizer(locale string) localizer {
+	locale = normalizeLocale(locale)
+	if _, ok := s.locales[locale]; !ok {
+		locale = DefaultLocale
+	}
+	return localizer{c: s.locales, locale: locale}
+}
+
+// warnedKeys remembers missing translations already logged.
+var warnedKeys sync.Map
+
+// text renders key with data. A key missing from the locale falls back to
+// English with a one-time warning; a key English lacks too gives fallback.
+func (l localizer) text(key string, data map[string]any, fallback string) string {
+	if l.c == nil {
+		l.c = embeddedCatalog
+	}
+	if l.locale != "" && l.locale != DefaultLocale {
+		if t, ok := l.c[l.locale][key]; ok {
+			out, err := execMessage(t, data)
+			if err == nil {
+				return out
+			}
+			log.Printf("locale %s: render %s: %v; using %s", l.locale, key, err, DefaultLocale)
+		} else if _, dup := warnedKeys.LoadOrStore(l.locale+"|"+key, true); !dup {
+			log.Printf("locale %s: missing message %s; using %s", l.locale, key, DefaultLocale)
+		}
+	}
+	if t, ok := l.c[DefaultLocale][key]; ok {
+		if out, err := execMessage(t, data); err == nil {
+			return out
+		}
+	}
+	return fallback
+}
+
+// issue renders the description for code.
+func (l localizer) issue(code string, data map[string]any) string {
+	return l.text("issue."+code, data, "")
+}
+
+func execMessage(t *template.Template, data map[string]any) (string, error) {
+	var sb strings.Builder
+	if err := t.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
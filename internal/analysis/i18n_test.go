@@ -0,0 +1,165 @@
+package analysis
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestLocalesComplete keeps shipped translations in step with English: every
+// English key and every built-in interaction rule needs a translation.
+func TestLocalesComplete(t *testing.T) {
+	en := embeddedCatalog[DefaultLocale]
+	for code := range issueCatalog {
+		isRule := false
+		for _, r := range interactionRules {
+			isRule = isRule || r.Code == code
+		}
+		if _, ok := en["issue."+code]; !ok && !isRule {
+			t.Errorf("en: missing issue.%s", code)
+		}
+	}
+	for loc, msgs := range embeddedCatalog {
+		for key := range en {
+			if _, ok := msgs[key]; !ok {
+				t.Errorf("%s: missing %s", loc, key)
+			}
+		}
+		if loc == DefaultLocale {
+			continue
+		}
+		for _, r := range interactionRules {
+			if _, ok := msgs["issue."+r.Code]; !ok {
+				t.Errorf("%s: missing issue.%s", loc, r.Code)
+			}
+		}
+	}
+}
+
+var localeIntake = Intake{
+	PatientName: "Lokal",
+	Age:         70,
+	WeightKg:    95,
+	HeightCm:    170,
+	BP:          "150/95",
+	Complaint:   "ED",
+	Conditions:  []string{"heart disease"},
+	Medications: []Medication{{Name: "amlodipine"}, {Name: "simvastatin"}},
+}
+
+func TestAnalyze_Tagalog(t *testing.T) {
+	a := New()
+	en := a.Analyze(localeIntake)
+	tl := a.AnalyzeWithOptions(localeIntake, Options{Locale: "tl"})
+
+	if len(en.FlaggedIssues) != len(tl.FlaggedIssues) {
+		t.Fatalf("issue count differs: en %d, tl %d", len(en.FlaggedIssues), len(tl.FlaggedIssues))
+	}
+	for i := range en.FlaggedIssues {
+		e, l := en.FlaggedIssues[i], tl.FlaggedIssues[i]
+		if e.Code != l.Code || e.Severity != l.Severity {
+			t.Fatalf("issue %d: en %s/%s, tl %s/%s", i, e.Code, e.Severity, l.Code, l.Severity)
+		}
+		if e.Description == l.Description {
+			t.Errorf("%s not translated: %q", e.Code, l.Description)
+		}
+	}
+	if want := "Ang BMI na 32.9"; !containsIssue(tl.FlaggedIssues, "BMI_OBESITY", want) {
+		t.Errorf("BMI_OBESITY should interpolate BMI as %q", want)
+	}
+	if !strings.Contains(tl.RecommendedPlan.Rationale, "May kasaysayan sa puso") {
+		t.Errorf("tl rationale missing cardiac fragment: %q", tl.RecommendedPlan.Rationale)
+	}
+	if !strings.HasSuffix(en.RecommendedPlan.Rationale, "Encourage weight and activity changes to improve ED and cardiometabolic profile.") {
+		t.Errorf("en rationale changed: %q", en.RecommendedPlan.Rationale)
+	}
+	if en.RiskScore != tl.RiskScore || en.RiskLevel != tl.RiskLevel {
+		t.Fatalf("locale changed risk: en %d %s, tl %d %s", en.RiskScore, en.RiskLevel, tl.RiskScore, tl.RiskLevel)
+	}
+}
+
+func containsIssue(issues []Issue, code, text string) bool {
+	for _, is := range issues {
+		if is.Code == code && strings.Contains(is.Description, text) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLoadLocaleDir_FallsBackToEnglish(t *testing.T) {
+	dir := t.TempDir()
+	writeLocale(t, dir, "es.json", `{"issue.BMI_OBESITY": "IMC {{.BMI}} indica obesidad."}`)
+	a := New()
+	if err := a.LoadLocaleDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if got := a.Locales(); strings.Join(got, ",") != "en,es,tl" {
+		t.Fatalf("Locales() = %v", got)
+	}
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	resp := a.AnalyzeWithOptions(localeIntake, Options{Locale: a.MatchLocale("es-MX")})
+	a.AnalyzeWithOptions(localeIntake, Options{Locale: "es"})
+
+	if !containsIssue(resp.FlaggedIssues, "BMI_OBESITY", "IMC 32.9 indica obesidad.") {
+		t.Errorf("translated key not used: %+v", resp.FlaggedIssues)
+	}
+	if !containsIssue(resp.FlaggedIssues, "COND_HEART_DISEASE", "History of heart disease") {
+		t.Errorf("missing key should fall back to English: %+v", resp.FlaggedIssues)
+	}
+	if n := strings.Count(logs.String(), "locale es: missing message issue.COND_HEART_DISEASE"); n != 1 {
+		t.Errorf("want one warning for the missing key, got %d:\n%s", n, logs.String())
+	}
+}
+
+func TestLoadLocaleDir_RejectsBadFiles(t *testing.T) {
+	for name, body := range map[string]string{
+		"unknown key":  `{"issue.NOT_A_CODE": "x"}`,
+		"bad template": `{"issue.BMI_OBESITY": "{{.BMI"}`,
+		"not json":     `[`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeLocale(t, dir, "de.json", body)
+			a := New()
+			if err := a.LoadLocaleDir(dir); err == nil {
+				t.Fatal("expected error")
+			}
+			if got := a.MatchLocale("de"); got != DefaultLocale {
+				t.Fatalf("failed load should keep the old catalog, matched %q", got)
+			}
+		})
+	}
+}
+
+func TestMatchLocale(t *testing.T) {
+	cases := []struct {
+		prefs []string
+		want  string
+	}{
+		{nil, "en"},
+		{[]string{"TL_ph"}, "tl"},
+		{[]string{"fil-PH"}, "tl"},
+		{[]string{"fr", "tl"}, "tl"},
+		{[]string{"en-GB", "tl"}, "en"},
+		{[]string{"zz"}, "en"},
+	}
+	for _, tc := range cases {
+		if got := MatchLocale(tc.prefs...); got != tc.want {
+			t.Errorf("MatchLocale(%v) = %q, want %q", tc.prefs, got, tc.want)
+		}
+	}
+}
+
+func writeLocale(t *testing.T, dir, name, body string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,141 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DrugClass groups medications for class-level interaction and
+// duplicate-therapy checks. Members match normalized names by substring, as
+// matchingMedications does.
+type DrugClass struct {
+	Name    string   `json:"name"`
+	Members []string `json:"members"`
+}
+
+func (c DrugClass) has(name string) bool {
+	n := strings.ToLower(name)
+	for _, m := range c.Members {
+		if strings.Contains(n, m) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	classPDE5         = DrugClass{"PDE5 inhibitor", []string{"tadalafil", "sildenafil", "vardenafil"}}
+	classAlphaBlocker = DrugClass{"alpha-blocker", []string{"tamsulosin", "doxazosin", "terazosin", "alfuzosin", "silodosin", "prazosin"}}
+	classNitrate      = DrugClass{"nitrate", []string{"nitroglycerin", "isosorbide", "nitrate"}}
+)
+
+// drugClasses is the built-in class taxonomy. A medication list with two
+// members of one class is flagged as duplicate therapy.
+var drugClasses = []DrugClass{
+	classPDE5,
+	classAlphaBlocker,
+	classNitrate,
+	{"statin", []string{"simvastatin", "atorvastatin", "rosuvastatin", "pravastatin", "lovastatin"}},
+	{"calcium channel blocker", []string{"amlodipine", "nifedipine", "felodipine", "diltiazem", "verapamil"}},
+	{"ACE inhibitor", []string{"lisinopril", "enalapril", "ramipril", "captopril", "perindopril"}},
+	{"angiotensin receptor blocker", []string{"losartan", "valsartan", "irbesartan", "telmisartan", "olmesartan"}},
+	{"5-alpha-reductase inhibitor", []string{"finasteride", "dutasteride"}},
+}
+
+// maxInteractionMedications bounds one interaction check.
+const maxInteractionMedications = 50
+
+// CheckInteractions runs the medication checks of the analysis pipeline on a
+// bare medication list: PDE5 contraindications and interactions, the
+// interaction ruleset, duplicate therapy within a drug class, and dose caps.
+// Nothing is audited and no patient fields are needed. Findings are grouped
+// by the drugs they involve.
+func (a *Analyzer) CheckInteractions(req InteractionRequest, opts Options) InteractionReport {
+	report := InteractionReport{Medications: []string{}, Pairs: []InteractionPair{}}
+	meds := normalizeMeds(req.Medications)
+	switch {
+	case len(meds) == 0:
+		report.ValidationErrors = []string{"medications must name at least one drug"}
+		return report
+	case len(req.Medications) > maxInteractionMedications:
+		report.ValidationErrors = []string{fmt.Sprintf("medications must hold at most %d entries", maxInteractionMedications)}
+		return report
+	}
+	for m := range meds {
+		report.Medications = append(report.Medications, m)
+	}
+	sort.Strings(report.Medications)
+
+	s := a.settings()
+	l := s.localizer(opts.Locale)
+	var issues []Issue
+	prnNitrates, scheduledNitrates := nitrateUse(req.Medications)
+	for _, pde5 := range matchingMedications(meds, classPDE5.Members) {
+		for _, nitrate := range scheduledNitrates {
+			issues = append(issues, nitrateIssue(false, l, pde5, nitrate))
+		}
+		for _, nitrate := range prnNitrates {
+			issues = append(issues, nitrateIssue(true, l, pde5, nitrate))
+		}
+		if meds["amlodipine"] {
+			issues = append(issues, newIssue("DDI_PDE5_AMLODIPINE", SeverityWarning, l.issue("DDI_PDE5_AMLODIPINE", nil), pde5, "amlodipine"))
+		}
+		for _, ab := range matchingMedications(meds, classAlphaBlocker.Members) {
+			if strings.Contains(ab, "tamsulosin") {
+				issues = append(issues, newIssue("DDI_PDE5_TAMSULOSIN", SeverityWarning, l.issue("DDI_PDE5_TAMSULOSIN", nil), pde5, ab))
+				continue
+			}
+			issues = append(issues, newIssue("DDI_PDE5_ALPHA_BLOCKER", SeverityWarning, l.issue("DDI_PDE5_ALPHA_BLOCKER", map[string]any{"Medication": ab}), pde5, ab))
+		}
+	}
+	issues = append(issues, interactionIssues(meds, s.rules.InteractionRules(), l)...)
+	for _, c := range s.drugClasses {
+		members := matchingMedications(meds, c.Members)
+		for i := range members {
+			for _, other := range members[i+1:] {
+				data := map[string]any{"First": members[i], "Second": other, "Class": c.Name}
+				issues = append(issues, newIssue("DUP_THERAPY", SeverityWarning, l.issue("DUP_THERAPY", data), members[i], other))
+			}
+		}
+	}
+	for _, m := range req.Medications {
+		if exceedsDose(s.doseCaps, m.Name, m.Dosage) {
+			data := map[string]any{"Dosage": m.Dosage, "Medication": m.Name}
+			issues = append(issues, newIssue("DOSE_CAP_PDE5", SeverityWarning, l.issue("DOSE_CAP_PDE5", data), m.Name))
+		}
+	}
+
+	report.Pairs = groupByDrugs(issues)
+	return report
+}
+
+func CheckInteractions(req InteractionRequest, opts Options) InteractionReport {
+	return defaultAnalyzer.CheckInteractions(req, opts)
+}
+
+// groupByDrugs buckets issues by their sorted related medications, finalizes
+// each bucket, and orders the buckets by drug names.
+func groupByDrugs(issues []Issue) []InteractionPair {
+	index := map[string]int{}
+	out := []InteractionPair{}
+	for _, is := range issues {
+		drugs := append([]string(nil), is.RelatedMedications...)
+		sort.Strings(drugs)
+		key := strings.Join(drugs, "\x00")
+		i, ok := index[key]
+		if !ok {
+			i = len(out)
+			index[key] = i
+			out = append(out, InteractionPair{Drugs: drugs})
+		}
+		out[i].Issues = append(out[i].Issues, is)
+	}
+	for i := range out {
+		out[i].Issues = finalizeIssues(out[i].Issues)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return strings.Join(out[i].Drugs, "\x00") < strings.Join(out[j].Drugs, "\x00")
+	})
+	return out
+}
@@ -0,0 +1,79 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+)
+
+func pairCodes(r InteractionReport) map[string][]string {
+	out := map[string][]string{}
+	for _, p := range r.Pairs {
+		key := strings.Join(p.Drugs, "+")
+		for _, is := range p.Issues {
+			out[key] = append(out[key], is.Code)
+		}
+	}
+	return out
+}
+
+func TestCheckInteractions(t *testing.T) {
+	r := New().CheckInteractions(InteractionRequest{Medications: []Medication{
+		{Name: "Sildenafil", Dosage: "100mg"},
+		{Name: "tamsulosin"},
+		{Name: " Doxazosin "},
+	}}, Options{})
+	if len(r.ValidationErrors) > 0 {
+		t.Fatalf("validation: %v", r.ValidationErrors)
+	}
+	if got := strings.Join(r.Medications, ","); got != "doxazosin,sildenafil,tamsulosin" {
+		t.Fatalf("medications = %s", got)
+	}
+	want := map[string]string{
+		"sildenafil+tamsulosin": "DDI_PDE5_TAMSULOSIN",
+		"doxazosin+sildenafil":  "DDI_PDE5_ALPHA_BLOCKER",
+		"doxazosin+tamsulosin":  "DUP_THERAPY",
+		"sildenafil":            "DOSE_CAP_PDE5",
+	}
+	got := pairCodes(r)
+	if len(got) != len(want) {
+		t.Fatalf("pairs = %v, want %v", got, want)
+	}
+	for pair, code := range want {
+		if codes := got[pair]; len(codes) != 1 || codes[0] != code {
+			t.Errorf("%s: codes %v, want %s", pair, codes, code)
+		}
+	}
+	for i := 1; i < len(r.Pairs); i++ {
+		if strings.Join(r.Pairs[i-1].Drugs, "+") > strings.Join(r.Pairs[i].Drugs, "+") {
+			t.Fatalf("pairs not sorted: %v before %v", r.Pairs[i-1].Drugs, r.Pairs[i].Drugs)
+		}
+	}
+}
+
+func TestCheckInteractions_ContraindicationAndRules(t *testing.T) {
+	r := New().CheckInteractions(InteractionRequest{Medications: []Medication{
+		{Name: "tadalafil"}, {Name: "isosorbide mononitrate"}, {Name: "amlodipine"}, {Name: "simvastatin"},
+	}}, Options{Locale: "tl"})
+	got := pairCodes(r)
+	for pair, code := range map[string]string{
+		"isosorbide mononitrate+tadalafil": "CI_NITRATE_PDE5",
+		"amlodipine+tadalafil":             "DDI_PDE5_AMLODIPINE",
+		"amlodipine+simvastatin":           "DDI_AMLODIPINE_SIMVASTATIN",
+	} {
+		if codes := got[pair]; len(codes) != 1 || codes[0] != code {
+			t.Errorf("%s: codes %v, want %s", pair, codes, code)
+		}
+	}
+	if r.Pairs[0].Issues[0].Description == "" {
+		t.Fatal("issues should be described in the requested locale")
+	}
+}
+
+func TestCheckInteractions_Validation(t *testing.T) {
+	if r := CheckInteractions(InteractionRequest{Medications: []Medication{{Name: " "}}}, Options{}); len(r.ValidationErrors) == 0 {
+		t.Fatal("a list with no named drug should fail validation")
+	}
+	if r := CheckInteractions(InteractionRequest{Medications: []Medication{{Name: "aspirin"}}}, Options{}); len(r.ValidationErrors) > 0 || len(r.Pairs) != 0 {
+		t.Fatalf("one unremarkable drug: %+v", r)
+	}
+}
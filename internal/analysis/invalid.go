@@ -0,0 +1,100 @@
+package analysis
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/metrics"
+)
+
+var validationFailures = metrics.NewCounter("validation_failures_total", "Intake validation errors of rejected analyses by code.", "code")
+
+// ErrValidationFailuresUnsupported is returned when the audit store does not
+// keep validation failures.
+var ErrValidationFailuresUnsupported = errors.New("audit store does not keep validation failures")
+
+func countValidationFailure(errs []FieldError) {
+	for _, e := range errs {
+		validationFailures.Inc(e.Code)
+	}
+}
+
+// validationFailure builds the record of an intake rejected with errs. It
+// keeps fields and codes only; the user ID is dropped when it is one of the
+// rejected fields, as it then cannot be trusted in a log.
+func (a *Analyzer) validationFailure(in Intake, errs []FieldError) *audit.ValidationFailure {
+	f := &audit.ValidationFailure{ID: a.ids.NewID(), UserID: in.UserID, At: a.now().UTC()}
+	for _, e := range errs {
+		f.Errors = append(f.Errors, audit.FieldCode{Field: e.Field, Code: e.Code})
+		if e.Field == "userId" {
+			f.UserID = ""
+		}
+	}
+	return f
+}
+
+// recordFailure writes the run's validation failure and points the response
+// at it. A failed write is only logged: the response already reports why the
+// intake was rejected.
+func (r *analysisRun) recordFailure(ctx context.Context, s settings) {
+	store := s.store.(audit.ValidationFailureStore)
+	if err := store.InsertValidationFailure(ctx, *r.failure); err != nil {
+		log.Printf("validation failure audit write failed: %v", err)
+		return
+	}
+	r.resp.AuditID = r.failure.ID
+	r.resp.AuditAt = r.failure.At.Format(time.RFC3339)
+}
+
+// LatestAuditsWithFailures is LatestAuditsContext with the recorded
+// validation failures merged in by time, newest last. Failures have type
+// AuditTypeValidationFailed, risk level INVALID, and no patient reference.
+func (a *Analyzer) LatestAuditsWithFailures(ctx context.Context, limit int) ([]AuditSummary, error) {
+	s := a.settings()
+	store, ok := s.store.(audit.ValidationFailureStore)
+	if !ok {
+		return nil, ErrValidationFailuresUnsupported
+	}
+	summaries, err := s.store.Latest(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	failures, err := store.LatestValidationFailures(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]AuditSummary, 0, len(summaries)+len(failures))
+	for _, sum := range summaries {
+		out = append(out, auditSummary(sum))
+	}
+	for _, f := range failures {
+		out = append(out, failureSummary(f))
+	}
+	slices.SortStableFunc(out, func(x, y AuditSummary) int { return cmp.Compare(x.At, y.At) })
+	if limit > 0 && len(out) > limit {
+		out = out[len(out)-limit:]
+	}
+	return out, nil
+}
+
+func LatestAuditsWithFailures(ctx context.Context, limit int) ([]AuditSummary, error) {
+	return defaultAnalyzer.LatestAuditsWithFailures(ctx, limit)
+}
+
+func failureSummary(f audit.ValidationFailure) AuditSummary {
+	sum := AuditSummary{
+		AuditID:   f.ID,
+		RiskLevel: RiskInvalid,
+		At:        f.At.UTC().Format(time.RFC3339),
+		Type:      AuditTypeValidationFailed,
+	}
+	for _, e := range f.Errors {
+		sum.Errors = append(sum.Errors, FieldCode{Field: e.Field, Code: e.Code})
+	}
+	return sum
+}
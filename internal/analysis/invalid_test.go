@@ -0,0 +1,82 @@
+package analysis
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+func TestAnalyze_RecordsValidationFailure(t *testing.T) {
+	store := audit.NewMemoryStore()
+	a := New(WithAuditStore(store))
+	in := Intake{PatientName: "Jane Doe", UserID: "clin-1", Complaint: "ED", Age: 40}
+	fieldErrs, _ := a.settings().fieldErrors(in)
+	var required float64
+	for _, e := range fieldErrs {
+		if e.Code == "required" {
+			required++
+		}
+	}
+	before := validationFailures.Value("required")
+
+	resp := a.AnalyzeContext(t.Context(), in, Options{})
+	if resp.RiskLevel != RiskInvalid || resp.AuditID == "" || resp.AuditAt == "" {
+		t.Fatalf("invalid analysis should reference its failure record: %+v", resp)
+	}
+	if got := validationFailures.Value("required") - before; got != required || required == 0 {
+		t.Fatalf("required counter moved by %v, want %v", got, required)
+	}
+	if audits, _ := store.Latest(t.Context(), 10); len(audits) != 0 {
+		t.Fatalf("a rejected intake should not be a clinical audit: %+v", audits)
+	}
+	failures, err := store.LatestValidationFailures(t.Context(), 10)
+	if err != nil || len(failures) != 1 || failures[0].ID != resp.AuditID || failures[0].UserID != "clin-1" || len(failures[0].Errors) != len(fieldErrs) {
+		t.Fatalf("failures = %+v, %v", failures, err)
+	}
+	if raw, _ := json.Marshal(failures[0]); strings.Contains(string(raw), "Jane") || strings.Contains(string(raw), "message") {
+		t.Fatalf("failure record should hold no patient data or messages: %s", raw)
+	}
+
+	if resp := a.AnalyzeContext(t.Context(), in, Options{DryRun: true}); resp.AuditID != "" {
+		t.Fatalf("dry run recorded a failure: %q", resp.AuditID)
+	}
+	if resp := a.Analyze(llmIntake); resp.AuditID == "" {
+		t.Fatalf("valid analysis unaudited: %+v", resp.ValidationErrors)
+	}
+	sums, err := a.LatestAuditsWithFailures(t.Context(), 10)
+	if err != nil || len(sums) != 2 {
+		t.Fatalf("LatestAuditsWithFailures = %+v, %v", sums, err)
+	}
+	var failed *AuditSummary
+	for i := range sums {
+		if sums[i].Type == AuditTypeValidationFailed {
+			failed = &sums[i]
+		}
+	}
+	if failed == nil || failed.AuditID != resp.AuditID || failed.RiskLevel != RiskInvalid || failed.PatientRef != "" || len(failed.Errors) != len(fieldErrs) {
+		t.Fatalf("merged listing = %+v", sums)
+	}
+	if got := a.LatestAudits(10); len(got) != 1 || got[0].Type != "" {
+		t.Fatalf("default listing should leave failures out: %+v", got)
+	}
+}
+
+func TestAnalyze_ValidationFailureUnsupported(t *testing.T) {
+	a := New(WithAuditStore(struct{ audit.Store }{audit.NewMemoryStore()}))
+	if resp := a.Analyze(Intake{}); resp.RiskLevel != RiskInvalid || resp.AuditID != "" {
+		t.Fatalf("store without failure support: %+v", resp)
+	}
+	if _, err := a.LatestAuditsWithFailures(t.Context(), 10); !errors.Is(err, ErrValidationFailuresUnsupported) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestValidationFailure_DropsInvalidUserID(t *testing.T) {
+	f := New().validationFailure(Intake{UserID: "bad\x00"}, []FieldError{{Field: "userId", Code: "invalid_character", Message: "userId contains ..."}})
+	if f.UserID != "" || len(f.Errors) != 1 || f.Errors[0].Code != "invalid_character" {
+		t.Fatalf("failure = %+v", f)
+	}
+}
@@ -0,0 +1,123 @@
+package analysis
+
+import (
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// riskRank orders the risk levels, INVALID lowest.
+var riskRank = map[RiskLevel]int{RiskInvalid: 0, RiskLow: 1, RiskMedium: 2, RiskHigh: 3, RiskCritical: 4}
+
+// checkResponseInvariants fails t when resp breaks a property every analysis
+// must hold whatever the intake: a non-negative score, a schema-valid
+// response, INVALID exactly when the intake was rejected, at least MEDIUM risk
+// whenever a danger issue is flagged, and medication flags that name flagged
+// issues.
+func checkResponseInvariants(t testing.TB, in Intake, resp Response) {
+	t.Helper()
+	if resp.RiskScore < 0 || resp.RiskScoreNormalized < 0 || resp.RiskScoreNormalized > 100 {
+		t.Errorf("risk score %d (normalized %d) out of range for %+v", resp.RiskScore, resp.RiskScoreNormalized, in)
+	}
+	// Rejected intakes get a schema-valid response too.
+	if errs := ValidateResponse(resp); len(errs) > 0 {
+		t.Errorf("schema-invalid response for %+v: %v", in, errs)
+	}
+	if (resp.RiskLevel == RiskInvalid) != (len(resp.ValidationErrors) > 0) {
+		t.Errorf("risk level %s with validation errors %q for %+v", resp.RiskLevel, resp.ValidationErrors, in)
+	}
+	if i := slices.IndexFunc(resp.FlaggedIssues, func(is Issue) bool { return is.Severity == SeverityDanger }); i >= 0 && riskRank[resp.RiskLevel] < riskRank[RiskMedium] {
+		t.Errorf("danger issue %s flagged at %s risk (score %d) for %+v", resp.FlaggedIssues[i].Code, resp.RiskLevel, resp.RiskScore, in)
+	}
+	for _, am := range resp.MedicationsAssessed {
+		for _, code := range am.Flags {
+			if !slices.ContainsFunc(resp.FlaggedIssues, func(is Issue) bool { return is.Code == code }) {
+				t.Errorf("medication %q flagged %s, which is not a flagged issue", am.Input.Name, code)
+			}
+		}
+	}
+}
+
+// checkBPInvariants fails t when parseBP's result for bp is inconsistent: a
+// reading outside the plausibility bounds accepted, values returned with an
+// unreadable one, a problem code other than the two documented, or an
+// accepted reading that does not parse back from its canonical form.
+func checkBPInvariants(t testing.TB, bp string) {
+	t.Helper()
+	s, d, problem := parseBP(bp)
+	switch {
+	case problem == nil:
+		if s < plausibleMinSystolic || s > plausibleMaxSystolic || d < plausibleMinDiastolic || d > plausibleMaxDiastolic || d >= s {
+			t.Errorf("parseBP(%q) accepted %d/%d", bp, s, d)
+		}
+		if s2, d2, p2 := parseBP(strconv.Itoa(s) + "/" + strconv.Itoa(d)); p2 != nil || s2 != s || d2 != d {
+			t.Errorf("parseBP(%q) = %d/%d does not round-trip: %d/%d, %v", bp, s, d, s2, d2, p2)
+		}
+	case problem.Code == "invalid_format":
+		if s != 0 || d != 0 {
+			t.Errorf("parseBP(%q) returned %d/%d with invalid_format", bp, s, d)
+		}
+	case problem.Code != "out_of_range":
+		t.Errorf("parseBP(%q) problem code %q", bp, problem.Code)
+	}
+	if s2, d2, p2 := parseBP(" \t" + bp + " \n"); s2 != s || d2 != d || (p2 == nil) != (problem == nil) {
+		t.Errorf("parseBP(%q) depends on surrounding whitespace", bp)
+	}
+}
+
+// checkDoseInvariants fails t when extractMg reads dose as a negative or NaN
+// amount, or reads it differently in another letter case.
+func checkDoseInvariants(t testing.TB, dose string) {
+	t.Helper()
+	mg := extractMg(dose)
+	if mg < 0 || math.IsNaN(mg) {
+		t.Errorf("extractMg(%q) = %v", dose, mg)
+	}
+	if upper := extractMg(strings.ToUpper(dose)); upper != mg && !(math.IsNaN(upper) && math.IsNaN(mg)) {
+		t.Errorf("extractMg(%q) = %v but %v in upper case", dose, mg, upper)
+	}
+}
+
+// checkMedsInvariants fails t when normalizeMeds yields a name the rules
+// could not match, or when the set depends on the order of meds or is not
+// stable under normalizing its own names again.
+func checkMedsInvariants(t testing.TB, meds []Medication) {
+	t.Helper()
+	got := normalizeMeds(meds)
+	again := make([]Medication, 0, len(got))
+	for name := range got {
+		if name == "" || name != normalizeName(name) {
+			t.Errorf("normalizeMeds(%+v) yields %q", meds, name)
+		}
+		again = append(again, Medication{Name: name})
+	}
+	reversed := slices.Clone(meds)
+	slices.Reverse(reversed)
+	if r := normalizeMeds(reversed); !maps.Equal(r, got) {
+		t.Errorf("normalizeMeds depends on order: %v vs %v", got, r)
+	}
+	for name := range normalizeMeds(again) {
+		if !got[name] {
+			t.Errorf("normalizeMeds(%+v) not stable: %q appears on renormalizing %v", meds, name, got)
+		}
+	}
+}
+
+// checkRiskMonotonic fails t when more, the analysis of an intake with one
+// more condition, medication, or allergy than the one analyzed as base,
+// scores lower, sits at a lower risk level, or claims more plan confidence.
+func checkRiskMonotonic(t testing.TB, added string, base, more Response) {
+	t.Helper()
+	if more.RiskScore < base.RiskScore {
+		t.Errorf("adding %s lowered the risk score %d -> %d", added, base.RiskScore, more.RiskScore)
+	}
+	if riskRank[more.RiskLevel] < riskRank[base.RiskLevel] {
+		t.Errorf("adding %s lowered the risk level %s -> %s", added, base.RiskLevel, more.RiskLevel)
+	}
+	if more.PlanConfidence > base.PlanConfidence {
+		t.Errorf("adding %s raised plan confidence %.3f -> %.3f", added, base.PlanConfidence, more.PlanConfidence)
+	}
+}
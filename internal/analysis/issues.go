@@ -0,0 +1,342 @@
+package analysis
+
+import (
+	"sort"
+	"strings"
+)
+
+// issueDef documents a stable issue code. Codes are part of the API contract:
+// never rename or reuse one, add a new code instead.
+type issueDef struct {
+	Type      string
+	Reference string
+	Doc       string
+}
+
+// issueCatalog is the registry of every issue code the engine can emit.
+var issueCatalog = map[string]issueDef{
+	"BMI_OBESITY": {
+		Type:      "bmi",
+		Reference: "WHO BMI classification; AHA/ACC/TOS 2013 Obesity Guideline",
+		Doc:       "BMI >= 30.",
+	},
+	"BMI_ELEVATED": {
+		Type:      "bmi",
+		Reference: "WHO BMI classification",
+		Doc:       "BMI 27-30.",
+	},
+	"BMI_INCONSISTENT": {
+		Type: "bmi",
+		Doc:  "Supplied BMI differs by more than 1.0 from weight and height; the computed value is used.",
+	},
+	"BMI_UNVERIFIABLE": {
+		Type: "bmi",
+		Doc:  "BMI supplied without weight and height, so it could not be checked.",
+	},
+	"BP_UNCONTROLLED": {
+		Type:      "blood_pressure",
+		Reference: "2017 ACC/AHA High Blood Pressure Guideline",
+		Doc:       "Systolic >= 160 or diastolic >= 100.",
+	},
+	"BP_ELEVATED": {
+		Type:      "blood_pressure",
+		Reference: "2017 ACC/AHA High Blood Pressure Guideline",
+		Doc:       "Systolic >= 140 or diastolic >= 90.",
+	},
+	"COND_HEART_DISEASE": {
+		Type:      "cardiac_history",
+		Reference: "Princeton III Consensus on sexual activity and cardiac risk",
+		Doc:       "Heart disease listed in conditions.",
+	},
+	"COND_KIDNEY_DISEASE": {
+		Type:      "renal_impairment",
+		Reference: "KDIGO 2012 CKD Guideline, drug dosing",
+		Doc:       "Kidney disease listed in conditions.",
+	},
+	"COND_LIVER_DISEASE": {
+		Type:      "hepatic_impairment",
+		Reference: "FDA Guidance: Pharmacokinetics in Patients with Impaired Hepatic Function",
+		Doc:       "Liver disease listed in conditions.",
+	},
+	"COND_DIABETES": {
+		Type:      "metabolic_risk",
+		Reference: "ADA Standards of Care in Diabetes, cardiovascular risk management",
+		Doc:       "Diabetes listed in conditions.",
+	},
+	"CONDITION_UNMAPPED": {
+		Type: "condition",
+		Doc:  "A listed condition matched no known condition or synonym, so no rule read it.",
+	},
+	"METABOLIC_SYNDROME": {
+		Type:      "condition",
+		Reference: "Harmonizing the Metabolic Syndrome (IDF/AHA/NHLBI joint statement, 2009)",
+		Doc:       "Three or more of the five metabolic syndrome criteria met.",
+	},
+	"FAMILY_HISTORY_UNMAPPED": {
+		Type: "family_history",
+		Doc:  "A family history entry matched no known term, so no rule read it.",
+	},
+	"FAMILY_PREMATURE_CAD": {
+		Type:      "family_history",
+		Reference: "ACC/AHA 2019 Primary Prevention Guideline (risk-enhancing factors)",
+		Doc:       "Family history of premature CAD with an ED or weight loss complaint.",
+	},
+	"FAMILY_PROSTATE_CANCER_FINASTERIDE": {
+		Type:      "family_history",
+		Reference: "AUA/ASCO guidance on 5-alpha reductase inhibitors and PSA",
+		Doc:       "Finasteride planned for a patient with a family history of prostate cancer.",
+	},
+	"COMPLAINT_WATCHFUL_WAITING": {
+		Type: "complaint",
+		Doc:  "Complaint present for under two weeks; watchful waiting may be preferable to treatment.",
+	},
+	"INTAKE_FIELD_MISSING": {
+		Type: "intake_completeness",
+		Doc:  "A field the complaint recommends but does not require, such as bp for hair loss, was not recorded.",
+	},
+	"HISTORY_INCOMPLETE": {
+		Type: "intake_completeness",
+		Doc:  "The condition, medication, or allergy list is empty without being confirmed as none, so plan confidence is capped.",
+	},
+	"AGE_OVER_65": {
+		Type:      "age_related",
+		Reference: "AGS Beers Criteria",
+		Doc:       "Age above 65.",
+	},
+	"LIFESTYLE_SMOKING": {
+		Type:      "lifestyle",
+		Reference: "USPSTF Tobacco Smoking Cessation in Adults",
+		Doc:       "Current smoker, with pack-years when recorded.",
+	},
+	"LIFESTYLE_SMOKING_RECENT_QUIT": {
+		Type:      "lifestyle",
+		Reference: "USPSTF Tobacco Smoking Cessation in Adults",
+		Doc:       "Former smoker who quit less than a year ago.",
+	},
+	"LIFESTYLE_SMOKING_HISTORY": {
+		Type:      "lifestyle",
+		Reference: "USPSTF Lung Cancer Screening",
+		Doc:       "Former smoker with a history of more than 20 pack-years.",
+	},
+	"LIFESTYLE_ALCOHOL_HEAVY": {
+		Type:      "alcohol",
+		Reference: "NIAAA drinking levels definitions",
+		Doc:       "Heavy alcohol use: more than 14 standard drinks a week, or a heavy label without a count.",
+	},
+	"LIFESTYLE_ALCOHOL_MODERATE": {
+		Type:      "alcohol",
+		Reference: "NIAAA drinking levels definitions",
+		Doc:       "8 to 14 standard drinks a week.",
+	},
+	"SLEEP_SHORT": {
+		Type:      "lifestyle",
+		Reference: "AASM/SRS Recommended Amount of Sleep for a Healthy Adult",
+		Doc:       "Under 6 hours of sleep a night.",
+	},
+	"ALCOHOL_LABEL_CONFLICT": {
+		Type: "alcohol",
+		Doc:  "The alcohol label contradicts alcoholDrinksPerWeek; the drink count is used.",
+	},
+	"CI_NITRATE_PDE5": {
+		Type:      "contraindication",
+		Reference: "FDA PDE5 inhibitor labeling, Contraindications (nitrates)",
+		Doc:       "A scheduled nitrate in the medication list; PDE5 inhibitors are withheld.",
+	},
+	"CI_NITRATE_PRN_PDE5": {
+		Type:      "contraindication",
+		Reference: "FDA PDE5 inhibitor labeling, Contraindications (nitrates); ACC/AHA expert consensus on sildenafil and nitrates",
+		Doc:       "An as-needed nitrate, such as sublingual nitroglycerin, in the medication list; PDE5 inhibitors are withheld.",
+	},
+	"DDI_PDE5_AMLODIPINE": {
+		Type:      "drug_interaction",
+		Reference: "FDA tadalafil labeling, Drug Interactions (antihypertensives)",
+		Doc:       "PDE5 plan with amlodipine.",
+	},
+	"DDI_PDE5_TAMSULOSIN": {
+		Type:      "drug_interaction",
+		Reference: "FDA tadalafil labeling, Drug Interactions (alpha-blockers)",
+		Doc:       "PDE5 plan with tamsulosin.",
+	},
+	"CARDIAC_CLEARANCE_PDE5": {
+		Type:      "cardiac_clearance",
+		Reference: "Princeton III Consensus on sexual activity and cardiac risk",
+		Doc:       "PDE5 plan with heart disease history.",
+	},
+	"DDI_PDE5_ALCOHOL": {
+		Type:      "alcohol",
+		Reference: "FDA tadalafil labeling, Drug Interactions (alcohol)",
+		Doc:       "PDE5 plan with heavy alcohol use.",
+	},
+	"DDI_AMLODIPINE_SIMVASTATIN": {
+		Type:      "drug_interaction",
+		Reference: "FDA Drug Safety Communication (2011): simvastatin dose limits",
+		Doc:       "Amlodipine with simvastatin.",
+	},
+	"DDI_METFORMIN_CONTRAST": {
+		Type:      "drug_interaction",
+		Reference: "ACR Manual on Contrast Media, metformin",
+		Doc:       "Metformin with iodinated contrast.",
+	},
+	"CI_FINASTERIDE_PREGNANCY": {
+		Type:      "drug_interaction",
+		Reference: "FDA finasteride labeling, Contraindications (pregnancy)",
+		Doc:       "Finasteride with pregnancy exposure.",
+	},
+	"ALLERGY_PLAN": {
+		Type: "allergy",
+		Doc:  "Planned medication matches a listed allergy.",
+	},
+	"ALLERGY_ALTERNATIVE": {
+		Type: "allergy",
+		Doc:  "An alternative matches a listed allergy.",
+	},
+	"ALLERGY_MEDICATION": {
+		Type: "allergy",
+		Doc:  "A current medication matches a listed allergy; no risk points.",
+	},
+	"LLM_SCORING_DEGRADED": {
+		Type: "scoring",
+		Doc:  "LLM client failed or timed out; confidence came from the deterministic stub.",
+	},
+	"DOSE_CAP_PDE5": {
+		Type:      "dose_cap",
+		Reference: "FDA PDE5 inhibitor labeling, Dosage and Administration",
+		Doc:       "PDE5 plan dose above 20mg.",
+	},
+	"DDI_PDE5_ALPHA_BLOCKER": {
+		Type:      "drug_interaction",
+		Reference: "FDA tadalafil labeling, Drug Interactions (alpha-blockers)",
+		Doc:       "PDE5 inhibitor with an alpha-blocker other than tamsulosin; interaction check only.",
+	},
+	"DUP_THERAPY": {
+		Type: "duplicate_therapy",
+		Doc:  "Two medications from the same drug class; interaction check only.",
+	},
+}
+
+// newIssue builds an Issue from the catalog so type and reference stay consistent per code.
+func newIssue(code string, severity Severity, description string, relatedMeds ...string) Issue {
+	def := issueCatalog[code]
+	var related []string
+	for _, m := range relatedMeds {
+		if m = strings.ToLower(strings.TrimSpace(m)); m != "" {
+			related = append(related, m)
+		}
+	}
+	return Issue{
+		Code:               code,
+		Type:               def.Type,
+		Severity:           severity,
+		Description:        description,
+		Reference:          def.Reference,
+		RelatedMedications: related,
+	}
+}
+
+// issueMerges folds overlapping issues into a single combined issue. The key
+// code is absorbed into the value code when both fire for the same intake.
+var issueMerges = map[string]string{
+	"LIFESTYLE_ALCOHOL_HEAVY": "DDI_PDE5_ALCOHOL",
+}
+
+var severityRank = map[Severity]int{
+	SeverityDanger:  0,
+	SeverityWarning: 1,
+	SeverityInfo:    2,
+}
+
+// issueKey is the canonical identity of an issue: its code and the sorted,
+// normalized medications it involves. Issues name no conditions, so those are
+// carried by the code alone. The same fact flagged by an inline check and by
+// a ruleset entry shares a key however each words it.
+func issueKey(code string, meds ...string) string {
+	drugs := make([]string, 0, len(meds))
+	for _, m := range meds {
+		if m = strings.ToLower(strings.TrimSpace(m)); m != "" && !containsString(drugs, m) {
+			drugs = append(drugs, m)
+		}
+	}
+	sort.Strings(drugs)
+	return code + "|" + strings.Join(drugs, ",")
+}
+
+// finalizeIssues de-duplicates issues by issueKey (falling back to
+// type+description for issues without a code), merges overlapping issues,
+// and orders them danger > warning > info. Ordering within a severity tier
+// follows evaluation order.
+func finalizeIssues(issues []Issue) []Issue {
+	out := make([]Issue, 0, len(issues))
+	index := make(map[string]int, len(issues))
+	// firstKey holds the key of the first issue with each code, which
+	// issueMerges fold into.
+	firstKey := make(map[string]string, len(issues))
+	for _, issue := range issues {
+		if _, ok := firstKey[issue.Code]; !ok {
+			firstKey[issue.Code] = issueKey(issue.Code, issue.RelatedMedications...)
+		}
+	}
+
+	for _, issue := range issues {
+		key := issueKey(issue.Code, issue.RelatedMedications...)
+		code := issue.Code
+		if target, ok := issueMerges[code]; ok {
+			if k, ok := firstKey[target]; ok {
+				key, code = k, target
+			}
+		}
+		if code == "" {
+			key = issue.Type + "|" + issue.Description
+		}
+		i, seen := index[key]
+		if !seen {
+			index[key] = len(out)
+			out = append(out, issue)
+			continue
+		}
+		out[i] = mergeIssue(out[i], issue, code)
+	}
+
+	sort.SliceStable(out, func(a, b int) bool {
+		return severityRank[out[a].Severity] < severityRank[out[b].Severity]
+	})
+	return out
+}
+
+// mergeIssue combines two issues under code, keeping the higher severity and
+// the union of related medications. Duplicates of one fact keep the longer
+// description; issues folded in by issueMerges join theirs.
+func mergeIssue(existing, incoming Issue, code string) Issue {
+	merged := existing
+	if existing.Code != code {
+		def := issueCatalog[code]
+		merged.Code = code
+		merged.Type = def.Type
+		merged.Reference = def.Reference
+	}
+	if severityRank[incoming.Severity] < severityRank[merged.Severity] {
+		merged.Severity = incoming.Severity
+	}
+	switch {
+	case existing.Code == incoming.Code:
+		if len(incoming.Description) > len(merged.Description) {
+			merged.Description = incoming.Description
+		}
+	case incoming.Description != "" && !strings.Contains(merged.Description, incoming.Description):
+		merged.Description = strings.TrimSpace(merged.Description + " " + incoming.Description)
+	}
+	for _, m := range incoming.RelatedMedications {
+		if !containsString(merged.RelatedMedications, m) {
+			merged.RelatedMedications = append(merged.RelatedMedications, m)
+		}
+	}
+	return merged
+}
+
+func containsString(values []string, needle string) bool {
+	for _, v := range values {
+		if v == needle {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+package analysis
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestIssueCatalogCoversConstructors fails when an issue is built without a
+// registered code, either via a raw Issue literal or an unknown newIssue code,
+// or with a severity spelled as a string literal instead of a Severity
+// constant.
+func TestIssueCatalogCoversConstructors(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatalf("glob: %v", err)
+	}
+	fset := token.NewFileSet()
+	used := map[string]bool{}
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatalf("parse %s: %v", name, err)
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch node := n.(type) {
+			case *ast.FuncDecl:
+				// newIssue is the single place allowed to build Issue literals.
+				if node.Name.Name == "newIssue" {
+					return false
+				}
+			case *ast.CompositeLit:
+				if id, ok := node.Type.(*ast.Ident); ok && id.Name == "Issue" {
+					t.Errorf("%s: Issue literal outside newIssue; register a code and use newIssue", fset.Position(node.Pos()))
+				}
+			case *ast.CallExpr:
+				id, ok := node.Fun.(*ast.Ident)
+				if !ok || id.Name != "newIssue" || len(node.Args) == 0 {
+					return true
+				}
+				if len(node.Args) > 1 {
+					if _, raw := node.Args[1].(*ast.BasicLit); raw {
+						t.Errorf("%s: newIssue severity is a string literal; use a Severity constant", fset.Position(node.Pos()))
+					}
+				}
+				lit, ok := node.Args[0].(*ast.BasicLit)
+				if !ok {
+					// Dynamic codes (e.g. from rule tables) are checked below.
+					return true
+				}
+				code, _ := strconv.Unquote(lit.Value)
+				used[code] = true
+				if _, ok := issueCatalog[code]; !ok {
+					t.Errorf("%s: issue code %q missing from issueCatalog", fset.Position(node.Pos()), code)
+				}
+			}
+			return true
+		})
+	}
+
+	for _, rule := range interactionRules {
+		used[rule.Code] = true
+		if _, ok := issueCatalog[rule.Code]; !ok {
+			t.Errorf("interaction rule %s+%s has unregistered code %q", rule.Drug, rule.With, rule.Code)
+		}
+	}
+
+	for code, def := range issueCatalog {
+		if def.Type == "" {
+			t.Errorf("issue code %q has no type", code)
+		}
+		if !used[code] {
+			t.Errorf("issue code %q is registered but never emitted", code)
+		}
+	}
+}
+
+func TestAnalyze_IssuesCarryCodes(t *testing.T) {
+	input := Intake{
+		PatientName: "High Risk",
+		Age:         68,
+		WeightKg:    90,
+		HeightCm:    170,
+		BP:          "168/102",
+		Conditions:  []string{"Heart Disease", "Hypertension"},
+		Medications: []Medication{
+			{Name: "Nitroglycerin", Dosage: "0.4mg", Frequency: "PRN"},
+		},
+		Complaint: "ED",
+	}
+
+	resp := Analyze(input)
+	if len(resp.ValidationErrors) > 0 {
+		t.Fatalf("unexpected validation errors: %v", resp.ValidationErrors)
+	}
+	var nitrate *Issue
+	for i, issue := range resp.FlaggedIssues {
+		if issue.Code == "" {
+			t.Fatalf("issue missing code: %+v", issue)
+		}
+		if issue.Code == "CI_NITRATE_PRN_PDE5" {
+			nitrate = &resp.FlaggedIssues[i]
+		}
+	}
+	if nitrate == nil {
+		t.Fatalf("expected CI_NITRATE_PRN_PDE5 issue")
+	}
+	if nitrate.Reference == "" {
+		t.Fatalf("expected nitrate issue to carry a reference")
+	}
+	if len(nitrate.RelatedMedications) != 1 || nitrate.RelatedMedications[0] != "nitroglycerin" {
+		t.Fatalf("expected nitroglycerin as related medication, got %v", nitrate.RelatedMedications)
+	}
+}
+
+func TestFinalizeIssues_CanonicalKey(t *testing.T) {
+	got := finalizeIssues([]Issue{
+		newIssue("DUP_THERAPY", "warning", "Two statins.", "simvastatin", "atorvastatin"),
+		newIssue("DUP_THERAPY", "warning", "Two ACE inhibitors.", "lisinopril", "enalapril"),
+		newIssue("DUP_THERAPY", "danger", "Two statins prescribed together.", "Atorvastatin", "simvastatin"),
+	})
+	if len(got) != 2 {
+		t.Fatalf("issues = %+v, want one per drug pair", got)
+	}
+	if got[0].Severity != "danger" || got[0].Description != "Two statins prescribed together." || len(got[0].RelatedMedications) != 2 {
+		t.Fatalf("merged issue = %+v, want the higher severity and longer description", got[0])
+	}
+}
+
+func TestAnalyze_OverlappingFileRule(t *testing.T) {
+	in := Intake{
+		PatientName: "Overlap",
+		Age:         50,
+		WeightKg:    75,
+		HeightCm:    175,
+		BP:          "120/80",
+		Medications: []Medication{{Name: "amlodipine", Dosage: "5mg", Frequency: "daily"}},
+		Complaint:   "ED",
+	}
+	a := New()
+	before := a.Analyze(in)
+	pde5 := strings.ToLower(before.RecommendedPlan.Medication)
+	if !usesPDE5(pde5) {
+		t.Fatalf("plan %q is not a PDE5 inhibitor", pde5)
+	}
+
+	r := a.Rules().Ruleset
+	const desc = "PDE5 inhibitors add to the blood pressure lowering of amlodipine; start at the lowest dose and watch for symptomatic hypotension."
+	r.Interactions = append(r.Interactions, InteractionRule{Code: "DDI_PDE5_AMLODIPINE", Drug: "amlodipine", With: pde5, Severity: "danger", Desc: desc, RiskDelta: 3})
+	b, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.LoadRulesFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := a.Analyze(in)
+	var issues []Issue
+	for _, is := range resp.FlaggedIssues {
+		if is.Code == "DDI_PDE5_AMLODIPINE" {
+			issues = append(issues, is)
+		}
+	}
+	if len(issues) != 1 || issues[0].Severity != "danger" || issues[0].Description != desc {
+		t.Fatalf("issues = %+v, want one merged danger issue", issues)
+	}
+	var factors []RiskFactor
+	for _, f := range resp.RiskFactors {
+		if f.Code == "pde5_amlodipine" || f.Code == "ddi_pde5_amlodipine" {
+			factors = append(factors, f)
+		}
+	}
+	if len(factors) != 1 || factors[0].Points != 3 {
+		t.Fatalf("risk factors = %+v, want one contribution of 3", factors)
+	}
+	if resp.RiskScore != before.RiskScore+2 {
+		t.Fatalf("score %d, want %d: only the larger contribution counts", resp.RiskScore, before.RiskScore+2)
+	}
+}
@@ -0,0 +1,92 @@
+package analysis
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Exercise frequencies accepted in Intake.Exercise.
+var exerciseLevels = []string{"none", "occasional", "regular"}
+
+// exerciseAliases map the labels earlier clients sent to an exercise level.
+var exerciseAliases = map[string]string{
+	"sedentary": "none",
+	"light":     "occasional",
+	"1-2x/week": "occasional",
+	"3-4x/week": "regular",
+	"3+x/week":  "regular",
+	"daily":     "regular",
+}
+
+// cardiometabolicComplaints are the complaints whose plans a sedentary
+// lifestyle works against.
+var cardiometabolicComplaints = []string{"ed", "weight loss"}
+
+const (
+	// shortSleepHours is the nightly sleep below which an info issue is
+	// raised.
+	shortSleepHours = 6
+	// maxSleepHours bounds a plausible nightly sleep.
+	maxSleepHours = 24
+)
+
+// exerciseLevel normalizes Intake.Exercise to one of exerciseLevels, "" when
+// it is empty, and false when it is not recognized.
+func exerciseLevel(v string) (string, bool) {
+	v = normalizeName(v)
+	if alias, ok := exerciseAliases[v]; ok {
+		v = alias
+	}
+	return v, v == "" || slices.Contains(exerciseLevels, v)
+}
+
+// cardiometabolicComplaint reports whether any of the intake's complaints is
+// one of cardiometabolicComplaints.
+func cardiometabolicComplaint(in Intake) bool {
+	return slices.ContainsFunc(intakeComplaints(in), func(c string) bool {
+		return slices.Contains(cardiometabolicComplaints, normalizeName(c))
+	})
+}
+
+// sedentary reports whether the intake records no exercise.
+func sedentary(in Intake) bool {
+	level, _ := exerciseLevel(in.Exercise)
+	return level == "none"
+}
+
+// shortSleep reports whether the intake records under six hours of sleep.
+func shortSleep(in Intake) bool {
+	return in.SleepHours > 0 && in.SleepHours < shortSleepHours
+}
+
+// assessLifestyle adds a risk point for a sedentary patient with a
+// cardiometabolic complaint and flags short sleep. The ED and weight loss
+// rationales strengthen their lifestyle advice for both; see
+// buildPlanContext.
+func assessLifestyle(in Intake, risk *riskAccumulator, l localizer) []Issue {
+	var issues []Issue
+	if risk.check("sedentary", "", sedentary(in) && cardiometabolicComplaint(in), func() map[string]any {
+		level, _ := exerciseLevel(in.Exercise)
+		return map[string]any{"exercise": level, "cardiometabolicComplaint": cardiometabolicComplaint(in)}
+	}) {
+		risk.add("sedentary", "Sedentary (no regular exercise)")
+	}
+	if shortSleep(in) {
+		issues = append(issues, newIssue("SLEEP_SHORT", SeverityInfo, l.issue("SLEEP_SHORT", map[string]any{"Hours": formatQuantity(in.SleepHours)})))
+	}
+	return issues
+}
+
+// lifestyleErrors rejects unknown exercise levels and implausible sleep
+// hours.
+func lifestyleErrors(in Intake) []FieldError {
+	var errs []FieldError
+	if _, ok := exerciseLevel(in.Exercise); !ok {
+		errs = append(errs, FieldError{Field: "exercise", Code: "invalid_format", Message: "exercise must be one of " + strings.Join(exerciseLevels, ", ")})
+	}
+	if h := in.SleepHours; h < 0 || h > maxSleepHours {
+		errs = append(errs, FieldError{Field: "sleepHours", Code: "out_of_range", Message: fmt.Sprintf("sleepHours must be between 0 and %d, not %s", maxSleepHours, formatQuantity(h))})
+	}
+	return errs
+}
@@ -0,0 +1,86 @@
+package analysis
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAnalyze_Sedentary(t *testing.T) {
+	cases := []struct {
+		name      string
+		complaint string
+		exercise  string
+		sedentary bool
+	}{
+		{"ed, none", "ED", "none", true},
+		{"ed, legacy label", "ED", "Sedentary", true},
+		{"ed, occasional", "ED", "1-2x/week", false},
+		{"weight loss, none", "Weight Loss", "None", true},
+		{"hair loss, none", "Hair Loss", "none", false},
+		{"ed, unrecorded", "ED", "", false},
+	}
+	a := New()
+	for _, tc := range cases {
+		in := llmIntake
+		in.Complaint, in.ConfirmedNoMedications, in.ConfirmedNoConditions = tc.complaint, true, true
+		in.Exercise = tc.exercise
+		resp := a.Analyze(in)
+		if len(resp.ValidationErrors) > 0 {
+			t.Fatalf("%s: %v", tc.name, resp.ValidationErrors)
+		}
+		scored := slices.ContainsFunc(resp.RiskFactors, func(f RiskFactor) bool { return f.Code == "sedentary" })
+		if scored != tc.sedentary {
+			t.Errorf("%s: sedentary scored %t, want %t", tc.name, scored, tc.sedentary)
+		}
+		if advised := strings.Contains(resp.RecommendedPlan.Rationale, "150 minutes"); advised != tc.sedentary {
+			t.Errorf("%s: activity advice %t, want %t: %q", tc.name, advised, tc.sedentary, resp.RecommendedPlan.Rationale)
+		}
+	}
+}
+
+func TestAnalyze_ShortSleep(t *testing.T) {
+	in := llmIntake
+	in.Complaint, in.SleepHours = "Weight Loss", 5
+	resp := New().Analyze(in)
+	i := slices.IndexFunc(resp.FlaggedIssues, func(i Issue) bool { return i.Code == "SLEEP_SHORT" })
+	if i < 0 || !strings.Contains(resp.FlaggedIssues[i].Description, "about 5 hours") {
+		t.Fatalf("short sleep issue: %+v", resp.FlaggedIssues)
+	}
+	if !strings.Contains(resp.RecommendedPlan.Rationale, "at least 7 hours") {
+		t.Errorf("rationale lacks sleep advice: %q", resp.RecommendedPlan.Rationale)
+	}
+
+	in.SleepHours = 7
+	resp = New().Analyze(in)
+	if slices.ContainsFunc(resp.FlaggedIssues, func(i Issue) bool { return i.Code == "SLEEP_SHORT" }) {
+		t.Errorf("7 hours flagged: %+v", resp.FlaggedIssues)
+	}
+}
+
+func TestLifestyleErrors(t *testing.T) {
+	cases := []struct {
+		name        string
+		exercise    string
+		sleep       float64
+		field, code string
+	}{
+		{"unknown exercise", "sometimes", 0, "exercise", "invalid_format"},
+		{"negative sleep", "regular", -1, "sleepHours", "out_of_range"},
+		{"sleep over a day", "", 25, "sleepHours", "out_of_range"},
+	}
+	for _, tc := range cases {
+		in := llmIntake
+		in.Exercise, in.SleepHours = tc.exercise, tc.sleep
+		if errs := lifestyleErrors(in); len(errs) != 1 || errs[0].Field != tc.field || errs[0].Code != tc.code {
+			t.Errorf("%s: errors %+v", tc.name, errs)
+		}
+	}
+	for _, v := range append(slices.Clone(exerciseLevels), "", "Daily", " 3-4x/week ") {
+		in := llmIntake
+		in.Exercise = v
+		if errs := lifestyleErrors(in); len(errs) != 0 {
+			t.Errorf("exercise %q: %+v", v, errs)
+		}
+	}
+}
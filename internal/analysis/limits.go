@@ -0,0 +1,162 @@
+package analysis
+
+import (
+	"fmt"
+	"slices"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Intake size limits. Values past them are entry mistakes or abuse, and
+// would otherwise flow into logs, the audit store, and LLM prompts. Lengths
+// count characters, not bytes.
+const (
+	maxTextLen  = 2000 // complaints and treatment notes
+	maxNameLen  = 200  // every other string field
+	maxListSize = 100  // entries in any intake list
+)
+
+// limitErrors reports intake fields that are too long, lists with too many
+// entries, and strings holding control or bidirectional formatting
+// characters. The messages name the field and the limit, never the value,
+// so a rejected intake is not echoed back into logs.
+func limitErrors(in Intake) []FieldError {
+	var errs []FieldError
+	text := func(field, v string, limit int, multiline bool) {
+		if n := utf8.RuneCountInString(v); n > limit {
+			errs = append(errs, FieldError{Field: field, Code: "too_long", Message: fmt.Sprintf("%s must be at most %d characters, not %d", field, limit, n)})
+		}
+		if r, ok := disallowedRune(v, multiline); ok {
+			errs = append(errs, FieldError{Field: field, Code: "invalid_character", Message: fmt.Sprintf("%s contains the disallowed character %U", field, r)})
+		}
+	}
+	// list checks a list's size and, only when it is within bounds, each
+	// entry, so a 10,000-entry list yields one error rather than thousands.
+	list := func(field string, n int, each func(i int, field string)) {
+		if n > maxListSize {
+			errs = append(errs, FieldError{Field: field, Code: "too_many_items", Message: fmt.Sprintf("%s must have at most %d entries, not %d", field, maxListSize, n)})
+			return
+		}
+		for i := range n {
+			each(i, fmt.Sprintf("%s[%d]", field, i))
+		}
+	}
+
+	text("patientName", in.PatientName, maxNameLen, false)
+	text("bp", in.BP, maxNameLen, false)
+	text("smoking", in.Smoking, maxNameLen, false)
+	text("alcohol", in.Alcohol, maxNameLen, false)
+	text("exercise", in.Exercise, maxNameLen, false)
+	text("complaint", in.Complaint, maxTextLen, true)
+	text("complaintSeverity", in.ComplaintSeverity, maxNameLen, false)
+	text("userId", in.UserID, maxNameLen, false)
+	if c := in.Consent; c != nil {
+		text("consent.timestamp", c.Timestamp, maxNameLen, false)
+		text("consent.method", c.Method, maxNameLen, false)
+	}
+	list("conditions", len(in.Conditions), func(i int, field string) {
+		text(field, in.Conditions[i], maxNameLen, false)
+	})
+	list("conditionCodes", len(in.ConditionCodes), func(i int, field string) {
+		text(field, in.ConditionCodes[i], maxNameLen, false)
+	})
+	list("familyHistory", len(in.FamilyHistory), func(i int, field string) {
+		text(field, in.FamilyHistory[i], maxNameLen, false)
+	})
+	list("allergies", len(in.Allergies), func(i int, field string) {
+		text(field, in.Allergies[i], maxNameLen, false)
+	})
+	list("allergyDetails", len(in.AllergyDetails), func(i int, field string) {
+		text(field+".substance", in.AllergyDetails[i].Substance, maxNameLen, false)
+		text(field+".severity", in.AllergyDetails[i].Severity, maxNameLen, false)
+	})
+	list("medications", len(in.Medications), func(i int, field string) {
+		m := in.Medications[i]
+		text(field+".name", m.Name, maxNameLen, false)
+		text(field+".dosage", m.Dosage, maxNameLen, false)
+		text(field+".frequency", m.Frequency, maxNameLen, false)
+	})
+	list("complaints", len(in.Complaints), func(i int, field string) {
+		text(field, in.Complaints[i], maxTextLen, true)
+	})
+	list("priorTreatments", len(in.PriorTreatments), func(i int, field string) {
+		p := in.PriorTreatments[i]
+		text(field+".medication", p.Medication, maxNameLen, false)
+		text(field+".maxDose", p.MaxDose, maxNameLen, false)
+		text(field+".outcome", p.Outcome, maxNameLen, false)
+		text(field+".notes", p.Notes, maxTextLen, true)
+	})
+	return errs
+}
+
+// normalizeText returns in with every string field limitErrors checks in
+// Unicode NFC, so a name typed with combining characters matches its
+// precomposed spelling in the rules, lookups, and audit. The lists are
+// copied, leaving the caller's intake as it was.
+func normalizeText(in Intake) Intake {
+	nfc := norm.NFC.String
+	each := func(v []string) []string {
+		if v == nil {
+			return nil
+		}
+		out := make([]string, len(v))
+		for i, s := range v {
+			out[i] = nfc(s)
+		}
+		return out
+	}
+
+	in.PatientName = nfc(in.PatientName)
+	in.BP = nfc(in.BP)
+	in.Smoking = nfc(in.Smoking)
+	in.Alcohol = nfc(in.Alcohol)
+	in.Exercise = nfc(in.Exercise)
+	in.Complaint = nfc(in.Complaint)
+	in.ComplaintSeverity = nfc(in.ComplaintSeverity)
+	in.UserID = nfc(in.UserID)
+	if c := in.Consent; c != nil {
+		c := *c
+		c.Timestamp, c.Method = nfc(c.Timestamp), nfc(c.Method)
+		in.Consent = &c
+	}
+	in.Conditions = each(in.Conditions)
+	in.ConditionCodes = each(in.ConditionCodes)
+	in.FamilyHistory = each(in.FamilyHistory)
+	in.Allergies = each(in.Allergies)
+	in.Complaints = each(in.Complaints)
+	in.AllergyDetails = slices.Clone(in.AllergyDetails)
+	for i := range in.AllergyDetails {
+		a := &in.AllergyDetails[i]
+		a.Substance, a.Severity = nfc(a.Substance), nfc(a.Severity)
+	}
+	in.Medications = slices.Clone(in.Medications)
+	for i := range in.Medications {
+		m := &in.Medications[i]
+		m.Name, m.Dosage, m.Frequency = nfc(m.Name), nfc(m.Dosage), nfc(m.Frequency)
+	}
+	in.PriorTreatments = slices.Clone(in.PriorTreatments)
+	for i := range in.PriorTreatments {
+		p := &in.PriorTreatments[i]
+		p.Medication, p.MaxDose, p.Outcome, p.Notes = nfc(p.Medication), nfc(p.MaxDose), nfc(p.Outcome), nfc(p.Notes)
+	}
+	return in
+}
+
+// disallowedRune returns the first control character in v, or the first
+// bidirectional embedding, override, or isolate, which can make logged or
+// rendered text read differently from what was stored. Multiline fields may
+// hold tabs and line breaks.
+func disallowedRune(v string, multiline bool) (rune, bool) {
+	for _, r := range v {
+		switch {
+		case multiline && (r == '\t' || r == '\n' || r == '\r'):
+		case unicode.IsControl(r),
+			r >= '\u202a' && r <= '\u202e',
+			r >= '\u2066' && r <= '\u2069':
+			return r, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,103 @@
+package analysis
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+func TestLimitErrors(t *testing.T) {
+	many := make([]Medication, 10000)
+	for i := range many {
+		many[i] = Medication{Name: "aspirin", Dosage: "81 mg", Frequency: "daily"}
+	}
+	cases := []struct {
+		name  string
+		edit  func(*Intake)
+		field string
+		code  string
+	}{
+		{"null byte", func(in *Intake) { in.PatientName = "Juan\x00Cruz" }, "patientName", "invalid_character"},
+		{"rtl override", func(in *Intake) { in.Medications = []Medication{{Name: "sildenafil\u202egnp.exe"}} }, "medications[0].name", "invalid_character"},
+		{"isolate", func(in *Intake) { in.Conditions = []string{"\u2067hypertension"} }, "conditions[0]", "invalid_character"},
+		{"escape in complaint", func(in *Intake) { in.Complaint = "ED\x1b[2J" }, "complaint", "invalid_character"},
+		{"long complaint", func(in *Intake) { in.Complaint = strings.Repeat("e", maxTextLen+1) }, "complaint", "too_long"},
+		{"long name", func(in *Intake) { in.PatientName = strings.Repeat("é", maxNameLen+1) }, "patientName", "too_long"},
+		{"long note", func(in *Intake) {
+			in.PriorTreatments = []PriorTreatment{{Medication: "sildenafil", Outcome: "ineffective", Notes: strings.Repeat("n", maxTextLen+1)}}
+		}, "priorTreatments[0].notes", "too_long"},
+		{"10k medications", func(in *Intake) { in.Medications = many }, "medications", "too_many_items"},
+	}
+	for _, tc := range cases {
+		in := llmIntake
+		tc.edit(&in)
+		errs := limitErrors(in)
+		if len(errs) != 1 || errs[0].Field != tc.field || errs[0].Code != tc.code {
+			t.Errorf("%s: errors %+v, want one %s on %s", tc.name, errs, tc.code, tc.field)
+		}
+	}
+
+	in := llmIntake
+	in.Complaint = "ED\n\tfor two years"
+	in.PatientName = strings.Repeat("é", maxNameLen)
+	if errs := limitErrors(in); len(errs) != 0 {
+		t.Fatalf("within limits: %+v", errs)
+	}
+}
+
+func TestAnalyze_RejectsAdversarialIntake(t *testing.T) {
+	store := audit.NewMemoryStore()
+	a := New(WithAuditStore(store))
+	in := llmIntake
+	in.Complaint = strings.Repeat("\x00", 2<<20)
+	in.Medications = make([]Medication, 10000)
+	resp := a.Analyze(in)
+	if resp.RiskLevel != RiskInvalid || len(resp.ValidationErrors) != 3 {
+		t.Fatalf("response %s with errors %q", resp.RiskLevel, resp.ValidationErrors)
+	}
+	// The messages name the limits; none quotes the rejected value.
+	body, _ := json.Marshal(resp.ValidationErrors)
+	if strings.Contains(string(body), `\u0000`) || len(body) > 1024 {
+		t.Fatalf("validation errors echo the input: %s", body)
+	}
+	if n := store.Len(); n != 0 {
+		t.Fatalf("rejected intake was audited: %d entries", n)
+	}
+}
+
+func TestNormalizeText_DecomposedInput(t *testing.T) {
+	in := llmIntake
+	in.PatientName = "Jose\u0301 Pe\u0301rez"
+	in.Allergies = []string{"sulfame\u0301thoxazole"}
+	in.Medications = []Medication{{Name: "cafe\u0301ine", Dosage: "100 mg"}}
+	got := normalizeText(in)
+	if got.PatientName != "José Pérez" || got.Allergies[0] != "sulfaméthoxazole" || got.Medications[0].Name != "caféine" {
+		t.Fatalf("not NFC: %+v", got)
+	}
+	if in.Allergies[0] != "sulfame\u0301thoxazole" || in.Medications[0].Name != "cafe\u0301ine" {
+		t.Fatal("normalizeText changed the caller's lists")
+	}
+
+	// Limits count the composed characters, so a decomposed name of the
+	// maximum length is accepted.
+	in = llmIntake
+	in.PatientName = strings.Repeat("e\u0301", maxNameLen)
+	if resp := New().Analyze(in); len(resp.ValidationErrors) != 0 {
+		t.Fatalf("decomposed name at the limit rejected: %v", resp.ValidationErrors)
+	}
+}
+
+func TestAnalyze_DecomposedNameMatchesPatient(t *testing.T) {
+	a := New()
+	first := llmIntake
+	first.PatientName = "José Pérez"
+	a.Analyze(first)
+
+	again := llmIntake
+	again.PatientName = "Jose\u0301 Pe\u0301rez"
+	if resp := a.Analyze(again); resp.PreviousRiskScore == nil {
+		t.Fatal("decomposed spelling of the same name did not find the earlier analysis")
+	}
+}
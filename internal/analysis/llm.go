@@ -0,0 +1,128 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+// ScoreRequest is the input to an LLM confidence scoring call: the deterministic
+// plan plus the findings the engine already produced for the case.
+type ScoreRequest struct {
+	Intake          Intake
+	Plan            Plan
+	Alternatives    []Alternative
+	RiskScore       int
+	RiskLevel       RiskLevel
+	Issues          []Issue
+	PlanSubstituted bool
+	// SystemPrompt is the rendered prompt of the Analyzer making the call.
+	SystemPrompt string
+	// historyCeiling is the Analyzer's history confidence ceiling, which
+	// the stub applies to lists filled only by named entries.
+	historyCeiling float64
+}
+
+// LLMResult is the confidence payload returned by an LLM client.
+type LLMResult struct {
+	PlanConfidence  float64
+	AlternativeConf []float64
+	// Factors is only set by the deterministic stub.
+	Factors *ConfidenceFactors
+	// Usage is recorded on the audit entry for real model calls.
+	Usage audit.LLMUsage
+	// Cached reports that the result was served by CachingLLMClient.
+	Cached bool
+}
+
+// LLMClient scores a deterministic plan. Implementations must be safe for concurrent use.
+type LLMClient interface {
+	Score(ctx context.Context, req ScoreRequest) (LLMResult, error)
+}
+
+// StubLLM is the deterministic default LLMClient.
+type StubLLM struct{}
+
+func (StubLLM) Score(_ context.Context, req ScoreRequest) (LLMResult, error) {
+	return callLLMStub(req), nil
+}
+
+const defaultLLMTimeout = 5 * time.Second
+
+// SetLLMClient replaces the confidence scoring client; nil is ignored.
+func (a *Analyzer) SetLLMClient(client LLMClient) {
+	if client == nil {
+		return
+	}
+	_ = a.update(func(s *settings) error {
+		s.llm = client
+		return nil
+	})
+}
+
+func SetLLMClient(client LLMClient) {
+	defaultAnalyzer.SetLLMClient(client)
+}
+
+// SetLLMTimeout bounds each scoring call; non-positive values restore the default.
+func (a *Analyzer) SetLLMTimeout(d time.Duration) {
+	if d <= 0 {
+		d = defaultLLMTimeout
+	}
+	_ = a.update(func(s *settings) error {
+		s.llmTimeout = d
+		return nil
+	})
+}
+
+func SetLLMTimeout(d time.Duration) {
+	defaultAnalyzer.SetLLMTimeout(d)
+}
+
+// scorePlan calls the configured client and falls back to the deterministic stub
+// on error, timeout, or malformed output. degraded reports whether the fallback was used.
+func scorePlan(ctx context.Context, s settings, req ScoreRequest) (res LLMResult, degraded bool) {
+	ctx, cancel := context.WithTimeout(ctx, s.llmTimeout)
+	defer cancel()
+
+	res, err := s.llm.Score(ctx, req)
+	if err == nil {
+		err = checkLLMResult(res, len(req.Alternatives))
+	}
+	if err != nil {
+		return callLLMStub(req), true
+	}
+
+	return holdToBand(res, req.RiskLevel), false
+}
+
+// holdToBand clamps model output to the confidence band of level, so the
+// deterministic guardrails stay authoritative.
+func holdToBand(res LLMResult, level RiskLevel) LLMResult {
+	band, ok := confidenceBands[level]
+	if !ok {
+		band = confidenceBands[RiskHigh]
+	}
+	res.PlanConfidence = clamp(res.PlanConfidence, band.Floor, band.Ceiling)
+	for i := range res.AlternativeConf {
+		res.AlternativeConf[i] = clamp(res.AlternativeConf[i], 0, band.Ceiling)
+	}
+	return res
+}
+
+func checkLLMResult(res LLMResult, alts int) error {
+	if res.PlanConfidence < 0 || res.PlanConfidence > 1 {
+		return fmt.Errorf("plan confidence %.3f out of range", res.PlanConfidence)
+	}
+	if len(res.AlternativeConf) != alts {
+		return fmt.Errorf("expected %d alternative confidences, got %d", alts, len(res.AlternativeConf))
+	}
+	for _, c := range res.AlternativeConf {
+		if c < 0 || c > 1 {
+			return fmt.Errorf("alternative confidence %.3f out of range", c)
+		}
+	}
+	return nil
+}
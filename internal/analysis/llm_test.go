@@ -0,0 +1,124 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type ctxKey struct{}
+
+type fakeLLM struct {
+	t        *testing.T
+	wantPlan string
+	called   bool
+	result   LLMResult
+	err      error
+	delay    time.Duration
+}
+
+func (f *fakeLLM) Score(ctx context.Context, req ScoreRequest) (LLMResult, error) {
+	f.called = true
+	if got, _ := ctx.Value(ctxKey{}).(string); got != "req-123" {
+		f.t.Errorf("expected request context to propagate, got value %q", got)
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		f.t.Errorf("expected scoring context to carry a timeout")
+	}
+	if req.Plan.Medication != f.wantPlan {
+		f.t.Errorf("expected plan %s, got %s", f.wantPlan, req.Plan.Medication)
+	}
+	if f.delay > 0 {
+		select {
+		case <-time.After(f.delay):
+		case <-ctx.Done():
+			return LLMResult{}, ctx.Err()
+		}
+	}
+	return f.result, f.err
+}
+
+func useLLM(t *testing.T, client LLMClient, timeout time.Duration) {
+	t.Helper()
+	SetLLMClient(client)
+	SetLLMTimeout(timeout)
+	t.Cleanup(func() {
+		SetLLMClient(StubLLM{})
+		SetLLMTimeout(0)
+	})
+}
+
+var llmIntake = Intake{
+	PatientName: "LLM",
+	Age:         45,
+	WeightKg:    78,
+	HeightCm:    175,
+	BP:          "125/80",
+	Complaint:   "Hair Loss",
+
+	ConfirmedNoConditions:  true,
+	ConfirmedNoMedications: true,
+	ConfirmedNoAllergies:   true,
+}
+
+func TestAnalyze_UsesConfiguredLLMClient(t *testing.T) {
+	fake := &fakeLLM{
+		t:        t,
+		wantPlan: "Finasteride",
+		result:   LLMResult{PlanConfidence: 0.81, AlternativeConf: []float64{0.7, 0.6}},
+	}
+	useLLM(t, fake, time.Second)
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "req-123")
+	resp := AnalyzeContext(ctx, llmIntake, Options{})
+	if !fake.called {
+		t.Fatalf("expected LLM client to be called")
+	}
+	if len(resp.ValidationErrors) > 0 {
+		t.Fatalf("unexpected validation errors: %v", resp.ValidationErrors)
+	}
+	if resp.PlanConfidence != 0.81 {
+		t.Fatalf("expected model confidence 0.81, got %.2f", resp.PlanConfidence)
+	}
+	if resp.Alternatives[0].Confidence != 0.7 {
+		t.Fatalf("expected alternative confidence 0.7, got %.2f", resp.Alternatives[0].Confidence)
+	}
+	if hasIssue(resp.FlaggedIssues, "scoring") {
+		t.Fatalf("healthy client should not flag degraded scoring")
+	}
+}
+
+func TestAnalyze_LLMFailureFallsBackToStub(t *testing.T) {
+	stub := callLLMStub(ScoreRequest{Intake: llmIntake, RiskScore: 1, RiskLevel: "LOW"})
+
+	cases := map[string]*fakeLLM{
+		"error":     {t: t, wantPlan: "Finasteride", err: errors.New("boom")},
+		"timeout":   {t: t, wantPlan: "Finasteride", delay: time.Second},
+		"malformed": {t: t, wantPlan: "Finasteride", result: LLMResult{PlanConfidence: 1.7}},
+	}
+	for name, fake := range cases {
+		t.Run(name, func(t *testing.T) {
+			fake.t = t
+			useLLM(t, fake, 20*time.Millisecond)
+
+			ctx := context.WithValue(context.Background(), ctxKey{}, "req-123")
+			resp := AnalyzeContext(ctx, llmIntake, Options{})
+			if len(resp.ValidationErrors) > 0 {
+				t.Fatalf("LLM failure must not fail the analysis: %v", resp.ValidationErrors)
+			}
+			if resp.PlanConfidence != stub.PlanConfidence {
+				t.Fatalf("expected stub confidence %.3f, got %.3f", stub.PlanConfidence, resp.PlanConfidence)
+			}
+			found := false
+			for _, issue := range resp.FlaggedIssues {
+				if issue.Code == "LLM_SCORING_DEGRADED" && issue.Severity == "info" {
+					found = true
+				}
+			}
+			if !found {
+				t.Fatalf("expected degraded scoring info issue")
+			}
+		})
+	}
+}
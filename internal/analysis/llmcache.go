@@ -0,0 +1,233 @@
+package analysis
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/metrics"
+)
+
+var llmCacheLookups = metrics.NewCounter("llm_cache_lookups_total", "LLM scoring cache lookups by result.", "result")
+
+// CachingLLMClient is an LRU cache in front of another LLMClient. Only
+// successful, well-formed results are stored, so failures are always retried.
+type CachingLLMClient struct {
+	next LLMClient
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type llmCacheEntry struct {
+	key     string
+	result  LLMResult
+	expires time.Time
+}
+
+// NewCachingLLMClient wraps next with a cache of at most size entries, each valid for ttl.
+func NewCachingLLMClient(next LLMClient, size int, ttl time.Duration) *CachingLLMClient {
+	return &CachingLLMClient{
+		next:    next,
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		order:   list.New(),
+		entries: make(map[string]*list.Element, size),
+	}
+}
+
+func (c *CachingLLMClient) Score(ctx context.Context, req ScoreRequest) (LLMResult, error) {
+	key := llmCacheKey(req)
+	if res, ok := c.get(key); ok {
+		llmCacheLookups.Inc("hit")
+		res.Cached = true
+		return res, nil
+	}
+	llmCacheLookups.Inc("miss")
+
+	res, err := c.next.Score(ctx, req)
+	if err != nil {
+		return res, err
+	}
+	if checkLLMResult(res, len(req.Alternatives)) == nil {
+		c.put(key, res)
+	}
+	return res, nil
+}
+
+// Len reports the number of cached entries, including expired ones not yet evicted.
+func (c *CachingLLMClient) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *CachingLLMClient) get(key string) (LLMResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return LLMResult{}, false
+	}
+	entry := el.Value.(*llmCacheEntry)
+	if c.now().After(entry.expires) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return LLMResult{}, false
+	}
+	c.order.MoveToFront(el)
+	return copyLLMResult(entry.result), true
+}
+
+func (c *CachingLLMClient) put(key string, res LLMResult) {
+	if c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := &llmCacheEntry{key: key, result: copyLLMResult(res), expires: c.now().Add(c.ttl)}
+	if el, ok := c.entries[key]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*llmCacheEntry).key)
+	}
+}
+
+// copyLLMResult prevents callers from mutating cached slices.
+func copyLLMResult(res LLMResult) LLMResult {
+	res.AlternativeConf = append([]float64(nil), res.AlternativeConf...)
+	return res
+}
+
+// llmCacheKey hashes the clinically relevant intake fields (never patientName or
+// userId) in canonical form together with the plan medication, dose, prompt,
+// and the risk and issues the rules produced, which a ruleset change can alter
+// for the same intake.
+func llmCacheKey(req ScoreRequest) string {
+	in := req.Intake
+	meds := make([]string, 0, len(in.Medications))
+	for _, m := range in.Medications {
+		meds = append(meds, canonical(m.Name)+"|"+canonical(m.Dosage)+"|"+canonical(m.Frequency))
+	}
+	sort.Strings(meds)
+	var allergies []string
+	for _, a := range in.AllergyDetails {
+		allergies = append(allergies, canonical(a.Substance)+"|"+canonical(a.Severity))
+	}
+	sort.Strings(allergies)
+	var prior []string
+	for _, p := range in.PriorTreatments {
+		prior = append(prior, canonical(p.Medication)+"|"+canonical(p.MaxDose)+"|"+canonical(p.Outcome))
+	}
+	sort.Strings(prior)
+	issues := make([]string, 0, len(req.Issues))
+	for _, is := range req.Issues {
+		issues = append(issues, is.Code+"|"+string(is.Severity))
+	}
+	sort.Strings(issues)
+	key := struct {
+		Age         int      `json:"age"`
+		WeightKg    float64  `json:"weightKg"`
+		HeightCm    float64  `json:"heightCm"`
+		BP          string   `json:"bp"`
+		BMI         float64  `json:"bmi"`
+		Conditions  []string `json:"conditions"`
+		Allergies   []string `json:"allergies"`
+		Medications []string `json:"medications"`
+		Smoking     string   `json:"smoking"`
+		Alcohol     string   `json:"alcohol"`
+		Exercise    string   `json:"exercise"`
+		Complaint   string   `json:"complaint"`
+		Plan        string   `json:"plan"`
+		Dose        string   `json:"dose"`
+		Alts        int      `json:"alts"`
+		Prompt      string   `json:"prompt"`
+		RiskLevel   string   `json:"riskLevel"`
+		RiskScore   int      `json:"riskScore"`
+		Issues      []string `json:"issues"`
+		// Omitted when empty so keys of intakes without the later optional
+		// fields are unchanged.
+		ConditionCodes []string `json:"conditionCodes,omitempty"`
+		AllergyDetails []string `json:"allergyDetails,omitempty"`
+		Complaints     []string `json:"complaints,omitempty"`
+		Severity       string   `json:"severity,omitempty"`
+		DurationWeeks  int      `json:"durationWeeks,omitempty"`
+		Prior          []string `json:"priorTreatments,omitempty"`
+		PackYears      float64  `json:"packYears,omitempty"`
+		QuitYearsAgo   *float64 `json:"quitYearsAgo,omitempty"`
+		DrinksPerWeek  *float64 `json:"drinksPerWeek,omitempty"`
+		SleepHours     float64  `json:"sleepHours,omitempty"`
+		FamilyHistory  []string `json:"familyHistory,omitempty"`
+		WaistCm        float64  `json:"waistCm,omitempty"`
+		Labs           *Labs    `json:"labs,omitempty"`
+	}{
+		Age:         in.Age,
+		WeightKg:    in.WeightKg,
+		HeightCm:    in.HeightCm,
+		BP:          strings.ReplaceAll(canonical(in.BP), " ", ""),
+		BMI:         in.BMI,
+		Conditions:  canonicalSet(in.Conditions),
+		Allergies:   canonicalSet(in.Allergies),
+		Medications: meds,
+		Smoking:     canonical(in.Smoking),
+		Alcohol:     canonical(in.Alcohol),
+		Exercise:    canonical(in.Exercise),
+		Complaint:   canonical(in.Complaint),
+		Plan:        canonical(req.Plan.Medication),
+		Dose:        canonical(req.Plan.Dosage),
+		Alts:        len(req.Alternatives),
+		Prompt:      req.SystemPrompt,
+		RiskLevel:   string(req.RiskLevel),
+		RiskScore:   req.RiskScore,
+		Issues:      issues,
+
+		ConditionCodes: canonicalSet(in.ConditionCodes),
+		AllergyDetails: allergies,
+		Complaints:     canonicalSet(in.Complaints),
+		Severity:       canonical(in.ComplaintSeverity),
+		DurationWeeks:  in.ComplaintDurationWeeks,
+		Prior:          prior,
+		PackYears:      in.SmokingPackYears,
+		QuitYearsAgo:   in.SmokingQuitYearsAgo,
+		DrinksPerWeek:  in.AlcoholDrinksPerWeek,
+		SleepHours:     in.SleepHours,
+		FamilyHistory:  canonicalSet(in.FamilyHistory),
+		WaistCm:        in.WaistCircumferenceCm,
+		Labs:           in.Labs,
+	}
+	body, _ := json.Marshal(key)
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+func canonical(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+func canonicalSet(values []string) []string {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if c := canonical(v); c != "" {
+			out = append(out, c)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,134 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type countingLLM struct {
+	calls int32
+	fail  bool
+}
+
+func (c *countingLLM) Score(_ context.Context, req ScoreRequest) (LLMResult, error) {
+	atomic.AddInt32(&c.calls, 1)
+	if c.fail {
+		return LLMResult{}, errors.New("unavailable")
+	}
+	conf := make([]float64, len(req.Alternatives))
+	for i := range conf {
+		conf[i] = 0.6
+	}
+	return LLMResult{PlanConfidence: 0.7, AlternativeConf: conf}, nil
+}
+
+func TestCachingLLMClient_ParallelIdenticalIntakes(t *testing.T) {
+	next := &countingLLM{}
+	cache := NewCachingLLMClient(next, 8, time.Minute)
+	useLLM(t, cache, time.Second)
+
+	first := Analyze(llmIntake)
+	if first.LLMCacheHit {
+		t.Fatalf("first analysis should miss the cache")
+	}
+
+	var wg sync.WaitGroup
+	var hits int32
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			in := llmIntake
+			// Patient name is not part of the cache key.
+			in.PatientName = "Patient " + string(rune('A'+i%26))
+			resp := Analyze(in)
+			if len(resp.ValidationErrors) > 0 {
+				t.Errorf("unexpected validation errors: %v", resp.ValidationErrors)
+			}
+			if resp.LLMCacheHit {
+				atomic.AddInt32(&hits, 1)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if next.calls != 1 {
+		t.Fatalf("expected a single upstream call, got %d", next.calls)
+	}
+	if hits != 50 {
+		t.Fatalf("expected 50 cache hits, got %d", hits)
+	}
+}
+
+func TestCachingLLMClient_KeyAndExpiry(t *testing.T) {
+	next := &countingLLM{}
+	cache := NewCachingLLMClient(next, 1, time.Minute)
+	now := time.Unix(0, 0)
+	cache.now = func() time.Time { return now }
+
+	plan, alts := hairLossPlan(buildPlanContext{})
+	req := ScoreRequest{Intake: llmIntake, Plan: plan, Alternatives: alts}
+	ctx := context.Background()
+
+	_, _ = cache.Score(ctx, req)
+	if res, _ := cache.Score(ctx, req); !res.Cached {
+		t.Fatalf("expected cache hit for identical request")
+	}
+
+	changed := req
+	changed.Intake.BP = "150/95"
+	if res, _ := cache.Score(ctx, changed); res.Cached {
+		t.Fatalf("changing a clinical field must miss the cache")
+	}
+	if cache.Len() != 1 {
+		t.Fatalf("expected LRU to evict down to size 1, got %d", cache.Len())
+	}
+
+	now = now.Add(2 * time.Minute)
+	if res, _ := cache.Score(ctx, changed); res.Cached {
+		t.Fatalf("expired entry must not be served")
+	}
+	if next.calls != 3 {
+		t.Fatalf("expected 3 upstream calls, got %d", next.calls)
+	}
+}
+
+func TestCachingLLMClient_MissesAfterRulesetChange(t *testing.T) {
+	cache := NewCachingLLMClient(&countingLLM{}, 8, time.Minute)
+	a := New(WithLLMClient(cache))
+	in := llmIntake
+	in.Conditions, in.ConfirmedNoConditions = []string{"heart disease"}, false
+
+	a.Analyze(in)
+	if !a.Analyze(in).LLMCacheHit {
+		t.Fatalf("expected a cache hit before the ruleset changes")
+	}
+	r := a.Rules().Ruleset
+	r.RiskWeights = []RiskWeight{{Code: "heart_disease", Points: 7}}
+	if err := a.SetRuleset(r, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if resp := a.Analyze(in); resp.LLMCacheHit {
+		t.Fatalf("risk score %d was served the score cached for the old ruleset", resp.RiskScore)
+	}
+}
+
+func TestCachingLLMClient_DoesNotCacheFailures(t *testing.T) {
+	next := &countingLLM{fail: true}
+	cache := NewCachingLLMClient(next, 8, time.Minute)
+	plan, alts := hairLossPlan(buildPlanContext{})
+	req := ScoreRequest{Intake: llmIntake, Plan: plan, Alternatives: alts}
+
+	for i := 0; i < 2; i++ {
+		if _, err := cache.Score(context.Background(), req); err == nil {
+			t.Fatalf("expected upstream error")
+		}
+	}
+	if next.calls != 2 || cache.Len() != 0 {
+		t.Fatalf("failures must not be cached (calls=%d, len=%d)", next.calls, cache.Len())
+	}
+}
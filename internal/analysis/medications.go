@@ -0,0 +1,66 @@
+package analysis
+
+import (
+	"slices"
+	"strings"
+)
+
+// assessMedications echoes meds with how the rules read each one and the
+// codes of the flagged issues involving it. An issue involves a medication
+// when one of its related medications is the medication's normalized name or
+// one of its components, the names the rules match on.
+func (s settings) assessMedications(meds []Medication, issues []Issue) []AssessedMedication {
+	var out []AssessedMedication
+	for _, m := range meds {
+		name := normalizeName(m.Name)
+		if name == "" {
+			continue
+		}
+		names := []string{name}
+		var generics, classes []string
+		for _, c := range medicationComponents(m) {
+			generics = append(generics, c.Name)
+			if !slices.Contains(names, c.Name) {
+				names = append(names, c.Name)
+			}
+		}
+		known := false
+		for _, n := range generics {
+			for _, c := range s.drugClasses {
+				if c.has(n) && !slices.Contains(classes, c.Name) {
+					classes = append(classes, c.Name)
+				}
+			}
+			known = known || s.knownDrug(n)
+		}
+		am := AssessedMedication{
+			Input:        m,
+			Generic:      strings.Join(generics, " + "),
+			Class:        strings.Join(classes, ", "),
+			ParsedDoseMg: extractMg(m.Dosage),
+			Recognized:   known || len(classes) > 0,
+			Flags:        []string{},
+		}
+		for _, is := range issues {
+			if !slices.Contains(am.Flags, is.Code) && slices.ContainsFunc(is.RelatedMedications, func(r string) bool { return slices.Contains(names, r) }) {
+				am.Flags = append(am.Flags, is.Code)
+			}
+		}
+		out = append(out, am)
+	}
+	return out
+}
+
+// knownDrug reports whether a generic is a combination component or is named
+// by an interaction rule or dose cap of the active ruleset.
+func (s settings) knownDrug(name string) bool {
+	if knownIngredient(name) {
+		return true
+	}
+	for _, r := range s.rules.InteractionRules() {
+		if r.Drug == name || r.With == name {
+			return true
+		}
+	}
+	return slices.ContainsFunc(s.doseCaps, func(c DoseCap) bool { return strings.Contains(name, c.Medication) })
+}
@@ -0,0 +1,63 @@
+package analysis
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMedicationsAssessed(t *testing.T) {
+	in := Intake{
+		PatientName: "Meds", Age: 58, WeightKg: 88, HeightCm: 178, BP: "132/84", Complaint: "ED",
+		Allergies: []string{"Sulfa"},
+		Medications: []Medication{
+			{Name: "Amlodipine", Dosage: "10mg", Frequency: "daily"},
+			{Name: "Caduet", Dosage: "5/20mg", Frequency: "daily"},
+			{Name: "Sulfasalazine", Dosage: "500mg", Frequency: "twice daily"},
+			{Name: "Zorblatin", Dosage: "2 tabs"},
+			{Name: "  "},
+		},
+	}
+	resp := Analyze(in)
+	checkResponseInvariants(t, in, resp)
+	got := map[string]AssessedMedication{}
+	for _, am := range resp.MedicationsAssessed {
+		got[am.Input.Name] = am
+	}
+	if len(resp.MedicationsAssessed) != 4 {
+		t.Fatalf("assessed %d medications, want the 4 named: %+v", len(resp.MedicationsAssessed), resp.MedicationsAssessed)
+	}
+
+	aml := got["Amlodipine"]
+	if aml.Generic != "amlodipine" || aml.ParsedDoseMg != 10 || !aml.Recognized || !slices.Contains(aml.Flags, "DDI_PDE5_AMLODIPINE") {
+		t.Errorf("amlodipine = %+v", aml)
+	}
+	caduet := got["Caduet"]
+	if caduet.Generic != "amlodipine + atorvastatin" || !caduet.Recognized || !slices.Contains(caduet.Flags, "DDI_PDE5_AMLODIPINE") {
+		t.Errorf("combination = %+v", caduet)
+	}
+	if sulfa := got["Sulfasalazine"]; !slices.Equal(sulfa.Flags, []string{"ALLERGY_MEDICATION"}) {
+		t.Errorf("allergy conflict = %+v", sulfa)
+	}
+	if unknown := got["Zorblatin"]; unknown.Recognized || unknown.Class != "" || len(unknown.Flags) != 0 || unknown.Flags == nil {
+		t.Errorf("unrecognized = %+v", unknown)
+	}
+	if !slices.ContainsFunc(resp.FlaggedIssues, func(is Issue) bool {
+		return is.Code == "ALLERGY_MEDICATION" && is.Severity == SeverityWarning && slices.Equal(is.RelatedMedications, []string{"sulfasalazine"})
+	}) {
+		t.Errorf("issues = %+v", resp.FlaggedIssues)
+	}
+}
+
+func TestMedicationsAssessed_FreeTextName(t *testing.T) {
+	in := nitratePatient(Medication{Name: "Nitroglycerin 0.4mg SL p.r.n."})
+	resp := Analyze(in)
+	checkResponseInvariants(t, in, resp)
+	if len(resp.MedicationsAssessed) != 1 {
+		t.Fatalf("assessed = %+v", resp.MedicationsAssessed)
+	}
+	// The rules match the whole entry, and read doses from the dosage only.
+	am := resp.MedicationsAssessed[0]
+	if am.Generic != "nitroglycerin 0.4mg sl p.r.n." || am.Class != "nitrate" || am.ParsedDoseMg != 0 || !slices.Contains(am.Flags, "CI_NITRATE_PRN_PDE5") {
+		t.Errorf("assessed = %+v", am)
+	}
+}
@@ -0,0 +1,135 @@
+package analysis
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Metabolic syndrome criteria (harmonized IDF/AHA/NHLBI 2009 definition).
+// Waist and HDL thresholds differ by sex, which the intake records only
+// implicitly: an ED complaint means a male patient, and otherwise a value
+// counts only when it is on the same side of both thresholds.
+const (
+	waistMaleCm           = 102
+	waistFemaleCm         = 88
+	highTriglyceridesMgDl = 150
+	hdlMaleMgDl           = 40
+	hdlFemaleMgDl         = 50
+	metabolicSystolic     = 130
+	metabolicDiastolic    = 85
+	prediabetesA1c        = 5.7
+	metabolicCriteriaMet  = 3
+)
+
+// Metabolic syndrome criteria, as named in metabolicSyndrome.Met.
+const (
+	metWaist         = "waist"
+	metTriglycerides = "triglycerides"
+	metHDL           = "hdl"
+	metBP            = "bp"
+	metGlucose       = "glucose"
+)
+
+// Plausible ranges for waist circumference and labs; values outside them
+// are entry or unit mistakes.
+const (
+	minWaistCm, maxWaistCm                     = 40, 250
+	minTriglyceridesMgDl, maxTriglyceridesMgDl = 10, 5000
+	minHDLMgDl, maxHDLMgDl                     = 5, 200
+	minA1cPercent, maxA1cPercent               = 3, 20
+)
+
+// metabolicSyndrome is the outcome of evaluating the five criteria.
+// Determinable counts criteria the intake holds enough data to decide; with
+// fewer than three the syndrome cannot be ruled in or out and Evaluated is
+// false.
+type metabolicSyndrome struct {
+	Met          []string
+	Determinable int
+	Evaluated    bool
+	Present      bool
+}
+
+// evaluateMetabolicSyndrome checks waist circumference, triglycerides, HDL,
+// blood pressure (or hypertension history), and glucose (A1c, or diabetes
+// history) against the metabolic syndrome criteria. Three met criteria
+// make the syndrome present.
+func evaluateMetabolicSyndrome(in Intake, cond map[string]bool) metabolicSyndrome {
+	var r metabolicSyndrome
+	male := slices.ContainsFunc(intakeComplaints(in), func(c string) bool { return normalizeName(c) == "ed" })
+	criterion := func(name string, determinable, met bool) {
+		if !determinable {
+			return
+		}
+		r.Determinable++
+		if met {
+			r.Met = append(r.Met, name)
+		}
+	}
+	// sexed decides a criterion with sex-specific thresholds: met at or past
+	// the male threshold for a man, and otherwise only when past both.
+	sexed := func(name string, v, maleCut, femaleCut float64, below bool) {
+		if v <= 0 {
+			return
+		}
+		past := func(cut float64) bool {
+			if below {
+				return v < cut
+			}
+			return v >= cut
+		}
+		if male {
+			criterion(name, true, past(maleCut))
+			return
+		}
+		criterion(name, past(maleCut) == past(femaleCut), past(maleCut) && past(femaleCut))
+	}
+
+	sexed(metWaist, in.WaistCircumferenceCm, waistMaleCm, waistFemaleCm, false)
+	var labs Labs
+	if in.Labs != nil {
+		labs = *in.Labs
+	}
+	criterion(metTriglycerides, labs.TriglyceridesMgDl > 0, labs.TriglyceridesMgDl >= highTriglyceridesMgDl)
+	sexed(metHDL, labs.HDLMgDl, hdlMaleMgDl, hdlFemaleMgDl, true)
+	systolic, diastolic, err := parseBP(in.BP)
+	elevated := err == nil && (systolic >= metabolicSystolic || diastolic >= metabolicDiastolic)
+	criterion(metBP, err == nil || cond[condHypertension], elevated || cond[condHypertension])
+	criterion(metGlucose, labs.A1cPercent > 0 || cond[condDiabetes], labs.A1cPercent >= prediabetesA1c || cond[condDiabetes])
+
+	r.Evaluated = r.Determinable >= metabolicCriteriaMet
+	r.Present = len(r.Met) >= metabolicCriteriaMet
+	return r
+}
+
+// assessMetabolicSyndrome scores metabolic syndrome and flags it with the
+// criteria met.
+func assessMetabolicSyndrome(in Intake, cond map[string]bool, risk *riskAccumulator, l localizer) (metabolicSyndrome, []Issue) {
+	r := evaluateMetabolicSyndrome(in, cond)
+	if !risk.check("metabolic_syndrome", "METABOLIC_SYNDROME", r.Present, func() map[string]any {
+		return map[string]any{"criteriaMet": append([]string{}, r.Met...), "criteriaDeterminable": r.Determinable}
+	}) {
+		return r, nil
+	}
+	risk.add("metabolic_syndrome", fmt.Sprintf("Metabolic syndrome (%s)", strings.Join(r.Met, ", ")))
+	data := map[string]any{"Criteria": strings.Join(r.Met, ", "), "Count": len(r.Met)}
+	return r, []Issue{newIssue("METABOLIC_SYNDROME", SeverityWarning, l.issue("METABOLIC_SYNDROME", data))}
+}
+
+// metabolicErrors rejects implausible waist circumference and lab values.
+func metabolicErrors(in Intake) []FieldError {
+	var errs []FieldError
+	check := func(field string, v, lo, hi float64) {
+		if v != 0 && (v < lo || v > hi) {
+			errs = append(errs, FieldError{Field: field, Code: "out_of_range", Message: fmt.Sprintf("%s must be between %s and %s, not %s", field, formatQuantity(lo), formatQuantity(hi), formatQuantity(v))})
+		}
+	}
+	check("waistCircumferenceCm", in.WaistCircumferenceCm, minWaistCm, maxWaistCm)
+	if labs := in.Labs; labs != nil {
+		check("labs.triglyceridesMgDl", labs.TriglyceridesMgDl, minTriglyceridesMgDl, maxTriglyceridesMgDl)
+		check("labs.hdlMgDl", labs.HDLMgDl, minHDLMgDl, maxHDLMgDl)
+		check("labs.a1cPercent", labs.A1cPercent, minA1cPercent, maxA1cPercent)
+	}
+	return errs
+}
@@ -0,0 +1,78 @@
+package analysis
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEvaluateMetabolicSyndrome(t *testing.T) {
+	tests := []struct {
+		name         string
+		complaint    string
+		bp           string
+		waist        float64
+		labs         *Labs
+		conditions   []string
+		met          []string
+		determinable int
+		evaluated    bool
+		present      bool
+	}{
+		{"bp only", "Weight Loss", "135/80", 0, nil, nil, []string{metBP}, 1, false, false},
+		{"no data", "Weight Loss", "", 0, nil, nil, nil, 0, false, false},
+		{"three met", "Weight Loss", "135/80", 110, &Labs{TriglyceridesMgDl: 180}, nil, []string{metWaist, metTriglycerides, metBP}, 3, true, true},
+		{"three determinable, one met", "Weight Loss", "118/76", 80, &Labs{HDLMgDl: 55}, nil, nil, 3, true, false},
+		{"history stands in for bp and glucose", "Weight Loss", "", 0, &Labs{TriglyceridesMgDl: 200}, []string{"HTN", "T2DM"}, []string{metTriglycerides, metBP, metGlucose}, 3, true, true},
+		{"a1c in prediabetes range", "Weight Loss", "120/70", 0, &Labs{A1cPercent: 5.9, HDLMgDl: 35}, nil, []string{metHDL, metGlucose}, 3, true, false},
+		// Waist 95 and HDL 45 are past the female thresholds only.
+		{"ambiguous sex thresholds", "Weight Loss", "140/90", 95, &Labs{HDLMgDl: 45, TriglyceridesMgDl: 160}, nil, []string{metTriglycerides, metBP}, 2, false, false},
+		{"ed means male thresholds", "ED", "140/90", 95, &Labs{HDLMgDl: 45, TriglyceridesMgDl: 160}, nil, []string{metTriglycerides, metBP}, 4, true, false},
+		{"male thresholds met", "ED", "140/90", 104, &Labs{HDLMgDl: 38}, nil, []string{metWaist, metHDL, metBP}, 3, true, true},
+	}
+	for _, tt := range tests {
+		in := Intake{Complaint: tt.complaint, BP: tt.bp, WaistCircumferenceCm: tt.waist, Labs: tt.labs, Conditions: tt.conditions}
+		cond, _ := normalizeConditions(in.Conditions)
+		got := evaluateMetabolicSyndrome(in, cond)
+		if !slices.Equal(got.Met, tt.met) {
+			t.Errorf("%s: met %v, want %v", tt.name, got.Met, tt.met)
+		}
+		if got.Determinable != tt.determinable || got.Evaluated != tt.evaluated || got.Present != tt.present {
+			t.Errorf("%s: got %+v, want determinable %d, evaluated %t, present %t", tt.name, got, tt.determinable, tt.evaluated, tt.present)
+		}
+	}
+}
+
+func TestAnalyze_MetabolicSyndrome(t *testing.T) {
+	in := llmIntake
+	in.Complaint, in.BP, in.WaistCircumferenceCm = "Weight Loss", "135/86", 112
+	in.Labs = &Labs{TriglyceridesMgDl: 190}
+	resp := New().Analyze(in)
+	if len(resp.ValidationErrors) > 0 {
+		t.Fatal(resp.ValidationErrors)
+	}
+	i := slices.IndexFunc(resp.FlaggedIssues, func(i Issue) bool { return i.Code == "METABOLIC_SYNDROME" })
+	if i < 0 || resp.FlaggedIssues[i].Severity != SeverityWarning || !strings.Contains(resp.FlaggedIssues[i].Description, "3 of 5 criteria met (waist, triglycerides, bp)") {
+		t.Fatalf("metabolic syndrome issue: %+v", resp.FlaggedIssues)
+	}
+	if !slices.ContainsFunc(resp.RiskFactors, func(f RiskFactor) bool { return f.Code == "metabolic_syndrome" && f.Points == 2 }) {
+		t.Errorf("risk factors %+v", resp.RiskFactors)
+	}
+	if !strings.Contains(resp.RecommendedPlan.Rationale, "Metabolic syndrome present") {
+		t.Errorf("rationale %q", resp.RecommendedPlan.Rationale)
+	}
+}
+
+func TestMetabolicErrors(t *testing.T) {
+	in := Intake{WaistCircumferenceCm: 20, Labs: &Labs{TriglyceridesMgDl: 9000, HDLMgDl: 50, A1cPercent: 57}}
+	var fields []string
+	for _, e := range metabolicErrors(in) {
+		if e.Code != "out_of_range" {
+			t.Errorf("%s: code %q", e.Field, e.Code)
+		}
+		fields = append(fields, e.Field)
+	}
+	if want := []string{"waistCircumferenceCm", "labs.triglyceridesMgDl", "labs.a1cPercent"}; !slices.Equal(fields, want) {
+		t.Errorf("fields %v, want %v", fields, want)
+	}
+}
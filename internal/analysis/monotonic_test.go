@@ -0,0 +1,64 @@
+package analysis
+
+import (
+	"slices"
+	"testing"
+)
+
+// TestRiskMonotonic adds one condition, medication, or allergy at a time to
+// each base intake and requires the risk never to read lower for it.
+// Filling in an unconfirmed list lifts the HISTORY_INCOMPLETE cap, which the
+// factor cap must hold in its place.
+func TestRiskMonotonic(t *testing.T) {
+	bases := map[string]Intake{
+		"ed":            {Age: 45, WeightKg: 78, HeightCm: 178, BP: "122/78", Complaint: "ED"},
+		"ed documented": {Age: 45, WeightKg: 78, HeightCm: 178, BP: "122/78", Complaint: "ED", Conditions: []string{}, Medications: []Medication{}, Allergies: []string{}},
+		"ed treated": {Age: 67, WeightKg: 100, HeightCm: 170, BP: "165/100", Complaint: "ED", Conditions: []string{"hypertension"},
+			Medications: []Medication{{Name: "amlodipine", Dosage: "5mg"}, {Name: "tamsulosin", Dosage: "0.4mg"}, {Name: "simvastatin", Dosage: "40mg"}}, Allergies: []string{"penicillin"}},
+		"ed renal":  {Age: 60, WeightKg: 80, HeightCm: 175, BP: "130/85", Complaint: "ED", Conditions: []string{"kidney disease"}, ConfirmedNoMedications: true},
+		"hair loss": {Age: 35, WeightKg: 75, HeightCm: 180, BP: "118/76", Complaint: "hair loss"},
+		"weight loss": {Age: 50, WeightKg: 105, HeightCm: 175, BP: "132/84", Complaint: "weight loss", Conditions: []string{"diabetes"},
+			Medications: []Medication{{Name: "metformin", Dosage: "500mg", Frequency: "BID"}}},
+		"general": {Age: 40, WeightKg: 80, HeightCm: 180, BP: "120/80", Complaint: "fatigue"},
+		// Allergies are the only unconfirmed section, so naming one
+		// completes the history.
+		"ed allergies open": {Age: 52, WeightKg: 84, HeightCm: 176, BP: "126/82", Complaint: "ED", ConfirmedNoConditions: true, ConfirmedNoMedications: true},
+	}
+	conditions := []string{"heart disease", "kidney disease", "liver disease", "diabetes", "hypertension", "asthma"}
+	medications := []string{"nitroglycerin", "isosorbide mononitrate", "amlodipine", "tamsulosin", "doxazosin", "simvastatin", "metformin", "contrast", "sildenafil", "tadalafil", "finasteride"}
+	allergies := []string{"sildenafil", "tadalafil", "finasteride", "minoxidil", "metformin", "orlistat", "phentermine", "penicillin"}
+
+	a := New()
+	analyze := func(t *testing.T, in Intake) Response {
+		t.Helper()
+		in.PatientName = "Monotonic"
+		resp := a.AnalyzeWithOptions(in, Options{DryRun: true})
+		if len(resp.ValidationErrors) > 0 {
+			t.Fatalf("invalid intake %+v: %v", in, resp.ValidationErrors)
+		}
+		return resp
+	}
+	for name, base := range bases {
+		t.Run(name, func(t *testing.T) {
+			want := analyze(t, base)
+			for _, c := range conditions {
+				in := base
+				in.Conditions = append(slices.Clone(base.Conditions), c)
+				in.ConfirmedNoConditions = false
+				checkRiskMonotonic(t, "condition "+c, want, analyze(t, in))
+			}
+			for _, m := range medications {
+				in := base
+				in.Medications = append(slices.Clone(base.Medications), Medication{Name: m, Dosage: "10mg", Frequency: "daily"})
+				in.ConfirmedNoMedications = false
+				checkRiskMonotonic(t, "medication "+m, want, analyze(t, in))
+			}
+			for _, al := range allergies {
+				in := base
+				in.Allergies = append(slices.Clone(base.Allergies), al)
+				in.ConfirmedNoAllergies = false
+				checkRiskMonotonic(t, "allergy "+al, want, analyze(t, in))
+			}
+		})
+	}
+}
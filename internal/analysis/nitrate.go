@@ -0,0 +1,55 @@
+package analysis
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// prnTerms mark a medication taken as needed rather than on a schedule.
+var prnTerms = []string{"prn", "as needed", "when needed", "if needed", "on demand", "as required"}
+
+// prnMedication reports whether m is taken as needed. The frequency decides
+// when it is set; otherwise a note in the name or dosage, as in
+// "Nitroglycerin 0.4mg SL PRN", does.
+func prnMedication(m Medication) bool {
+	text := m.Frequency
+	if strings.TrimSpace(text) == "" {
+		text = m.Name + " " + m.Dosage
+	}
+	words := " " + strings.Join(strings.FieldsFunc(strings.ToLower(strings.ReplaceAll(text, ".", "")), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}), " ") + " "
+	return slices.ContainsFunc(prnTerms, func(t string) bool { return strings.Contains(words, " "+t+" ") })
+}
+
+// nitrateUse returns the normalized names of the nitrates in meds, split
+// into those taken as needed and those taken on a schedule, each sorted. A
+// nitrate with no frequency counts as scheduled.
+func nitrateUse(meds []Medication) (prn, scheduled []string) {
+	for _, m := range meds {
+		name := normalizeName(m.Name)
+		if name == "" || !classNitrate.has(name) {
+			continue
+		}
+		if prnMedication(m) {
+			prn = append(prn, name)
+		} else {
+			scheduled = append(scheduled, name)
+		}
+	}
+	slices.Sort(prn)
+	slices.Sort(scheduled)
+	return slices.Compact(prn), slices.Compact(scheduled)
+}
+
+// nitrateIssue is the PDE5 contraindication for a nitrate. Both kinds are
+// absolute contraindications; an as-needed nitrate gets guidance on the
+// interval since its last use and on whether it can be stopped, and a
+// scheduled one the firm do-not-combine text.
+func nitrateIssue(prn bool, l localizer, meds ...string) Issue {
+	if prn {
+		return newIssue("CI_NITRATE_PRN_PDE5", SeverityDanger, l.issue("CI_NITRATE_PRN_PDE5", nil), meds...)
+	}
+	return newIssue("CI_NITRATE_PDE5", SeverityDanger, l.issue("CI_NITRATE_PDE5", nil), meds...)
+}
@@ -0,0 +1,359 @@
+package analysis
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Visit note formats.
+const (
+	NoteMarkdown = "markdown"
+	NoteText     = "text"
+)
+
+// ErrUnknownNoteFormat is returned by VisitNote for a format other than
+// NoteMarkdown or NoteText.
+var ErrUnknownNoteFormat = errors.New("note format must be markdown or text")
+
+var (
+	//go:embed note/soap.md.tmpl
+	noteMarkdownTemplate string
+	//go:embed note/soap.txt.tmpl
+	noteTextTemplate string
+)
+
+var noteTemplates = map[string]*template.Template{
+	NoteMarkdown: template.Must(template.New("soap.md").Option("missingkey=error").Parse(noteMarkdownTemplate)),
+	NoteText: template.Must(template.New("soap.txt").Option("missingkey=error").Funcs(template.FuncMap{
+		"upper": strings.ToUpper,
+	}).Parse(noteTextTemplate)),
+}
+
+// NoteHeaders are the section headings of a visit note, so a deployment can
+// match the wording of its EHR.
+type NoteHeaders struct {
+	Subjective string `json:"subjective"`
+	Objective  string `json:"objective"`
+	Assessment string `json:"assessment"`
+	Plan       string `json:"plan"`
+}
+
+// DefaultNoteHeaders are the SOAP headings notes use unless the deployment
+// sets its own.
+var DefaultNoteHeaders = NoteHeaders{Subjective: "Subjective", Objective: "Objective", Assessment: "Assessment", Plan: "Plan"}
+
+// withDefaults fills blank headings from DefaultNoteHeaders and rejects
+// headings that span lines.
+func (h NoteHeaders) withDefaults() (NoteHeaders, error) {
+	for _, f := range []struct {
+		name string
+		v    *string
+		def  string
+	}{
+		{"subjective", &h.Subjective, DefaultNoteHeaders.Subjective},
+		{"objective", &h.Objective, DefaultNoteHeaders.Objective},
+		{"assessment", &h.Assessment, DefaultNoteHeaders.Assessment},
+		{"plan", &h.Plan, DefaultNoteHeaders.Plan},
+	} {
+		*f.v = strings.TrimSpace(*f.v)
+		switch {
+		case *f.v == "":
+			*f.v = f.def
+		case strings.ContainsAny(*f.v, "\r\n"):
+			return NoteHeaders{}, fmt.Errorf("note header %s must be a single line", f.name)
+		}
+	}
+	return h, nil
+}
+
+// WithNoteHeaders sets the visit note headings; blank ones keep the default.
+// It panics on headings SetNoteHeaders rejects.
+func WithNoteHeaders(h NoteHeaders) Option {
+	return func(a *Analyzer) {
+		h, err := h.withDefaults()
+		if err != nil {
+			panic("analysis: " + err.Error())
+		}
+		a.s.noteHeaders = h
+	}
+}
+
+// SetNoteHeaders replaces the visit note headings; blank ones take the
+// default. On error the current headings stay.
+func (a *Analyzer) SetNoteHeaders(h NoteHeaders) error {
+	h, err := h.withDefaults()
+	if err != nil {
+		return err
+	}
+	return a.update(func(s *settings) error {
+		s.noteHeaders = h
+		return nil
+	})
+}
+
+func SetNoteHeaders(h NoteHeaders) error {
+	return defaultAnalyzer.SetNoteHeaders(h)
+}
+
+// VisitNote renders audit auditID as a SOAP visit note in format, markdown or
+// text, for pasting into an EHR. It reads the stored intake and response, so
+// the note names the patient by reference only. Errors are
+// ErrUnknownNoteFormat, audit.ErrNotFound, ErrNoIntake,
+// ErrIntakesUnsupported, or ErrResponsesUnsupported.
+func (a *Analyzer) VisitNote(ctx context.Context, auditID, format string) (string, error) {
+	tmpl, ok := noteTemplates[format]
+	if !ok {
+		return "", ErrUnknownNoteFormat
+	}
+	resp, err := a.AuditResponse(ctx, auditID)
+	if err != nil {
+		return "", err
+	}
+	stored, err := a.storedIntake(ctx, auditID)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, newNoteData(a.settings().noteHeaders, auditID, stored, resp)); err != nil {
+		return "", fmt.Errorf("render visit note: %w", err)
+	}
+	return b.String(), nil
+}
+
+func VisitNote(ctx context.Context, auditID, format string) (string, error) {
+	return defaultAnalyzer.VisitNote(ctx, auditID, format)
+}
+
+// noteData is what the note templates render. The Go side does the
+// formatting so the templates only lay out sections.
+type noteData struct {
+	Headers     NoteHeaders
+	AuditID     string
+	At          string
+	PatientRef  string
+	Subjective  []noteLine
+	Objective   []noteLine
+	Risk        string
+	Issues      []string
+	Plans       []notePlan
+	Decision    string
+	Disclaimers []string
+}
+
+type noteLine struct {
+	Label, Value string
+}
+
+type notePlan struct {
+	Complaint    string
+	Summary      string
+	Rationale    string
+	Monitoring   []string
+	FollowUp     string
+	Alternatives string
+}
+
+func newNoteData(headers NoteHeaders, auditID string, stored StoredIntake, resp Response) noteData {
+	in := stored.Intake
+	d := noteData{
+		Headers:     headers,
+		AuditID:     auditID,
+		At:          resp.AuditAt,
+		PatientRef:  stored.PatientRef,
+		Disclaimers: resp.Disclaimers,
+	}
+
+	line := func(lines *[]noteLine, label, value string) {
+		if value != "" {
+			*lines = append(*lines, noteLine{label, value})
+		}
+	}
+	complaint := strings.Join(intakeComplaints(in), ", ")
+	var detail []string
+	if in.ComplaintSeverity != "" {
+		detail = append(detail, in.ComplaintSeverity)
+	}
+	if in.ComplaintDurationWeeks > 0 {
+		detail = append(detail, plural(in.ComplaintDurationWeeks, "week"))
+	}
+	if len(detail) > 0 {
+		complaint += " (" + strings.Join(detail, ", ") + ")"
+	}
+	line(&d.Subjective, "Chief complaint", complaint)
+	line(&d.Subjective, "History", listOrNone(append(append([]string{}, in.Conditions...), in.ConditionCodes...), in.ConfirmedNoConditions))
+	// Analyses from before MedicationsAssessed list the intake medications
+	// without flags.
+	var meds []string
+	if assessed := resp.MedicationsAssessed; len(assessed) > 0 {
+		for _, am := range assessed {
+			m := am.Input
+			med := joinNonEmpty(" ", m.Name, m.Dosage, m.Frequency)
+			if len(am.Flags) > 0 {
+				med += " (" + strings.Join(am.Flags, ", ") + ")"
+			}
+			meds = append(meds, med)
+		}
+	} else {
+		for _, m := range in.Medications {
+			meds = append(meds, joinNonEmpty(" ", m.Name, m.Dosage, m.Frequency))
+		}
+	}
+	line(&d.Subjective, "Medications", listOrNone(meds, in.ConfirmedNoMedications))
+	allergies := append([]string{}, in.Allergies...)
+	for _, al := range in.AllergyDetails {
+		if al.Severity != "" {
+			allergies = append(allergies, al.Substance+" ("+al.Severity+")")
+		} else {
+			allergies = append(allergies, al.Substance)
+		}
+	}
+	line(&d.Subjective, "Allergies", listOrNone(allergies, in.ConfirmedNoAllergies))
+	var prior []string
+	for _, p := range in.PriorTreatments {
+		prior = append(prior, joinNonEmpty(" ", p.Medication, p.MaxDose)+": "+p.Outcome)
+	}
+	line(&d.Subjective, "Prior treatments", strings.Join(prior, "; "))
+	line(&d.Subjective, "Family history", strings.Join(in.FamilyHistory, ", "))
+	line(&d.Subjective, "Social", noteSocial(in))
+
+	if in.Age > 0 {
+		line(&d.Objective, "Age", strconv.Itoa(in.Age))
+	}
+	line(&d.Objective, "BP", in.BP)
+	line(&d.Objective, "Weight", measure(in.WeightKg, "kg"))
+	line(&d.Objective, "Height", measure(in.HeightCm, "cm"))
+	if resp.ComputedBMI > 0 {
+		line(&d.Objective, "BMI", strconv.FormatFloat(resp.ComputedBMI, 'f', 1, 64))
+	}
+	line(&d.Objective, "Waist", measure(in.WaistCircumferenceCm, "cm"))
+	if l := in.Labs; l != nil {
+		line(&d.Objective, "Labs", joinNonEmpty(", ",
+			prefixed("triglycerides ", measure(l.TriglyceridesMgDl, "mg/dL")),
+			prefixed("HDL ", measure(l.HDLMgDl, "mg/dL")),
+			prefixed("A1c ", measure(l.A1cPercent, "%"))))
+	}
+
+	d.Risk = fmt.Sprintf("%s (score %d)", resp.RiskLevel, resp.RiskScore)
+	for _, is := range resp.FlaggedIssues {
+		d.Issues = append(d.Issues, fmt.Sprintf("[%s] %s", is.Severity, is.Description))
+	}
+
+	plans := resp.Plans
+	if len(plans) == 0 {
+		plans = []ComplaintPlan{{Complaint: in.Complaint, Plan: resp.RecommendedPlan, Alternatives: resp.Alternatives}}
+	}
+	for _, cp := range plans {
+		p := cp.Plan
+		np := notePlan{
+			Complaint:  cp.Complaint,
+			Summary:    joinNonEmpty(", ", joinNonEmpty(" ", p.Medication, p.Dosage), p.Frequency, prefixed("for ", p.Duration)),
+			Rationale:  p.Rationale,
+			Monitoring: p.Monitoring,
+		}
+		if p.Review {
+			np.Summary = "Review current " + np.Summary
+		}
+		if f := p.FollowUp; f != nil {
+			np.FollowUp = "in " + plural(f.IntervalDays, "day")
+			if f.Instructions != "" {
+				np.FollowUp += ": " + f.Instructions
+			}
+		}
+		var alts []string
+		for _, alt := range cp.Alternatives {
+			alts = append(alts, alt.Medication)
+		}
+		np.Alternatives = strings.Join(alts, ", ")
+		d.Plans = append(d.Plans, np)
+	}
+	if dec := currentDecision(resp.Decisions); dec != nil {
+		d.Decision = dec.Decision
+		if dec.Reason != "" {
+			d.Decision += ": " + dec.Reason
+		}
+	}
+	return d
+}
+
+// currentDecision returns the highest revision, or nil when there is none.
+func currentDecision(decisions []Decision) *Decision {
+	var cur *Decision
+	for i := range decisions {
+		if cur == nil || decisions[i].Revision > cur.Revision {
+			cur = &decisions[i]
+		}
+	}
+	return cur
+}
+
+func noteSocial(in Intake) string {
+	var parts []string
+	if in.Smoking != "" {
+		s := "smoking " + in.Smoking
+		if in.SmokingPackYears > 0 {
+			s += ", " + measure(in.SmokingPackYears, "pack-years")
+		}
+		if q := in.SmokingQuitYearsAgo; q != nil {
+			s += ", quit " + measure(*q, "years") + " ago"
+		}
+		parts = append(parts, s)
+	}
+	if in.AlcoholDrinksPerWeek != nil {
+		parts = append(parts, "alcohol "+measure(*in.AlcoholDrinksPerWeek, "drinks/week"))
+	} else if in.Alcohol != "" {
+		parts = append(parts, "alcohol "+in.Alcohol)
+	}
+	if in.Exercise != "" {
+		parts = append(parts, "exercise "+in.Exercise)
+	}
+	if in.SleepHours > 0 {
+		parts = append(parts, "sleep "+measure(in.SleepHours, "h/night"))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// listOrNone joins a history list, "none" when the clinician confirmed it is
+// empty, and "" (omitted) when it was simply not recorded.
+func listOrNone(items []string, confirmedNone bool) string {
+	if len(items) == 0 && confirmedNone {
+		return "none"
+	}
+	return strings.Join(items, ", ")
+}
+
+// measure formats a positive value with its unit, and zero as "".
+func measure(v float64, unit string) string {
+	if v <= 0 {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64) + " " + unit
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return strconv.Itoa(n) + " " + unit + "s"
+}
+
+func prefixed(prefix, v string) string {
+	if v == "" {
+		return ""
+	}
+	return prefix + v
+}
+
+func joinNonEmpty(sep string, parts ...string) string {
+	var out []string
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return strings.Join(out, sep)
+}
@@ -0,0 +1,85 @@
+package analysis
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+// TestVisitNoteGolden renders the ED golden case as a note in each format
+// and compares it with testdata/golden/ed-cardiac.note.<ext>. Run with
+// UPDATE_GOLDEN=1 to accept an intentional change.
+func TestVisitNoteGolden(t *testing.T) {
+	raw, err := os.ReadFile(filepath.Join("testdata", "golden", "ed-cardiac.intake.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var in Intake
+	if err := json.Unmarshal(raw, &in); err != nil {
+		t.Fatal(err)
+	}
+	in.AllergyDetails = []Allergy{{Substance: "penicillin", Severity: "severe"}}
+	in.ComplaintDurationWeeks = 12
+	a := New(WithClock(fixedClock), WithIDGenerator(fixedID), WithPseudonymizer(LegacyPseudonymizer{}))
+	resp := a.AnalyzeWithOptions(in, Options{})
+	if resp.AuditID == "" {
+		t.Fatalf("not audited: %v", resp.ValidationErrors)
+	}
+	for format, ext := range map[string]string{NoteMarkdown: "md", NoteText: "txt"} {
+		t.Run(format, func(t *testing.T) {
+			note, err := a.VisitNote(t.Context(), resp.AuditID, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(note, in.PatientName) {
+				t.Fatalf("note names the patient:\n%s", note)
+			}
+			path := filepath.Join("testdata", "golden", "ed-cardiac.note."+ext)
+			if os.Getenv("UPDATE_GOLDEN") == "1" {
+				if err := os.WriteFile(path, []byte(note), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if note != string(want) {
+				t.Errorf("note differs from %s; rerun with UPDATE_GOLDEN=1 if intended\ngot:\n%s", path, note)
+			}
+		})
+	}
+}
+
+func TestVisitNote_Headers(t *testing.T) {
+	a := New()
+	if err := a.SetNoteHeaders(NoteHeaders{Subjective: "History", Plan: "Orders"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SetNoteHeaders(NoteHeaders{Objective: "Exam\nFindings"}); err == nil {
+		t.Fatal("multi-line header accepted")
+	}
+	resp := a.Analyze(Intake{PatientName: "Note Headers", Age: 40, WeightKg: 70, HeightCm: 170, BP: "120/80", Complaint: "hair loss"})
+	note, err := a.VisitNote(t.Context(), resp.AuditID, NoteText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"\nHISTORY\n", "\nOBJECTIVE\n", "\nASSESSMENT\n", "\nORDERS\n"} {
+		if !strings.Contains(note, want) {
+			t.Errorf("note lacks %q:\n%s", want, note)
+		}
+	}
+
+	if _, err := a.VisitNote(t.Context(), resp.AuditID, "html"); !errors.Is(err, ErrUnknownNoteFormat) {
+		t.Errorf("html format: err %v", err)
+	}
+	if _, err := a.VisitNote(t.Context(), "missing", NoteMarkdown); !errors.Is(err, audit.ErrNotFound) {
+		t.Errorf("missing audit: err %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package analysis
+
+import (
+	"context"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/metrics"
+)
+
+var notifications = metrics.NewCounter("notifications_total", "Danger notifications by result (ok, retry, failed).", "result")
+
+// Event kinds.
+const (
+	// EventDanger is an audited analysis that flagged a danger issue.
+	EventDanger = "danger"
+	// EventReminderOverdue is a follow-up reminder that came due unmet; it
+	// sets ReminderID and DueAt and leaves the risk fields empty.
+	EventReminderOverdue = "reminder_overdue"
+	// EventSLOBreach is an operational alert, not a clinical one: analysis
+	// latency stayed over the SLO. It sets SLO and leaves the audit and risk
+	// fields empty.
+	EventSLOBreach = "slo_breach"
+)
+
+// Event is what a Notifier is told about an audited analysis that flagged a
+// danger issue, or whose follow-up reminder is overdue, and about latency
+// SLO breaches. It identifies an analysis by audit ID only and carries no
+// patient name or reference.
+type Event struct {
+	Kind           string    `json:"kind"`
+	AuditID        string    `json:"auditId"`
+	At             time.Time `json:"at"`
+	RiskLevel      RiskLevel `json:"riskLevel"`
+	RiskScore      int       `json:"riskScore"`
+	DangerCodes    []string  `json:"dangerCodes"`
+	RulesetVersion string    `json:"rulesetVersion"`
+	// Org is the org the analysis was audited under; empty when the
+	// deployment is not partitioned.
+	Org string `json:"org,omitempty"`
+	// ReminderID and DueAt identify the overdue reminder of an
+	// EventReminderOverdue.
+	ReminderID string    `json:"reminderId,omitempty"`
+	DueAt      time.Time `json:"dueAt,omitzero"`
+	// SLO describes the breach of an EventSLOBreach.
+	SLO *SLOBreach `json:"slo,omitempty"`
+}
+
+// Notifier delivers Events, e.g. by email. Notify runs off the request path
+// and may block; it is retried when it fails.
+type Notifier interface {
+	Notify(ctx context.Context, e Event) error
+}
+
+const (
+	// notifyAttempts bounds the deliveries tried per notifier and event.
+	notifyAttempts = 3
+	// notifyTimeout bounds each delivery.
+	notifyTimeout = 10 * time.Second
+	// defaultNotifyBackoff is the delay before the first retry; it doubles
+	// per attempt.
+	defaultNotifyBackoff = time.Second
+)
+
+// WithNotifier adds n to the notifiers told about danger flags; nil is
+// ignored.
+func WithNotifier(n Notifier) Option {
+	return func(a *Analyzer) {
+		if n != nil {
+			a.s.notifiers = append(a.s.notifiers, n)
+		}
+	}
+}
+
+// AddNotifier adds n to the notifiers told about every audited analysis that
+// flags a danger issue, and about overdue reminders once
+// SetReminderNotifications is on. Each active notifier receives every event, off the
+// request path; failures are retried, then logged and counted in
+// notifications_total, and never reach the caller. nil is ignored.
+func (a *Analyzer) AddNotifier(n Notifier) {
+	if n == nil {
+		return
+	}
+	_ = a.update(func(s *settings) error {
+		s.notifiers = append(slices.Clip(s.notifiers), n)
+		return nil
+	})
+}
+
+func AddNotifier(n Notifier) {
+	defaultAnalyzer.AddNotifier(n)
+}
+
+// WaitNotifications blocks until in-flight notifications have been delivered
+// or given up on.
+func (a *Analyzer) WaitNotifications() {
+	a.notifyWG.Wait()
+}
+
+func WaitNotifications() {
+	defaultAnalyzer.WaitNotifications()
+}
+
+// dangerCodes returns the codes of the danger issues in issues, in order,
+// each once.
+func dangerCodes(issues []Issue) []string {
+	var out []string
+	for _, is := range issues {
+		if is.Severity == SeverityDanger && !slices.Contains(out, is.Code) {
+			out = append(out, is.Code)
+		}
+	}
+	return out
+}
+
+// notifier returns the hook that tells s's notifiers about resp once its
+// audit is written, or nil when there is nothing to tell.
+func (a *Analyzer) notifier(s settings, resp Response) func(ctx context.Context, sum audit.Summary) {
+	codes := dangerCodes(resp.FlaggedIssues)
+	if len(s.notifiers) == 0 || len(codes) == 0 {
+		return nil
+	}
+	return func(ctx context.Context, sum audit.Summary) {
+		e := Event{
+			Kind:           EventDanger,
+			AuditID:        sum.AuditID,
+			RiskLevel:      resp.RiskLevel,
+			RiskScore:      resp.RiskScore,
+			DangerCodes:    codes,
+			RulesetVersion: resp.RulesetVersion,
+			Org:            audit.OrgFrom(ctx),
+		}
+		e.At, _ = time.Parse(time.RFC3339, sum.At)
+		for _, n := range s.notifiers {
+			a.notify(ctx, n, e)
+		}
+	}
+}
+
+// notify delivers e with n in the background, retrying with backoff. The
+// context keeps request values but not its cancellation.
+func (a *Analyzer) notify(ctx context.Context, n Notifier, e Event) {
+	a.notifyWG.Add(1)
+	go func() {
+		defer a.notifyWG.Done()
+		detached := context.WithoutCancel(ctx)
+		backoff := a.notifyBackoff
+		var err error
+		for attempt := 1; attempt <= notifyAttempts; attempt++ {
+			if attempt > 1 {
+				notifications.Inc("retry")
+				time.Sleep(backoff)
+				backoff *= 2
+			}
+			attemptCtx, cancel := context.WithTimeout(detached, notifyTimeout)
+			err = n.Notify(attemptCtx, e)
+			cancel()
+			if err == nil {
+				notifications.Inc("ok")
+				return
+			}
+		}
+		notifications.Inc("failed")
+		log.Printf("notification failed after %d attempts kind=%s audit_id=%s notifier=%T: %v", notifyAttempts, e.Kind, e.AuditID, n, err)
+	}()
+}
@@ -0,0 +1,64 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+type recordingNotifier struct {
+	mu     sync.Mutex
+	fails  int
+	calls  int
+	events []Event
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, e Event) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.calls++
+	if n.calls <= n.fails {
+		return errors.New("smtp: 421 try again later")
+	}
+	n.events = append(n.events, e)
+	return nil
+}
+
+func TestNotifiers(t *testing.T) {
+	flaky := &recordingNotifier{fails: 2}
+	down := &recordingNotifier{fails: notifyAttempts}
+	a := New(WithAuditStore(audit.NewMemoryStore()), WithClock(fixedClock), WithIDGenerator(fixedID), WithNotifier(flaky))
+	a.AddNotifier(down)
+	a.notifyBackoff = 0
+
+	ctx := audit.WithOrg(t.Context(), "clinic-a")
+	danger := nitratePatient(Medication{Name: "Nitroglycerin", Dosage: "0.4mg SL", Frequency: "as needed"})
+	danger.PatientName = "Jane Roe"
+	resp := a.AnalyzeContext(ctx, danger, Options{})
+	a.AnalyzeContext(ctx, danger, Options{DryRun: true})
+	a.AnalyzeContext(ctx, Intake{PatientName: "Low", Age: 30, WeightKg: 70, HeightCm: 175, BP: "118/76", Complaint: "ED"}, Options{})
+	a.WaitNotifications()
+
+	if resp.AuditID == "" {
+		t.Fatalf("notifier failure surfaced to the caller: %+v", resp)
+	}
+	if len(flaky.events) != 1 || flaky.calls != 3 {
+		t.Fatalf("flaky notifier: %d calls, events %+v", flaky.calls, flaky.events)
+	}
+	if down.calls != notifyAttempts || len(down.events) != 0 {
+		t.Fatalf("failing notifier: %d calls", down.calls)
+	}
+	e := flaky.events[0]
+	if e.AuditID != resp.AuditID || e.RiskLevel != resp.RiskLevel || e.Org != "clinic-a" || e.At.IsZero() || !slices.Contains(e.DangerCodes, "CI_NITRATE_PRN_PDE5") {
+		t.Errorf("event = %+v", e)
+	}
+	if body, _ := json.Marshal(e); strings.Contains(string(body), "Jane Roe") {
+		t.Errorf("event carries the patient name: %s", body)
+	}
+}
@@ -0,0 +1,155 @@
+package analysis
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+// OrgConfig is one organization's section of the orgs file: the API keys its
+// callers authenticate with and the settings its analyses override. Nil
+// overrides keep the deployment's; an empty Disclaimers stamps none.
+type OrgConfig struct {
+	APIKeys        []string        `json:"apiKeys"`
+	RiskThresholds *RiskThresholds `json:"riskThresholds,omitempty"`
+	Disclaimers    []string        `json:"disclaimers,omitempty"`
+}
+
+// apiKeyHash indexes API keys so the settings hold no key in clear and a
+// lookup compares digests, not the secret.
+func apiKeyHash(key string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(key))
+}
+
+// validateOrgs reports every problem with orgs: blank org IDs, orgs without
+// an API key, blank or shared keys, and invalid thresholds.
+func validateOrgs(orgs map[string]OrgConfig) []string {
+	var errs []string
+	owner := map[[sha256.Size]byte]string{}
+	for _, id := range slices.Sorted(maps.Keys(orgs)) {
+		oc := orgs[id]
+		if strings.TrimSpace(id) == "" {
+			errs = append(errs, "org ID must not be blank")
+		}
+		if len(oc.APIKeys) == 0 {
+			errs = append(errs, fmt.Sprintf("org %s: at least one API key is required", id))
+		}
+		for i, key := range oc.APIKeys {
+			if strings.TrimSpace(key) == "" {
+				errs = append(errs, fmt.Sprintf("org %s: apiKeys[%d] is blank", id, i))
+				continue
+			}
+			h := apiKeyHash(key)
+			if prev, ok := owner[h]; ok {
+				errs = append(errs, fmt.Sprintf("org %s: apiKeys[%d] is already a key of org %s", id, i, prev))
+			}
+			owner[h] = id
+		}
+		if t := oc.RiskThresholds; t != nil {
+			if err := t.Validate(); err != nil {
+				errs = append(errs, fmt.Sprintf("org %s: %v", id, err))
+			}
+		}
+	}
+	return errs
+}
+
+// SetOrgs partitions the deployment into orgs keyed by org ID. Each caller's
+// org comes from OrgForAPIKey, and analyses and audit reads scoped to it with
+// audit.WithOrg see only its audits and use its overrides. Nil or empty orgs
+// turn partitioning off. On error the current orgs stay.
+func (a *Analyzer) SetOrgs(orgs map[string]OrgConfig) error {
+	if errs := validateOrgs(orgs); len(errs) > 0 {
+		return fmt.Errorf("invalid orgs: %s", strings.Join(errs, "; "))
+	}
+	configs := make(map[string]OrgConfig, len(orgs))
+	keys := map[[sha256.Size]byte]string{}
+	for id, oc := range orgs {
+		for _, key := range oc.APIKeys {
+			keys[apiKeyHash(key)] = id
+		}
+		oc.APIKeys = nil
+		if oc.RiskThresholds != nil {
+			t := *oc.RiskThresholds
+			oc.RiskThresholds = &t
+		}
+		if oc.Disclaimers != nil {
+			oc.Disclaimers = slices.Clone(oc.Disclaimers)
+		}
+		configs[id] = oc
+	}
+	return a.update(func(s *settings) error {
+		s.orgs, s.orgKeys = configs, keys
+		return nil
+	})
+}
+
+func SetOrgs(orgs map[string]OrgConfig) error {
+	return defaultAnalyzer.SetOrgs(orgs)
+}
+
+// LoadOrgsFile reads a JSON object of OrgConfig keyed by org ID and applies
+// it with SetOrgs.
+func (a *Analyzer) LoadOrgsFile(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read orgs: %w", err)
+	}
+	var orgs map[string]OrgConfig
+	if err := json.Unmarshal(raw, &orgs); err != nil {
+		return fmt.Errorf("parse orgs %s: %w", path, err)
+	}
+	return a.SetOrgs(orgs)
+}
+
+func LoadOrgsFile(path string) error {
+	return defaultAnalyzer.LoadOrgsFile(path)
+}
+
+// Orgs returns the configured org IDs, sorted; none when the deployment is
+// not partitioned.
+func (a *Analyzer) Orgs() []string {
+	return slices.Sorted(maps.Keys(a.settings().orgs))
+}
+
+func Orgs() []string {
+	return defaultAnalyzer.Orgs()
+}
+
+// OrgForAPIKey returns the org an API key belongs to.
+func (a *Analyzer) OrgForAPIKey(key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	org, ok := a.settings().orgKeys[apiKeyHash(key)]
+	return org, ok
+}
+
+// settingsFor is the settings with the overrides of the org ctx is scoped
+// to. The prompt quotes the thresholds, so an org with its own gets the
+// prompt rendered with them.
+func (a *Analyzer) settingsFor(ctx context.Context) settings {
+	s := a.settings()
+	oc, ok := s.orgs[audit.OrgFrom(ctx)]
+	if !ok {
+		return s
+	}
+	if oc.Disclaimers != nil {
+		s.disclaimers = oc.Disclaimers
+	}
+	if t := oc.RiskThresholds; t != nil && *t != s.thresholds {
+		s.thresholds = *t
+		if info, err := renderPrompt(s.prompt, s); err == nil {
+			info.Source = s.promptInfo.Source
+			s.promptInfo = info
+		}
+	}
+	return s
+}
@@ -0,0 +1,87 @@
+package analysis
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+func TestOrgs_Overrides(t *testing.T) {
+	a := New(WithAuditStore(audit.NewMemoryStore()))
+	err := a.SetOrgs(map[string]OrgConfig{
+		"clinic-a": {APIKeys: []string{"key-a"}, RiskThresholds: &RiskThresholds{Medium: 4, High: 8, Critical: 12}, Disclaimers: []string{"Clinic A pilot."}},
+		"clinic-b": {APIKeys: []string{"key-b"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := a.Orgs(); !slices.Equal(got, []string{"clinic-a", "clinic-b"}) {
+		t.Fatalf("orgs = %q", got)
+	}
+	if org, ok := a.OrgForAPIKey("key-b"); !ok || org != "clinic-b" {
+		t.Fatalf("key-b belongs to %q (%v)", org, ok)
+	}
+	if _, ok := a.OrgForAPIKey("key-c"); ok {
+		t.Fatal("unknown key resolved to an org")
+	}
+
+	in := Intake{
+		PatientName: "High Risk",
+		Age:         68,
+		WeightKg:    90,
+		HeightCm:    170,
+		BP:          "168/102",
+		Conditions:  []string{"Heart Disease", "Hypertension"},
+		Medications: []Medication{{Name: "Nitroglycerin", Dosage: "0.4mg", Frequency: "PRN"}},
+		Complaint:   "ED",
+	}
+	ctxA := audit.WithOrg(t.Context(), "clinic-a")
+	ctxB := audit.WithOrg(t.Context(), "clinic-b")
+	respA := a.AnalyzeContext(ctxA, in, Options{})
+	if respA.RiskLevel != "CRITICAL" || !slices.Equal(respA.Disclaimers, []string{"Clinic A pilot."}) {
+		t.Fatalf("clinic-a: %s, disclaimers %q", respA.RiskLevel, respA.Disclaimers)
+	}
+	respB := a.AnalyzeContext(ctxB, in, Options{})
+	if respB.RiskLevel != "HIGH" || !slices.Equal(respB.Disclaimers, DefaultDisclaimers) {
+		t.Fatalf("clinic-b keeps the deployment settings: %s, disclaimers %q", respB.RiskLevel, respB.Disclaimers)
+	}
+
+	if sums := a.LatestAuditsContext(ctxA, 10); len(sums) != 1 || sums[0].AuditID != respA.AuditID {
+		t.Fatalf("clinic-a audits = %+v", sums)
+	}
+	if _, err := a.AuditRecord(ctxB, respA.AuditID); !errors.Is(err, audit.ErrNotFound) {
+		t.Fatalf("clinic-b reading a clinic-a audit: %v, want audit.ErrNotFound", err)
+	}
+
+	if err := a.SetOrgs(map[string]OrgConfig{"x": {APIKeys: []string{"shared"}}, "y": {APIKeys: []string{"shared"}}}); err == nil || !strings.Contains(err.Error(), "already a key") {
+		t.Fatalf("shared key: %v", err)
+	}
+	if len(a.Orgs()) != 2 {
+		t.Fatal("invalid orgs should not replace the active ones")
+	}
+}
+
+func TestLoadOrgsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "orgs.json")
+	if err := os.WriteFile(path, []byte(`{"clinic-a": {"apiKeys": ["k"], "riskThresholds": {"medium": 8, "high": 4}}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	a := New()
+	if err := a.LoadOrgsFile(path); err == nil || !strings.Contains(err.Error(), "org clinic-a") {
+		t.Fatalf("invalid thresholds: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(`{"clinic-a": {"apiKeys": ["k"]}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.LoadOrgsFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if org, ok := a.OrgForAPIKey("k"); !ok || org != "clinic-a" {
+		t.Fatalf("k belongs to %q (%v)", org, ok)
+	}
+}
@@ -0,0 +1,145 @@
+package analysis
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"text/template"
+)
+
+//go:embed prompt/system.tmpl
+var embeddedPrompt string
+
+// Clinical cut points shared by the rule engine and the prompt template so the
+// two cannot drift apart.
+const (
+	bmiObesity              = 30
+	bmiElevated             = 27
+	bpUncontrolledSystolic  = 160
+	bpUncontrolledDiastolic = 100
+	bpElevatedSystolic      = 140
+	bpElevatedDiastolic     = 90
+	pde5DoseCapMg           = 20
+)
+
+// promptData is the set of named placeholders available to the prompt template.
+type promptData struct {
+	Thresholds              RiskThresholds
+	BMIObesity              int
+	BMIElevated             int
+	BPUncontrolledSystolic  int
+	BPUncontrolledDiastolic int
+	PDE5DoseCapMg           float64
+}
+
+// PromptInfo describes the active system prompt.
+type PromptInfo struct {
+	Version string `json:"version"`
+	Source  string `json:"source"`
+	Prompt  string `json:"prompt"`
+}
+
+// SystemPrompt returns the clinical guardrail prompt LLM providers must send.
+func (a *Analyzer) SystemPrompt() string {
+	return a.ActivePrompt().Prompt
+}
+
+func SystemPrompt() string {
+	return defaultAnalyzer.SystemPrompt()
+}
+
+// PromptVersion is a short hash of the rendered system prompt, recorded with
+// every response and audit entry.
+func (a *Analyzer) PromptVersion() string {
+	return a.ActivePrompt().Version
+}
+
+func PromptVersion() string {
+	return defaultAnalyzer.PromptVersion()
+}
+
+// ActivePrompt returns the rendered prompt with its version and source.
+func (a *Analyzer) ActivePrompt() PromptInfo {
+	return a.settings().promptInfo
+}
+
+func ActivePrompt() PromptInfo {
+	return defaultAnalyzer.ActivePrompt()
+}
+
+// SetSystemPromptTemplate parses and renders a prompt template; on error the
+// current prompt stays active. source is reported by ActivePrompt.
+func (a *Analyzer) SetSystemPromptTemplate(text, source string) error {
+	return a.update(func(s *settings) error {
+		return s.setPrompt(text, source)
+	})
+}
+
+func SetSystemPromptTemplate(text, source string) error {
+	return defaultAnalyzer.SetSystemPromptTemplate(text, source)
+}
+
+// LoadSystemPromptFile replaces the embedded template with the one at path.
+func (a *Analyzer) LoadSystemPromptFile(path string) error {
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read system prompt: %w", err)
+	}
+	return a.SetSystemPromptTemplate(string(text), path)
+}
+
+func LoadSystemPromptFile(path string) error {
+	return defaultAnalyzer.LoadSystemPromptFile(path)
+}
+
+func (s *settings) setPrompt(text, source string) error {
+	tmpl, err := template.New("system").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return fmt.Errorf("parse system prompt: %w", err)
+	}
+	info, err := renderPrompt(tmpl, *s)
+	if err != nil {
+		return err
+	}
+	info.Source = source
+	s.prompt = tmpl
+	s.promptInfo = info
+	s.promptText = text
+	return nil
+}
+
+// renderPrompt fills tmpl from the thresholds and dose caps of s, so the
+// prompt quotes the cut points the engine applies.
+func renderPrompt(tmpl *template.Template, s settings) (PromptInfo, error) {
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, promptData{
+		Thresholds:              s.thresholds,
+		BMIObesity:              bmiObesity,
+		BMIElevated:             bmiElevated,
+		BPUncontrolledSystolic:  bpUncontrolledSystolic,
+		BPUncontrolledDiastolic: bpUncontrolledDiastolic,
+		PDE5DoseCapMg:           promptDoseCapMg(s.doseCaps),
+	})
+	if err != nil {
+		return PromptInfo{}, fmt.Errorf("render system prompt: %w", err)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return PromptInfo{
+		Version: hex.EncodeToString(sum[:])[:12],
+		Prompt:  buf.String(),
+	}, nil
+}
+
+// promptDoseCapMg is the tadalafil single-dose cap in caps, which the prompt
+// quotes, or pde5DoseCapMg when the ruleset caps no tadalafil dose.
+func promptDoseCapMg(caps []DoseCap) float64 {
+	for _, c := range caps {
+		if normalizeName(c.Medication) == "tadalafil" {
+			return c.MaxMg
+		}
+	}
+	return pde5DoseCapMg
+}
@@ -0,0 +1,100 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func restorePrompt(t *testing.T) {
+	t.Helper()
+	t.Cleanup(func() {
+		_ = SetRiskThresholds(DefaultRiskThresholds)
+		if err := SetSystemPromptTemplate(embeddedPrompt, "embedded"); err != nil {
+			t.Fatalf("restore prompt: %v", err)
+		}
+	})
+}
+
+func TestSystemPrompt_RendersRuleValues(t *testing.T) {
+	prompt := SystemPrompt()
+	for _, want := range []string{"warn >20mg tadalafil", "(>160/100)", "MEDIUM >= 4, HIGH >= 8."} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("expected prompt to contain %q:\n%s", want, prompt)
+		}
+	}
+	if strings.Contains(prompt, "{{") {
+		t.Fatalf("unrendered placeholder in prompt:\n%s", prompt)
+	}
+}
+
+func TestSystemPrompt_TracksRiskThresholds(t *testing.T) {
+	restorePrompt(t)
+	before := PromptVersion()
+	if err := SetRiskThresholds(RiskThresholds{Medium: 5, High: 9, Critical: 12}); err != nil {
+		t.Fatalf("set thresholds: %v", err)
+	}
+	if !strings.Contains(SystemPrompt(), "MEDIUM >= 5, HIGH >= 9, CRITICAL >= 12.") {
+		t.Fatalf("prompt did not pick up new thresholds:\n%s", SystemPrompt())
+	}
+	if PromptVersion() == before {
+		t.Fatalf("expected prompt version to change with thresholds")
+	}
+}
+
+func TestLoadSystemPromptFile(t *testing.T) {
+	restorePrompt(t)
+	path := filepath.Join(t.TempDir(), "prompt.tmpl")
+	if err := os.WriteFile(path, []byte("Cap {{.PDE5DoseCapMg}}mg, high {{.Thresholds.High}}."), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadSystemPromptFile(path); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	info := ActivePrompt()
+	if info.Prompt != "Cap 20mg, high 8." || info.Source != path {
+		t.Fatalf("unexpected active prompt: %+v", info)
+	}
+
+	resp := Analyze(llmIntake)
+	if resp.PromptVersion != info.Version {
+		t.Fatalf("expected response promptVersion %s, got %s", info.Version, resp.PromptVersion)
+	}
+	audits := LatestAudits(1)
+	if len(audits) != 1 || audits[0].PromptVersion != info.Version {
+		t.Fatalf("expected audit to record prompt version %s, got %+v", info.Version, audits)
+	}
+}
+
+func TestSetSystemPromptTemplate_RejectsUnknownPlaceholder(t *testing.T) {
+	restorePrompt(t)
+	before := ActivePrompt()
+	if err := SetSystemPromptTemplate("{{.NoSuchField}}", "test"); err == nil {
+		t.Fatalf("expected unknown placeholder to be rejected")
+	}
+	if ActivePrompt() != before {
+		t.Fatalf("failed template must not replace the active prompt")
+	}
+}
+
+func TestSystemPrompt_TracksDoseCaps(t *testing.T) {
+	a := New()
+	before := a.ActivePrompt()
+	rules := a.Rules().Ruleset
+	for i := range rules.DoseCaps {
+		if rules.DoseCaps[i].Medication == "tadalafil" {
+			rules.DoseCaps[i].MaxMg = 10
+		}
+	}
+	if err := a.SetRuleset(rules, "test"); err != nil {
+		t.Fatalf("set ruleset: %v", err)
+	}
+	after := a.ActivePrompt()
+	if !strings.Contains(after.Prompt, "warn >10mg tadalafil") {
+		t.Fatalf("prompt did not pick up the new dose cap:\n%s", after.Prompt)
+	}
+	if after.Version == before.Version || after.Source != before.Source {
+		t.Fatalf("expected a new prompt version from the same source, got %+v", after)
+	}
+}
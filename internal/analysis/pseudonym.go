@@ -0,0 +1,102 @@
+package analysis
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Pseudonymizer derives the patient reference stored on audit entries and
+// written to logs in place of the name. Implementations must be safe for
+// concurrent use.
+type Pseudonymizer interface {
+	PatientRef(name string) string
+}
+
+// minPseudonymKeyLen is the shortest HMAC key accepted.
+const minPseudonymKeyLen = 16
+
+// HMACPseudonymizer maps the trimmed, lowercased name to a truncated
+// HMAC-SHA256 token, so the same patient always gets the same reference and
+// audits can be grouped per patient without storing names.
+type HMACPseudonymizer struct {
+	key []byte
+}
+
+// NewHMACPseudonymizer returns a pseudonymizer keyed by key (at least 16 bytes).
+func NewHMACPseudonymizer(key []byte) (*HMACPseudonymizer, error) {
+	if len(key) < minPseudonymKeyLen {
+		return nil, fmt.Errorf("pseudonym key must be at least %d bytes, got %d", minPseudonymKeyLen, len(key))
+	}
+	return &HMACPseudonymizer{key: append([]byte(nil), key...)}, nil
+}
+
+func (p *HMACPseudonymizer) PatientRef(name string) string {
+	norm := strings.ToLower(strings.TrimSpace(name))
+	if norm == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(norm))
+	return "p_" + hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// LegacyPseudonymizer keeps the original first-letter redaction ("J***").
+// Names of two characters or fewer are returned unchanged.
+type LegacyPseudonymizer struct{}
+
+func (LegacyPseudonymizer) PatientRef(name string) string {
+	ref := []rune(strings.TrimSpace(name))
+	if len(ref) > 2 {
+		return string(ref[:1]) + "***"
+	}
+	return string(ref)
+}
+
+// ephemeralPseudonymizer is the default when no key is configured: references
+// are stable for the life of the process only.
+func ephemeralPseudonymizer() Pseudonymizer {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("analysis: pseudonym key: %v", err))
+	}
+	p, _ := NewHMACPseudonymizer(key)
+	return p
+}
+
+// WithPseudonymizer sets how patient references are derived; nil keeps the
+// per-process HMAC default.
+func WithPseudonymizer(p Pseudonymizer) Option {
+	return func(a *Analyzer) {
+		if p != nil {
+			a.s.pseudonymizer = p
+		}
+	}
+}
+
+// SetPseudonymizer replaces the patient reference strategy; nil is ignored.
+func (a *Analyzer) SetPseudonymizer(p Pseudonymizer) {
+	if p == nil {
+		return
+	}
+	_ = a.update(func(s *settings) error {
+		s.pseudonymizer = p
+		return nil
+	})
+}
+
+func SetPseudonymizer(p Pseudonymizer) {
+	defaultAnalyzer.SetPseudonymizer(p)
+}
+
+// PatientRef returns the reference recorded for name instead of the name itself.
+func (a *Analyzer) PatientRef(name string) string {
+	return a.settings().pseudonymizer.PatientRef(name)
+}
+
+func PatientRef(name string) string {
+	return defaultAnalyzer.PatientRef(name)
+}
@@ -0,0 +1,55 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+func TestHMACPseudonymizer_StableAndKeyed(t *testing.T) {
+	p, err := NewHMACPseudonymizer([]byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := p.PatientRef("John Smith")
+	if ref != p.PatientRef("  john SMITH ") {
+		t.Fatalf("expected normalization to yield the same reference")
+	}
+	if !strings.HasPrefix(ref, "p_") || len(ref) != 18 || strings.Contains(strings.ToLower(ref), "john") {
+		t.Fatalf("unexpected reference %q", ref)
+	}
+	if ref == p.PatientRef("John Smyth") {
+		t.Fatalf("different names should not collide")
+	}
+	other, _ := NewHMACPseudonymizer([]byte("fedcba9876543210"))
+	if other.PatientRef("John Smith") == ref {
+		t.Fatalf("references must depend on the key")
+	}
+	if _, err := NewHMACPseudonymizer([]byte("short")); err == nil {
+		t.Fatalf("expected short key to be rejected")
+	}
+}
+
+func TestLegacyPseudonymizer_MultibyteNames(t *testing.T) {
+	cases := map[string]string{"Élodie": "É***", "张伟明": "张***", "Al": "Al", " Jane ": "J***"}
+	for name, want := range cases {
+		got := LegacyPseudonymizer{}.PatientRef(name)
+		if got != want || !utf8.ValidString(got) {
+			t.Fatalf("%q: expected %q, got %q", name, want, got)
+		}
+	}
+}
+
+func TestAnalyzer_RecordsPseudonymizedRef(t *testing.T) {
+	p, _ := NewHMACPseudonymizer([]byte("0123456789abcdef"))
+	store := audit.NewMemoryStore()
+	a := New(WithAuditStore(store), WithPseudonymizer(p))
+	a.Analyze(llmIntake)
+
+	got, _ := store.Latest(t.Context(), 1)
+	if len(got) != 1 || got[0].PatientRef != p.PatientRef(llmIntake.PatientName) {
+		t.Fatalf("expected HMAC patient ref, got %+v", got)
+	}
+}
@@ -0,0 +1,113 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+// ErrRangeUnsupported is returned when the audit store cannot list audits by
+// time.
+var ErrRangeUnsupported = errors.New("audit store does not support listing audits by time")
+
+// Reanalyze replays the intake stored with auditID under the current rules as
+// a dry run and compares the result with the response stored at the time.
+// The audit itself is never modified; when the store supports it, a
+// re-analysis record referencing auditID is kept. Errors are
+// audit.ErrNotFound, ErrNoIntake, ErrIntakesUnsupported, or
+// ErrResponsesUnsupported.
+func (a *Analyzer) Reanalyze(ctx context.Context, auditID string, opts Options) (Reanalysis, error) {
+	stored, err := a.AuditResponse(ctx, auditID)
+	if err != nil {
+		return Reanalysis{}, err
+	}
+	base, err := a.storedIntake(ctx, auditID)
+	if err != nil {
+		return Reanalysis{}, err
+	}
+	// As in WhatIf, the reference stands in for the name.
+	in := base.Intake
+	in.PatientName = base.PatientRef
+	opts.patientRef = base.PatientRef
+	opts.DryRun = true
+	resp := a.AnalyzeContext(ctx, in, opts)
+
+	out := Reanalysis{
+		AuditID:              auditID,
+		RulesetVersionBefore: stored.RulesetVersion,
+		RulesetVersionAfter:  resp.RulesetVersion,
+		Response:             &resp,
+	}
+	if len(resp.ValidationErrors) > 0 {
+		out.Changed = true
+		out.Error = "stored intake no longer validates: " + strings.Join(resp.ValidationErrors, "; ")
+	} else {
+		// The replay's intake is the stored one, so there is no BP delta.
+		diff := diffAnalyses(stored, resp, nil, nil)
+		out.Diff = &diff
+		out.Changed = diff.RiskScoreDelta != 0 || diff.RiskLevelBefore != diff.RiskLevelAfter ||
+			len(diff.IssuesAdded) > 0 || len(diff.IssuesRemoved) > 0 || len(diff.IssuesChanged) > 0 || diff.PlanChanged
+	}
+
+	if store, ok := a.settings().store.(audit.ReanalysisStore); ok {
+		rec := audit.Reanalysis{
+			ID:                   a.ids.NewID(),
+			AuditID:              auditID,
+			RulesetVersionBefore: stored.RulesetVersion,
+			RulesetVersionAfter:  resp.RulesetVersion,
+			RiskLevelBefore:      string(stored.RiskLevel),
+			RiskLevelAfter:       string(resp.RiskLevel),
+			RiskScoreBefore:      stored.RiskScore,
+			RiskScoreAfter:       resp.RiskScore,
+			Changed:              out.Changed,
+			At:                   a.now().UTC(),
+		}
+		if err := store.InsertReanalysis(ctx, rec); err != nil {
+			return Reanalysis{}, err
+		}
+		out.ID, out.At = rec.ID, rec.At.Format(time.RFC3339)
+	}
+	return out, nil
+}
+
+func Reanalyze(ctx context.Context, auditID string, opts Options) (Reanalysis, error) {
+	return defaultAnalyzer.Reanalyze(ctx, auditID, opts)
+}
+
+// ReanalyzeRange reanalyzes up to limit audits in r, oldest first, passing
+// each result to fn as soon as it is ready, without the replayed Response so
+// long runs stay small. An audit that cannot be replayed
+// is reported in Reanalysis.Error and the run continues; an error from fn,
+// from listing the audits, or ctx ends it. Without a RangeLister store it
+// returns ErrRangeUnsupported.
+func (a *Analyzer) ReanalyzeRange(ctx context.Context, r audit.Range, limit int, opts Options, fn func(Reanalysis) error) error {
+	store, ok := a.settings().store.(audit.RangeLister)
+	if !ok {
+		return ErrRangeUnsupported
+	}
+	ids, err := store.AuditIDsInRange(ctx, r, limit)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		res, err := a.Reanalyze(ctx, id, opts)
+		if err != nil {
+			res = Reanalysis{AuditID: id, Error: err.Error()}
+		}
+		res.Response = nil
+		if err := fn(res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ReanalyzeRange(ctx context.Context, r audit.Range, limit int, opts Options, fn func(Reanalysis) error) error {
+	return defaultAnalyzer.ReanalyzeRange(ctx, r, limit, opts, fn)
+}
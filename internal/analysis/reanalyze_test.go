@@ -0,0 +1,99 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+func TestReanalyze(t *testing.T) {
+	ctx := context.Background()
+	store := audit.NewMemoryStore()
+	a := New(WithAuditStore(store))
+	in := Intake{PatientName: "Replay", Age: 50, WeightKg: 80, HeightCm: 175, BP: "125/80", Complaint: "ED",
+		Medications: []Medication{{Name: "aspirin"}, {Name: "omeprazole"}}}
+	orig := a.Analyze(in)
+
+	same, err := a.Reanalyze(ctx, orig.AuditID, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same.Changed || same.Diff == nil || same.RulesetVersionBefore != orig.RulesetVersion || same.ID == "" {
+		t.Fatalf("unchanged rules: %+v", same)
+	}
+
+	r := a.Rules().Ruleset
+	r.Interactions = append(r.Interactions, InteractionRule{Code: "DDI_ASPIRIN_OMEPRAZOLE", Drug: "aspirin", With: "omeprazole", Severity: "info", Desc: "Test rule."})
+	if _, err := a.ReplaceRules(ctx, r, "", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := a.Reanalyze(ctx, orig.AuditID, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Changed || got.RulesetVersionAfter == orig.RulesetVersion || len(got.Diff.IssuesAdded) != 1 || got.Diff.IssuesAdded[0].Code != "DDI_ASPIRIN_OMEPRAZOLE" {
+		t.Fatalf("after rule change: %+v", got)
+	}
+	if got.Response == nil || !got.Response.DryRun || got.Response.AuditID != "" {
+		t.Fatalf("replay was audited: %+v", got.Response)
+	}
+
+	stored, err := a.AuditResponse(ctx, orig.AuditID)
+	if err != nil || len(stored.FlaggedIssues) != len(orig.FlaggedIssues) || stored.RulesetVersion != orig.RulesetVersion {
+		t.Fatalf("original audit modified: %+v (err %v)", stored, err)
+	}
+	if n := len(a.LatestAudits(50)); n != 1 {
+		t.Fatalf("%d audits, want only the original", n)
+	}
+	records, err := store.Reanalyses(ctx, orig.AuditID)
+	if err != nil || len(records) != 2 || records[0].Changed || !records[1].Changed || records[1].AuditID != orig.AuditID {
+		t.Fatalf("records = %+v (err %v)", records, err)
+	}
+
+	if _, err := a.Reanalyze(ctx, "missing", Options{}); !errors.Is(err, audit.ErrNotFound) {
+		t.Fatalf("unknown audit: err = %v", err)
+	}
+}
+
+func TestReanalyzeRange(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	a := New(WithClock(func() time.Time { return now }))
+	high := Intake{PatientName: "High", Age: 68, WeightKg: 110, HeightCm: 170, BP: "170/105", Complaint: "ED",
+		Conditions: []string{"heart disease"}, Medications: []Medication{{Name: "isosorbide mononitrate"}}}
+	low := Intake{PatientName: "Low", Age: 30, WeightKg: 70, HeightCm: 175, BP: "118/76", Complaint: "ED"}
+	first := a.Analyze(high)
+	now = now.Add(time.Hour)
+	a.Analyze(low)
+	now = now.Add(time.Hour)
+	third := a.Analyze(high)
+
+	var got []Reanalysis
+	collect := func(r Reanalysis) error {
+		got = append(got, r)
+		return nil
+	}
+	if err := a.ReanalyzeRange(ctx, audit.Range{RiskLevel: string(first.RiskLevel)}, 0, Options{}, collect); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].AuditID != first.AuditID || got[1].AuditID != third.AuditID || got[0].Response != nil {
+		t.Fatalf("by risk level: %+v", got)
+	}
+
+	got = nil
+	from := time.Date(2025, 6, 1, 12, 30, 0, 0, time.UTC)
+	if err := a.ReanalyzeRange(ctx, audit.Range{From: from}, 1, Options{}, collect); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].AuditID == first.AuditID {
+		t.Fatalf("from %s, limit 1: %+v", from, got)
+	}
+
+	stop := errors.New("stop")
+	if err := a.ReanalyzeRange(ctx, audit.Range{}, 0, Options{}, func(Reanalysis) error { return stop }); !errors.Is(err, stop) {
+		t.Fatalf("callback error: err = %v", err)
+	}
+}
@@ -0,0 +1,221 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/metrics"
+	"github.com/Skufu/Clinical-AI-Assistant/types"
+)
+
+// Follow-up reminder types.
+type (
+	Reminder                = types.Reminder
+	CompleteReminderRequest = types.CompleteReminderRequest
+)
+
+var remindersOverdue = metrics.NewCounter("reminders_overdue_total", "Follow-up reminders found past due by the reminder check.")
+
+// ErrRemindersUnsupported is returned when the audit store does not keep
+// follow-up reminders.
+var ErrRemindersUnsupported = errors.New("audit store does not support follow-up reminders")
+
+// ErrInvalidReminderFilter is returned by Reminders for a due filter other
+// than overdue or a day count such as 7d.
+var ErrInvalidReminderFilter = errors.New(`due must be "overdue" or a day count from 1d to 365d`)
+
+// ReminderFilter selects open reminders. Due is "overdue" for those past
+// due, a day count such as "7d" for those due within that many days,
+// overdue ones included, or empty for all of them.
+type ReminderFilter struct {
+	Due   string
+	Limit int
+}
+
+// dueBy is the latest due time f selects; zero selects every open reminder.
+func (f ReminderFilter) dueBy(now time.Time) (time.Time, error) {
+	due := strings.TrimSpace(f.Due)
+	switch {
+	case due == "":
+		return time.Time{}, nil
+	case due == "overdue":
+		return now, nil
+	case strings.HasSuffix(due, "d"):
+		if days, err := strconv.Atoi(strings.TrimSuffix(due, "d")); err == nil && days >= 1 && days <= 365 {
+			return now.AddDate(0, 0, days), nil
+		}
+	}
+	return time.Time{}, ErrInvalidReminderFilter
+}
+
+// Reminders lists the open follow-up reminders of the ctx org that f
+// selects, soonest due first. Errors are ErrInvalidReminderFilter or
+// ErrRemindersUnsupported.
+func (a *Analyzer) Reminders(ctx context.Context, f ReminderFilter) ([]Reminder, error) {
+	store, ok := a.settings().store.(audit.ReminderStore)
+	if !ok {
+		return nil, ErrRemindersUnsupported
+	}
+	now := a.now().UTC()
+	dueBy, err := f.dueBy(now)
+	if err != nil {
+		return nil, err
+	}
+	rs, err := store.Reminders(ctx, dueBy, f.Limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Reminder, 0, len(rs))
+	for _, r := range rs {
+		out = append(out, reminderOf(r, now))
+	}
+	return out, nil
+}
+
+func Reminders(ctx context.Context, f ReminderFilter) ([]Reminder, error) {
+	return defaultAnalyzer.Reminders(ctx, f)
+}
+
+// CompleteReminder closes reminder id once the follow-up has happened.
+// Errors are audit.ErrNotFound, audit.ErrReminderCompleted, or
+// ErrRemindersUnsupported.
+func (a *Analyzer) CompleteReminder(ctx context.Context, id string, req CompleteReminderRequest) (Reminder, error) {
+	store, ok := a.settings().store.(audit.ReminderStore)
+	if !ok {
+		return Reminder{}, ErrRemindersUnsupported
+	}
+	now := a.now().UTC()
+	r, err := store.CompleteReminder(ctx, id, req.UserID, now)
+	if err != nil {
+		return Reminder{}, err
+	}
+	return reminderOf(r, now), nil
+}
+
+func CompleteReminder(ctx context.Context, id string, req CompleteReminderRequest) (Reminder, error) {
+	return defaultAnalyzer.CompleteReminder(ctx, id, req)
+}
+
+// SetReminderNotifications makes MarkOverdueReminders tell the notifiers
+// about each reminder it finds past due.
+func (a *Analyzer) SetReminderNotifications(on bool) {
+	_ = a.update(func(s *settings) error {
+		s.reminderNotify = on
+		return nil
+	})
+}
+
+func SetReminderNotifications(on bool) {
+	defaultAnalyzer.SetReminderNotifications(on)
+}
+
+// MarkOverdueReminders marks the pending reminders of every org that are
+// past due as overdue, notifying about each when SetReminderNotifications is
+// on, and returns how many it marked.
+func (a *Analyzer) MarkOverdueReminders(ctx context.Context) (int, error) {
+	s := a.settings()
+	store, ok := s.store.(audit.ReminderStore)
+	if !ok {
+		return 0, ErrRemindersUnsupported
+	}
+	marked, err := store.MarkOverdue(ctx, a.now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	for _, r := range marked {
+		remindersOverdue.Inc()
+		if !s.reminderNotify {
+			continue
+		}
+		e := Event{Kind: EventReminderOverdue, AuditID: r.AuditID, At: r.CreatedAt, Org: r.Org, ReminderID: r.ID, DueAt: r.DueAt}
+		for _, n := range s.notifiers {
+			a.notify(audit.WithOrg(ctx, r.Org), n, e)
+		}
+	}
+	return len(marked), nil
+}
+
+func MarkOverdueReminders(ctx context.Context) (int, error) {
+	return defaultAnalyzer.MarkOverdueReminders(ctx)
+}
+
+// RunReminders calls MarkOverdueReminders every interval until ctx is done,
+// logging failures.
+func (a *Analyzer) RunReminders(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if n, err := a.MarkOverdueReminders(ctx); err != nil {
+				log.Printf("reminder check failed: %v", err)
+			} else if n > 0 {
+				log.Printf("reminder check marked %d overdue", n)
+			}
+		}
+	}
+}
+
+func RunReminders(ctx context.Context, every time.Duration) {
+	defaultAnalyzer.RunReminders(ctx, every)
+}
+
+// reminder returns the hook that schedules the follow-up of resp's plan once
+// its audit is written, or nil when the plan has none or the store keeps no
+// reminders. The due date counts from the audit time.
+func (a *Analyzer) reminder(s settings, resp Response) func(ctx context.Context, sum audit.Summary) {
+	store, ok := s.store.(audit.ReminderStore)
+	fu := resp.RecommendedPlan.FollowUp
+	if !ok || fu == nil || fu.IntervalDays <= 0 {
+		return nil
+	}
+	return func(ctx context.Context, sum audit.Summary) {
+		at, err := time.Parse(time.RFC3339, sum.At)
+		if err != nil {
+			at = a.now().UTC()
+		}
+		_, err = store.InsertReminder(ctx, audit.Reminder{
+			AuditID:      sum.AuditID,
+			Instructions: fu.Instructions,
+			DueAt:        at.AddDate(0, 0, fu.IntervalDays),
+			CreatedAt:    at,
+		})
+		if err != nil {
+			// The analysis stands; only its reminder is missing.
+			log.Printf("follow-up reminder not scheduled audit_id=%s: %v", sum.AuditID, err)
+		}
+	}
+}
+
+// reminderOf converts r, showing a pending reminder past due at now as
+// overdue before the reminder check has marked it.
+func reminderOf(r audit.Reminder, now time.Time) Reminder {
+	status := r.Status
+	if status == audit.ReminderPending && !r.DueAt.After(now) {
+		status = audit.ReminderOverdue
+	}
+	out := Reminder{
+		ID:           r.ID,
+		AuditID:      r.AuditID,
+		PatientRef:   r.PatientRef,
+		Complaint:    r.Complaint,
+		Instructions: r.Instructions,
+		DueAt:        r.DueAt.UTC().Format(time.RFC3339),
+		Status:       status,
+		CompletedBy:  r.CompletedBy,
+	}
+	if !r.CreatedAt.IsZero() {
+		out.CreatedAt = r.CreatedAt.UTC().Format(time.RFC3339)
+	}
+	if !r.CompletedAt.IsZero() {
+		out.CompletedAt = r.CompletedAt.UTC().Format(time.RFC3339)
+	}
+	return out
+}
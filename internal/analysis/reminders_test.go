@@ -0,0 +1,84 @@
+package analysis
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+func TestReminders_FromPlanFollowUp(t *testing.T) {
+	now := fixedClock()
+	n := &recordingNotifier{}
+	a := New(WithAuditStore(audit.NewMemoryStore()), WithClock(func() time.Time { return now }), WithNotifier(n))
+	a.SetReminderNotifications(true)
+
+	in := Intake{PatientName: "Jane Roe", Age: 30, WeightKg: 70, HeightCm: 175, BP: "118/76", Complaint: "ED"}
+	resp := a.AnalyzeContext(t.Context(), in, Options{})
+	a.AnalyzeContext(t.Context(), in, Options{DryRun: true})
+	fu := resp.RecommendedPlan.FollowUp
+	if resp.AuditID == "" || fu == nil {
+		t.Fatalf("response = %+v", resp)
+	}
+
+	all, err := a.Reminders(t.Context(), ReminderFilter{})
+	if err != nil || len(all) != 1 {
+		t.Fatalf("reminders = %+v (err %v)", all, err)
+	}
+	r := all[0]
+	wantDue := now.AddDate(0, 0, fu.IntervalDays).Format(time.RFC3339)
+	if r.AuditID != resp.AuditID || r.DueAt != wantDue || r.Status != audit.ReminderPending || r.Complaint != "ED" || r.Instructions != fu.Instructions {
+		t.Fatalf("reminder = %+v, want due %s", r, wantDue)
+	}
+	if body, _ := json.Marshal(r); r.PatientRef == "" || strings.Contains(string(body), "Jane Roe") {
+		t.Fatalf("reminder should name the patient by reference only: %s", body)
+	}
+	if soon, err := a.Reminders(t.Context(), ReminderFilter{Due: "7d"}); err != nil || len(soon) != 0 {
+		t.Fatalf("due in 7 days = %+v (err %v)", soon, err)
+	}
+	for _, bad := range []string{"soon", "0d", "7", "-3d"} {
+		if _, err := a.Reminders(t.Context(), ReminderFilter{Due: bad}); !errors.Is(err, ErrInvalidReminderFilter) {
+			t.Errorf("due=%s: %v", bad, err)
+		}
+	}
+
+	now = now.AddDate(0, 0, fu.IntervalDays+1)
+	overdue, err := a.Reminders(t.Context(), ReminderFilter{Due: "overdue"})
+	if err != nil || len(overdue) != 1 || overdue[0].Status != audit.ReminderOverdue {
+		t.Fatalf("overdue before the check = %+v (err %v)", overdue, err)
+	}
+	if marked, err := a.MarkOverdueReminders(t.Context()); err != nil || marked != 1 {
+		t.Fatalf("marked %d (err %v)", marked, err)
+	}
+	if marked, err := a.MarkOverdueReminders(t.Context()); err != nil || marked != 0 {
+		t.Fatalf("second check marked %d (err %v)", marked, err)
+	}
+	a.WaitNotifications()
+	if len(n.events) != 1 || n.events[0].Kind != EventReminderOverdue || n.events[0].ReminderID != r.ID || n.events[0].AuditID != resp.AuditID {
+		t.Fatalf("events = %+v", n.events)
+	}
+
+	done, err := a.CompleteReminder(t.Context(), r.ID, CompleteReminderRequest{UserID: "dr-a"})
+	if err != nil || done.Status != audit.ReminderCompleted || done.CompletedBy != "dr-a" || done.CompletedAt == "" {
+		t.Fatalf("completed = %+v (err %v)", done, err)
+	}
+	if _, err := a.CompleteReminder(t.Context(), r.ID, CompleteReminderRequest{}); !errors.Is(err, audit.ErrReminderCompleted) {
+		t.Fatalf("second complete: %v", err)
+	}
+	if open, err := a.Reminders(t.Context(), ReminderFilter{}); err != nil || len(open) != 0 {
+		t.Fatalf("open after completing = %+v (err %v)", open, err)
+	}
+}
+
+func TestReminders_Unsupported(t *testing.T) {
+	a := New(WithAuditStore(latestOnlyStore{audit.NewMemoryStore()}))
+	if resp := a.Analyze(localeIntake); resp.AuditID == "" {
+		t.Fatalf("analysis without reminder support: %+v", resp)
+	}
+	if _, err := a.Reminders(t.Context(), ReminderFilter{}); !errors.Is(err, ErrRemindersUnsupported) {
+		t.Fatalf("err = %v, want ErrRemindersUnsupported", err)
+	}
+}
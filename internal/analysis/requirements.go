@@ -0,0 +1,168 @@
+package analysis
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Intake fields a complaint can require or recommend.
+const (
+	RequireBP          = "bp"
+	RequireWeight      = "weight"
+	RequireHeight      = "height"
+	RequireMedications = "medications"
+	RequireConditions  = "conditions"
+)
+
+var requirableFields = []string{RequireBP, RequireWeight, RequireHeight, RequireMedications, RequireConditions}
+
+// ComplaintRequirements lists the intake fields a complaint's plan depends
+// on. A missing Required field fails validation; a missing Recommended one is
+// a warning, and analysis flags it with an INTAKE_FIELD_MISSING issue. A
+// supplied bmi stands in for weight and height, and confirmedNoMedications
+// and confirmedNoConditions stand in for an empty list.
+type ComplaintRequirements struct {
+	Required    []string `json:"required,omitempty"`
+	Recommended []string `json:"recommended,omitempty"`
+}
+
+// Validate reports unknown fields and fields listed as both required and
+// recommended.
+func (r ComplaintRequirements) Validate() error {
+	for _, f := range slices.Concat(r.Required, r.Recommended) {
+		if !slices.Contains(requirableFields, f) {
+			return fmt.Errorf("unknown required field %q; want one of %s", f, strings.Join(requirableFields, ", "))
+		}
+	}
+	for _, f := range r.Required {
+		if slices.Contains(r.Recommended, f) {
+			return fmt.Errorf("field %q is both required and recommended", f)
+		}
+	}
+	return nil
+}
+
+// generalRequirements apply to complaints with no entry of their own, and
+// when the intake names no complaint.
+var generalRequirements = ComplaintRequirements{Required: []string{RequireBP, RequireWeight, RequireHeight}}
+
+// complaintRequirements sit beside buildPlan: each plan declares what it
+// reads. ED plans are screened for nitrates, alpha-blockers, and cardiac
+// history, so an empty medication or condition list must be confirmed;
+// hair loss plans do not read blood pressure, and weight loss plans read
+// BMI but not blood pressure.
+var complaintRequirements = map[string]ComplaintRequirements{
+	"ed": {Required: []string{RequireBP, RequireWeight, RequireHeight, RequireMedications, RequireConditions}},
+	"hair loss": {
+		Required:    []string{RequireWeight, RequireHeight},
+		Recommended: []string{RequireBP},
+	},
+	"weight loss": {Required: []string{RequireWeight, RequireHeight}},
+}
+
+// WithComplaintRequirements declares the fields a complaint requires,
+// replacing the built-in entry for a known complaint. Complaints without an
+// entry use the general requirements (bp, weight, and height). It panics on
+// fields Validate rejects.
+func WithComplaintRequirements(complaint string, r ComplaintRequirements) Option {
+	return func(a *Analyzer) {
+		if err := r.Validate(); err != nil {
+			panic(fmt.Sprintf("analysis: complaint %q: %v", complaint, err))
+		}
+		reqs := maps.Clone(a.s.requirements)
+		reqs[normalizeName(complaint)] = ComplaintRequirements{Required: slices.Clone(r.Required), Recommended: slices.Clone(r.Recommended)}
+		a.s.requirements = reqs
+	}
+}
+
+// SetListConfirmationRequired makes Validate reject an intake whose
+// complaint requires the medication or condition list (ED does) when the list
+// is empty and not confirmed with confirmedNoMedications or
+// confirmedNoConditions. It is off by default so existing embedders keep
+// working; until then such an intake only gets a warning.
+func (a *Analyzer) SetListConfirmationRequired(enabled bool) {
+	_ = a.update(func(s *settings) error {
+		s.listConfirmation = enabled
+		return nil
+	})
+}
+
+func SetListConfirmationRequired(enabled bool) {
+	defaultAnalyzer.SetListConfirmationRequired(enabled)
+}
+
+// requirementErrors checks the intake against the requirements of each of
+// its complaints. A field any complaint requires is an error attributed to
+// the first such complaint in priority order; a field only recommended is a
+// warning, as is a required list while list confirmation is off.
+func (s settings) requirementErrors(in Intake) (errs, warnings []FieldError) {
+	complaints := intakeComplaints(in)
+	if len(complaints) == 0 {
+		complaints = []string{""}
+	}
+	required := map[string]string{}
+	recommended := map[string]string{}
+	for _, c := range complaints {
+		r, ok := s.requirements[normalizeName(c)]
+		if !ok {
+			r = generalRequirements
+		}
+		for _, f := range r.Required {
+			if _, seen := required[f]; !seen {
+				required[f] = c
+			}
+		}
+		for _, f := range r.Recommended {
+			if _, seen := recommended[f]; !seen {
+				recommended[f] = c
+			}
+		}
+	}
+	for _, f := range requirableFields {
+		if !missingField(in, f) {
+			continue
+		}
+		list := f == RequireMedications || f == RequireConditions
+		if c, ok := required[f]; ok && (s.listConfirmation || !list) {
+			errs = append(errs, requirementError(f, c, "required", "is required"))
+		} else if ok {
+			warnings = append(warnings, requirementError(f, c, "recommended", "is recommended"))
+		} else if c, ok := recommended[f]; ok {
+			warnings = append(warnings, requirementError(f, c, "recommended", "is recommended"))
+		}
+	}
+	return errs, warnings
+}
+
+func requirementError(field, complaint, code, verb string) FieldError {
+	msg := field + " " + verb
+	if complaint != "" {
+		msg += " for " + complaint
+	}
+	switch field {
+	case RequireMedications:
+		msg += "; set confirmedNoMedications when the patient takes none"
+	case RequireConditions:
+		msg += "; set confirmedNoConditions when the patient has none"
+	}
+	return FieldError{Field: field, Code: code, Message: msg, Complaint: complaint}
+}
+
+// missingField reports whether the intake lacks a requirable field. A
+// supplied BMI stands in for weight and height, as effectiveBMI uses it.
+func missingField(in Intake, field string) bool {
+	switch field {
+	case RequireBP:
+		return strings.TrimSpace(in.BP) == ""
+	case RequireWeight:
+		return in.WeightKg == 0 && in.BMI <= 0
+	case RequireHeight:
+		return in.HeightCm == 0 && in.BMI <= 0
+	case RequireMedications, RequireConditions:
+		n, none := historyEntries(in, field)
+		return n == 0 && !none
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package analysis
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRequirementErrors(t *testing.T) {
+	base := Intake{PatientName: "Req", Age: 40, WeightKg: 80, HeightCm: 175}
+	with := func(complaint string, edit func(*Intake)) Intake {
+		in := base
+		in.Complaint = complaint
+		if edit != nil {
+			edit(&in)
+		}
+		return in
+	}
+	noBP := func(in *Intake) { in.BP = "" }
+	cases := []struct {
+		name     string
+		in       Intake
+		errs     []string // field:complaint
+		warnings []string
+	}{
+		{"ed without bp", with("ED", noBP), []string{"bp:ED"}, []string{"medications:ED", "conditions:ED"}},
+		{"ed with confirmed lists", with("ED", func(in *Intake) {
+			in.BP, in.ConfirmedNoMedications, in.ConfirmedNoConditions = "120/80", true, true
+		}), nil, nil},
+		{"hair loss without bp", with("Hair Loss", noBP), nil, []string{"bp:Hair Loss"}},
+		{"weight loss without bp", with("Weight Loss", noBP), nil, nil},
+		{"weight loss without height", with("Weight Loss", func(in *Intake) { in.HeightCm = 0 }), []string{"height:Weight Loss"}, nil},
+		{"bmi stands in", with("Weight Loss", func(in *Intake) { in.WeightKg, in.HeightCm, in.BMI = 0, 0, 27 }), nil, nil},
+		{"unrecognized complaint", with("Fatigue", noBP), []string{"bp:Fatigue"}, nil},
+		{"ed outranks hair loss", with("Hair Loss", func(in *Intake) {
+			in.Complaints, in.Conditions, in.Medications = []string{"ED"}, []string{"asthma"}, []Medication{{Name: "salbutamol"}}
+		}), []string{"bp:ED"}, nil},
+	}
+	s := New().settings()
+	for _, tc := range cases {
+		errs, warnings := s.requirementErrors(tc.in)
+		if got := requirementKeys(errs, "required"); !slices.Equal(got, tc.errs) {
+			t.Errorf("%s: errors %v, want %v", tc.name, got, tc.errs)
+		}
+		if got := requirementKeys(warnings, "recommended"); !slices.Equal(got, tc.warnings) {
+			t.Errorf("%s: warnings %v, want %v", tc.name, got, tc.warnings)
+		}
+	}
+}
+
+func requirementKeys(errs []FieldError, code string) []string {
+	var out []string
+	for _, e := range errs {
+		if e.Code == code {
+			out = append(out, e.Field+":"+e.Complaint)
+		}
+	}
+	return out
+}
+
+func TestAnalyze_ListConfirmation(t *testing.T) {
+	in := Intake{PatientName: "Lists", Age: 50, WeightKg: 80, HeightCm: 175, BP: "125/80", Complaint: "ED"}
+	a := New()
+	resp := a.Analyze(in)
+	if resp.RiskLevel == RiskInvalid || !slices.Contains(issueCodes(resp.FlaggedIssues), "INTAKE_FIELD_MISSING") {
+		t.Fatalf("unconfirmed lists should only be flagged: %s %v %v", resp.RiskLevel, resp.ValidationErrors, issueCodes(resp.FlaggedIssues))
+	}
+
+	a.SetListConfirmationRequired(true)
+	resp = a.Analyze(in)
+	want := []string{
+		"medications is required for ED; set confirmedNoMedications when the patient takes none",
+		"conditions is required for ED; set confirmedNoConditions when the patient has none",
+	}
+	if resp.RiskLevel != RiskInvalid || !slices.Equal(resp.ValidationErrors, want) {
+		t.Fatalf("validation errors %q, want %q", resp.ValidationErrors, want)
+	}
+
+	in.ConfirmedNoMedications, in.ConfirmedNoConditions = true, true
+	if resp = a.Analyze(in); resp.RiskLevel == RiskInvalid || slices.Contains(issueCodes(resp.FlaggedIssues), "INTAKE_FIELD_MISSING") {
+		t.Fatalf("confirmed lists: %s %v %v", resp.RiskLevel, resp.ValidationErrors, issueCodes(resp.FlaggedIssues))
+	}
+}
+
+func TestWithComplaintRequirements(t *testing.T) {
+	a := New(WithComplaintRequirements("Acne", ComplaintRequirements{Required: []string{RequireWeight}, Recommended: []string{RequireBP}}))
+	errs, warnings := a.settings().requirementErrors(Intake{Complaint: "acne"})
+	if got := requirementKeys(errs, "required"); !slices.Equal(got, []string{"weight:acne"}) {
+		t.Fatalf("errors %v", got)
+	}
+	if got := requirementKeys(warnings, "recommended"); !slices.Equal(got, []string{"bp:acne"}) {
+		t.Fatalf("warnings %v", got)
+	}
+	if _, ok := New().settings().requirements["acne"]; ok {
+		t.Fatal("WithComplaintRequirements changed the shared table")
+	}
+
+	for _, r := range []ComplaintRequirements{
+		{Required: []string{"pulse"}},
+		{Required: []string{RequireBP}, Recommended: []string{RequireBP}},
+	} {
+		if err := r.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil", r)
+		}
+	}
+}
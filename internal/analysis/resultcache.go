@@ -0,0 +1,147 @@
+package analysis
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/metrics"
+)
+
+var resultCacheLookups = metrics.NewCounter("analysis_cache_lookups_total", "Analysis result cache lookups by result.", "result")
+
+// resultCache is an LRU of analysis responses keyed by resultCacheKey. It
+// holds the responses of one settings version only: the first lookup under a
+// new version empties it.
+type resultCache struct {
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu      sync.Mutex
+	version string
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type resultCacheEntry struct {
+	key     string
+	body    []byte
+	expires time.Time
+}
+
+func newResultCache(size int, ttl time.Duration) *resultCache {
+	return &resultCache{size: size, ttl: ttl, now: time.Now, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+// WithResultCache caches up to size analysis responses for ttl each, so an
+// intake resubmitted unchanged skips the pipeline; see SetResultCache.
+func WithResultCache(size int, ttl time.Duration) Option {
+	return func(a *Analyzer) {
+		if size > 0 && ttl > 0 {
+			a.s.results = newResultCache(size, ttl)
+		}
+	}
+}
+
+// SetResultCache caches up to size analysis responses for ttl each. A
+// resubmitted intake that differs only in patientName, userId, or consent
+// gets the cached clinical content with Cached set, and is still audited as
+// its own analysis with its own audit ID and patient trend. Changing the
+// ruleset, prompt, or any other setting empties the cache, and degraded LLM
+// scores are never cached. A size or ttl of zero turns caching off.
+func (a *Analyzer) SetResultCache(size int, ttl time.Duration) {
+	var c *resultCache
+	if size > 0 && ttl > 0 {
+		c = newResultCache(size, ttl)
+	}
+	_ = a.update(func(s *settings) error {
+		s.results = c
+		return nil
+	})
+}
+
+func SetResultCache(size int, ttl time.Duration) {
+	defaultAnalyzer.SetResultCache(size, ttl)
+}
+
+// resultVersion names the settings a cached response was computed under. The
+// generation moves with every setter; the ruleset version also catches a
+// rules source whose content changed under it.
+func (s settings) resultVersion() string {
+	return s.rulesetVersion() + "/" + strconv.FormatUint(s.generation, 10)
+}
+
+func (c *resultCache) get(version, key string) (Response, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if version != c.version {
+		c.order.Init()
+		clear(c.entries)
+		c.version = version
+	}
+	el, ok := c.entries[key]
+	if !ok {
+		return Response{}, false
+	}
+	entry := el.Value.(*resultCacheEntry)
+	if c.now().After(entry.expires) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return Response{}, false
+	}
+	// Each hit decodes its own copy, which the caller is free to change.
+	var resp Response
+	if err := json.Unmarshal(entry.body, &resp); err != nil {
+		return Response{}, false
+	}
+	c.order.MoveToFront(el)
+	return resp, true
+}
+
+func (c *resultCache) put(version, key string, resp Response) {
+	body, err := json.Marshal(resp)
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if version != c.version {
+		return
+	}
+	entry := &resultCacheEntry{key: key, body: body, expires: c.now().Add(c.ttl)}
+	if el, ok := c.entries[key]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*resultCacheEntry).key)
+	}
+}
+
+// resultCacheKey hashes the intake without the fields that identify the
+// patient or clinician or only reach the audit, together with the org
+// whose overrides apply and the options that shape the response.
+func resultCacheKey(ctx context.Context, in Intake, opts Options) string {
+	in.PatientName, in.UserID, in.Consent = "", "", nil
+	key := struct {
+		Intake Intake `json:"intake"`
+		Org    string `json:"org"`
+		Locale string `json:"locale"`
+		Debug  bool   `json:"debug"`
+		DryRun bool   `json:"dryRun"`
+	}{in, audit.OrgFrom(ctx), opts.Locale, opts.Debug, opts.DryRun}
+	body, _ := json.Marshal(key)
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,87 @@
+package analysis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+func TestResultCache(t *testing.T) {
+	ctx := context.Background()
+	llm := &countingLLM{}
+	store := audit.NewMemoryStore()
+	a := New(WithAuditStore(store), WithLLMClient(llm), WithResultCache(8, time.Minute))
+	in := Intake{PatientName: "First Visit", Age: 50, WeightKg: 80, HeightCm: 175, BP: "125/80", Complaint: "ED",
+		Medications: []Medication{{Name: "aspirin"}, {Name: "omeprazole"}}}
+
+	first := a.Analyze(in)
+	if first.Cached || llm.calls != 1 {
+		t.Fatalf("first analysis: cached %v, %d LLM calls", first.Cached, llm.calls)
+	}
+	again := in
+	again.PatientName, again.UserID = "Second Visit", "dr-b"
+	second := a.Analyze(again)
+	if !second.Cached || llm.calls != 1 {
+		t.Fatalf("resubmission: cached %v, %d LLM calls", second.Cached, llm.calls)
+	}
+	if second.AuditID == "" || second.AuditID == first.AuditID || second.RiskScore != first.RiskScore || len(second.FlaggedIssues) != len(first.FlaggedIssues) {
+		t.Fatalf("cached response %+v, first %+v", second, first)
+	}
+	if errs := ValidateResponse(second); len(errs) > 0 {
+		t.Fatalf("cached response should satisfy schema: %v", errs)
+	}
+	if sums := a.LatestAudits(10); len(sums) != 2 {
+		t.Fatalf("each submission should be audited, got %d audits", len(sums))
+	}
+	if other := a.AnalyzeWithOptions(in, Options{Locale: "es"}); other.Cached {
+		t.Fatal("another locale should not share the cached response")
+	}
+
+	r := a.Rules().Ruleset
+	r.Interactions = append(r.Interactions, InteractionRule{Code: "DDI_ASPIRIN_OMEPRAZOLE", Drug: "aspirin", With: "omeprazole", Severity: "info", Desc: "Test rule."})
+	if _, err := a.ReplaceRules(ctx, r, "", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	calls := llm.calls
+	reloaded := a.Analyze(in)
+	if reloaded.Cached || llm.calls != calls+1 || reloaded.RulesetVersion == first.RulesetVersion {
+		t.Fatalf("after a rules reload: cached %v, %d new LLM calls", reloaded.Cached, llm.calls-calls)
+	}
+	found := false
+	for _, issue := range reloaded.FlaggedIssues {
+		found = found || issue.Code == "DDI_ASPIRIN_OMEPRAZOLE"
+	}
+	if !found {
+		t.Fatalf("recomputed response misses the new rule: %+v", reloaded.FlaggedIssues)
+	}
+	if !a.Analyze(in).Cached {
+		t.Fatal("the recomputed response should be cached in turn")
+	}
+
+	if err := a.SetSystemPromptTemplate(embeddedPrompt+"\nBe brief.\n", "test"); err != nil {
+		t.Fatal(err)
+	}
+	if resp := a.Analyze(in); resp.Cached || resp.PromptVersion == reloaded.PromptVersion {
+		t.Fatalf("after a prompt change: cached %v, prompt %s", resp.Cached, resp.PromptVersion)
+	}
+
+	a.SetResultCache(0, 0)
+	if resp := a.Analyze(in); resp.Cached {
+		t.Fatal("cache turned off still served a cached response")
+	}
+}
+
+func TestResultCache_DegradedNotCached(t *testing.T) {
+	llm := &countingLLM{fail: true}
+	a := New(WithLLMClient(llm), WithResultCache(8, time.Minute))
+	for range 2 {
+		if resp := a.Analyze(llmIntake); resp.Cached {
+			t.Fatal("degraded response was cached")
+		}
+	}
+	if llm.calls < 2 {
+		t.Fatalf("expected a scoring attempt per analysis, got %d", llm.calls)
+	}
+}
@@ -0,0 +1,291 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+)
+
+// RiskWeight sets the points a risk factor adds to the score. It is part of
+// the Ruleset, so a rules file can tune every weight the engine applies.
+type RiskWeight struct {
+	Code   string `json:"code"`
+	Points int    `json:"points"`
+}
+
+// riskFactorDefs lists every risk factor the engine records, in display
+// order, with its default points. Factors sharing a group are mutually
+// exclusive tiers (e.g. elevated vs uncontrolled BP), so only the largest
+// weight in a group counts towards the maximum possible score. The
+// allergy_plan tiers scale with the matched allergy's severity; allergy_plan
+// itself is for allergies listed without one.
+var riskFactorDefs = []struct {
+	Code   string
+	Group  string
+	Points int
+}{
+	{"baseline", "baseline", 1},
+	{"bmi_obesity", "bmi", 2},
+	{"bmi_elevated", "bmi", 1},
+	{"bp_uncontrolled", "bp", 3},
+	{"bp_elevated", "bp", 2},
+	{"heart_disease", "heart_disease", 3},
+	{"kidney_disease", "kidney_disease", 2},
+	{"liver_disease", "liver_disease", 2},
+	{"diabetes", "diabetes", 1},
+	{"hypertension", "hypertension", 1},
+	{"metabolic_syndrome", "metabolic_syndrome", 2},
+	{"family_premature_cad", "family_premature_cad", 1},
+	{"age_over_65", "age", 2},
+	{"age_55_to_65", "age", 1},
+	{"smoking_current", "smoking", 2},
+	{"smoking_recent_quit", "smoking", 1},
+	{"smoking_heavy_history", "smoking_history", 1},
+	{"alcohol_heavy", "alcohol", 1},
+	{"sedentary", "exercise", 1},
+	{"nitrate_therapy", "nitrate", 5},
+	{"pde5_amlodipine", "pde5_amlodipine", 1},
+	{"pde5_tamsulosin", "pde5_tamsulosin", 1},
+	{"allergy_plan", "allergy_plan", 3},
+	{"allergy_plan_anaphylaxis", "allergy_plan", 5},
+	{"allergy_plan_severe", "allergy_plan", 4},
+	{"allergy_plan_moderate", "allergy_plan", 3},
+	{"allergy_plan_mild", "allergy_plan", 2},
+	{"allergy_plan_intolerance", "allergy_plan", 1},
+	{"dose_cap", "dose_cap", 2},
+}
+
+func defaultRiskWeights() map[string]int {
+	out := make(map[string]int, len(riskFactorDefs))
+	for _, d := range riskFactorDefs {
+		out[d.Code] = d.Points
+	}
+	return out
+}
+
+// riskFactorGroups maps each factor code to its group; codes not listed,
+// such as interaction rules, are their own group.
+var riskFactorGroups = func() map[string]string {
+	out := make(map[string]string, len(riskFactorDefs))
+	for _, d := range riskFactorDefs {
+		out[d.Code] = d.Group
+	}
+	return out
+}()
+
+// riskAccumulator collects risk contributions so the score and its breakdown
+// stay in sync. Factors worth no points are not recorded, and each group
+// counts once: when several plans trip the same rule, or tiers of one group,
+// only the largest contribution is kept. Contributions made under the same
+// issueKey count once too, so one fact flagged by several rule sources is
+// not scored twice. Nothing is ever subtracted, so a factor added to an
+// intake can only hold or raise the score.
+//
+// A tracing accumulator also records every rule checked, matched or not,
+// with the points each match added to the score after grouping, so the
+// trace sums to the score; see check.
+type riskAccumulator struct {
+	weights map[string]int
+	score   int
+	factors []RiskFactor
+	groups  map[string]int
+	keys    map[string]int
+	tracing bool
+	trace   []RuleTrace
+}
+
+func newRiskAccumulator(weights map[string]int, tracing bool) *riskAccumulator {
+	return &riskAccumulator{weights: weights, groups: map[string]int{}, keys: map[string]int{}, tracing: tracing}
+}
+
+// check reports matched, and when tracing records that rule code was
+// evaluated: the normalized values it read, from inputs, and on a match the
+// issue code it flags, if any. The points come from the add that follows a
+// match. inputs only runs when tracing, so untraced analyses build nothing.
+func (a *riskAccumulator) check(code, issue string, matched bool, inputs func() map[string]any) bool {
+	if !a.tracing {
+		return matched
+	}
+	t := RuleTrace{Rule: code, Matched: matched}
+	if inputs != nil {
+		t.Inputs = inputs()
+	}
+	if matched {
+		t.Issue = issue
+	}
+	a.trace = append(a.trace, t)
+	return matched
+}
+
+// credit adds the points a factor added to the score to the trace entry of
+// its latest check, or records a match with no inputs when none checked it.
+func (a *riskAccumulator) credit(code string, points int) {
+	if !a.tracing {
+		return
+	}
+	for i := len(a.trace) - 1; i >= 0; i-- {
+		if t := &a.trace[i]; t.Rule == code && t.Matched {
+			t.Points += points
+			return
+		}
+	}
+	a.trace = append(a.trace, RuleTrace{Rule: code, Matched: true, Points: points})
+}
+
+// add records factor code at its weight in the ruleset.
+func (a *riskAccumulator) add(code, description string) {
+	a.addPoints(code, description, a.weights[code])
+}
+
+// addKeyed is add for a factor backing the issue with the given issueKey.
+func (a *riskAccumulator) addKeyed(key, code, description string) {
+	a.addPointsKeyed(key, code, description, a.weights[code])
+}
+
+// addPoints records a factor whose points come from elsewhere in the ruleset,
+// such as an interaction rule's riskDelta.
+func (a *riskAccumulator) addPoints(code, description string, points int) {
+	a.addPointsKeyed("", code, description, points)
+}
+
+// addPointsKeyed is addPoints for a factor backing the issue with the given
+// issueKey; an empty key ties the factor to no issue.
+func (a *riskAccumulator) addPointsKeyed(key, code, description string, points int) {
+	if points <= 0 {
+		return
+	}
+	group, ok := riskFactorGroups[code]
+	if !ok {
+		group = code
+	}
+	f := RiskFactor{Code: code, Description: description, Points: points}
+	i, seen := a.groups[group]
+	if !seen && key != "" {
+		i, seen = a.keys[key]
+	}
+	if seen {
+		a.groups[group] = i
+		if key != "" {
+			a.keys[key] = i
+		}
+		if a.factors[i].Points < points {
+			a.credit(code, points-a.factors[i].Points)
+			a.score += points - a.factors[i].Points
+			a.factors[i] = f
+		}
+		return
+	}
+	a.groups[group] = len(a.factors)
+	if key != "" {
+		a.keys[key] = len(a.factors)
+	}
+	a.credit(code, points)
+	a.score += points
+	a.factors = append(a.factors, f)
+}
+
+// RiskThresholds controls how raw risk scores map to risk levels. A score at or
+// above a threshold is classified at that tier. Critical is optional; zero
+// disables the CRITICAL tier.
+type RiskThresholds struct {
+	Medium   int `json:"medium"`
+	High     int `json:"high"`
+	Critical int `json:"critical,omitempty"`
+}
+
+// DefaultRiskThresholds preserves the original MEDIUM >= 4, HIGH >= 8 cut points.
+var DefaultRiskThresholds = RiskThresholds{Medium: 4, High: 8}
+
+// Validate checks that thresholds are positive and strictly increasing.
+func (t RiskThresholds) Validate() error {
+	if t.Medium <= 0 {
+		return fmt.Errorf("medium threshold must be greater than 0")
+	}
+	if t.High <= t.Medium {
+		return fmt.Errorf("high threshold (%d) must be greater than medium (%d)", t.High, t.Medium)
+	}
+	if t.Critical != 0 && t.Critical <= t.High {
+		return fmt.Errorf("critical threshold (%d) must be greater than high (%d)", t.Critical, t.High)
+	}
+	return nil
+}
+
+// SetRiskThresholds replaces the active thresholds after validating them.
+func (a *Analyzer) SetRiskThresholds(t RiskThresholds) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	return a.update(func(s *settings) error {
+		// The prompt quotes the thresholds, so re-render it before committing.
+		next := *s
+		next.thresholds = t
+		info, err := renderPrompt(s.prompt, next)
+		if err != nil {
+			return err
+		}
+		info.Source = s.promptInfo.Source
+		s.thresholds = t
+		s.promptInfo = info
+		return nil
+	})
+}
+
+func SetRiskThresholds(t RiskThresholds) error {
+	return defaultAnalyzer.SetRiskThresholds(t)
+}
+
+// RiskThresholds returns the active risk tier cut points.
+func (a *Analyzer) RiskThresholds() RiskThresholds {
+	return a.settings().thresholds
+}
+
+func classifyRisk(score int, t RiskThresholds) RiskLevel {
+	switch {
+	case t.Critical > 0 && score >= t.Critical:
+		return RiskCritical
+	case score >= t.High:
+		return RiskHigh
+	case score >= t.Medium:
+		return RiskMedium
+	default:
+		return RiskLow
+	}
+}
+
+// MaxRiskScore is the highest raw score the active ruleset can produce: the sum
+// of the largest weight in each rule group plus every interaction rule's
+// riskDelta. It is an upper bound; some rules cannot fire together (a nitrate
+// patient never receives a PDE5 plan).
+func (a *Analyzer) MaxRiskScore() int {
+	return a.settings().maxRiskScore()
+}
+
+func MaxRiskScore() int {
+	return defaultAnalyzer.MaxRiskScore()
+}
+
+func (s settings) maxRiskScore() int {
+	maxByGroup := map[string]int{}
+	for _, d := range riskFactorDefs {
+		maxByGroup[d.Group] = max(maxByGroup[d.Group], s.riskWeights[d.Code])
+	}
+	total := 0
+	for _, p := range maxByGroup {
+		total += p
+	}
+	for _, rule := range s.rules.InteractionRules() {
+		total += max(rule.RiskDelta, 0)
+	}
+	return total
+}
+
+// normalizeRiskScore scales a raw score to 0-100 against max.
+func normalizeRiskScore(score, max int) int {
+	if max <= 0 || score <= 0 {
+		return 0
+	}
+	n := int(math.Round(float64(score) * 100 / float64(max)))
+	if n > 100 {
+		return 100
+	}
+	return n
+}
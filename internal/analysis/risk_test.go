@@ -0,0 +1,93 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+func TestRuleTrace_SumsToRiskScore(t *testing.T) {
+	a := New(WithAuditStore(audit.NewMemoryStore()))
+	cases := DemoCases()
+	// Two plans tripping one interaction rule, and an allergy with a
+	// severity, exercise the grouped and keyed contributions.
+	cases = append(cases, DemoCase{ID: "allergy-severe", Intake: Intake{
+		PatientName: "Trace Allergy", Age: 60, WeightKg: 90, HeightCm: 175, BP: "128/80", Complaint: "ED", Complaints: []string{"Hair Loss"},
+		Medications:    []Medication{{Name: "amlodipine", Dosage: "5mg", Frequency: "daily"}},
+		AllergyDetails: []Allergy{{Substance: "sildenafil", Severity: "severe"}},
+	}})
+	for _, c := range cases {
+		t.Run(c.ID, func(t *testing.T) {
+			resp := a.AnalyzeWithOptions(c.Intake, Options{RuleTrace: true, DryRun: true})
+			if len(resp.ValidationErrors) > 0 {
+				t.Fatal(resp.ValidationErrors)
+			}
+			sum, matched, unmatched := 0, 0, 0
+			for _, rt := range resp.RuleTrace {
+				sum += rt.Points
+				if rt.Matched {
+					matched++
+				} else {
+					unmatched++
+					if rt.Points != 0 || rt.Issue != "" {
+						t.Errorf("unmatched rule %s has points %d, issue %q", rt.Rule, rt.Points, rt.Issue)
+					}
+				}
+			}
+			if sum != resp.RiskScore {
+				t.Fatalf("trace points sum to %d, risk score %d: %+v", sum, resp.RiskScore, resp.RuleTrace)
+			}
+			if matched < len(resp.RiskFactors) || unmatched == 0 {
+				t.Fatalf("trace has %d matches for %d factors and %d non-matches", matched, len(resp.RiskFactors), unmatched)
+			}
+		})
+	}
+}
+
+func TestRuleTrace_Inputs(t *testing.T) {
+	in := llmIntake
+	in.BP = "150/95"
+	resp := New().AnalyzeWithOptions(in, Options{RuleTrace: true, DryRun: true})
+	byRule := map[string]RuleTrace{}
+	for _, rt := range resp.RuleTrace {
+		byRule[rt.Rule] = rt
+	}
+	bp := byRule["bp_elevated"]
+	if !bp.Matched || bp.Issue != "BP_ELEVATED" || bp.Inputs["systolic"] != 150 || bp.Inputs["diastolic"] != 95 {
+		t.Fatalf("bp_elevated trace = %+v", bp)
+	}
+	if u := byRule["bp_uncontrolled"]; u.Matched || u.Inputs["systolic"] != 150 {
+		t.Fatalf("bp_uncontrolled trace = %+v", u)
+	}
+	for _, rt := range resp.RuleTrace {
+		for k, v := range rt.Inputs {
+			if s, ok := v.(string); ok && strings.Contains(s, in.PatientName) {
+				t.Errorf("rule %s input %s carries the patient name", rt.Rule, k)
+			}
+		}
+	}
+}
+
+func TestRuleTrace_OffByDefault(t *testing.T) {
+	store := audit.NewMemoryStore()
+	a := New(WithAuditStore(store), WithResultCache(10, time.Minute))
+	if resp := a.AnalyzeWithOptions(llmIntake, Options{Debug: true}); resp.RuleTrace != nil {
+		t.Fatalf("rule trace without RuleTrace: %+v", resp.RuleTrace)
+	}
+
+	// A traced resubmission is evaluated afresh rather than served from the
+	// cache, and its trace stays out of the audit log.
+	traced := a.AnalyzeWithOptions(llmIntake, Options{RuleTrace: true})
+	if traced.Cached || len(traced.RuleTrace) == 0 {
+		t.Fatalf("traced response cached=%t trace=%d", traced.Cached, len(traced.RuleTrace))
+	}
+	stored, err := a.AuditResponse(t.Context(), traced.AuditID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.RuleTrace != nil {
+		t.Fatalf("rule trace written to the audit log: %+v", stored.RuleTrace)
+	}
+}
@@ -0,0 +1,461 @@
+package analysis
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+// ErrRulesAuditUnsupported is returned when the audit store cannot record
+// ruleset changes, so a replacement would go unaudited.
+var ErrRulesAuditUnsupported = errors.New("audit store does not support ruleset changes")
+
+// Ruleset is the editable clinical knowledge of the engine: pairwise
+// interaction rules, per-medication dose caps, the drug classes used for
+// duplicate-therapy checks, and the points each risk factor scores. The
+// built-in nitrate, PDE5, and alpha-blocker contraindication checks do not
+// read the rules, but their risk factors are weighted by RiskWeights.
+type Ruleset struct {
+	Interactions []InteractionRule `json:"interactions"`
+	DoseCaps     []DoseCap         `json:"doseCaps"`
+	DrugClasses  []DrugClass       `json:"drugClasses"`
+	// RiskWeights override the default points per risk factor code;
+	// factors not listed keep their default.
+	RiskWeights []RiskWeight `json:"riskWeights"`
+}
+
+// DoseCap flags a dose of Medication above MaxMg as DOSE_CAP_PDE5. Medication
+// matches normalized names by substring, as drug class members do.
+type DoseCap struct {
+	Medication string  `json:"medication"`
+	MaxMg      float64 `json:"maxMg"`
+}
+
+// RulesInfo describes the active ruleset. Version is a short hash of the
+// ruleset; Source is "embedded", "custom" for WithRules, the file it was
+// loaded from, or "api" for an unpersisted replacement.
+type RulesInfo struct {
+	Version string `json:"version"`
+	Source  string `json:"source"`
+	Ruleset
+}
+
+// RulesetError lists why a ruleset was rejected.
+type RulesetError struct {
+	Errors []string
+}
+
+func (e *RulesetError) Error() string {
+	return "invalid ruleset: " + strings.Join(e.Errors, "; ")
+}
+
+// defaultDoseCaps caps each PDE5 inhibitor at the starting dose the system
+// prompt quotes.
+func defaultDoseCaps() []DoseCap {
+	out := make([]DoseCap, 0, len(classPDE5.Members))
+	for _, m := range classPDE5.Members {
+		out = append(out, DoseCap{Medication: m, MaxMg: pde5DoseCapMg})
+	}
+	return out
+}
+
+// Validate reports every problem with r: rules missing a code or drug, a drug
+// paired with itself, the same pair listed twice in either order, unknown
+// severities, negative risk deltas, non-positive or duplicate dose caps,
+// empty or duplicate drug classes, and unknown, duplicate, or negative risk
+// weights.
+func (r Ruleset) Validate() []string {
+	r = r.normalized()
+	var errs []string
+	pairs := map[[2]string]int{}
+	for i, rule := range r.Interactions {
+		field := fmt.Sprintf("interactions[%d]", i)
+		if rule.Code == "" {
+			errs = append(errs, field+".code is required")
+		}
+		if rule.Drug == "" || rule.With == "" {
+			errs = append(errs, field+": drug and with are required")
+		} else if rule.Drug == rule.With {
+			errs = append(errs, fmt.Sprintf("%s pairs %s with itself", field, rule.Drug))
+		} else {
+			key := [2]string{min(rule.Drug, rule.With), max(rule.Drug, rule.With)}
+			if j, dup := pairs[key]; dup {
+				errs = append(errs, fmt.Sprintf("%s duplicates the %s/%s pair of interactions[%d]", field, key[0], key[1], j))
+			} else {
+				pairs[key] = i
+			}
+		}
+		if !rule.Severity.Valid() {
+			errs = append(errs, fmt.Sprintf("%s.severity must be danger, warning, or info, not %q", field, rule.Severity))
+		}
+		if rule.RiskDelta < 0 {
+			errs = append(errs, fmt.Sprintf("%s.riskDelta must not be negative", field))
+		}
+	}
+	caps := map[string]int{}
+	for i, c := range r.DoseCaps {
+		field := fmt.Sprintf("doseCaps[%d]", i)
+		switch j, dup := caps[c.Medication]; {
+		case c.Medication == "":
+			errs = append(errs, field+".medication is required")
+		case dup:
+			errs = append(errs, fmt.Sprintf("%s duplicates the %s cap of doseCaps[%d]", field, c.Medication, j))
+		default:
+			caps[c.Medication] = i
+		}
+		if c.MaxMg <= 0 {
+			errs = append(errs, field+".maxMg must be positive")
+		}
+	}
+	classes := map[string]int{}
+	for i, c := range r.DrugClasses {
+		field := fmt.Sprintf("drugClasses[%d]", i)
+		key := strings.ToLower(c.Name)
+		switch j, dup := classes[key]; {
+		case c.Name == "":
+			errs = append(errs, field+".name is required")
+		case dup:
+			errs = append(errs, fmt.Sprintf("%s duplicates the %s class of drugClasses[%d]", field, c.Name, j))
+		default:
+			classes[key] = i
+		}
+		if len(c.Members) == 0 {
+			errs = append(errs, field+".members must list at least one drug")
+		}
+		for _, m := range c.Members {
+			if m == "" {
+				errs = append(errs, field+".members must not be empty")
+				break
+			}
+		}
+	}
+	defaults := defaultRiskWeights()
+	weights := map[string]int{}
+	for i, w := range r.RiskWeights {
+		field := fmt.Sprintf("riskWeights[%d]", i)
+		_, known := defaults[w.Code]
+		switch j, dup := weights[w.Code]; {
+		case !known:
+			errs = append(errs, fmt.Sprintf("%s.code %q is not a risk factor", field, w.Code))
+		case dup:
+			errs = append(errs, fmt.Sprintf("%s duplicates the %s weight of riskWeights[%d]", field, w.Code, j))
+		default:
+			weights[w.Code] = i
+		}
+		if w.Points < 0 {
+			errs = append(errs, field+".points must not be negative")
+		}
+	}
+	return errs
+}
+
+// normalized lowercases and trims medication names the way intakes are
+// normalized, so rules match what the engine compares against. Slices are
+// copied and never nil.
+func (r Ruleset) normalized() Ruleset {
+	out := Ruleset{
+		Interactions: make([]InteractionRule, 0, len(r.Interactions)),
+		DoseCaps:     make([]DoseCap, 0, len(r.DoseCaps)),
+		DrugClasses:  make([]DrugClass, 0, len(r.DrugClasses)),
+		RiskWeights:  make([]RiskWeight, 0, len(r.RiskWeights)),
+	}
+	for _, rule := range r.Interactions {
+		rule.Code = strings.TrimSpace(rule.Code)
+		rule.Drug = normalizeName(rule.Drug)
+		rule.With = normalizeName(rule.With)
+		rule.Severity = Severity(normalizeName(string(rule.Severity)))
+		out.Interactions = append(out.Interactions, rule)
+	}
+	for _, c := range r.DoseCaps {
+		c.Medication = normalizeName(c.Medication)
+		out.DoseCaps = append(out.DoseCaps, c)
+	}
+	for _, c := range r.DrugClasses {
+		members := make([]string, 0, len(c.Members))
+		for _, m := range c.Members {
+			members = append(members, normalizeName(m))
+		}
+		out.DrugClasses = append(out.DrugClasses, DrugClass{Name: strings.TrimSpace(c.Name), Members: members})
+	}
+	for _, w := range r.RiskWeights {
+		w.Code = normalizeName(w.Code)
+		out.RiskWeights = append(out.RiskWeights, w)
+	}
+	return out
+}
+
+func normalizeName(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+func rulesHash(r Ruleset) string {
+	b, _ := json.Marshal(r)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// Rules returns the active ruleset with its version and source.
+func (a *Analyzer) Rules() RulesInfo {
+	return a.settings().rulesInfo()
+}
+
+func Rules() RulesInfo {
+	return defaultAnalyzer.Rules()
+}
+
+func (s settings) rulesInfo() RulesInfo {
+	r := Ruleset{
+		Interactions: s.rules.InteractionRules(),
+		DoseCaps:     s.doseCaps,
+		DrugClasses:  s.drugClasses,
+		RiskWeights:  make([]RiskWeight, 0, len(riskFactorDefs)),
+	}.normalized()
+	for _, d := range riskFactorDefs {
+		r.RiskWeights = append(r.RiskWeights, RiskWeight{Code: d.Code, Points: s.riskWeights[d.Code]})
+	}
+	return RulesInfo{Version: rulesHash(r), Source: s.rulesSource, Ruleset: r}
+}
+
+func (s *settings) setRuleset(r Ruleset, source string) error {
+	if errs := r.Validate(); len(errs) > 0 {
+		return &RulesetError{Errors: errs}
+	}
+	r = r.normalized()
+	s.rules = StaticRules(r.Interactions)
+	s.doseCaps = r.DoseCaps
+	s.drugClasses = r.DrugClasses
+	s.riskWeights = defaultRiskWeights()
+	for _, w := range r.RiskWeights {
+		s.riskWeights[w.Code] = w.Points
+	}
+	s.rulesSource = source
+	// The prompt quotes the tadalafil dose cap, so re-render it with the new
+	// caps; options set the ruleset before the prompt is first parsed.
+	if s.prompt != nil {
+		info, err := renderPrompt(s.prompt, *s)
+		if err != nil {
+			return err
+		}
+		info.Source = s.promptInfo.Source
+		s.promptInfo = info
+	}
+	return nil
+}
+
+// SetRuleset validates r and makes it active; on error the current ruleset
+// stays active. source is reported by Rules. The change is not audited; use
+// ReplaceRules for changes made at runtime.
+func (a *Analyzer) SetRuleset(r Ruleset, source string) error {
+	return a.update(func(s *settings) error {
+		return s.setRuleset(r, source)
+	})
+}
+
+func SetRuleset(r Ruleset, source string) error {
+	return defaultAnalyzer.SetRuleset(r, source)
+}
+
+// LoadRulesFile replaces the built-in ruleset with the JSON document at path,
+// in the form Rules reports it.
+func (a *Analyzer) LoadRulesFile(path string) error {
+	r, err := readRulesFile(path)
+	if err != nil {
+		return err
+	}
+	return a.SetRuleset(r, path)
+}
+
+func LoadRulesFile(path string) error {
+	return defaultAnalyzer.LoadRulesFile(path)
+}
+
+func readRulesFile(path string) (Ruleset, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Ruleset{}, fmt.Errorf("read rules: %w", err)
+	}
+	var r Ruleset
+	if err := json.Unmarshal(b, &r); err != nil {
+		return Ruleset{}, fmt.Errorf("parse rules %s: %w", path, err)
+	}
+	// Validate here as well as in SetRuleset so errors can name the line.
+	if errs := r.Validate(); len(errs) > 0 {
+		lines := jsonLines(b)
+		for i, e := range errs {
+			if n, ok := lines[errorField(e)]; ok {
+				errs[i] = fmt.Sprintf("%s:%d: %s", path, n, e)
+			}
+		}
+		return Ruleset{}, &RulesetError{Errors: errs}
+	}
+	return r, nil
+}
+
+// errorField returns the field path a Validate error starts with, such as
+// interactions[1].severity.
+func errorField(e string) string {
+	if i := strings.IndexAny(e, " :"); i >= 0 {
+		return e[:i]
+	}
+	return e
+}
+
+// jsonLines maps the path of every value in the JSON document b, written the
+// way Validate names fields (interactions[1].severity), to the line the value
+// starts on. b must be valid JSON.
+func jsonLines(b []byte) map[string]int {
+	type frame struct {
+		path   string
+		array  bool
+		index  int
+		key    string
+		hasKey bool
+	}
+	lines := map[string]int{}
+	var stack []*frame
+	dec := json.NewDecoder(bytes.NewReader(b))
+	for {
+		off := dec.InputOffset()
+		tok, err := dec.Token()
+		if err != nil {
+			return lines
+		}
+		if d, ok := tok.(json.Delim); ok && (d == '}' || d == ']') {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		var path string
+		if len(stack) > 0 {
+			top := stack[len(stack)-1]
+			switch {
+			case top.array:
+				path = fmt.Sprintf("%s[%d]", top.path, top.index)
+				top.index++
+			case !top.hasKey:
+				top.key, top.hasKey = tok.(string), true
+				continue
+			default:
+				path = top.key
+				if top.path != "" {
+					path = top.path + "." + top.key
+				}
+				top.hasKey = false
+			}
+		}
+		// The offset is where the previous token ended; skip the separator.
+		start := int(off) + len(b[off:]) - len(bytes.TrimLeft(b[off:], " \t\r\n:,"))
+		lines[path] = 1 + bytes.Count(b[:start], []byte("\n"))
+		if d, ok := tok.(json.Delim); ok {
+			stack = append(stack, &frame{path: path, array: d == '['})
+		}
+	}
+}
+
+// ReplaceRules validates r, writes it to path when path is set, records the
+// change in the audit store, and only then swaps it in, so analyses see
+// either the old or the new ruleset and every swap is audited. If the audit
+// insert fails the previous file contents are restored. Errors are a
+// *RulesetError, ErrRulesAuditUnsupported, or a store or file error.
+func (a *Analyzer) ReplaceRules(ctx context.Context, r Ruleset, path, actor string) (RulesInfo, error) {
+	if errs := r.Validate(); len(errs) > 0 {
+		return RulesInfo{}, &RulesetError{Errors: errs}
+	}
+	a.rulesMu.Lock()
+	defer a.rulesMu.Unlock()
+
+	s := a.settings()
+	store, ok := s.store.(audit.RulesChangeStore)
+	if !ok {
+		return RulesInfo{}, ErrRulesAuditUnsupported
+	}
+	source := "api"
+	if path != "" {
+		source = path
+	}
+	var next settings
+	if err := next.setRuleset(r, source); err != nil {
+		return RulesInfo{}, err
+	}
+	info := next.rulesInfo()
+
+	restore := func() error { return nil }
+	if path != "" {
+		var err error
+		if restore, err = writeRulesFile(path, info.Ruleset); err != nil {
+			return RulesInfo{}, err
+		}
+	}
+	change := audit.RulesChange{
+		OldVersion: s.rulesInfo().Version,
+		NewVersion: info.Version,
+		Source:     source,
+		Actor:      actor,
+		At:         a.now().UTC(),
+	}
+	if err := store.InsertRulesChange(ctx, change); err != nil {
+		if rerr := restore(); rerr != nil {
+			return RulesInfo{}, errors.Join(err, rerr)
+		}
+		return RulesInfo{}, err
+	}
+	if err := a.SetRuleset(info.Ruleset, source); err != nil {
+		return RulesInfo{}, err
+	}
+	return info, nil
+}
+
+func ReplaceRules(ctx context.Context, r Ruleset, path, actor string) (RulesInfo, error) {
+	return defaultAnalyzer.ReplaceRules(ctx, r, path, actor)
+}
+
+// writeRulesFile atomically replaces path with r and returns a function that
+// puts the previous contents back.
+func writeRulesFile(path string, r Ruleset) (restore func() error, err error) {
+	prev, err := os.ReadFile(path)
+	existed := err == nil
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("read rules: %w", err)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r); err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(path, buf.Bytes()); err != nil {
+		return nil, fmt.Errorf("write rules: %w", err)
+	}
+	return func() error {
+		if !existed {
+			return os.Remove(path)
+		}
+		return writeFileAtomic(path, prev)
+	}, nil
+}
+
+// writeFileAtomic replaces path with data through a temporary file beside
+// it, so readers see the old contents or the new, never part of either.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
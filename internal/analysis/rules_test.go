@@ -0,0 +1,193 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+	"github.com/Skufu/Clinical-AI-Assistant/types"
+)
+
+func TestRulesetValidate(t *testing.T) {
+	if errs := New().Rules().Validate(); len(errs) > 0 {
+		t.Fatalf("built-in ruleset invalid: %v", errs)
+	}
+	r := Ruleset{
+		Interactions: []InteractionRule{
+			{Code: "A", Drug: "aspirin", With: "warfarin", Severity: "warning", RiskDelta: 1},
+			{Code: "B", Drug: "Warfarin", With: "aspirin", Severity: "severe", RiskDelta: -1},
+		},
+		DoseCaps:    []DoseCap{{Medication: "sildenafil", MaxMg: 0}},
+		DrugClasses: []DrugClass{{Name: "statin", Members: []string{"simvastatin"}}, {Name: "Statin"}},
+		RiskWeights: []RiskWeight{{Code: "heart_disease", Points: 4}, {Code: "Heart_Disease", Points: -1}, {Code: "gout"}},
+	}
+	errs := strings.Join(r.Validate(), "\n")
+	for _, want := range []string{"duplicates the aspirin/warfarin pair", "interactions[1].severity", "interactions[1].riskDelta", "doseCaps[0].maxMg", "duplicates the Statin class", "drugClasses[1].members", "duplicates the heart_disease weight", "riskWeights[1].points", `"gout" is not a risk factor`} {
+		if !strings.Contains(errs, want) {
+			t.Errorf("errors missing %q:\n%s", want, errs)
+		}
+	}
+}
+
+func TestRiskWeightsFromRuleset(t *testing.T) {
+	in := Intake{
+		PatientName: "Weights",
+		Age:         40,
+		WeightKg:    70,
+		HeightCm:    170,
+		BP:          "120/80",
+		Conditions:  []string{"heart disease"},
+		Medications: []Medication{{Name: "amlodipine"}, {Name: "simvastatin"}},
+		Complaint:   "Hair Loss",
+	}
+	a := New()
+	resp := a.Analyze(in)
+	if resp.RiskScore != 5 || resp.RiskLevel != "MEDIUM" || classifyRisk(resp.RiskScore, DefaultRiskThresholds) != resp.RiskLevel {
+		t.Fatalf("default weights: score %d level %s factors %+v", resp.RiskScore, resp.RiskLevel, resp.RiskFactors)
+	}
+
+	r := a.Rules().Ruleset
+	for i := range r.Interactions {
+		if r.Interactions[i].Code == "DDI_AMLODIPINE_SIMVASTATIN" {
+			r.Interactions[i].RiskDelta = 2
+		}
+	}
+	r.RiskWeights = []RiskWeight{{Code: "heart_disease", Points: 7}, {Code: "baseline", Points: 0}}
+	b, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.LoadRulesFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	resp = a.Analyze(in)
+	if resp.RiskScore != 9 || resp.RiskLevel != "HIGH" {
+		t.Fatalf("custom weights: score %d level %s factors %+v", resp.RiskScore, resp.RiskLevel, resp.RiskFactors)
+	}
+	got := map[string]int{}
+	for _, f := range resp.RiskFactors {
+		got[f.Code] = f.Points
+	}
+	if _, ok := got["baseline"]; ok || got["heart_disease"] != 7 || got["ddi_amlodipine_simvastatin"] != 2 {
+		t.Fatalf("risk factors = %v", got)
+	}
+	if resp.RiskScoreNormalized != normalizeRiskScore(9, a.MaxRiskScore()) || a.MaxRiskScore() == MaxRiskScore() {
+		t.Fatalf("normalized %d against max %d", resp.RiskScoreNormalized, a.MaxRiskScore())
+	}
+}
+
+func TestLoadRulesFile_UnknownSeverity(t *testing.T) {
+	const doc = `{
+  "interactions": [
+    {"code": "DDI_AMLODIPINE_SIMVASTATIN", "drug": "amlodipine", "with": "simvastatin", "severity": "Warning", "riskDelta": 1},
+    {
+      "code": "DDI_ASPIRIN_WARFARIN",
+      "drug": "aspirin",
+      "with": "warfarin",
+      "severity": "waring",
+      "riskDelta": 2
+    }
+  ],
+  "doseCaps": [{"medication": "sildenafil", "maxMg": 0}]
+}`
+	path := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(path, []byte(doc), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	a := New()
+	err := a.LoadRulesFile(path)
+	var invalid *RulesetError
+	if !errors.As(err, &invalid) || len(invalid.Errors) != 2 {
+		t.Fatalf("err = %v, want two ruleset errors", err)
+	}
+	for _, want := range []string{path + `:8: interactions[1].severity must be danger, warning, or info, not "waring"`, path + ":12: doseCaps[0].maxMg"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("err = %v, want %q", err, want)
+		}
+	}
+	if a.Rules().Source != "embedded" {
+		t.Fatal("invalid rules file was loaded")
+	}
+
+	b, err := json.Marshal(Issue{Severity: SeverityDanger})
+	if err != nil || !strings.Contains(string(b), `"severity":"danger"`) {
+		t.Fatalf("issue JSON = %s (err %v)", b, err)
+	}
+	for _, c := range []struct {
+		in   string
+		want Severity
+	}{{"danger", SeverityDanger}, {" Info ", SeverityInfo}, {"waring", ""}} {
+		if got, err := types.ParseSeverity(c.in); got != c.want || (err != nil) != (c.want == "") {
+			t.Errorf("ParseSeverity(%q) = %q, %v", c.in, got, err)
+		}
+	}
+	if got, err := types.ParseRiskLevel("high"); got != RiskHigh || err != nil {
+		t.Errorf("ParseRiskLevel(high) = %q, %v", got, err)
+	}
+}
+
+func TestReplaceRules(t *testing.T) {
+	store := audit.NewMemoryStore()
+	a := New(WithAuditStore(store))
+	before := a.Rules()
+	if before.Source != "embedded" || before.Version == "" {
+		t.Fatalf("rules = %+v", before)
+	}
+
+	r := before.Ruleset
+	r.Interactions = append(r.Interactions, InteractionRule{Code: "DDI_ASPIRIN_WARFARIN", Drug: "Aspirin", With: "warfarin", Severity: "danger", Desc: "Bleeding risk.", RiskDelta: 2})
+	r.DoseCaps = []DoseCap{{Medication: "sildenafil", MaxMg: 50}}
+	path := filepath.Join(t.TempDir(), "rules.json")
+	info, err := a.ReplaceRules(context.Background(), r, path, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Version == before.Version || info.Source != path || a.Rules().Version != info.Version {
+		t.Fatalf("after replace: %+v, active %s", info, a.Rules().Version)
+	}
+
+	report := a.CheckInteractions(InteractionRequest{Medications: []Medication{{Name: "aspirin"}, {Name: "warfarin"}, {Name: "sildenafil", Dosage: "50mg"}}}, Options{})
+	got := pairCodes(report)
+	if codes := got["aspirin+warfarin"]; len(codes) != 1 || codes[0] != "DDI_ASPIRIN_WARFARIN" {
+		t.Fatalf("new rule not applied: %v", got)
+	}
+	if _, capped := got["sildenafil"]; capped {
+		t.Fatalf("raised dose cap not applied: %v", got)
+	}
+
+	changes, err := store.RulesChanges(context.Background())
+	if err != nil || len(changes) != 1 || changes[0].OldVersion != before.Version || changes[0].NewVersion != info.Version || changes[0].Actor != "admin" {
+		t.Fatalf("changes = %+v (err %v)", changes, err)
+	}
+
+	reloaded := New()
+	if err := reloaded.LoadRulesFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.Rules().Version != info.Version {
+		t.Fatalf("persisted version %s, want %s", reloaded.Rules().Version, info.Version)
+	}
+
+	r.DoseCaps = []DoseCap{{Medication: "sildenafil", MaxMg: -5}}
+	var invalid *RulesetError
+	if _, err := a.ReplaceRules(context.Background(), r, path, "admin"); !errors.As(err, &invalid) {
+		t.Fatalf("invalid ruleset: err = %v", err)
+	}
+	if a.Rules().Version != info.Version {
+		t.Fatal("invalid ruleset replaced the active one")
+	}
+	var onDisk Ruleset
+	if b, err := os.ReadFile(path); err != nil || json.Unmarshal(b, &onDisk) != nil || onDisk.DoseCaps[0].MaxMg != 50 {
+		t.Fatalf("invalid ruleset reached the file: %+v (err %v)", onDisk, err)
+	}
+}
@@ -0,0 +1,109 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/metrics"
+)
+
+var shadowRuns = metrics.NewCounter("llm_shadow_runs_total", "Shadow-mode LLM scoring calls by result.", "result")
+
+// maxShadowCalls bounds the shadow scoring calls in flight; further ones are
+// dropped and counted as llm_shadow_runs_total{result="dropped"}.
+const maxShadowCalls = 8
+
+// SetLLMShadowMode makes Analyze always return stub confidence while the
+// configured LLMClient is scored asynchronously and compared in the audit store.
+func (a *Analyzer) SetLLMShadowMode(enabled bool) {
+	_ = a.update(func(s *settings) error {
+		s.shadow = enabled
+		return nil
+	})
+}
+
+func SetLLMShadowMode(enabled bool) {
+	defaultAnalyzer.SetLLMShadowMode(enabled)
+}
+
+// ErrShadowUnsupported is returned when the audit store cannot hold shadow comparisons.
+var ErrShadowUnsupported = errors.New("audit store does not support llm shadow records")
+
+// LLMDivergence aggregates shadow comparisons recorded so far.
+func (a *Analyzer) LLMDivergence(ctx context.Context) (audit.DivergenceStats, error) {
+	store, ok := a.settings().store.(audit.ShadowStore)
+	if !ok {
+		return audit.DivergenceStats{}, ErrShadowUnsupported
+	}
+	return store.ShadowDivergence(ctx)
+}
+
+func LLMDivergence(ctx context.Context) (audit.DivergenceStats, error) {
+	return defaultAnalyzer.LLMDivergence(ctx)
+}
+
+// WaitShadow blocks until in-flight shadow scoring calls have been recorded.
+func (a *Analyzer) WaitShadow() {
+	a.shadowWG.Wait()
+}
+
+func WaitShadow() {
+	defaultAnalyzer.WaitShadow()
+}
+
+// startShadow scores req with the configured client off the request path; it
+// never touches the response. The context keeps request values but not its
+// cancellation. Both confidences are held to the risk band before they are
+// compared, as the live path holds the client's.
+func (a *Analyzer) startShadow(ctx context.Context, s settings, req ScoreRequest, stub LLMResult, auditID string) {
+	if _, isStub := s.llm.(StubLLM); isStub {
+		return
+	}
+	store, ok := s.store.(audit.ShadowStore)
+	if !ok || auditID == "" {
+		return
+	}
+	select {
+	case a.shadowSlots <- struct{}{}:
+	default:
+		shadowRuns.Inc("dropped")
+		return
+	}
+	a.shadowWG.Add(1)
+	go func() {
+		defer a.shadowWG.Done()
+		defer func() { <-a.shadowSlots }()
+		detached := context.WithoutCancel(ctx)
+		scoreCtx, cancel := context.WithTimeout(detached, s.llmTimeout)
+		defer cancel()
+
+		start := time.Now()
+		res, err := s.llm.Score(scoreCtx, req)
+		if err == nil {
+			err = checkLLMResult(res, len(req.Alternatives))
+		}
+		stub = holdToBand(stub, req.RiskLevel)
+		entry := audit.ShadowEntry{
+			AuditID:        auditID,
+			StubConfidence: stub.PlanConfidence,
+			Model:          res.Usage.Model,
+			LatencyMs:      time.Since(start).Milliseconds(),
+			At:             a.now().UTC(),
+		}
+		if err != nil {
+			shadowRuns.Inc("error")
+			entry.Err = err.Error()
+		} else {
+			shadowRuns.Inc("ok")
+			res = holdToBand(res, req.RiskLevel)
+			entry.LLMConfidence = res.PlanConfidence
+			entry.Delta = res.PlanConfidence - stub.PlanConfidence
+		}
+		if err := store.InsertShadow(detached, entry); err != nil {
+			log.Printf("llm shadow record failed audit_id=%s: %v", auditID, err)
+		}
+	}()
+}
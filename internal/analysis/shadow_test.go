@@ -0,0 +1,131 @@
+package analysis
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+var fixedClock = func() time.Time { return time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+var fixedID = audit.IDGeneratorFunc(func() string { return "audit-fixed" })
+
+func newShadowAnalyzer(t *testing.T, client LLMClient) (*Analyzer, *audit.MemoryStore) {
+	t.Helper()
+	store := audit.NewMemoryStore()
+	a := New(WithAuditStore(store), WithLLMClient(client), WithLLMTimeout(time.Second), WithClock(fixedClock), WithIDGenerator(fixedID))
+	a.SetLLMShadowMode(true)
+	t.Cleanup(a.WaitShadow)
+	return a, store
+}
+
+func responseJSON(t *testing.T, resp Response) []byte {
+	t.Helper()
+	out, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return out
+}
+
+func TestShadowMode_ResponseMatchesStub(t *testing.T) {
+	ctx := context.WithValue(context.Background(), ctxKey{}, "req-123")
+	want := responseJSON(t, New(WithClock(fixedClock), WithIDGenerator(fixedID)).AnalyzeContext(ctx, llmIntake, Options{}))
+
+	fake := &fakeLLM{
+		t:        t,
+		wantPlan: "Finasteride",
+		result:   LLMResult{PlanConfidence: 0.55, AlternativeConf: []float64{0.5, 0.4}},
+	}
+	a, store := newShadowAnalyzer(t, fake)
+
+	resp := a.AnalyzeContext(ctx, llmIntake, Options{})
+	if got := responseJSON(t, resp); !bytes.Equal(got, want) {
+		t.Fatalf("shadow mode changed the response:\n got %s\nwant %s", got, want)
+	}
+	a.WaitShadow()
+
+	stats, err := store.ShadowDivergence(t.Context())
+	if err != nil {
+		t.Fatalf("divergence: %v", err)
+	}
+	if stats.Samples != 1 || stats.Failures != 0 {
+		t.Fatalf("expected one successful shadow sample, got %+v", stats)
+	}
+	wantDelta := 0.55 - resp.PlanConfidence
+	if diff := stats.MeanDelta - wantDelta; diff > 1e-9 || diff < -1e-9 {
+		t.Fatalf("expected delta %.3f, got %.3f", wantDelta, stats.MeanDelta)
+	}
+}
+
+func TestShadowMode_RecordsFailures(t *testing.T) {
+	fake := &fakeLLM{t: t, wantPlan: "Finasteride", err: errors.New("upstream down")}
+	a, _ := newShadowAnalyzer(t, fake)
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "req-123")
+	resp := a.AnalyzeContext(ctx, llmIntake, Options{})
+	if hasIssue(resp.FlaggedIssues, "scoring") {
+		t.Fatalf("shadow failures must not surface in the response")
+	}
+	a.WaitShadow()
+
+	stats, err := a.LLMDivergence(t.Context())
+	if err != nil {
+		t.Fatalf("divergence: %v", err)
+	}
+	if stats.Samples != 0 || stats.Failures != 1 {
+		t.Fatalf("expected one failed shadow sample, got %+v", stats)
+	}
+}
+
+func TestShadowMode_DeltaComparesBandedConfidence(t *testing.T) {
+	fake := &fakeLLM{
+		t:        t,
+		wantPlan: "Finasteride",
+		result:   LLMResult{PlanConfidence: 0.99, AlternativeConf: []float64{0.5, 0.4}},
+	}
+	a, store := newShadowAnalyzer(t, fake)
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "req-123")
+	resp := a.AnalyzeContext(ctx, llmIntake, Options{})
+	a.WaitShadow()
+
+	stats, err := store.ShadowDivergence(t.Context())
+	if err != nil {
+		t.Fatalf("divergence: %v", err)
+	}
+	// The live path would have held 0.99 to the LOW band's ceiling.
+	wantDelta := confidenceBands[resp.RiskLevel].Ceiling - resp.PlanConfidence
+	if diff := stats.MeanDelta - wantDelta; diff > 1e-9 || diff < -1e-9 {
+		t.Fatalf("expected delta %.3f, got %.3f", wantDelta, stats.MeanDelta)
+	}
+}
+
+// blockingLLM holds every Score call until release is closed.
+type blockingLLM struct{ release chan struct{} }
+
+func (b blockingLLM) Score(ctx context.Context, req ScoreRequest) (LLMResult, error) {
+	<-b.release
+	return LLMResult{PlanConfidence: 0.6, AlternativeConf: make([]float64, len(req.Alternatives))}, nil
+}
+
+func TestShadowMode_DropsCallsOverLimit(t *testing.T) {
+	client := blockingLLM{release: make(chan struct{})}
+	a := New(WithLLMClient(client), WithLLMTimeout(time.Second))
+	a.SetLLMShadowMode(true)
+	defer a.WaitShadow()
+	defer close(client.release)
+
+	before := shadowRuns.Value("dropped")
+	for range maxShadowCalls + 2 {
+		a.Analyze(llmIntake)
+	}
+	if got := shadowRuns.Value("dropped") - before; got != 2 {
+		t.Fatalf("expected 2 dropped shadow calls, got %v", got)
+	}
+}
@@ -0,0 +1,81 @@
+package analysis
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/signing"
+)
+
+// SetSigningKey signs each audited response with key, recording the
+// signature with the audit, and has SignResponse sign responses on request.
+// An empty keyID derives one from the public key; a nil key turns signing
+// off.
+func (a *Analyzer) SetSigningKey(keyID string, key ed25519.PrivateKey) error {
+	var k *signing.Key
+	if key != nil {
+		var err error
+		if k, err = signing.NewKey(keyID, key); err != nil {
+			return err
+		}
+	}
+	return a.update(func(s *settings) error {
+		s.signer = k
+		return nil
+	})
+}
+
+func SetSigningKey(keyID string, key ed25519.PrivateKey) error {
+	return defaultAnalyzer.SetSigningKey(keyID, key)
+}
+
+// SigningKey returns the public half of the signing key, or false when
+// signing is off.
+func (a *Analyzer) SigningKey() (PublicKey, bool) {
+	k := a.settings().signer
+	if k == nil {
+		return PublicKey{}, false
+	}
+	return PublicKey{
+		KeyID:     k.ID(),
+		Algorithm: signing.Algorithm,
+		PublicKey: base64.StdEncoding.EncodeToString(k.Public()),
+	}, true
+}
+
+func SigningKey() (PublicKey, bool) {
+	return defaultAnalyzer.SigningKey()
+}
+
+// SignResponse signs the canonical JSON of resp as it is about to be sent.
+// It returns nil when signing is off.
+func (a *Analyzer) SignResponse(resp Response) (*Signature, error) {
+	k := a.settings().signer
+	if k == nil {
+		return nil, nil
+	}
+	doc, err := json.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	value, err := k.Sign(doc)
+	if err != nil {
+		return nil, err
+	}
+	return &Signature{KeyID: k.ID(), Algorithm: signing.Algorithm, Value: value}, nil
+}
+
+// auditSignature signs body, the response stored with an audit; nil when
+// signing is off.
+func auditSignature(s settings, body []byte) (*audit.Signature, error) {
+	if s.signer == nil {
+		return nil, nil
+	}
+	value, err := s.signer.Sign(body)
+	if err != nil {
+		return nil, err
+	}
+	return &audit.Signature{KeyID: s.signer.ID(), Algorithm: signing.Algorithm, Value: value}, nil
+}
@@ -0,0 +1,309 @@
+package analysis
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/latency"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/metrics"
+	"github.com/Skufu/Clinical-AI-Assistant/types"
+)
+
+// Latency types.
+type (
+	LatencyStats  = types.LatencyStats
+	LatencySLO    = types.LatencySLO
+	LatencyWindow = types.LatencyWindow
+)
+
+// Latency window scopes.
+const (
+	LatencyAll       = "all"
+	LatencyComplaint = "complaint"
+	LatencyRisk      = "risk"
+)
+
+const (
+	// LatencyWindowAge is how far back the rolling latency windows look.
+	LatencyWindowAge = 15 * time.Minute
+	// latencyWindowSize bounds the samples each window keeps.
+	latencyWindowSize = 1000
+	// DefaultSLOMinSamples is the fewest samples a window needs to be judged
+	// against the SLO when SLO.MinSamples is zero.
+	DefaultSLOMinSamples = 20
+	// otherPathway keys analyses whose primary complaint has no dedicated
+	// plan.
+	otherPathway = "other"
+)
+
+var (
+	latencyQuantiles = metrics.NewGaugeVec("analysis_latency_seconds", "Analysis latency over the rolling window by scope (all, complaint, risk), key, and quantile, as of the last latency check.", "scope", "key", "quantile")
+	sloBreaching     = metrics.NewGaugeVec("analysis_slo_breaching", "1 while a rolling latency window is over the SLO, by scope and key.", "scope", "key")
+	sloAlerts        = metrics.NewCounter("analysis_slo_alerts_total", "Latency SLO breach alerts raised, by scope.", "scope")
+)
+
+// ErrInvalidSLO is returned by SetSLO for an objective it cannot judge.
+var ErrInvalidSLO = errors.New("invalid latency SLO")
+
+// SLO is an analysis latency objective: the Percentile-th percentile of each
+// rolling window stays at or under Threshold. The zero SLO sets none.
+type SLO struct {
+	Percentile float64
+	Threshold  time.Duration
+	// Sustain is how long a window must stay over the objective before it
+	// alerts; zero alerts on the first check that finds it over.
+	Sustain time.Duration
+	// MinSamples is the fewest samples a window needs to be judged; zero is
+	// DefaultSLOMinSamples.
+	MinSamples int
+}
+
+// SLOBreach describes the breach reported by an EventSLOBreach.
+type SLOBreach struct {
+	Scope       string    `json:"scope"`
+	Key         string    `json:"key,omitempty"`
+	Percentile  float64   `json:"percentile"`
+	ThresholdMs float64   `json:"thresholdMs"`
+	ObservedMs  float64   `json:"observedMs"`
+	Samples     int       `json:"samples"`
+	Since       time.Time `json:"since"`
+}
+
+func (o SLO) set() bool {
+	return o.Threshold > 0
+}
+
+func (o SLO) minSamples() int {
+	if o.MinSamples > 0 {
+		return o.MinSamples
+	}
+	return DefaultSLOMinSamples
+}
+
+// SetSLO sets the latency objective CheckLatency judges the rolling windows
+// against; the zero SLO removes it. A Percentile outside (0, 100], a
+// Threshold that is not positive, or a negative Sustain or MinSamples fails
+// with ErrInvalidSLO.
+func (a *Analyzer) SetSLO(o SLO) error {
+	if o != (SLO{}) {
+		switch {
+		case o.Percentile <= 0 || o.Percentile > 100:
+			return fmt.Errorf("%w: percentile %v is not in (0, 100]", ErrInvalidSLO, o.Percentile)
+		case o.Threshold <= 0:
+			return fmt.Errorf("%w: threshold must be positive", ErrInvalidSLO)
+		case o.Sustain < 0 || o.MinSamples < 0:
+			return fmt.Errorf("%w: sustain and minimum samples cannot be negative", ErrInvalidSLO)
+		}
+	}
+	return a.update(func(s *settings) error {
+		s.slo = o
+		return nil
+	})
+}
+
+func SetSLO(o SLO) error {
+	return defaultAnalyzer.SetSLO(o)
+}
+
+// latencyKey names one rolling window.
+type latencyKey struct {
+	scope, key string
+}
+
+// latencyState is one rolling window and where it stands against the SLO.
+type latencyState struct {
+	window *latency.Window
+	// breachSince is when checks began finding the window over the SLO; zero
+	// while it is within it. alerted is set once the breach has alerted.
+	breachSince time.Time
+	alerted     bool
+}
+
+// latencyTracker keeps the rolling windows of an Analyzer, created as
+// complaint pathways and risk levels are first seen.
+type latencyTracker struct {
+	mu      sync.Mutex
+	windows map[latencyKey]*latencyState
+}
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{windows: map[latencyKey]*latencyState{}}
+}
+
+func (t *latencyTracker) observe(at time.Time, d time.Duration, keys ...latencyKey) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, k := range keys {
+		st, ok := t.windows[k]
+		if !ok {
+			st = &latencyState{window: latency.NewWindow(latencyWindowSize, LatencyWindowAge)}
+			t.windows[k] = st
+		}
+		st.window.Add(at, d)
+	}
+}
+
+// sorted returns the tracker's keys, overall first, then by scope and key.
+// The caller holds t.mu.
+func (t *latencyTracker) sorted() []latencyKey {
+	order := map[string]int{LatencyAll: 0, LatencyComplaint: 1, LatencyRisk: 2}
+	keys := make([]latencyKey, 0, len(t.windows))
+	for k := range t.windows {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(x, y latencyKey) int {
+		return cmp.Or(cmp.Compare(order[x.scope], order[y.scope]), cmp.Compare(x.key, y.key))
+	})
+	return keys
+}
+
+// observeLatency adds d, how long the analysis of in took, to the overall
+// window and those of its complaint pathway and risk level. Rejected intakes
+// are left out, as they never reach scoring.
+func (a *Analyzer) observeLatency(in Intake, resp Response, d time.Duration) {
+	if resp.RiskLevel == RiskInvalid {
+		return
+	}
+	a.latency.observe(a.now(), d,
+		latencyKey{LatencyAll, ""},
+		latencyKey{LatencyComplaint, complaintPathway(in)},
+		latencyKey{LatencyRisk, string(resp.RiskLevel)},
+	)
+}
+
+// complaintPathway is the lower-cased primary complaint of in when it has a
+// dedicated plan, and otherPathway otherwise, so windows stay few.
+func complaintPathway(in Intake) string {
+	if cs := intakeComplaints(in); len(cs) > 0 && slices.Contains(complaintPriority, strings.ToLower(cs[0])) {
+		return strings.ToLower(cs[0])
+	}
+	return otherPathway
+}
+
+// Latency summarizes the rolling latency windows, with where each stood at
+// the last CheckLatency. Windows are listed overall first, then per
+// complaint pathway and risk level; they span every org.
+func (a *Analyzer) Latency() LatencyStats {
+	s := a.settings()
+	now := a.now()
+	out := LatencyStats{WindowSeconds: int(LatencyWindowAge / time.Second), Windows: []LatencyWindow{}}
+	if o := s.slo; o.set() {
+		out.SLO = &LatencySLO{
+			Percentile:     o.Percentile,
+			ThresholdMs:    durationMillis(o.Threshold),
+			SustainSeconds: o.Sustain.Seconds(),
+			MinSamples:     o.minSamples(),
+		}
+	}
+	t := a.latency
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, k := range t.sorted() {
+		st := t.windows[k]
+		sum := st.window.Summary(now)
+		w := LatencyWindow{
+			Scope: k.scope,
+			Key:   k.key,
+			Count: sum.Count,
+			P50Ms: durationMillis(sum.P50),
+			P95Ms: durationMillis(sum.P95),
+			P99Ms: durationMillis(sum.P99),
+			MaxMs: durationMillis(sum.Max),
+		}
+		if !st.breachSince.IsZero() {
+			w.Breaching, w.BreachingSince = true, st.breachSince.UTC().Format(time.RFC3339)
+		}
+		out.Windows = append(out.Windows, w)
+	}
+	return out
+}
+
+func Latency() LatencyStats {
+	return defaultAnalyzer.Latency()
+}
+
+// CheckLatency updates the analysis_latency_seconds gauges from the rolling
+// windows and judges each against the SLO, if one is set. A window over the
+// objective for the SLO's Sustain raises one EventSLOBreach to the
+// notifiers; it alerts again only after a check finds it back within the
+// objective. It returns the number of alerts raised.
+func (a *Analyzer) CheckLatency(ctx context.Context) int {
+	s := a.settings()
+	now := a.now()
+	t := a.latency
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	alerts := 0
+	for _, k := range t.sorted() {
+		st := t.windows[k]
+		sum := st.window.Summary(now)
+		latencyQuantiles.Set(sum.P50.Seconds(), k.scope, k.key, "0.5")
+		latencyQuantiles.Set(sum.P95.Seconds(), k.scope, k.key, "0.95")
+		latencyQuantiles.Set(sum.P99.Seconds(), k.scope, k.key, "0.99")
+
+		observed, _ := st.window.Percentile(now, s.slo.Percentile)
+		if !s.slo.set() || sum.Count < s.slo.minSamples() || observed <= s.slo.Threshold {
+			st.breachSince, st.alerted = time.Time{}, false
+			sloBreaching.Set(0, k.scope, k.key)
+			continue
+		}
+		sloBreaching.Set(1, k.scope, k.key)
+		if st.breachSince.IsZero() {
+			st.breachSince = now
+		}
+		if st.alerted || now.Sub(st.breachSince) < s.slo.Sustain {
+			continue
+		}
+		st.alerted = true
+		alerts++
+		sloAlerts.Inc(k.scope)
+		log.Printf("warn: latency SLO breached scope=%s key=%s p%v=%s threshold=%s samples=%d since=%s",
+			k.scope, k.key, s.slo.Percentile, observed.Round(time.Millisecond), s.slo.Threshold, sum.Count, st.breachSince.UTC().Format(time.RFC3339))
+		e := Event{
+			Kind: EventSLOBreach,
+			At:   now.UTC(),
+			SLO: &SLOBreach{
+				Scope:       k.scope,
+				Key:         k.key,
+				Percentile:  s.slo.Percentile,
+				ThresholdMs: durationMillis(s.slo.Threshold),
+				ObservedMs:  durationMillis(observed),
+				Samples:     sum.Count,
+				Since:       st.breachSince.UTC(),
+			},
+		}
+		for _, n := range s.notifiers {
+			a.notify(ctx, n, e)
+		}
+	}
+	return alerts
+}
+
+func CheckLatency(ctx context.Context) int {
+	return defaultAnalyzer.CheckLatency(ctx)
+}
+
+// RunLatencyChecks calls CheckLatency every interval until ctx is done.
+func (a *Analyzer) RunLatencyChecks(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			a.CheckLatency(ctx)
+		}
+	}
+}
+
+func RunLatencyChecks(ctx context.Context, every time.Duration) {
+	defaultAnalyzer.RunLatencyChecks(ctx, every)
+}
@@ -0,0 +1,128 @@
+package analysis
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCheckLatency_SustainedBreach(t *testing.T) {
+	now := fixedClock()
+	n := &recordingNotifier{}
+	a := New(WithClock(func() time.Time { return now }), WithNotifier(n))
+	if err := a.SetSLO(SLO{Percentile: 95, Threshold: 500 * time.Millisecond, Sustain: 5 * time.Minute, MinSamples: 10}); err != nil {
+		t.Fatal(err)
+	}
+	ed := Intake{Complaint: "ED"}
+	hair := Intake{Complaint: "Hair Loss"}
+	for range 10 {
+		a.observeLatency(ed, Response{RiskLevel: RiskHigh}, 900*time.Millisecond)
+		a.observeLatency(hair, Response{RiskLevel: RiskLow}, 100*time.Millisecond)
+	}
+	a.observeLatency(Intake{}, Response{RiskLevel: RiskInvalid}, time.Hour)
+
+	if got := a.CheckLatency(t.Context()); got != 0 {
+		t.Fatalf("first breaching check alerted %d times, want a sustained breach first", got)
+	}
+	stats := a.Latency()
+	want := []struct {
+		scope, key string
+		breaching  bool
+	}{
+		// Overall p95 is 900ms across the 20 samples.
+		{LatencyAll, "", true},
+		{LatencyComplaint, "ed", true},
+		{LatencyComplaint, "hair loss", false},
+		{LatencyRisk, "HIGH", true},
+		{LatencyRisk, "LOW", false},
+	}
+	if len(stats.Windows) != len(want) || stats.SLO == nil || stats.SLO.ThresholdMs != 500 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	for i, w := range want {
+		got := stats.Windows[i]
+		if got.Scope != w.scope || got.Key != w.key || got.Breaching != w.breaching || got.Count != 10 && w.scope != LatencyAll {
+			t.Errorf("window %d = %+v, want %v", i, got, w)
+		}
+	}
+	if latencyQuantiles.Value(LatencyComplaint, "ed", "0.95") != 0.9 || sloBreaching.Value(LatencyRisk, "HIGH") != 1 || sloBreaching.Value(LatencyRisk, "LOW") != 0 {
+		t.Fatal("latency gauges not updated by the check")
+	}
+
+	now = now.Add(5 * time.Minute)
+	if got := a.CheckLatency(t.Context()); got != 3 {
+		t.Fatalf("sustained breach raised %d alerts, want 3", got)
+	}
+	if got := a.CheckLatency(t.Context()); got != 0 {
+		t.Fatalf("ongoing breach alerted again %d times", got)
+	}
+	a.WaitNotifications()
+	if len(n.events) != 3 {
+		t.Fatalf("events = %+v", n.events)
+	}
+	// Delivery is concurrent, so the events arrive in any order.
+	i := slices.IndexFunc(n.events, func(e Event) bool { return e.SLO != nil && e.SLO.Key == "ed" })
+	if i < 0 {
+		t.Fatalf("no alert for the ed pathway: %+v", n.events)
+	}
+	e := n.events[i]
+	if e.Kind != EventSLOBreach || e.AuditID != "" || e.SLO == nil || e.SLO.Scope != LatencyComplaint || e.SLO.Key != "ed" ||
+		e.SLO.ObservedMs != 900 || e.SLO.Samples != 10 || !e.SLO.Since.Equal(fixedClock()) {
+		t.Fatalf("event = %+v (slo %+v)", e, e.SLO)
+	}
+
+	// Once the slow samples age out the windows recover and re-arm.
+	now = now.Add(LatencyWindowAge)
+	a.CheckLatency(t.Context())
+	if w := a.Latency().Windows[0]; w.Breaching || w.Count != 0 {
+		t.Fatalf("after the window aged out = %+v", w)
+	}
+}
+
+func TestCheckLatency_MinSamples(t *testing.T) {
+	a := New()
+	if err := a.SetSLO(SLO{Percentile: 99, Threshold: time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	for range DefaultSLOMinSamples - 1 {
+		a.observeLatency(Intake{Complaint: "ED"}, Response{RiskLevel: RiskLow}, time.Second)
+	}
+	if got := a.CheckLatency(t.Context()); got != 0 || a.Latency().Windows[0].Breaching {
+		t.Fatalf("judged a window under the minimum samples: %d alerts", got)
+	}
+}
+
+func TestSetSLO_Invalid(t *testing.T) {
+	a := New()
+	for _, o := range []SLO{
+		{Percentile: 0, Threshold: time.Second},
+		{Percentile: 101, Threshold: time.Second},
+		{Percentile: 95},
+		{Percentile: 95, Threshold: time.Second, Sustain: -time.Minute},
+	} {
+		if err := a.SetSLO(o); !errors.Is(err, ErrInvalidSLO) {
+			t.Errorf("SetSLO(%+v) = %v", o, err)
+		}
+	}
+	if err := a.SetSLO(SLO{}); err != nil || a.Latency().SLO != nil {
+		t.Fatalf("clearing the SLO: %v", err)
+	}
+}
+
+func TestComplaintPathway(t *testing.T) {
+	for _, c := range []struct {
+		in   Intake
+		want string
+	}{
+		{Intake{Complaint: "ED"}, "ed"},
+		{Intake{Complaint: "fatigue", Complaints: []string{"ED"}}, "ed"},
+		{Intake{Complaint: "Weight Loss"}, "weight loss"},
+		{Intake{Complaint: "fatigue"}, otherPathway},
+		{Intake{}, otherPathway},
+	} {
+		if got := complaintPathway(c.in); got != c.want {
+			t.Errorf("complaintPathway(%+v) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
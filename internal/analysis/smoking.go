@@ -0,0 +1,109 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	// heavyPackYears is the pack-year history above which smoking adds risk
+	// even after the patient quit.
+	heavyPackYears = 20
+	// recentQuitYears is how long after quitting a former smoker keeps part
+	// of a current smoker's risk.
+	recentQuitYears = 1
+	// maxPackYears bounds a plausible pack-year history.
+	maxPackYears = 300
+)
+
+// smokingStatus reads Intake.Smoking as current, former, or never, or ""
+// when it is none of those. A quit time without a status means a former
+// smoker.
+func smokingStatus(in Intake) string {
+	switch s := normalizeName(in.Smoking); s {
+	case "current", "former", "never":
+		return s
+	}
+	if in.SmokingQuitYearsAgo != nil {
+		return "former"
+	}
+	return ""
+}
+
+// assessSmoking scores the smoking history. A current smoker adds
+// smoking_current and a former smoker who quit under a year ago the smaller
+// smoking_recent_quit; more than 20 pack-years adds smoking_heavy_history
+// either way. Each case gets one issue describing the specifics.
+func assessSmoking(in Intake, risk *riskAccumulator, l localizer) []Issue {
+	status := smokingStatus(in)
+	heavy := in.SmokingPackYears > heavyPackYears
+	// Empty values render as absent in the issue templates.
+	data := map[string]any{"PackYears": "", "QuitYears": "", "Months": 0}
+	if in.SmokingPackYears > 0 {
+		data["PackYears"] = formatQuantity(in.SmokingPackYears)
+	}
+	var issues []Issue
+	inputs := func() map[string]any {
+		out := map[string]any{"status": status, "packYears": in.SmokingPackYears}
+		if q := in.SmokingQuitYearsAgo; q != nil {
+			out["quitYearsAgo"] = *q
+		}
+		return out
+	}
+	switch {
+	case risk.check("smoking_current", "LIFESTYLE_SMOKING", status == "current", inputs):
+		risk.add("smoking_current", "Current smoker")
+		issues = append(issues, newIssue("LIFESTYLE_SMOKING", SeverityInfo, l.issue("LIFESTYLE_SMOKING", data)))
+	case risk.check("smoking_recent_quit", "LIFESTYLE_SMOKING_RECENT_QUIT", status == "former" && in.SmokingQuitYearsAgo != nil && *in.SmokingQuitYearsAgo < recentQuitYears, inputs):
+		risk.add("smoking_recent_quit", "Quit smoking less than a year ago")
+		data["Months"] = int(math.Round(*in.SmokingQuitYearsAgo * 12))
+		issues = append(issues, newIssue("LIFESTYLE_SMOKING_RECENT_QUIT", SeverityInfo, l.issue("LIFESTYLE_SMOKING_RECENT_QUIT", data)))
+	case status == "former" && heavy:
+		if q := in.SmokingQuitYearsAgo; q != nil {
+			data["QuitYears"] = formatQuantity(*q)
+		}
+		issues = append(issues, newIssue("LIFESTYLE_SMOKING_HISTORY", SeverityInfo, l.issue("LIFESTYLE_SMOKING_HISTORY", data)))
+	}
+	if risk.check("smoking_heavy_history", "", heavy && status != "never", inputs) {
+		risk.add("smoking_heavy_history", fmt.Sprintf("Heavy smoking history (%s pack-years)", formatQuantity(in.SmokingPackYears)))
+	}
+	return issues
+}
+
+// smokingErrors rejects out-of-range pack-years and quit times, and quit
+// times or pack-years that contradict the smoking status.
+func smokingErrors(in Intake) []FieldError {
+	var errs []FieldError
+	if p := in.SmokingPackYears; p < 0 || p > maxPackYears {
+		errs = append(errs, FieldError{Field: "smokingPackYears", Code: "out_of_range", Message: fmt.Sprintf("smokingPackYears must be between 0 and %d, not %s", maxPackYears, formatQuantity(p))})
+	}
+	if q := in.SmokingQuitYearsAgo; q != nil {
+		switch {
+		case *q < 0:
+			errs = append(errs, FieldError{Field: "smokingQuitYearsAgo", Code: "out_of_range", Message: fmt.Sprintf("smokingQuitYearsAgo must not be negative, not %s", formatQuantity(*q))})
+		case in.Age > 0 && *q > float64(in.Age):
+			errs = append(errs, FieldError{Field: "smokingQuitYearsAgo", Code: "out_of_range", Message: fmt.Sprintf("smokingQuitYearsAgo %s exceeds age %d", formatQuantity(*q), in.Age)})
+		}
+	}
+	switch smokingStatus(in) {
+	case "current":
+		if in.SmokingQuitYearsAgo != nil {
+			errs = append(errs, FieldError{Field: "smokingQuitYearsAgo", Code: "contradictory", Message: "smokingQuitYearsAgo is set but smoking is current; clear one of them"})
+		}
+	case "never":
+		if in.SmokingQuitYearsAgo != nil {
+			errs = append(errs, FieldError{Field: "smokingQuitYearsAgo", Code: "contradictory", Message: "smokingQuitYearsAgo is set but smoking is never; record smoking as former"})
+		}
+		if in.SmokingPackYears > 0 {
+			errs = append(errs, FieldError{Field: "smokingPackYears", Code: "contradictory", Message: "smokingPackYears is set but smoking is never; record smoking as former or current"})
+		}
+	}
+	return errs
+}
+
+// formatQuantity renders a count of years, pack-years, or drinks without
+// trailing zeros: 20, 0.5, 12.25.
+func formatQuantity(v float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", v), "0"), ".")
+}
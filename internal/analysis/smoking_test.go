@@ -0,0 +1,87 @@
+package analysis
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAnalyze_SmokingHistory(t *testing.T) {
+	years := func(v float64) *float64 { return &v }
+	cases := []struct {
+		name      string
+		smoking   string
+		packYears float64
+		quit      *float64
+		factors   []string
+		issue     string
+		text      string
+	}{
+		{"current", "Current", 0, nil, []string{"smoking_current"}, "LIFESTYLE_SMOKING", "Current smoker—"},
+		{"current heavy", "current", 30, nil, []string{"smoking_current", "smoking_heavy_history"}, "LIFESTYLE_SMOKING", "(30 pack-years)"},
+		{"quit two months ago", "Former", 10, years(2.0 / 12), []string{"smoking_recent_quit"}, "LIFESTYLE_SMOKING_RECENT_QUIT", "quit 2 month(s) ago after 10 pack-years"},
+		{"quit twenty years ago", "Former", 10, years(20), nil, "", ""},
+		{"heavy former", "Former", 40, years(20), []string{"smoking_heavy_history"}, "LIFESTYLE_SMOKING_HISTORY", "40 pack-years, quit 20 year(s) ago"},
+		{"quit time without status", "", 0, years(0.5), []string{"smoking_recent_quit"}, "LIFESTYLE_SMOKING_RECENT_QUIT", "quit 6 month(s) ago—"},
+		{"never", "Never", 0, nil, nil, "", ""},
+	}
+	a := New()
+	for _, tc := range cases {
+		in := llmIntake
+		in.Smoking, in.SmokingPackYears, in.SmokingQuitYearsAgo = tc.smoking, tc.packYears, tc.quit
+		resp := a.Analyze(in)
+		if len(resp.ValidationErrors) > 0 {
+			t.Fatalf("%s: %v", tc.name, resp.ValidationErrors)
+		}
+		var factors []string
+		for _, f := range resp.RiskFactors {
+			if strings.HasPrefix(f.Code, "smoking") {
+				factors = append(factors, f.Code)
+			}
+		}
+		if !slices.Equal(factors, tc.factors) {
+			t.Errorf("%s: smoking factors %v, want %v", tc.name, factors, tc.factors)
+		}
+		var smoking []Issue
+		for _, i := range resp.FlaggedIssues {
+			if strings.HasPrefix(i.Code, "LIFESTYLE_SMOKING") {
+				smoking = append(smoking, i)
+			}
+		}
+		switch {
+		case tc.issue == "" && len(smoking) > 0:
+			t.Errorf("%s: unexpected issues %+v", tc.name, smoking)
+		case tc.issue != "" && (len(smoking) != 1 || smoking[0].Code != tc.issue || !strings.Contains(smoking[0].Description, tc.text)):
+			t.Errorf("%s: issues %+v, want one %s saying %q", tc.name, smoking, tc.issue, tc.text)
+		}
+	}
+}
+
+func TestSmokingErrors(t *testing.T) {
+	years := func(v float64) *float64 { return &v }
+	cases := []struct {
+		name  string
+		edit  func(*Intake)
+		field string
+		code  string
+	}{
+		{"current with quit time", func(in *Intake) { in.Smoking, in.SmokingQuitYearsAgo = "Current", years(2) }, "smokingQuitYearsAgo", "contradictory"},
+		{"never with quit time", func(in *Intake) { in.Smoking, in.SmokingQuitYearsAgo = "never", years(0) }, "smokingQuitYearsAgo", "contradictory"},
+		{"never with pack-years", func(in *Intake) { in.Smoking, in.SmokingPackYears = "Never", 5 }, "smokingPackYears", "contradictory"},
+		{"negative pack-years", func(in *Intake) { in.SmokingPackYears = -1 }, "smokingPackYears", "out_of_range"},
+		{"quit before birth", func(in *Intake) { in.Smoking, in.SmokingQuitYearsAgo = "Former", years(50) }, "smokingQuitYearsAgo", "out_of_range"},
+	}
+	for _, tc := range cases {
+		in := llmIntake
+		tc.edit(&in)
+		errs := smokingErrors(in)
+		if len(errs) != 1 || errs[0].Field != tc.field || errs[0].Code != tc.code {
+			t.Errorf("%s: errors %+v, want one %s on %s", tc.name, errs, tc.code, tc.field)
+		}
+	}
+	in := llmIntake
+	in.Smoking, in.SmokingPackYears, in.SmokingQuitYearsAgo = "Former", 25, years(0)
+	if errs := smokingErrors(in); len(errs) != 0 {
+		t.Fatalf("former smoker who just quit: %+v", errs)
+	}
+}
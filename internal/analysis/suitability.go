@@ -0,0 +1,137 @@
+package analysis
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Suitability penalties per finding on an alternative. A danger finding
+// removes the alternative instead.
+var suitabilityPenalty = map[Severity]float64{
+	SeverityWarning: 0.2,
+	SeverityInfo:    0.05,
+}
+
+// minSuitability is the lowest score an alternative that survives the checks
+// can have.
+const minSuitability = 0.1
+
+// conditionCautions are condition-specific cautions for alternatives that
+// the plan builders only handle for the plan itself. Members match the
+// alternative's medication by substring; Key names the suitability.<Key>
+// message.
+var conditionCautions = []struct {
+	Condition string
+	Members   []string
+	Key       string
+}{
+	{condKidneyDisease, []string{"metformin"}, "renal_metformin"},
+	{condKidneyDisease, []string{"tadalafil (daily)"}, "renal_tadalafil_daily"},
+	{condLiverDisease, []string{"metformin"}, "hepatic_metformin"},
+	{condLiverDisease, []string{"tadalafil (daily)"}, "hepatic_tadalafil_daily"},
+}
+
+// suitabilityChecks holds what an alternative is checked against: the
+// patient's medications together with the other complaints' plans, but not
+// the plan the alternative would replace.
+type suitabilityChecks struct {
+	Regimen      map[string]bool
+	Conditions   map[string]bool
+	Allergies    []Allergy
+	HasNitrate   bool
+	NitratePRN   bool
+	HeavyAlcohol bool
+	Rules        []InteractionRule
+	Classes      []DrugClass
+	Localizer    localizer
+}
+
+type suitabilityFinding struct {
+	Severity Severity
+	Text     string
+}
+
+// findings runs the plan's contraindication, interaction, allergy, and
+// duplicate-therapy checks on one alternative, plus conditionCautions.
+func (c suitabilityChecks) findings(medication string) []suitabilityFinding {
+	l := c.Localizer
+	name := normalizeName(medication)
+	var out []suitabilityFinding
+	add := func(severity Severity, text string) {
+		out = append(out, suitabilityFinding{Severity: severity, Text: text})
+	}
+
+	if usesPDE5(name) {
+		if c.HasNitrate {
+			add(SeverityDanger, nitrateIssue(c.NitratePRN, l).Description)
+		}
+		if c.Regimen["amlodipine"] {
+			add(SeverityWarning, l.issue("DDI_PDE5_AMLODIPINE", nil))
+		}
+		if c.Regimen["tamsulosin"] {
+			add(SeverityWarning, l.issue("DDI_PDE5_TAMSULOSIN", nil))
+		}
+		if c.Conditions[condHeartDisease] {
+			add(SeverityWarning, l.issue("CARDIAC_CLEARANCE_PDE5", nil))
+		}
+		if c.HeavyAlcohol {
+			add(SeverityInfo, l.issue("DDI_PDE5_ALCOHOL", nil))
+		}
+	}
+	if allergy, ok := intersectsAllergy(c.Allergies, name); ok {
+		add(SeverityDanger, l.issue("ALLERGY_ALTERNATIVE", map[string]any{"Medication": medication, "Allergy": allergy.Substance}))
+	}
+	for _, rule := range c.Rules {
+		if (strings.Contains(name, rule.Drug) && c.Regimen[rule.With]) || (strings.Contains(name, rule.With) && c.Regimen[rule.Drug]) {
+			add(rule.Severity, ruleText(rule, l))
+		}
+	}
+	for _, class := range c.Classes {
+		if !class.has(name) {
+			continue
+		}
+		for _, other := range matchingMedications(c.Regimen, class.Members) {
+			if other != name {
+				add(SeverityWarning, l.issue("DUP_THERAPY", map[string]any{"First": other, "Second": name, "Class": class.Name}))
+			}
+		}
+	}
+	for _, caution := range conditionCautions {
+		if c.Conditions[caution.Condition] && slices.ContainsFunc(caution.Members, func(m string) bool { return strings.Contains(name, m) }) {
+			add(SeverityWarning, l.text("suitability."+caution.Key, nil, ""))
+		}
+	}
+	return out
+}
+
+// rankAlternatives drops alternatives with a danger finding, scores the rest
+// from 1 down by suitabilityPenalty, and orders them by score, keeping the
+// authored order among equals. The score is stored in Confidence, where it
+// caps what the scorer may report later, and the findings in Suitability.
+func rankAlternatives(alts []Alternative, c suitabilityChecks) []Alternative {
+	out := make([]Alternative, 0, len(alts))
+	for _, alt := range alts {
+		findings := c.findings(alt.Medication)
+		if slices.ContainsFunc(findings, func(f suitabilityFinding) bool { return f.Severity == SeverityDanger }) {
+			continue
+		}
+		score := 1.0
+		texts := make([]string, 0, len(findings))
+		for _, f := range findings {
+			score -= suitabilityPenalty[f.Severity]
+			texts = append(texts, f.Text)
+		}
+		if len(texts) == 0 {
+			texts = append(texts, c.Localizer.text("suitability.clear", nil, ""))
+		}
+		alt.Confidence = math.Round(max(score, minSuitability)*100) / 100
+		alt.Suitability = strings.Join(texts, " ")
+		out = append(out, alt)
+	}
+	slices.SortStableFunc(out, func(a, b Alternative) int {
+		return cmp.Compare(b.Confidence, a.Confidence)
+	})
+	return out
+}
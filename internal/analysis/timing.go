@@ -0,0 +1,81 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+// Pipeline stages timed by Analyze, as reported in Response.Timings.
+const (
+	stageValidation       = "validation"
+	stagePlanBuild        = "planBuild"
+	stageRules            = "rules"
+	stageLLMScoring       = "llmScoring"
+	stageAuditInsert      = "auditInsert"
+	stageSchemaValidation = "schemaValidation"
+	stageTotal            = "total"
+)
+
+// stageTimer measures pipeline stages with the analyzer's clock, so tests
+// with an injected clock see deterministic durations.
+type stageTimer struct {
+	now    func() time.Time
+	start  time.Time
+	stages map[string]time.Duration
+}
+
+func newStageTimer(now func() time.Time) *stageTimer {
+	return &stageTimer{now: now, start: now(), stages: map[string]time.Duration{}}
+}
+
+// begin starts timing stage; the returned func stops it. A stage timed more
+// than once accumulates.
+func (t *stageTimer) begin(stage string) func() {
+	start := t.now()
+	return func() {
+		t.stages[stage] += t.now().Sub(start)
+	}
+}
+
+// elapsed is the time since the analysis started.
+func (t *stageTimer) elapsed() time.Duration {
+	return t.now().Sub(t.start)
+}
+
+// millis reports every stage and the total so far in milliseconds, to the
+// microsecond.
+func (t *stageTimer) millis() map[string]float64 {
+	out := make(map[string]float64, len(t.stages)+1)
+	for stage, d := range t.stages {
+		out[stage] = durationMillis(d)
+	}
+	out[stageTotal] = durationMillis(t.elapsed())
+	return out
+}
+
+func durationMillis(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Microsecond)) / 1000
+}
+
+// ErrDurationsUnsupported is returned when the audit store does not keep
+// analysis durations.
+var ErrDurationsUnsupported = errors.New("audit store does not support analysis durations")
+
+// DurationStats reports analysis and LLM scoring duration percentiles per UTC
+// day over the last days days, today included.
+func (a *Analyzer) DurationStats(ctx context.Context, days int) (audit.DurationStats, error) {
+	store, ok := a.settings().store.(audit.DurationStore)
+	if !ok {
+		return audit.DurationStats{}, ErrDurationsUnsupported
+	}
+	today := a.now().UTC().Truncate(24 * time.Hour)
+	return store.DurationStats(ctx, today.AddDate(0, 0, 1-days))
+}
+
+func DurationStats(ctx context.Context, days int) (audit.DurationStats, error) {
+	return defaultAnalyzer.DurationStats(ctx, days)
+}
@@ -0,0 +1,53 @@
+package analysis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+func TestAnalyze_Timings(t *testing.T) {
+	// Each clock read advances 1ms, so every timed stage takes at least 1ms.
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	tick := func() time.Time {
+		now = now.Add(time.Millisecond)
+		return now
+	}
+	a := New(WithClock(tick), WithAuditStore(audit.NewMemoryStore()))
+	in := Intake{PatientName: "Timed", Age: 45, WeightKg: 80, HeightCm: 175, BP: "124/80", Complaint: "ED"}
+
+	if resp := a.Analyze(in); resp.Timings != nil {
+		t.Fatalf("timings without debug: %v", resp.Timings)
+	}
+	resp := a.AnalyzeWithOptions(in, Options{Debug: true})
+	if len(resp.ValidationErrors) > 0 {
+		t.Fatalf("unexpected validation errors: %v", resp.ValidationErrors)
+	}
+	var sum float64
+	for _, stage := range []string{stageValidation, stagePlanBuild, stageRules, stageLLMScoring, stageAuditInsert, stageSchemaValidation} {
+		if resp.Timings[stage] <= 0 {
+			t.Errorf("timings[%s] = %v", stage, resp.Timings[stage])
+		}
+		sum += resp.Timings[stage]
+	}
+	if resp.Timings[stageTotal] < sum {
+		t.Fatalf("total %v below the sum of stages %v", resp.Timings[stageTotal], sum)
+	}
+
+	sums := a.LatestAudits(1)
+	if len(sums) != 1 || sums[0].DurationMs <= 0 || sums[0].DurationMs >= resp.Timings[stageTotal] || sums[0].LLMDurationMs != resp.Timings[stageLLMScoring] {
+		t.Fatalf("audit summary = %+v, timings %v", sums, resp.Timings)
+	}
+	stats, err := a.DurationStats(t.Context(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.ByDay) != 1 || stats.ByDay[0].Day != "2025-03-01" || stats.ByDay[0].Analyses != 2 || stats.ByDay[0].P95Ms <= 0 {
+		t.Fatalf("duration stats = %+v", stats)
+	}
+
+	if resp := a.AnalyzeWithOptions(Intake{}, Options{Debug: true}); resp.Timings[stageValidation] <= 0 {
+		t.Fatalf("invalid intake timings = %v", resp.Timings)
+	}
+}
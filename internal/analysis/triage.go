@@ -0,0 +1,343 @@
+package analysis
+
+import (
+	"cmp"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+//go:embed triage/questions.json
+var embeddedTriage []byte
+
+// TriageCatalog is the data Triage asks from. Fields holds the question for
+// each field a complaint can require, and for patientName, age, complaint,
+// and allergies; Triage asks it while the field is missing. Questions are the
+// red-flag screens and rule prerequisites each pathway contributes.
+type TriageCatalog struct {
+	Fields    map[string]string `json:"fields"`
+	Questions []TriageRule      `json:"questions"`
+}
+
+// TriageRule asks Question when every condition it sets holds: one of
+// Complaints is named (case-insensitive), one of Conditions is recorded and
+// none of WithoutConditions (canonical conditions, as the rules read them),
+// a medication matches Medications and none matches WithoutMedications (drug
+// class names or names matched by substring, as drug class members are), the
+// systolic reading is at least MinSystolic, and, with Unanswered, Field is
+// still empty. Kind is "red_flag" or "rule".
+type TriageRule struct {
+	ID                 string   `json:"id"`
+	Kind               string   `json:"kind"`
+	Field              string   `json:"field"`
+	Question           string   `json:"question"`
+	Reason             string   `json:"reason"`
+	Blocking           bool     `json:"blocking,omitempty"`
+	Complaints         []string `json:"complaints,omitempty"`
+	Conditions         []string `json:"conditions,omitempty"`
+	WithoutConditions  []string `json:"withoutConditions,omitempty"`
+	Medications        []string `json:"medications,omitempty"`
+	WithoutMedications []string `json:"withoutMedications,omitempty"`
+	MinSystolic        int      `json:"minSystolic,omitempty"`
+	Unanswered         bool     `json:"unanswered,omitempty"`
+}
+
+// Question sources, in the order Triage lists them within blocking and
+// optional questions.
+const (
+	triageRedFlag     = "red_flag"
+	triageRequirement = "requirement"
+	triageRule        = "rule"
+	triageHistory     = "history"
+)
+
+var triageSourceRank = map[string]int{triageRedFlag: 0, triageRequirement: 1, triageRule: 2, triageHistory: 3}
+
+// triageIdentityFields are required of every intake before the complaint's
+// own requirements apply.
+var triageIdentityFields = []string{"patientName", "age", "complaint"}
+
+// triageFields are the fields a TriageRule can ask about. medications.frequency
+// is unanswered while a medication matching the rule has no frequency.
+var triageFields = slices.Concat(triageIdentityFields, requirableFields, []string{
+	RequireAllergies, "familyHistory", "smoking", "alcohol", "exercise", "labs", "waistCircumferenceCm", "medications.frequency",
+})
+
+var embeddedTriageCatalog = mustEmbeddedTriage()
+
+func mustEmbeddedTriage() TriageCatalog {
+	c, err := parseTriage(embeddedTriage, "embedded")
+	if err != nil {
+		panic(fmt.Sprintf("analysis: %v", err))
+	}
+	return c
+}
+
+func parseTriage(raw []byte, source string) (TriageCatalog, error) {
+	var c TriageCatalog
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return TriageCatalog{}, fmt.Errorf("parse triage catalog %s: %w", source, err)
+	}
+	if errs := c.Validate(); len(errs) > 0 {
+		return TriageCatalog{}, fmt.Errorf("invalid triage catalog %s: %s", source, strings.Join(errs, "; "))
+	}
+	return c.normalized(), nil
+}
+
+// Validate reports every problem with c: a field question missing or for an
+// unknown field, questions without an id, kind, field, question, or reason,
+// duplicate ids, unknown fields or conditions, and a medications.frequency
+// question that names no medications.
+func (c TriageCatalog) Validate() []string {
+	var errs []string
+	for _, f := range slices.Concat(triageIdentityFields, requirableFields, []string{RequireAllergies}) {
+		if strings.TrimSpace(c.Fields[f]) == "" {
+			errs = append(errs, fmt.Sprintf("fields.%s is required", f))
+		}
+	}
+	for f := range c.Fields {
+		if !slices.Contains(triageFields, f) {
+			errs = append(errs, fmt.Sprintf("fields.%s is not an intake field", f))
+		}
+	}
+	canonical := map[string]bool{}
+	for _, t := range conditionTerms {
+		canonical[t.Canonical] = true
+	}
+	ids := map[string]int{}
+	for i, q := range c.normalized().Questions {
+		field := fmt.Sprintf("questions[%d]", i)
+		switch j, dup := ids[q.ID]; {
+		case q.ID == "":
+			errs = append(errs, field+".id is required")
+		case dup:
+			errs = append(errs, fmt.Sprintf("%s duplicates the %s question of questions[%d]", field, q.ID, j))
+		default:
+			ids[q.ID] = i
+		}
+		if q.Kind != triageRedFlag && q.Kind != triageRule {
+			errs = append(errs, fmt.Sprintf("%s.kind must be red_flag or rule, not %q", field, q.Kind))
+		}
+		if !slices.Contains(triageFields, q.Field) {
+			errs = append(errs, fmt.Sprintf("%s.field %q is not an intake field", field, q.Field))
+		}
+		if q.Question == "" || q.Reason == "" {
+			errs = append(errs, field+": question and reason are required")
+		}
+		for _, cond := range slices.Concat(q.Conditions, q.WithoutConditions) {
+			if !canonical[cond] {
+				errs = append(errs, fmt.Sprintf("%s: %q is not a condition the rules read", field, cond))
+			}
+		}
+		if q.Field == "medications.frequency" && len(q.Medications) == 0 {
+			errs = append(errs, field+": a medications.frequency question must list medications")
+		}
+	}
+	return errs
+}
+
+// normalized trims ids and text and lowercases the matched names, so
+// matching compares like with like.
+func (c TriageCatalog) normalized() TriageCatalog {
+	out := TriageCatalog{Fields: make(map[string]string, len(c.Fields)), Questions: make([]TriageRule, 0, len(c.Questions))}
+	for f, q := range c.Fields {
+		out.Fields[strings.TrimSpace(f)] = strings.TrimSpace(q)
+	}
+	lower := func(vs []string) []string {
+		var n []string
+		for _, v := range vs {
+			n = append(n, normalizeName(v))
+		}
+		return n
+	}
+	for _, q := range c.Questions {
+		q.ID, q.Kind, q.Field = strings.TrimSpace(q.ID), strings.TrimSpace(q.Kind), strings.TrimSpace(q.Field)
+		q.Question, q.Reason = strings.TrimSpace(q.Question), strings.TrimSpace(q.Reason)
+		q.Complaints, q.Medications, q.WithoutMedications = lower(q.Complaints), lower(q.Medications), lower(q.WithoutMedications)
+		q.Conditions, q.WithoutConditions = lower(q.Conditions), lower(q.WithoutConditions)
+		out.Questions = append(out.Questions, q)
+	}
+	return out
+}
+
+// SetTriageCatalog validates c and makes it the catalog Triage asks from; on
+// error the current catalog stays active.
+func (a *Analyzer) SetTriageCatalog(c TriageCatalog) error {
+	if errs := c.Validate(); len(errs) > 0 {
+		return fmt.Errorf("invalid triage catalog: %s", strings.Join(errs, "; "))
+	}
+	return a.update(func(s *settings) error {
+		s.triage = c.normalized()
+		return nil
+	})
+}
+
+func SetTriageCatalog(c TriageCatalog) error {
+	return defaultAnalyzer.SetTriageCatalog(c)
+}
+
+// LoadTriageFile replaces the embedded triage catalog with the JSON document
+// at path.
+func (a *Analyzer) LoadTriageFile(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read triage catalog: %w", err)
+	}
+	c, err := parseTriage(raw, path)
+	if err != nil {
+		return err
+	}
+	return a.update(func(s *settings) error {
+		s.triage = c
+		return nil
+	})
+}
+
+func LoadTriageFile(path string) error {
+	return defaultAnalyzer.LoadTriageFile(path)
+}
+
+// Triage lists the follow-up questions for a partial intake: the missing
+// fields its complaints require or recommend, the catalog's red-flag and
+// rule questions that match it, and the history lists left unconfirmed.
+// Blocking questions come first, then red flags, requirements, rules, and
+// history, in catalog order within each. Nothing is validated, planned, or
+// audited.
+func (a *Analyzer) Triage(in Intake) TriageReport {
+	s := a.settings()
+	var qs []FollowUpQuestion
+	ask := func(id, field, reason string, blocking bool, source string) {
+		qs = append(qs, FollowUpQuestion{ID: id, Field: field, Question: s.triage.Fields[field], Reason: reason, Blocking: blocking, Source: source})
+	}
+
+	for _, f := range triageIdentityFields {
+		if !triageAnswered(in, f, nil) {
+			ask("required-"+f, f, f+" is required for every analysis", true, triageRequirement)
+		}
+	}
+	errs, warnings := s.requirementErrors(in)
+	for _, e := range errs {
+		ask("required-"+e.Field, e.Field, e.Message, true, triageRequirement)
+	}
+	for _, w := range warnings {
+		ask("recommended-"+w.Field, w.Field, w.Message, false, triageRequirement)
+	}
+
+	complaints := map[string]bool{}
+	for _, c := range intakeComplaints(in) {
+		complaints[normalizeName(c)] = true
+	}
+	conds, _ := normalizeConditions(in.Conditions)
+	mergeConditionCodes(conds, in.ConditionCodes)
+	systolic, _, _ := parseBP(in.BP)
+	for _, r := range s.triage.Questions {
+		if s.triageMatches(r, in, complaints, conds, systolic) {
+			qs = append(qs, FollowUpQuestion{ID: r.ID, Field: r.Field, Question: r.Question, Reason: r.Reason, Blocking: r.Blocking, Source: r.Kind})
+		}
+	}
+
+	for _, section := range unconfirmedSections(in) {
+		if slices.ContainsFunc(qs, func(q FollowUpQuestion) bool { return q.Field == section && q.Source == triageRequirement }) {
+			continue
+		}
+		ask("unconfirmed-"+section, section, section+" is empty without being confirmed as none, which caps plan confidence", false, triageHistory)
+	}
+
+	slices.SortStableFunc(qs, func(x, y FollowUpQuestion) int {
+		if x.Blocking != y.Blocking {
+			if x.Blocking {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Compare(triageSourceRank[x.Source], triageSourceRank[y.Source])
+	})
+	if qs == nil {
+		qs = []FollowUpQuestion{}
+	}
+	return TriageReport{Questions: qs}
+}
+
+func Triage(in Intake) TriageReport {
+	return defaultAnalyzer.Triage(in)
+}
+
+func (s settings) triageMatches(r TriageRule, in Intake, complaints, conds map[string]bool, systolic int) bool {
+	if len(r.Complaints) > 0 && !slices.ContainsFunc(r.Complaints, func(c string) bool { return complaints[c] }) {
+		return false
+	}
+	if len(r.Conditions) > 0 && !slices.ContainsFunc(r.Conditions, func(c string) bool { return conds[c] }) {
+		return false
+	}
+	if slices.ContainsFunc(r.WithoutConditions, func(c string) bool { return conds[c] }) {
+		return false
+	}
+	if r.MinSystolic > 0 && systolic < r.MinSystolic {
+		return false
+	}
+	matching := s.triageMedications(in.Medications, r.Medications)
+	if len(r.Medications) > 0 && len(matching) == 0 {
+		return false
+	}
+	if len(r.WithoutMedications) > 0 && len(s.triageMedications(in.Medications, r.WithoutMedications)) > 0 {
+		return false
+	}
+	return !r.Unanswered || !triageAnswered(in, r.Field, matching)
+}
+
+// triageMedications returns the medications matching names, each a drug
+// class name or a substring of the normalized medication name.
+func (s settings) triageMedications(meds []Medication, names []string) []Medication {
+	var needles []string
+	for _, n := range names {
+		needles = append(needles, n)
+		for _, c := range s.drugClasses {
+			if strings.EqualFold(c.Name, n) {
+				needles = append(needles, c.Members...)
+			}
+		}
+	}
+	var out []Medication
+	for _, m := range meds {
+		for _, c := range medicationComponents(m) {
+			if slices.ContainsFunc(needles, func(n string) bool { return strings.Contains(c.Name, n) }) {
+				out = append(out, m)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// triageAnswered reports whether in fills field. medications.frequency is
+// answered once every medication in matching has a frequency.
+func triageAnswered(in Intake, field string, matching []Medication) bool {
+	switch field {
+	case "patientName":
+		return strings.TrimSpace(in.PatientName) != ""
+	case "age":
+		return in.Age > 0
+	case "complaint":
+		return len(intakeComplaints(in)) > 0
+	case RequireAllergies:
+		return !slices.Contains(unconfirmedSections(in), RequireAllergies)
+	case "familyHistory":
+		return len(in.FamilyHistory) > 0
+	case "smoking":
+		return strings.TrimSpace(in.Smoking) != ""
+	case "alcohol":
+		return strings.TrimSpace(in.Alcohol) != ""
+	case "exercise":
+		return strings.TrimSpace(in.Exercise) != ""
+	case "labs":
+		return in.Labs != nil && (in.Labs.A1cPercent > 0 || in.Labs.HDLMgDl > 0 || in.Labs.TriglyceridesMgDl > 0)
+	case "waistCircumferenceCm":
+		return in.WaistCircumferenceCm > 0
+	case "medications.frequency":
+		return !slices.ContainsFunc(matching, func(m Medication) bool { return strings.TrimSpace(m.Frequency) == "" })
+	}
+	return !missingField(in, field)
+}
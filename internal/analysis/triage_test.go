@@ -0,0 +1,127 @@
+package analysis
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func questionIDs(r TriageReport) []string {
+	var ids []string
+	for _, q := range r.Questions {
+		ids = append(ids, q.ID)
+	}
+	return ids
+}
+
+func findQuestion(r TriageReport, id string) (FollowUpQuestion, bool) {
+	for _, q := range r.Questions {
+		if q.ID == id {
+			return q, true
+		}
+	}
+	return FollowUpQuestion{}, false
+}
+
+func TestTriage_EDCardiac(t *testing.T) {
+	r := New().Triage(Intake{PatientName: "Triage", Age: 62, BP: "150/90", Complaint: "ED", Conditions: []string{"CAD"}})
+	for _, id := range []string{"ed-cardiac-nitrates", "required-weight", "required-height", "recommended-medications", "unconfirmed-allergies", "ed-family-cad", "smoking-history"} {
+		if _, ok := findQuestion(r, id); !ok {
+			t.Errorf("questions %v lack %s", questionIDs(r), id)
+		}
+	}
+	if q, _ := findQuestion(r, "ed-cardiac-nitrates"); !q.Blocking || q.Source != "rule" || q.Field != "medications" {
+		t.Errorf("nitrate question = %+v", q)
+	}
+	for _, id := range []string{"required-bp", "required-conditions", "required-age", "hair-loss-family-prostate", "weight-loss-labs", "ed-severe-bp"} {
+		if _, ok := findQuestion(r, id); ok {
+			t.Errorf("questions %v include %s", questionIDs(r), id)
+		}
+	}
+	// Blocking questions lead, requirements before rules.
+	seenOptional := false
+	for _, q := range r.Questions {
+		if !q.Blocking {
+			seenOptional = true
+		} else if seenOptional {
+			t.Fatalf("blocking %s after an optional question: %v", q.ID, questionIDs(r))
+		}
+	}
+	if r.Questions[0].ID != "required-weight" {
+		t.Errorf("first question %s, want required-weight", r.Questions[0].ID)
+	}
+
+	// A listed nitrate answers the nitrate question, and a missing frequency
+	// asks for it.
+	r = New().Triage(Intake{PatientName: "Triage", Age: 62, BP: "190/100", Complaint: "ED",
+		Medications: []Medication{{Name: "Isosorbide mononitrate", Dosage: "30mg"}, {Name: "amlodipine", Dosage: "5mg"}}})
+	if _, ok := findQuestion(r, "ed-cardiac-nitrates"); ok {
+		t.Errorf("nitrate question asked with a nitrate listed")
+	}
+	if q, ok := findQuestion(r, "ed-nitrate-frequency"); !ok || !q.Blocking {
+		t.Errorf("frequency question = %+v, %t", q, ok)
+	}
+	if q, ok := findQuestion(r, "ed-severe-bp"); !ok || q.Source != "red_flag" || r.Questions[0].ID != "ed-severe-bp" {
+		t.Errorf("severe BP red flag = %+v, first question %s", q, r.Questions[0].ID)
+	}
+}
+
+func TestTriage_CompleteIntakeAsksLittle(t *testing.T) {
+	in := llmIntake
+	in.FamilyHistory = []string{"prostate cancer"}
+	if r := New().Triage(in); len(r.Questions) != 0 {
+		t.Fatalf("complete hair loss intake asked %v", questionIDs(r))
+	}
+	r := New().Triage(Intake{Complaint: "Weight Loss"})
+	for _, id := range []string{"required-patientName", "required-age", "weight-loss-pregnancy", "weight-loss-labs", "weight-loss-waist", "smoking-history"} {
+		if _, ok := findQuestion(r, id); !ok {
+			t.Errorf("questions %v lack %s", questionIDs(r), id)
+		}
+	}
+	if q, _ := findQuestion(r, "required-weight"); q.Question == "" || q.Reason != "weight is required for Weight Loss" {
+		t.Errorf("weight question = %+v", q)
+	}
+}
+
+func TestTriageCatalog_Validate(t *testing.T) {
+	c := embeddedTriageCatalog
+	if errs := c.Validate(); len(errs) != 0 {
+		t.Fatalf("embedded catalog: %v", errs)
+	}
+	bad := TriageCatalog{Fields: map[string]string{"bp": "BP?", "shoeSize": "?"}, Questions: []TriageRule{
+		{ID: "a", Kind: "rule", Field: "bp", Question: "q", Reason: "r", Conditions: []string{"gout"}},
+		{ID: "a", Kind: "hunch", Field: "medications.frequency", Question: "q"},
+	}}
+	errs := strings.Join(bad.Validate(), "; ")
+	for _, want := range []string{"fields.age is required", "fields.shoeSize is not an intake field", `"gout" is not a condition`, "duplicates the a question", "kind must be red_flag or rule", "question and reason are required", "must list medications"} {
+		if !strings.Contains(errs, want) {
+			t.Errorf("errors %q lack %q", errs, want)
+		}
+	}
+}
+
+func TestLoadTriageFile(t *testing.T) {
+	c := embeddedTriageCatalog
+	c.Questions = []TriageRule{{ID: "hair-loss-scalp", Kind: "red_flag", Field: "conditions", Question: "Any scalp scarring or patchy loss?", Reason: "Patchy or scarring loss needs dermatology review.", Blocking: true, Complaints: []string{"Hair Loss"}}}
+	raw, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "triage.json")
+	if err := os.WriteFile(path, raw, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	a := New()
+	if err := a.LoadTriageFile(path); err != nil {
+		t.Fatal(err)
+	}
+	r := a.Triage(llmIntake)
+	if len(r.Questions) != 1 || r.Questions[0].ID != "hair-loss-scalp" {
+		t.Fatalf("questions %v, want the loaded pathway's own", questionIDs(r))
+	}
+	if err := a.SetTriageCatalog(TriageCatalog{}); err == nil {
+		t.Fatal("empty catalog accepted")
+	}
+}
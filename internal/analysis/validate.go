@@ -0,0 +1,234 @@
+package analysis
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+//go:embed schema/intake.schema.json
+var intakeSchema []byte
+
+var compiledIntakeSchema = sync.OnceValues(func() (*gojsonschema.Schema, error) {
+	return gojsonschema.NewSchema(gojsonschema.NewBytesLoader(intakeSchema))
+})
+
+// ErrMalformedIntake is returned by CheckIntake for a body that is not JSON.
+var ErrMalformedIntake = errors.New("intake is not valid JSON")
+
+// Plausibility bounds. Values outside them pass validation, since the engine
+// can analyze them, but are usually entry mistakes such as a height typed in
+// metres. Blood pressure is the exception: parseBP rejects a reading outside
+// its bounds, since it would drive the hypertension rules.
+const (
+	plausibleMaxAge       = 120
+	plausibleMinWeightKg  = 20
+	plausibleMaxWeightKg  = 350
+	plausibleMinHeightCm  = 100
+	plausibleMaxHeightCm  = 230
+	plausibleMinSystolic  = 60
+	plausibleMaxSystolic  = 260
+	plausibleMinDiastolic = 30
+	plausibleMaxDiastolic = 160
+	plausibleBMIDrift     = 1.0
+)
+
+// CheckIntake validates a raw intake body for form feedback: the intake JSON
+// schema, Validate, and plausibility checks, plus a preview of how the engine
+// will read the intake. It never builds a plan, scores, or audits, so it is
+// cheap enough to call on every field change.
+func (a *Analyzer) CheckIntake(raw []byte) (ValidationReport, error) {
+	report := ValidationReport{Errors: []FieldError{}, Warnings: []FieldError{}}
+	if !json.Valid(raw) {
+		return report, ErrMalformedIntake
+	}
+	schema, err := compiledIntakeSchema()
+	if err != nil {
+		return report, fmt.Errorf("intake schema: %w", err)
+	}
+	result, err := schema.Validate(gojsonschema.NewBytesLoader(raw))
+	if err != nil {
+		return report, fmt.Errorf("intake schema: %w", err)
+	}
+	if !result.Valid() {
+		for _, e := range result.Errors() {
+			report.Errors = append(report.Errors, FieldError{Field: e.Field(), Code: e.Type(), Message: e.Field() + ": " + e.Description()})
+		}
+		return report, nil
+	}
+	var in Intake
+	if err := json.Unmarshal(raw, &in); err != nil {
+		report.Errors = append(report.Errors, FieldError{Field: "(root)", Code: "invalid_type", Message: err.Error()})
+		return report, nil
+	}
+	in = normalizeText(in)
+
+	errs, warnings := a.settings().fieldErrors(in)
+	report.Errors = append(report.Errors, errs...)
+	report.Warnings = append(append(report.Warnings, warnings...), plausibilityWarnings(in)...)
+	report.Valid = len(report.Errors) == 0
+	report.Preview = previewIntake(in)
+	return report, nil
+}
+
+func CheckIntake(raw []byte) (ValidationReport, error) {
+	return defaultAnalyzer.CheckIntake(raw)
+}
+
+// CheckPartialIntake is CheckIntake for an intake still being filled in, as
+// sent over the /api/analyze/ws socket. It also evaluates the risk factors
+// and issues that need no plan on whatever fields are present, even while
+// required fields are missing. Like CheckIntake it never builds a plan,
+// scores, or audits.
+func (a *Analyzer) CheckPartialIntake(raw []byte, opts Options) (PartialResult, error) {
+	report, err := a.CheckIntake(raw)
+	out := PartialResult{ValidationReport: report, RiskFactors: []RiskFactor{}, FlaggedIssues: []Issue{}}
+	if err != nil {
+		return out, err
+	}
+	var in Intake
+	if err := json.Unmarshal(raw, &in); err != nil {
+		// The report already carries the type error.
+		return out, nil
+	}
+	s := a.settings()
+	assessed := assessIntake(in, newRiskAccumulator(s.riskWeights, false), s.localizer(opts.Locale))
+	out.ComputedBMI = assessed.BMI
+	out.RiskScore = assessed.Risk.score
+	out.RiskLevel = classifyRisk(assessed.Risk.score, s.thresholds)
+	if len(assessed.Risk.factors) > 0 {
+		out.RiskFactors = assessed.Risk.factors
+	}
+	if issues := finalizeIssues(assessed.Issues); len(issues) > 0 {
+		out.FlaggedIssues = issues
+	}
+	return out, nil
+}
+
+func CheckPartialIntake(raw []byte, opts Options) (PartialResult, error) {
+	return defaultAnalyzer.CheckPartialIntake(raw, opts)
+}
+
+// plausibilityWarnings flags values outside the plausibility bounds and a
+// supplied BMI that disagrees with weight and height. Blood pressure is
+// checked by Validate.
+func plausibilityWarnings(in Intake) []FieldError {
+	var out []FieldError
+	warn := func(field, format string, args ...any) {
+		out = append(out, FieldError{Field: field, Code: "implausible", Message: fmt.Sprintf(format, args...)})
+	}
+	if in.Age > plausibleMaxAge {
+		warn("age", "age %d is above %d", in.Age, plausibleMaxAge)
+	}
+	if in.WeightKg > 0 && (in.WeightKg < plausibleMinWeightKg || in.WeightKg > plausibleMaxWeightKg) {
+		warn("weight", "weight %.1f kg is outside %d-%d kg", in.WeightKg, plausibleMinWeightKg, plausibleMaxWeightKg)
+	}
+	if in.HeightCm > 0 && (in.HeightCm < plausibleMinHeightCm || in.HeightCm > plausibleMaxHeightCm) {
+		warn("height", "height %.1f cm is outside %d-%d cm", in.HeightCm, plausibleMinHeightCm, plausibleMaxHeightCm)
+	}
+	if computed := computeBMI(in.WeightKg, in.HeightCm); in.BMI > 0 && computed > 0 && math.Abs(in.BMI-computed) > plausibleBMIDrift {
+		warn("bmi", "bmi %.1f differs from %.1f computed from weight and height; the computed value is used", in.BMI, computed)
+	}
+	return out
+}
+
+// previewIntake reports the values the engine derives from in.
+func previewIntake(in Intake) *IntakePreview {
+	sys, dia, _ := parseBP(in.BP)
+	p := &IntakePreview{
+		Systolic:    sys,
+		Diastolic:   dia,
+		ComputedBMI: math.Round(computeBMI(in.WeightKg, in.HeightCm)*10) / 10,
+		Medications: []string{},
+	}
+	seen := map[string]bool{}
+	for _, m := range in.Medications {
+		for _, c := range medicationComponents(m) {
+			if !seen[c.Name] {
+				seen[c.Name] = true
+				p.Medications = append(p.Medications, c.Name)
+			}
+		}
+	}
+	return p
+}
+
+// normalizeIntake reports, value by value, how the rules read in. It takes
+// nothing from the patient's name.
+func normalizeIntake(in Intake, classes []DrugClass) *NormalizedIntake {
+	sys, dia, _ := parseBP(in.BP)
+	n := &NormalizedIntake{
+		BloodPressure: NormalizedBP{Raw: in.BP, Systolic: sys, Diastolic: dia},
+		BMI: NormalizedBMI{
+			WeightKg: in.WeightKg,
+			HeightCm: in.HeightCm,
+			Provided: in.BMI,
+			Computed: math.Round(computeBMI(in.WeightKg, in.HeightCm)*10) / 10,
+		},
+		Conditions:  []NormalizedCondition{},
+		Medications: []NormalizedMedication{},
+		Complaints:  []NormalizedComplaint{},
+	}
+	for _, v := range in.Conditions {
+		if strings.TrimSpace(v) == "" {
+			continue
+		}
+		set, _ := normalizeConditions([]string{v})
+		canonical := append([]string{}, slices.Sorted(maps.Keys(set))...)
+		n.Conditions = append(n.Conditions, NormalizedCondition{Raw: v, Source: "conditions", Canonical: canonical})
+	}
+	for _, raw := range in.ConditionCodes {
+		code, ok := normalizeICD10(raw)
+		if !ok {
+			continue
+		}
+		canonical := []string{}
+		if c := icd10Condition(code); c != "" {
+			canonical = append(canonical, c)
+		}
+		n.Conditions = append(n.Conditions, NormalizedCondition{Raw: raw, Source: "conditionCodes", Canonical: canonical})
+	}
+	for _, m := range in.Medications {
+		name := normalizeName(m.Name)
+		if name == "" {
+			continue
+		}
+		med := NormalizedMedication{
+			Raw:       m,
+			Name:      name,
+			DoseMg:    extractMg(m.Dosage),
+			Frequency: normalizeName(m.Frequency),
+			Classes:   []string{},
+		}
+		for _, c := range classes {
+			if c.has(name) {
+				med.Classes = append(med.Classes, c.Name)
+			}
+		}
+		if components := medicationComponents(m); len(components) > 1 {
+			for _, mc := range components {
+				nc := NormalizedComponent{Name: mc.Name, DoseMg: mc.DoseMg, Classes: []string{}}
+				for _, c := range classes {
+					if c.has(mc.Name) {
+						nc.Classes = append(nc.Classes, c.Name)
+					}
+				}
+				med.Components = append(med.Components, nc)
+			}
+		}
+		n.Medications = append(n.Medications, med)
+	}
+	for _, c := range intakeComplaints(in) {
+		key := strings.ToLower(c)
+		n.Complaints = append(n.Complaints, NormalizedComplaint{Raw: c, Complaint: key, Recognized: slices.Contains(complaintPriority, key)})
+	}
+	return n
+}
@@ -0,0 +1,118 @@
+package analysis
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+func TestCheckIntake(t *testing.T) {
+	raw := []byte(`{"patientName":"Form","age":45,"weight":70,"height":1.7,"bp":"135 / 88","bmi":31,
+		"complaint":"ED","confirmedNoConditions":true,"confirmedNoAllergies":true,"medications":[{"name":" Amlodipine "},{"name":"amlodipine"},{"name":"Tamsulosin"}]}`)
+	r, err := New().CheckIntake(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Valid || len(r.Errors) != 0 {
+		t.Fatalf("errors = %+v", r.Errors)
+	}
+	if p := r.Preview; p == nil || p.Systolic != 135 || p.Diastolic != 88 || len(p.Medications) != 2 || p.Medications[0] != "amlodipine" {
+		t.Fatalf("preview = %+v", r.Preview)
+	}
+	fields := map[string]bool{}
+	for _, w := range r.Warnings {
+		fields[w.Field] = w.Code == "implausible"
+	}
+	if !fields["height"] || !fields["bmi"] || len(r.Warnings) != 2 {
+		t.Fatalf("warnings = %+v, want height and bmi", r.Warnings)
+	}
+}
+
+func TestCheckIntake_Errors(t *testing.T) {
+	a := New()
+	a.SetConsentRequired(true)
+	r, err := a.CheckIntake([]byte(`{"patientName":"Form","age":0,"weight":70,"height":170,"bp":"120/80","complaint":""}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes := map[string]string{}
+	for _, e := range r.Errors {
+		codes[e.Field] = e.Code
+	}
+	if r.Valid || codes["age"] != "not_positive" || codes["complaint"] != "required" || codes["consent.given"] != ConsentRequired {
+		t.Fatalf("errors = %+v", r.Errors)
+	}
+
+	r, err = a.CheckIntake([]byte(`{"age":"forty","medications":[{"name":5}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Valid || r.Preview != nil || len(r.Errors) != 2 || r.Errors[0].Code != "invalid_type" {
+		t.Fatalf("schema errors = %+v", r)
+	}
+
+	if _, err := a.CheckIntake([]byte(`{"age":`)); !errors.Is(err, ErrMalformedIntake) {
+		t.Fatalf("malformed body: %v", err)
+	}
+}
+
+func TestNormalizedIntake(t *testing.T) {
+	a := New(WithAuditStore(audit.NewMemoryStore()))
+	in := Intake{
+		PatientName: "Normal Form", Age: 60, WeightKg: 80, HeightCm: 175, BP: "150 / 95", BMI: 25,
+		Conditions:     []string{"CKD stage 3", "gout", " "},
+		ConditionCodes: []string{"i25.10", "Z99"},
+		Medications:    []Medication{{Name: " Amlodipine ", Dosage: "10 mg", Frequency: " Daily "}, {Name: "Norvasc"}},
+		Complaint:      "ED", Complaints: []string{"insomnia"},
+	}
+	if resp := a.Analyze(in); resp.NormalizedIntake != nil {
+		t.Fatalf("normalizedIntake set without IncludeNormalized")
+	}
+	resp := a.AnalyzeWithOptions(in, Options{IncludeNormalized: true})
+	if len(resp.ValidationErrors) > 0 {
+		t.Fatalf("validation errors: %v", resp.ValidationErrors)
+	}
+	n := resp.NormalizedIntake
+	if n == nil {
+		t.Fatal("normalizedIntake missing")
+	}
+	if n.BloodPressure != (NormalizedBP{Raw: "150 / 95", Systolic: 150, Diastolic: 95}) || n.BMI.Computed != 26.1 || n.BMI.Provided != 25 {
+		t.Fatalf("bp/bmi = %+v %+v", n.BloodPressure, n.BMI)
+	}
+	want := []NormalizedCondition{
+		{Raw: "CKD stage 3", Source: "conditions", Canonical: []string{condKidneyDisease}},
+		{Raw: "gout", Source: "conditions", Canonical: []string{}},
+		{Raw: "i25.10", Source: "conditionCodes", Canonical: []string{condHeartDisease}},
+		{Raw: "Z99", Source: "conditionCodes", Canonical: []string{}},
+	}
+	if !reflect.DeepEqual(n.Conditions, want) {
+		t.Fatalf("conditions = %+v", n.Conditions)
+	}
+	if len(n.Medications) != 2 {
+		t.Fatalf("medications = %+v", n.Medications)
+	}
+	if m := n.Medications[0]; m.Name != "amlodipine" || m.DoseMg != 10 || m.Frequency != "daily" || m.Raw.Name != " Amlodipine " || !slices.Equal(m.Classes, []string{"calcium channel blocker"}) {
+		t.Fatalf("amlodipine = %+v", m)
+	}
+	// Brand names are not resolved; an unrecognized name shows no class.
+	if m := n.Medications[1]; m.Name != "norvasc" || m.DoseMg != 0 || len(m.Classes) != 0 {
+		t.Fatalf("norvasc = %+v", m)
+	}
+	if len(n.Complaints) != 2 || !n.Complaints[0].Recognized || n.Complaints[0].Complaint != "ed" || n.Complaints[1].Recognized {
+		t.Fatalf("complaints = %+v", n.Complaints)
+	}
+
+	body, _ := json.Marshal(n)
+	if strings.Contains(string(body), "Normal Form") {
+		t.Fatalf("normalizedIntake carries the patient name: %s", body)
+	}
+	stored, err := a.AuditResponse(t.Context(), resp.AuditID)
+	if err != nil || stored.NormalizedIntake != nil {
+		t.Fatalf("audited response = %+v (err %v), want no normalizedIntake", stored.NormalizedIntake, err)
+	}
+}
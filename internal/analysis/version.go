@@ -0,0 +1,100 @@
+package analysis
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+// ErrRulesetHistoryUnsupported is returned when the audit store cannot list
+// audits by ruleset version.
+var ErrRulesetHistoryUnsupported = errors.New("audit store does not support ruleset version lookups")
+
+// BuildVersion describes the running binary from its embedded build info: the
+// module version, plus the VCS revision and a "-dirty" suffix when the binary
+// was built from a checkout. It is "unknown" when no build info is embedded.
+func BuildVersion() string {
+	return buildVersion()
+}
+
+var buildVersion = sync.OnceValue(func() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	v := info.Main.Version
+	if v == "" {
+		v = "(devel)"
+	}
+	var rev string
+	var dirty bool
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			rev = s.Value
+		case "vcs.modified":
+			dirty = s.Value == "true"
+		}
+	}
+	if rev != "" {
+		v += "+" + rev[:min(12, len(rev))]
+		if dirty {
+			v += "-dirty"
+		}
+	}
+	return v
+})
+
+// RulesetVersion identifies everything that decides an analysis: the active
+// ruleset, the system prompt, the build, and the configuration bundle when
+// one is applied. It is a short hash of their versions, stamped on every
+// Response and audit entry so analyses can be traced back after a rule
+// changes.
+func (a *Analyzer) RulesetVersion() string {
+	return a.settings().rulesetVersion()
+}
+
+func RulesetVersion() string {
+	return defaultAnalyzer.RulesetVersion()
+}
+
+func (s settings) rulesetVersion() string {
+	versions := []string{s.rulesInfo().Version, s.promptInfo.Version, BuildVersion()}
+	if s.bundle != nil {
+		versions = append(versions, s.bundle.Hash)
+	}
+	return combinedVersion(versions...)
+}
+
+func combinedVersion(versions ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(versions, "\x00")))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// AuditsByRulesetVersion returns up to limit of the most recent analyses
+// stamped with version, oldest first. A limit of 0 uses the store default.
+func (a *Analyzer) AuditsByRulesetVersion(ctx context.Context, version string, limit int) ([]AuditSummary, error) {
+	store, ok := a.settings().store.(audit.RulesetHistory)
+	if !ok {
+		return nil, ErrRulesetHistoryUnsupported
+	}
+	summaries, err := store.ListByRulesetVersion(ctx, version, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]AuditSummary, 0, len(summaries))
+	for _, sum := range summaries {
+		out = append(out, auditSummary(sum))
+	}
+	return out, nil
+}
+
+func AuditsByRulesetVersion(ctx context.Context, version string, limit int) ([]AuditSummary, error) {
+	return defaultAnalyzer.AuditsByRulesetVersion(ctx, version, limit)
+}
@@ -0,0 +1,42 @@
+package analysis
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRulesetVersionStamped(t *testing.T) {
+	a := New()
+	in := Intake{PatientName: "Stamp", Age: 40, WeightKg: 70, HeightCm: 175, BP: "120/80", Complaint: "ED"}
+	first := a.Analyze(in)
+	if first.RulesetVersion == "" || first.RulesetVersion != a.RulesetVersion() {
+		t.Fatalf("rulesetVersion = %q, active %q", first.RulesetVersion, a.RulesetVersion())
+	}
+
+	r := a.Rules().Ruleset
+	r.DoseCaps = []DoseCap{{Medication: "sildenafil", MaxMg: 50}}
+	if _, err := a.ReplaceRules(context.Background(), r, "", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	second := a.Analyze(in)
+	if second.RulesetVersion == first.RulesetVersion {
+		t.Fatal("ruleset change kept the ruleset version")
+	}
+	if err := a.SetSystemPromptTemplate("Custom prompt.", "test"); err != nil {
+		t.Fatal(err)
+	}
+	if a.RulesetVersion() == second.RulesetVersion {
+		t.Fatal("prompt change kept the ruleset version")
+	}
+
+	got, err := a.AuditsByRulesetVersion(context.Background(), first.RulesetVersion, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].AuditID != first.AuditID || got[0].RulesetVersion != first.RulesetVersion {
+		t.Fatalf("audits for %s = %+v", first.RulesetVersion, got)
+	}
+	if BuildVersion() == "" {
+		t.Fatal("empty build version")
+	}
+}
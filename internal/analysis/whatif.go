@@ -0,0 +1,292 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+// ErrIntakesUnsupported is returned when the audit store does not keep intakes.
+var ErrIntakesUnsupported = errors.New("audit store does not keep intakes")
+
+// ErrNoIntake is returned for an audit written before intakes were stored.
+var ErrNoIntake = errors.New("audit has no stored intake")
+
+// PatchError reports the what-if patch operation that could not be applied.
+type PatchError struct {
+	Index  int
+	Op     PatchOp
+	Reason string
+}
+
+func (e *PatchError) Error() string {
+	return fmt.Sprintf("patch[%d] %s %s: %s", e.Index, e.Op.Op, e.Op.Path, e.Reason)
+}
+
+func newStoredIntake(in Intake, ref string) StoredIntake {
+	in.PatientName = ""
+	return StoredIntake{Intake: in, PatientRef: ref}
+}
+
+// SetStoreIntakes controls whether each audit entry keeps the analyzed intake,
+// redacted to the patient reference. It is on by default; what-if and
+// re-analysis need it, and fail with ErrNoIntake for audits written while it
+// was off.
+func (a *Analyzer) SetStoreIntakes(enabled bool) {
+	_ = a.update(func(s *settings) error {
+		s.storeIntakes = enabled
+		return nil
+	})
+}
+
+func SetStoreIntakes(enabled bool) {
+	defaultAnalyzer.SetStoreIntakes(enabled)
+}
+
+// AuditRecord returns the response stored with audit id together with its
+// redacted intake and soft deletion trail. Intake is nil when none was kept
+// or the store keeps none.
+func (a *Analyzer) AuditRecord(ctx context.Context, id string) (AuditDetail, error) {
+	resp, err := a.AuditResponse(ctx, id)
+	if err != nil {
+		return AuditDetail{}, err
+	}
+	rec := AuditDetail{Response: resp}
+	switch si, err := a.storedIntake(ctx, id); {
+	case err == nil:
+		rec.Intake = &si
+	case !errors.Is(err, ErrNoIntake) && !errors.Is(err, ErrIntakesUnsupported):
+		return AuditDetail{}, err
+	}
+	if rec.Deletions, err = a.auditDeletions(ctx, id); err != nil {
+		return AuditDetail{}, err
+	}
+	return rec, nil
+}
+
+func AuditRecord(ctx context.Context, id string) (AuditDetail, error) {
+	return defaultAnalyzer.AuditRecord(ctx, id)
+}
+
+func (a *Analyzer) storedIntake(ctx context.Context, id string) (StoredIntake, error) {
+	reader, ok := a.settings().store.(audit.IntakeReader)
+	if !ok {
+		return StoredIntake{}, ErrIntakesUnsupported
+	}
+	raw, err := reader.Intake(ctx, id)
+	if err != nil {
+		return StoredIntake{}, err
+	}
+	if len(raw) == 0 {
+		return StoredIntake{}, ErrNoIntake
+	}
+	var si StoredIntake
+	if err := json.Unmarshal(raw, &si); err != nil {
+		return StoredIntake{}, fmt.Errorf("decode audit %s intake: %w", id, err)
+	}
+	return si, nil
+}
+
+// WhatIf re-analyzes the intake stored with req.AuditID after applying
+// req.Patch. Both analyses run under the current rules; only a hypothetical
+// with req.Record set is audited. Errors are audit.ErrNotFound, ErrNoIntake,
+// ErrIntakesUnsupported, or a *PatchError.
+func (a *Analyzer) WhatIf(ctx context.Context, req WhatIfRequest, opts Options) (WhatIfResponse, error) {
+	base, err := a.storedIntake(ctx, req.AuditID)
+	if err != nil {
+		return WhatIfResponse{}, err
+	}
+	hypo, err := applyPatch(base.Intake, req.Patch)
+	if err != nil {
+		return WhatIfResponse{}, err
+	}
+	// The reference stands in for the name so the intake validates, and keeps
+	// any recorded audit and its trend on the same patient.
+	orig := base.Intake
+	orig.PatientName, hypo.PatientName = base.PatientRef, base.PatientRef
+	opts.patientRef = base.PatientRef
+
+	origOpts := opts
+	origOpts.DryRun = true
+	opts.DryRun = !req.Record
+	original := a.AnalyzeContext(ctx, orig, origOpts)
+	hypothetical := a.AnalyzeContext(ctx, hypo, opts)
+	return WhatIfResponse{
+		BaseAuditID:  req.AuditID,
+		Original:     original,
+		Hypothetical: hypothetical,
+		Diff:         diffAnalyses(original, hypothetical, &orig, &hypo),
+	}, nil
+}
+
+func WhatIf(ctx context.Context, req WhatIfRequest, opts Options) (WhatIfResponse, error) {
+	return defaultAnalyzer.WhatIf(ctx, req, opts)
+}
+
+// applyPatch applies patch to in as a JSON document. Each operation is checked
+// against the Intake type as it is applied, so a bad value is reported on the
+// operation that introduced it.
+func applyPatch(in Intake, patch []PatchOp) (Intake, error) {
+	raw, err := json.Marshal(in)
+	if err != nil {
+		return Intake{}, err
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return Intake{}, err
+	}
+	out := in
+	for i, op := range patch {
+		if err := applyOp(doc, op); err != nil {
+			return Intake{}, &PatchError{Index: i, Op: op, Reason: err.Error()}
+		}
+		if out, err = decodeIntake(doc); err != nil {
+			return Intake{}, &PatchError{Index: i, Op: op, Reason: err.Error()}
+		}
+	}
+	return out, nil
+}
+
+func decodeIntake(doc map[string]any) (Intake, error) {
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return Intake{}, err
+	}
+	var in Intake
+	if err := json.Unmarshal(raw, &in); err != nil {
+		var te *json.UnmarshalTypeError
+		if errors.As(err, &te) {
+			return Intake{}, fmt.Errorf("%s cannot be a %s", te.Field, te.Value)
+		}
+		return Intake{}, err
+	}
+	return in, nil
+}
+
+// unpatchable fields identify the patient and the author of the base audit,
+// or record the patient's consent to it.
+var unpatchable = map[string]bool{"patientName": true, "userId": true, "consent": true}
+
+func applyOp(doc map[string]any, op PatchOp) error {
+	field, index, err := parsePatchPath(op.Path)
+	if err != nil {
+		return err
+	}
+	if unpatchable[field] {
+		return fmt.Errorf("%s cannot be patched", field)
+	}
+	cur, ok := doc[field]
+	if !ok {
+		return fmt.Errorf("unknown field %q", field)
+	}
+	list, isList := cur.([]any)
+	if cur == nil {
+		list, isList = []any{}, true
+	}
+	if index != "" && !isList {
+		return fmt.Errorf("%s is not a list", field)
+	}
+
+	switch op.Op {
+	case "replace", "add":
+		if op.Value == nil {
+			return errors.New("value is required")
+		}
+		if index == "" {
+			doc[field] = op.Value
+			return nil
+		}
+		if op.Op == "add" && index == "-" {
+			doc[field] = append(list, op.Value)
+			return nil
+		}
+		i, err := listIndex(index, len(list), op.Op == "add")
+		if err != nil {
+			return err
+		}
+		if op.Op == "replace" {
+			list[i] = op.Value
+			return nil
+		}
+		doc[field] = append(list[:i], append([]any{op.Value}, list[i:]...)...)
+		return nil
+	case "remove":
+		if !isList {
+			return fmt.Errorf("%s is not a list; use replace", field)
+		}
+		if index != "" {
+			i, err := listIndex(index, len(list), false)
+			if err != nil {
+				return err
+			}
+			doc[field] = append(list[:i], list[i+1:]...)
+			return nil
+		}
+		if op.Value == nil {
+			doc[field] = []any{}
+			return nil
+		}
+		name, ok := op.Value.(string)
+		if !ok {
+			return errors.New("value must be a name")
+		}
+		kept := make([]any, 0, len(list))
+		for _, el := range list {
+			if !elementNamed(el, name) {
+				kept = append(kept, el)
+			}
+		}
+		if len(kept) == len(list) {
+			return fmt.Errorf("no %s entry matches %q", field, name)
+		}
+		doc[field] = kept
+		return nil
+	default:
+		return fmt.Errorf("unknown op %q", op.Op)
+	}
+}
+
+// parsePatchPath splits a JSON Pointer of the form /field or /field/index.
+func parsePatchPath(path string) (field, index string, err error) {
+	rest, ok := strings.CutPrefix(path, "/")
+	if !ok || rest == "" {
+		return "", "", fmt.Errorf("path %q must be /field or /field/index", path)
+	}
+	tokens := strings.Split(rest, "/")
+	if len(tokens) > 2 {
+		return "", "", fmt.Errorf("path %q must be /field or /field/index", path)
+	}
+	unescape := strings.NewReplacer("~1", "/", "~0", "~")
+	field = unescape.Replace(tokens[0])
+	if len(tokens) == 2 {
+		index = tokens[1]
+	}
+	return field, index, nil
+}
+
+// listIndex parses a list index; insert allows the position after the end.
+func listIndex(raw string, n int, insert bool) (int, error) {
+	i, err := strconv.Atoi(raw)
+	last := n - 1
+	if insert {
+		last = n
+	}
+	if err != nil || i < 0 || i > last {
+		return 0, fmt.Errorf("index %s out of range (list has %d entries)", raw, n)
+	}
+	return i, nil
+}
+
+// elementNamed matches a string element, or an object by its name field.
+func elementNamed(el any, name string) bool {
+	if m, ok := el.(map[string]any); ok {
+		el = m["name"]
+	}
+	s, ok := el.(string)
+	return ok && strings.EqualFold(strings.TrimSpace(s), strings.TrimSpace(name))
+}
@@ -0,0 +1,175 @@
+package analysis
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+var nitrateIntake = Intake{
+	PatientName: "Pedro Nitrate",
+	Age:         62,
+	WeightKg:    88,
+	HeightCm:    172,
+	BP:          "150/95",
+	Complaint:   "ED",
+	Conditions:  []string{"heart disease"},
+	Medications: []Medication{{Name: "amlodipine", Dosage: "5mg"}, {Name: "nitroglycerin", Dosage: "0.4mg SL"}},
+}
+
+func TestWhatIf(t *testing.T) {
+	store := audit.NewMemoryStore()
+	a := New(WithAuditStore(store))
+	base := a.Analyze(nitrateIntake)
+
+	got, err := a.WhatIf(t.Context(), WhatIfRequest{AuditID: base.AuditID, Patch: []PatchOp{
+		{Op: "remove", Path: "/medications", Value: "Nitroglycerin"},
+		{Op: "replace", Path: "/bp", Value: "130/85"},
+	}}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Original.RiskScore != base.RiskScore || got.Original.AuditID != "" || got.Hypothetical.AuditID != "" {
+		t.Fatalf("original %d (audit %q), base %d; hypothetical audit %q", got.Original.RiskScore, got.Original.AuditID, base.RiskScore, got.Hypothetical.AuditID)
+	}
+	d := got.Diff
+	if d.RiskScoreDelta >= 0 || d.RiskScoreDelta != d.RiskScoreAfter-d.RiskScoreBefore {
+		t.Fatalf("removing the nitrate and lowering BP should lower risk: %+v", d)
+	}
+	var removed []string
+	for _, is := range d.IssuesRemoved {
+		removed = append(removed, is.Code)
+	}
+	if !strings.Contains(strings.Join(removed, ","), "CI_NITRATE_PDE5") {
+		t.Fatalf("issues removed = %v", removed)
+	}
+	if latest, _ := store.Latest(t.Context(), 10); len(latest) != 1 {
+		t.Fatalf("dry run wrote %d audits", len(latest)-1)
+	}
+
+	recorded, err := a.WhatIf(t.Context(), WhatIfRequest{AuditID: base.AuditID, Record: true}, Options{})
+	if err != nil || recorded.Hypothetical.AuditID == "" {
+		t.Fatalf("record: %+v (err %v)", recorded.Hypothetical, err)
+	}
+	latest, _ := store.Latest(t.Context(), 10)
+	if len(latest) != 2 || latest[1].PatientRef != latest[0].PatientRef {
+		t.Fatalf("recorded what-if should be filed under the same patient: %+v", latest)
+	}
+}
+
+func TestWhatIf_StoredIntakeHasNoName(t *testing.T) {
+	dir := t.TempDir()
+	store, err := audit.NewSQLiteStore(filepath.Join(dir, "audit.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := New(WithAuditStore(store))
+	base := a.Analyze(nitrateIntake)
+	raw, err := store.Intake(t.Context(), base.AuditID)
+	if err != nil || len(raw) == 0 {
+		t.Fatalf("intake not stored: %v", err)
+	}
+	ref := a.PatientRef(nitrateIntake.PatientName)
+	if strings.Contains(string(raw), "Pedro") || !strings.Contains(string(raw), `"patientRef":"`+ref+`"`) {
+		t.Fatalf("stored intake should carry %s and not the name: %s", ref, raw)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "audit.db*"))
+	for _, f := range files {
+		if b, _ := os.ReadFile(f); bytes.Contains(b, []byte("Pedro")) {
+			t.Fatalf("%s contains the name", f)
+		}
+	}
+}
+
+func TestSetStoreIntakes(t *testing.T) {
+	store := audit.NewMemoryStore()
+	a := New(WithAuditStore(store))
+	kept := a.Analyze(nitrateIntake)
+	a.SetStoreIntakes(false)
+	dropped := a.Analyze(nitrateIntake)
+
+	rec, err := a.AuditRecord(t.Context(), kept.AuditID)
+	if err != nil || rec.Intake == nil || rec.Intake.PatientName != "" || rec.Intake.PatientRef == "" || rec.Response.AuditID != kept.AuditID {
+		t.Fatalf("kept record: %+v (err %v)", rec, err)
+	}
+	rec, err = a.AuditRecord(t.Context(), dropped.AuditID)
+	if err != nil || rec.Intake != nil {
+		t.Fatalf("dropped record: %+v (err %v)", rec, err)
+	}
+	if _, err := a.WhatIf(t.Context(), WhatIfRequest{AuditID: dropped.AuditID}, Options{}); !errors.Is(err, ErrNoIntake) {
+		t.Fatalf("what-if without intake: %v", err)
+	}
+}
+
+func TestWhatIf_Errors(t *testing.T) {
+	a := New()
+	base := a.Analyze(nitrateIntake)
+	if _, err := a.WhatIf(t.Context(), WhatIfRequest{AuditID: "missing"}, Options{}); !errors.Is(err, audit.ErrNotFound) {
+		t.Fatalf("missing audit: %v", err)
+	}
+	if _, err := New(WithAuditStore(latestOnlyStore{audit.NewMemoryStore()})).WhatIf(t.Context(), WhatIfRequest{AuditID: "x"}, Options{}); err != ErrIntakesUnsupported {
+		t.Fatalf("unsupported store: %v", err)
+	}
+
+	_, err := a.WhatIf(t.Context(), WhatIfRequest{AuditID: base.AuditID, Patch: []PatchOp{
+		{Op: "replace", Path: "/bp", Value: "130/85"},
+		{Op: "replace", Path: "/age", Value: "sixty"},
+	}}, Options{})
+	var pe *PatchError
+	if !errors.As(err, &pe) || pe.Index != 1 || !strings.Contains(pe.Error(), "patch[1] replace /age") {
+		t.Fatalf("bad value should name the operation, got %v", err)
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	cases := []struct {
+		name  string
+		op    PatchOp
+		check func(Intake) bool
+		err   string
+	}{
+		{"append", PatchOp{Op: "add", Path: "/medications/-", Value: map[string]any{"name": "sildenafil"}},
+			func(in Intake) bool { return len(in.Medications) == 3 && in.Medications[2].Name == "sildenafil" }, ""},
+		{"insert", PatchOp{Op: "add", Path: "/conditions/0", Value: "diabetes"},
+			func(in Intake) bool { return in.Conditions[0] == "diabetes" && len(in.Conditions) == 2 }, ""},
+		{"remove index", PatchOp{Op: "remove", Path: "/medications/0"},
+			func(in Intake) bool { return len(in.Medications) == 1 && in.Medications[0].Name == "nitroglycerin" }, ""},
+		{"clear list", PatchOp{Op: "remove", Path: "/conditions"},
+			func(in Intake) bool { return len(in.Conditions) == 0 }, ""},
+		{"replace number", PatchOp{Op: "replace", Path: "/weight", Value: 70.5},
+			func(in Intake) bool { return in.WeightKg == 70.5 }, ""},
+		{"unknown field", PatchOp{Op: "replace", Path: "/pulse", Value: 80}, nil, `unknown field "pulse"`},
+		{"name fixed", PatchOp{Op: "replace", Path: "/patientName", Value: "X"}, nil, "cannot be patched"},
+		{"no match", PatchOp{Op: "remove", Path: "/medications", Value: "aspirin"}, nil, `no medications entry matches "aspirin"`},
+		{"out of range", PatchOp{Op: "remove", Path: "/medications/5"}, nil, "out of range"},
+		{"scalar remove", PatchOp{Op: "remove", Path: "/bp"}, nil, "use replace"},
+		{"bad op", PatchOp{Op: "move", Path: "/bp"}, nil, `unknown op "move"`},
+		{"bad path", PatchOp{Op: "replace", Path: "bp", Value: "1/1"}, nil, "must be /field"},
+		{"missing value", PatchOp{Op: "replace", Path: "/bp"}, nil, "value is required"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := applyPatch(nitrateIntake, []PatchOp{tc.op})
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("err = %v, want %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil || !tc.check(got) {
+				t.Fatalf("got %+v (err %v)", got, err)
+			}
+		})
+	}
+	if nitrateIntake.Medications[0].Name != "amlodipine" || len(nitrateIntake.Medications) != 2 {
+		t.Fatal("applyPatch modified its input")
+	}
+}
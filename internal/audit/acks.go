@@ -0,0 +1,216 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrAlreadyAcknowledged is returned when an issue of an audit is
+// acknowledged twice.
+var ErrAlreadyAcknowledged = errors.New("audit: issue already acknowledged")
+
+// IssueAck is a clinician's acknowledgment of one issue flagged on an
+// audited analysis. Medications are the issue's related medications when it
+// was acknowledged, so a later analysis can tell whether it is the same
+// finding.
+type IssueAck struct {
+	AuditID     string
+	IssueCode   string
+	Medications []string
+	UserID      string
+	Reason      string
+	At          time.Time
+}
+
+// IssueAckStore is implemented by stores that keep issue acknowledgments.
+type IssueAckStore interface {
+	// InsertIssueAck records a against an existing audit of the ctx org and
+	// returns it with At set, failing with ErrNotFound for an unknown or
+	// deleted audit and ErrAlreadyAcknowledged for a repeat.
+	InsertIssueAck(ctx context.Context, a IssueAck) (IssueAck, error)
+	// IssueAcks returns the acknowledgments on ref's audits in the ctx org,
+	// newest first. Deleted audits' acknowledgments are left out.
+	IssueAcks(ctx context.Context, ref string) ([]IssueAck, error)
+}
+
+// ackRowID binds an encrypted acknowledgment reason to one audit and issue.
+func ackRowID(auditID, code string) string {
+	return auditID + "#ack:" + code
+}
+
+func (s *SQLiteStore) InsertIssueAck(ctx context.Context, a IssueAck) (IssueAck, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if a.At.IsZero() {
+		a.At = time.Now().UTC()
+	}
+	meds, err := json.Marshal(slices.Clip(a.Medications))
+	if err != nil {
+		return IssueAck{}, fmt.Errorf("insert issue ack: %w", err)
+	}
+	reason, err := encryptColumn(s.cipher, "reason", ackRowID(a.AuditID, a.IssueCode), a.Reason)
+	if err != nil {
+		return IssueAck{}, fmt.Errorf("insert issue ack: %w", err)
+	}
+	err = retryBusy(ctx, func() error {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var audits, acks int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM audits WHERE id = ? AND org_id = ? AND deleted_at IS NULL`, a.AuditID, OrgFrom(ctx)).Scan(&audits); err != nil {
+			return err
+		}
+		if audits == 0 {
+			return ErrNotFound
+		}
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM issue_acks WHERE audit_id = ? AND issue_code = ?`, a.AuditID, a.IssueCode).Scan(&acks); err != nil {
+			return err
+		}
+		if acks > 0 {
+			return ErrAlreadyAcknowledged
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO issue_acks (audit_id, issue_code, medications_json, user_id, reason, at_utc)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, a.AuditID, a.IssueCode, string(meds), a.UserID, reason, a.At.Format(time.RFC3339)); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		return IssueAck{}, fmt.Errorf("insert issue ack: %w", err)
+	}
+	return a, nil
+}
+
+func (s *SQLiteStore) IssueAcks(ctx context.Context, ref string) ([]IssueAck, error) {
+	if ref == "" {
+		return []IssueAck{}, nil
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT k.audit_id, k.issue_code, k.medications_json, k.user_id, k.reason, k.at_utc
+		FROM issue_acks k
+		JOIN audits a ON a.id = k.audit_id
+		WHERE a.patient_key = ? AND a.deleted_at IS NULL AND a.org_id = ?
+		ORDER BY k.at_utc DESC, k.rowid DESC
+	`, patientKey(s.cipher, ref), OrgFrom(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("query issue acks: %w", err)
+	}
+	defer rows.Close()
+
+	out := []IssueAck{}
+	for rows.Next() {
+		var a IssueAck
+		var meds, userID, reason, at sql.NullString
+		if err := rows.Scan(&a.AuditID, &a.IssueCode, &meds, &userID, &reason, &at); err != nil {
+			return nil, fmt.Errorf("scan issue ack: %w", err)
+		}
+		if meds.String != "" {
+			if err := json.Unmarshal([]byte(meds.String), &a.Medications); err != nil {
+				return nil, fmt.Errorf("issue ack %s medications: %w", ackRowID(a.AuditID, a.IssueCode), err)
+			}
+		}
+		if a.Reason, err = decryptColumn(s.cipher, "reason", ackRowID(a.AuditID, a.IssueCode), reason.String); err != nil {
+			return nil, fmt.Errorf("issue ack %s reason: %w", ackRowID(a.AuditID, a.IssueCode), err)
+		}
+		a.UserID = userID.String
+		a.At, _ = time.Parse(time.RFC3339, at.String)
+		out = append(out, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read issue acks: %w", err)
+	}
+	return out, nil
+}
+
+// encryptPlaintextAcks seals acknowledgment reasons written before a key was
+// configured; it runs inside EncryptPlaintextRows' transaction.
+func (s *SQLiteStore) encryptPlaintextAcks(tx *sql.Tx) (int, error) {
+	rows, err := tx.Query(`SELECT audit_id, issue_code, reason FROM issue_acks WHERE COALESCE(reason, '') != ''`)
+	if err != nil {
+		return 0, fmt.Errorf("query issue acks: %w", err)
+	}
+	type row struct{ auditID, code, reason string }
+	var pending []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.auditID, &r.code, &r.reason); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan issue ack: %w", err)
+		}
+		if !strings.HasPrefix(r.reason, encPrefix) {
+			pending = append(pending, r)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("iterate issue acks: %w", err)
+	}
+
+	for _, r := range pending {
+		rowID := ackRowID(r.auditID, r.code)
+		reason, err := encryptColumn(s.cipher, "reason", rowID, r.reason)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(`UPDATE issue_acks SET reason = ? WHERE audit_id = ? AND issue_code = ?`, reason, r.auditID, r.code); err != nil {
+			return 0, fmt.Errorf("update issue ack %s: %w", rowID, err)
+		}
+	}
+	return len(pending), nil
+}
+
+func (m *MemoryStore) InsertIssueAck(ctx context.Context, a IssueAck) (IssueAck, error) {
+	if err := ctx.Err(); err != nil {
+		return IssueAck{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.live(ctx, a.AuditID); !ok {
+		return IssueAck{}, ErrNotFound
+	}
+	if slices.ContainsFunc(m.acks[a.AuditID], func(k IssueAck) bool { return k.IssueCode == a.IssueCode }) {
+		return IssueAck{}, ErrAlreadyAcknowledged
+	}
+	if a.At.IsZero() {
+		a.At = time.Now().UTC()
+	}
+	a.Medications = slices.Clone(a.Medications)
+	m.acks[a.AuditID] = append(m.acks[a.AuditID], a)
+	return a, nil
+}
+
+func (m *MemoryStore) IssueAcks(ctx context.Context, ref string) ([]IssueAck, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []IssueAck{}
+	if ref == "" {
+		return out, nil
+	}
+	for _, e := range m.entries {
+		if e.PatientRef != ref || e.DeletedAt != "" || !m.retained(ctx, e.AuditID) {
+			continue
+		}
+		for _, a := range m.acks[e.AuditID] {
+			a.Medications = slices.Clone(a.Medications)
+			out = append(out, a)
+		}
+	}
+	// Entries are oldest first; acknowledgments are listed newest first.
+	slices.SortStableFunc(out, func(x, y IssueAck) int { return y.At.Compare(x.At) })
+	return out, nil
+}
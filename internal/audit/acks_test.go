@@ -0,0 +1,72 @@
+package audit
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestIssueAcks(t *testing.T) {
+	stores := map[string]interface {
+		Store
+		SoftDeleter
+		IssueAckStore
+	}{
+		"memory":    NewMemoryStore(),
+		"sqlite":    openStore(t, filepath.Join(t.TempDir(), "plain.db"), nil),
+		"encrypted": openStore(t, filepath.Join(t.TempDir(), "enc.db"), testKey(8)),
+	}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			at := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+			for _, e := range []Entry{{ID: "a1", PatientRef: "PT-1"}, {ID: "a2", PatientRef: "PT-1"}, {ID: "b1", PatientRef: "PT-2"}} {
+				if _, err := s.Insert(t.Context(), e); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ack := IssueAck{AuditID: "a1", IssueCode: "INT_AMLODIPINE_PDE5", Medications: []string{"amlodipine", "sildenafil"}, UserID: "dr-a", Reason: "BP stable on both", At: at}
+			if _, err := s.InsertIssueAck(t.Context(), ack); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.InsertIssueAck(t.Context(), ack); !errors.Is(err, ErrAlreadyAcknowledged) {
+				t.Fatalf("second ack: %v", err)
+			}
+			if _, err := s.InsertIssueAck(t.Context(), IssueAck{AuditID: "missing", IssueCode: "X"}); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("unknown audit: %v", err)
+			}
+			if _, err := s.InsertIssueAck(WithOrg(t.Context(), "clinic-b"), IssueAck{AuditID: "a1", IssueCode: "Y"}); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("other org: %v", err)
+			}
+			if _, err := s.InsertIssueAck(t.Context(), IssueAck{AuditID: "a2", IssueCode: "BP_STAGE1", Reason: "home readings normal", At: at.Add(time.Hour)}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.InsertIssueAck(t.Context(), IssueAck{AuditID: "b1", IssueCode: "BP_STAGE1", Reason: "other patient", At: at}); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := s.IssueAcks(t.Context(), "PT-1")
+			if err != nil || len(got) != 2 {
+				t.Fatalf("acks = %+v (err %v)", got, err)
+			}
+			if got[0].IssueCode != "BP_STAGE1" || got[1].Reason != "BP stable on both" || got[1].UserID != "dr-a" ||
+				!slices.Equal(got[1].Medications, ack.Medications) || !got[1].At.Equal(at) {
+				t.Fatalf("acks = %+v", got)
+			}
+			if other, err := s.IssueAcks(WithOrg(t.Context(), "clinic-b"), "PT-1"); err != nil || len(other) != 0 {
+				t.Fatalf("other org's acks = %+v (err %v)", other, err)
+			}
+
+			if err := s.SoftDelete(t.Context(), "a2", "duplicate", "dr-a"); err != nil {
+				t.Fatal(err)
+			}
+			if got, err := s.IssueAcks(t.Context(), "PT-1"); err != nil || len(got) != 1 || got[0].AuditID != "a1" {
+				t.Fatalf("acks after delete = %+v (err %v)", got, err)
+			}
+			if _, err := s.InsertIssueAck(t.Context(), IssueAck{AuditID: "a2", IssueCode: "Z"}); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("ack on a deleted audit: %v", err)
+			}
+		})
+	}
+}
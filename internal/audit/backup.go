@@ -0,0 +1,178 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Backups are named audit-<UTC timestamp>.db, so names sort oldest first.
+const (
+	backupPrefix     = "audit-"
+	backupSuffix     = ".db"
+	backupTimeLayout = "20060102T150405.000000Z"
+)
+
+// BackupInfo describes a snapshot written by Backup.
+type BackupInfo struct {
+	Path      string `json:"path"`
+	SizeBytes int64  `json:"sizeBytes"`
+	At        string `json:"at"`
+	// Pruned lists the older backups removed to honor the retention count.
+	Pruned []string `json:"pruned,omitempty"`
+}
+
+// BackupStore is implemented by stores that can snapshot themselves while
+// serving.
+type BackupStore interface {
+	// Backup writes a consistent snapshot into dir and then removes all but
+	// the keep newest backups there; keep <= 0 keeps them all.
+	Backup(ctx context.Context, dir string, keep int) (BackupInfo, error)
+}
+
+// Backup snapshots the database with VACUUM INTO, which reads in one
+// transaction and so does not block writers. Encrypted columns stay
+// encrypted in the copy. The snapshot is written under a temporary name and
+// renamed, so a failed backup never looks like a complete one.
+func (s *SQLiteStore) Backup(ctx context.Context, dir string, keep int) (BackupInfo, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return BackupInfo{}, fmt.Errorf("backup dir: %w", err)
+	}
+	at := time.Now().UTC()
+	path := filepath.Join(dir, backupPrefix+at.Format(backupTimeLayout)+backupSuffix)
+	tmp := path + ".tmp"
+	if _, err := s.db.ExecContext(ctx, `VACUUM INTO ?`, tmp); err != nil {
+		os.Remove(tmp)
+		return BackupInfo{}, fmt.Errorf("backup sqlite: %w", err)
+	}
+	// The snapshot holds patient data; keep it private to the service user.
+	if err := os.Chmod(tmp, 0o600); err != nil {
+		os.Remove(tmp)
+		return BackupInfo{}, fmt.Errorf("backup sqlite: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return BackupInfo{}, fmt.Errorf("backup sqlite: %w", err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return BackupInfo{}, fmt.Errorf("backup sqlite: %w", err)
+	}
+	info := BackupInfo{Path: path, SizeBytes: fi.Size(), At: at.Format(time.RFC3339)}
+	if keep > 0 {
+		backups, err := listBackups(dir)
+		if err != nil {
+			return info, err
+		}
+		for _, old := range backups[:max(0, len(backups)-keep)] {
+			if err := os.Remove(old); err != nil {
+				return info, fmt.Errorf("prune backup: %w", err)
+			}
+			info.Pruned = append(info.Pruned, old)
+		}
+	}
+	return info, nil
+}
+
+// listBackups returns the paths of the backups in dir, oldest first.
+func listBackups(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("list backups: %w", err)
+	}
+	var paths []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.Type().IsRegular() && strings.HasPrefix(name, backupPrefix) && strings.HasSuffix(name, backupSuffix) {
+			paths = append(paths, filepath.Join(dir, name))
+		}
+	}
+	slices.Sort(paths)
+	return paths, nil
+}
+
+// LatestBackup returns the path of the newest backup in dir, or ErrNotFound
+// when there is none.
+func LatestBackup(dir string) (string, error) {
+	backups, err := listBackups(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(backups) == 0 {
+		return "", ErrNotFound
+	}
+	return backups[len(backups)-1], nil
+}
+
+// CheckSQLite reports whether the database file at path exists and passes
+// SQLite's quick integrity check.
+func CheckSQLite(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	db, err := openSQLite(path)
+	if err != nil {
+		return fmt.Errorf("open sqlite: %w", err)
+	}
+	defer db.Close()
+	var result string
+	if err := db.QueryRow(`PRAGMA quick_check`).Scan(&result); err != nil {
+		return fmt.Errorf("check sqlite: %w", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("check sqlite: %s", result)
+	}
+	return nil
+}
+
+// Restore replaces the database at path with the backup, which must pass
+// CheckSQLite. It is meant for startup, before a store is opened on path. An
+// existing database and its WAL files are kept beside it as
+// <path>.unusable-<timestamp> rather than deleted.
+func Restore(path, backup string) error {
+	if err := CheckSQLite(backup); err != nil {
+		return fmt.Errorf("restore from %s: %w", backup, err)
+	}
+	src, err := os.Open(backup)
+	if err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	defer src.Close()
+	tmp := path + ".restore"
+	dst, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	_, err = io.Copy(dst, src)
+	if err == nil {
+		err = dst.Sync()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("restore: %w", err)
+	}
+
+	// The WAL files go with the database so they are not replayed onto the
+	// restored copy.
+	aside := path + ".unusable-" + time.Now().UTC().Format(backupTimeLayout)
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		if err := os.Rename(path+suffix, aside+suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			os.Remove(tmp)
+			return fmt.Errorf("restore: set aside %s: %w", path+suffix, err)
+		}
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("restore: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package audit
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBackupRestore(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.db")
+	backups := filepath.Join(dir, "backups")
+	s, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	for _, id := range []string{"a", "b", "c"} {
+		if _, err := s.Insert(t.Context(), Entry{ID: id, RiskLevel: "LOW", At: at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := LatestBackup(backups); err == nil {
+		t.Fatal("latest backup found before any was taken")
+	}
+	var last BackupInfo
+	for i := range 3 {
+		last, err = s.Backup(t.Context(), backups, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := max(0, i-1); len(last.Pruned) != want {
+			t.Fatalf("backup %d pruned %v, want %d", i, last.Pruned, want)
+		}
+	}
+	if fi, err := os.Stat(last.Path); err != nil || fi.Size() != last.SizeBytes || fi.Mode().Perm() != 0o600 {
+		t.Fatalf("backup %+v: stat %v, %v", last, fi, err)
+	}
+	if kept, _ := listBackups(backups); len(kept) != 2 {
+		t.Fatalf("kept %v, want 2 backups", kept)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, bytes.Repeat([]byte("corrupt!"), 512), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckSQLite(path); err == nil {
+		t.Fatal("corrupt database passed the check")
+	}
+	if err := CheckSQLite(filepath.Join(dir, "missing.db")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("missing database: %v", err)
+	}
+	latest, err := LatestBackup(backups)
+	if err != nil || latest != last.Path {
+		t.Fatalf("latest backup = %q, %v; want %q", latest, err, last.Path)
+	}
+	if err := Restore(path, latest); err != nil {
+		t.Fatal(err)
+	}
+	if aside, _ := filepath.Glob(path + ".unusable-*"); len(aside) != 1 {
+		t.Errorf("set-aside copies = %v", aside)
+	}
+	restored := openStore(t, path, nil)
+	sums, err := restored.Latest(t.Context(), 10)
+	if err != nil || len(sums) != 3 {
+		t.Fatalf("restored %d rows, %v; want 3", len(sums), err)
+	}
+	if err := Restore(path, path+".unusable-missing"); err == nil {
+		t.Error("restore from a missing backup succeeded")
+	}
+}
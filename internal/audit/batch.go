@@ -0,0 +1,107 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+)
+
+// BatchInserter is implemented by stores that can write many entries at
+// once.
+type BatchInserter interface {
+	// InsertBatch writes entries, returning one Summary per entry in order.
+	// Entries that fail are reported by index in a *BatchInsertError and
+	// have a zero Summary while the others are still written; any other
+	// error means none were.
+	InsertBatch(ctx context.Context, entries []Entry) ([]Summary, error)
+}
+
+// BatchInsertError reports the entries InsertBatch could not write.
+type BatchInsertError struct {
+	// Errs has one element per entry, nil where the entry was written.
+	Errs []error
+}
+
+func (e *BatchInsertError) Error() string {
+	failed := 0
+	var first error
+	for _, err := range e.Errs {
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			failed++
+		}
+	}
+	return fmt.Sprintf("insert audit batch: %d of %d entries failed, first: %v", failed, len(e.Errs), first)
+}
+
+// InsertBatch writes entries with the prepared insert in one transaction,
+// taking the writer lock once. A failed insert only rolls back its own
+// statement, so the rest of the batch still commits.
+func (s *SQLiteStore) InsertBatch(ctx context.Context, entries []Entry) ([]Summary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rows := make([]auditRow, len(entries))
+	rowErrs := make([]error, len(entries))
+	for i, e := range entries {
+		rows[i], rowErrs[i] = s.auditRow(e, OrgFrom(ctx))
+	}
+	var errs []error
+	err := retryBusy(ctx, func() error {
+		errs = append([]error(nil), rowErrs...)
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		stmt := tx.StmtContext(ctx, s.insert)
+		for i, row := range rows {
+			if errs[i] != nil {
+				continue
+			}
+			if _, err := stmt.ExecContext(ctx, row.args...); err != nil {
+				if isBusy(err) {
+					return err
+				}
+				errs[i] = fmt.Errorf("insert audit: %w", err)
+			}
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("insert audit batch: %w", err)
+	}
+	return batchResult(rows, errs)
+}
+
+func (m *MemoryStore) InsertBatch(ctx context.Context, entries []Entry) ([]Summary, error) {
+	sums := make([]Summary, len(entries))
+	errs := make([]error, len(entries))
+	for i, e := range entries {
+		sums[i], errs[i] = m.Insert(ctx, e)
+	}
+	for _, err := range errs {
+		if err != nil {
+			return sums, &BatchInsertError{Errs: errs}
+		}
+	}
+	return sums, nil
+}
+
+// batchResult pairs rows with their write errors.
+func batchResult(rows []auditRow, errs []error) ([]Summary, error) {
+	sums := make([]Summary, len(rows))
+	failed := false
+	for i, row := range rows {
+		if errs[i] != nil {
+			failed = true
+			continue
+		}
+		sums[i] = row.summary
+	}
+	if failed {
+		return sums, &BatchInsertError{Errs: errs}
+	}
+	return sums, nil
+}
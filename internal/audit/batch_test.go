@@ -0,0 +1,92 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestInsertBatch(t *testing.T) {
+	stores := map[string]interface {
+		Store
+		BatchInserter
+	}{
+		"memory": NewMemoryStore(),
+		"sqlite": openStore(t, filepath.Join(t.TempDir(), "batch.db"), nil),
+	}
+	at := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			if _, err := s.Insert(t.Context(), Entry{ID: "taken", At: at}); err != nil {
+				t.Fatal(err)
+			}
+			entries := []Entry{
+				{ID: "a", At: at, RiskLevel: "LOW"},
+				{ID: "b", At: at.Add(time.Second), RiskLevel: "HIGH", RiskScore: 9},
+				{At: at.Add(2 * time.Second)},
+			}
+			sums, err := s.InsertBatch(t.Context(), entries)
+			if err != nil || len(sums) != 3 || sums[0].AuditID != "a" || sums[1].RiskScore != 9 || sums[2].AuditID == "" {
+				t.Fatalf("InsertBatch = %+v, %v", sums, err)
+			}
+			if got, _ := s.Latest(t.Context(), 10); len(got) != 4 {
+				t.Fatalf("stored %d entries, want 4", len(got))
+			}
+		})
+	}
+
+	// SQLite rejects a duplicate ID; the rest of the batch still commits.
+	s := stores["sqlite"]
+	sums, err := s.InsertBatch(t.Context(), []Entry{{ID: "c", At: at}, {ID: "taken", At: at}, {ID: "d", At: at}})
+	var batchErr *BatchInsertError
+	if !errors.As(err, &batchErr) || len(batchErr.Errs) != 3 || batchErr.Errs[0] != nil || batchErr.Errs[1] == nil || batchErr.Errs[2] != nil {
+		t.Fatalf("duplicate in batch: %v", err)
+	}
+	if sums[0].AuditID != "c" || sums[1].AuditID != "" || sums[2].AuditID != "d" {
+		t.Fatalf("summaries = %+v", sums)
+	}
+	if got, _ := s.Latest(t.Context(), 10); len(got) != 6 {
+		t.Fatalf("stored %d entries, want 6", len(got))
+	}
+}
+
+// Results are recorded in testdata/benchmarks.txt:
+//
+//	go test ./internal/audit -run '^$' -bench Insert -benchmem
+
+const benchBatchSize = 500
+
+func benchEntries(n int) []Entry {
+	entries := make([]Entry, n)
+	for i := range entries {
+		entries[i] = Entry{PatientRef: fmt.Sprintf("ref-%d", i%50), Complaint: "ED", RiskLevel: "MEDIUM", RiskScore: 5,
+			Response: []byte(`{"riskLevel":"MEDIUM","riskScore":5}`)}
+	}
+	return entries
+}
+
+func BenchmarkInsertLoop500(b *testing.B) {
+	s := openStore(b, filepath.Join(b.TempDir(), "bench.db"), nil)
+	entries := benchEntries(benchBatchSize)
+	b.ReportAllocs()
+	for b.Loop() {
+		for _, e := range entries {
+			if _, err := s.Insert(b.Context(), e); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkInsertBatch500(b *testing.B) {
+	s := openStore(b, filepath.Join(b.TempDir(), "bench.db"), nil)
+	entries := benchEntries(benchBatchSize)
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := s.InsertBatch(b.Context(), entries); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
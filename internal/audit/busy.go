@@ -0,0 +1,59 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	busyTimeoutMs = 5000
+	// maxOpenConns lets WAL readers run alongside the single writer.
+	maxOpenConns   = 8
+	busyRetries    = 5
+	busyRetryDelay = 10 * time.Millisecond
+)
+
+// sqliteDSN applies WAL journaling and a busy timeout to every pooled
+// connection; pragmas must be in the DSN because each connection is separate.
+func sqliteDSN(path string) string {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + fmt.Sprintf("_pragma=journal_mode(WAL)&_pragma=busy_timeout(%d)&_pragma=synchronous(NORMAL)", busyTimeoutMs)
+}
+
+// isBusy reports SQLITE_BUSY / SQLITE_LOCKED, including extended codes.
+func isBusy(err error) bool {
+	var coded interface{ Code() int }
+	if errors.As(err, &coded) {
+		switch coded.Code() & 0xff {
+		case 5, 6:
+			return true
+		}
+	}
+	return err != nil && strings.Contains(err.Error(), "database is locked")
+}
+
+// retryBusy runs fn, retrying busy errors with exponential backoff until ctx is
+// done. busy_timeout already waits inside SQLite; this covers the cases it
+// returns early.
+func retryBusy(ctx context.Context, fn func() error) error {
+	delay := busyRetryDelay
+	var err error
+	for attempt := 0; attempt <= busyRetries; attempt++ {
+		if err = fn(); err == nil || !isBusy(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+	return err
+}
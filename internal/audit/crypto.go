@@ -0,0 +1,216 @@
+package audit
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// encPrefix marks an encrypted column value; anything else is legacy plaintext.
+const encPrefix = "enc:v1:"
+
+// ErrDecrypt is returned when an encrypted column cannot be opened, typically
+// because the configured key differs from the one used to write it.
+var ErrDecrypt = errors.New("audit: cannot decrypt column (wrong key or corrupted data)")
+
+// ErrNoKey is returned when an encrypted column is read without a key configured.
+var ErrNoKey = errors.New("audit: column is encrypted but no encryption key is configured")
+
+// FieldCipher encrypts individual column values with AES-256-GCM. Each value
+// gets a random nonce stored with the ciphertext, and the column name and row
+// ID are bound as additional data so values cannot be swapped between rows.
+type FieldCipher struct {
+	aead cipher.AEAD
+	// index keys the blind index that lets encrypted patient references be
+	// looked up without decrypting every row.
+	index []byte
+}
+
+// NewFieldCipher builds a cipher from a 32-byte key.
+func NewFieldCipher(key []byte) (*FieldCipher, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("audit: encryption key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("audit: init cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("audit: init gcm: %w", err)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("audit patient_key v1"))
+	return &FieldCipher{aead: aead, index: mac.Sum(nil)}, nil
+}
+
+// ParseKey decodes a 32-byte key given as hex or standard base64.
+func ParseKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if key, err := hex.DecodeString(s); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(s); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	return nil, errors.New("audit: encryption key must be 32 bytes encoded as hex or base64")
+}
+
+// LoadKeyFile reads a key file holding either 32 raw bytes or a hex/base64 encoding.
+func LoadKeyFile(path string) ([]byte, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("audit: read key file: %w", err)
+	}
+	if len(raw) == 32 {
+		return raw, nil
+	}
+	return ParseKey(string(raw))
+}
+
+func (c *FieldCipher) seal(column, id, plaintext string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("audit: nonce: %w", err)
+	}
+	out := c.aead.Seal(nonce, nonce, []byte(plaintext), additionalData(column, id))
+	return encPrefix + base64.StdEncoding.EncodeToString(out), nil
+}
+
+func (c *FieldCipher) open(column, id, value string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encPrefix))
+	if err != nil || len(data) < c.aead.NonceSize() {
+		return "", ErrDecrypt
+	}
+	nonce, ciphertext := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	plain, err := c.aead.Open(nil, nonce, ciphertext, additionalData(column, id))
+	if err != nil {
+		return "", ErrDecrypt
+	}
+	return string(plain), nil
+}
+
+func additionalData(column, id string) []byte {
+	return []byte(column + "|" + id)
+}
+
+// encryptColumn seals value when a cipher is configured; empty values stay empty.
+func encryptColumn(c *FieldCipher, column, id, value string) (string, error) {
+	if c == nil || value == "" {
+		return value, nil
+	}
+	return c.seal(column, id, value)
+}
+
+// decryptColumn opens encrypted values and passes plaintext rows through.
+func decryptColumn(c *FieldCipher, column, id, value string) (string, error) {
+	if !strings.HasPrefix(value, encPrefix) {
+		return value, nil
+	}
+	if c == nil {
+		return "", ErrNoKey
+	}
+	return c.open(column, id, value)
+}
+
+// sensitiveColumns are encrypted when the store has a cipher.
+var sensitiveColumns = []string{"patient_ref", "complaint", "response_json", "intake_json"}
+
+// EncryptPlaintextRows encrypts, in place and in one transaction, every
+// sensitive column still stored as plaintext, decision reasons and plans
+// included. It returns the number of rows changed and is safe to run
+// repeatedly.
+func (s *SQLiteStore) EncryptPlaintextRows() (int, error) {
+	if s.cipher == nil {
+		return 0, ErrNoKey
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id, patient_ref, complaint, response_json, intake_json FROM audits`)
+	if err != nil {
+		return 0, fmt.Errorf("query audits: %w", err)
+	}
+	type row struct {
+		id     string
+		values [4]sql.NullString
+	}
+	var pending []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.values[0], &r.values[1], &r.values[2], &r.values[3]); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan audit: %w", err)
+		}
+		pending = append(pending, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("iterate audits: %w", err)
+	}
+
+	changed := 0
+	for _, r := range pending {
+		var sealed [4]string
+		dirty := false
+		for i, col := range sensitiveColumns {
+			v := r.values[i].String
+			if v == "" || strings.HasPrefix(v, encPrefix) {
+				sealed[i] = v
+				continue
+			}
+			if sealed[i], err = s.cipher.seal(col, r.id, v); err != nil {
+				return 0, err
+			}
+			dirty = true
+		}
+		if !dirty {
+			continue
+		}
+		// The lookup key of a plaintext row is the reference itself; replace it
+		// with the blind index so the reference does not survive in clear.
+		key := sql.NullString{}
+		if ref := r.values[0].String; ref != "" && !strings.HasPrefix(ref, encPrefix) {
+			key = sql.NullString{String: patientKey(s.cipher, ref), Valid: true}
+		}
+		if _, err := tx.Exec(`UPDATE audits SET patient_ref = ?, complaint = ?, response_json = ?, intake_json = ?, patient_key = COALESCE(?, patient_key) WHERE id = ?`,
+			sealed[0], sealed[1], sealed[2], sealed[3], key, r.id); err != nil {
+			return 0, fmt.Errorf("update audit %s: %w", r.id, err)
+		}
+		changed++
+	}
+	decisions, err := s.encryptPlaintextDecisions(tx)
+	if err != nil {
+		return 0, err
+	}
+	changed += decisions
+	deletions, err := s.encryptPlaintextDeletions(tx)
+	if err != nil {
+		return 0, err
+	}
+	changed += deletions
+	acks, err := s.encryptPlaintextAcks(tx)
+	if err != nil {
+		return 0, err
+	}
+	changed += acks
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+	return changed, nil
+}
@@ -0,0 +1,134 @@
+package audit
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func openStore(t testing.TB, path string, key []byte) *SQLiteStore {
+	t.Helper()
+	var opts []SQLiteOption
+	if key != nil {
+		c, err := NewFieldCipher(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts = append(opts, WithCipher(c))
+	}
+	s, err := NewSQLiteStore(path, opts...)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { s.db.Close() })
+	return s
+}
+
+func rawColumn(t *testing.T, s *SQLiteStore, id, column string) string {
+	t.Helper()
+	var v sql.NullString
+	if err := s.db.QueryRow(`SELECT `+column+` FROM audits WHERE id = ?`, id).Scan(&v); err != nil {
+		t.Fatalf("raw %s: %v", column, err)
+	}
+	return v.String
+}
+
+var (
+	sampleResponse = json.RawMessage(`{"riskLevel":"LOW"}`)
+	sampleIntake   = json.RawMessage(`{"age":40,"complaint":"ED"}`)
+)
+
+func TestSQLiteStore_EncryptsSensitiveColumns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.db")
+	s := openStore(t, path, testKey(1))
+	if _, err := s.Insert(t.Context(), Entry{ID: "a1", PatientRef: "p_abc", Complaint: "ED", RiskLevel: "LOW", Response: sampleResponse, Intake: sampleIntake}); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	for _, col := range sensitiveColumns {
+		if v := rawColumn(t, s, "a1", col); !strings.HasPrefix(v, encPrefix) {
+			t.Fatalf("%s stored as plaintext: %q", col, v)
+		}
+	}
+	if rawColumn(t, s, "a1", "risk_level") != "LOW" {
+		t.Fatalf("non-sensitive columns should stay readable")
+	}
+
+	got, err := s.Latest(t.Context(), 1)
+	if err != nil || len(got) != 1 || got[0].PatientRef != "p_abc" || got[0].Complaint != "ED" {
+		t.Fatalf("expected transparent decryption, got %+v (err %v)", got, err)
+	}
+	resp, err := s.Response(t.Context(), "a1")
+	if err != nil || !bytes.Equal(resp, sampleResponse) {
+		t.Fatalf("expected decrypted response, got %s (err %v)", resp, err)
+	}
+	intake, err := s.Intake(t.Context(), "a1")
+	if err != nil || !bytes.Equal(intake, sampleIntake) {
+		t.Fatalf("expected decrypted intake, got %s (err %v)", intake, err)
+	}
+}
+
+func TestSQLiteStore_WrongKeyFailsClearly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.db")
+	s := openStore(t, path, testKey(1))
+	if _, err := s.Insert(t.Context(), Entry{ID: "a1", PatientRef: "p_abc", Complaint: "ED", Response: sampleResponse}); err != nil {
+		t.Fatal(err)
+	}
+
+	wrong := openStore(t, path, testKey(2))
+	if _, err := wrong.Latest(t.Context(), 1); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("expected ErrDecrypt for wrong key, got %v", err)
+	}
+	if _, err := wrong.Response(t.Context(), "a1"); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("expected ErrDecrypt for wrong key, got %v", err)
+	}
+	if _, err := openStore(t, path, nil).Latest(t.Context(), 1); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("expected ErrNoKey without a key, got %v", err)
+	}
+}
+
+func TestSQLiteStore_EncryptPlaintextRows(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.db")
+	plain := openStore(t, path, nil)
+	if _, err := plain.Insert(t.Context(), Entry{ID: "old", PatientRef: "J***", Complaint: "Hair Loss", Response: sampleResponse}); err != nil {
+		t.Fatal(err)
+	}
+	if rawColumn(t, plain, "old", "complaint") != "Hair Loss" {
+		t.Fatalf("without a key columns should be plaintext")
+	}
+
+	enc := openStore(t, path, testKey(3))
+	if got, err := enc.Latest(t.Context(), 1); err != nil || got[0].Complaint != "Hair Loss" {
+		t.Fatalf("plaintext rows must stay readable with a key, got %+v (err %v)", got, err)
+	}
+	n, err := enc.EncryptPlaintextRows()
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 row encrypted, got %d (err %v)", n, err)
+	}
+	if v := rawColumn(t, enc, "old", "complaint"); !strings.HasPrefix(v, encPrefix) {
+		t.Fatalf("complaint not encrypted in place: %q", v)
+	}
+	if n, _ := enc.EncryptPlaintextRows(); n != 0 {
+		t.Fatalf("second run should be a no-op, changed %d rows", n)
+	}
+	if got, err := enc.Latest(t.Context(), 1); err != nil || got[0].PatientRef != "J***" {
+		t.Fatalf("migrated row should decrypt, got %+v (err %v)", got, err)
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	hexKey := strings.Repeat("ab", 32)
+	if k, err := ParseKey(hexKey); err != nil || len(k) != 32 {
+		t.Fatalf("hex key: %v", err)
+	}
+	if _, err := ParseKey("too-short"); err == nil {
+		t.Fatalf("expected short key to be rejected")
+	}
+}
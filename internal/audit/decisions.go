@@ -0,0 +1,379 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Decision values recorded by clinicians.
+const (
+	DecisionApproved = "approved"
+	DecisionModified = "modified"
+	DecisionRejected = "rejected"
+)
+
+// ErrDecisionExists is returned when an audit already has a decision and
+// revisions are not allowed.
+var ErrDecisionExists = errors.New("audit: decision already recorded")
+
+// Decision is a clinician's sign-off on an analysis. Revisions count from 1
+// and the highest one is current.
+type Decision struct {
+	AuditID      string          `json:"auditId"`
+	Revision     int             `json:"revision"`
+	Decision     string          `json:"decision"`
+	ModifiedPlan json.RawMessage `json:"modifiedPlan,omitempty"`
+	Reason       string          `json:"reason,omitempty"`
+	UserID       string          `json:"userId,omitempty"`
+	At           time.Time       `json:"at"`
+}
+
+// DecisionRates counts the current decisions on analyses of one risk level.
+// Rates are shares of decided analyses; an override is a modified or
+// rejected plan.
+type DecisionRates struct {
+	Analyses     int     `json:"analyses"`
+	Decided      int     `json:"decided"`
+	Approved     int     `json:"approved"`
+	Modified     int     `json:"modified"`
+	Rejected     int     `json:"rejected"`
+	ApprovalRate float64 `json:"approvalRate"`
+	OverrideRate float64 `json:"overrideRate"`
+}
+
+// DecisionStats aggregates decisions by the risk level of the analysis.
+type DecisionStats struct {
+	ByRiskLevel map[string]DecisionRates `json:"byRiskLevel"`
+}
+
+// DecisionStore is implemented by stores that can record clinician decisions.
+type DecisionStore interface {
+	// InsertDecision records d against an existing audit and returns it with
+	// Revision and At set. A second decision fails with ErrDecisionExists
+	// unless revise is set, in which case it becomes the next revision.
+	InsertDecision(ctx context.Context, d Decision, revise bool) (Decision, error)
+	// Decisions returns every revision recorded for auditID, oldest first.
+	Decisions(ctx context.Context, auditID string) ([]Decision, error)
+	DecisionStats(ctx context.Context) (DecisionStats, error)
+}
+
+func (r *DecisionRates) count(decision string, n int) {
+	r.Decided += n
+	switch decision {
+	case DecisionApproved:
+		r.Approved += n
+	case DecisionModified:
+		r.Modified += n
+	case DecisionRejected:
+		r.Rejected += n
+	}
+}
+
+func (st DecisionStats) withRates() DecisionStats {
+	for level, r := range st.ByRiskLevel {
+		if r.Decided > 0 {
+			r.ApprovalRate = float64(r.Approved) / float64(r.Decided)
+			r.OverrideRate = float64(r.Modified+r.Rejected) / float64(r.Decided)
+		}
+		st.ByRiskLevel[level] = r
+	}
+	return st
+}
+
+// decisionRowID binds encrypted decision columns to one revision.
+func decisionRowID(auditID string, revision int) string {
+	return auditID + "#" + strconv.Itoa(revision)
+}
+
+func (s *SQLiteStore) InsertDecision(ctx context.Context, d Decision, revise bool) (Decision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if d.At.IsZero() {
+		d.At = time.Now().UTC()
+	}
+	err := retryBusy(ctx, func() error {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var audits, last int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM audits WHERE id = ? AND org_id = ?`, d.AuditID, OrgFrom(ctx)).Scan(&audits); err != nil {
+			return err
+		}
+		if audits == 0 {
+			return ErrNotFound
+		}
+		if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(revision), 0) FROM decisions WHERE audit_id = ?`, d.AuditID).Scan(&last); err != nil {
+			return err
+		}
+		if last > 0 && !revise {
+			return ErrDecisionExists
+		}
+		d.Revision = last + 1
+		rowID := decisionRowID(d.AuditID, d.Revision)
+		plan, err := encryptColumn(s.cipher, "modified_plan", rowID, string(d.ModifiedPlan))
+		if err != nil {
+			return err
+		}
+		reason, err := encryptColumn(s.cipher, "reason", rowID, d.Reason)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO decisions (audit_id, revision, decision, modified_plan, reason, user_id, at_utc)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, d.AuditID, d.Revision, d.Decision, plan, reason, d.UserID, d.At.Format(time.RFC3339)); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		return Decision{}, fmt.Errorf("insert decision: %w", err)
+	}
+	return d, nil
+}
+
+const decisionColumns = `audit_id, revision, decision, modified_plan, reason, user_id, at_utc`
+
+func (s *SQLiteStore) Decisions(ctx context.Context, auditID string) ([]Decision, error) {
+	return s.queryDecisions(ctx, `
+		SELECT `+decisionColumns+`
+		FROM decisions
+		WHERE audit_id = ? AND audit_id IN (SELECT id FROM audits WHERE org_id = ?)
+		ORDER BY revision
+	`, auditID, OrgFrom(ctx))
+}
+
+func (s *SQLiteStore) queryDecisions(ctx context.Context, query string, args ...any) ([]Decision, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query decisions: %w", err)
+	}
+	defer rows.Close()
+
+	out := []Decision{}
+	for rows.Next() {
+		var d Decision
+		var plan, reason, userID, at sql.NullString
+		if err := rows.Scan(&d.AuditID, &d.Revision, &d.Decision, &plan, &reason, &userID, &at); err != nil {
+			return nil, fmt.Errorf("scan decision: %w", err)
+		}
+		rowID := decisionRowID(d.AuditID, d.Revision)
+		p, err := decryptColumn(s.cipher, "modified_plan", rowID, plan.String)
+		if err != nil {
+			return nil, fmt.Errorf("decision %s modified_plan: %w", rowID, err)
+		}
+		if p != "" {
+			d.ModifiedPlan = json.RawMessage(p)
+		}
+		if d.Reason, err = decryptColumn(s.cipher, "reason", rowID, reason.String); err != nil {
+			return nil, fmt.Errorf("decision %s reason: %w", rowID, err)
+		}
+		d.UserID = userID.String
+		d.At, _ = time.Parse(time.RFC3339, at.String)
+		out = append(out, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read decisions: %w", err)
+	}
+	return out, nil
+}
+
+// attachDecisions sets the current decision on each summary that has one.
+func (s *SQLiteStore) attachDecisions(ctx context.Context, sums []Summary) error {
+	if len(sums) == 0 {
+		return nil
+	}
+	ids := make([]any, len(sums))
+	for i, sum := range sums {
+		ids[i] = sum.AuditID
+	}
+	current, err := s.queryDecisions(ctx, `
+		SELECT `+decisionColumns+`
+		FROM decisions d
+		WHERE audit_id IN (?`+strings.Repeat(", ?", len(ids)-1)+`)
+			AND revision = (SELECT MAX(revision) FROM decisions WHERE audit_id = d.audit_id)
+	`, ids...)
+	if err != nil {
+		return err
+	}
+	byID := make(map[string]*Decision, len(current))
+	for i := range current {
+		byID[current[i].AuditID] = &current[i]
+	}
+	for i := range sums {
+		sums[i].Decision = byID[sums[i].AuditID]
+	}
+	return nil
+}
+
+func (s *SQLiteStore) DecisionStats(ctx context.Context) (DecisionStats, error) {
+	out := DecisionStats{ByRiskLevel: map[string]DecisionRates{}}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT a.risk_level, COUNT(*), d.decision
+		FROM audits a
+		LEFT JOIN decisions d ON d.audit_id = a.id
+			AND d.revision = (SELECT MAX(revision) FROM decisions WHERE audit_id = a.id)
+		WHERE a.deleted_at IS NULL AND a.org_id = ?
+		GROUP BY a.risk_level, d.decision
+	`, OrgFrom(ctx))
+	if err != nil {
+		return DecisionStats{}, fmt.Errorf("query decision stats: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var level, decision sql.NullString
+		var n int
+		if err := rows.Scan(&level, &n, &decision); err != nil {
+			return DecisionStats{}, fmt.Errorf("scan decision stats: %w", err)
+		}
+		r := out.ByRiskLevel[level.String]
+		r.Analyses += n
+		if decision.Valid {
+			r.count(decision.String, n)
+		}
+		out.ByRiskLevel[level.String] = r
+	}
+	if err := rows.Err(); err != nil {
+		return DecisionStats{}, fmt.Errorf("read decision stats: %w", err)
+	}
+	return out.withRates(), nil
+}
+
+func (m *MemoryStore) InsertDecision(ctx context.Context, d Decision, revise bool) (Decision, error) {
+	if err := ctx.Err(); err != nil {
+		return Decision{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.retained(ctx, d.AuditID) {
+		return Decision{}, ErrNotFound
+	}
+	prev := m.decisions[d.AuditID]
+	if len(prev) > 0 && !revise {
+		return Decision{}, ErrDecisionExists
+	}
+	if d.At.IsZero() {
+		d.At = time.Now().UTC()
+	}
+	d.Revision = len(prev) + 1
+	d.ModifiedPlan = append(json.RawMessage(nil), d.ModifiedPlan...)
+	m.decisions[d.AuditID] = append(prev, d)
+	return d, nil
+}
+
+func (m *MemoryStore) Decisions(ctx context.Context, auditID string) ([]Decision, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.retained(ctx, auditID) {
+		return []Decision{}, nil
+	}
+	return append([]Decision{}, m.decisions[auditID]...), nil
+}
+
+func (m *MemoryStore) DecisionStats(ctx context.Context) (DecisionStats, error) {
+	if err := ctx.Err(); err != nil {
+		return DecisionStats{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := DecisionStats{ByRiskLevel: map[string]DecisionRates{}}
+	for _, e := range m.entries {
+		if e.DeletedAt != "" || !m.retained(ctx, e.AuditID) {
+			continue
+		}
+		r := out.ByRiskLevel[e.RiskLevel]
+		r.Analyses++
+		if d := m.currentDecision(e.AuditID); d != nil {
+			r.count(d.Decision, 1)
+		}
+		out.ByRiskLevel[e.RiskLevel] = r
+	}
+	return out.withRates(), nil
+}
+
+// withDecisions sets the current decision on sums, which the caller has copied
+// out of m.entries while holding m.mu.
+func (m *MemoryStore) withDecisions(sums []Summary) []Summary {
+	for i := range sums {
+		sums[i].Decision = m.currentDecision(sums[i].AuditID)
+	}
+	return sums
+}
+
+func (m *MemoryStore) currentDecision(auditID string) *Decision {
+	ds := m.decisions[auditID]
+	if len(ds) == 0 {
+		return nil
+	}
+	d := ds[len(ds)-1]
+	return &d
+}
+
+// retained reports whether auditID is still held for the org of ctx.
+func (m *MemoryStore) retained(ctx context.Context, auditID string) bool {
+	org, ok := m.orgs[auditID]
+	return ok && org == OrgFrom(ctx)
+}
+
+// encryptPlaintextDecisions seals decision reasons and plans written before a
+// key was configured; it runs inside EncryptPlaintextRows' transaction.
+func (s *SQLiteStore) encryptPlaintextDecisions(tx *sql.Tx) (int, error) {
+	rows, err := tx.Query(`SELECT audit_id, revision, modified_plan, reason FROM decisions`)
+	if err != nil {
+		return 0, fmt.Errorf("query decisions: %w", err)
+	}
+	type row struct {
+		auditID      string
+		revision     int
+		plan, reason sql.NullString
+	}
+	var pending []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.auditID, &r.revision, &r.plan, &r.reason); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan decision: %w", err)
+		}
+		if (r.plan.String != "" && !strings.HasPrefix(r.plan.String, encPrefix)) ||
+			(r.reason.String != "" && !strings.HasPrefix(r.reason.String, encPrefix)) {
+			pending = append(pending, r)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("iterate decisions: %w", err)
+	}
+
+	for _, r := range pending {
+		rowID := decisionRowID(r.auditID, r.revision)
+		plan, reason := r.plan.String, r.reason.String
+		if !strings.HasPrefix(plan, encPrefix) {
+			if plan, err = encryptColumn(s.cipher, "modified_plan", rowID, plan); err != nil {
+				return 0, err
+			}
+		}
+		if !strings.HasPrefix(reason, encPrefix) {
+			if reason, err = encryptColumn(s.cipher, "reason", rowID, reason); err != nil {
+				return 0, err
+			}
+		}
+		if _, err := tx.Exec(`UPDATE decisions SET modified_plan = ?, reason = ? WHERE audit_id = ? AND revision = ?`,
+			plan, reason, r.auditID, r.revision); err != nil {
+			return 0, fmt.Errorf("update decision %s: %w", rowID, err)
+		}
+	}
+	return len(pending), nil
+}
@@ -0,0 +1,113 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDecisions(t *testing.T) {
+	stores := map[string]interface {
+		Store
+		DecisionStore
+	}{
+		"memory":    NewMemoryStore(),
+		"sqlite":    openStore(t, filepath.Join(t.TempDir(), "plain.db"), nil),
+		"encrypted": openStore(t, filepath.Join(t.TempDir(), "enc.db"), testKey(6)),
+	}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			for _, e := range []Entry{
+				{ID: "low-1", RiskLevel: "LOW"}, {ID: "low-2", RiskLevel: "LOW"}, {ID: "high-1", RiskLevel: "HIGH"},
+			} {
+				if _, err := s.Insert(t.Context(), e); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := s.InsertDecision(t.Context(), Decision{AuditID: "missing", Decision: DecisionApproved}, false); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("unknown audit: %v", err)
+			}
+			d, err := s.InsertDecision(t.Context(), Decision{AuditID: "low-1", Decision: DecisionApproved, UserID: "dr-a"}, false)
+			if err != nil || d.Revision != 1 || d.At.IsZero() {
+				t.Fatalf("first decision: %+v (err %v)", d, err)
+			}
+			if _, err := s.InsertDecision(t.Context(), Decision{AuditID: "low-1", Decision: DecisionRejected}, false); !errors.Is(err, ErrDecisionExists) {
+				t.Fatalf("second decision without revisions: %v", err)
+			}
+			plan := json.RawMessage(`{"medication":"Tadalafil"}`)
+			d, err = s.InsertDecision(t.Context(), Decision{AuditID: "low-1", Decision: DecisionModified, ModifiedPlan: plan, Reason: "prefers daily dosing"}, true)
+			if err != nil || d.Revision != 2 {
+				t.Fatalf("revision: %+v (err %v)", d, err)
+			}
+			if _, err := s.InsertDecision(t.Context(), Decision{AuditID: "high-1", Decision: DecisionRejected, Reason: "refer to cardiology"}, false); err != nil {
+				t.Fatal(err)
+			}
+
+			all, err := s.Decisions(t.Context(), "low-1")
+			if err != nil || len(all) != 2 || all[0].Decision != DecisionApproved || string(all[1].ModifiedPlan) != string(plan) || all[1].Reason != "prefers daily dosing" {
+				t.Fatalf("decisions = %+v (err %v)", all, err)
+			}
+
+			latest, err := s.Latest(t.Context(), 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			current := map[string]string{}
+			for _, sum := range latest {
+				if sum.Decision != nil {
+					current[sum.AuditID] = sum.Decision.Decision
+				}
+			}
+			if len(current) != 2 || current["low-1"] != DecisionModified || current["high-1"] != DecisionRejected {
+				t.Fatalf("listing should carry the current decision: %v", current)
+			}
+
+			stats, err := s.DecisionStats(t.Context())
+			if err != nil {
+				t.Fatal(err)
+			}
+			low, high := stats.ByRiskLevel["LOW"], stats.ByRiskLevel["HIGH"]
+			if low.Analyses != 2 || low.Decided != 1 || low.Modified != 1 || low.OverrideRate != 1 || low.ApprovalRate != 0 {
+				t.Fatalf("LOW = %+v", low)
+			}
+			if high.Analyses != 1 || high.Rejected != 1 || high.OverrideRate != 1 {
+				t.Fatalf("HIGH = %+v", high)
+			}
+		})
+	}
+}
+
+func TestDecisions_EncryptedAtRest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.db")
+	plain := openStore(t, path, nil)
+	if _, err := plain.Insert(t.Context(), Entry{ID: "a1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := plain.InsertDecision(t.Context(), Decision{AuditID: "a1", Decision: DecisionRejected, Reason: "history of MI"}, false); err != nil {
+		t.Fatal(err)
+	}
+	reason := func(s *SQLiteStore) string {
+		var v string
+		if err := s.db.QueryRow(`SELECT reason FROM decisions WHERE audit_id = 'a1'`).Scan(&v); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	if reason(plain) != "history of MI" {
+		t.Fatal("without a key the reason should be plaintext")
+	}
+
+	enc := openStore(t, path, testKey(7))
+	if _, err := enc.EncryptPlaintextRows(); err != nil {
+		t.Fatal(err)
+	}
+	if v := reason(enc); !strings.HasPrefix(v, encPrefix) {
+		t.Fatalf("reason not encrypted in place: %q", v)
+	}
+	got, err := enc.Decisions(t.Context(), "a1")
+	if err != nil || len(got) != 1 || got[0].Reason != "history of MI" {
+		t.Fatalf("decisions = %+v (err %v)", got, err)
+	}
+}
@@ -0,0 +1,262 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Deletion trail actions.
+const (
+	ActionDeleted  = "deleted"
+	ActionRestored = "restored"
+)
+
+var (
+	// ErrAlreadyDeleted is returned when soft-deleting an audit that is
+	// already deleted.
+	ErrAlreadyDeleted = errors.New("audit: entry already deleted")
+	// ErrNotDeleted is returned when restoring an audit that is not deleted.
+	ErrNotDeleted = errors.New("audit: entry not deleted")
+)
+
+// Deletion is one soft deletion or restore of an audit. Events count from 1
+// per audit, so the trail reads in order with no gaps.
+type Deletion struct {
+	AuditID string    `json:"auditId"`
+	Seq     int       `json:"seq"`
+	Action  string    `json:"action"`
+	Reason  string    `json:"reason,omitempty"`
+	UserID  string    `json:"userId,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// SoftDeleter is implemented by stores that can hide an audit without
+// erasing it. A deleted audit stays readable by ID but is left out of
+// Latest, the patient and ruleset listings, re-analysis ranges, and every
+// statistic.
+type SoftDeleter interface {
+	// SoftDelete marks audit id deleted and records why and by whom. It
+	// fails with ErrNotFound or ErrAlreadyDeleted.
+	SoftDelete(ctx context.Context, id, reason, userID string) error
+	// Restore clears the mark left by SoftDelete and records the restore. It
+	// fails with ErrNotFound or ErrNotDeleted.
+	Restore(ctx context.Context, id string) error
+	// LatestIncludingDeleted is Latest with deleted audits listed too, their
+	// DeletedAt set.
+	LatestIncludingDeleted(ctx context.Context, limit int) ([]Summary, error)
+	// Deletions returns the deletion trail of auditID, oldest first.
+	Deletions(ctx context.Context, auditID string) ([]Deletion, error)
+}
+
+// deletionRowID binds an encrypted deletion reason to one trail event.
+func deletionRowID(auditID string, seq int) string {
+	return auditID + "#del" + strconv.Itoa(seq)
+}
+
+func (s *SQLiteStore) SoftDelete(ctx context.Context, id, reason, userID string) error {
+	return s.recordDeletion(ctx, Deletion{AuditID: id, Action: ActionDeleted, Reason: reason, UserID: userID})
+}
+
+func (s *SQLiteStore) Restore(ctx context.Context, id string) error {
+	return s.recordDeletion(ctx, Deletion{AuditID: id, Action: ActionRestored})
+}
+
+// recordDeletion sets or clears deleted_at on the audit and appends d to its
+// trail in one transaction.
+func (s *SQLiteStore) recordDeletion(ctx context.Context, d Deletion) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d.At = time.Now().UTC()
+	err := retryBusy(ctx, func() error {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var deletedAt sql.NullString
+		err = tx.QueryRowContext(ctx, `SELECT deleted_at FROM audits WHERE id = ? AND org_id = ?`, d.AuditID, OrgFrom(ctx)).Scan(&deletedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		mark := sql.NullString{}
+		switch {
+		case d.Action == ActionDeleted && deletedAt.Valid:
+			return ErrAlreadyDeleted
+		case d.Action == ActionRestored && !deletedAt.Valid:
+			return ErrNotDeleted
+		case d.Action == ActionDeleted:
+			mark = sql.NullString{String: d.At.Format(time.RFC3339), Valid: true}
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE audits SET deleted_at = ? WHERE id = ?`, mark, d.AuditID); err != nil {
+			return err
+		}
+		if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(seq), 0) + 1 FROM audit_deletions WHERE audit_id = ?`, d.AuditID).Scan(&d.Seq); err != nil {
+			return err
+		}
+		reason, err := encryptColumn(s.cipher, "reason", deletionRowID(d.AuditID, d.Seq), d.Reason)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO audit_deletions (audit_id, seq, action, reason, user_id, at_utc)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, d.AuditID, d.Seq, d.Action, reason, d.UserID, d.At.Format(time.RFC3339)); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		return fmt.Errorf("mark audit %s: %w", d.Action, err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) LatestIncludingDeleted(ctx context.Context, limit int) ([]Summary, error) {
+	if limit <= 0 || limit > maxLimit {
+		limit = 10
+	}
+	return s.querySummaries(ctx, `
+		SELECT `+summaryColumns+`
+		FROM audits
+		WHERE org_id = ?
+		ORDER BY at_utc DESC
+		LIMIT ?
+	`, OrgFrom(ctx), limit)
+}
+
+func (s *SQLiteStore) Deletions(ctx context.Context, auditID string) ([]Deletion, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT audit_id, seq, action, reason, user_id, at_utc
+		FROM audit_deletions
+		WHERE audit_id = ? AND audit_id IN (SELECT id FROM audits WHERE org_id = ?)
+		ORDER BY seq
+	`, auditID, OrgFrom(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("query deletions: %w", err)
+	}
+	defer rows.Close()
+
+	out := []Deletion{}
+	for rows.Next() {
+		var d Deletion
+		var reason, userID, at sql.NullString
+		if err := rows.Scan(&d.AuditID, &d.Seq, &d.Action, &reason, &userID, &at); err != nil {
+			return nil, fmt.Errorf("scan deletion: %w", err)
+		}
+		rowID := deletionRowID(d.AuditID, d.Seq)
+		if d.Reason, err = decryptColumn(s.cipher, "reason", rowID, reason.String); err != nil {
+			return nil, fmt.Errorf("deletion %s reason: %w", rowID, err)
+		}
+		d.UserID = userID.String
+		d.At, _ = time.Parse(time.RFC3339, at.String)
+		out = append(out, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read deletions: %w", err)
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) SoftDelete(ctx context.Context, id, reason, userID string) error {
+	return m.recordDeletion(ctx, Deletion{AuditID: id, Action: ActionDeleted, Reason: reason, UserID: userID})
+}
+
+func (m *MemoryStore) Restore(ctx context.Context, id string) error {
+	return m.recordDeletion(ctx, Deletion{AuditID: id, Action: ActionRestored})
+}
+
+func (m *MemoryStore) recordDeletion(ctx context.Context, d Deletion) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.entries {
+		e := &m.entries[i]
+		if e.AuditID != d.AuditID || !m.retained(ctx, e.AuditID) {
+			continue
+		}
+		switch {
+		case d.Action == ActionDeleted && e.DeletedAt != "":
+			return ErrAlreadyDeleted
+		case d.Action == ActionRestored && e.DeletedAt == "":
+			return ErrNotDeleted
+		}
+		d.At = time.Now().UTC()
+		d.Seq = len(m.deletions[d.AuditID]) + 1
+		e.DeletedAt = ""
+		if d.Action == ActionDeleted {
+			e.DeletedAt = d.At.Format(time.RFC3339)
+		}
+		m.deletions[d.AuditID] = append(m.deletions[d.AuditID], d)
+		return nil
+	}
+	return ErrNotFound
+}
+
+func (m *MemoryStore) LatestIncludingDeleted(ctx context.Context, limit int) ([]Summary, error) {
+	return m.latest(ctx, limit, true)
+}
+
+func (m *MemoryStore) Deletions(ctx context.Context, auditID string) ([]Deletion, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.retained(ctx, auditID) {
+		return []Deletion{}, nil
+	}
+	return append([]Deletion{}, m.deletions[auditID]...), nil
+}
+
+// encryptPlaintextDeletions seals deletion reasons written before a key was
+// configured; it runs inside EncryptPlaintextRows' transaction.
+func (s *SQLiteStore) encryptPlaintextDeletions(tx *sql.Tx) (int, error) {
+	rows, err := tx.Query(`SELECT audit_id, seq, reason FROM audit_deletions WHERE COALESCE(reason, '') != ''`)
+	if err != nil {
+		return 0, fmt.Errorf("query deletions: %w", err)
+	}
+	type row struct {
+		auditID string
+		seq     int
+		reason  string
+	}
+	var pending []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.auditID, &r.seq, &r.reason); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan deletion: %w", err)
+		}
+		if !strings.HasPrefix(r.reason, encPrefix) {
+			pending = append(pending, r)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("iterate deletions: %w", err)
+	}
+
+	for _, r := range pending {
+		rowID := deletionRowID(r.auditID, r.seq)
+		reason, err := encryptColumn(s.cipher, "reason", rowID, r.reason)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(`UPDATE audit_deletions SET reason = ? WHERE audit_id = ? AND seq = ?`, reason, r.auditID, r.seq); err != nil {
+			return 0, fmt.Errorf("update deletion %s: %w", rowID, err)
+		}
+	}
+	return len(pending), nil
+}
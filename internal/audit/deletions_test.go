@@ -0,0 +1,118 @@
+package audit
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSoftDelete(t *testing.T) {
+	stores := map[string]interface {
+		Store
+		SoftDeleter
+		SummaryReader
+		DecisionStore
+		PatientHistory
+	}{
+		"memory":    NewMemoryStore(),
+		"sqlite":    openStore(t, filepath.Join(t.TempDir(), "plain.db"), nil),
+		"encrypted": openStore(t, filepath.Join(t.TempDir(), "enc.db"), testKey(8)),
+	}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			at := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+			for i, id := range []string{"keep", "drop"} {
+				if _, err := s.Insert(t.Context(), Entry{ID: id, PatientRef: "PT-1", RiskLevel: "LOW", At: at.Add(time.Duration(i) * time.Minute)}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := s.SoftDelete(t.Context(), "missing", "duplicate", "dr-a"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("unknown audit: %v", err)
+			}
+			if err := s.Restore(t.Context(), "drop"); !errors.Is(err, ErrNotDeleted) {
+				t.Fatalf("restore before delete: %v", err)
+			}
+			if err := s.SoftDelete(t.Context(), "drop", "entered for the wrong patient", "dr-a"); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.SoftDelete(t.Context(), "drop", "again", "dr-a"); !errors.Is(err, ErrAlreadyDeleted) {
+				t.Fatalf("second delete: %v", err)
+			}
+
+			ids := func(sums []Summary) string {
+				var out []string
+				for _, sum := range sums {
+					out = append(out, sum.AuditID)
+				}
+				return strings.Join(out, ",")
+			}
+			if latest, err := s.Latest(t.Context(), 10); err != nil || ids(latest) != "keep" {
+				t.Fatalf("latest = %s (err %v)", ids(latest), err)
+			}
+			if history, err := s.ListByPatientRef(t.Context(), "PT-1", 10); err != nil || ids(history) != "keep" {
+				t.Fatalf("patient history = %s (err %v)", ids(history), err)
+			}
+			if stats, err := s.DecisionStats(t.Context()); err != nil || stats.ByRiskLevel["LOW"].Analyses != 1 {
+				t.Fatalf("stats = %+v (err %v)", stats, err)
+			}
+			all, err := s.LatestIncludingDeleted(t.Context(), 10)
+			if err != nil || len(all) != 2 {
+				t.Fatalf("including deleted = %+v (err %v)", all, err)
+			}
+			for _, sum := range all {
+				if (sum.DeletedAt != "") != (sum.AuditID == "drop") {
+					t.Errorf("%s deletedAt = %q", sum.AuditID, sum.DeletedAt)
+				}
+			}
+			if sum, err := s.Summary(t.Context(), "drop"); err != nil || sum.DeletedAt == "" {
+				t.Fatalf("deleted audit should stay readable by ID: %+v (err %v)", sum, err)
+			}
+
+			if err := s.Restore(t.Context(), "drop"); err != nil {
+				t.Fatal(err)
+			}
+			if latest, err := s.Latest(t.Context(), 10); err != nil || len(latest) != 2 {
+				t.Fatalf("latest after restore = %s (err %v)", ids(latest), err)
+			}
+			trail, err := s.Deletions(t.Context(), "drop")
+			if err != nil || len(trail) != 2 {
+				t.Fatalf("trail = %+v (err %v)", trail, err)
+			}
+			if d := trail[0]; d.Seq != 1 || d.Action != ActionDeleted || d.Reason != "entered for the wrong patient" || d.UserID != "dr-a" || d.At.IsZero() {
+				t.Errorf("delete event = %+v", d)
+			}
+			if d := trail[1]; d.Seq != 2 || d.Action != ActionRestored {
+				t.Errorf("restore event = %+v", d)
+			}
+		})
+	}
+}
+
+func TestSoftDelete_EncryptedAtRest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.db")
+	plain := openStore(t, path, nil)
+	if _, err := plain.Insert(t.Context(), Entry{ID: "a1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.SoftDelete(t.Context(), "a1", "test patient", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	enc := openStore(t, path, testKey(9))
+	if _, err := enc.EncryptPlaintextRows(); err != nil {
+		t.Fatal(err)
+	}
+	var v string
+	if err := enc.db.QueryRow(`SELECT reason FROM audit_deletions WHERE audit_id = 'a1'`).Scan(&v); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(v, encPrefix) {
+		t.Fatalf("reason not encrypted in place: %q", v)
+	}
+	got, err := enc.Deletions(t.Context(), "a1")
+	if err != nil || len(got) != 1 || got[0].Reason != "test patient" {
+		t.Fatalf("deletions = %+v (err %v)", got, err)
+	}
+}
@@ -0,0 +1,148 @@
+package audit
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// DayDurations summarizes the analyses audited on one UTC day. Percentiles
+// are nearest-rank, in milliseconds.
+type DayDurations struct {
+	Day      string  `json:"day"`
+	Analyses int     `json:"analyses"`
+	P50Ms    float64 `json:"p50Ms"`
+	P95Ms    float64 `json:"p95Ms"`
+	LLMP50Ms float64 `json:"llmP50Ms"`
+	LLMP95Ms float64 `json:"llmP95Ms"`
+}
+
+// DurationStats lists DayDurations oldest first. Days without timed analyses,
+// including everything audited before durations were recorded, are omitted.
+type DurationStats struct {
+	ByDay []DayDurations `json:"byDay"`
+}
+
+// DurationStore is implemented by stores that keep analysis durations.
+type DurationStore interface {
+	// DurationStats aggregates the analyses audited at or after since.
+	DurationStats(ctx context.Context, since time.Time) (DurationStats, error)
+}
+
+// durationSample is one audit's recorded durations.
+type durationSample struct {
+	Day           string
+	DurationMs    float64
+	LLMDurationMs float64
+}
+
+func (s *SQLiteStore) DurationStats(ctx context.Context, since time.Time) (DurationStats, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT at_utc, duration_ms, COALESCE(llm_duration_ms, 0)
+		FROM audits
+		WHERE duration_ms IS NOT NULL AND at_utc >= ? AND deleted_at IS NULL AND org_id = ?
+		ORDER BY at_utc
+	`, since.UTC().Format(time.RFC3339), OrgFrom(ctx))
+	if err != nil {
+		return DurationStats{}, fmt.Errorf("query duration stats: %w", err)
+	}
+	defer rows.Close()
+	var samples []durationSample
+	for rows.Next() {
+		var at string
+		var d durationSample
+		if err := rows.Scan(&at, &d.DurationMs, &d.LLMDurationMs); err != nil {
+			return DurationStats{}, fmt.Errorf("scan duration stats: %w", err)
+		}
+		d.Day = dayOf(at)
+		samples = append(samples, d)
+	}
+	if err := rows.Err(); err != nil {
+		return DurationStats{}, fmt.Errorf("read duration stats: %w", err)
+	}
+	return durationStats(samples), nil
+}
+
+func (m *MemoryStore) DurationStats(ctx context.Context, since time.Time) (DurationStats, error) {
+	if err := ctx.Err(); err != nil {
+		return DurationStats{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cutoff := since.UTC().Format(time.RFC3339)
+	var samples []durationSample
+	for _, e := range m.entries {
+		if e.DurationMs == 0 || e.At < cutoff || e.DeletedAt != "" || !m.retained(ctx, e.AuditID) {
+			continue
+		}
+		samples = append(samples, durationSample{Day: dayOf(e.At), DurationMs: e.DurationMs, LLMDurationMs: e.LLMDurationMs})
+	}
+	slices.SortStableFunc(samples, func(a, b durationSample) int {
+		return cmp.Compare(a.Day, b.Day)
+	})
+	return durationStats(samples), nil
+}
+
+// dayOf returns the date of an RFC 3339 UTC timestamp.
+func dayOf(at string) string {
+	if len(at) < len(time.DateOnly) {
+		return at
+	}
+	return at[:len(time.DateOnly)]
+}
+
+// durationStats groups samples, which are ordered by day, into DayDurations.
+func durationStats(samples []durationSample) DurationStats {
+	out := DurationStats{ByDay: []DayDurations{}}
+	for start := 0; start < len(samples); {
+		end := start
+		for end < len(samples) && samples[end].Day == samples[start].Day {
+			end++
+		}
+		total := make([]float64, 0, end-start)
+		llm := make([]float64, 0, end-start)
+		for _, s := range samples[start:end] {
+			total = append(total, s.DurationMs)
+			llm = append(llm, s.LLMDurationMs)
+		}
+		out.ByDay = append(out.ByDay, DayDurations{
+			Day:      samples[start].Day,
+			Analyses: end - start,
+			P50Ms:    percentile(total, 50),
+			P95Ms:    percentile(total, 95),
+			LLMP50Ms: percentile(llm, 50),
+			LLMP95Ms: percentile(llm, 95),
+		})
+		start = end
+	}
+	return out
+}
+
+// percentile returns the nearest-rank p-th percentile of values, sorting them
+// in place.
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	slices.Sort(values)
+	rank := int(math.Ceil(p / 100 * float64(len(values))))
+	return values[max(rank, 1)-1]
+}
+
+// durationMs converts d for the duration columns, which stay NULL for
+// entries that were not timed.
+func durationMs(d time.Duration) sql.NullFloat64 {
+	if d <= 0 {
+		return sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: millis(d), Valid: true}
+}
+
+// millis is d in milliseconds, to the microsecond.
+func millis(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Microsecond)) / 1000
+}
@@ -0,0 +1,65 @@
+package audit
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDurationStats(t *testing.T) {
+	stores := map[string]interface {
+		Store
+		DurationStore
+	}{
+		"memory": NewMemoryStore(),
+		"sqlite": openStore(t, filepath.Join(t.TempDir(), "durations.db"), nil),
+	}
+	day1 := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	entries := []Entry{
+		{ID: "old", At: day1.AddDate(0, 0, -5), Duration: time.Second},
+		{ID: "untimed", At: day1},
+		{ID: "a", At: day1.Add(time.Minute), Duration: 40 * time.Millisecond, LLMDuration: 30 * time.Millisecond},
+		{ID: "b", At: day1.Add(2 * time.Minute), Duration: 10 * time.Millisecond, LLMDuration: 5 * time.Millisecond},
+		{ID: "c", At: day1.Add(3 * time.Minute), Duration: 20 * time.Millisecond},
+		{ID: "d", At: day2, Duration: 1500 * time.Microsecond},
+	}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			for _, e := range entries {
+				if _, err := s.Insert(t.Context(), e); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := s.DurationStats(t.Context(), day1.Truncate(24*time.Hour))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []DayDurations{
+				{Day: "2025-03-01", Analyses: 3, P50Ms: 20, P95Ms: 40, LLMP50Ms: 5, LLMP95Ms: 30},
+				{Day: "2025-03-02", Analyses: 1, P50Ms: 1.5, P95Ms: 1.5},
+			}
+			if len(got.ByDay) != len(want) {
+				t.Fatalf("stats = %+v, want %+v", got.ByDay, want)
+			}
+			for i := range want {
+				if got.ByDay[i] != want[i] {
+					t.Errorf("day %d = %+v, want %+v", i, got.ByDay[i], want[i])
+				}
+			}
+
+			sums, err := s.Latest(t.Context(), 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, sum := range sums {
+				if sum.AuditID == "a" && (sum.DurationMs != 40 || sum.LLMDurationMs != 30) {
+					t.Errorf("summary a = %+v", sum)
+				}
+				if sum.AuditID == "untimed" && sum.DurationMs != 0 {
+					t.Errorf("untimed summary = %+v", sum)
+				}
+			}
+		})
+	}
+}
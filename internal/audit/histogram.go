@@ -0,0 +1,155 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Histogram bucket sizes.
+const (
+	BucketDay  = "day"
+	BucketWeek = "week"
+)
+
+// HistogramBucket counts the analyses audited in one day or week, starting on
+// the local date Start.
+type HistogramBucket struct {
+	Start       string         `json:"start"`
+	Analyses    int            `json:"analyses"`
+	ByRiskLevel map[string]int `json:"byRiskLevel"`
+	AvgScore    float64        `json:"avgScore"`
+}
+
+// Histogram lists buckets oldest first, including empty ones, so a chart of
+// it has no gaps. Timezone names the location bucket boundaries are
+// midnights in.
+type Histogram struct {
+	Bucket   string            `json:"bucket"`
+	Timezone string            `json:"timezone"`
+	Buckets  []HistogramBucket `json:"buckets"`
+}
+
+// HistogramStore is implemented by stores that can bucket audits by time.
+type HistogramStore interface {
+	// Histogram counts the analyses audited in [from, to) into BucketDay or
+	// BucketWeek buckets whose boundaries are midnights in from's location;
+	// weeks start on Monday.
+	Histogram(ctx context.Context, from, to time.Time, bucket string) (Histogram, error)
+}
+
+// bucketCounts accumulates one bucket by risk level.
+type bucketCounts struct {
+	byLevel map[string]int
+	score   int
+}
+
+// sqliteBucket are the SQLite date modifiers that, after the zone offset,
+// take a timestamp to the start date of its bucket.
+var sqliteBucket = map[string]string{
+	BucketDay:  "",
+	BucketWeek: ", '-6 days', 'weekday 1'",
+}
+
+// Histogram groups with strftime, one query per span of constant UTC offset
+// in from's location, since SQLite only knows fixed offsets.
+func (s *SQLiteStore) Histogram(ctx context.Context, from, to time.Time, bucket string) (Histogram, error) {
+	mods, ok := sqliteBucket[bucket]
+	if !ok {
+		return Histogram{}, fmt.Errorf("histogram: unknown bucket %q", bucket)
+	}
+	counts := map[string]*bucketCounts{}
+	for start := from; start.Before(to); {
+		end := to
+		if _, zoneEnd := start.ZoneBounds(); !zoneEnd.IsZero() && zoneEnd.Before(to) {
+			end = zoneEnd
+		}
+		_, offset := start.Zone()
+		rows, err := s.db.QueryContext(ctx, `
+			SELECT strftime('%Y-%m-%d', at_utc, ?`+mods+`), risk_level, COUNT(*), COALESCE(SUM(risk_score), 0)
+			FROM audits
+			WHERE at_utc >= ? AND at_utc < ? AND deleted_at IS NULL AND org_id = ?
+			GROUP BY 1, 2
+		`, fmt.Sprintf("%+d seconds", offset), start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339), OrgFrom(ctx))
+		if err != nil {
+			return Histogram{}, fmt.Errorf("query histogram: %w", err)
+		}
+		for rows.Next() {
+			var day, level string
+			var n, score int
+			if err := rows.Scan(&day, &level, &n, &score); err != nil {
+				rows.Close()
+				return Histogram{}, fmt.Errorf("scan histogram: %w", err)
+			}
+			counts[day] = counts[day].add(level, n, score)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return Histogram{}, fmt.Errorf("read histogram: %w", err)
+		}
+		start = end
+	}
+	return histogram(from, to, bucket, counts), nil
+}
+
+func (m *MemoryStore) Histogram(ctx context.Context, from, to time.Time, bucket string) (Histogram, error) {
+	if _, ok := sqliteBucket[bucket]; !ok {
+		return Histogram{}, fmt.Errorf("histogram: unknown bucket %q", bucket)
+	}
+	if err := ctx.Err(); err != nil {
+		return Histogram{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counts := map[string]*bucketCounts{}
+	for _, e := range m.entries {
+		at, err := time.Parse(time.RFC3339, e.At)
+		if err != nil || at.Before(from) || !at.Before(to) || e.DeletedAt != "" || !m.retained(ctx, e.AuditID) {
+			continue
+		}
+		key := bucketStart(at.In(from.Location()), bucket).Format(time.DateOnly)
+		counts[key] = counts[key].add(e.RiskLevel, 1, e.RiskScore)
+	}
+	return histogram(from, to, bucket, counts), nil
+}
+
+func (c *bucketCounts) add(level string, n, score int) *bucketCounts {
+	if c == nil {
+		c = &bucketCounts{byLevel: map[string]int{}}
+	}
+	c.byLevel[level] += n
+	c.score += score
+	return c
+}
+
+// bucketStart is local midnight of the day, or of the Monday, t falls in.
+func bucketStart(t time.Time, bucket string) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if bucket == BucketWeek {
+		day = day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	}
+	return day
+}
+
+// histogram lists every bucket overlapping [from, to), filling in counts.
+func histogram(from, to time.Time, bucket string, counts map[string]*bucketCounts) Histogram {
+	out := Histogram{Bucket: bucket, Timezone: from.Location().String(), Buckets: []HistogramBucket{}}
+	step := 1
+	if bucket == BucketWeek {
+		step = 7
+	}
+	for start := bucketStart(from, bucket); start.Before(to); start = start.AddDate(0, 0, step) {
+		b := HistogramBucket{Start: start.Format(time.DateOnly), ByRiskLevel: map[string]int{}}
+		if c := counts[b.Start]; c != nil {
+			for level, n := range c.byLevel {
+				b.ByRiskLevel[level] = n
+				b.Analyses += n
+			}
+			b.AvgScore = math.Round(float64(c.score)/float64(b.Analyses)*100) / 100
+		}
+		out.Buckets = append(out.Buckets, b)
+	}
+	return out
+}
@@ -0,0 +1,104 @@
+package audit
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistogram(t *testing.T) {
+	stores := map[string]interface {
+		Store
+		HistogramStore
+	}{
+		"memory": NewMemoryStore(),
+		"sqlite": openStore(t, filepath.Join(t.TempDir(), "histogram.db"), nil),
+	}
+	manila, err := time.LoadLocation("Asia/Manila")
+	if err != nil {
+		t.Skip(err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	at := func(s string) time.Time {
+		v, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	entries := []Entry{
+		{ID: "old", At: at("2025-02-20T12:00:00Z"), RiskLevel: "LOW", RiskScore: 1},
+		{ID: "a", At: at("2025-03-03T01:00:00Z"), RiskLevel: "LOW", RiskScore: 2},
+		{ID: "b", At: at("2025-03-03T20:00:00Z"), RiskLevel: "HIGH", RiskScore: 9},
+		{ID: "c", At: at("2025-03-06T10:00:00Z"), RiskLevel: "LOW", RiskScore: 3},
+		// Either side of the New York switch to daylight time on March 9.
+		{ID: "d", At: at("2025-03-09T04:30:00Z"), RiskLevel: "LOW", RiskScore: 4},
+		{ID: "e", At: at("2025-03-10T02:00:00Z"), RiskLevel: "MEDIUM", RiskScore: 6},
+	}
+	date := func(s string, loc *time.Location) time.Time {
+		v, err := time.ParseInLocation(time.DateOnly, s, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	cases := []struct {
+		name     string
+		from, to time.Time
+		bucket   string
+		want     string
+	}{
+		{"utc days", date("2025-03-03", time.UTC), date("2025-03-07", time.UTC), BucketDay,
+			"2025-03-03 2 HIGH:1 LOW:1 5.5; 2025-03-04 0 0; 2025-03-05 0 0; 2025-03-06 1 LOW:1 3"},
+		{"manila days", date("2025-03-03", manila), date("2025-03-07", manila), BucketDay,
+			"2025-03-03 1 LOW:1 2; 2025-03-04 1 HIGH:1 9; 2025-03-05 0 0; 2025-03-06 1 LOW:1 3"},
+		{"new york days", date("2025-03-08", newYork), date("2025-03-11", newYork), BucketDay,
+			"2025-03-08 1 LOW:1 4; 2025-03-09 1 MEDIUM:1 6; 2025-03-10 0 0"},
+		{"utc weeks", date("2025-03-05", time.UTC), date("2025-03-12", time.UTC), BucketWeek,
+			"2025-03-03 2 LOW:2 3.5; 2025-03-10 1 MEDIUM:1 6"},
+	}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			for _, e := range entries {
+				if _, err := s.Insert(t.Context(), e); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, tc := range cases {
+				got, err := s.Histogram(t.Context(), tc.from, tc.to, tc.bucket)
+				if err != nil {
+					t.Fatalf("%s: %v", tc.name, err)
+				}
+				if got.Bucket != tc.bucket || got.Timezone != tc.from.Location().String() {
+					t.Errorf("%s: bucket %q, timezone %q", tc.name, got.Bucket, got.Timezone)
+				}
+				if s := formatBuckets(got.Buckets); s != tc.want {
+					t.Errorf("%s:\n got %s\nwant %s", tc.name, s, tc.want)
+				}
+			}
+			if _, err := s.Histogram(t.Context(), entries[0].At, entries[1].At, "month"); err == nil {
+				t.Error("unknown bucket accepted")
+			}
+		})
+	}
+}
+
+// formatBuckets renders buckets as "start analyses level:n... avgScore".
+func formatBuckets(buckets []HistogramBucket) string {
+	var out []string
+	for _, b := range buckets {
+		parts := []string{b.Start, fmt.Sprint(b.Analyses)}
+		for _, level := range []string{"CRITICAL", "HIGH", "MEDIUM", "LOW"} {
+			if n := b.ByRiskLevel[level]; n > 0 {
+				parts = append(parts, fmt.Sprintf("%s:%d", level, n))
+			}
+		}
+		out = append(out, strings.Join(append(parts, fmt.Sprint(b.AvgScore)), " "))
+	}
+	return strings.Join(out, "; ")
+}
@@ -0,0 +1,22 @@
+package audit
+
+import "github.com/google/uuid"
+
+// IDGenerator produces audit IDs. Implementations must be safe for concurrent use.
+type IDGenerator interface {
+	NewID() string
+}
+
+// UUIDGenerator issues random UUIDv4 IDs; it is the default for both stores.
+type UUIDGenerator struct{}
+
+func (UUIDGenerator) NewID() string {
+	return uuid.NewString()
+}
+
+// IDGeneratorFunc adapts a function to IDGenerator.
+type IDGeneratorFunc func() string
+
+func (f IDGeneratorFunc) NewID() string {
+	return f()
+}
@@ -0,0 +1,48 @@
+package audit
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestUUIDGenerator_UniqueUnderConcurrency(t *testing.T) {
+	const workers, perWorker = 50, 200
+	var (
+		mu   sync.Mutex
+		seen = make(map[string]bool, workers*perWorker)
+		wg   sync.WaitGroup
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ids := make([]string, perWorker)
+			for i := range ids {
+				ids[i] = UUIDGenerator{}.NewID()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, id := range ids {
+				if seen[id] {
+					t.Errorf("duplicate id %s", id)
+				}
+				seen[id] = true
+			}
+		}()
+	}
+	wg.Wait()
+	if len(seen) != workers*perWorker {
+		t.Fatalf("expected %d unique ids, got %d", workers*perWorker, len(seen))
+	}
+}
+
+func TestMemoryStore_KeepsLegacyIDs(t *testing.T) {
+	store := NewMemoryStore()
+	if _, err := store.Insert(t.Context(), Entry{ID: "audit-1700000000000000000", PatientRef: "J***"}); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	got, err := store.Latest(t.Context(), 1)
+	if err != nil || len(got) != 1 || got[0].AuditID != "audit-1700000000000000000" {
+		t.Fatalf("expected legacy id to round-trip, got %+v (err %v)", got, err)
+	}
+}
@@ -0,0 +1,365 @@
+package audit
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// migration is one forward-only schema change. Versions are applied in order
+// and must never be edited once released; add a new migration instead.
+type migration struct {
+	Version int
+	Name    string
+	Up      []string
+}
+
+var migrations = []migration{
+	{
+		Version: 1,
+		Name:    "create audits",
+		Up: []string{`
+			CREATE TABLE IF NOT EXISTS audits (
+				id TEXT PRIMARY KEY,
+				patient_ref TEXT,
+				complaint TEXT,
+				risk_level TEXT,
+				risk_score INTEGER,
+				user_id TEXT,
+				at_utc TEXT
+			)`,
+		},
+	},
+	{
+		Version: 2,
+		Name:    "llm usage columns",
+		Up: []string{
+			`ALTER TABLE audits ADD COLUMN llm_model TEXT`,
+			`ALTER TABLE audits ADD COLUMN llm_prompt_tokens INTEGER`,
+			`ALTER TABLE audits ADD COLUMN llm_completion_tokens INTEGER`,
+			`ALTER TABLE audits ADD COLUMN llm_latency_ms INTEGER`,
+		},
+	},
+	{
+		Version: 3,
+		Name:    "llm shadow comparisons",
+		Up: []string{`
+			CREATE TABLE IF NOT EXISTS llm_shadow (
+				audit_id TEXT PRIMARY KEY,
+				stub_confidence REAL,
+				llm_confidence REAL,
+				delta REAL,
+				error TEXT,
+				model TEXT,
+				latency_ms INTEGER,
+				at_utc TEXT
+			)`,
+		},
+	},
+	{
+		Version: 4,
+		Name:    "prompt version",
+		Up:      []string{`ALTER TABLE audits ADD COLUMN prompt_version TEXT`},
+	},
+	{
+		Version: 5,
+		Name:    "response json",
+		Up:      []string{`ALTER TABLE audits ADD COLUMN response_json TEXT`},
+	},
+	{
+		Version: 6,
+		Name:    "patient lookup key",
+		// patient_key is filled for existing rows by reindexPatientKeys, which
+		// can decrypt patient_ref.
+		Up: []string{
+			`ALTER TABLE audits ADD COLUMN patient_key TEXT`,
+			`CREATE INDEX IF NOT EXISTS audits_patient_key_at ON audits (patient_key, at_utc)`,
+		},
+	},
+	{
+		Version: 7,
+		Name:    "intake json",
+		Up:      []string{`ALTER TABLE audits ADD COLUMN intake_json TEXT`},
+	},
+	{
+		Version: 8,
+		Name:    "clinician decisions",
+		Up: []string{`
+			CREATE TABLE IF NOT EXISTS decisions (
+				audit_id TEXT NOT NULL,
+				revision INTEGER NOT NULL,
+				decision TEXT NOT NULL,
+				modified_plan TEXT,
+				reason TEXT,
+				user_id TEXT,
+				at_utc TEXT,
+				PRIMARY KEY (audit_id, revision)
+			)`,
+		},
+	},
+	{
+		Version: 9,
+		Name:    "patient consent",
+		Up: []string{
+			`ALTER TABLE audits ADD COLUMN consent_given INTEGER`,
+			`ALTER TABLE audits ADD COLUMN consent_at TEXT`,
+			`ALTER TABLE audits ADD COLUMN consent_method TEXT`,
+		},
+	},
+	{
+		Version: 10,
+		Name:    "rules changes",
+		Up: []string{`
+			CREATE TABLE IF NOT EXISTS rules_changes (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				old_version TEXT,
+				new_version TEXT,
+				source TEXT,
+				actor TEXT,
+				at_utc TEXT
+			)`,
+		},
+	},
+	{
+		Version: 11,
+		Name:    "ruleset version",
+		Up: []string{
+			`ALTER TABLE audits ADD COLUMN ruleset_version TEXT`,
+			`CREATE INDEX IF NOT EXISTS audits_ruleset_version_at ON audits (ruleset_version, at_utc)`,
+		},
+	},
+	{
+		Version: 12,
+		Name:    "reanalyses",
+		Up: []string{`
+			CREATE TABLE IF NOT EXISTS reanalyses (
+				id TEXT PRIMARY KEY,
+				audit_id TEXT NOT NULL,
+				ruleset_version_before TEXT,
+				ruleset_version_after TEXT,
+				risk_level_before TEXT,
+				risk_level_after TEXT,
+				risk_score_before INTEGER,
+				risk_score_after INTEGER,
+				changed INTEGER,
+				at_utc TEXT
+			)`,
+			`CREATE INDEX IF NOT EXISTS reanalyses_audit_id ON reanalyses (audit_id)`,
+			`CREATE INDEX IF NOT EXISTS audits_at ON audits (at_utc)`,
+		},
+	},
+	{
+		Version: 13,
+		Name:    "analysis durations",
+		Up: []string{
+			`ALTER TABLE audits ADD COLUMN duration_ms REAL`,
+			`ALTER TABLE audits ADD COLUMN llm_duration_ms REAL`,
+		},
+	},
+	{
+		Version: 14,
+		Name:    "validation failures",
+		Up: []string{`
+			CREATE TABLE IF NOT EXISTS validation_failures (
+				id TEXT PRIMARY KEY,
+				errors_json TEXT NOT NULL,
+				user_id TEXT,
+				at_utc TEXT
+			)`,
+			`CREATE INDEX IF NOT EXISTS validation_failures_at ON validation_failures (at_utc)`,
+		},
+	},
+	{
+		Version: 15,
+		Name:    "soft deletion",
+		Up: []string{
+			`ALTER TABLE audits ADD COLUMN deleted_at TEXT`,
+			`CREATE TABLE IF NOT EXISTS audit_deletions (
+				audit_id TEXT NOT NULL,
+				seq INTEGER NOT NULL,
+				action TEXT NOT NULL,
+				reason TEXT,
+				user_id TEXT,
+				at_utc TEXT,
+				PRIMARY KEY (audit_id, seq)
+			)`,
+		},
+	},
+	{
+		Version: 16,
+		Name:    "organizations",
+		Up: []string{
+			`ALTER TABLE audits ADD COLUMN org_id TEXT NOT NULL DEFAULT ''`,
+			`CREATE INDEX IF NOT EXISTS audits_org_at ON audits (org_id, at_utc)`,
+			`ALTER TABLE validation_failures ADD COLUMN org_id TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		Version: 17,
+		Name:    "response signatures",
+		Up: []string{
+			`ALTER TABLE audits ADD COLUMN signature_key_id TEXT`,
+			`ALTER TABLE audits ADD COLUMN signature_alg TEXT`,
+			`ALTER TABLE audits ADD COLUMN signature TEXT`,
+		},
+	},
+	{
+		Version: 18,
+		Name:    "follow-up reminders",
+		Up: []string{`
+			CREATE TABLE IF NOT EXISTS reminders (
+				id TEXT PRIMARY KEY,
+				audit_id TEXT NOT NULL,
+				instructions TEXT,
+				due_at TEXT NOT NULL,
+				status TEXT NOT NULL,
+				created_at TEXT,
+				overdue_at TEXT,
+				completed_at TEXT,
+				completed_by TEXT
+			)`,
+			`CREATE INDEX IF NOT EXISTS reminders_status_due ON reminders (status, due_at)`,
+			`CREATE INDEX IF NOT EXISTS reminders_audit ON reminders (audit_id)`,
+		},
+	},
+	{
+		Version: 19,
+		Name:    "issue acknowledgments",
+		Up: []string{`
+			CREATE TABLE IF NOT EXISTS issue_acks (
+				audit_id TEXT NOT NULL,
+				issue_code TEXT NOT NULL,
+				medications_json TEXT,
+				user_id TEXT,
+				reason TEXT,
+				at_utc TEXT,
+				PRIMARY KEY (audit_id, issue_code)
+			)`,
+		},
+	},
+}
+
+// SchemaVersion is the schema version this build migrates databases to.
+func SchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// migrate brings db up to SchemaVersion, one transaction per migration. It
+// refuses to run against a database written by a newer build.
+func migrate(db *sql.DB) error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT,
+			applied_at TEXT
+		)
+	`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	current, err := appliedVersion(db)
+	if err != nil {
+		return err
+	}
+	if current == 0 {
+		// Databases created before migrations were tracked: infer how far the
+		// old CREATE/ALTER bootstrap got and record that as applied.
+		if current, err = legacyVersion(db); err != nil {
+			return err
+		}
+		for _, m := range migrations[:current] {
+			if err := recordMigration(db, m); err != nil {
+				return err
+			}
+		}
+	}
+	if current > SchemaVersion() {
+		return fmt.Errorf("audit database schema version %d is newer than supported version %d", current, SchemaVersion())
+	}
+
+	for _, m := range migrations[current:] {
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("migration %d: begin: %w", m.Version, err)
+		}
+		for _, stmt := range m.Up {
+			if _, err := tx.Exec(stmt); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+			}
+		}
+		if err := recordMigration(tx, m); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("migration %d: commit: %w", m.Version, err)
+		}
+	}
+	return nil
+}
+
+func appliedVersion(db *sql.DB) (int, error) {
+	var v sql.NullInt64
+	if err := db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&v); err != nil {
+		return 0, fmt.Errorf("read schema version: %w", err)
+	}
+	return int(v.Int64), nil
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func recordMigration(db execer, m migration) error {
+	_, err := db.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+		m.Version, m.Name, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("record migration %d: %w", m.Version, err)
+	}
+	return nil
+}
+
+// legacyVersion maps an untracked database to the migration it already matches.
+func legacyVersion(db *sql.DB) (int, error) {
+	tables, err := names(db, `SELECT name FROM sqlite_master WHERE type = 'table'`)
+	if err != nil {
+		return 0, err
+	}
+	if !tables["audits"] {
+		return 0, nil
+	}
+	cols, err := names(db, `SELECT name FROM pragma_table_info('audits')`)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case cols["response_json"]:
+		return 5, nil
+	case cols["prompt_version"]:
+		return 4, nil
+	case tables["llm_shadow"]:
+		return 3, nil
+	case cols["llm_model"]:
+		return 2, nil
+	default:
+		return 1, nil
+	}
+}
+
+func names(db *sql.DB, query string) (map[string]bool, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("inspect schema: %w", err)
+	}
+	defer rows.Close()
+	out := map[string]bool{}
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			return nil, fmt.Errorf("inspect schema: %w", err)
+		}
+		out[n] = true
+	}
+	return out, rows.Err()
+}
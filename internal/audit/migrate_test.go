@@ -0,0 +1,73 @@
+package audit
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeV1Database creates a database with the original, untracked schema.
+func writeV1Database(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "v1.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(migrations[0].Up[0]); err != nil {
+		t.Fatalf("create v1 schema: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO audits (id, patient_ref, complaint, risk_level, risk_score, user_id, at_utc)
+		VALUES ('audit-1700000000000000000', 'J***', 'ED', 'HIGH', 9, 'u1', '2024-01-01T00:00:00Z')`); err != nil {
+		t.Fatalf("seed v1 row: %v", err)
+	}
+	return path
+}
+
+func TestMigrate_UpgradesV1Database(t *testing.T) {
+	path := writeV1Database(t)
+	s := openStore(t, path, nil)
+
+	if v, err := appliedVersion(s.db); err != nil || v != SchemaVersion() {
+		t.Fatalf("expected schema version %d, got %d (err %v)", SchemaVersion(), v, err)
+	}
+	var recorded int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&recorded); err != nil || recorded != len(migrations) {
+		t.Fatalf("expected %d recorded migrations, got %d (err %v)", len(migrations), recorded, err)
+	}
+
+	got, err := s.Latest(t.Context(), 10)
+	if err != nil || len(got) != 1 || got[0].AuditID != "audit-1700000000000000000" || got[0].RiskScore != 9 {
+		t.Fatalf("v1 row should survive the upgrade, got %+v (err %v)", got, err)
+	}
+	if _, err := s.Insert(t.Context(), Entry{ID: "new", PatientRef: "p_1", PromptVersion: "abc", Response: sampleResponse}); err != nil {
+		t.Fatalf("insert after upgrade: %v", err)
+	}
+	if err := s.InsertShadow(t.Context(), ShadowEntry{AuditID: "new", StubConfidence: 0.5}); err != nil {
+		t.Fatalf("shadow insert after upgrade: %v", err)
+	}
+}
+
+func TestMigrate_IsIdempotent(t *testing.T) {
+	path := writeV1Database(t)
+	openStore(t, path, nil)
+	s := openStore(t, path, nil)
+	var recorded int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&recorded); err != nil || recorded != len(migrations) {
+		t.Fatalf("reopening should not reapply migrations, got %d rows (err %v)", recorded, err)
+	}
+}
+
+func TestMigrate_RefusesFutureVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "future.db")
+	s := openStore(t, path, nil)
+	if _, err := s.db.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, 'from the future', '')`, SchemaVersion()+1); err != nil {
+		t.Fatal(err)
+	}
+	_, err := NewSQLiteStore(path)
+	if err == nil || !strings.Contains(err.Error(), "newer than supported") {
+		t.Fatalf("expected future schema to be refused, got %v", err)
+	}
+}
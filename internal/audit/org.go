@@ -0,0 +1,26 @@
+package audit
+
+import "context"
+
+type orgKey struct{}
+
+// WithOrg scopes ctx to organization org. Stores write entries under the org
+// of the context they are given and read back only that org's audits, so an
+// audit of another org is ErrNotFound rather than forbidden. The empty org is
+// the single organization of an unpartitioned deployment.
+func WithOrg(ctx context.Context, org string) context.Context {
+	return context.WithValue(ctx, orgKey{}, org)
+}
+
+// OrgFrom returns the org ctx is scoped to, "" when none.
+func OrgFrom(ctx context.Context) string {
+	org, _ := ctx.Value(orgKey{}).(string)
+	return org
+}
+
+// orgScoped is a memory store record tagged with the org it was written
+// under, for records that outlive the audit they refer to.
+type orgScoped[T any] struct {
+	org  string
+	item T
+}
@@ -0,0 +1,99 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOrgIsolation(t *testing.T) {
+	stores := map[string]interface {
+		Store
+		SummaryReader
+		DecisionStore
+		PatientHistory
+		SoftDeleter
+	}{
+		"memory":    NewMemoryStore(WithCapacity(0)),
+		"sqlite":    openStore(t, filepath.Join(t.TempDir(), "plain.db"), nil),
+		"encrypted": openStore(t, filepath.Join(t.TempDir(), "enc.db"), testKey(10)),
+	}
+	const perOrg = 20
+	orgs := []string{"clinic-a", "clinic-b"}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			at := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+			var wg sync.WaitGroup
+			errs := make(chan error, 2*perOrg*len(orgs))
+			for _, org := range orgs {
+				ctx := WithOrg(t.Context(), org)
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := range perOrg {
+						id := fmt.Sprintf("%s-%d", org, i)
+						if _, err := s.Insert(ctx, Entry{ID: id, PatientRef: "PT-1", RiskLevel: "LOW", At: at.Add(time.Duration(i) * time.Minute)}); err != nil {
+							errs <- err
+							continue
+						}
+						if _, err := s.InsertDecision(ctx, Decision{AuditID: id, Decision: "accepted"}, false); err != nil {
+							errs <- err
+						}
+						if _, err := s.Latest(ctx, maxLimit); err != nil {
+							errs <- err
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				t.Error(err)
+			}
+
+			for _, org := range orgs {
+				ctx := WithOrg(t.Context(), org)
+				other := orgs[0]
+				if org == other {
+					other = orgs[1]
+				}
+				latest, err := s.Latest(ctx, maxLimit)
+				if err != nil || len(latest) != perOrg {
+					t.Fatalf("%s latest: %d audits (err %v)", org, len(latest), err)
+				}
+				history, err := s.ListByPatientRef(ctx, "PT-1", maxLimit)
+				if err != nil || len(history) != perOrg {
+					t.Fatalf("%s patient history: %d audits (err %v)", org, len(history), err)
+				}
+				for _, sum := range append(latest, history...) {
+					if sum.AuditID[:len(org)] != org {
+						t.Errorf("%s read %s", org, sum.AuditID)
+					}
+				}
+				if stats, err := s.DecisionStats(ctx); err != nil || stats.ByRiskLevel["LOW"].Analyses != perOrg {
+					t.Fatalf("%s stats = %+v (err %v)", org, stats, err)
+				}
+
+				foreign := other + "-0"
+				if _, err := s.Summary(ctx, foreign); !errors.Is(err, ErrNotFound) {
+					t.Errorf("%s reading %s: %v", org, foreign, err)
+				}
+				if _, err := s.InsertDecision(ctx, Decision{AuditID: foreign, Decision: "rejected"}, true); !errors.Is(err, ErrNotFound) {
+					t.Errorf("%s deciding %s: %v", org, foreign, err)
+				}
+				if err := s.SoftDelete(ctx, foreign, "not ours", ""); !errors.Is(err, ErrNotFound) {
+					t.Errorf("%s deleting %s: %v", org, foreign, err)
+				}
+				if ds, err := s.Decisions(ctx, foreign); err != nil || len(ds) != 0 {
+					t.Errorf("%s decisions of %s = %+v (err %v)", org, foreign, ds, err)
+				}
+			}
+			if latest, err := s.Latest(t.Context(), maxLimit); err != nil || len(latest) != 0 {
+				t.Errorf("unscoped read saw %d org audits (err %v)", len(latest), err)
+			}
+		})
+	}
+}
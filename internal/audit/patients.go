@@ -0,0 +1,141 @@
+package audit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+)
+
+// PatientHistory is implemented by stores that can list one patient's audits.
+type PatientHistory interface {
+	// ListByPatientRef returns up to limit of the most recent audits for ref,
+	// oldest first.
+	ListByPatientRef(ctx context.Context, ref string, limit int) ([]Summary, error)
+}
+
+// Lookup key prefixes: a plaintext key is the reference itself, a hashed key
+// is the cipher's blind index of it.
+const (
+	plainKeyPrefix  = "p:"
+	hashedKeyPrefix = "h:"
+)
+
+// patientKey is the indexed lookup value for ref. With a cipher it is an HMAC
+// so the index does not reveal the encrypted reference.
+func patientKey(c *FieldCipher, ref string) string {
+	if c == nil {
+		return plainKeyPrefix + ref
+	}
+	mac := hmac.New(sha256.New, c.index)
+	mac.Write([]byte(ref))
+	return hashedKeyPrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+func patientKeyOrNull(c *FieldCipher, ref string) sql.NullString {
+	if ref == "" {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: patientKey(c, ref), Valid: true}
+}
+
+// ListByPatientRef returns ref's most recent audits, oldest first.
+func (s *SQLiteStore) ListByPatientRef(ctx context.Context, ref string, limit int) ([]Summary, error) {
+	if limit <= 0 || limit > maxLimit {
+		limit = 10
+	}
+	if ref == "" {
+		return []Summary{}, nil
+	}
+	out, err := s.querySummaries(ctx, `
+		SELECT `+summaryColumns+`
+		FROM audits
+		WHERE patient_key = ? AND deleted_at IS NULL AND org_id = ?
+		ORDER BY at_utc DESC, rowid DESC
+		LIMIT ?
+	`, patientKey(s.cipher, ref), OrgFrom(ctx), limit)
+	if err != nil {
+		return nil, err
+	}
+	reverse(out)
+	return out, nil
+}
+
+// reindexPatientKeys fills patient_key for rows written before the column
+// existed and, once a cipher is configured, replaces plaintext keys with the
+// blind index. Rows whose reference cannot be decrypted are left unindexed.
+func (s *SQLiteStore) reindexPatientKeys() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := `SELECT id, patient_ref FROM audits WHERE patient_key IS NULL AND COALESCE(patient_ref, '') != ''`
+	if s.cipher != nil {
+		query += ` OR patient_key LIKE '` + plainKeyPrefix + `%'`
+	}
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return fmt.Errorf("reindex patient keys: %w", err)
+	}
+	type row struct{ id, key string }
+	var pending []row
+	for rows.Next() {
+		var id string
+		var ref sql.NullString
+		if err := rows.Scan(&id, &ref); err != nil {
+			rows.Close()
+			return fmt.Errorf("reindex patient keys: %w", err)
+		}
+		plain, err := decryptColumn(s.cipher, "patient_ref", id, ref.String)
+		if err != nil || plain == "" {
+			continue
+		}
+		pending = append(pending, row{id, patientKey(s.cipher, plain)})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("reindex patient keys: %w", err)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("reindex patient keys: %w", err)
+	}
+	defer tx.Rollback()
+	for _, r := range pending {
+		if _, err := tx.Exec(`UPDATE audits SET patient_key = ? WHERE id = ?`, r.key, r.id); err != nil {
+			return fmt.Errorf("reindex audit %s: %w", r.id, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// ListByPatientRef returns ref's most recent retained audits, oldest first.
+func (m *MemoryStore) ListByPatientRef(ctx context.Context, ref string, limit int) ([]Summary, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > maxLimit {
+		limit = 10
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []Summary{}
+	for i := len(m.entries) - 1; i >= 0 && len(out) < limit; i-- {
+		if e := m.entries[i]; ref != "" && e.PatientRef == ref && e.DeletedAt == "" && m.retained(ctx, e.AuditID) {
+			out = append(out, e)
+		}
+	}
+	reverse(out)
+	return m.withDecisions(out), nil
+}
+
+func reverse(s []Summary) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
@@ -0,0 +1,85 @@
+package audit
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func insertVisits(t *testing.T, s Store, ref string, scores ...int) {
+	t.Helper()
+	base := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	for i, score := range scores {
+		id := fmt.Sprintf("%s-%d", ref, i)
+		if _, err := s.Insert(t.Context(), Entry{ID: id, PatientRef: ref, RiskScore: score, At: base.Add(time.Duration(i) * time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestListByPatientRef(t *testing.T) {
+	stores := map[string]interface {
+		Store
+		PatientHistory
+	}{
+		"memory":    NewMemoryStore(),
+		"sqlite":    openStore(t, filepath.Join(t.TempDir(), "plain.db"), nil),
+		"encrypted": openStore(t, filepath.Join(t.TempDir(), "enc.db"), testKey(4)),
+	}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			insertVisits(t, s, "p_a", 3, 5, 4, 7)
+			insertVisits(t, s, "p_b", 9)
+
+			got, err := s.ListByPatientRef(t.Context(), "p_a", 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var scores []int
+			for _, sum := range got {
+				if sum.PatientRef != "p_a" {
+					t.Fatalf("other patient's audit returned: %+v", sum)
+				}
+				scores = append(scores, sum.RiskScore)
+			}
+			if fmt.Sprint(scores) != "[5 4 7]" {
+				t.Fatalf("want the last three visits oldest first, got %v", scores)
+			}
+			if got, err := s.ListByPatientRef(t.Context(), "p_unknown", 10); err != nil || len(got) != 0 {
+				t.Fatalf("unknown patient: %+v (err %v)", got, err)
+			}
+		})
+	}
+}
+
+func TestSQLiteStore_PatientKeyIsBlindWithCipher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.db")
+	plain := openStore(t, path, nil)
+	insertVisits(t, plain, "p_secret", 2)
+	if got := rawColumn(t, plain, "p_secret-0", "patient_key"); got != plainKeyPrefix+"p_secret" {
+		t.Fatalf("plaintext store key %q", got)
+	}
+
+	// Opening with a key re-indexes rows written without one.
+	enc := openStore(t, path, testKey(5))
+	if got := rawColumn(t, enc, "p_secret-0", "patient_key"); strings.Contains(got, "p_secret") {
+		t.Fatalf("blind index leaks the reference: %q", got)
+	}
+	if _, err := enc.Insert(t.Context(), Entry{ID: "p_secret-later", PatientRef: "p_secret", RiskScore: 6, At: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := enc.ListByPatientRef(t.Context(), "p_secret", 10)
+	if err != nil || len(got) != 2 || got[0].RiskScore != 2 || got[1].RiskScore != 6 {
+		t.Fatalf("expected rows written before and after the key, got %+v (err %v)", got, err)
+	}
+}
+
+func TestMigrate_IndexesExistingPatients(t *testing.T) {
+	s := openStore(t, writeV1Database(t), nil)
+	got, err := s.ListByPatientRef(t.Context(), "J***", 10)
+	if err != nil || len(got) != 1 || got[0].AuditID != "audit-1700000000000000000" {
+		t.Fatalf("pre-migration row should be listed, got %+v (err %v)", got, err)
+	}
+}
@@ -0,0 +1,194 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Reanalysis records one replay of an audited intake. The audit it names is
+// left as it was written.
+type Reanalysis struct {
+	ID                   string    `json:"id"`
+	AuditID              string    `json:"auditId"`
+	RulesetVersionBefore string    `json:"rulesetVersionBefore,omitempty"`
+	RulesetVersionAfter  string    `json:"rulesetVersionAfter"`
+	RiskLevelBefore      string    `json:"riskLevelBefore"`
+	RiskLevelAfter       string    `json:"riskLevelAfter"`
+	RiskScoreBefore      int       `json:"riskScoreBefore"`
+	RiskScoreAfter       int       `json:"riskScoreAfter"`
+	Changed              bool      `json:"changed"`
+	At                   time.Time `json:"at"`
+}
+
+// ReanalysisStore is implemented by stores that can keep re-analysis records.
+type ReanalysisStore interface {
+	InsertReanalysis(ctx context.Context, r Reanalysis) error
+	// Reanalyses returns the records for auditID, oldest first.
+	Reanalyses(ctx context.Context, auditID string) ([]Reanalysis, error)
+}
+
+// Range selects audits written at or after From and before To; a zero bound
+// is open. RiskLevel, when set, must match exactly.
+type Range struct {
+	From, To  time.Time
+	RiskLevel string
+}
+
+// RangeLister is implemented by stores that can list audits by time.
+type RangeLister interface {
+	// AuditIDsInRange returns the IDs of up to limit audits in r, oldest
+	// first.
+	AuditIDsInRange(ctx context.Context, r Range, limit int) ([]string, error)
+}
+
+// maxRangeLimit bounds one range listing; it is larger than maxLimit since
+// only IDs are returned.
+const maxRangeLimit = 1000
+
+const maxMemoryReanalyses = 1000
+
+func (s *SQLiteStore) InsertReanalysis(ctx context.Context, r Reanalysis) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	at := r.At
+	if at.IsZero() {
+		at = time.Now().UTC()
+	}
+	err := retryBusy(ctx, func() error {
+		_, err := s.db.ExecContext(ctx, `
+			INSERT INTO reanalyses (id, audit_id, ruleset_version_before, ruleset_version_after,
+				risk_level_before, risk_level_after, risk_score_before, risk_score_after, changed, at_utc)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, r.ID, r.AuditID, r.RulesetVersionBefore, r.RulesetVersionAfter,
+			r.RiskLevelBefore, r.RiskLevelAfter, r.RiskScoreBefore, r.RiskScoreAfter, r.Changed, at.Format(time.RFC3339))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("insert reanalysis: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Reanalyses(ctx context.Context, auditID string) ([]Reanalysis, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, audit_id, ruleset_version_before, ruleset_version_after,
+			risk_level_before, risk_level_after, risk_score_before, risk_score_after, changed, at_utc
+		FROM reanalyses
+		WHERE audit_id = ? AND audit_id IN (SELECT id FROM audits WHERE org_id = ?)
+		ORDER BY at_utc, rowid
+	`, auditID, OrgFrom(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("query reanalyses: %w", err)
+	}
+	defer rows.Close()
+	out := []Reanalysis{}
+	for rows.Next() {
+		var r Reanalysis
+		var at string
+		if err := rows.Scan(&r.ID, &r.AuditID, &r.RulesetVersionBefore, &r.RulesetVersionAfter,
+			&r.RiskLevelBefore, &r.RiskLevelAfter, &r.RiskScoreBefore, &r.RiskScoreAfter, &r.Changed, &at); err != nil {
+			return nil, fmt.Errorf("scan reanalysis: %w", err)
+		}
+		r.At, _ = time.Parse(time.RFC3339, at)
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// AuditIDsInRange returns audit IDs in r, oldest first.
+func (s *SQLiteStore) AuditIDsInRange(ctx context.Context, r Range, limit int) ([]string, error) {
+	if limit <= 0 || limit > maxRangeLimit {
+		limit = maxRangeLimit
+	}
+	query := `SELECT id FROM audits WHERE deleted_at IS NULL AND org_id = ?`
+	args := []any{OrgFrom(ctx)}
+	if !r.From.IsZero() {
+		query += ` AND at_utc >= ?`
+		args = append(args, r.From.UTC().Format(time.RFC3339))
+	}
+	if !r.To.IsZero() {
+		query += ` AND at_utc < ?`
+		args = append(args, r.To.UTC().Format(time.RFC3339))
+	}
+	if r.RiskLevel != "" {
+		query += ` AND risk_level = ?`
+		args = append(args, r.RiskLevel)
+	}
+	query += ` ORDER BY at_utc, rowid LIMIT ?`
+	rows, err := s.db.QueryContext(ctx, query, append(args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("query audit range: %w", err)
+	}
+	defer rows.Close()
+	out := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan audit range: %w", err)
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
+
+func (m *MemoryStore) InsertReanalysis(ctx context.Context, r Reanalysis) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if r.At.IsZero() {
+		r.At = time.Now().UTC()
+	}
+	m.reanalyses = append(m.reanalyses, orgScoped[Reanalysis]{OrgFrom(ctx), r})
+	if len(m.reanalyses) > maxMemoryReanalyses {
+		m.reanalyses = m.reanalyses[len(m.reanalyses)-maxMemoryReanalyses:]
+	}
+	return nil
+}
+
+func (m *MemoryStore) Reanalyses(ctx context.Context, auditID string) ([]Reanalysis, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []Reanalysis{}
+	for _, r := range m.reanalyses {
+		if r.item.AuditID == auditID && r.org == OrgFrom(ctx) {
+			out = append(out, r.item)
+		}
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) AuditIDsInRange(ctx context.Context, r Range, limit int) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > maxRangeLimit {
+		limit = maxRangeLimit
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []string{}
+	for _, e := range m.entries {
+		if len(out) == limit {
+			break
+		}
+		at, err := time.Parse(time.RFC3339, e.At)
+		if err != nil || e.DeletedAt != "" || !m.retained(ctx, e.AuditID) {
+			continue
+		}
+		if (!r.From.IsZero() && at.Before(r.From)) || (!r.To.IsZero() && !at.Before(r.To)) {
+			continue
+		}
+		if r.RiskLevel != "" && e.RiskLevel != r.RiskLevel {
+			continue
+		}
+		out = append(out, e.AuditID)
+	}
+	return out, nil
+}
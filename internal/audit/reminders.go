@@ -0,0 +1,357 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Reminder statuses. A pending reminder becomes overdue when MarkOverdue
+// finds it past due, and completed when a clinician closes it.
+const (
+	ReminderPending   = "pending"
+	ReminderOverdue   = "overdue"
+	ReminderCompleted = "completed"
+)
+
+// ErrReminderCompleted is returned when completing a reminder twice.
+var ErrReminderCompleted = errors.New("audit: reminder already completed")
+
+// maxReminders bounds one Reminders listing.
+const maxReminders = 200
+
+// Reminder is a follow-up visit due after an audited analysis. It stores no
+// patient data of its own: PatientRef and Complaint are read from its
+// audit, so soft-deleting or evicting the audit takes the reminder with it.
+type Reminder struct {
+	ID           string
+	AuditID      string
+	PatientRef   string
+	Complaint    string
+	Instructions string
+	DueAt        time.Time
+	Status       string
+	CreatedAt    time.Time
+	// OverdueAt is when MarkOverdue found the reminder past due; zero until
+	// then.
+	OverdueAt   time.Time
+	CompletedAt time.Time
+	CompletedBy string
+	// Org is the org of the reminder's audit.
+	Org string
+}
+
+// ReminderStore is implemented by stores that keep follow-up reminders.
+// Reminders of soft-deleted audits are left out of every method.
+type ReminderStore interface {
+	// InsertReminder records r against an existing audit of the ctx org and
+	// returns it with its ID, Status, and CreatedAt set, or ErrNotFound.
+	InsertReminder(ctx context.Context, r Reminder) (Reminder, error)
+	// Reminders returns up to limit open reminders of the ctx org due by
+	// dueBy, or all open ones when dueBy is zero, soonest first.
+	Reminders(ctx context.Context, dueBy time.Time, limit int) ([]Reminder, error)
+	// CompleteReminder closes reminder id, failing with ErrNotFound or
+	// ErrReminderCompleted.
+	CompleteReminder(ctx context.Context, id, userID string, at time.Time) (Reminder, error)
+	// MarkOverdue marks the pending reminders of every org due by now as
+	// overdue and returns them.
+	MarkOverdue(ctx context.Context, now time.Time) ([]Reminder, error)
+}
+
+func reminderLimit(limit int) int {
+	if limit <= 0 || limit > maxReminders {
+		return maxReminders
+	}
+	return limit
+}
+
+func (s *SQLiteStore) InsertReminder(ctx context.Context, r Reminder) (Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.ID == "" {
+		r.ID = UUIDGenerator{}.NewID()
+	}
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = time.Now().UTC()
+	}
+	r.Status = ReminderPending
+	err := retryBusy(ctx, func() error {
+		res, err := s.db.ExecContext(ctx, `
+			INSERT INTO reminders (id, audit_id, instructions, due_at, status, created_at)
+			SELECT ?, id, ?, ?, ?, ? FROM audits WHERE id = ? AND org_id = ? AND deleted_at IS NULL
+		`, r.ID, r.Instructions, r.DueAt.UTC().Format(time.RFC3339), r.Status, r.CreatedAt.UTC().Format(time.RFC3339), r.AuditID, OrgFrom(ctx))
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return Reminder{}, fmt.Errorf("insert reminder: %w", err)
+	}
+	return r, nil
+}
+
+// reminderQuery selects reminders with their audit's reference, complaint,
+// and org; callers append the WHERE clause.
+const reminderQuery = `
+	SELECT r.id, r.audit_id, a.patient_ref, a.complaint, r.instructions, r.due_at, r.status,
+		r.created_at, r.overdue_at, r.completed_at, r.completed_by, a.org_id
+	FROM reminders r
+	JOIN audits a ON a.id = r.audit_id
+	WHERE a.deleted_at IS NULL AND `
+
+func (s *SQLiteStore) Reminders(ctx context.Context, dueBy time.Time, limit int) ([]Reminder, error) {
+	where := `a.org_id = ? AND r.status != ?`
+	args := []any{OrgFrom(ctx), ReminderCompleted}
+	if !dueBy.IsZero() {
+		where += ` AND r.due_at <= ?`
+		args = append(args, dueBy.UTC().Format(time.RFC3339))
+	}
+	return s.queryReminders(ctx, s.db, reminderQuery+where+` ORDER BY r.due_at, r.id LIMIT ?`, append(args, reminderLimit(limit))...)
+}
+
+func (s *SQLiteStore) CompleteReminder(ctx context.Context, id, userID string, at time.Time) (Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out Reminder
+	err := retryBusy(ctx, func() error {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		found, err := s.queryReminders(ctx, tx, reminderQuery+`r.id = ? AND a.org_id = ?`, id, OrgFrom(ctx))
+		if err != nil {
+			return err
+		}
+		if len(found) == 0 {
+			return ErrNotFound
+		}
+		out = found[0]
+		if out.Status == ReminderCompleted {
+			return ErrReminderCompleted
+		}
+		out.Status, out.CompletedAt, out.CompletedBy = ReminderCompleted, at.UTC(), userID
+		if _, err := tx.ExecContext(ctx, `UPDATE reminders SET status = ?, completed_at = ?, completed_by = ? WHERE id = ?`,
+			out.Status, out.CompletedAt.Format(time.RFC3339), userID, id); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		return Reminder{}, fmt.Errorf("complete reminder: %w", err)
+	}
+	return out, nil
+}
+
+func (s *SQLiteStore) MarkOverdue(ctx context.Context, now time.Time) ([]Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []Reminder
+	err := retryBusy(ctx, func() error {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		due, err := s.queryReminders(ctx, tx, reminderQuery+`r.status = ? AND r.due_at <= ? ORDER BY r.due_at, r.id`,
+			ReminderPending, now.UTC().Format(time.RFC3339))
+		if err != nil {
+			return err
+		}
+		for i := range due {
+			due[i].Status, due[i].OverdueAt = ReminderOverdue, now.UTC()
+			if _, err := tx.ExecContext(ctx, `UPDATE reminders SET status = ?, overdue_at = ? WHERE id = ?`,
+				ReminderOverdue, due[i].OverdueAt.Format(time.RFC3339), due[i].ID); err != nil {
+				return err
+			}
+		}
+		out = due
+		return tx.Commit()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("mark reminders overdue: %w", err)
+	}
+	return out, nil
+}
+
+// querier is the part of *sql.DB and *sql.Tx queryReminders needs.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func (s *SQLiteStore) queryReminders(ctx context.Context, q querier, query string, args ...any) ([]Reminder, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query reminders: %w", err)
+	}
+	defer rows.Close()
+
+	out := []Reminder{}
+	for rows.Next() {
+		var r Reminder
+		var ref, complaint, instructions, due, created, overdue, completed, completedBy sql.NullString
+		if err := rows.Scan(&r.ID, &r.AuditID, &ref, &complaint, &instructions, &due, &r.Status,
+			&created, &overdue, &completed, &completedBy, &r.Org); err != nil {
+			return nil, fmt.Errorf("scan reminder: %w", err)
+		}
+		if r.PatientRef, err = decryptColumn(s.cipher, "patient_ref", r.AuditID, ref.String); err != nil {
+			return nil, fmt.Errorf("reminder %s patient_ref: %w", r.ID, err)
+		}
+		if r.Complaint, err = decryptColumn(s.cipher, "complaint", r.AuditID, complaint.String); err != nil {
+			return nil, fmt.Errorf("reminder %s complaint: %w", r.ID, err)
+		}
+		r.Instructions, r.CompletedBy = instructions.String, completedBy.String
+		r.DueAt, _ = time.Parse(time.RFC3339, due.String)
+		r.CreatedAt, _ = time.Parse(time.RFC3339, created.String)
+		r.OverdueAt, _ = time.Parse(time.RFC3339, overdue.String)
+		r.CompletedAt, _ = time.Parse(time.RFC3339, completed.String)
+		out = append(out, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read reminders: %w", err)
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) InsertReminder(ctx context.Context, r Reminder) (Reminder, error) {
+	if err := ctx.Err(); err != nil {
+		return Reminder{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.live(ctx, r.AuditID); !ok {
+		return Reminder{}, ErrNotFound
+	}
+	if r.ID == "" {
+		r.ID = UUIDGenerator{}.NewID()
+	}
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = time.Now().UTC()
+	}
+	r.Status = ReminderPending
+	// The audit supplies these on every read.
+	r.PatientRef, r.Complaint, r.Org = "", "", ""
+	m.reminders = append(m.reminders, r)
+	return m.joined(r), nil
+}
+
+func (m *MemoryStore) Reminders(ctx context.Context, dueBy time.Time, limit int) ([]Reminder, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []Reminder{}
+	for _, r := range m.reminders {
+		if _, ok := m.live(ctx, r.AuditID); !ok || r.Status == ReminderCompleted || (!dueBy.IsZero() && r.DueAt.After(dueBy)) {
+			continue
+		}
+		out = append(out, m.joined(r))
+	}
+	sortReminders(out)
+	return out[:min(len(out), reminderLimit(limit))], nil
+}
+
+func (m *MemoryStore) CompleteReminder(ctx context.Context, id, userID string, at time.Time) (Reminder, error) {
+	if err := ctx.Err(); err != nil {
+		return Reminder{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, r := range m.reminders {
+		if r.ID != id {
+			continue
+		}
+		if _, ok := m.live(ctx, r.AuditID); !ok {
+			break
+		}
+		if r.Status == ReminderCompleted {
+			return Reminder{}, ErrReminderCompleted
+		}
+		r.Status, r.CompletedAt, r.CompletedBy = ReminderCompleted, at.UTC(), userID
+		m.reminders[i] = r
+		return m.joined(r), nil
+	}
+	return Reminder{}, ErrNotFound
+}
+
+func (m *MemoryStore) MarkOverdue(ctx context.Context, now time.Time) ([]Reminder, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []Reminder
+	for i, r := range m.reminders {
+		if r.Status != ReminderPending || r.DueAt.After(now) || m.deleted(r.AuditID) {
+			continue
+		}
+		r.Status, r.OverdueAt = ReminderOverdue, now.UTC()
+		m.reminders[i] = r
+		out = append(out, m.joined(r))
+	}
+	sortReminders(out)
+	return out, nil
+}
+
+// live returns audit id when the ctx org holds it and it is not deleted.
+func (m *MemoryStore) live(ctx context.Context, id string) (Summary, bool) {
+	if !m.retained(ctx, id) {
+		return Summary{}, false
+	}
+	for _, e := range m.entries {
+		if e.AuditID == id {
+			return e, e.DeletedAt == ""
+		}
+	}
+	return Summary{}, false
+}
+
+// deleted reports whether audit id is soft-deleted or no longer held.
+func (m *MemoryStore) deleted(id string) bool {
+	for _, e := range m.entries {
+		if e.AuditID == id {
+			return e.DeletedAt != ""
+		}
+	}
+	return true
+}
+
+// joined fills r in from its audit, as the SQLite join does.
+func (m *MemoryStore) joined(r Reminder) Reminder {
+	for _, e := range m.entries {
+		if e.AuditID == r.AuditID {
+			r.PatientRef, r.Complaint = e.PatientRef, e.Complaint
+			break
+		}
+	}
+	r.Org = m.orgs[r.AuditID]
+	return r
+}
+
+// dropReminders removes the reminders of evicted audits.
+func (m *MemoryStore) dropReminders(evicted []Summary) {
+	m.reminders = slices.DeleteFunc(m.reminders, func(r Reminder) bool {
+		return slices.ContainsFunc(evicted, func(e Summary) bool { return e.AuditID == r.AuditID })
+	})
+}
+
+func sortReminders(rs []Reminder) {
+	slices.SortStableFunc(rs, func(x, y Reminder) int {
+		if c := x.DueAt.Compare(y.DueAt); c != 0 {
+			return c
+		}
+		return strings.Compare(x.ID, y.ID)
+	})
+}
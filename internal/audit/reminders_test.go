@@ -0,0 +1,140 @@
+package audit
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReminders(t *testing.T) {
+	stores := map[string]interface {
+		Store
+		SoftDeleter
+		ReminderStore
+	}{
+		"memory":    NewMemoryStore(),
+		"sqlite":    openStore(t, filepath.Join(t.TempDir(), "plain.db"), nil),
+		"encrypted": openStore(t, filepath.Join(t.TempDir(), "enc.db"), testKey(8)),
+	}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			at := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+			clinic := WithOrg(t.Context(), "clinic-b")
+			for _, e := range []Entry{
+				{ID: "a1", PatientRef: "PT-1", Complaint: "cough"},
+				{ID: "a2", PatientRef: "PT-2", Complaint: "headache"},
+			} {
+				if _, err := s.Insert(t.Context(), e); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := s.Insert(clinic, Entry{ID: "b1", PatientRef: "PT-9"}); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := s.InsertReminder(t.Context(), Reminder{AuditID: "missing", DueAt: at}); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("unknown audit: %v", err)
+			}
+			if _, err := s.InsertReminder(t.Context(), Reminder{AuditID: "b1", DueAt: at}); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("other org's audit: %v", err)
+			}
+			r1, err := s.InsertReminder(t.Context(), Reminder{ID: "r1", AuditID: "a1", Instructions: "recheck chest", DueAt: at.AddDate(0, 0, 3), CreatedAt: at})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r1.Status != ReminderPending || r1.ID != "r1" {
+				t.Fatalf("inserted = %+v", r1)
+			}
+			for _, r := range []Reminder{{ID: "r2", AuditID: "a2", DueAt: at.AddDate(0, 0, 10)}} {
+				if _, err := s.InsertReminder(t.Context(), r); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := s.InsertReminder(clinic, Reminder{ID: "rb", AuditID: "b1", DueAt: at}); err != nil {
+				t.Fatal(err)
+			}
+
+			ids := func(rs []Reminder) string {
+				var out []string
+				for _, r := range rs {
+					out = append(out, r.ID)
+				}
+				return strings.Join(out, ",")
+			}
+			all, err := s.Reminders(t.Context(), time.Time{}, 0)
+			if err != nil || ids(all) != "r1,r2" {
+				t.Fatalf("all = %s (err %v)", ids(all), err)
+			}
+			if got := all[0]; got.PatientRef != "PT-1" || got.Complaint != "cough" || got.Instructions != "recheck chest" || !got.DueAt.Equal(at.AddDate(0, 0, 3)) {
+				t.Fatalf("reminder = %+v", got)
+			}
+			if due, err := s.Reminders(t.Context(), at.AddDate(0, 0, 7), 0); err != nil || ids(due) != "r1" {
+				t.Fatalf("due in 7 days = %s (err %v)", ids(due), err)
+			}
+
+			// MarkOverdue spans orgs and marks each reminder once.
+			overdue, err := s.MarkOverdue(t.Context(), at.AddDate(0, 0, 5))
+			if err != nil || ids(overdue) != "rb,r1" {
+				t.Fatalf("overdue = %s (err %v)", ids(overdue), err)
+			}
+			if overdue[0].Org != "clinic-b" || overdue[1].Status != ReminderOverdue || overdue[1].OverdueAt.IsZero() {
+				t.Fatalf("overdue = %+v", overdue)
+			}
+			if again, err := s.MarkOverdue(t.Context(), at.AddDate(0, 0, 5)); err != nil || len(again) != 0 {
+				t.Fatalf("second pass = %s (err %v)", ids(again), err)
+			}
+
+			if _, err := s.CompleteReminder(clinic, "r1", "dr-a", at); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("complete from other org: %v", err)
+			}
+			done, err := s.CompleteReminder(t.Context(), "r1", "dr-a", at.AddDate(0, 0, 6))
+			if err != nil || done.Status != ReminderCompleted || done.CompletedBy != "dr-a" {
+				t.Fatalf("completed = %+v (err %v)", done, err)
+			}
+			if _, err := s.CompleteReminder(t.Context(), "r1", "dr-a", at); !errors.Is(err, ErrReminderCompleted) {
+				t.Fatalf("second complete: %v", err)
+			}
+			if open, err := s.Reminders(t.Context(), time.Time{}, 0); err != nil || ids(open) != "r2" {
+				t.Fatalf("open = %s (err %v)", ids(open), err)
+			}
+
+			// Deleting the audit hides its reminders; restoring brings them back.
+			if err := s.SoftDelete(t.Context(), "a2", "wrong patient", "dr-a"); err != nil {
+				t.Fatal(err)
+			}
+			if open, err := s.Reminders(t.Context(), time.Time{}, 0); err != nil || len(open) != 0 {
+				t.Fatalf("open after delete = %s (err %v)", ids(open), err)
+			}
+			if overdue, err := s.MarkOverdue(t.Context(), at.AddDate(0, 1, 0)); err != nil || len(overdue) != 0 {
+				t.Fatalf("overdue after delete = %s (err %v)", ids(overdue), err)
+			}
+			if _, err := s.CompleteReminder(t.Context(), "r2", "dr-a", at); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("complete after delete: %v", err)
+			}
+			if err := s.Restore(t.Context(), "a2"); err != nil {
+				t.Fatal(err)
+			}
+			if open, err := s.Reminders(t.Context(), time.Time{}, 0); err != nil || ids(open) != "r2" {
+				t.Fatalf("open after restore = %s (err %v)", ids(open), err)
+			}
+		})
+	}
+}
+
+func TestMemoryStore_EvictionDropsReminders(t *testing.T) {
+	m := NewMemoryStore(WithCapacity(1))
+	if _, err := m.Insert(t.Context(), Entry{ID: "old"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.InsertReminder(t.Context(), Reminder{AuditID: "old", DueAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Insert(t.Context(), Entry{ID: "new"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.reminders) != 0 {
+		t.Fatalf("evicted audit left reminders %+v", m.reminders)
+	}
+}
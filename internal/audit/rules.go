@@ -0,0 +1,139 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RulesChange records one replacement of the clinical ruleset. Versions are
+// the ruleset hashes before and after the swap.
+type RulesChange struct {
+	OldVersion string    `json:"oldVersion"`
+	NewVersion string    `json:"newVersion"`
+	Source     string    `json:"source"`
+	Actor      string    `json:"actor,omitempty"`
+	At         time.Time `json:"at"`
+}
+
+// RulesChangeStore is implemented by stores that can audit ruleset changes.
+type RulesChangeStore interface {
+	InsertRulesChange(ctx context.Context, c RulesChange) error
+	// RulesChanges returns recorded changes, oldest first.
+	RulesChanges(ctx context.Context) ([]RulesChange, error)
+}
+
+// RulesetHistory is implemented by stores that can list the audits produced
+// under one ruleset version.
+type RulesetHistory interface {
+	// ListByRulesetVersion returns up to limit of the most recent audits
+	// stamped with version, oldest first.
+	ListByRulesetVersion(ctx context.Context, version string, limit int) ([]Summary, error)
+}
+
+func (s *SQLiteStore) InsertRulesChange(ctx context.Context, c RulesChange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	at := c.At
+	if at.IsZero() {
+		at = time.Now().UTC()
+	}
+	err := retryBusy(ctx, func() error {
+		_, err := s.db.ExecContext(ctx, `
+			INSERT INTO rules_changes (old_version, new_version, source, actor, at_utc)
+			VALUES (?, ?, ?, ?, ?)
+		`, c.OldVersion, c.NewVersion, c.Source, c.Actor, at.Format(time.RFC3339Nano))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("insert rules change: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) RulesChanges(ctx context.Context) ([]RulesChange, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT old_version, new_version, source, actor, at_utc
+		FROM rules_changes
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query rules changes: %w", err)
+	}
+	defer rows.Close()
+	out := []RulesChange{}
+	for rows.Next() {
+		var c RulesChange
+		var at string
+		if err := rows.Scan(&c.OldVersion, &c.NewVersion, &c.Source, &c.Actor, &at); err != nil {
+			return nil, fmt.Errorf("scan rules change: %w", err)
+		}
+		c.At, _ = time.Parse(time.RFC3339Nano, at)
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+func (m *MemoryStore) InsertRulesChange(ctx context.Context, c RulesChange) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if c.At.IsZero() {
+		c.At = time.Now().UTC()
+	}
+	m.rulesChanges = append(m.rulesChanges, c)
+	return nil
+}
+
+func (m *MemoryStore) RulesChanges(ctx context.Context) ([]RulesChange, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]RulesChange{}, m.rulesChanges...), nil
+}
+
+// ListByRulesetVersion returns the most recent audits for version, oldest first.
+func (s *SQLiteStore) ListByRulesetVersion(ctx context.Context, version string, limit int) ([]Summary, error) {
+	if limit <= 0 || limit > maxLimit {
+		limit = 10
+	}
+	if version == "" {
+		return []Summary{}, nil
+	}
+	out, err := s.querySummaries(ctx, `
+		SELECT `+summaryColumns+`
+		FROM audits
+		WHERE ruleset_version = ? AND deleted_at IS NULL AND org_id = ?
+		ORDER BY at_utc DESC, rowid DESC
+		LIMIT ?
+	`, version, OrgFrom(ctx), limit)
+	if err != nil {
+		return nil, err
+	}
+	reverse(out)
+	return out, nil
+}
+
+func (m *MemoryStore) ListByRulesetVersion(ctx context.Context, version string, limit int) ([]Summary, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > maxLimit {
+		limit = 10
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []Summary{}
+	for i := len(m.entries) - 1; i >= 0 && len(out) < limit; i-- {
+		if e := m.entries[i]; version != "" && e.RulesetVersion == version && e.DeletedAt == "" && m.retained(ctx, e.AuditID) {
+			out = append(out, e)
+		}
+	}
+	reverse(out)
+	return m.withDecisions(out), nil
+}
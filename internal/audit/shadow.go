@@ -0,0 +1,126 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ShadowEntry compares the deterministic stub with an LLM scored in shadow mode
+// for the same analysis. Err is set when the shadow call failed.
+type ShadowEntry struct {
+	AuditID        string    `json:"auditId"`
+	StubConfidence float64   `json:"stubConfidence"`
+	LLMConfidence  float64   `json:"llmConfidence"`
+	Delta          float64   `json:"delta"`
+	Err            string    `json:"error,omitempty"`
+	Model          string    `json:"model,omitempty"`
+	LatencyMs      int64     `json:"latencyMs"`
+	At             time.Time `json:"at"`
+}
+
+// DivergenceStats aggregates shadow comparisons. Samples counts successful
+// comparisons only; deltas are LLM minus stub.
+type DivergenceStats struct {
+	Samples      int     `json:"samples"`
+	Failures     int     `json:"failures"`
+	MeanDelta    float64 `json:"meanDelta"`
+	MeanAbsDelta float64 `json:"meanAbsDelta"`
+	MaxAbsDelta  float64 `json:"maxAbsDelta"`
+}
+
+// ShadowStore is implemented by stores that can persist shadow-mode comparisons.
+type ShadowStore interface {
+	InsertShadow(ctx context.Context, entry ShadowEntry) error
+	ShadowDivergence(ctx context.Context) (DivergenceStats, error)
+}
+
+const maxMemoryShadows = 1000
+
+func (s *SQLiteStore) InsertShadow(ctx context.Context, entry ShadowEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	at := entry.At
+	if at.IsZero() {
+		at = time.Now().UTC()
+	}
+	err := retryBusy(ctx, func() error {
+		_, err := s.db.ExecContext(ctx, `
+			INSERT OR REPLACE INTO llm_shadow (audit_id, stub_confidence, llm_confidence, delta, error, model, latency_ms, at_utc)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, entry.AuditID, entry.StubConfidence, entry.LLMConfidence, entry.Delta, entry.Err, entry.Model, entry.LatencyMs, at.Format(time.RFC3339))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("insert shadow: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) ShadowDivergence(ctx context.Context) (DivergenceStats, error) {
+	var out DivergenceStats
+	var mean, meanAbs, maxAbs sql.NullFloat64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT
+			COUNT(*) FILTER (WHERE error = ''),
+			COUNT(*) FILTER (WHERE error <> ''),
+			AVG(delta) FILTER (WHERE error = ''),
+			AVG(ABS(delta)) FILTER (WHERE error = ''),
+			MAX(ABS(delta)) FILTER (WHERE error = '')
+		FROM llm_shadow
+		WHERE audit_id IN (SELECT id FROM audits WHERE org_id = ?)
+	`, OrgFrom(ctx)).Scan(&out.Samples, &out.Failures, &mean, &meanAbs, &maxAbs)
+	if err != nil {
+		return DivergenceStats{}, fmt.Errorf("query shadow divergence: %w", err)
+	}
+	out.MeanDelta, out.MeanAbsDelta, out.MaxAbsDelta = mean.Float64, meanAbs.Float64, maxAbs.Float64
+	return out, nil
+}
+
+func (m *MemoryStore) InsertShadow(ctx context.Context, entry ShadowEntry) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if entry.At.IsZero() {
+		entry.At = time.Now().UTC()
+	}
+	m.shadows = append(m.shadows, orgScoped[ShadowEntry]{OrgFrom(ctx), entry})
+	if len(m.shadows) > maxMemoryShadows {
+		m.shadows = m.shadows[len(m.shadows)-maxMemoryShadows:]
+	}
+	return nil
+}
+
+func (m *MemoryStore) ShadowDivergence(ctx context.Context) (DivergenceStats, error) {
+	if err := ctx.Err(); err != nil {
+		return DivergenceStats{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out DivergenceStats
+	var sum, sumAbs float64
+	for _, s := range m.shadows {
+		if s.org != OrgFrom(ctx) {
+			continue
+		}
+		e := s.item
+		if e.Err != "" {
+			out.Failures++
+			continue
+		}
+		out.Samples++
+		sum += e.Delta
+		sumAbs += math.Abs(e.Delta)
+		out.MaxAbsDelta = math.Max(out.MaxAbsDelta, math.Abs(e.Delta))
+	}
+	if out.Samples > 0 {
+		out.MeanDelta = sum / float64(out.Samples)
+		out.MeanAbsDelta = sumAbs / float64(out.Samples)
+	}
+	return out, nil
+}
@@ -0,0 +1,15 @@
+//go:build !js
+
+package audit
+
+import (
+	"database/sql"
+
+	_ "modernc.org/sqlite"
+)
+
+// openSQLite opens dsn with the pure-Go SQLite driver, which does not build
+// for js/wasm.
+func openSQLite(dsn string) (*sql.DB, error) {
+	return sql.Open("sqlite", dsn)
+}
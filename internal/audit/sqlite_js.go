@@ -0,0 +1,14 @@
+//go:build js
+
+package audit
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// openSQLite fails in the browser, where there is no SQLite driver; the wasm
+// build audits to a MemoryStore, if at all.
+func openSQLite(string) (*sql.DB, error) {
+	return nil, errors.New("sqlite is not available in js/wasm builds")
+}
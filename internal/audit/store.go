@@ -1,12 +1,15 @@
 package audit
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"slices"
 	"sync"
 	"time"
-
-	_ "modernc.org/sqlite"
 )
 
 // Entry captures an audit event for a clinical analysis or approval.
@@ -18,93 +21,289 @@ type Entry struct {
 	RiskScore  int
 	UserID     string
 	At         time.Time
+	LLM        LLMUsage
+	// PromptVersion identifies the system prompt active for this analysis.
+	PromptVersion string
+	// RulesetVersion identifies the rules, prompt, and build that produced
+	// the analysis.
+	RulesetVersion string
+	// Response is the analysis response as returned to the caller.
+	Response json.RawMessage
+	// Intake is the analyzed intake, stored so the analysis can be re-run.
+	Intake json.RawMessage
+	// Consent is the patient consent the intake carried; nil when none did.
+	Consent *Consent
+	// Duration is how long the analysis took before its audit write, and
+	// LLMDuration the part spent scoring; both are zero when not timed.
+	Duration    time.Duration
+	LLMDuration time.Duration
+	// Signature signs Response; nil when response signing is off.
+	Signature *Signature
+}
+
+// Consent is the consent metadata recorded with an audit.
+type Consent struct {
+	Given     bool   `json:"given"`
+	Timestamp string `json:"timestamp,omitempty"`
+	Method    string `json:"method,omitempty"`
+}
+
+// Signature is the detached signature recorded with a response.
+type Signature struct {
+	KeyID     string `json:"keyId"`
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"value"`
+}
+
+// LLMUsage records the cost of an LLM scoring call; zero when the stub was used.
+type LLMUsage struct {
+	Model            string `json:"model,omitempty"`
+	PromptTokens     int    `json:"promptTokens,omitempty"`
+	CompletionTokens int    `json:"completionTokens,omitempty"`
+	LatencyMs        int64  `json:"latencyMs,omitempty"`
 }
 
 // Summary is a read-friendly view of an audit record.
 type Summary struct {
-	AuditID    string `json:"auditId"`
-	PatientRef string `json:"patientRef"`
-	Complaint  string `json:"complaint"`
-	RiskLevel  string `json:"riskLevel"`
-	RiskScore  int    `json:"riskScore"`
-	UserID     string `json:"userId,omitempty"`
-	At         string `json:"at"`
+	AuditID       string    `json:"auditId"`
+	PatientRef    string    `json:"patientRef"`
+	Complaint     string    `json:"complaint"`
+	RiskLevel     string    `json:"riskLevel"`
+	RiskScore     int       `json:"riskScore"`
+	UserID        string    `json:"userId,omitempty"`
+	At            string    `json:"at"`
+	LLM           *LLMUsage `json:"llm,omitempty"`
+	PromptVersion string    `json:"promptVersion,omitempty"`
+	// RulesetVersion is empty for audits written before it was recorded.
+	RulesetVersion string   `json:"rulesetVersion,omitempty"`
+	Consent        *Consent `json:"consent,omitempty"`
+	// Decision is the current clinician decision, if any.
+	Decision *Decision `json:"decision,omitempty"`
+	// DurationMs and LLMDurationMs are Entry.Duration and Entry.LLMDuration
+	// in milliseconds; absent for audits that were not timed.
+	DurationMs    float64 `json:"durationMs,omitempty"`
+	LLMDurationMs float64 `json:"llmDurationMs,omitempty"`
+	// DeletedAt is when the audit was soft-deleted; empty unless it is.
+	DeletedAt string `json:"deletedAt,omitempty"`
+	// Signature is Entry.Signature; nil for unsigned audits.
+	Signature *Signature `json:"signature,omitempty"`
 }
 
+// Store persists audit entries. Methods honor ctx cancellation so a stalled
+// disk cannot hang a request; Close releases the underlying resources.
 type Store interface {
+	Insert(ctx context.Context, entry Entry) (Summary, error)
+	Latest(ctx context.Context, limit int) ([]Summary, error)
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// LegacyStore is the Store interface before context support.
+//
+// Deprecated: implement Store and wrap older implementations with AdaptLegacy.
+// LegacyStore will be removed in the next release.
+type LegacyStore interface {
 	Insert(entry Entry) (Summary, error)
 	Latest(limit int) ([]Summary, error)
 }
 
+// AdaptLegacy wraps a LegacyStore as a Store. The context is checked before each
+// call but cannot interrupt one in progress; Ping always succeeds and Close
+// closes the wrapped store only if it implements io.Closer.
+//
+// Deprecated: migrate the implementation to Store.
+func AdaptLegacy(s LegacyStore) Store {
+	return legacyStore{s}
+}
+
+type legacyStore struct {
+	LegacyStore
+}
+
+func (l legacyStore) Insert(ctx context.Context, entry Entry) (Summary, error) {
+	if err := ctx.Err(); err != nil {
+		return Summary{}, err
+	}
+	return l.LegacyStore.Insert(entry)
+}
+
+func (l legacyStore) Latest(ctx context.Context, limit int) ([]Summary, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return l.LegacyStore.Latest(limit)
+}
+
+func (l legacyStore) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+func (l legacyStore) Close() error {
+	if c, ok := l.LegacyStore.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// ErrNotFound is returned when an audit ID does not exist.
+var ErrNotFound = errors.New("audit: entry not found")
+
 const maxLimit = 50
 
-// SQLiteStore is a simple SQLite-backed store; safe for concurrent use.
+// SQLiteStore is a simple SQLite-backed store; safe for concurrent use. It runs
+// in WAL mode so reads proceed without the write lock.
 type SQLiteStore struct {
 	db *sql.DB
-	mu sync.Mutex
+	// insert is insertAuditSQL, prepared when the store is opened.
+	insert *sql.Stmt
+	// mu serializes writers only.
+	mu     sync.Mutex
+	cipher *FieldCipher
 }
 
-func NewSQLiteStore(path string) (*SQLiteStore, error) {
-	db, err := sql.Open("sqlite", path)
+// SQLiteOption configures a SQLiteStore.
+type SQLiteOption func(*SQLiteStore)
+
+// WithCipher encrypts patient_ref, complaint, response_json, intake_json,
+// decision reasons and plans, and deletion reasons at rest. Without it those
+// columns are written as plaintext.
+func WithCipher(c *FieldCipher) SQLiteOption {
+	return func(s *SQLiteStore) {
+		s.cipher = c
+	}
+}
+
+func NewSQLiteStore(path string, opts ...SQLiteOption) (*SQLiteStore, error) {
+	db, err := openSQLite(sqliteDSN(path))
 	if err != nil {
 		return nil, fmt.Errorf("open sqlite: %w", err)
 	}
-	if _, err := db.Exec(`
-		CREATE TABLE IF NOT EXISTS audits (
-			id TEXT PRIMARY KEY,
-			patient_ref TEXT,
-			complaint TEXT,
-			risk_level TEXT,
-			risk_score INTEGER,
-			user_id TEXT,
-			at_utc TEXT
-		);
-	`); err != nil {
-		return nil, fmt.Errorf("create table: %w", err)
+	db.SetMaxOpenConns(maxOpenConns)
+	db.SetMaxIdleConns(maxOpenConns)
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
 	}
-	return &SQLiteStore{db: db}, nil
+	insert, err := db.Prepare(insertAuditSQL)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("prepare audit insert: %w", err)
+	}
+	s := &SQLiteStore{db: db, insert: insert}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if err := s.reindexPatientKeys(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
 }
 
-func (s *SQLiteStore) Insert(entry Entry) (Summary, error) {
+// insertAuditSQL is prepared once per store as SQLiteStore.insert.
+const insertAuditSQL = `
+	INSERT INTO audits (id, patient_ref, complaint, risk_level, risk_score, user_id, at_utc,
+		llm_model, llm_prompt_tokens, llm_completion_tokens, llm_latency_ms, prompt_version, response_json, patient_key, intake_json,
+		consent_given, consent_at, consent_method, ruleset_version, duration_ms, llm_duration_ms, org_id,
+		signature_key_id, signature_alg, signature)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+func (s *SQLiteStore) Insert(ctx context.Context, entry Entry) (Summary, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	row, err := s.auditRow(entry, OrgFrom(ctx))
+	if err != nil {
+		return Summary{}, err
+	}
+	err = retryBusy(ctx, func() error {
+		_, err := s.insert.ExecContext(ctx, row.args...)
+		return err
+	})
+	if err != nil {
+		return Summary{}, fmt.Errorf("insert audit: %w", err)
+	}
+	return row.summary, nil
+}
+
+// auditRow is an entry ready for insertAuditSQL, with the summary it is
+// reported as once written.
+type auditRow struct {
+	summary Summary
+	args    []any
+}
+
+// auditRow assigns entry its ID and time where unset and encrypts its
+// sensitive columns; the row is written under org.
+func (s *SQLiteStore) auditRow(entry Entry, org string) (auditRow, error) {
 	now := entry.At
 	if now.IsZero() {
 		now = time.Now().UTC()
 	}
 	id := entry.ID
 	if id == "" {
-		id = fmt.Sprintf("audit-%d", time.Now().UnixNano())
+		id = UUIDGenerator{}.NewID()
 	}
-	_, err := s.db.Exec(`
-		INSERT INTO audits (id, patient_ref, complaint, risk_level, risk_score, user_id, at_utc)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`, id, entry.PatientRef, entry.Complaint, entry.RiskLevel, entry.RiskScore, entry.UserID, now.Format(time.RFC3339))
+	patientRef, err := encryptColumn(s.cipher, "patient_ref", id, entry.PatientRef)
 	if err != nil {
-		return Summary{}, fmt.Errorf("insert audit: %w", err)
+		return auditRow{}, err
 	}
-	return Summary{
-		AuditID:    id,
-		PatientRef: entry.PatientRef,
-		Complaint:  entry.Complaint,
-		RiskLevel:  entry.RiskLevel,
-		RiskScore:  entry.RiskScore,
-		UserID:     entry.UserID,
-		At:         now.Format(time.RFC3339),
+	complaint, err := encryptColumn(s.cipher, "complaint", id, entry.Complaint)
+	if err != nil {
+		return auditRow{}, err
+	}
+	response, err := encryptColumn(s.cipher, "response_json", id, string(entry.Response))
+	if err != nil {
+		return auditRow{}, err
+	}
+	intake, err := encryptColumn(s.cipher, "intake_json", id, string(entry.Intake))
+	if err != nil {
+		return auditRow{}, err
+	}
+	var consentGiven sql.NullBool
+	var consentAt, consentMethod sql.NullString
+	if c := entry.Consent; c != nil {
+		consentGiven = sql.NullBool{Bool: c.Given, Valid: true}
+		consentAt = sql.NullString{String: c.Timestamp, Valid: true}
+		consentMethod = sql.NullString{String: c.Method, Valid: true}
+	}
+	var sigKeyID, sigAlg, sig sql.NullString
+	if g := entry.Signature; g != nil {
+		sigKeyID = sql.NullString{String: g.KeyID, Valid: true}
+		sigAlg = sql.NullString{String: g.Algorithm, Valid: true}
+		sig = sql.NullString{String: g.Value, Valid: true}
+	}
+	return auditRow{
+		summary: summaryOf(id, entry, now),
+		args: []any{id, patientRef, complaint, entry.RiskLevel, entry.RiskScore, entry.UserID, now.Format(time.RFC3339),
+			entry.LLM.Model, entry.LLM.PromptTokens, entry.LLM.CompletionTokens, entry.LLM.LatencyMs, entry.PromptVersion, response,
+			patientKeyOrNull(s.cipher, entry.PatientRef), intake, consentGiven, consentAt, consentMethod, entry.RulesetVersion,
+			durationMs(entry.Duration), durationMs(entry.LLMDuration), org, sigKeyID, sigAlg, sig},
 	}, nil
 }
 
-func (s *SQLiteStore) Latest(limit int) ([]Summary, error) {
+func (s *SQLiteStore) Latest(ctx context.Context, limit int) ([]Summary, error) {
 	if limit <= 0 || limit > maxLimit {
 		limit = 10
 	}
-	rows, err := s.db.Query(`
-		SELECT id, patient_ref, complaint, risk_level, risk_score, user_id, at_utc
+	return s.querySummaries(ctx, `
+		SELECT `+summaryColumns+`
 		FROM audits
+		WHERE deleted_at IS NULL AND org_id = ?
 		ORDER BY at_utc DESC
 		LIMIT ?
-	`, limit)
+	`, OrgFrom(ctx), limit)
+}
+
+// summaryColumns are scanned by querySummaries, in order.
+const summaryColumns = `id, patient_ref, complaint, risk_level, risk_score, user_id, at_utc,
+			llm_model, llm_prompt_tokens, llm_completion_tokens, llm_latency_ms, prompt_version,
+			consent_given, consent_at, consent_method, ruleset_version, duration_ms, llm_duration_ms, deleted_at,
+			signature_key_id, signature_alg, signature`
+
+func (s *SQLiteStore) querySummaries(ctx context.Context, query string, args ...any) ([]Summary, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("query audits: %w", err)
 	}
@@ -113,66 +312,364 @@ func (s *SQLiteStore) Latest(limit int) ([]Summary, error) {
 	var out []Summary
 	for rows.Next() {
 		var sEntry Summary
-		if err := rows.Scan(&sEntry.AuditID, &sEntry.PatientRef, &sEntry.Complaint, &sEntry.RiskLevel, &sEntry.RiskScore, &sEntry.UserID, &sEntry.At); err != nil {
+		var model, promptVersion, consentAt, consentMethod, rulesetVersion, deletedAt sql.NullString
+		var sigKeyID, sigAlg, sig sql.NullString
+		var prompt, completion, latency sql.NullInt64
+		var consentGiven sql.NullBool
+		var duration, llmDuration sql.NullFloat64
+		if err := rows.Scan(&sEntry.AuditID, &sEntry.PatientRef, &sEntry.Complaint, &sEntry.RiskLevel, &sEntry.RiskScore, &sEntry.UserID, &sEntry.At,
+			&model, &prompt, &completion, &latency, &promptVersion, &consentGiven, &consentAt, &consentMethod, &rulesetVersion,
+			&duration, &llmDuration, &deletedAt, &sigKeyID, &sigAlg, &sig); err != nil {
 			return nil, fmt.Errorf("scan audit: %w", err)
 		}
+		if sEntry.PatientRef, err = decryptColumn(s.cipher, "patient_ref", sEntry.AuditID, sEntry.PatientRef); err != nil {
+			return nil, fmt.Errorf("audit %s patient_ref: %w", sEntry.AuditID, err)
+		}
+		if sEntry.Complaint, err = decryptColumn(s.cipher, "complaint", sEntry.AuditID, sEntry.Complaint); err != nil {
+			return nil, fmt.Errorf("audit %s complaint: %w", sEntry.AuditID, err)
+		}
+		sEntry.LLM = usageOf(LLMUsage{
+			Model:            model.String,
+			PromptTokens:     int(prompt.Int64),
+			CompletionTokens: int(completion.Int64),
+			LatencyMs:        latency.Int64,
+		})
+		sEntry.PromptVersion = promptVersion.String
+		sEntry.RulesetVersion = rulesetVersion.String
+		sEntry.DurationMs, sEntry.LLMDurationMs = duration.Float64, llmDuration.Float64
+		sEntry.DeletedAt = deletedAt.String
+		if consentGiven.Valid {
+			sEntry.Consent = &Consent{Given: consentGiven.Bool, Timestamp: consentAt.String, Method: consentMethod.String}
+		}
+		if sig.Valid {
+			sEntry.Signature = &Signature{KeyID: sigKeyID.String, Algorithm: sigAlg.String, Value: sig.String}
+		}
 		out = append(out, sEntry)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read audits: %w", err)
+	}
+	if err := s.attachDecisions(ctx, out); err != nil {
+		return nil, err
+	}
 	return out, nil
 }
 
-// MemoryStore is a lightweight fallback for tests and offline use.
+// Ping checks that the database is reachable.
+func (s *SQLiteStore) Ping(ctx context.Context) error {
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("ping sqlite: %w", err)
+	}
+	return nil
+}
+
+// Close closes the database; the store must not be used afterwards.
+func (s *SQLiteStore) Close() error {
+	s.insert.Close()
+	return s.db.Close()
+}
+
+// ResponseReader is implemented by stores that keep the full response JSON.
+type ResponseReader interface {
+	Response(ctx context.Context, id string) (json.RawMessage, error)
+}
+
+// Response returns the stored response for id, decrypting it when needed.
+func (s *SQLiteStore) Response(ctx context.Context, id string) (json.RawMessage, error) {
+	return s.jsonColumn(ctx, "response_json", id)
+}
+
+// IntakeReader is implemented by stores that keep the analyzed intake.
+type IntakeReader interface {
+	// Intake returns the intake stored with audit id: ErrNotFound for an
+	// unknown id, nil for an audit written before intakes were kept.
+	Intake(ctx context.Context, id string) (json.RawMessage, error)
+}
+
+// Intake returns the stored intake for id, decrypting it when needed.
+func (s *SQLiteStore) Intake(ctx context.Context, id string) (json.RawMessage, error) {
+	return s.jsonColumn(ctx, "intake_json", id)
+}
+
+// SummaryReader is implemented by stores that can look up one audit.
+type SummaryReader interface {
+	// Summary returns audit id, or ErrNotFound.
+	Summary(ctx context.Context, id string) (Summary, error)
+}
+
+// Summary returns audit id with its current decision.
+func (s *SQLiteStore) Summary(ctx context.Context, id string) (Summary, error) {
+	out, err := s.querySummaries(ctx, `
+		SELECT `+summaryColumns+`
+		FROM audits
+		WHERE id = ? AND org_id = ?
+	`, id, OrgFrom(ctx))
+	if err != nil {
+		return Summary{}, err
+	}
+	if len(out) == 0 {
+		return Summary{}, ErrNotFound
+	}
+	return out[0], nil
+}
+
+// jsonColumn reads one of the JSON document columns; column is a constant.
+func (s *SQLiteStore) jsonColumn(ctx context.Context, column, id string) (json.RawMessage, error) {
+	var raw sql.NullString
+	err := s.db.QueryRowContext(ctx, `SELECT `+column+` FROM audits WHERE id = ? AND org_id = ?`, id, OrgFrom(ctx)).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query %s: %w", column, err)
+	}
+	plain, err := decryptColumn(s.cipher, column, id, raw.String)
+	if err != nil {
+		return nil, fmt.Errorf("audit %s %s: %w", id, column, err)
+	}
+	if plain == "" {
+		return nil, nil
+	}
+	return json.RawMessage(plain), nil
+}
+
+// DefaultMemoryCapacity is how many audits a MemoryStore keeps unless
+// WithCapacity says otherwise.
+const DefaultMemoryCapacity = maxLimit
+
+// MemoryStore is a lightweight fallback for tests and offline use. Past its
+// capacity it evicts the oldest audits, with their responses, intakes,
+// decisions, acknowledgments, and reminders.
 type MemoryStore struct {
-	mu      sync.Mutex
-	entries []Summary
+	mu        sync.Mutex
+	entries   []Summary
+	responses map[string]json.RawMessage
+	intakes   map[string]json.RawMessage
+	decisions map[string][]Decision
+	deletions map[string][]Deletion
+	acks      map[string][]IssueAck
+	// orgs is the org each retained audit was written under.
+	orgs    map[string]string
+	shadows []orgScoped[ShadowEntry]
+
+	rulesChanges []RulesChange
+	reanalyses   []orgScoped[Reanalysis]
+
+	validationFailures []orgScoped[ValidationFailure]
+
+	reminders []Reminder
+
+	capacity int
+	onEvict  func(Summary)
 }
 
-func NewMemoryStore() *MemoryStore {
-	return &MemoryStore{entries: []Summary{}}
+// MemoryOption configures a MemoryStore.
+type MemoryOption func(*MemoryStore)
+
+// WithCapacity sets how many audits the store keeps; 0 keeps them all.
+func WithCapacity(n int) MemoryOption {
+	return func(m *MemoryStore) {
+		m.capacity = max(0, n)
+	}
+}
+
+// WithEviction calls fn with each audit evicted to honor the capacity,
+// oldest first, so it can be logged or spilled elsewhere. fn runs after the
+// insert that caused the eviction, outside the store's lock.
+func WithEviction(fn func(Summary)) MemoryOption {
+	return func(m *MemoryStore) {
+		m.onEvict = fn
+	}
+}
+
+// NewMemoryStore returns an empty store keeping DefaultMemoryCapacity audits
+// unless opts say otherwise.
+func NewMemoryStore(opts ...MemoryOption) *MemoryStore {
+	m := &MemoryStore{
+		entries:   []Summary{},
+		responses: map[string]json.RawMessage{},
+		intakes:   map[string]json.RawMessage{},
+		decisions: map[string][]Decision{},
+		deletions: map[string][]Deletion{},
+		acks:      map[string][]IssueAck{},
+		orgs:      map[string]string{},
+		capacity:  DefaultMemoryCapacity,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
 }
 
-func (m *MemoryStore) Insert(entry Entry) (Summary, error) {
+// Len reports how many audits the store holds.
+func (m *MemoryStore) Len() int {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	return len(m.entries)
+}
 
+func (m *MemoryStore) Insert(ctx context.Context, entry Entry) (Summary, error) {
+	if err := ctx.Err(); err != nil {
+		return Summary{}, err
+	}
+	m.mu.Lock()
 	now := entry.At
 	if now.IsZero() {
 		now = time.Now().UTC()
 	}
 	id := entry.ID
 	if id == "" {
-		id = fmt.Sprintf("audit-%d", time.Now().UnixNano())
-	}
-	sum := Summary{
-		AuditID:    id,
-		PatientRef: entry.PatientRef,
-		Complaint:  entry.Complaint,
-		RiskLevel:  entry.RiskLevel,
-		RiskScore:  entry.RiskScore,
-		UserID:     entry.UserID,
-		At:         now.Format(time.RFC3339),
+		id = UUIDGenerator{}.NewID()
 	}
+	sum := summaryOf(id, entry, now)
 
 	m.entries = append(m.entries, sum)
-	if len(m.entries) > maxLimit {
-		m.entries = m.entries[len(m.entries)-maxLimit:]
+	m.orgs[id] = OrgFrom(ctx)
+	if len(entry.Response) > 0 {
+		m.responses[id] = append(json.RawMessage(nil), entry.Response...)
+	}
+	if len(entry.Intake) > 0 {
+		m.intakes[id] = append(json.RawMessage(nil), entry.Intake...)
+	}
+	var evicted []Summary
+	if m.capacity > 0 && len(m.entries) > m.capacity {
+		evicted = m.withDecisions(slices.Clone(m.entries[:len(m.entries)-m.capacity]))
+		for _, dropped := range evicted {
+			delete(m.responses, dropped.AuditID)
+			delete(m.intakes, dropped.AuditID)
+			delete(m.decisions, dropped.AuditID)
+			delete(m.deletions, dropped.AuditID)
+			delete(m.acks, dropped.AuditID)
+			delete(m.orgs, dropped.AuditID)
+		}
+		m.dropReminders(evicted)
+		m.entries = m.entries[len(m.entries)-m.capacity:]
+	}
+	m.mu.Unlock()
+
+	if m.onEvict != nil {
+		for _, dropped := range evicted {
+			m.onEvict(dropped)
+		}
 	}
 	return sum, nil
 }
 
-func (m *MemoryStore) Latest(limit int) ([]Summary, error) {
+func (m *MemoryStore) Latest(ctx context.Context, limit int) ([]Summary, error) {
+	return m.latest(ctx, limit, false)
+}
+
+// latest returns the newest limit audits, oldest first, leaving deleted ones
+// out unless includeDeleted.
+func (m *MemoryStore) latest(ctx context.Context, limit int, includeDeleted bool) ([]Summary, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	if limit <= 0 || limit > maxLimit {
 		limit = 10
 	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	n := len(m.entries)
-	start := n - limit
-	if start < 0 {
-		start = 0
+	org := OrgFrom(ctx)
+	out := []Summary{}
+	for i := len(m.entries) - 1; i >= 0 && len(out) < limit; i-- {
+		if e := m.entries[i]; m.orgs[e.AuditID] == org && (includeDeleted || e.DeletedAt == "") {
+			out = append(out, e)
+		}
 	}
-	out := make([]Summary, 0, n-start)
-	out = append(out, m.entries[start:]...)
-	return out, nil
+	reverse(out)
+	return m.withDecisions(out), nil
+}
+
+// Response returns the stored response for id while it is still retained.
+func (m *MemoryStore) Response(ctx context.Context, id string) (json.RawMessage, error) {
+	return m.document(ctx, m.responses, id)
+}
+
+// Intake returns the stored intake for id while it is still retained.
+func (m *MemoryStore) Intake(ctx context.Context, id string) (json.RawMessage, error) {
+	return m.document(ctx, m.intakes, id)
+}
+
+func (m *MemoryStore) document(ctx context.Context, docs map[string]json.RawMessage, id string) (json.RawMessage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.retained(ctx, id) {
+		return nil, ErrNotFound
+	}
+	return append(json.RawMessage(nil), docs[id]...), nil
+}
+
+// Summary returns audit id while it is still retained.
+func (m *MemoryStore) Summary(ctx context.Context, id string) (Summary, error) {
+	if err := ctx.Err(); err != nil {
+		return Summary{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range m.entries {
+		if e.AuditID == id && m.orgs[id] == OrgFrom(ctx) {
+			return m.withDecisions([]Summary{e})[0], nil
+		}
+	}
+	return Summary{}, ErrNotFound
+}
+
+// Ping reports ctx errors only; the memory store is always available.
+func (m *MemoryStore) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// Close is a no-op.
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+func summaryOf(id string, entry Entry, at time.Time) Summary {
+	return Summary{
+		AuditID:        id,
+		PatientRef:     entry.PatientRef,
+		Complaint:      entry.Complaint,
+		RiskLevel:      entry.RiskLevel,
+		RiskScore:      entry.RiskScore,
+		UserID:         entry.UserID,
+		At:             at.Format(time.RFC3339),
+		LLM:            usageOf(entry.LLM),
+		PromptVersion:  entry.PromptVersion,
+		RulesetVersion: entry.RulesetVersion,
+		Consent:        consentOf(entry.Consent),
+		DurationMs:     durationMs(entry.Duration).Float64,
+		LLMDurationMs:  durationMs(entry.LLMDuration).Float64,
+		Signature:      signatureOf(entry.Signature),
+	}
+}
+
+// consentOf copies c so a Summary does not alias the caller's Entry.
+func consentOf(c *Consent) *Consent {
+	if c == nil {
+		return nil
+	}
+	cp := *c
+	return &cp
+}
+
+// signatureOf copies g so a Summary does not alias the caller's Entry.
+func signatureOf(g *Signature) *Signature {
+	if g == nil {
+		return nil
+	}
+	cp := *g
+	return &cp
+}
+
+// usageOf returns nil for an empty usage record so stub-scored audits omit it.
+func usageOf(u LLMUsage) *LLMUsage {
+	if u == (LLMUsage{}) {
+		return nil
+	}
+	return &u
 }
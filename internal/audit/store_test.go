@@ -0,0 +1,404 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSQLiteStore_ConcurrentInsertAndLatest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.db")
+	// Two handles on one file contend for the SQLite write lock, which the
+	// per-store mutex cannot serialize.
+	stores := []*SQLiteStore{openStore(t, path, nil), openStore(t, path, nil)}
+
+	const writers, perWriter = 8, 50
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*perWriter*2)
+	for w := 0; w < writers; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			s := stores[w%len(stores)]
+			for i := 0; i < perWriter; i++ {
+				if _, err := s.Insert(t.Context(), Entry{ID: fmt.Sprintf("w%d-%d", w, i), RiskLevel: "LOW", Response: sampleResponse}); err != nil {
+					errs <- fmt.Errorf("insert: %w", err)
+				}
+			}
+		}(w)
+		go func(w int) {
+			defer wg.Done()
+			s := stores[(w+1)%len(stores)]
+			for i := 0; i < perWriter; i++ {
+				if _, err := s.Latest(t.Context(), 10); err != nil {
+					errs <- fmt.Errorf("latest: %w", err)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	var n int
+	if err := stores[0].db.QueryRow(`SELECT COUNT(*) FROM audits`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != writers*perWriter {
+		t.Fatalf("rows = %d, want %d", n, writers*perWriter)
+	}
+	var mode string
+	if err := stores[0].db.QueryRow(`PRAGMA journal_mode`).Scan(&mode); err != nil {
+		t.Fatal(err)
+	}
+	if mode != "wal" {
+		t.Fatalf("journal_mode = %q, want wal", mode)
+	}
+}
+
+type codedErr int
+
+func (e codedErr) Error() string { return fmt.Sprintf("sqlite code %d", int(e)) }
+func (e codedErr) Code() int     { return int(e) }
+
+func TestRetryBusy(t *testing.T) {
+	calls := 0
+	err := retryBusy(t.Context(), func() error {
+		calls++
+		if calls < 3 {
+			return fmt.Errorf("exec: %w", codedErr(517)) // SQLITE_BUSY_SNAPSHOT
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("err=%v calls=%d, want success after 3 calls", err, calls)
+	}
+
+	calls = 0
+	constraint := codedErr(19)
+	if err := retryBusy(t.Context(), func() error { calls++; return constraint }); !errors.Is(err, constraint) || calls != 1 {
+		t.Fatalf("err=%v calls=%d, want non-busy error returned immediately", err, calls)
+	}
+}
+
+func TestSQLiteStore_PingCloseAndCancel(t *testing.T) {
+	s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "audit.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Ping(t.Context()); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	if _, err := s.Insert(ctx, Entry{ID: "a1"}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("insert with cancelled ctx: %v, want context.Canceled", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := s.Ping(t.Context()); err == nil {
+		t.Fatal("ping after close succeeded")
+	}
+}
+
+// oldStore implements only the pre-context interface.
+type oldStore struct{ m *MemoryStore }
+
+func (o oldStore) Insert(e Entry) (Summary, error) { return o.m.Insert(context.Background(), e) }
+func (o oldStore) Latest(limit int) ([]Summary, error) {
+	return o.m.Latest(context.Background(), limit)
+}
+
+func TestAdaptLegacy(t *testing.T) {
+	s := AdaptLegacy(oldStore{NewMemoryStore()})
+	if _, err := s.Insert(t.Context(), Entry{ID: "a1"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Latest(t.Context(), 1)
+	if err != nil || len(got) != 1 || got[0].AuditID != "a1" {
+		t.Fatalf("latest = %+v, %v", got, err)
+	}
+	if err := s.Ping(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStore_RecordsConsent(t *testing.T) {
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"sqlite": openStore(t, filepath.Join(t.TempDir(), "audit.db"), nil),
+	}
+	consent := &Consent{Given: true, Timestamp: "2025-03-01T09:00:00Z", Method: "written"}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			if _, err := s.Insert(t.Context(), Entry{ID: "with", Consent: consent}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Insert(t.Context(), Entry{ID: "without"}); err != nil {
+				t.Fatal(err)
+			}
+			got, err := s.Latest(t.Context(), 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			byID := map[string]*Consent{}
+			for _, sum := range got {
+				byID[sum.AuditID] = sum.Consent
+			}
+			if c := byID["with"]; c == nil || *c != *consent {
+				t.Fatalf("consent = %+v, want %+v", c, consent)
+			}
+			if c := byID["without"]; c != nil {
+				t.Fatalf("audit without consent got %+v", c)
+			}
+		})
+	}
+}
+
+func TestStore_RecordsSignature(t *testing.T) {
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"sqlite": openStore(t, filepath.Join(t.TempDir(), "audit.db"), nil),
+	}
+	sig := &Signature{KeyID: "k1", Algorithm: "Ed25519", Value: "c2ln"}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			if _, err := s.Insert(t.Context(), Entry{ID: "signed", Signature: sig}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Insert(t.Context(), Entry{ID: "unsigned"}); err != nil {
+				t.Fatal(err)
+			}
+			got, err := s.Latest(t.Context(), 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			byID := map[string]*Signature{}
+			for _, sum := range got {
+				byID[sum.AuditID] = sum.Signature
+			}
+			if g := byID["signed"]; g == nil || *g != *sig {
+				t.Fatalf("signature = %+v, want %+v", g, sig)
+			}
+			if g := byID["unsigned"]; g != nil {
+				t.Fatalf("unsigned audit got %+v", g)
+			}
+		})
+	}
+}
+
+func TestStore_RulesChanges(t *testing.T) {
+	stores := map[string]RulesChangeStore{
+		"memory": NewMemoryStore(),
+		"sqlite": openStore(t, filepath.Join(t.TempDir(), "audit.db"), nil),
+	}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			for _, c := range []RulesChange{{OldVersion: "a", NewVersion: "b", Source: "api", Actor: "admin"}, {OldVersion: "b", NewVersion: "c", Source: "rules.json"}} {
+				if err := s.InsertRulesChange(t.Context(), c); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := s.RulesChanges(t.Context())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 2 || got[0].NewVersion != "b" || got[0].Actor != "admin" || got[1].OldVersion != "b" || got[1].At.IsZero() {
+				t.Fatalf("changes = %+v", got)
+			}
+		})
+	}
+}
+
+func TestStore_ListByRulesetVersion(t *testing.T) {
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"sqlite": openStore(t, filepath.Join(t.TempDir(), "audit.db"), nil),
+	}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			at := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+			for i, v := range []string{"r1", "r2", "r1"} {
+				if _, err := s.Insert(t.Context(), Entry{ID: fmt.Sprintf("a%d", i), RulesetVersion: v, At: at.Add(time.Duration(i) * time.Minute)}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := s.(RulesetHistory).ListByRulesetVersion(t.Context(), "r1", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 2 || got[0].AuditID != "a0" || got[1].AuditID != "a2" || got[1].RulesetVersion != "r1" {
+				t.Fatalf("audits = %+v", got)
+			}
+		})
+	}
+}
+
+func TestStore_Summary(t *testing.T) {
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"sqlite": openStore(t, filepath.Join(t.TempDir(), "audit.db"), nil),
+	}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			at := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+			for i, ref := range []string{"p1", "p2"} {
+				if _, err := s.Insert(t.Context(), Entry{ID: fmt.Sprintf("a%d", i), PatientRef: ref, At: at}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := s.(DecisionStore).InsertDecision(t.Context(), Decision{AuditID: "a1", Decision: "accepted"}, false); err != nil {
+				t.Fatal(err)
+			}
+			reader := s.(SummaryReader)
+			got, err := reader.Summary(t.Context(), "a1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.AuditID != "a1" || got.PatientRef != "p2" || got.Decision == nil || got.Decision.Decision != "accepted" {
+				t.Fatalf("summary = %+v", got)
+			}
+			if _, err := reader.Summary(t.Context(), "missing"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("missing audit: %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestStore_Reanalyses(t *testing.T) {
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"sqlite": openStore(t, filepath.Join(t.TempDir(), "audit.db"), nil),
+	}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			at := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+			for i, level := range []string{"low", "high", "high"} {
+				if _, err := s.Insert(t.Context(), Entry{ID: fmt.Sprintf("a%d", i), RiskLevel: level, At: at.Add(time.Duration(i) * time.Hour)}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ids, err := s.(RangeLister).AuditIDsInRange(t.Context(), Range{From: at, To: at.Add(3 * time.Hour), RiskLevel: "high"}, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ids) != 2 || ids[0] != "a1" || ids[1] != "a2" {
+				t.Fatalf("ids = %v", ids)
+			}
+			ids, err = s.(RangeLister).AuditIDsInRange(t.Context(), Range{From: at.Add(time.Hour)}, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ids) != 1 || ids[0] != "a1" {
+				t.Fatalf("limited ids = %v", ids)
+			}
+
+			rs := s.(ReanalysisStore)
+			if err := rs.InsertReanalysis(t.Context(), Reanalysis{ID: "r1", AuditID: "a1", RulesetVersionBefore: "v1", RulesetVersionAfter: "v2", RiskLevelBefore: "high", RiskLevelAfter: "moderate", RiskScoreBefore: 70, RiskScoreAfter: 40, Changed: true, At: at}); err != nil {
+				t.Fatal(err)
+			}
+			got, err := rs.Reanalyses(t.Context(), "a1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 1 || !got[0].Changed || got[0].RiskScoreAfter != 40 || got[0].RulesetVersionBefore != "v1" || !got[0].At.Equal(at) {
+				t.Fatalf("reanalyses = %+v", got)
+			}
+			if got, _ := rs.Reanalyses(t.Context(), "a0"); len(got) != 0 {
+				t.Fatalf("a0 reanalyses = %+v", got)
+			}
+		})
+	}
+}
+
+func TestStore_ValidationFailures(t *testing.T) {
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"sqlite": openStore(t, filepath.Join(t.TempDir(), "audit.db"), nil),
+	}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			at := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+			vs := s.(ValidationFailureStore)
+			for i := range 3 {
+				f := ValidationFailure{ID: fmt.Sprintf("v%d", i), Errors: []FieldCode{{Field: "bp", Code: "required"}}, UserID: "u1", At: at.Add(time.Duration(i) * time.Hour)}
+				if err := vs.InsertValidationFailure(t.Context(), f); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := vs.LatestValidationFailures(t.Context(), 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 2 || got[0].ID != "v2" || got[1].ID != "v1" || got[0].UserID != "u1" || !got[0].At.Equal(at.Add(2*time.Hour)) {
+				t.Fatalf("validation failures = %+v", got)
+			}
+			if len(got[0].Errors) != 1 || got[0].Errors[0] != (FieldCode{Field: "bp", Code: "required"}) {
+				t.Fatalf("errors = %+v", got[0].Errors)
+			}
+			if audits, _ := s.Latest(t.Context(), 10); len(audits) != 0 {
+				t.Fatalf("validation failures should not be listed as audits: %+v", audits)
+			}
+		})
+	}
+}
+
+func TestMemoryStore_Capacity(t *testing.T) {
+	insert := func(m *MemoryStore, n int) {
+		t.Helper()
+		for i := range n {
+			id := fmt.Sprintf("a%02d", i)
+			if _, err := m.Insert(t.Context(), Entry{ID: id, Response: []byte(`{}`)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	def := NewMemoryStore()
+	insert(def, DefaultMemoryCapacity+5)
+	if def.Len() != DefaultMemoryCapacity {
+		t.Fatalf("default store holds %d, want %d", def.Len(), DefaultMemoryCapacity)
+	}
+
+	var evicted []string
+	var m *MemoryStore
+	m = NewMemoryStore(WithCapacity(3), WithEviction(func(s Summary) {
+		// Runs outside the lock, so the store is usable here.
+		if _, err := m.Response(t.Context(), s.AuditID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("evicted %s still has a response: %v", s.AuditID, err)
+		}
+		if s.AuditID == "x" && s.Decision == nil {
+			t.Error("evicted summary lacks its decision")
+		}
+		evicted = append(evicted, s.AuditID)
+	}))
+	if _, err := m.Insert(t.Context(), Entry{ID: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.InsertDecision(t.Context(), Decision{AuditID: "x", Decision: "approved"}, false); err != nil {
+		t.Fatal(err)
+	}
+	insert(m, 4)
+	if fmt.Sprint(evicted) != "[x a00]" || m.Len() != 3 {
+		t.Fatalf("evicted %v, len %d", evicted, m.Len())
+	}
+
+	unbounded := NewMemoryStore(WithCapacity(0))
+	insert(unbounded, 2*DefaultMemoryCapacity)
+	if unbounded.Len() != 2*DefaultMemoryCapacity {
+		t.Fatalf("unbounded store holds %d", unbounded.Len())
+	}
+	if latest, _ := unbounded.Latest(t.Context(), maxLimit); len(latest) != maxLimit || latest[len(latest)-1].AuditID != fmt.Sprintf("a%02d", 2*DefaultMemoryCapacity-1) {
+		t.Fatalf("latest of unbounded store = %d entries", len(latest))
+	}
+}
@@ -0,0 +1,130 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ValidationFailure records an analysis attempt rejected by intake
+// validation. It keeps the failing fields and their error codes, never the
+// values or messages, so it holds no patient data.
+type ValidationFailure struct {
+	ID     string      `json:"id"`
+	Errors []FieldCode `json:"errors"`
+	UserID string      `json:"userId,omitempty"`
+	At     time.Time   `json:"at"`
+}
+
+// FieldCode is one validation error of a ValidationFailure.
+type FieldCode struct {
+	Field string `json:"field"`
+	Code  string `json:"code"`
+}
+
+// ValidationFailureStore is implemented by stores that can keep validation
+// failures. They are kept apart from audits so clinical listings, history,
+// and statistics never see them.
+type ValidationFailureStore interface {
+	InsertValidationFailure(ctx context.Context, f ValidationFailure) error
+	// LatestValidationFailures returns up to limit failures, newest first.
+	LatestValidationFailures(ctx context.Context, limit int) ([]ValidationFailure, error)
+}
+
+const maxMemoryValidationFailures = 1000
+
+func (s *SQLiteStore) InsertValidationFailure(ctx context.Context, f ValidationFailure) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	at := f.At
+	if at.IsZero() {
+		at = time.Now().UTC()
+	}
+	errs, err := json.Marshal(f.Errors)
+	if err != nil {
+		return fmt.Errorf("encode validation failure: %w", err)
+	}
+	err = retryBusy(ctx, func() error {
+		_, err := s.db.ExecContext(ctx, `
+			INSERT INTO validation_failures (id, errors_json, user_id, at_utc, org_id)
+			VALUES (?, ?, ?, ?, ?)
+		`, f.ID, string(errs), f.UserID, at.Format(time.RFC3339), OrgFrom(ctx))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("insert validation failure: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) LatestValidationFailures(ctx context.Context, limit int) ([]ValidationFailure, error) {
+	if limit <= 0 || limit > maxLimit {
+		limit = 10
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, errors_json, user_id, at_utc
+		FROM validation_failures
+		WHERE org_id = ?
+		ORDER BY at_utc DESC, rowid DESC
+		LIMIT ?
+	`, OrgFrom(ctx), limit)
+	if err != nil {
+		return nil, fmt.Errorf("query validation failures: %w", err)
+	}
+	defer rows.Close()
+	out := []ValidationFailure{}
+	for rows.Next() {
+		var f ValidationFailure
+		var errs, at string
+		if err := rows.Scan(&f.ID, &errs, &f.UserID, &at); err != nil {
+			return nil, fmt.Errorf("scan validation failure: %w", err)
+		}
+		if err := json.Unmarshal([]byte(errs), &f.Errors); err != nil {
+			return nil, fmt.Errorf("decode validation failure %s: %w", f.ID, err)
+		}
+		f.At, _ = time.Parse(time.RFC3339, at)
+		out = append(out, f)
+	}
+	return out, rows.Err()
+}
+
+func (m *MemoryStore) InsertValidationFailure(ctx context.Context, f ValidationFailure) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if f.At.IsZero() {
+		f.At = time.Now().UTC()
+	}
+	f.Errors = slices.Clone(f.Errors)
+	m.validationFailures = append(m.validationFailures, orgScoped[ValidationFailure]{OrgFrom(ctx), f})
+	if len(m.validationFailures) > maxMemoryValidationFailures {
+		m.validationFailures = m.validationFailures[len(m.validationFailures)-maxMemoryValidationFailures:]
+	}
+	return nil
+}
+
+func (m *MemoryStore) LatestValidationFailures(ctx context.Context, limit int) ([]ValidationFailure, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > maxLimit {
+		limit = 10
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []ValidationFailure{}
+	for i := len(m.validationFailures) - 1; i >= 0 && len(out) < limit; i-- {
+		if m.validationFailures[i].org != OrgFrom(ctx) {
+			continue
+		}
+		f := m.validationFailures[i].item
+		f.Errors = slices.Clone(f.Errors)
+		out = append(out, f)
+	}
+	return out, nil
+}
@@ -0,0 +1,225 @@
+// Package csvimport reads intakes from a spreadsheet export, one CSV row per
+// patient, for bulk import.
+package csvimport
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+)
+
+// Columns is the import layout, in the documented order. The header row must
+// name each column at most once, in any order and letter case. Spaces and
+// punctuation are ignored, and a few common spellings are accepted, such as
+// "Patient name", "Weight (kg)", and "Blood pressure".
+//
+//   - name: the patient name
+//   - age: whole years
+//   - weight, height: kilograms and centimetres
+//   - bp: systolic/diastolic, e.g. 120/80
+//   - conditions: semicolon-separated; "none" confirms there are none
+//   - medications: semicolon-separated name:dose:freq triples, dose and
+//     frequency optional (sildenafil:50mg:prn;amlodipine:5mg); "none"
+//     confirms there are none
+//   - complaint: the presenting complaint
+var Columns = []string{"name", "age", "weight", "height", "bp", "conditions", "medications", "complaint"}
+
+// requiredColumns must appear in the header; a file without them would fail
+// every row.
+var requiredColumns = []string{"name", "age", "complaint"}
+
+// columnAliases maps other header spellings, after normalizeHeader, to a
+// column.
+var columnAliases = map[string]string{
+	"patient":       "name",
+	"patientname":   "name",
+	"ageyears":      "age",
+	"weightkg":      "weight",
+	"heightcm":      "height",
+	"bloodpressure": "bp",
+	"bpmmhg":        "bp",
+	"meds":          "medications",
+}
+
+// Row is one data row. Line is where the row starts in the file, counting the
+// header as line 1, so it matches the spreadsheet row. Errors lists the cells
+// that could not be read; such a row should not be analyzed.
+type Row struct {
+	Line   int
+	Intake analysis.Intake
+	Errors []string
+}
+
+// Reader reads Rows from CSV without holding the file in memory.
+type Reader struct {
+	r       *csv.Reader
+	columns []string // column of each header field
+}
+
+// NewReader reads the header row of r and reports a header that is missing,
+// names a column twice, lacks a required column, or names an unknown one. A
+// leading UTF-8 byte order mark, as spreadsheet exports write, is skipped.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	if b, err := br.Peek(3); err == nil && string(b) == "\ufeff" {
+		_, _ = br.Discard(3)
+	}
+	cr := csv.NewReader(br)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("csvimport: the file is empty; want a header row")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("csvimport: header: %w", err)
+	}
+	columns := make([]string, len(header))
+	seen := map[string]bool{}
+	for i, h := range header {
+		key := normalizeHeader(h)
+		if alias, ok := columnAliases[key]; ok {
+			key = alias
+		}
+		switch {
+		case key == "":
+			return nil, fmt.Errorf("csvimport: header field %d is blank", i+1)
+		case !slices.Contains(Columns, key):
+			return nil, fmt.Errorf("csvimport: unknown column %q; want %s", strings.TrimSpace(h), strings.Join(Columns, ", "))
+		case seen[key]:
+			return nil, fmt.Errorf("csvimport: column %s appears twice", key)
+		}
+		seen[key] = true
+		columns[i] = key
+	}
+	for _, c := range requiredColumns {
+		if !seen[c] {
+			return nil, fmt.Errorf("csvimport: the header has no %s column", c)
+		}
+	}
+	return &Reader{r: cr, columns: columns}, nil
+}
+
+// Next returns the next data row, skipping rows whose cells are all blank, and
+// io.EOF after the last. Any other error means the file is malformed from
+// that point and reading should stop.
+func (r *Reader) Next() (Row, error) {
+	for {
+		record, err := r.r.Read()
+		if err != nil {
+			return Row{}, err
+		}
+		line, _ := r.r.FieldPos(0)
+		if blank(record) {
+			continue
+		}
+		row := Row{Line: line}
+		if len(record) != len(r.columns) {
+			row.Errors = append(row.Errors, fmt.Sprintf("row has %d fields, the header has %d", len(record), len(r.columns)))
+			return row, nil
+		}
+		for i, cell := range record {
+			if err := set(&row.Intake, r.columns[i], strings.TrimSpace(cell)); err != nil {
+				row.Errors = append(row.Errors, err.Error())
+			}
+		}
+		return row, nil
+	}
+}
+
+// set stores one cell in the intake. Error messages name the column but not
+// the value, which may hold patient data.
+func set(in *analysis.Intake, column, cell string) error {
+	switch column {
+	case "name":
+		in.PatientName = cell
+	case "age":
+		if cell == "" {
+			return nil
+		}
+		n, err := strconv.Atoi(cell)
+		if err != nil {
+			return errors.New("age must be a whole number")
+		}
+		in.Age = n
+	case "weight", "height":
+		if cell == "" {
+			return nil
+		}
+		f, err := strconv.ParseFloat(cell, 64)
+		if err != nil {
+			return fmt.Errorf("%s must be a number", column)
+		}
+		if column == "weight" {
+			in.WeightKg = f
+		} else {
+			in.HeightCm = f
+		}
+	case "bp":
+		in.BP = cell
+	case "conditions":
+		if strings.EqualFold(cell, "none") {
+			in.ConfirmedNoConditions = true
+			return nil
+		}
+		in.Conditions = splitList(cell)
+	case "medications":
+		if strings.EqualFold(cell, "none") {
+			in.ConfirmedNoMedications = true
+			return nil
+		}
+		for i, entry := range splitList(cell) {
+			parts := strings.Split(entry, ":")
+			if len(parts) > 3 || strings.TrimSpace(parts[0]) == "" {
+				return fmt.Errorf("medications entry %d must be name:dose:freq", i+1)
+			}
+			parts = append(parts, "", "")
+			in.Medications = append(in.Medications, analysis.Medication{
+				Name:      strings.TrimSpace(parts[0]),
+				Dosage:    strings.TrimSpace(parts[1]),
+				Frequency: strings.TrimSpace(parts[2]),
+			})
+		}
+	case "complaint":
+		in.Complaint = cell
+	}
+	return nil
+}
+
+// splitList splits a semicolon-separated cell, dropping blank entries.
+func splitList(cell string) []string {
+	var out []string
+	for _, v := range strings.Split(cell, ";") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// normalizeHeader lower-cases h and keeps only its letters.
+func normalizeHeader(h string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, h)
+}
+
+func blank(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package csvimport
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+)
+
+func readAll(t *testing.T, data string) []Row {
+	t.Helper()
+	r, err := NewReader(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []Row
+	for {
+		row, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return rows
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, row)
+	}
+}
+
+func TestReader(t *testing.T) {
+	const data = "\ufeffComplaint,Patient Name,AGE,Weight (kg),height,BP,Conditions,Meds\n" +
+		"ED,Juan Cruz,52,80.5,175,130/85,hypertension; diabetes,sildenafil:50mg:prn;Amlodipine:5mg\n" +
+		",,,,,,,\n" +
+		"\"weight\nloss\",Ana Reyes,41,92,160,120/80,none,none\n"
+	rows := readAll(t, data)
+	want := []Row{
+		{Line: 2, Intake: analysis.Intake{
+			PatientName: "Juan Cruz", Age: 52, WeightKg: 80.5, HeightCm: 175, BP: "130/85", Complaint: "ED",
+			Conditions: []string{"hypertension", "diabetes"},
+			Medications: []analysis.Medication{
+				{Name: "sildenafil", Dosage: "50mg", Frequency: "prn"},
+				{Name: "Amlodipine", Dosage: "5mg"},
+			},
+		}},
+		{Line: 4, Intake: analysis.Intake{
+			PatientName: "Ana Reyes", Age: 41, WeightKg: 92, HeightCm: 160, BP: "120/80", Complaint: "weight\nloss",
+			ConfirmedNoConditions: true, ConfirmedNoMedications: true,
+		}},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Fatalf("rows = %+v\nwant %+v", rows, want)
+	}
+}
+
+func TestReader_RowErrors(t *testing.T) {
+	rows := readAll(t, "name,age,weight,complaint,medications\n"+
+		"A,fifty,80,ED,\n"+
+		"B,50,heavy,ED,:10mg\n"+
+		"C,50,80\n")
+	want := [][]string{
+		{"age must be a whole number"},
+		{"weight must be a number", "medications entry 1 must be name:dose:freq"},
+		{"row has 3 fields, the header has 5"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("got %d rows, want %d", len(rows), len(want))
+	}
+	for i, row := range rows {
+		if !reflect.DeepEqual(row.Errors, want[i]) {
+			t.Errorf("row %d errors = %q, want %q", i, row.Errors, want[i])
+		}
+		if strings.Contains(strings.Join(row.Errors, " "), "fifty") {
+			t.Errorf("row %d errors echo the cell: %q", i, row.Errors)
+		}
+	}
+}
+
+func TestNewReader_Header(t *testing.T) {
+	for _, tt := range []struct{ header, err string }{
+		{"", "the file is empty"},
+		{"name,age,complaint,notes", `unknown column "notes"`},
+		{"name,Age,complaint,AGE", "column age appears twice"},
+		{"name,age,,complaint", "header field 3 is blank"},
+		{"name,age,weight", "the header has no complaint column"},
+	} {
+		_, err := NewReader(strings.NewReader(tt.header))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("header %q: error %v, want %q", tt.header, err, tt.err)
+		}
+	}
+}
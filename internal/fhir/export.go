@@ -0,0 +1,251 @@
+package fhir
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+	"github.com/google/uuid"
+)
+
+// Identifier systems used on exported resources.
+const (
+	AuditIDSystem    = "urn:clinical-ai-assistant:audit-id"
+	PatientRefSystem = "urn:clinical-ai-assistant:patient-ref"
+	IssueCodeSystem  = "urn:clinical-ai-assistant:issue-code"
+
+	riskProbabilitySystem = "http://terminology.hl7.org/CodeSystem/risk-probability"
+)
+
+type Identifier struct {
+	System string `json:"system,omitempty"`
+	Value  string `json:"value,omitempty"`
+}
+
+type Annotation struct {
+	Text string `json:"text"`
+}
+
+// SubjectReference points at a patient by identifier; exports never carry the
+// patient's name.
+type SubjectReference struct {
+	Reference  string      `json:"reference,omitempty"`
+	Identifier *Identifier `json:"identifier,omitempty"`
+	Display    string      `json:"display,omitempty"`
+}
+
+type RiskPrediction struct {
+	ProbabilityDecimal *float64        `json:"probabilityDecimal,omitempty"`
+	QualitativeRisk    CodeableConcept `json:"qualitativeRisk"`
+}
+
+type RiskAssessment struct {
+	ResourceType       string             `json:"resourceType"`
+	ID                 string             `json:"id"`
+	Identifier         []Identifier       `json:"identifier,omitempty"`
+	Status             string             `json:"status"`
+	Subject            SubjectReference   `json:"subject"`
+	OccurrenceDateTime string             `json:"occurrenceDateTime,omitempty"`
+	Basis              []SubjectReference `json:"basis,omitempty"`
+	Prediction         []RiskPrediction   `json:"prediction,omitempty"`
+	Note               []Annotation       `json:"note,omitempty"`
+}
+
+type CarePlanActivity struct {
+	Reference SubjectReference `json:"reference"`
+}
+
+type CarePlan struct {
+	ResourceType string             `json:"resourceType"`
+	ID           string             `json:"id"`
+	Identifier   []Identifier       `json:"identifier,omitempty"`
+	Status       string             `json:"status"`
+	Intent       string             `json:"intent"`
+	Title        string             `json:"title,omitempty"`
+	Description  string             `json:"description,omitempty"`
+	Subject      SubjectReference   `json:"subject"`
+	Created      string             `json:"created,omitempty"`
+	Activity     []CarePlanActivity `json:"activity,omitempty"`
+	Note         []Annotation       `json:"note,omitempty"`
+}
+
+type DosageInstruction struct {
+	Text string `json:"text,omitempty"`
+}
+
+type MedicationRequest struct {
+	ResourceType              string              `json:"resourceType"`
+	ID                        string              `json:"id"`
+	Identifier                []Identifier        `json:"identifier,omitempty"`
+	Status                    string              `json:"status"`
+	Intent                    string              `json:"intent"`
+	MedicationCodeableConcept CodeableConcept     `json:"medicationCodeableConcept"`
+	Subject                   SubjectReference    `json:"subject"`
+	AuthoredOn                string              `json:"authoredOn,omitempty"`
+	DosageInstruction         []DosageInstruction `json:"dosageInstruction,omitempty"`
+	Note                      []Annotation        `json:"note,omitempty"`
+}
+
+// qualitativeRisk maps risk levels to the HL7 risk-probability code system,
+// which has no tier above "high".
+var qualitativeRisk = map[analysis.RiskLevel]string{
+	analysis.RiskLow:      "low",
+	analysis.RiskMedium:   "moderate",
+	analysis.RiskHigh:     "high",
+	analysis.RiskCritical: "high",
+}
+
+// ToBundle renders resp as a collection Bundle holding a RiskAssessment, a
+// draft CarePlan, and a MedicationRequest for the recommended plan (intent
+// proposal) and each alternative (intent option). Every resource carries the
+// audit ID as an identifier and refers to the patient by patientRef.
+func ToBundle(resp analysis.Response, patientRef string) Bundle {
+	x := exporter{resp: resp, subject: SubjectReference{Display: patientRef}}
+	if patientRef != "" {
+		x.subject.Identifier = &Identifier{System: PatientRefSystem, Value: patientRef}
+	}
+	if resp.AuditID != "" {
+		x.ids = []Identifier{{System: AuditIDSystem, Value: resp.AuditID}}
+	}
+
+	b := Bundle{ResourceType: "Bundle", Type: "collection"}
+	b.Entry = append(b.Entry, x.entry("risk", x.riskAssessment()))
+
+	var activities []CarePlanActivity
+	var requests []Entry
+	addRequest := func(name, intent, medication, dosage string, notes []string) {
+		e := x.entry(name, x.medicationRequest(name, intent, medication, dosage, notes))
+		requests = append(requests, e)
+		activities = append(activities, CarePlanActivity{Reference: SubjectReference{Reference: e.FullURL, Display: medication}})
+	}
+	p := resp.RecommendedPlan
+	addRequest("plan", "proposal", p.Medication, joinNonEmpty(" ", p.Dosage, p.Frequency, p.Duration), nonEmpty(p.Rationale))
+	for i, alt := range resp.Alternatives {
+		var notes []string
+		for _, pro := range alt.Pros {
+			notes = append(notes, "Pro: "+pro)
+		}
+		for _, con := range alt.Cons {
+			notes = append(notes, "Con: "+con)
+		}
+		addRequest(fmt.Sprintf("alternative-%d", i), "option", alt.Medication, alt.Dosage, notes)
+	}
+
+	b.Entry = append(b.Entry, x.entry("careplan", CarePlan{
+		ResourceType: "CarePlan",
+		ID:           x.id("careplan"),
+		Identifier:   x.ids,
+		// Recommendations stay draft proposals until a clinician approves them.
+		Status:      "draft",
+		Intent:      "proposal",
+		Title:       "Recommended treatment",
+		Description: p.Rationale,
+		Subject:     x.subject,
+		Created:     resp.AuditAt,
+		Activity:    activities,
+		Note:        x.disclaimers(),
+	}))
+	b.Entry = append(b.Entry, requests...)
+	return b
+}
+
+type exporter struct {
+	resp    analysis.Response
+	subject SubjectReference
+	ids     []Identifier
+}
+
+// id derives a stable resource ID from the audit ID so re-exporting the same
+// analysis yields the same Bundle.
+func (x exporter) id(name string) string {
+	if x.resp.AuditID == "" {
+		return uuid.NewString()
+	}
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(AuditIDSystem+":"+x.resp.AuditID+"/"+name)).String()
+}
+
+func (x exporter) entry(name string, resource any) Entry {
+	raw, err := json.Marshal(resource)
+	if err != nil {
+		// The resource types above always marshal.
+		panic(fmt.Sprintf("fhir: marshal %s: %v", name, err))
+	}
+	var h resourceHeader
+	_ = json.Unmarshal(raw, &h)
+	return Entry{FullURL: "urn:uuid:" + h.ID, Resource: raw}
+}
+
+func (x exporter) riskAssessment() RiskAssessment {
+	resp := x.resp
+	pred := RiskPrediction{QualitativeRisk: CodeableConcept{Text: string(resp.RiskLevel)}}
+	if code, ok := qualitativeRisk[resp.RiskLevel]; ok {
+		pred.QualitativeRisk.Coding = []Coding{{System: riskProbabilitySystem, Code: code}}
+	}
+	if resp.PlanConfidence > 0 {
+		c := resp.PlanConfidence
+		pred.ProbabilityDecimal = &c
+	}
+	ra := RiskAssessment{
+		ResourceType:       "RiskAssessment",
+		ID:                 x.id("risk"),
+		Identifier:         x.ids,
+		Status:             "final",
+		Subject:            x.subject,
+		OccurrenceDateTime: resp.AuditAt,
+		Prediction:         []RiskPrediction{pred},
+		Note:               []Annotation{{Text: fmt.Sprintf("Risk score %d (normalized %d)", resp.RiskScore, resp.RiskScoreNormalized)}},
+	}
+	ra.Note = append(ra.Note, x.disclaimers()...)
+	for _, is := range resp.FlaggedIssues {
+		ra.Basis = append(ra.Basis, SubjectReference{
+			Identifier: &Identifier{System: IssueCodeSystem, Value: is.Code},
+			Display:    fmt.Sprintf("[%s] %s", is.Severity, is.Description),
+		})
+	}
+	return ra
+}
+
+// disclaimers carries the response's disclaimers as notes, so they travel
+// with the exported recommendation.
+func (x exporter) disclaimers() []Annotation {
+	var out []Annotation
+	for _, d := range x.resp.Disclaimers {
+		out = append(out, Annotation{Text: d})
+	}
+	return out
+}
+
+func (x exporter) medicationRequest(name, intent, medication, dosage string, notes []string) MedicationRequest {
+	mr := MedicationRequest{
+		ResourceType:              "MedicationRequest",
+		ID:                        x.id(name),
+		Identifier:                x.ids,
+		Status:                    "draft",
+		Intent:                    intent,
+		MedicationCodeableConcept: CodeableConcept{Text: medication},
+		Subject:                   x.subject,
+		AuthoredOn:                x.resp.AuditAt,
+	}
+	if dosage != "" {
+		mr.DosageInstruction = []DosageInstruction{{Text: dosage}}
+	}
+	for _, n := range notes {
+		mr.Note = append(mr.Note, Annotation{Text: n})
+	}
+	return mr
+}
+
+func joinNonEmpty(sep string, parts ...string) string {
+	return strings.Join(nonEmpty(parts...), sep)
+}
+
+func nonEmpty(parts ...string) []string {
+	var out []string
+	for _, p := range parts {
+		if strings.TrimSpace(p) != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,115 @@
+package fhir
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+func loadSchema(t *testing.T) *gojsonschema.Schema {
+	t.Helper()
+	path, err := filepath.Abs(filepath.Join("testdata", "schema", "fhir-r4-subset.schema.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewReferenceLoader("file://" + filepath.ToSlash(path)))
+	if err != nil {
+		t.Fatalf("compile schema: %v", err)
+	}
+	return schema
+}
+
+func validateJSON(t *testing.T, schema *gojsonschema.Schema, doc []byte) []gojsonschema.ResultError {
+	t.Helper()
+	res, err := schema.Validate(gojsonschema.NewBytesLoader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.Errors()
+}
+
+func sampleResponse(t *testing.T) analysis.Response {
+	t.Helper()
+	a := analysis.New(
+		analysis.WithClock(func() time.Time { return goldenNow }),
+		analysis.WithIDGenerator(audit.IDGeneratorFunc(func() string { return "audit-export" })),
+	)
+	resp := a.Analyze(analysis.Intake{
+		PatientName: "Juan Dela Cruz", Age: 58, WeightKg: 92, HeightCm: 172, BP: "150/95",
+		Conditions:  []string{"Hypertension"},
+		Medications: []analysis.Medication{{Name: "Tamsulosin", Dosage: "0.4mg", Frequency: "nightly"}},
+		Complaint:   "ED",
+	})
+	if len(resp.ValidationErrors) > 0 || len(resp.FlaggedIssues) == 0 || len(resp.Alternatives) == 0 {
+		t.Fatalf("sample response unsuitable: %+v", resp)
+	}
+	return resp
+}
+
+func TestToBundle_ProducesValidResources(t *testing.T) {
+	schema := loadSchema(t)
+	resp := sampleResponse(t)
+	b := ToBundle(resp, "p_0123456789abcdef")
+	doc, err := json.Marshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range validateJSON(t, schema, doc) {
+		t.Errorf("schema: %s", e)
+	}
+
+	if want := 2 + 1 + len(resp.Alternatives); len(b.Entry) != want {
+		t.Fatalf("entries = %d, want %d", len(b.Entry), want)
+	}
+	var ra RiskAssessment
+	if err := json.Unmarshal(b.Entry[0].Resource, &ra); err != nil {
+		t.Fatal(err)
+	}
+	if ra.ResourceType != "RiskAssessment" || len(ra.Identifier) != 1 || ra.Identifier[0] != (Identifier{System: AuditIDSystem, Value: "audit-export"}) {
+		t.Fatalf("risk assessment identity: %+v", ra)
+	}
+	pred := ra.Prediction[0]
+	if pred.ProbabilityDecimal == nil || *pred.ProbabilityDecimal != resp.PlanConfidence || pred.QualitativeRisk.Text != string(resp.RiskLevel) {
+		t.Fatalf("prediction = %+v, want confidence %v and risk %s", pred, resp.PlanConfidence, resp.RiskLevel)
+	}
+	if len(ra.Basis) != len(resp.FlaggedIssues) || ra.Basis[0].Identifier.Value != resp.FlaggedIssues[0].Code {
+		t.Fatalf("basis = %+v, want one per flagged issue", ra.Basis)
+	}
+
+	var plan CarePlan
+	if err := json.Unmarshal(b.Entry[1].Resource, &plan); err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Activity) != 1+len(resp.Alternatives) || plan.Activity[0].Reference.Reference != b.Entry[2].FullURL {
+		t.Fatalf("care plan activities = %+v", plan.Activity)
+	}
+	if len(resp.Disclaimers) == 0 || len(plan.Note) != len(resp.Disclaimers) || plan.Note[0].Text != resp.Disclaimers[0] || ra.Note[len(ra.Note)-1].Text != resp.Disclaimers[len(resp.Disclaimers)-1] {
+		t.Fatalf("disclaimer notes: care plan %+v, risk assessment %+v", plan.Note, ra.Note)
+	}
+	var mr MedicationRequest
+	if err := json.Unmarshal(b.Entry[2].Resource, &mr); err != nil {
+		t.Fatal(err)
+	}
+	if mr.Intent != "proposal" || mr.MedicationCodeableConcept.Text != resp.RecommendedPlan.Medication {
+		t.Fatalf("plan request = %+v", mr)
+	}
+
+	if again := ToBundle(resp, "p_0123456789abcdef"); !reflect.DeepEqual(again, b) {
+		t.Fatal("exporting the same response twice gave different bundles")
+	}
+}
+
+func TestSchema_RejectsInvalidResources(t *testing.T) {
+	schema := loadSchema(t)
+	bad := `{"resourceType":"Bundle","type":"collection","entry":[{"resource":
+		{"resourceType":"RiskAssessment","id":"r1","status":"done","subject":{},"prediction":[{"probabilityDecimal":1.5}]}}]}`
+	if errs := validateJSON(t, schema, []byte(bad)); len(errs) == 0 {
+		t.Fatal("schema accepted an invalid RiskAssessment")
+	}
+}
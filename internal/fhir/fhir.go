@@ -0,0 +1,137 @@
+// Package fhir maps FHIR R4 resources to and from the analysis types. Only the
+// fields the rules engine uses are modelled; everything else is ignored.
+package fhir
+
+import "encoding/json"
+
+// Bundle is a FHIR Bundle whose entries are decoded lazily by resourceType.
+type Bundle struct {
+	ResourceType string  `json:"resourceType"`
+	Type         string  `json:"type,omitempty"`
+	Entry        []Entry `json:"entry,omitempty"`
+}
+
+// Entry is one Bundle entry.
+type Entry struct {
+	FullURL  string          `json:"fullUrl,omitempty"`
+	Resource json.RawMessage `json:"resource"`
+}
+
+// resourceHeader is decoded first to dispatch on resourceType.
+type resourceHeader struct {
+	ResourceType string `json:"resourceType"`
+	ID           string `json:"id"`
+}
+
+type Coding struct {
+	System  string `json:"system,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Display string `json:"display,omitempty"`
+}
+
+type CodeableConcept struct {
+	Coding []Coding `json:"coding,omitempty"`
+	Text   string   `json:"text,omitempty"`
+}
+
+// label prefers the concept's text, then the first coding display.
+func (c *CodeableConcept) label() string {
+	if c == nil {
+		return ""
+	}
+	if c.Text != "" {
+		return c.Text
+	}
+	for _, cd := range c.Coding {
+		if cd.Display != "" {
+			return cd.Display
+		}
+	}
+	return ""
+}
+
+// hasCode reports whether any coding matches system and code.
+func (c *CodeableConcept) hasCode(system, code string) bool {
+	if c == nil {
+		return false
+	}
+	for _, cd := range c.Coding {
+		if cd.System == system && cd.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+type Quantity struct {
+	Value *float64 `json:"value,omitempty"`
+	Unit  string   `json:"unit,omitempty"`
+	Code  string   `json:"code,omitempty"`
+}
+
+type Reference struct {
+	Reference string `json:"reference,omitempty"`
+	Display   string `json:"display,omitempty"`
+}
+
+type HumanName struct {
+	Text   string   `json:"text,omitempty"`
+	Family string   `json:"family,omitempty"`
+	Given  []string `json:"given,omitempty"`
+}
+
+type Patient struct {
+	ID        string      `json:"id"`
+	Name      []HumanName `json:"name,omitempty"`
+	BirthDate string      `json:"birthDate,omitempty"`
+}
+
+type ObservationComponent struct {
+	Code          CodeableConcept `json:"code"`
+	ValueQuantity *Quantity       `json:"valueQuantity,omitempty"`
+}
+
+type Observation struct {
+	ID            string                 `json:"id"`
+	Code          CodeableConcept        `json:"code"`
+	ValueQuantity *Quantity              `json:"valueQuantity,omitempty"`
+	Component     []ObservationComponent `json:"component,omitempty"`
+}
+
+type Condition struct {
+	ID   string           `json:"id"`
+	Code *CodeableConcept `json:"code,omitempty"`
+}
+
+type DoseAndRate struct {
+	DoseQuantity *Quantity `json:"doseQuantity,omitempty"`
+}
+
+type Timing struct {
+	Code *CodeableConcept `json:"code,omitempty"`
+}
+
+type Dosage struct {
+	Text        string        `json:"text,omitempty"`
+	Timing      *Timing       `json:"timing,omitempty"`
+	DoseAndRate []DoseAndRate `json:"doseAndRate,omitempty"`
+}
+
+type MedicationStatement struct {
+	ID                        string           `json:"id"`
+	MedicationCodeableConcept *CodeableConcept `json:"medicationCodeableConcept,omitempty"`
+	MedicationReference       *Reference       `json:"medicationReference,omitempty"`
+	Dosage                    []Dosage         `json:"dosage,omitempty"`
+}
+
+type AllergyIntolerance struct {
+	ID   string           `json:"id"`
+	Code *CodeableConcept `json:"code,omitempty"`
+}
+
+// Consent is the subset of the R4 Consent resource the mapper reads.
+type Consent struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"`
+	DateTime string `json:"dateTime,omitempty"`
+}
@@ -0,0 +1,334 @@
+package fhir
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+)
+
+const loinc = "http://loinc.org"
+
+// ICD-10 code systems whose Condition codings are passed on as
+// Intake.ConditionCodes.
+var icd10Systems = map[string]bool{
+	"http://hl7.org/fhir/sid/icd-10":    true,
+	"http://hl7.org/fhir/sid/icd-10-cm": true,
+}
+
+// LOINC codes read from Observations.
+const (
+	loincBPPanel   = "85354-9"
+	loincSystolic  = "8480-6"
+	loincDiastolic = "8462-4"
+	loincWeight    = "29463-7"
+	loincHeight    = "8302-2"
+)
+
+// MappingError describes a resource that could not be mapped to the intake.
+// ResourceID is empty for problems with the Bundle as a whole.
+type MappingError struct {
+	ResourceType string `json:"resourceType"`
+	ResourceID   string `json:"resourceId,omitempty"`
+	Field        string `json:"field,omitempty"`
+	Message      string `json:"message"`
+}
+
+func (e MappingError) Error() string {
+	ref := e.ResourceType
+	if e.ResourceID != "" {
+		ref += "/" + e.ResourceID
+	}
+	if e.Field != "" {
+		ref += "." + e.Field
+	}
+	return ref + ": " + e.Message
+}
+
+// Messages flattens errs for the errors list of a validation-failed problem.
+func Messages(errs []MappingError) []string {
+	out := make([]string, 0, len(errs))
+	for _, e := range errs {
+		out = append(out, e.Error())
+	}
+	return out
+}
+
+// ParseBundle decodes a FHIR Bundle, rejecting other resource types.
+func ParseBundle(data []byte) (Bundle, error) {
+	var b Bundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return Bundle{}, fmt.Errorf("fhir: invalid bundle JSON: %w", err)
+	}
+	if b.ResourceType != "Bundle" {
+		return Bundle{}, fmt.Errorf("fhir: resourceType is %q, want Bundle", b.ResourceType)
+	}
+	return b, nil
+}
+
+// ToIntake maps a Bundle's Patient, Observation (blood pressure, weight,
+// height), Condition, MedicationStatement, and AllergyIntolerance resources to
+// an Intake. Age is computed from birthDate as of now. Resource types the
+// engine does not use are ignored. The complaint is not part of the Bundle and
+// is left for the caller to set.
+func ToIntake(b Bundle, now time.Time) (analysis.Intake, []MappingError) {
+	m := mapper{now: now, seen: map[string]bool{}}
+	for i, e := range b.Entry {
+		m.entry(i, e)
+	}
+	m.require()
+	return m.in, m.errs
+}
+
+type mapper struct {
+	now      time.Time
+	in       analysis.Intake
+	errs     []MappingError
+	patients int
+	// seen records Observation kinds present, valid or not, so a bad resource
+	// is not reported a second time as missing.
+	seen map[string]bool
+}
+
+func (m *mapper) fail(resourceType, id, field, format string, args ...any) {
+	m.errs = append(m.errs, MappingError{ResourceType: resourceType, ResourceID: id, Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (m *mapper) entry(i int, e Entry) {
+	var h resourceHeader
+	if err := json.Unmarshal(e.Resource, &h); err != nil || h.ResourceType == "" {
+		m.fail("Bundle", "", fmt.Sprintf("entry[%d]", i), "entry has no resource")
+		return
+	}
+	decode := func(v any) bool {
+		if err := json.Unmarshal(e.Resource, v); err != nil {
+			m.fail(h.ResourceType, h.ID, "", "malformed resource: %v", err)
+			return false
+		}
+		return true
+	}
+	switch h.ResourceType {
+	case "Patient":
+		var p Patient
+		if decode(&p) {
+			m.patient(p)
+		}
+	case "Observation":
+		var o Observation
+		if decode(&o) {
+			m.observation(o)
+		}
+	case "Condition":
+		var c Condition
+		if decode(&c) {
+			m.condition(c)
+		}
+	case "MedicationStatement":
+		var ms MedicationStatement
+		if decode(&ms) {
+			m.medication(ms)
+		}
+	case "Consent":
+		var c Consent
+		if decode(&c) {
+			m.consent(c)
+		}
+	case "AllergyIntolerance":
+		var a AllergyIntolerance
+		if decode(&a) {
+			if name := a.Code.label(); name != "" {
+				m.in.Allergies = append(m.in.Allergies, name)
+			} else {
+				m.fail("AllergyIntolerance", a.ID, "code", "no display text")
+			}
+		}
+	}
+}
+
+// consent maps an active Consent to given consent and any other status to
+// consent not given. The resource does not say how consent was obtained, so
+// the method is recorded as "fhir".
+func (m *mapper) consent(c Consent) {
+	m.in.Consent = &analysis.Consent{Given: c.Status == "active", Timestamp: c.DateTime, Method: "fhir"}
+}
+
+func (m *mapper) patient(p Patient) {
+	m.patients++
+	if m.patients > 1 {
+		m.fail("Patient", p.ID, "", "bundle must contain exactly one Patient")
+		return
+	}
+	m.in.PatientName = patientName(p.Name)
+	if m.in.PatientName == "" {
+		m.fail("Patient", p.ID, "name", "missing")
+	}
+	if p.BirthDate == "" {
+		m.fail("Patient", p.ID, "birthDate", "missing")
+		return
+	}
+	born, err := parseDate(p.BirthDate)
+	if err != nil {
+		m.fail("Patient", p.ID, "birthDate", "invalid date %q", p.BirthDate)
+		return
+	}
+	m.in.Age = ageAt(born, m.now)
+	if m.in.Age <= 0 {
+		m.fail("Patient", p.ID, "birthDate", "%s gives age %d", p.BirthDate, m.in.Age)
+	}
+}
+
+func patientName(names []HumanName) string {
+	for _, n := range names {
+		if n.Text != "" {
+			return n.Text
+		}
+		if full := strings.TrimSpace(strings.Join(append(append([]string{}, n.Given...), n.Family), " ")); full != "" {
+			return full
+		}
+	}
+	return ""
+}
+
+func (m *mapper) observation(o Observation) {
+	switch {
+	case o.Code.hasCode(loinc, loincBPPanel):
+		m.seen[loincBPPanel] = true
+		var sys, dia *float64
+		for _, c := range o.Component {
+			if c.ValueQuantity == nil {
+				continue
+			}
+			switch {
+			case c.Code.hasCode(loinc, loincSystolic):
+				sys = c.ValueQuantity.Value
+			case c.Code.hasCode(loinc, loincDiastolic):
+				dia = c.ValueQuantity.Value
+			}
+		}
+		if sys == nil || dia == nil {
+			m.fail("Observation", o.ID, "component", "blood pressure needs systolic (%s) and diastolic (%s) values", loincSystolic, loincDiastolic)
+			return
+		}
+		m.in.BP = fmt.Sprintf("%s/%s", formatNumber(*sys), formatNumber(*dia))
+	case o.Code.hasCode(loinc, loincWeight):
+		m.seen[loincWeight] = true
+		if v, ok := m.quantity(o, map[string]float64{"kg": 1, "g": 0.001, "[lb_av]": 0.45359237, "lb": 0.45359237}); ok {
+			m.in.WeightKg = v
+		}
+	case o.Code.hasCode(loinc, loincHeight):
+		m.seen[loincHeight] = true
+		if v, ok := m.quantity(o, map[string]float64{"cm": 1, "m": 100, "[in_i]": 2.54, "in": 2.54}); ok {
+			m.in.HeightCm = v
+		}
+	}
+}
+
+// quantity converts an Observation's valueQuantity using factors keyed by UCUM
+// code (or unit when no code is given), rounded to one decimal place.
+func (m *mapper) quantity(o Observation, factors map[string]float64) (float64, bool) {
+	q := o.ValueQuantity
+	if q == nil || q.Value == nil {
+		m.fail("Observation", o.ID, "valueQuantity", "missing value")
+		return 0, false
+	}
+	unit := q.Code
+	if unit == "" {
+		unit = q.Unit
+	}
+	f, ok := factors[unit]
+	if !ok {
+		m.fail("Observation", o.ID, "valueQuantity", "unsupported unit %q", unit)
+		return 0, false
+	}
+	return math.Round(*q.Value*f*10) / 10, true
+}
+
+// condition maps an ICD-10 coding to a condition code, which the engine maps
+// more reliably than display text, and anything else to its label. A code no
+// ICD-10 category covers keeps its label as well, so the engine can still
+// recognize the condition by name.
+func (m *mapper) condition(c Condition) {
+	if c.Code != nil {
+		for _, cd := range c.Code.Coding {
+			if icd10Systems[cd.System] && cd.Code != "" {
+				m.in.ConditionCodes = append(m.in.ConditionCodes, cd.Code)
+				if name := c.Code.label(); name != "" && analysis.ICD10Condition(cd.Code) == "" {
+					m.in.Conditions = append(m.in.Conditions, name)
+				}
+				return
+			}
+		}
+	}
+	if name := c.Code.label(); name != "" {
+		m.in.Conditions = append(m.in.Conditions, name)
+		return
+	}
+	m.fail("Condition", c.ID, "code", "no display text")
+}
+
+func (m *mapper) medication(ms MedicationStatement) {
+	name := ms.MedicationCodeableConcept.label()
+	if name == "" && ms.MedicationReference != nil {
+		name = ms.MedicationReference.Display
+	}
+	if name == "" {
+		m.fail("MedicationStatement", ms.ID, "medication", "no drug name")
+		return
+	}
+	med := analysis.Medication{Name: name}
+	for _, d := range ms.Dosage {
+		for _, dr := range d.DoseAndRate {
+			if q := dr.DoseQuantity; q != nil && q.Value != nil && med.Dosage == "" {
+				med.Dosage = formatNumber(*q.Value) + q.Unit
+			}
+		}
+		if med.Frequency == "" && d.Timing != nil {
+			med.Frequency = d.Timing.Code.label()
+		}
+		if med.Frequency == "" {
+			med.Frequency = d.Text
+		}
+	}
+	m.in.Medications = append(m.in.Medications, med)
+}
+
+func (m *mapper) require() {
+	if m.patients == 0 {
+		m.fail("Bundle", "", "", "no Patient resource")
+	}
+	if !m.seen[loincBPPanel] {
+		m.fail("Bundle", "", "", "no blood pressure Observation (LOINC %s)", loincBPPanel)
+	}
+	if !m.seen[loincWeight] {
+		m.fail("Bundle", "", "", "no body weight Observation (LOINC %s)", loincWeight)
+	}
+	if !m.seen[loincHeight] {
+		m.fail("Bundle", "", "", "no body height Observation (LOINC %s)", loincHeight)
+	}
+}
+
+// parseDate accepts the FHIR date precisions YYYY, YYYY-MM, and YYYY-MM-DD.
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", "2006-01", "2006"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+func ageAt(born, now time.Time) int {
+	age := now.Year() - born.Year()
+	if now.Month() < born.Month() || (now.Month() == born.Month() && now.Day() < born.Day()) {
+		age--
+	}
+	return age
+}
+
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
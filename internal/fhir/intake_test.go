@@ -0,0 +1,104 @@
+package fhir
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+// goldenNow fixes the reference date used for ages in golden files.
+var goldenNow = time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+type mapped struct {
+	Intake analysis.Intake `json:"intake"`
+	Errors []MappingError  `json:"errors"`
+}
+
+func TestToIntake_Golden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "*.bundle.json"))
+	if err != nil || len(inputs) == 0 {
+		t.Fatalf("no sample bundles: %v", err)
+	}
+	for _, path := range inputs {
+		name := strings.TrimSuffix(filepath.Base(path), ".bundle.json")
+		t.Run(name, func(t *testing.T) {
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := ParseBundle(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			in, errs := ToIntake(b, goldenNow)
+			got, err := json.MarshalIndent(mapped{Intake: in, Errors: errs}, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			golden := filepath.Join("testdata", name+".golden.json")
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("read golden (run with -update to create): %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("mapping differs from %s:\n%s", golden, got)
+			}
+		})
+	}
+}
+
+func TestParseBundle_RejectsOtherResources(t *testing.T) {
+	if _, err := ParseBundle([]byte(`{"resourceType":"Patient","id":"p"}`)); err == nil {
+		t.Fatal("expected error for non-Bundle resource")
+	}
+}
+
+func TestAgeAt(t *testing.T) {
+	born := time.Date(1980, 6, 2, 0, 0, 0, 0, time.UTC)
+	if got := ageAt(born, goldenNow); got != 44 {
+		t.Fatalf("day before birthday: age %d, want 44", got)
+	}
+	if got := ageAt(born, goldenNow.AddDate(0, 0, 1)); got != 45 {
+		t.Fatalf("on birthday: age %d, want 45", got)
+	}
+}
+
+func TestToIntake_UnmappedCodeKeepsLabel(t *testing.T) {
+	raw, err := os.ReadFile(filepath.Join("testdata", "unmapped-code.bundle.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ParseBundle(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in, errs := ToIntake(b, goldenNow)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	// I42.9 is outside the ICD-10 categories, so only its display text tells
+	// the engine it is heart disease.
+	in.ConditionCodes = slices.DeleteFunc(in.ConditionCodes, func(c string) bool { return c != "I42.9" })
+	in.Complaint = "ED"
+	resp := analysis.New().Analyze(in)
+	if !slices.ContainsFunc(resp.FlaggedIssues, func(is analysis.Issue) bool { return is.Code == "COND_HEART_DISEASE" }) {
+		t.Fatalf("unmapped coded cardiomyopathy not read as heart disease: %+v", resp.FlaggedIssues)
+	}
+}
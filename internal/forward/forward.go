@@ -0,0 +1,182 @@
+// Package forward ships audit records to an external log, syslog or an HTTPS
+// collector, from a bounded queue off the request path.
+package forward
+
+import (
+	"context"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/metrics"
+)
+
+var (
+	forwarded = metrics.NewCounter("audit_forward_total", "Audit records forwarded by result (sent, retry).", "result")
+	dropped   = metrics.NewCounter("audit_forward_dropped_total", "Audit records dropped by reason (queue_full, failed, shutdown).", "reason")
+	// queued counts the records waiting across every Forwarder.
+	queued atomic.Int64
+	_      = metrics.NewGaugeFunc("audit_forward_queue_depth", "Audit records waiting to be forwarded.", func() float64 {
+		return float64(queued.Load())
+	})
+)
+
+const (
+	DefaultQueueSize = 1000
+	DefaultAttempts  = 5
+	// DefaultBackoff is the delay before the first retry; it doubles per
+	// attempt up to maxBackoff.
+	DefaultBackoff = time.Second
+	DefaultTimeout = 10 * time.Second
+
+	maxBackoff = 30 * time.Second
+)
+
+// Sink delivers one audit record, e.g. to syslog.
+type Sink interface {
+	Send(ctx context.Context, rec analysis.ForwardedAudit) error
+}
+
+// Config tunes a Forwarder; zero fields take the defaults above. Attempts
+// bounds the deliveries tried per record and Timeout each delivery.
+type Config struct {
+	QueueSize int
+	Attempts  int
+	Backoff   time.Duration
+	Timeout   time.Duration
+}
+
+// Forwarder implements analysis.AuditForwarder by queueing records for a
+// Sink. One worker delivers them in order; a record is dropped, and counted
+// in audit_forward_dropped_total, when the queue is full or every attempt
+// failed.
+type Forwarder struct {
+	sink Sink
+	cfg  Config
+
+	// mu guards closed against Forward sending on the closed queue.
+	mu     sync.RWMutex
+	closed bool
+	queue  chan analysis.ForwardedAudit
+
+	// ctx is cancelled when Close gives up waiting for the queue to drain.
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New starts a Forwarder delivering to sink. Close it to flush on shutdown.
+func New(sink Sink, cfg Config) *Forwarder {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultQueueSize
+	}
+	if cfg.Attempts <= 0 {
+		cfg.Attempts = DefaultAttempts
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = DefaultBackoff
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	f := &Forwarder{
+		sink:   sink,
+		cfg:    cfg,
+		queue:  make(chan analysis.ForwardedAudit, cfg.QueueSize),
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go f.run()
+	return f
+}
+
+// Forward queues rec without blocking, dropping it when the queue is full or
+// the Forwarder is closed.
+func (f *Forwarder) Forward(rec analysis.ForwardedAudit) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.closed {
+		dropped.Inc("shutdown")
+		return
+	}
+	select {
+	case f.queue <- rec:
+		queued.Add(1)
+	default:
+		dropped.Inc("queue_full")
+	}
+}
+
+// Close stops accepting records and waits for the queued ones to be
+// delivered, then closes the sink if it is an io.Closer. When ctx is done
+// first it abandons the rest, counting them as dropped, and returns ctx's
+// error.
+func (f *Forwarder) Close(ctx context.Context) error {
+	f.mu.Lock()
+	if !f.closed {
+		f.closed = true
+		close(f.queue)
+	}
+	f.mu.Unlock()
+
+	var err error
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		f.cancel()
+		<-f.done
+		err = ctx.Err()
+	}
+	if c, ok := f.sink.(io.Closer); ok {
+		c.Close()
+	}
+	return err
+}
+
+func (f *Forwarder) run() {
+	defer close(f.done)
+	defer f.cancel()
+	for rec := range f.queue {
+		queued.Add(-1)
+		f.deliver(rec)
+	}
+}
+
+// deliver sends rec, retrying with backoff until it is sent, every attempt
+// has failed, or Close gives up.
+func (f *Forwarder) deliver(rec analysis.ForwardedAudit) {
+	backoff := f.cfg.Backoff
+	for attempt := 1; ; attempt++ {
+		if f.ctx.Err() != nil {
+			dropped.Inc("shutdown")
+			return
+		}
+		ctx, cancel := context.WithTimeout(f.ctx, f.cfg.Timeout)
+		err := f.sink.Send(ctx, rec)
+		cancel()
+		if err == nil {
+			forwarded.Inc("sent")
+			return
+		}
+		if f.ctx.Err() != nil {
+			dropped.Inc("shutdown")
+			return
+		}
+		if attempt == f.cfg.Attempts {
+			dropped.Inc("failed")
+			log.Printf("audit forward failed after %d attempts audit_id=%s sink=%T: %v", attempt, rec.AuditID, f.sink, err)
+			return
+		}
+		forwarded.Inc("retry")
+		select {
+		case <-f.ctx.Done():
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
@@ -0,0 +1,245 @@
+package forward
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+)
+
+// flakySink fails the first failures sends, then records the rest; block,
+// when set, holds every send until it is closed or ctx is done.
+type flakySink struct {
+	mu       sync.Mutex
+	failures int
+	block    chan struct{}
+	sent     []string
+}
+
+func (s *flakySink) Send(ctx context.Context, rec analysis.ForwardedAudit) error {
+	if s.block != nil {
+		select {
+		case <-s.block:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("collector down")
+	}
+	s.sent = append(s.sent, rec.AuditID)
+	return nil
+}
+
+func record(id string) analysis.ForwardedAudit {
+	return analysis.ForwardedAudit{AuditSummary: analysis.AuditSummary{
+		AuditID: id, PatientRef: "p_1", RiskLevel: analysis.RiskCritical, RiskScore: 9, At: "2026-03-01T09:00:00Z",
+	}, Org: "clinic-a"}
+}
+
+func TestForwarder_RetriesAndFlushes(t *testing.T) {
+	sink := &flakySink{failures: 2}
+	f := New(sink, Config{Backoff: time.Millisecond})
+	sent, retried := forwarded.Value("sent"), forwarded.Value("retry")
+	for _, id := range []string{"a1", "a2", "a3"} {
+		f.Forward(record(id))
+	}
+	if err := f.Close(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(sink.sent, ",") != "a1,a2,a3" {
+		t.Fatalf("sent = %v", sink.sent)
+	}
+	if got := forwarded.Value("sent") - sent; got != 3 {
+		t.Errorf("sent counter +%v, want 3", got)
+	}
+	if got := forwarded.Value("retry") - retried; got != 2 {
+		t.Errorf("retry counter +%v, want 2", got)
+	}
+
+	shutdown := dropped.Value("shutdown")
+	f.Forward(record("late"))
+	if got := dropped.Value("shutdown") - shutdown; got != 1 || len(sink.sent) != 3 {
+		t.Fatalf("record after Close: dropped +%v, sent %v", got, sink.sent)
+	}
+}
+
+func TestForwarder_Drops(t *testing.T) {
+	sink := &flakySink{block: make(chan struct{})}
+	f := New(sink, Config{QueueSize: 1, Attempts: 1})
+	full, shutdown := dropped.Value("queue_full"), dropped.Value("shutdown")
+
+	// The worker takes a1 and blocks on it, a2 fills the queue, and a3 and
+	// a4 find it full; Forward returns at once throughout.
+	f.Forward(record("a1"))
+	deadline := time.Now().Add(5 * time.Second)
+	for queued.Load() != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	for _, id := range []string{"a2", "a3", "a4"} {
+		f.Forward(record(id))
+	}
+	if got := dropped.Value("queue_full") - full; got != 2 {
+		t.Fatalf("queue_full +%v, want 2", got)
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+	defer cancel()
+	if err := f.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Close = %v, want the deadline", err)
+	}
+	if got := dropped.Value("shutdown") - shutdown; got != 2 || len(sink.sent) != 0 {
+		t.Fatalf("abandoned on shutdown +%v (sent %v), want 2", got, sink.sent)
+	}
+}
+
+func TestForwarder_GivesUp(t *testing.T) {
+	sink := &flakySink{failures: 5}
+	f := New(sink, Config{Attempts: 2, Backoff: time.Millisecond})
+	failed := dropped.Value("failed")
+	f.Forward(record("a1"))
+	f.Forward(record("a2"))
+	if err := f.Close(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if got := dropped.Value("failed") - failed; got != 2 || len(sink.sent) != 0 {
+		t.Fatalf("failed +%v (sent %v), want 2", got, sink.sent)
+	}
+}
+
+func TestSyslog_TCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	msgs := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			n, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+			size, _ := strconv.Atoi(strings.TrimSpace(n))
+			buf := make([]byte, size)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return
+			}
+			msgs <- string(buf)
+		}
+	}()
+
+	s, err := NewSyslog(SyslogConfig{Addr: ln.Addr().String(), Hostname: "clinic host"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	rec := record("a1")
+	rec.Org = `clinic "a"`
+	for range 2 {
+		if err := s.Send(t.Context(), rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for range 2 {
+		msg := <-msgs
+		// local0.warning for a CRITICAL analysis.
+		prefix := `<132>1 2026-03-01T09:00:00Z clinichost clinical-ai `
+		sd := ` audit [audit@32473 auditId="a1" riskLevel="CRITICAL" riskScore="9" org="clinic \"a\""] `
+		if !strings.HasPrefix(msg, prefix) || !strings.Contains(msg, sd) {
+			t.Fatalf("message = %q", msg)
+		}
+		var got analysis.ForwardedAudit
+		if err := json.Unmarshal([]byte(msg[strings.Index(msg, "] ")+2:]), &got); err != nil || got.AuditID != "a1" || got.PatientRef != "p_1" {
+			t.Fatalf("body = %+v (err %v)", got, err)
+		}
+	}
+}
+
+func TestSyslog_UDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	s, err := NewSyslog(SyslogConfig{Network: "UDP", Addr: pc.LocalAddr().String(), AppName: "cds"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	rec := record("a1")
+	rec.RiskLevel = analysis.RiskLow
+	if err := s.Send(t.Context(), rec); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 64<<10)
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// No octet count over UDP; local0.info for a LOW analysis.
+	if msg := string(buf[:n]); !strings.HasPrefix(msg, "<134>1 ") || !strings.Contains(msg, " cds ") {
+		t.Fatalf("datagram = %q", msg)
+	}
+}
+
+func TestNewSyslog_Invalid(t *testing.T) {
+	for _, cfg := range []SyslogConfig{{Addr: "localhost"}, {Network: "unix", Addr: "localhost:514"}} {
+		if _, err := NewSyslog(cfg); err == nil {
+			t.Errorf("NewSyslog(%+v) accepted", cfg)
+		}
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	var status = http.StatusAccepted
+	var got analysis.ForwardedAudit
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer s3cret" || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	h, err := NewHTTP(HTTPConfig{URL: srv.URL + "/ingest", Token: "s3cret", Client: srv.Client()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Send(t.Context(), record("a1")); err != nil || got.AuditID != "a1" || got.Org != "clinic-a" {
+		t.Fatalf("Send = %v, collector got %+v", err, got)
+	}
+	status = http.StatusServiceUnavailable
+	if err := h.Send(t.Context(), record("a2")); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("Send on 503 = %v", err)
+	}
+
+	for _, u := range []string{"http://collector.example/ingest", "collector.example", ""} {
+		if _, err := NewHTTP(HTTPConfig{URL: u}); err == nil {
+			t.Errorf("NewHTTP(%q) accepted", u)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package forward
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+)
+
+// HTTPConfig configures an HTTP sink. URL must be https; Token, when set, is
+// sent as a bearer token. Client defaults to one with no timeout of its own,
+// each delivery being bounded by the Forwarder.
+type HTTPConfig struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+// HTTP implements Sink by POSTing each record as JSON to a collector. Any
+// status other than 2xx is a failed delivery.
+type HTTP struct {
+	cfg HTTPConfig
+}
+
+// NewHTTP builds an HTTP sink, validating the configuration.
+func NewHTTP(cfg HTTPConfig) (*HTTP, error) {
+	u, err := url.Parse(strings.TrimSpace(cfg.URL))
+	if err != nil {
+		return nil, fmt.Errorf("http collector: url: %w", err)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("http collector: url %q is not an https URL", cfg.URL)
+	}
+	cfg.URL = u.String()
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{}
+	}
+	return &HTTP{cfg: cfg}, nil
+}
+
+// Send posts rec to the collector.
+func (h *HTTP) Send(ctx context.Context, rec analysis.ForwardedAudit) error {
+	body, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("http collector: encode: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("http collector: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.cfg.Token)
+	}
+	resp, err := h.cfg.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("http collector: %w", err)
+	}
+	defer resp.Body.Close()
+	// Drain a little so the connection can be reused.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("http collector: %s answered %s", req.URL.Host, resp.Status)
+	}
+	return nil
+}
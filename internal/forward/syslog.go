@@ -0,0 +1,178 @@
+package forward
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+)
+
+const (
+	DefaultAppName = "clinical-ai"
+	// facilityLocal0 is the syslog facility audit records are logged under.
+	facilityLocal0 = 16
+	// sdID names the structured data element of each record; 32473 is the
+	// private enterprise number RFC 5424 reserves for examples, as the
+	// project has none of its own.
+	sdID = "audit@32473"
+)
+
+// Syslog severities.
+const (
+	severityWarning = 4
+	severityNotice  = 5
+	severityInfo    = 6
+)
+
+// SyslogConfig configures a Syslog sink. Network is "tcp" (the default) or
+// "udp"; Hostname falls back to the machine's and AppName to DefaultAppName.
+type SyslogConfig struct {
+	Network  string
+	Addr     string
+	AppName  string
+	Hostname string
+}
+
+// Syslog implements Sink by writing each record as one RFC 5424 message:
+// the audit ID, risk, and org as structured data and the record as JSON.
+// TCP messages are octet-counted (RFC 6587); a UDP datagram carries one
+// message. The connection is kept and redialled after an error.
+type Syslog struct {
+	cfg SyslogConfig
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslog builds a Syslog sink, validating the configuration. It does not
+// dial until the first record.
+func NewSyslog(cfg SyslogConfig) (*Syslog, error) {
+	switch cfg.Network = strings.ToLower(strings.TrimSpace(cfg.Network)); cfg.Network {
+	case "":
+		cfg.Network = "tcp"
+	case "tcp", "udp":
+	default:
+		return nil, fmt.Errorf("syslog: network %q is not tcp or udp", cfg.Network)
+	}
+	if _, _, err := net.SplitHostPort(cfg.Addr); err != nil {
+		return nil, fmt.Errorf("syslog: address %q: %w", cfg.Addr, err)
+	}
+	if cfg.AppName == "" {
+		cfg.AppName = DefaultAppName
+	}
+	if cfg.Hostname == "" {
+		cfg.Hostname, _ = os.Hostname()
+	}
+	cfg.AppName = headerField(cfg.AppName, 48)
+	cfg.Hostname = headerField(cfg.Hostname, 255)
+	return &Syslog{cfg: cfg}, nil
+}
+
+// Send writes rec, dialling first when there is no open connection.
+func (s *Syslog) Send(ctx context.Context, rec analysis.ForwardedAudit) error {
+	msg, err := s.message(rec, time.Now())
+	if err != nil {
+		return err
+	}
+	if s.cfg.Network == "tcp" {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		var d net.Dialer
+		if s.conn, err = d.DialContext(ctx, s.cfg.Network, s.cfg.Addr); err != nil {
+			return fmt.Errorf("syslog: dial %s: %w", s.cfg.Addr, err)
+		}
+	}
+	deadline, _ := ctx.Deadline()
+	s.conn.SetWriteDeadline(deadline)
+	if _, err := s.conn.Write(msg); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return fmt.Errorf("syslog: write: %w", err)
+	}
+	return nil
+}
+
+// Close closes the connection, if one is open.
+func (s *Syslog) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// message formats rec as an RFC 5424 message, timestamped with the audit's
+// time, or now when it has none.
+func (s *Syslog) message(rec analysis.ForwardedAudit, now time.Time) ([]byte, error) {
+	body, err := json.Marshal(rec)
+	if err != nil {
+		return nil, fmt.Errorf("syslog: encode: %w", err)
+	}
+	at, err := time.Parse(time.RFC3339, rec.At)
+	if err != nil {
+		at = now
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %d audit [%s", facilityLocal0*8+severity(rec.RiskLevel),
+		at.UTC().Format(time.RFC3339Nano), s.cfg.Hostname, s.cfg.AppName, os.Getpid(), sdID)
+	param := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, ` %s="%s"`, name, sdEscaper.Replace(value))
+		}
+	}
+	param("auditId", rec.AuditID)
+	param("riskLevel", string(rec.RiskLevel))
+	param("riskScore", strconv.Itoa(rec.RiskScore))
+	param("org", rec.Org)
+	b.WriteString("] ")
+	b.Write(body)
+	return []byte(b.String()), nil
+}
+
+// severity maps a risk level to the syslog severity its record is logged at,
+// so collectors can alert on the worst analyses without parsing the JSON.
+func severity(level analysis.RiskLevel) int {
+	switch level {
+	case analysis.RiskCritical:
+		return severityWarning
+	case analysis.RiskHigh:
+		return severityNotice
+	default:
+		return severityInfo
+	}
+}
+
+// sdEscaper escapes the characters RFC 5424 reserves in a PARAM-VALUE.
+var sdEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`, `]`, `\]`)
+
+// headerField makes v a valid HEADER field of at most n characters: printable
+// US-ASCII without spaces, or the nil value "-" when nothing is left.
+func headerField(v string, n int) string {
+	v = strings.Map(func(r rune) rune {
+		if r < '!' || r > '~' {
+			return -1
+		}
+		return r
+	}, v)
+	if len(v) > n {
+		v = v[:n]
+	}
+	if v == "" {
+		return "-"
+	}
+	return v
+}
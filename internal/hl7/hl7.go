@@ -0,0 +1,193 @@
+// Package hl7 parses HL7 v2 messages, maps ADT^A04 and ORU^R01 messages to
+// analysis intakes, and serves analyses to MLLP senders.
+package hl7
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Message is a parsed HL7 v2 message: its segments in order, with the
+// delimiters declared in MSH.
+type Message struct {
+	Segments []Segment
+}
+
+// Segment is one segment. Fields are numbered as in the standard, so Field(1)
+// is the first field after the segment ID; for MSH that is the field
+// separator itself.
+type Segment struct {
+	Name   string
+	fields []string
+	d      delimiters
+}
+
+type delimiters struct {
+	field, component, repetition, escape, subcomponent byte
+}
+
+// Parse splits a message into segments. Segments end in a carriage return;
+// line feeds are accepted too. The first segment must be MSH, which declares
+// the delimiters.
+func Parse(data []byte) (Message, error) {
+	s := strings.TrimLeft(string(data), "\r\n")
+	if !strings.HasPrefix(s, "MSH") || len(s) < 8 {
+		return Message{}, errors.New("hl7: message does not start with an MSH segment")
+	}
+	d := delimiters{field: s[3], component: s[4], repetition: s[5], escape: s[6], subcomponent: s[7]}
+	if d.field == d.component || strings.ContainsAny(string([]byte{d.field, d.component, d.repetition, d.escape, d.subcomponent}), "\r\n") {
+		return Message{}, fmt.Errorf("hl7: invalid MSH delimiters %q", s[3:8])
+	}
+	var m Message
+	for _, line := range strings.FieldsFunc(s, func(r rune) bool { return r == '\r' || r == '\n' }) {
+		fields := strings.Split(line, string(d.field))
+		name := fields[0]
+		if len(name) != 3 {
+			return Message{}, fmt.Errorf("hl7: segment %d has invalid ID %q", len(m.Segments)+1, name)
+		}
+		if name == "MSH" {
+			// MSH-1 is the field separator, which the split consumed.
+			fields = append([]string{name, string(d.field)}, fields[1:]...)
+		}
+		m.Segments = append(m.Segments, Segment{Name: name, fields: fields, d: d})
+	}
+	return m, nil
+}
+
+// All returns the segments named name, in order.
+func (m Message) All(name string) []Segment {
+	var out []Segment
+	for _, seg := range m.Segments {
+		if seg.Name == name {
+			out = append(out, seg)
+		}
+	}
+	return out
+}
+
+// First returns the first segment named name.
+func (m Message) First(name string) (Segment, bool) {
+	for _, seg := range m.Segments {
+		if seg.Name == name {
+			return seg, true
+		}
+	}
+	return Segment{}, false
+}
+
+// header is the message's MSH segment; Parse guarantees there is one.
+func (m Message) header() Segment {
+	seg, _ := m.First("MSH")
+	return seg
+}
+
+// Type returns the message code and trigger event of MSH-9, e.g. ADT and A04.
+func (m Message) Type() (code, event string) {
+	h := m.header()
+	return h.Component(9, 1), h.Component(9, 2)
+}
+
+// ControlID returns MSH-10, which the ACK echoes.
+func (m Message) ControlID() string {
+	return m.header().Field(10)
+}
+
+// Raw returns field n as sent, repetitions and escapes included.
+func (s Segment) Raw(n int) string {
+	if n < 1 || n >= len(s.fields) {
+		return ""
+	}
+	return s.fields[n]
+}
+
+// Field returns the first repetition of field n, unescaped, with any
+// components joined as sent.
+func (s Segment) Field(n int) string {
+	if s.Name == "MSH" && n <= 2 {
+		// The delimiters are not escaped.
+		return s.Raw(n)
+	}
+	rep, _, _ := strings.Cut(s.Raw(n), string(s.d.repetition))
+	return s.unescape(rep)
+}
+
+// Component returns component c of the first repetition of field n,
+// unescaped; subcomponents are joined as sent.
+func (s Segment) Component(n, c int) string {
+	rep, _, _ := strings.Cut(s.Raw(n), string(s.d.repetition))
+	parts := strings.Split(rep, string(s.d.component))
+	if c < 1 || c > len(parts) {
+		return ""
+	}
+	return s.unescape(parts[c-1])
+}
+
+// Repetitions returns how many repetitions field n has.
+func (s Segment) Repetitions(n int) int {
+	raw := s.Raw(n)
+	if raw == "" {
+		return 0
+	}
+	return strings.Count(raw, string(s.d.repetition)) + 1
+}
+
+// unescape resolves the delimiter escapes \F\, \S\, \T\, \R\, and \E\. Other
+// escapes, such as formatting and hex, are dropped.
+func (s Segment) unescape(v string) string {
+	esc := string(s.d.escape)
+	if !strings.Contains(v, esc) {
+		return strings.TrimSpace(v)
+	}
+	var b strings.Builder
+	for {
+		before, rest, ok := strings.Cut(v, esc)
+		b.WriteString(before)
+		if !ok {
+			break
+		}
+		seq, after, ok := strings.Cut(rest, esc)
+		if !ok {
+			b.WriteString(rest)
+			break
+		}
+		switch seq {
+		case "F":
+			b.WriteByte(s.d.field)
+		case "S":
+			b.WriteByte(s.d.component)
+		case "T":
+			b.WriteByte(s.d.subcomponent)
+		case "R":
+			b.WriteByte(s.d.repetition)
+		case "E":
+			b.WriteByte(s.d.escape)
+		}
+		v = after
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// quote is the inverse of unescape, for text written into an ACK.
+func (d delimiters) quote(v string) string {
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		switch v[i] {
+		case d.escape:
+			b.WriteString(string(d.escape) + "E" + string(d.escape))
+		case d.field:
+			b.WriteString(string(d.escape) + "F" + string(d.escape))
+		case d.component:
+			b.WriteString(string(d.escape) + "S" + string(d.escape))
+		case d.subcomponent:
+			b.WriteString(string(d.escape) + "T" + string(d.escape))
+		case d.repetition:
+			b.WriteString(string(d.escape) + "R" + string(d.escape))
+		case '\r', '\n':
+			b.WriteByte(' ')
+		default:
+			b.WriteByte(v[i])
+		}
+	}
+	return b.String()
+}
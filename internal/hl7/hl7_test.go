@@ -0,0 +1,57 @@
+package hl7
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	msg, err := Parse([]byte("MSH|^~\\&|LAB|MERCY|||20250601||ORU^R01|C1|P|2.5.1\r\nPID|1||MRN1~MRN2||O\\S\\Brien^M\\F\\ry\\E\\||19851122\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msg.Segments) != 2 {
+		t.Fatalf("segments = %d", len(msg.Segments))
+	}
+	h, _ := msg.First("MSH")
+	if h.Field(1) != "|" || h.Field(2) != "^~\\&" || h.Field(3) != "LAB" || msg.ControlID() != "C1" {
+		t.Errorf("MSH numbering: 1=%q 2=%q 3=%q 10=%q", h.Field(1), h.Field(2), h.Field(3), msg.ControlID())
+	}
+	if typ, event := msg.Type(); typ != "ORU" || event != "R01" {
+		t.Errorf("type = %s^%s", typ, event)
+	}
+	pid, _ := msg.First("PID")
+	if got := pid.Field(3); got != "MRN1" {
+		t.Errorf("first repetition = %q", got)
+	}
+	if got := pid.Repetitions(3); got != 2 {
+		t.Errorf("repetitions = %d", got)
+	}
+	if fam, given := pid.Component(5, 1), pid.Component(5, 2); fam != "O^Brien" || given != "M|ry\\" {
+		t.Errorf("unescaped name = %q, %q", fam, given)
+	}
+	if pid.Field(40) != "" || pid.Component(5, 9) != "" {
+		t.Error("missing fields and components should be empty")
+	}
+}
+
+func TestParse_Rejects(t *testing.T) {
+	for _, raw := range []string{
+		"",
+		"PID|1||MRN1",
+		"MSH|",
+		"MSH|^~\\&|LAB\rPIDX|1",
+	} {
+		if _, err := Parse([]byte(raw)); err == nil {
+			t.Errorf("Parse(%q) accepted", raw)
+		}
+	}
+}
+
+func TestQuote(t *testing.T) {
+	d := delimiters{field: '|', component: '^', repetition: '~', escape: '\\', subcomponent: '&'}
+	if got := d.quote("a|b^c~d\\e&f\rg"); got != `a\F\b\S\c\R\d\E\e\T\f g` {
+		t.Fatalf("quote = %q", got)
+	}
+	seg := Segment{fields: []string{"ZZZ", d.quote("a|b^c~d\\e&f")}, d: d}
+	if got := seg.Field(1); got != "a|b^c~d\\e&f" {
+		t.Fatalf("round trip = %q", got)
+	}
+}
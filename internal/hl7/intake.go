@@ -0,0 +1,339 @@
+package hl7
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+)
+
+// ErrorCode is an HL7 message error condition code (table 0357), sent in
+// ERR-3 of a negative ACK.
+type ErrorCode string
+
+const (
+	SegmentSequenceError     ErrorCode = "100"
+	RequiredFieldMissing     ErrorCode = "101"
+	DataTypeError            ErrorCode = "102"
+	TableValueNotFound       ErrorCode = "103"
+	UnsupportedMessageType   ErrorCode = "200"
+	UnsupportedEventCode     ErrorCode = "201"
+	ApplicationInternalError ErrorCode = "207"
+)
+
+// LOINC codes read from OBX-3, as for FHIR Observations.
+const (
+	loincBPPanel   = "85354-9"
+	loincSystolic  = "8480-6"
+	loincDiastolic = "8462-4"
+	loincWeight    = "29463-7"
+	loincHeight    = "8302-2"
+)
+
+// supported lists the message types ToIntake maps, by code and trigger event.
+var supported = map[string][]string{
+	"ADT": {"A04"},
+	"ORU": {"R01"},
+}
+
+// MappingError describes a segment or field that could not be mapped to the
+// intake. Seq counts segments with the same ID from 1; it and Field are zero
+// for problems with the message as a whole.
+type MappingError struct {
+	Code    ErrorCode `json:"code"`
+	Segment string    `json:"segment,omitempty"`
+	Seq     int       `json:"seq,omitempty"`
+	Field   int       `json:"field,omitempty"`
+	Message string    `json:"message"`
+}
+
+func (e MappingError) Error() string {
+	ref := e.Segment
+	if e.Seq > 1 {
+		ref += "[" + strconv.Itoa(e.Seq) + "]"
+	}
+	if e.Field > 0 {
+		ref += "-" + strconv.Itoa(e.Field)
+	}
+	if ref == "" {
+		return e.Message
+	}
+	return ref + ": " + e.Message
+}
+
+// ToIntake maps an ADT^A04 or ORU^R01 message to an Intake: PID (name, and
+// age from the date of birth as of now), OBX blood pressure, weight, and
+// height observations coded in LOINC, AL1 allergies, RXA and RXO
+// medications, PV2-3 as the complaint, and CON as consent. Other segments
+// are ignored. Observations with result status W (wrong) are skipped.
+func ToIntake(msg Message, now time.Time) (analysis.Intake, []MappingError) {
+	m := mapper{now: now, seen: map[string]bool{}}
+	code, event := msg.Type()
+	events, ok := supported[code]
+	switch {
+	case !ok:
+		m.fail(UnsupportedMessageType, "MSH", 1, 9, "message type %q is not supported; send ADT^A04 or ORU^R01", code)
+		return m.in, m.errs
+	case !slices.Contains(events, event):
+		m.fail(UnsupportedEventCode, "MSH", 1, 9, "%s event %q is not supported", code, event)
+		return m.in, m.errs
+	}
+	seqs := map[string]int{}
+	for _, seg := range msg.Segments {
+		seqs[seg.Name]++
+		seq := seqs[seg.Name]
+		switch seg.Name {
+		case "PID":
+			m.patient(seg, seq)
+		case "OBX":
+			m.observation(seg, seq)
+		case "AL1":
+			m.allergy(seg, seq)
+		case "RXA":
+			m.medication(seg, seq, 5, 6, 7)
+		case "RXO":
+			m.medication(seg, seq, 1, 2, 4)
+		case "PV2":
+			if m.in.Complaint == "" {
+				m.in.Complaint = label(seg, 3)
+			}
+		case "CON":
+			m.consent(seg, seq)
+		}
+	}
+	m.require(seqs)
+	return m.in, m.errs
+}
+
+type mapper struct {
+	now  time.Time
+	in   analysis.Intake
+	errs []MappingError
+	// seen records observation kinds present, valid or not, so a bad
+	// segment is not reported a second time as missing.
+	seen     map[string]bool
+	sys, dia string
+}
+
+func (m *mapper) fail(code ErrorCode, segment string, seq, field int, format string, args ...any) {
+	m.errs = append(m.errs, MappingError{Code: code, Segment: segment, Seq: seq, Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (m *mapper) patient(seg Segment, seq int) {
+	if seq > 1 {
+		m.fail(SegmentSequenceError, "PID", seq, 0, "message must contain exactly one PID segment")
+		return
+	}
+	// PID-5 is family^given^middle.
+	name := strings.Join(strings.Fields(seg.Component(5, 2)+" "+seg.Component(5, 3)+" "+seg.Component(5, 1)), " ")
+	if name == "" {
+		m.fail(RequiredFieldMissing, "PID", seq, 5, "patient name missing")
+	}
+	m.in.PatientName = name
+	dob := seg.Component(7, 1)
+	if dob == "" {
+		m.fail(RequiredFieldMissing, "PID", seq, 7, "date of birth missing")
+		return
+	}
+	born, err := parseDTM(dob)
+	if err != nil {
+		m.fail(DataTypeError, "PID", seq, 7, "invalid date of birth %q", dob)
+		return
+	}
+	m.in.Age = ageAt(born, m.now)
+	if m.in.Age <= 0 {
+		m.fail(DataTypeError, "PID", seq, 7, "%s gives age %d", dob, m.in.Age)
+	}
+}
+
+var bpValue = regexp.MustCompile(`^\d{2,3}/\d{2,3}$`)
+
+func (m *mapper) observation(seg Segment, seq int) {
+	if seg.Field(11) == "W" {
+		return
+	}
+	sys := seg.Component(3, 3)
+	if sys != "" && sys != "LN" {
+		return
+	}
+	code := seg.Component(3, 1)
+	value := seg.Field(5)
+	switch code {
+	case loincBPPanel:
+		m.seen[loincBPPanel] = true
+		if !bpValue.MatchString(value) {
+			m.fail(DataTypeError, "OBX", seq, 5, "blood pressure %q is not systolic/diastolic", value)
+			return
+		}
+		m.in.BP = value
+	case loincSystolic, loincDiastolic:
+		m.seen[code] = true
+		v, ok := m.number(seg, seq, value)
+		if !ok {
+			return
+		}
+		if code == loincSystolic {
+			m.sys = formatNumber(v)
+		} else {
+			m.dia = formatNumber(v)
+		}
+	case loincWeight:
+		m.seen[loincWeight] = true
+		if v, ok := m.quantity(seg, seq, value, map[string]float64{"kg": 1, "g": 0.001, "[lb_av]": 0.45359237, "lb": 0.45359237}); ok {
+			m.in.WeightKg = v
+		}
+	case loincHeight:
+		m.seen[loincHeight] = true
+		if v, ok := m.quantity(seg, seq, value, map[string]float64{"cm": 1, "m": 100, "[in_i]": 2.54, "in": 2.54}); ok {
+			m.in.HeightCm = v
+		}
+	}
+}
+
+func (m *mapper) number(seg Segment, seq int, value string) (float64, bool) {
+	if value == "" {
+		m.fail(RequiredFieldMissing, "OBX", seq, 5, "observation value missing")
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		m.fail(DataTypeError, "OBX", seq, 5, "observation value %q is not a number", value)
+		return 0, false
+	}
+	return v, true
+}
+
+// quantity converts an OBX value using factors keyed by the UCUM code in
+// OBX-6 (or its text when no code is given), rounded to one decimal place.
+func (m *mapper) quantity(seg Segment, seq int, value string, factors map[string]float64) (float64, bool) {
+	v, ok := m.number(seg, seq, value)
+	if !ok {
+		return 0, false
+	}
+	unit := seg.Component(6, 1)
+	if unit == "" {
+		unit = seg.Component(6, 2)
+	}
+	f, ok := factors[unit]
+	if !ok {
+		m.fail(TableValueNotFound, "OBX", seq, 6, "unsupported unit %q", unit)
+		return 0, false
+	}
+	return math.Round(v*f*10) / 10, true
+}
+
+func (m *mapper) allergy(seg Segment, seq int) {
+	if name := label(seg, 3); name != "" {
+		m.in.Allergies = append(m.in.Allergies, name)
+		return
+	}
+	m.fail(RequiredFieldMissing, "AL1", seq, 3, "allergen missing")
+}
+
+// medication maps RXA (administered code, amount, units) or RXO (requested
+// give code, minimum amount, units) given the field numbers of each.
+func (m *mapper) medication(seg Segment, seq, codeField, amountField, unitsField int) {
+	name := label(seg, codeField)
+	if name == "" {
+		m.fail(RequiredFieldMissing, seg.Name, seq, codeField, "drug name missing")
+		return
+	}
+	med := analysis.Medication{Name: name}
+	if amount := seg.Field(amountField); amount != "" && amount != "999" {
+		// RXA-6 999 means the amount is unknown.
+		unit := seg.Component(unitsField, 1)
+		if unit == "" {
+			unit = seg.Component(unitsField, 2)
+		}
+		med.Dosage = amount + unit
+	}
+	m.in.Medications = append(m.in.Medications, med)
+}
+
+// consentModes maps CON-10 (consent mode, table 0497) to a consent method.
+var consentModes = map[string]string{"V": "verbal", "W": "written", "T": "telephone"}
+
+// consent maps a CON segment: CON-11 status A (active) is given consent and
+// any other status is consent not given, with the decision time in CON-13.
+func (m *mapper) consent(seg Segment, seq int) {
+	c := &analysis.Consent{Given: seg.Field(11) == "A", Method: consentModes[seg.Field(10)]}
+	if c.Method == "" {
+		c.Method = "hl7"
+	}
+	if at := seg.Component(13, 1); at != "" {
+		t, err := parseDTM(at)
+		if err != nil {
+			m.fail(DataTypeError, "CON", seq, 13, "invalid decision time %q", at)
+			return
+		}
+		c.Timestamp = t.Format(time.RFC3339)
+	}
+	m.in.Consent = c
+}
+
+func (m *mapper) require(seqs map[string]int) {
+	if seqs["PID"] == 0 {
+		m.fail(SegmentSequenceError, "PID", 0, 0, "no PID segment")
+	}
+	switch {
+	case m.seen[loincBPPanel]:
+	case m.seen[loincSystolic] && m.seen[loincDiastolic]:
+		if m.sys != "" && m.dia != "" {
+			m.in.BP = m.sys + "/" + m.dia
+		}
+	default:
+		m.fail(RequiredFieldMissing, "OBX", 0, 0, "no blood pressure observation (LOINC %s, or %s and %s)", loincBPPanel, loincSystolic, loincDiastolic)
+	}
+	if !m.seen[loincWeight] {
+		m.fail(RequiredFieldMissing, "OBX", 0, 0, "no body weight observation (LOINC %s)", loincWeight)
+	}
+	if !m.seen[loincHeight] {
+		m.fail(RequiredFieldMissing, "OBX", 0, 0, "no body height observation (LOINC %s)", loincHeight)
+	}
+}
+
+// label returns the text of a coded field (CE or CWE), falling back to the
+// identifier.
+func label(seg Segment, field int) string {
+	if text := seg.Component(field, 2); text != "" {
+		return text
+	}
+	return seg.Component(field, 1)
+}
+
+// parseDTM parses an HL7 date/time to the precisions used here: YYYYMMDD,
+// YYYYMMDDHHMM, or YYYYMMDDHHMMSS, with an optional +/-ZZZZ offset. Without
+// one the time is taken as UTC. Fractional seconds are dropped.
+func parseDTM(s string) (time.Time, error) {
+	v, offset := s, ""
+	if i := strings.IndexAny(s, "+-"); i >= 0 {
+		v, offset = s[:i], s[i:]
+	}
+	v, _, _ = strings.Cut(v, ".")
+	layouts := map[int]string{8: "20060102", 12: "200601021504", 14: "20060102150405"}
+	layout, ok := layouts[len(v)]
+	if !ok {
+		return time.Time{}, fmt.Errorf("invalid date/time %q", s)
+	}
+	if offset != "" {
+		return time.Parse(layout+"-0700", v+offset)
+	}
+	return time.Parse(layout, v)
+}
+
+func ageAt(born, now time.Time) int {
+	age := now.Year() - born.Year()
+	if now.Month() < born.Month() || (now.Month() == born.Month() && now.Day() < born.Day()) {
+		age--
+	}
+	return age
+}
+
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
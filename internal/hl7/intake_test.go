@@ -0,0 +1,84 @@
+package hl7
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+// goldenNow fixes the reference date used for ages in golden files.
+var goldenNow = time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+type mapped struct {
+	Intake analysis.Intake `json:"intake"`
+	Errors []MappingError  `json:"errors"`
+}
+
+func TestToIntake_Golden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "*.hl7"))
+	if err != nil || len(inputs) == 0 {
+		t.Fatalf("no sample messages: %v", err)
+	}
+	for _, path := range inputs {
+		name := strings.TrimSuffix(filepath.Base(path), ".hl7")
+		t.Run(name, func(t *testing.T) {
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg, err := Parse(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			in, errs := ToIntake(msg, goldenNow)
+			got, err := json.MarshalIndent(mapped{Intake: in, Errors: errs}, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			golden := filepath.Join("testdata", name+".golden.json")
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("read golden (run with -update to create): %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("mapping differs from %s:\n%s", golden, got)
+			}
+		})
+	}
+}
+
+func TestParseDTM(t *testing.T) {
+	for in, want := range map[string]string{
+		"19700315":               "1970-03-15T00:00:00Z",
+		"202506010830":           "2025-06-01T08:30:00Z",
+		"20250601083015.1234":    "2025-06-01T08:30:15Z",
+		"20250601083015-0500":    "2025-06-01T08:30:15-05:00",
+		"20250601083015.12+0130": "2025-06-01T08:30:15+01:30",
+	} {
+		got, err := parseDTM(in)
+		if err != nil || got.Format(time.RFC3339) != want {
+			t.Errorf("parseDTM(%q) = %v, %v; want %s", in, got.Format(time.RFC3339), err, want)
+		}
+	}
+	for _, in := range []string{"1970", "1970-03-15", "19700230", "2025060108"} {
+		if _, err := parseDTM(in); err == nil {
+			t.Errorf("parseDTM(%q) accepted", in)
+		}
+	}
+}
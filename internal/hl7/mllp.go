@@ -0,0 +1,321 @@
+package hl7
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+// MLLP frame bytes: a message is sent as start, message, end, carriage return.
+const (
+	frameStart = 0x0b
+	frameEnd   = 0x1c
+)
+
+const (
+	// DefaultAuditIDField is the ACK field that carries the audit ID.
+	DefaultAuditIDField = "MSA-3"
+	// DefaultApplication is MSH-3 of ACKs.
+	DefaultApplication = "CLINICAL-AI-ASSISTANT"
+	// maxMessageBytes bounds one framed message.
+	maxMessageBytes = 1 << 20
+	// idleTimeout closes connections that send nothing for this long.
+	idleTimeout = 5 * time.Minute
+)
+
+// ACK codes (MSA-1).
+const (
+	AckAccept = "AA"
+	AckError  = "AE"
+	AckReject = "AR"
+)
+
+// Config configures a Server.
+type Config struct {
+	// Analyzer runs the analyses; nil uses analysis.Default().
+	Analyzer *analysis.Analyzer
+	// Complaint is the complaint of messages without one in PV2-3.
+	Complaint string
+	// AuditIDField is the ACK field the audit ID is written to, as
+	// SEGMENT-FIELD: MSA-3 (the default), or a field of a Z segment such
+	// as ZAU-1, which is then appended to the ACK.
+	AuditIDField string
+	// Application is MSH-3 of ACKs; empty uses DefaultApplication.
+	Application string
+	// Org scopes analyses and audits to one org of a partitioned
+	// deployment; the MLLP sender has no API key to resolve one from.
+	Org string
+	// StrictAudit answers AE instead of AA when the audit write fails,
+	// as AUDIT_STRICT does for the HTTP API.
+	StrictAudit bool
+}
+
+// Server analyzes HL7 messages received over MLLP and answers each with an
+// ACK: AA with the audit ID when the analysis was recorded, AE when the
+// message could not be mapped or the intake failed validation, and AR for
+// messages that are not HL7 or not a supported type. Negative ACKs carry one
+// ERR segment per problem, with its location and table 0357 code.
+type Server struct {
+	a           *analysis.Analyzer
+	cfg         Config
+	ackSegment  string
+	ackField    int
+	now         func() time.Time
+	newMsgID    func() string
+	connections sync.WaitGroup
+}
+
+var auditIDField = regexp.MustCompile(`^([A-Z][A-Z0-9]{2})-([1-9][0-9]?)$`)
+
+// NewServer validates cfg and returns a Server.
+func NewServer(cfg Config) (*Server, error) {
+	s := &Server{a: cfg.Analyzer, cfg: cfg, now: time.Now, newMsgID: randomID}
+	if s.a == nil {
+		s.a = analysis.Default()
+	}
+	if s.cfg.Application == "" {
+		s.cfg.Application = DefaultApplication
+	}
+	if s.cfg.AuditIDField == "" {
+		s.cfg.AuditIDField = DefaultAuditIDField
+	}
+	m := auditIDField.FindStringSubmatch(s.cfg.AuditIDField)
+	if m == nil {
+		return nil, fmt.Errorf("hl7: audit ID field %q is not SEGMENT-FIELD, e.g. MSA-3 or ZAU-1", s.cfg.AuditIDField)
+	}
+	s.ackSegment = m[1]
+	s.ackField, _ = strconv.Atoi(m[2])
+	switch {
+	case s.ackSegment == "MSA" && s.ackField <= 2:
+		return nil, fmt.Errorf("hl7: %s holds the acknowledgment code or control ID", s.cfg.AuditIDField)
+	case s.ackSegment != "MSA" && !strings.HasPrefix(s.ackSegment, "Z"):
+		return nil, fmt.Errorf("hl7: audit ID field %s must be in MSA or a Z segment", s.cfg.AuditIDField)
+	}
+	return s, nil
+}
+
+// Serve accepts MLLP connections on ln until ctx is done, then closes ln,
+// lets in-flight messages finish, and returns nil.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	stop := context.AfterFunc(ctx, func() { ln.Close() })
+	defer stop()
+	defer s.connections.Wait()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if isTimeout(err) {
+				continue
+			}
+			return err
+		}
+		s.connections.Add(1)
+		go func() {
+			defer s.connections.Done()
+			s.serveConn(ctx, conn)
+		}()
+	}
+}
+
+// serveConn answers each framed message on conn in turn. Shutdown unblocks
+// a connection waiting for its next message but not one being answered.
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.SetReadDeadline(time.Now()) })
+	defer stop()
+	r := bufio.NewReader(conn)
+	for {
+		conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		msg, err := readFrame(r)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) && !isTimeout(err) {
+				log.Printf("hl7: %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		ack := s.Handle(context.WithoutCancel(ctx), msg)
+		conn.SetWriteDeadline(time.Now().Add(idleTimeout))
+		if _, err := conn.Write(frame(ack)); err != nil {
+			log.Printf("hl7: write ACK to %s: %v", conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// readFrame reads one MLLP frame, skipping bytes before its start byte.
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	if _, err := r.ReadBytes(frameStart); err != nil {
+		return nil, err
+	}
+	var msg []byte
+	for {
+		chunk, err := r.ReadSlice(frameEnd)
+		if len(msg)+len(chunk) > maxMessageBytes {
+			return nil, fmt.Errorf("message exceeds %d bytes", maxMessageBytes)
+		}
+		msg = append(msg, chunk...)
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		break
+	}
+	if b, err := r.ReadByte(); err != nil || b != '\r' {
+		return nil, errors.New("frame end not followed by a carriage return")
+	}
+	return msg[:len(msg)-1], nil
+}
+
+func frame(msg []byte) []byte {
+	out := make([]byte, 0, len(msg)+3)
+	out = append(out, frameStart)
+	out = append(out, msg...)
+	return append(out, frameEnd, '\r')
+}
+
+// Handle analyzes one unframed message and returns its unframed ACK.
+func (s *Server) Handle(ctx context.Context, raw []byte) []byte {
+	msg, err := Parse(raw)
+	if err != nil {
+		return s.ack(Message{}, AckReject, "", []MappingError{{Code: SegmentSequenceError, Segment: "MSH", Message: err.Error()}})
+	}
+	in, errs := ToIntake(msg, s.now())
+	if len(errs) > 0 {
+		code := AckError
+		if c := errs[0].Code; c == UnsupportedMessageType || c == UnsupportedEventCode {
+			code = AckReject
+		}
+		s.log(msg, code, "")
+		return s.ack(msg, code, "", errs)
+	}
+	if in.Complaint == "" {
+		in.Complaint = s.cfg.Complaint
+	}
+	if s.cfg.Org != "" {
+		ctx = audit.WithOrg(ctx, s.cfg.Org)
+	}
+	resp := s.a.AnalyzeContext(ctx, in, analysis.Options{})
+	if len(resp.ValidationErrors) > 0 {
+		errs := make([]MappingError, 0, len(resp.ValidationErrors))
+		for _, v := range resp.ValidationErrors {
+			errs = append(errs, MappingError{Code: DataTypeError, Message: v})
+		}
+		s.log(msg, AckError, resp.AuditID)
+		return s.ack(msg, AckError, resp.AuditID, errs)
+	}
+	if s.cfg.StrictAudit && resp.AuditRecorded != nil && !*resp.AuditRecorded {
+		s.log(msg, AckError, "")
+		return s.ack(msg, AckError, "", []MappingError{{Code: ApplicationInternalError, Message: "the audit log could not be written; no analysis is returned without one"}})
+	}
+	s.log(msg, AckAccept, resp.AuditID)
+	return s.ack(msg, AckAccept, resp.AuditID, nil)
+}
+
+// log records the outcome without patient data.
+func (s *Server) log(msg Message, code, auditID string) {
+	typ, event := msg.Type()
+	log.Printf("hl7 analysis audit_id=%s message=%s^%s control_id=%s ack=%s", auditID, typ, event, msg.ControlID(), code)
+}
+
+// ack builds the ACK to msg, which is empty when it could not be parsed.
+func (s *Server) ack(msg Message, code, auditID string, errs []MappingError) []byte {
+	d := delimiters{field: '|', component: '^', repetition: '~', escape: '\\', subcomponent: '&'}
+	h, ok := msg.First("MSH")
+	if ok {
+		d = h.d
+	}
+	seg := func(fields ...string) string {
+		return strings.Join(fields, string(d.field))
+	}
+	comp := func(parts ...string) string {
+		return strings.Join(parts, string(d.component))
+	}
+	_, event := msg.Type()
+	encoding := string([]byte{d.component, d.repetition, d.escape, d.subcomponent})
+	segments := []string{
+		seg("MSH", encoding, d.quote(s.cfg.Application), "", h.Raw(3), h.Raw(4), s.now().UTC().Format("20060102150405"), "",
+			comp("ACK", d.quote(event), "ACK"), s.newMsgID(), h.Raw(11), h.Raw(12)),
+	}
+	msa := []string{"MSA", code, d.quote(msg.ControlID())}
+	if s.ackSegment == "MSA" {
+		msa = setField(msa, s.ackField, d.quote(auditID))
+	}
+	segments = append(segments, seg(trimFields(msa)...))
+	for _, e := range errs {
+		loc := ""
+		if e.Segment != "" {
+			loc = comp(e.Segment, fieldNumber(e.Seq), fieldNumber(e.Field))
+		}
+		// ERR-2 location, ERR-3 code (table 0357), ERR-4 severity, ERR-8
+		// user message.
+		segments = append(segments, seg("ERR", "", strings.TrimRight(loc, string(d.component)),
+			comp(string(e.Code), errorText[e.Code], "HL70357"), "E", "", "", "", d.quote(e.Error())))
+	}
+	if s.ackSegment != "MSA" && auditID != "" {
+		segments = append(segments, seg(setField([]string{s.ackSegment}, s.ackField, d.quote(auditID))...))
+	}
+	return []byte(strings.Join(segments, "\r") + "\r")
+}
+
+// errorText is the table 0357 text of each code.
+var errorText = map[ErrorCode]string{
+	SegmentSequenceError:     "Segment sequence error",
+	RequiredFieldMissing:     "Required field missing",
+	DataTypeError:            "Data type error",
+	TableValueNotFound:       "Table value not found",
+	UnsupportedMessageType:   "Unsupported message type",
+	UnsupportedEventCode:     "Unsupported event code",
+	ApplicationInternalError: "Application internal error",
+}
+
+func setField(fields []string, n int, v string) []string {
+	for len(fields) <= n {
+		fields = append(fields, "")
+	}
+	fields[n] = v
+	return fields
+}
+
+func trimFields(fields []string) []string {
+	for len(fields) > 1 && fields[len(fields)-1] == "" {
+		fields = fields[:len(fields)-1]
+	}
+	return fields
+}
+
+func fieldNumber(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// randomID returns an ACK control ID; MSH-10 holds at most 20 characters.
+func randomID() string {
+	var b [10]byte
+	rand.Read(b[:])
+	return strings.ToUpper(hex.EncodeToString(b[:]))
+}
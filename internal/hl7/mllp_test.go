@@ -0,0 +1,171 @@
+package hl7
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+func newTestServer(t *testing.T, cfg Config) (*Server, *audit.MemoryStore) {
+	t.Helper()
+	store := audit.NewMemoryStore()
+	cfg.Analyzer = analysis.New(analysis.WithAuditStore(store), analysis.WithIDGenerator(audit.IDGeneratorFunc(func() string { return "audit-1" })))
+	s, err := NewServer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.now = func() time.Time { return goldenNow }
+	s.newMsgID = func() string { return "ACK1" }
+	return s, store
+}
+
+func sample(t *testing.T, name string) []byte {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join("testdata", name+".hl7"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []byte(strings.ReplaceAll(string(raw), "\n", "\r"))
+}
+
+func ackSegments(t *testing.T, ack []byte) map[string][]Segment {
+	t.Helper()
+	msg, err := Parse(ack)
+	if err != nil {
+		t.Fatalf("ACK does not parse: %v\n%s", err, ack)
+	}
+	out := map[string][]Segment{}
+	for _, seg := range msg.Segments {
+		out[seg.Name] = append(out[seg.Name], seg)
+	}
+	return out
+}
+
+func TestHandle_Accept(t *testing.T) {
+	s, store := newTestServer(t, Config{})
+	ack := s.Handle(t.Context(), sample(t, "adt-a04"))
+	want := "MSH|^~\\&|CLINICAL-AI-ASSISTANT||REGADT|MERCY|20250601000000||ACK^A04^ACK|ACK1|P|2.5.1\rMSA|AA|MSG00001|audit-1\r"
+	if string(ack) != want {
+		t.Fatalf("ACK:\n got %q\nwant %q", ack, want)
+	}
+	if _, err := store.Summary(t.Context(), "audit-1"); err != nil {
+		t.Fatalf("analysis not audited: %v", err)
+	}
+}
+
+func TestHandle_AuditIDField(t *testing.T) {
+	s, _ := newTestServer(t, Config{AuditIDField: "ZAU-2", Complaint: "ED"})
+	segs := ackSegments(t, s.Handle(t.Context(), sample(t, "rxa")))
+	if msa := segs["MSA"][0]; msa.Field(1) != AckAccept || msa.Field(3) != "" {
+		t.Fatalf("MSA = %q", msa.fields)
+	}
+	if z := segs["ZAU"]; len(z) != 1 || z[0].Field(2) != "audit-1" || z[0].Field(1) != "" {
+		t.Fatalf("ZAU = %+v", z)
+	}
+
+	for _, field := range []string{"MSA-1", "MSA-2", "MSH-10", "PID-3", "msa-3", "ZAU"} {
+		if _, err := NewServer(Config{AuditIDField: field}); err == nil {
+			t.Errorf("audit ID field %s accepted", field)
+		}
+	}
+}
+
+func TestHandle_Rejections(t *testing.T) {
+	s, store := newTestServer(t, Config{})
+	for _, tc := range []struct {
+		name     string
+		raw      []byte
+		code     string
+		errCodes []ErrorCode
+		location string
+	}{
+		{"unmappable", sample(t, "unmappable"), AckError, []ErrorCode{DataTypeError, SegmentSequenceError, DataTypeError, TableValueNotFound, RequiredFieldMissing, RequiredFieldMissing, RequiredFieldMissing}, "PID^1^7"},
+		{"unsupported", sample(t, "unsupported"), AckReject, []ErrorCode{UnsupportedEventCode}, "MSH^1^9"},
+		{"not hl7", []byte("hello"), AckReject, []ErrorCode{SegmentSequenceError}, "MSH"},
+		// No complaint in PV2-3 or the config: the intake fails validation.
+		{"invalid intake", sample(t, "rxa"), AckError, []ErrorCode{DataTypeError}, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			segs := ackSegments(t, s.Handle(t.Context(), tc.raw))
+			if got := segs["MSA"][0].Field(1); got != tc.code {
+				t.Fatalf("MSA-1 = %s, want %s", got, tc.code)
+			}
+			errs := segs["ERR"]
+			if len(errs) != len(tc.errCodes) {
+				t.Fatalf("ERR segments = %d, want %d", len(errs), len(tc.errCodes))
+			}
+			for i, e := range errs {
+				if got := ErrorCode(e.Component(3, 1)); got != tc.errCodes[i] || e.Component(3, 3) != "HL70357" || e.Field(8) == "" {
+					t.Errorf("ERR %d = %q", i+1, e.fields)
+				}
+			}
+			if got := errs[0].Raw(2); got != tc.location {
+				t.Errorf("ERR-2 = %q, want %q", got, tc.location)
+			}
+		})
+	}
+	if latest, _ := store.Latest(t.Context(), 10); len(latest) != 0 {
+		t.Fatalf("rejected messages were audited: %+v", latest)
+	}
+}
+
+func TestServe(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(ctx, ln) }()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for _, name := range []string{"adt-a04", "unsupported"} {
+		if _, err := conn.Write(frame(sample(t, name))); err != nil {
+			t.Fatal(err)
+		}
+		ack, err := readFrame(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(ack), "MSH|") || !strings.Contains(string(ack), "\rMSA|A") {
+			t.Fatalf("%s: ACK %q", name, ack)
+		}
+	}
+
+	cancel()
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatalf("Serve = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after shutdown")
+	}
+	if _, err := readFrame(r); err == nil {
+		t.Fatal("connection left open after shutdown")
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("noise\x0bMSH|A\x1c\r\x0bMSH|B\x1cX"))
+	if msg, err := readFrame(r); err != nil || string(msg) != "MSH|A" {
+		t.Fatalf("first frame = %q, %v", msg, err)
+	}
+	if _, err := readFrame(r); err == nil {
+		t.Fatal("frame without trailing carriage return accepted")
+	}
+}
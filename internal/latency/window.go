@@ -0,0 +1,110 @@
+// Package latency keeps rolling windows of recent durations and reads
+// percentiles from them.
+package latency
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultSize is the number of samples a Window keeps when built with a size
+// of zero or less.
+const DefaultSize = 1000
+
+// Window is a ring buffer of the most recent durations, each stamped with
+// when it was observed. Reads consider only the samples no older than the
+// window's max age, so a quiet spell does not keep an old slow burst in view.
+// It is safe for concurrent use.
+type Window struct {
+	maxAge time.Duration
+
+	mu      sync.Mutex
+	samples []sample
+	// next is where the following sample goes once samples is full.
+	next int
+}
+
+type sample struct {
+	at time.Time
+	d  time.Duration
+}
+
+// Summary describes a window's samples at one moment.
+type Summary struct {
+	Count int
+	P50   time.Duration
+	P95   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// NewWindow returns a Window of the last size samples. A maxAge of zero
+// keeps samples until they are overwritten.
+func NewWindow(size int, maxAge time.Duration) *Window {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	return &Window{maxAge: maxAge, samples: make([]sample, 0, size)}
+}
+
+// Add records d as observed at at, overwriting the oldest sample when the
+// window is full.
+func (w *Window) Add(at time.Time, d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.samples) < cap(w.samples) {
+		w.samples = append(w.samples, sample{at, d})
+		return
+	}
+	w.samples[w.next] = sample{at, d}
+	w.next = (w.next + 1) % len(w.samples)
+}
+
+// Percentile returns the nearest-rank p-th percentile, 0 < p <= 100, of the
+// samples current at now and how many there are; zero and zero when there
+// are none.
+func (w *Window) Percentile(now time.Time, p float64) (time.Duration, int) {
+	ds := w.current(now)
+	if len(ds) == 0 {
+		return 0, 0
+	}
+	return rank(ds, p), len(ds)
+}
+
+// Summary returns the count, median, p95, p99, and maximum of the samples
+// current at now; all zero when there are none.
+func (w *Window) Summary(now time.Time) Summary {
+	ds := w.current(now)
+	if len(ds) == 0 {
+		return Summary{}
+	}
+	return Summary{
+		Count: len(ds),
+		P50:   rank(ds, 50),
+		P95:   rank(ds, 95),
+		P99:   rank(ds, 99),
+		Max:   ds[len(ds)-1],
+	}
+}
+
+// current returns the durations observed within maxAge of now, sorted.
+func (w *Window) current(now time.Time) []time.Duration {
+	w.mu.Lock()
+	out := make([]time.Duration, 0, len(w.samples))
+	for _, s := range w.samples {
+		if w.maxAge <= 0 || now.Sub(s.at) <= w.maxAge {
+			out = append(out, s.d)
+		}
+	}
+	w.mu.Unlock()
+	slices.Sort(out)
+	return out
+}
+
+// rank returns the nearest-rank p-th percentile of sorted ds.
+func rank(ds []time.Duration, p float64) time.Duration {
+	r := int(math.Ceil(p / 100 * float64(len(ds))))
+	return ds[min(max(r, 1), len(ds))-1]
+}
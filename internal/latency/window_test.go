@@ -0,0 +1,82 @@
+package latency
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWindow_Percentile(t *testing.T) {
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	w := NewWindow(100, 0)
+	if _, n := w.Percentile(now, 95); n != 0 {
+		t.Fatal("empty window reported a percentile")
+	}
+	// 1ms..100ms, shuffled by stride so the order does not help.
+	for i := range 100 {
+		w.Add(now, time.Duration(i*37%100+1)*time.Millisecond)
+	}
+	for _, c := range []struct {
+		p    float64
+		want time.Duration
+	}{
+		{50, 50 * time.Millisecond},
+		{95, 95 * time.Millisecond},
+		{99.5, 100 * time.Millisecond},
+		{100, 100 * time.Millisecond},
+		{0.1, time.Millisecond},
+	} {
+		if got, n := w.Percentile(now, c.p); n != 100 || got != c.want {
+			t.Errorf("p%v = %v, want %v", c.p, got, c.want)
+		}
+	}
+	if s := w.Summary(now); s.Count != 100 || s.P50 != 50*time.Millisecond || s.P99 != 99*time.Millisecond || s.Max != 100*time.Millisecond {
+		t.Fatalf("summary = %+v", s)
+	}
+}
+
+func TestWindow_Overwrites(t *testing.T) {
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	w := NewWindow(3, 0)
+	for _, ms := range []int{900, 800, 700, 1, 2} {
+		w.Add(now, time.Duration(ms)*time.Millisecond)
+	}
+	// 900 and 800 were overwritten by the last two samples.
+	if s := w.Summary(now); s.Count != 3 || s.Max != 700*time.Millisecond || s.P50 != 2*time.Millisecond {
+		t.Fatalf("summary = %+v", s)
+	}
+}
+
+func TestWindow_MaxAge(t *testing.T) {
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	w := NewWindow(10, time.Minute)
+	w.Add(start, 2*time.Second)
+	w.Add(start.Add(50*time.Second), 10*time.Millisecond)
+	if got, _ := w.Percentile(start.Add(55*time.Second), 100); got != 2*time.Second {
+		t.Fatalf("within the minute p100 = %v", got)
+	}
+	if got, _ := w.Percentile(start.Add(90*time.Second), 100); got != 10*time.Millisecond {
+		t.Fatalf("after the slow sample aged out p100 = %v", got)
+	}
+	if s := w.Summary(start.Add(time.Hour)); s != (Summary{}) {
+		t.Fatalf("all aged out = %+v", s)
+	}
+}
+
+func TestWindow_Concurrent(t *testing.T) {
+	now := time.Now()
+	w := NewWindow(0, 0)
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			for i := range 500 {
+				w.Add(now, time.Duration(i)*time.Microsecond)
+				w.Percentile(now, 95)
+			}
+		})
+	}
+	wg.Wait()
+	if s := w.Summary(now); s.Count != DefaultSize {
+		t.Fatalf("count = %d, want %d", s.Count, DefaultSize)
+	}
+}
@@ -0,0 +1,324 @@
+// Package openai scores analysis plans with an OpenAI-compatible chat completions endpoint.
+package openai
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+//go:embed schema/score.schema.json
+var scoreSchema []byte
+
+const (
+	DefaultBaseURL    = "https://api.openai.com/v1"
+	DefaultModel      = "gpt-4o-mini"
+	defaultMaxRetries = 3
+	defaultBackoff    = 500 * time.Millisecond
+)
+
+// Config configures the provider; BaseURL and Model fall back to the defaults.
+type Config struct {
+	BaseURL    string
+	APIKey     string
+	Model      string
+	MaxRetries int
+	// Backoff is the base delay before the first retry; it doubles per attempt with jitter.
+	Backoff    time.Duration
+	HTTPClient *http.Client
+}
+
+// Client implements analysis.LLMClient.
+type Client struct {
+	cfg    Config
+	schema *gojsonschema.Schema
+}
+
+// New builds a Client, validating the configuration and compiling the output schema.
+func New(cfg Config) (*Client, error) {
+	if strings.TrimSpace(cfg.APIKey) == "" {
+		return nil, errors.New("openai: api key is required")
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DefaultBaseURL
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	if cfg.Model == "" {
+		cfg.Model = DefaultModel
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	} else if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = defaultMaxRetries
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = defaultBackoff
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{}
+	}
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(scoreSchema))
+	if err != nil {
+		return nil, fmt.Errorf("openai: compile score schema: %w", err)
+	}
+	return &Client{cfg: cfg, schema: schema}, nil
+}
+
+// promptCase is the de-identified case sent to the model. It deliberately omits
+// patientName and userId; the remaining fields are exactly what the clinician entered.
+type promptCase struct {
+	Age          int                       `json:"age"`
+	WeightKg     float64                   `json:"weightKg"`
+	HeightCm     float64                   `json:"heightCm"`
+	BP           string                    `json:"bp"`
+	BMI          float64                   `json:"bmi,omitempty"`
+	Conditions   []string                  `json:"conditions"`
+	Family       []string                  `json:"familyHistory,omitempty"`
+	Allergies    []string                  `json:"allergies"`
+	Medications  []analysis.Medication     `json:"medications"`
+	Smoking      string                    `json:"smoking,omitempty"`
+	PackYears    float64                   `json:"smokingPackYears,omitempty"`
+	QuitYearsAgo *float64                  `json:"smokingQuitYearsAgo,omitempty"`
+	Alcohol      string                    `json:"alcohol,omitempty"`
+	Drinks       *float64                  `json:"alcoholDrinksPerWeek,omitempty"`
+	Exercise     string                    `json:"exercise,omitempty"`
+	SleepHours   float64                   `json:"sleepHours,omitempty"`
+	WaistCm      float64                   `json:"waistCircumferenceCm,omitempty"`
+	Labs         *analysis.Labs            `json:"labs,omitempty"`
+	Complaint    string                    `json:"complaint"`
+	Complaints   []string                  `json:"complaints,omitempty"`
+	Severity     string                    `json:"complaintSeverity,omitempty"`
+	Duration     int                       `json:"complaintDurationWeeks,omitempty"`
+	Prior        []analysis.PriorTreatment `json:"priorTreatments,omitempty"`
+	RiskLevel    string                    `json:"riskLevel"`
+	RiskScore    int                       `json:"riskScore"`
+	Issues       []analysis.Issue          `json:"flaggedIssues"`
+	Plan         analysis.Plan             `json:"recommendedPlan"`
+	Alternatives []analysis.Alternative    `json:"alternatives"`
+}
+
+func buildCase(req analysis.ScoreRequest) promptCase {
+	in := req.Intake
+	return promptCase{
+		Age:          in.Age,
+		WeightKg:     in.WeightKg,
+		HeightCm:     in.HeightCm,
+		BP:           in.BP,
+		BMI:          in.BMI,
+		Conditions:   in.Conditions,
+		Family:       in.FamilyHistory,
+		Allergies:    in.Allergies,
+		Medications:  in.Medications,
+		Smoking:      in.Smoking,
+		PackYears:    in.SmokingPackYears,
+		QuitYearsAgo: in.SmokingQuitYearsAgo,
+		Alcohol:      in.Alcohol,
+		Drinks:       in.AlcoholDrinksPerWeek,
+		Exercise:     in.Exercise,
+		SleepHours:   in.SleepHours,
+		WaistCm:      in.WaistCircumferenceCm,
+		Labs:         in.Labs,
+		Complaint:    in.Complaint,
+		Complaints:   in.Complaints,
+		Severity:     in.ComplaintSeverity,
+		Duration:     in.ComplaintDurationWeeks,
+		Prior:        in.PriorTreatments,
+		RiskLevel:    string(req.RiskLevel),
+		RiskScore:    req.RiskScore,
+		Issues:       req.Issues,
+		Plan:         req.Plan,
+		Alternatives: req.Alternatives,
+	}
+}
+
+const scoringInstruction = `Score the deterministic plan below. Respond with a JSON object only:
+{"planConfidence": number 0-1, "alternativeConfidence": [number 0-1 per alternative, same order], "rationale": string}.`
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model          string         `json:"model"`
+	Messages       []chatMessage  `json:"messages"`
+	ResponseFormat map[string]any `json:"response_format"`
+	Temperature    float64        `json:"temperature"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+type scorePayload struct {
+	PlanConfidence        float64   `json:"planConfidence"`
+	AlternativeConfidence []float64 `json:"alternativeConfidence"`
+	Rationale             string    `json:"rationale"`
+}
+
+// Score sends the caller's system prompt and the de-identified case to the model.
+func (c *Client) Score(ctx context.Context, req analysis.ScoreRequest) (analysis.LLMResult, error) {
+	caseJSON, err := json.Marshal(buildCase(req))
+	if err != nil {
+		return analysis.LLMResult{}, fmt.Errorf("openai: marshal case: %w", err)
+	}
+	system := req.SystemPrompt
+	if system == "" {
+		system = analysis.SystemPrompt()
+	}
+	body, err := json.Marshal(chatRequest{
+		Model: c.cfg.Model,
+		Messages: []chatMessage{
+			{Role: "system", Content: system},
+			{Role: "user", Content: scoringInstruction + "\n\n" + string(caseJSON)},
+		},
+		ResponseFormat: map[string]any{"type": "json_object"},
+	})
+	if err != nil {
+		return analysis.LLMResult{}, fmt.Errorf("openai: marshal request: %w", err)
+	}
+
+	start := time.Now()
+	chat, err := c.send(ctx, body)
+	if err != nil {
+		return analysis.LLMResult{}, err
+	}
+	latency := time.Since(start)
+
+	if len(chat.Choices) == 0 {
+		return analysis.LLMResult{}, errors.New("openai: response has no choices")
+	}
+	payload, err := c.parsePayload(chat.Choices[0].Message.Content)
+	if err != nil {
+		return analysis.LLMResult{}, err
+	}
+	return analysis.LLMResult{
+		PlanConfidence:  payload.PlanConfidence,
+		AlternativeConf: payload.AlternativeConfidence,
+		Usage: audit.LLMUsage{
+			Model:            c.cfg.Model,
+			PromptTokens:     chat.Usage.PromptTokens,
+			CompletionTokens: chat.Usage.CompletionTokens,
+			LatencyMs:        latency.Milliseconds(),
+		},
+	}, nil
+}
+
+// parsePayload validates the model output against the score schema before decoding it.
+func (c *Client) parsePayload(content string) (scorePayload, error) {
+	result, err := c.schema.Validate(gojsonschema.NewStringLoader(content))
+	if err != nil {
+		return scorePayload{}, fmt.Errorf("openai: output is not valid JSON: %w", err)
+	}
+	if !result.Valid() {
+		msgs := make([]string, 0, len(result.Errors()))
+		for _, e := range result.Errors() {
+			msgs = append(msgs, e.String())
+		}
+		return scorePayload{}, fmt.Errorf("openai: output failed schema: %s", strings.Join(msgs, "; "))
+	}
+	var p scorePayload
+	if err := json.Unmarshal([]byte(content), &p); err != nil {
+		return scorePayload{}, fmt.Errorf("openai: decode output: %w", err)
+	}
+	return p, nil
+}
+
+// statusError is returned for non-2xx responses.
+type statusError struct {
+	Status     int
+	RetryAfter time.Duration
+	Body       string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("openai: unexpected status %d: %s", e.Status, e.Body)
+}
+
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// send posts to /chat/completions, retrying 429 and 5xx responses with jittered backoff.
+func (c *Client) send(ctx context.Context, body []byte) (chatResponse, error) {
+	var lastErr error
+	for attempt := 0; attempt <= c.cfg.MaxRetries; attempt++ {
+		if attempt > 0 {
+			delay := c.backoff(attempt)
+			var se *statusError
+			if errors.As(lastErr, &se) && se.RetryAfter > delay {
+				delay = se.RetryAfter
+			}
+			select {
+			case <-ctx.Done():
+				return chatResponse{}, ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+
+		resp, err := c.do(ctx, body)
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+		var se *statusError
+		if !errors.As(err, &se) || !retryable(se.Status) {
+			return chatResponse{}, err
+		}
+	}
+	return chatResponse{}, fmt.Errorf("openai: retries exhausted: %w", lastErr)
+}
+
+func (c *Client) backoff(attempt int) time.Duration {
+	base := c.cfg.Backoff << (attempt - 1)
+	return base + time.Duration(rand.Int63n(int64(base)))
+}
+
+func (c *Client) do(ctx context.Context, body []byte) (chatResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.BaseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return chatResponse{}, fmt.Errorf("openai: build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.cfg.APIKey)
+
+	resp, err := c.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return chatResponse{}, fmt.Errorf("openai: request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		se := &statusError{Status: resp.StatusCode, Body: strings.TrimSpace(string(snippet))}
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+			se.RetryAfter = time.Duration(secs) * time.Second
+		}
+		return chatResponse{}, se
+	}
+
+	var out chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return chatResponse{}, fmt.Errorf("openai: decode response: %w", err)
+	}
+	return out, nil
+}
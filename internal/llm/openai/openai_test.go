@@ -0,0 +1,161 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+)
+
+func scoreRequest() analysis.ScoreRequest {
+	return analysis.ScoreRequest{
+		Intake: analysis.Intake{
+			PatientName: "Juan Dela Cruz",
+			UserID:      "dr-1",
+			Age:         45,
+			BP:          "135/88",
+			Complaint:   "ED",
+		},
+		Plan:         analysis.Plan{Medication: "Tadalafil", Dosage: "10mg"},
+		Alternatives: []analysis.Alternative{{Medication: "Sildenafil"}, {Medication: "Tadalafil (daily)"}},
+		RiskScore:    3,
+		RiskLevel:    "LOW",
+	}
+}
+
+func completion(content string) string {
+	body, _ := json.Marshal(map[string]any{
+		"choices": []map[string]any{{"message": map[string]string{"role": "assistant", "content": content}}},
+		"usage":   map[string]int{"prompt_tokens": 120, "completion_tokens": 30},
+	})
+	return string(body)
+}
+
+func newTestClient(t *testing.T, url string) *Client {
+	t.Helper()
+	c, err := New(Config{BaseURL: url, APIKey: "test-key", Model: "test-model", Backoff: time.Millisecond})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	return c
+}
+
+func TestScore_SendsDeidentifiedPromptAndParsesOutput(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer test-key" {
+			t.Errorf("unexpected auth header %q", got)
+		}
+		raw, _ := io.ReadAll(r.Body)
+		body := string(raw)
+		if strings.Contains(body, "Juan") || strings.Contains(body, "dr-1") {
+			t.Errorf("request must not contain patient name or user id: %s", body)
+		}
+		if !strings.Contains(body, `"json_object"`) {
+			t.Errorf("expected JSON response format in request")
+		}
+		if !strings.Contains(body, "clinical decision support assistant") {
+			t.Errorf("expected system prompt in request")
+		}
+		_, _ = io.WriteString(w, completion(`{"planConfidence":0.82,"alternativeConfidence":[0.7,0.65],"rationale":"ok"}`))
+	}))
+	defer srv.Close()
+
+	res, err := newTestClient(t, srv.URL).Score(context.Background(), scoreRequest())
+	if err != nil {
+		t.Fatalf("score: %v", err)
+	}
+	if res.PlanConfidence != 0.82 || len(res.AlternativeConf) != 2 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if res.Usage.PromptTokens != 120 || res.Usage.CompletionTokens != 30 || res.Usage.Model != "test-model" {
+		t.Fatalf("unexpected usage %+v", res.Usage)
+	}
+}
+
+func TestScore_RetriesRateLimitAndServerErrors(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			_, _ = io.WriteString(w, completion(`{"planConfidence":0.7,"alternativeConfidence":[0.6,0.5]}`))
+		}
+	}))
+	defer srv.Close()
+
+	if _, err := newTestClient(t, srv.URL).Score(context.Background(), scoreRequest()); err != nil {
+		t.Fatalf("expected success after retries, got %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 attempts, got %d", calls)
+	}
+}
+
+func TestScore_StopsAfterBoundedRetries(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	if _, err := newTestClient(t, srv.URL).Score(context.Background(), scoreRequest()); err == nil {
+		t.Fatalf("expected error after exhausting retries")
+	}
+	if calls != defaultMaxRetries+1 {
+		t.Fatalf("expected %d attempts, got %d", defaultMaxRetries+1, calls)
+	}
+}
+
+func TestScore_DoesNotRetryClientErrors(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	if _, err := newTestClient(t, srv.URL).Score(context.Background(), scoreRequest()); err == nil {
+		t.Fatalf("expected error for 401")
+	}
+	if calls != 1 {
+		t.Fatalf("expected a single attempt, got %d", calls)
+	}
+}
+
+func TestScore_RejectsOutputFailingSchema(t *testing.T) {
+	for name, content := range map[string]string{
+		"out of range": `{"planConfidence":1.4,"alternativeConfidence":[0.6,0.5]}`,
+		"missing":      `{"rationale":"no scores"}`,
+		"not json":     `I think 0.8`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.WriteString(w, completion(content))
+			}))
+			defer srv.Close()
+			if _, err := newTestClient(t, srv.URL).Score(context.Background(), scoreRequest()); err == nil {
+				t.Fatalf("expected schema rejection for %s", content)
+			}
+		})
+	}
+}
+
+func TestNew_RequiresAPIKey(t *testing.T) {
+	if _, err := New(Config{}); err == nil {
+		t.Fatalf("expected error without api key")
+	}
+}
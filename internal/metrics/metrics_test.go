@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteTo_PrometheusFormat(t *testing.T) {
+	c := NewCounter("test_requests_total", "Test requests.", "code")
+	c.Inc("200")
+	c.Add(2, "500")
+	h := NewHistogram("test_latency_seconds", "Test latency.", []float64{0.1, 1})
+	h.Observe(0.05)
+	h.Observe(0.5)
+	g := NewGaugeVec("test_p95_seconds", "Test percentile.", "route")
+	g.Set(0.4, "/a")
+	g.Set(0.2, "/a")
+
+	var b strings.Builder
+	WriteTo(&b)
+	out := b.String()
+	for _, want := range []string{
+		"# TYPE test_requests_total counter",
+		`test_requests_total{code="200"} 1`,
+		`test_requests_total{code="500"} 2`,
+		`test_latency_seconds_bucket{le="0.1"} 1`,
+		`test_latency_seconds_bucket{le="1"} 2`,
+		`test_latency_seconds_bucket{le="+Inf"} 2`,
+		"test_latency_seconds_count 2",
+		"# TYPE test_p95_seconds gauge",
+		`test_p95_seconds{route="/a"} 0.2`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output:\n%s", want, out)
+		}
+	}
+	if c.Value("500") != 2 || h.Count() != 2 || g.Value("/a") != 0.2 {
+		t.Fatalf("unexpected values: counter=%v histogram=%d gauge=%v", c.Value("500"), h.Count(), g.Value("/a"))
+	}
+}
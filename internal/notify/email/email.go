@@ -0,0 +1,149 @@
+// Package email sends danger notifications over SMTP.
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	_ "embed"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+)
+
+//go:embed message.tmpl
+var messageTemplate string
+
+var message = template.Must(template.New("message").Parse(messageTemplate))
+
+const DefaultPort = 587
+
+// Config configures the notifier; Port falls back to DefaultPort. Username
+// and Password authenticate with PLAIN when Username is set, which net/smtp
+// only allows over TLS or to localhost.
+type Config struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// Notifier implements analysis.Notifier by mailing each event to the
+// configured recipients. The message names the audit ID, risk level, and
+// danger issue codes only.
+type Notifier struct {
+	cfg Config
+}
+
+// New builds a Notifier, validating the configuration.
+func New(cfg Config) (*Notifier, error) {
+	cfg.Host = strings.TrimSpace(cfg.Host)
+	if cfg.Host == "" {
+		return nil, errors.New("email: host is required")
+	}
+	if cfg.Port == 0 {
+		cfg.Port = DefaultPort
+	}
+	if cfg.Port < 0 || cfg.Port > 65535 {
+		return nil, fmt.Errorf("email: port %d out of range", cfg.Port)
+	}
+	if strings.TrimSpace(cfg.From) == "" {
+		return nil, errors.New("email: from address is required")
+	}
+	var to []string
+	for _, addr := range cfg.To {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			to = append(to, addr)
+		}
+	}
+	if len(to) == 0 {
+		return nil, errors.New("email: at least one recipient is required")
+	}
+	cfg.To = to
+	return &Notifier{cfg: cfg}, nil
+}
+
+// Notify mails e. It upgrades to TLS when the server offers STARTTLS and
+// gives up when ctx is done.
+func (n *Notifier) Notify(ctx context.Context, e analysis.Event) error {
+	msg, err := n.render(e)
+	if err != nil {
+		return err
+	}
+	addr := net.JoinHostPort(n.cfg.Host, strconv.Itoa(n.cfg.Port))
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("email: dial %s: %w", addr, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	// Closing the connection unblocks the exchange when ctx is cancelled.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, n.cfg.Host)
+	if err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: n.cfg.Host}); err != nil {
+			return fmt.Errorf("email: starttls: %w", err)
+		}
+	}
+	if n.cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", n.cfg.Username, n.cfg.Password, n.cfg.Host)); err != nil {
+			return fmt.Errorf("email: auth: %w", err)
+		}
+	}
+	if err := c.Mail(n.cfg.From); err != nil {
+		return fmt.Errorf("email: mail from: %w", err)
+	}
+	for _, to := range n.cfg.To {
+		if err := c.Rcpt(to); err != nil {
+			return fmt.Errorf("email: rcpt %s: %w", to, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("email: data: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("email: write: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("email: send: %w", err)
+	}
+	return c.Quit()
+}
+
+// render builds the message: the headers, then the template's Subject line
+// and body, with CRLF line endings.
+func (n *Notifier) render(e analysis.Event) ([]byte, error) {
+	var out bytes.Buffer
+	if err := message.Execute(&out, e); err != nil {
+		return nil, fmt.Errorf("email: render: %w", err)
+	}
+	subject, body, _ := strings.Cut(out.String(), "\n")
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", n.cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.TrimPrefix(subject, "Subject: ")))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return []byte(msg.String()), nil
+}
@@ -0,0 +1,199 @@
+package email
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+)
+
+// fakeSMTP accepts one session on a local port and returns what the client
+// sent: the envelope commands and the message.
+func fakeSMTP(t *testing.T) (int, <-chan []string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	got := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		var lines []string
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			lines = append(lines, line)
+			switch cmd := strings.ToUpper(strings.Fields(line + " x")[0]); cmd {
+			case "EHLO":
+				reply("250-localhost\r\n250 AUTH PLAIN")
+			case "AUTH":
+				reply("235 ok")
+			case "DATA":
+				reply("354 go ahead")
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					l = strings.TrimRight(l, "\r\n")
+					if l == "." {
+						break
+					}
+					lines = append(lines, l)
+				}
+				reply("250 queued")
+			case "QUIT":
+				reply("221 bye")
+				got <- lines
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, got
+}
+
+func TestNotify(t *testing.T) {
+	port, got := fakeSMTP(t)
+	n, err := New(Config{Host: "localhost", Port: port, Username: "alerts", Password: "secret", From: "alerts@clinic.example", To: []string{"oncall@clinic.example", " ", "lead@clinic.example"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := analysis.Event{
+		AuditID:        "audit-123",
+		At:             time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC),
+		RiskLevel:      analysis.RiskHigh,
+		RiskScore:      9,
+		DangerCodes:    []string{"CI_NITRATE_PDE5", "BP_STAGE2"},
+		RulesetVersion: "rules-1",
+	}
+	if err := n.Notify(t.Context(), e); err != nil {
+		t.Fatal(err)
+	}
+	session := strings.Join(<-got, "\n")
+	for _, want := range []string{
+		"AUTH PLAIN",
+		"MAIL FROM:<alerts@clinic.example>",
+		"RCPT TO:<oncall@clinic.example>",
+		"RCPT TO:<lead@clinic.example>",
+		"Subject: [HIGH] Danger flag on analysis audit-123",
+		"Audit ID:  audit-123",
+		"Risk:      HIGH (9)",
+		"  - CI_NITRATE_PDE5",
+		"  - BP_STAGE2",
+	} {
+		if !strings.Contains(session, want) {
+			t.Errorf("session lacks %q:\n%s", want, session)
+		}
+	}
+	if strings.Contains(session, "Org:") {
+		t.Errorf("org line without an org:\n%s", session)
+	}
+}
+
+func TestRender_ReminderOverdue(t *testing.T) {
+	n, err := New(Config{Host: "localhost", Port: 25, From: "alerts@clinic.example", To: []string{"oncall@clinic.example"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := n.render(analysis.Event{
+		Kind:       analysis.EventReminderOverdue,
+		AuditID:    "audit-123",
+		Org:        "clinic-a",
+		ReminderID: "rem-7",
+		DueAt:      time.Date(2026, 3, 29, 9, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Subject: Follow-up overdue for analysis audit-123\r\n",
+		"came due on 2026-03-29",
+		"Org:       clinic-a",
+		"Reminder:  rem-7",
+	} {
+		if !strings.Contains(string(msg), want) {
+			t.Errorf("message lacks %q:\n%s", want, msg)
+		}
+	}
+	if strings.Contains(string(msg), "Danger flag") {
+		t.Errorf("reminder rendered as a danger flag:\n%s", msg)
+	}
+}
+
+func TestRender_SLOBreach(t *testing.T) {
+	n, err := New(Config{Host: "localhost", Port: 25, From: "alerts@clinic.example", To: []string{"oncall@clinic.example"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := n.render(analysis.Event{
+		Kind: analysis.EventSLOBreach,
+		At:   time.Date(2026, 3, 29, 9, 5, 0, 0, time.UTC),
+		SLO: &analysis.SLOBreach{
+			Scope: analysis.LatencyComplaint, Key: "ed", Percentile: 95, ThresholdMs: 500, ObservedMs: 812.5, Samples: 40,
+			Since: time.Date(2026, 3, 29, 9, 0, 0, 0, time.UTC),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Subject: [SLO] Analysis latency over objective (complaint ed)\r\n",
+		"operational alert, not a clinical one",
+		"Observed:  p95 812.5ms over 40 analyses",
+		"Objective: p95 at or under 500ms",
+		"since 2026-03-29 09:00:00 UTC",
+	} {
+		if !strings.Contains(string(msg), want) {
+			t.Errorf("message lacks %q:\n%s", want, msg)
+		}
+	}
+	if strings.Contains(string(msg), "Danger flag") {
+		t.Errorf("SLO breach rendered as a danger flag:\n%s", msg)
+	}
+}
+
+func TestNotify_Unreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	n, err := New(Config{Host: "127.0.0.1", Port: port, From: "a@b", To: []string{"c@d"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(t.Context(), analysis.Event{AuditID: "x"}); err == nil || !strings.Contains(err.Error(), "127.0.0.1:"+strconv.Itoa(port)) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, cfg := range []Config{
+		{Port: 25, From: "a@b", To: []string{"c@d"}},
+		{Host: "mail", Port: 70000, From: "a@b", To: []string{"c@d"}},
+		{Host: "mail", To: []string{"c@d"}},
+		{Host: "mail", From: "a@b", To: []string{" "}},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("New(%+v) accepted", cfg)
+		}
+	}
+}
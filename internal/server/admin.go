@@ -0,0 +1,221 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+// maxRulesBytes bounds a ruleset document.
+const maxRulesBytes = 1 << 20
+
+// adminActor is recorded as the actor of changes made with the admin token,
+// which names no individual.
+const adminActor = "admin"
+
+// requireAdmin reports whether r carries the admin bearer token. It answers
+// 403 when no token is configured and 401 for a missing or wrong one.
+func (s *server) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if s.adminToken == "" {
+		writeError(w, r, http.StatusForbidden, "admin role is not configured")
+		return false
+	}
+	if !s.isAdmin(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		writeError(w, r, http.StatusUnauthorized, "admin bearer token required")
+		return false
+	}
+	return true
+}
+
+// isAdmin reports whether r carries the configured admin bearer token.
+func (s *server) isAdmin(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && s.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) == 1
+}
+
+// ruleTrace reports whether r asks for ?debug=true and may see the rule
+// trace: callers with the admin token, or clinicians authenticated by an
+// org API key. Other debug requests get the timings without it.
+func (s *server) ruleTrace(r *http.Request) bool {
+	if r.URL.Query().Get("debug") != "true" {
+		return false
+	}
+	return s.isAdmin(r) || audit.OrgFrom(r.Context()) != ""
+}
+
+func (s *server) handleRules(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodGet, http.MethodPut) || !s.requireAdmin(w, r) {
+		return
+	}
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, s.a.Rules())
+		return
+	}
+
+	var rules analysis.Ruleset
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRulesBytes)).Decode(&rules); err != nil {
+		writeInvalidPayload(w, r, err)
+		return
+	}
+	info, err := s.a.ReplaceRules(r.Context(), rules, s.rulesPath, adminActor)
+	var invalid *analysis.RulesetError
+	switch {
+	case errors.As(err, &invalid):
+		writeValidation(w, r, invalid.Errors)
+		return
+	case errors.Is(err, analysis.ErrRulesAuditUnsupported):
+		writeError(w, r, http.StatusNotImplemented, "ruleset changes cannot be audited by this store")
+		return
+	case err != nil:
+		log.Printf("rules replace failed: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "ruleset not replaced")
+		return
+	}
+	log.Printf("rules replaced version=%s source=%s ruleset_version=%s request_id=%s", info.Version, info.Source, s.a.RulesetVersion(), requestID(r.Context()))
+	writeJSON(w, http.StatusOK, info)
+}
+
+// handleAdminAudit returns a stored audit with its redacted intake. Intakes
+// are only served here, behind the admin token.
+func (s *server) handleAdminAudit(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodGet) || !s.requireAdmin(w, r) {
+		return
+	}
+	rec, err := s.a.AuditRecord(r.Context(), r.PathValue("id"))
+	switch {
+	case errors.Is(err, audit.ErrNotFound):
+		writeError(w, r, http.StatusNotFound, "audit not found")
+		return
+	case errors.Is(err, analysis.ErrResponsesUnsupported):
+		writeError(w, r, http.StatusNotImplemented, "audit responses are not kept by this store")
+		return
+	case err != nil:
+		log.Printf("admin audit lookup failed: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "audit unavailable")
+		return
+	}
+	writeJSON(w, http.StatusOK, rec)
+}
+
+// handleDeleteAudit soft-deletes an audit. Hard deletion is not offered.
+func (s *server) handleDeleteAudit(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodPost) || !s.requireAdmin(w, r) {
+		return
+	}
+	var req analysis.DeleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeInvalidPayload(w, r, err)
+		return
+	}
+	if req.UserID == "" {
+		req.UserID = adminActor
+	}
+	d, err := s.a.DeleteAudit(r.Context(), r.PathValue("id"), req)
+	if errors.Is(err, analysis.ErrDeletionReasonRequired) {
+		writeValidation(w, r, []string{err.Error()})
+		return
+	}
+	writeDeletion(w, r, d, err, audit.ErrAlreadyDeleted, "audit already deleted")
+}
+
+// handleRestoreAudit undoes a soft deletion.
+func (s *server) handleRestoreAudit(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodPost) || !s.requireAdmin(w, r) {
+		return
+	}
+	d, err := s.a.RestoreAudit(r.Context(), r.PathValue("id"))
+	writeDeletion(w, r, d, err, audit.ErrNotDeleted, "audit is not deleted")
+}
+
+// writeDeletion answers a delete or restore; conflict is the error the
+// action reports for an audit already in the state it asks for.
+func writeDeletion(w http.ResponseWriter, r *http.Request, d analysis.AuditDeletion, err, conflict error, msg string) {
+	switch {
+	case errors.Is(err, audit.ErrNotFound):
+		writeError(w, r, http.StatusNotFound, "audit not found")
+		return
+	case errors.Is(err, conflict):
+		writeError(w, r, http.StatusConflict, msg)
+		return
+	case errors.Is(err, analysis.ErrSoftDeleteUnsupported):
+		writeError(w, r, http.StatusNotImplemented, "audit soft deletion unavailable")
+		return
+	case err != nil:
+		log.Printf("audit deletion failed: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "audit not changed")
+		return
+	}
+	log.Printf("audit %s audit_id=%s seq=%d user=%s request_id=%s", d.Action, d.AuditID, d.Seq, d.UserID, requestID(r.Context()))
+	writeJSON(w, http.StatusOK, d)
+}
+
+// handleBackup snapshots the audit store into the configured backup
+// directory without stopping the service.
+func (s *server) handleBackup(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodPost) || !s.requireAdmin(w, r) {
+		return
+	}
+	if s.backupDir == "" {
+		writeError(w, r, http.StatusNotImplemented, "backup directory is not configured")
+		return
+	}
+	info, err := s.a.BackupAuditStore(r.Context(), s.backupDir, s.backupKeep)
+	switch {
+	case errors.Is(err, analysis.ErrBackupUnsupported):
+		writeError(w, r, http.StatusNotImplemented, "audit store cannot be backed up")
+		return
+	case err != nil && info.Path == "":
+		log.Printf("audit backup failed: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "backup failed")
+		return
+	case err != nil:
+		// The snapshot was written; only pruning older ones failed.
+		log.Printf("audit backup path=%s written but pruning failed: %v", info.Path, err)
+	}
+	log.Printf("audit backup path=%s size=%d pruned=%d request_id=%s", info.Path, info.SizeBytes, len(info.Pruned), requestID(r.Context()))
+	writeJSON(w, http.StatusOK, info)
+}
+
+// handleBundle exports the deployment's ruleset, prompt, catalogs, and
+// non-secret config as a signed bundle for clinicli bundle apply. ?version=
+// numbers it; by default it is one past the applied bundle.
+func (s *server) handleBundle(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodGet) || !s.requireAdmin(w, r) {
+		return
+	}
+	version := 0
+	if raw := r.URL.Query().Get("version"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			writeError(w, r, http.StatusBadRequest, "invalid version")
+			return
+		}
+		version = n
+	}
+	data, m, err := s.a.ExportBundle(version)
+	switch {
+	case errors.Is(err, analysis.ErrBundleUnsigned):
+		writeError(w, r, http.StatusNotImplemented, "bundles need a response signing key")
+		return
+	case err != nil:
+		log.Printf("bundle export failed: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "bundle not exported")
+		return
+	}
+	log.Printf("bundle exported version=%d hash=%s ruleset_version=%s request_id=%s", m.Version, m.Hash, m.RulesetVersion, requestID(r.Context()))
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="bundle-v%d-%s.tar.gz"`, m.Version, m.Hash))
+	w.Header().Set("X-Bundle-Version", strconv.Itoa(m.Version))
+	w.Header().Set("X-Bundle-Hash", m.Hash)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
@@ -0,0 +1,256 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/csvimport"
+)
+
+// Limits of POST /api/analyze/import.
+const (
+	maxImportBytes = 8 << 20
+	maxImportRows  = 10000
+)
+
+const csvContentType = "text/csv"
+
+// importColumns heads the CSV result file.
+var importColumns = []string{"line", "auditId", "riskLevel", "dryRun", "errors"}
+
+// handleImport analyzes a CSV file uploaded as the multipart part named
+// file, laid out as csvimport.Columns describes. Rows are read and analyzed
+// maxBatchItems at a time, and each chunk's results are written before the
+// next is read, so neither the file nor its intakes are held in memory. The
+// results are NDJSON, or CSV with ?format=csv or an Accept header naming
+// text/csv. A bad header is rejected before anything is analyzed; a failure
+// after the stream has started, such as a malformed row or a file over the
+// size or row limit, ends it with an error line, the rows before it having
+// been analyzed.
+func (s *server) handleImport(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodPost) {
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		writeInvalidPayload(w, r, err)
+		return
+	}
+	file, err := filePart(mr)
+	if err != nil {
+		writeInvalidPayload(w, r, err)
+		return
+	}
+	rows, err := csvimport.NewReader(file)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		writeInvalidPayload(w, r, err)
+		return
+	case err != nil:
+		writeValidation(w, r, []string{err.Error()})
+		return
+	}
+
+	locale := s.a.MatchLocale(localePrefs(r)...)
+	opts := analysis.Options{Locale: locale}
+	dryRun := r.URL.Query().Get("dryRun") == "true"
+	rc := http.NewResponseController(w)
+	// HTTP/1 servers otherwise stop reading the upload once results are
+	// written; HTTP/2 does not need it and reports the call unsupported.
+	_ = rc.EnableFullDuplex()
+	enc := newImportEncoder(w, wantsCSV(r))
+	w.Header().Set("Content-Language", locale)
+	w.WriteHeader(http.StatusOK)
+
+	chunk := make([]csvimport.Row, 0, maxBatchItems)
+	analyzed := 0
+	// flush analyzes and writes the chunk, reporting whether the client is
+	// still there to read more.
+	flush := func() bool {
+		for _, res := range s.importChunk(r, chunk, dryRun, opts) {
+			if err := enc.result(res); err != nil {
+				log.Printf("csv import stopped: %v", err)
+				return false
+			}
+		}
+		if err := enc.flush(); err != nil {
+			log.Printf("csv import stopped: %v", err)
+			return false
+		}
+		analyzed += len(chunk)
+		chunk = chunk[:0]
+		// Flushing is best effort; a writer that cannot flush still gets
+		// every line.
+		_ = rc.Flush()
+		return r.Context().Err() == nil
+	}
+	var stop string
+	for {
+		row, err := rows.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			log.Printf("csv import stopped after %d rows: %v", analyzed+len(chunk), err)
+			stop = importStopMessage(err)
+			break
+		}
+		if analyzed+len(chunk) == maxImportRows {
+			stop = fmt.Sprintf("the file has more than %d rows; rows from line %d on were not imported", maxImportRows, row.Line)
+			break
+		}
+		chunk = append(chunk, row)
+		if len(chunk) == maxBatchItems && !flush() {
+			return
+		}
+	}
+	if len(chunk) > 0 && !flush() {
+		return
+	}
+	if stop != "" {
+		enc.stop(stop)
+	}
+	_ = enc.flush()
+	log.Printf("csv import: %d rows, dry_run=%t", analyzed, dryRun)
+}
+
+// importChunk analyzes the readable rows of chunk as one batch and returns one
+// result per row.
+func (s *server) importChunk(r *http.Request, chunk []csvimport.Row, dryRun bool, opts analysis.Options) []analysis.ImportResult {
+	out := make([]analysis.ImportResult, len(chunk))
+	req := analysis.BatchRequest{DryRun: dryRun}
+	var index []int
+	for i, row := range chunk {
+		out[i] = analysis.ImportResult{Line: row.Line, Errors: row.Errors}
+		if len(row.Errors) == 0 {
+			req.Items = append(req.Items, analysis.BatchItem{Intake: row.Intake})
+			index = append(index, i)
+		}
+	}
+	if len(req.Items) == 0 {
+		return out
+	}
+	batch := s.a.AnalyzeBatch(r.Context(), req, opts)
+	for j, resp := range batch.Results {
+		res := &out[index[j]]
+		res.AuditID, res.DryRun, res.Errors = resp.AuditID, resp.DryRun, resp.ValidationErrors
+		if s.withheld(resp) {
+			res.Errors = []string{"the audit log could not be written"}
+			continue
+		}
+		if len(resp.ValidationErrors) == 0 {
+			res.RiskLevel = resp.RiskLevel
+			item := req.Items[j].Intake
+			logAnalysis(resp, s.a.PatientRef(item.PatientName), item.Complaint)
+		}
+	}
+	if len(batch.AuditFailures) > 0 {
+		log.Printf("csv import: %d of %d audits not written", len(batch.AuditFailures), len(batch.Results))
+	}
+	return out
+}
+
+// filePart returns the upload's part named file, skipping any others.
+func filePart(mr *multipart.Reader) (*multipart.Part, error) {
+	for {
+		p, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("the upload has no part named file")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if p.FormName() == "file" {
+			return p, nil
+		}
+	}
+}
+
+// importStopMessage describes a read error without echoing file contents.
+func importStopMessage(err error) string {
+	var tooLarge *http.MaxBytesError
+	var parseErr *csv.ParseError
+	switch {
+	case errors.As(err, &tooLarge):
+		return fmt.Sprintf("the upload exceeds %d bytes; the rest of the file was not imported", maxImportBytes)
+	case errors.As(err, &parseErr):
+		return fmt.Sprintf("line %d is not valid CSV (%v); the rest of the file was not imported", parseErr.StartLine, parseErr.Err)
+	}
+	return "the upload could not be read; the rest of the file was not imported"
+}
+
+// wantsCSV reports whether the caller asked for CSV results, via ?format=csv
+// or an Accept header naming text/csv.
+func wantsCSV(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "csv" {
+		return true
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.EqualFold(mediaType, csvContentType) {
+			return true
+		}
+	}
+	return false
+}
+
+// importEncoder writes import results as NDJSON lines or CSV rows.
+type importEncoder struct {
+	csv  *csv.Writer
+	json *json.Encoder
+}
+
+// newImportEncoder sets the response headers for the chosen format; for CSV
+// it also writes the header row.
+func newImportEncoder(w http.ResponseWriter, asCSV bool) importEncoder {
+	if !asCSV {
+		w.Header().Set("Content-Type", ndjsonContentType)
+		return importEncoder{json: json.NewEncoder(w)}
+	}
+	w.Header().Set("Content-Type", csvContentType+"; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="import-results.csv"`)
+	cw := csv.NewWriter(w)
+	_ = cw.Write(importColumns)
+	return importEncoder{csv: cw}
+}
+
+func (e importEncoder) result(res analysis.ImportResult) error {
+	if e.json != nil {
+		return e.json.Encode(res)
+	}
+	dryRun := ""
+	if res.DryRun {
+		dryRun = "true"
+	}
+	return e.csv.Write([]string{strconv.Itoa(res.Line), res.AuditID, string(res.RiskLevel), dryRun, strings.Join(res.Errors, "; ")})
+}
+
+// stop ends the results with msg: an NDJSON line holding only an error, or a
+// CSV row with msg in the errors column and no line.
+func (e importEncoder) stop(msg string) {
+	if e.json != nil {
+		_ = e.json.Encode(map[string]string{"error": msg})
+		return
+	}
+	_ = e.csv.Write([]string{"", "", "", "", msg})
+}
+
+// flush writes out buffered CSV rows; NDJSON lines are not buffered.
+func (e importEncoder) flush() error {
+	if e.csv == nil {
+		return nil
+	}
+	e.csv.Flush()
+	return e.csv.Error()
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/ws"
+)
+
+// Limits of the /api/analyze/ws socket. Clients are expected to debounce
+// keystrokes; messages beyond the rate are answered with an error and
+// dropped.
+const (
+	maxLiveMessageBytes = 64 << 10
+	liveMessageRate     = 5 // messages per second
+	liveMessageBurst    = 10
+	liveIdleTimeout     = 10 * time.Minute
+	liveWriteTimeout    = 10 * time.Second
+)
+
+// liveLimiter is a per-connection token bucket.
+type liveLimiter struct {
+	tokens float64
+	last   time.Time
+}
+
+func newLiveLimiter(now time.Time) *liveLimiter {
+	return &liveLimiter{tokens: liveMessageBurst, last: now}
+}
+
+// allow spends a token for a message received at now.
+func (l *liveLimiter) allow(now time.Time) bool {
+	l.tokens = min(liveMessageBurst, l.tokens+now.Sub(l.last).Seconds()*liveMessageRate)
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// handleLive serves the incremental analysis socket. "intake" messages get a
+// partial result and are never audited; "submit" runs the full analysis,
+// which is. Messages are handled one at a time, so a shutdown lets the one
+// in hand finish before the socket closes with 1001.
+func (s *server) handleLive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, r, http.StatusMethodNotAllowed, r.Method+" is not supported; use GET")
+		return
+	}
+	if !ws.IsUpgrade(r) {
+		w.Header().Set("Upgrade", "websocket")
+		writeError(w, r, http.StatusUpgradeRequired, "websocket upgrade required")
+		return
+	}
+	conn, err := ws.Upgrade(w, r)
+	if err != nil {
+		log.Printf("live analysis upgrade failed: %v", err)
+		return
+	}
+	conn.SetReadLimit(maxLiveMessageBytes)
+	locale := s.a.MatchLocale(localePrefs(r)...)
+	opts := analysis.Options{
+		Debug:             r.URL.Query().Get("debug") == "true",
+		RuleTrace:         s.ruleTrace(r),
+		IncludeNormalized: r.URL.Query().Get("includeNormalized") == "true",
+		Locale:            locale,
+		DryRun:            r.URL.Query().Get("dryRun") == "true",
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	msgs := make(chan []byte)
+	go func() {
+		defer close(msgs)
+		for {
+			_ = conn.SetReadDeadline(time.Now().Add(liveIdleTimeout))
+			_, raw, err := conn.ReadMessage()
+			if err != nil {
+				if errors.Is(err, ws.ErrMessageTooLarge) {
+					log.Printf("live analysis message over %d bytes; closing", maxLiveMessageBytes)
+				}
+				// A no-op once the peer or a protocol error closed it.
+				_ = conn.Close(ws.CloseGoingAway, "")
+				return
+			}
+			select {
+			case msgs <- raw:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var stopping <-chan struct{}
+	if s.shutdown != nil {
+		stopping = s.shutdown.Done()
+	}
+	limiter := newLiveLimiter(time.Now())
+	var last json.RawMessage
+	for {
+		select {
+		case <-stopping:
+			_ = conn.Close(ws.CloseGoingAway, "server shutting down")
+			return
+		case raw, ok := <-msgs:
+			if !ok {
+				return
+			}
+			var reply analysis.LiveReply
+			if limiter.allow(time.Now()) {
+				reply = s.liveReply(r.Context(), raw, &last, opts)
+			} else {
+				reply = liveError(fmt.Sprintf("rate limit exceeded: at most %d messages per second; message dropped", liveMessageRate))
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+			if err := conn.WriteJSON(reply); err != nil {
+				_ = conn.Close(ws.CloseInternalError, "")
+				return
+			}
+		}
+	}
+}
+
+// liveReply answers one client message. last holds the most recent intake
+// snapshot, which a submit without an intake analyzes.
+func (s *server) liveReply(ctx context.Context, raw []byte, last *json.RawMessage, opts analysis.Options) analysis.LiveReply {
+	var msg analysis.LiveMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return liveError("invalid message: " + err.Error())
+	}
+	switch msg.Type {
+	case "intake":
+		if len(msg.Intake) == 0 {
+			return liveError("intake message has no intake")
+		}
+		partial, err := s.a.CheckPartialIntake(msg.Intake, opts)
+		switch {
+		case errors.Is(err, analysis.ErrMalformedIntake):
+			return liveError(err.Error())
+		case err != nil:
+			log.Printf("live intake check failed: %v", err)
+			return liveError("validation unavailable")
+		}
+		*last = msg.Intake
+		return analysis.LiveReply{Type: "partial", Partial: &partial}
+	case "submit":
+		body := msg.Intake
+		if len(body) == 0 {
+			body = *last
+		}
+		if len(body) == 0 {
+			return liveError("submit has no intake and none was sent before")
+		}
+		var in analysis.Intake
+		if err := json.Unmarshal(body, &in); err != nil {
+			return liveError("invalid intake: " + err.Error())
+		}
+		resp := s.a.AnalyzeContext(ctx, in, opts)
+		if s.withheld(resp) {
+			return liveError("the audit log could not be written; no analysis is returned without one")
+		}
+		if len(resp.ValidationErrors) == 0 {
+			logAnalysis(resp, s.a.PatientRef(in.PatientName), in.Complaint)
+		}
+		return analysis.LiveReply{Type: "result", Result: &resp}
+	}
+	return liveError(fmt.Sprintf("unknown message type %q; use intake or submit", msg.Type))
+}
+
+func liveError(msg string) analysis.LiveReply {
+	return analysis.LiveReply{Type: "error", Error: msg}
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/csv"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+)
+
+var errBadTimezone = errors.New("tz must be an IANA time zone name")
+
+// requestLocation returns the zone named by ?tz=, UTC when there is none.
+// "Local" is refused: it is the server's zone, not the reader's.
+func requestLocation(r *http.Request) (*time.Location, error) {
+	raw := r.URL.Query().Get("tz")
+	if raw == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(raw)
+	if err != nil || raw == "Local" {
+		return nil, errBadTimezone
+	}
+	return loc, nil
+}
+
+// localTime formats the RFC3339 timestamp at in loc, or returns "" when at
+// does not parse.
+func localTime(at string, loc *time.Location) string {
+	t, err := time.Parse(time.RFC3339, at)
+	if err != nil {
+		return ""
+	}
+	return t.In(loc).Format(time.RFC3339)
+}
+
+// localize sets LocalTime on each summary.
+func localize(sums []analysis.AuditSummary, loc *time.Location) []analysis.AuditSummary {
+	for i := range sums {
+		sums[i].LocalTime = localTime(sums[i].At, loc)
+	}
+	return sums
+}
+
+// auditColumns heads the CSV audit export; localTime follows at when the
+// request names a zone.
+var auditColumns = []string{"auditId", "at", "patientRef", "complaint", "riskLevel", "riskScore", "rulesetVersion", "decision", "type"}
+
+// writeAuditsCSV writes sums as a CSV attachment, with a localTime column
+// after the UTC one when withLocal.
+func writeAuditsCSV(w http.ResponseWriter, sums []analysis.AuditSummary, withLocal bool) {
+	w.Header().Set("Content-Type", csvContentType+"; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="audits.csv"`)
+	w.WriteHeader(http.StatusOK)
+	cw := csv.NewWriter(w)
+	row := func(fields []string, local string) []string {
+		if !withLocal {
+			return fields
+		}
+		return append(fields[:2:2], append([]string{local}, fields[2:]...)...)
+	}
+	_ = cw.Write(row(auditColumns, "localTime"))
+	for _, sum := range sums {
+		decision := ""
+		if sum.Decision != nil {
+			decision = sum.Decision.Decision
+		}
+		_ = cw.Write(row([]string{sum.AuditID, sum.At, sum.PatientRef, sum.Complaint, string(sum.RiskLevel), strconv.Itoa(sum.RiskScore), sum.RulesetVersion, decision, sum.Type}, sum.LocalTime))
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		log.Printf("write audit export: %v", err)
+	}
+}
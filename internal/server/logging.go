@@ -0,0 +1,137 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/latency"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/metrics"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/trace"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/ws"
+)
+
+// DefaultSlowRequest is the latency above which a request is logged as slow.
+const DefaultSlowRequest = time.Second
+
+const (
+	// slowWindowSize and slowWindowAge bound the recent requests an adaptive
+	// slow-request threshold is read from.
+	slowWindowSize = 1000
+	slowWindowAge  = 15 * time.Minute
+	// slowMinSamples is how many recent requests the adaptive threshold
+	// needs before it moves off the fixed one.
+	slowMinSamples = 100
+)
+
+var requestDuration = metrics.NewHistogram("http_request_duration_seconds", "HTTP request latency by route pattern, method, and status.", nil, "route", "method", "status")
+
+// statusRecorder captures the status and body size a handler writes.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// slowThreshold decides which requests are logged as slow: those over floor
+// and, when percentile is set, over that percentile of recent requests too,
+// so a deployment that is slower across the board logs its outliers rather
+// than every request.
+type slowThreshold struct {
+	floor      time.Duration
+	percentile float64
+	// recent is nil when the threshold is fixed.
+	recent *latency.Window
+}
+
+func newSlowThreshold(floor time.Duration, percentile float64) *slowThreshold {
+	if floor <= 0 {
+		floor = DefaultSlowRequest
+	}
+	t := &slowThreshold{floor: floor, percentile: percentile}
+	if percentile > 0 && percentile <= 100 {
+		t.recent = latency.NewWindow(slowWindowSize, slowWindowAge)
+	}
+	return t
+}
+
+// observe returns the threshold in force at now, from the requests before
+// this one, and then adds d to them.
+func (t *slowThreshold) observe(now time.Time, d time.Duration) time.Duration {
+	if t.recent == nil {
+		return t.floor
+	}
+	threshold := t.floor
+	if p, n := t.recent.Percentile(now, t.percentile); n >= slowMinSamples {
+		threshold = max(threshold, p)
+	}
+	t.recent.Add(now, d)
+	return threshold
+}
+
+// instrument traces, logs, and times every request, including requests
+// rejected before a handler runs. Each request gets a server span that
+// continues the caller's traceparent; only the method, path, and response
+// metadata are logged, since query strings and bodies can carry patient data.
+// The histogram and span name use the route pattern so IDs in paths do not
+// become series. Slow requests are logged as slowThreshold decides.
+func instrument(next http.Handler, slow *slowThreshold) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx, span := trace.StartServer(r.Context(), r.Method, r.Header, trace.String("http.request.method", r.Method))
+		r = r.WithContext(ctx)
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		d := time.Since(start)
+		switch {
+		case rec.status == 0 && ws.IsUpgrade(r):
+			// The handshake went out on the hijacked connection.
+			rec.status = http.StatusSwitchingProtocols
+		case rec.status == 0:
+			rec.status = http.StatusOK
+		}
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		span.SetName(r.Method + " " + route)
+		span.SetAttributes(trace.String("http.route", route), trace.Int("http.response.status_code", rec.status))
+		if rec.status >= 500 {
+			span.SetError(http.StatusText(rec.status))
+		}
+		span.End()
+
+		requestDuration.Observe(d.Seconds(), route, r.Method, strconv.Itoa(rec.status))
+		id := requestID(r.Context())
+		log.Printf("http method=%s path=%s status=%d bytes=%d duration=%s request_id=%s", r.Method, r.URL.Path, rec.status, rec.bytes, d.Round(time.Microsecond), id)
+		// A socket lasting as long as its client is not slow.
+		if rec.status == http.StatusSwitchingProtocols {
+			return
+		}
+		if threshold := slow.observe(start, d); d > threshold {
+			log.Printf("warn: slow request method=%s route=%s duration=%s threshold=%s request_id=%s", r.Method, route, d.Round(time.Millisecond), threshold.Round(time.Millisecond), id)
+		}
+	})
+}
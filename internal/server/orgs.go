@@ -0,0 +1,38 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+// globalRoutes are the admin routes that configure the whole deployment, not
+// one org, and so take the admin token alone.
+var globalRoutes = []string{"/api/admin/backup", "/api/admin/bundle", "/api/admin/prompt", "/api/admin/rules"}
+
+// withOrg scopes each /api/ request to the org of its X-API-Key when the
+// analyzer is partitioned into orgs. Audits of other orgs then read as not
+// found, so cross-org access answers 404 rather than revealing the audit.
+func (s *server) withOrg(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(s.a.Orgs()) == 0 || !strings.HasPrefix(r.URL.Path, "/api/") || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		for _, p := range globalRoutes {
+			if r.URL.Path == p {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		org, ok := s.a.OrgForAPIKey(r.Header.Get("X-API-Key"))
+		if !ok {
+			addCORS(w)
+			w.Header().Set("WWW-Authenticate", `APIKey realm="org", header="X-API-Key"`)
+			writeError(w, r, http.StatusUnauthorized, "an X-API-Key of a configured org is required")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(audit.WithOrg(r.Context(), org)))
+	})
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/fhir"
+	"github.com/Skufu/Clinical-AI-Assistant/types"
+)
+
+const problemContentType = "application/problem+json"
+
+// problem is the error body handlers write. Resources carries FHIR mapping
+// errors for a failed Bundle import.
+type problem struct {
+	types.Problem
+	Resources []fhir.MappingError `json:"resources,omitempty"`
+}
+
+// writeProblem sends p as application/problem+json with the request ID as its
+// instance. An empty Type is about:blank and an empty Title the status text.
+func writeProblem(w http.ResponseWriter, r *http.Request, p problem) {
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	p.Instance = "urn:clinical-ai-assistant:request:" + requestID(r.Context())
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(p.Status)
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		log.Printf("encode problem: %v", err)
+	}
+}
+
+// writeError sends an about:blank problem.
+func writeError(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	writeProblem(w, r, problem{Problem: types.Problem{Status: status, Detail: detail}})
+}
+
+// writeInvalidPayload reports a body that could not be read or decoded.
+func writeInvalidPayload(w http.ResponseWriter, r *http.Request, err error) {
+	writeProblem(w, r, problem{Problem: types.Problem{
+		Type:   types.ProblemInvalidPayload,
+		Title:  "Invalid payload",
+		Status: http.StatusBadRequest,
+		Detail: err.Error(),
+	}})
+}
+
+// writeValidation reports a well-formed request the API rejected.
+func writeValidation(w http.ResponseWriter, r *http.Request, errs []string) {
+	writeProblem(w, r, validationProblem(errs))
+}
+
+func validationProblem(errs []string) problem {
+	return problem{Problem: types.Problem{
+		Type:   types.ProblemValidationFailed,
+		Title:  "Validation failed",
+		Status: http.StatusBadRequest,
+		Detail: "the request failed validation; see errors",
+		Errors: errs,
+	}}
+}
+
+type requestIDKey struct{}
+
+// maxRequestIDLen bounds a caller-supplied X-Request-ID.
+const maxRequestIDLen = 64
+
+// withRequestID tags each request with the caller's X-Request-ID, or a random
+// one when it is missing or unsafe to echo, and returns it in the response.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			var b [8]byte
+			_, _ = rand.Read(b[:])
+			id = hex.EncodeToString(b[:])
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range id {
+		ok := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.'
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
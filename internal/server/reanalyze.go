@@ -0,0 +1,113 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+const ndjsonContentType = "application/x-ndjson"
+
+func (s *server) handleReanalyze(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodPost) {
+		return
+	}
+	locale := s.a.MatchLocale(localePrefs(r)...)
+	w.Header().Set("Content-Language", locale)
+	res, err := s.a.Reanalyze(r.Context(), r.PathValue("id"), analysis.Options{Locale: locale})
+	switch {
+	case errors.Is(err, audit.ErrNotFound):
+		writeError(w, r, http.StatusNotFound, "audit not found")
+		return
+	case errors.Is(err, analysis.ErrNoIntake):
+		writeError(w, r, http.StatusUnprocessableEntity, "audit has no stored intake")
+		return
+	case errors.Is(err, analysis.ErrIntakesUnsupported), errors.Is(err, analysis.ErrResponsesUnsupported):
+		writeError(w, r, http.StatusNotImplemented, "re-analysis unavailable")
+		return
+	case err != nil:
+		log.Printf("re-analysis failed: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "re-analysis unavailable")
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// handleReanalyzeRange streams one JSON line per reanalyzed audit. A failure
+// after the stream has started ends it with a line holding only an error.
+func (s *server) handleReanalyzeRange(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodPost) {
+		return
+	}
+	q := r.URL.Query()
+	var rng audit.Range
+	var errs []string
+	for _, bound := range []struct {
+		name string
+		t    *time.Time
+	}{{"from", &rng.From}, {"to", &rng.To}} {
+		if raw := q.Get(bound.name); raw != "" {
+			v, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				errs = append(errs, bound.name+" must be an RFC 3339 timestamp")
+				continue
+			}
+			*bound.t = v
+		}
+	}
+	rng.RiskLevel = q.Get("riskLevel")
+	limit := 0
+	if raw := q.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			errs = append(errs, "limit must be a positive integer")
+		}
+		limit = n
+	}
+	if !rng.From.IsZero() && !rng.To.IsZero() && !rng.From.Before(rng.To) {
+		errs = append(errs, "from must be before to")
+	}
+	if len(errs) > 0 {
+		writeValidation(w, r, errs)
+		return
+	}
+
+	locale := s.a.MatchLocale(localePrefs(r)...)
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	started := false
+	err := s.a.ReanalyzeRange(r.Context(), rng, limit, analysis.Options{Locale: locale}, func(res analysis.Reanalysis) error {
+		if !started {
+			started = true
+			w.Header().Set("Content-Type", ndjsonContentType)
+			w.Header().Set("Content-Language", locale)
+			w.WriteHeader(http.StatusOK)
+		}
+		if err := enc.Encode(res); err != nil {
+			return err
+		}
+		// Flushing is best effort; a writer that cannot flush still gets
+		// every line.
+		_ = rc.Flush()
+		return nil
+	})
+	switch {
+	case err == nil && !started:
+		w.Header().Set("Content-Type", ndjsonContentType)
+		w.WriteHeader(http.StatusOK)
+	case errors.Is(err, analysis.ErrRangeUnsupported):
+		writeError(w, r, http.StatusNotImplemented, "bulk re-analysis unavailable")
+	case err != nil && !started:
+		log.Printf("bulk re-analysis failed: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "bulk re-analysis unavailable")
+	case err != nil:
+		log.Printf("bulk re-analysis stopped: %v", err)
+		_ = enc.Encode(map[string]string{"error": "re-analysis stopped early"})
+	}
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+)
+
+// handleReminders lists open follow-up reminders; ?due=overdue or ?due=7d
+// narrows the list to those past due or due within that many days.
+func (s *server) handleReminders(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodGet) {
+		return
+	}
+	f := analysis.ReminderFilter{Due: r.URL.Query().Get("due")}
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			writeError(w, r, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		f.Limit = n
+	}
+	reminders, err := s.a.Reminders(r.Context(), f)
+	switch {
+	case errors.Is(err, analysis.ErrInvalidReminderFilter):
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, analysis.ErrRemindersUnsupported):
+		writeError(w, r, http.StatusNotImplemented, "reminders unavailable")
+		return
+	case err != nil:
+		log.Printf("reminder listing failed: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "reminders unavailable")
+		return
+	}
+	writeJSON(w, http.StatusOK, reminders)
+}
+
+// handleCompleteReminder closes a reminder once the follow-up has happened.
+// The body, naming the clinician, is optional.
+func (s *server) handleCompleteReminder(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodPost) {
+		return
+	}
+	var req analysis.CompleteReminderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeInvalidPayload(w, r, err)
+		return
+	}
+	rem, err := s.a.CompleteReminder(r.Context(), r.PathValue("id"), req)
+	switch {
+	case errors.Is(err, audit.ErrNotFound):
+		writeError(w, r, http.StatusNotFound, "reminder not found")
+		return
+	case errors.Is(err, audit.ErrReminderCompleted):
+		writeError(w, r, http.StatusConflict, "reminder already completed")
+		return
+	case errors.Is(err, analysis.ErrRemindersUnsupported):
+		writeError(w, r, http.StatusNotImplemented, "reminders unavailable")
+		return
+	case err != nil:
+		log.Printf("complete reminder failed: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "reminder not completed")
+		return
+	}
+	log.Printf("reminder completed reminder_id=%s audit_id=%s user=%s", rem.ID, rem.AuditID, rem.CompletedBy)
+	writeJSON(w, http.StatusOK, rem)
+}
@@ -0,0 +1,40 @@
+package server
+
+import "net/http"
+
+// DefaultContentSecurityPolicy fits the embedded UI: scripts and styles come
+// from /assets, the page talks only to this origin, and nothing may frame it.
+// Inline style attributes are allowed because the pages and the markup app.js
+// renders use them; inline script is not, so buttons bind handlers from
+// app.js instead of onclick.
+const DefaultContentSecurityPolicy = "default-src 'self'; script-src 'self'; style-src 'self' 'unsafe-inline'; " +
+	"img-src 'self' data:; connect-src 'self'; object-src 'none'; base-uri 'self'; form-action 'self'; frame-ancestors 'none'"
+
+// WASMContentSecurityPolicy is DefaultContentSecurityPolicy plus
+// 'wasm-unsafe-eval', which browsers require to compile the analysis.wasm
+// pre-screen; New uses it when Config.WASMDir is set.
+const WASMContentSecurityPolicy = "default-src 'self'; script-src 'self' 'wasm-unsafe-eval'; style-src 'self' 'unsafe-inline'; " +
+	"img-src 'self' data:; connect-src 'self'; object-src 'none'; base-uri 'self'; form-action 'self'; frame-ancestors 'none'"
+
+// hsts keeps browsers on HTTPS for a year, subdomains included.
+const hsts = "max-age=31536000; includeSubDomains"
+
+// securityHeaders sets the security headers on every response from next,
+// including API errors and static assets. Strict-Transport-Security is only
+// sent over TLS, since browsers ignore it on plain HTTP.
+func securityHeaders(next http.Handler, csp string) http.Handler {
+	if csp == "" {
+		csp = DefaultContentSecurityPolicy
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("Content-Security-Policy", csp)
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("X-Frame-Options", "DENY")
+		h.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+		if r.TLS != nil {
+			h.Set("Strict-Transport-Security", hsts)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,998 @@
+// Package server exposes an analysis.Analyzer over HTTP.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/fhir"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/metrics"
+	"github.com/Skufu/Clinical-AI-Assistant/types"
+)
+
+// Config configures the handler returned by New.
+type Config struct {
+	// Analyzer serves the API; nil uses analysis.Default().
+	Analyzer *analysis.Analyzer
+	// Static holds landing.html, the app page, and assets/. Nil serves the
+	// API only.
+	Static fs.FS
+	// AppPage names the clinical UI page in Static; empty is DefaultAppPage.
+	AppPage string
+	// ContentSecurityPolicy is sent on every response; empty is
+	// DefaultContentSecurityPolicy.
+	ContentSecurityPolicy string
+	// SlowRequest is the latency above which requests are logged as slow;
+	// zero is DefaultSlowRequest.
+	SlowRequest time.Duration
+	// SlowRequestPercentile, when in (0, 100], makes the slow threshold
+	// adaptive: a request is also compared with that percentile of the
+	// requests of the last 15 minutes, and logged only when over both.
+	SlowRequestPercentile float64
+	// AdminToken is the bearer token granting the admin role. Empty closes
+	// the admin routes that change state.
+	AdminToken string
+	// RulesPath is where PUT /api/admin/rules persists the ruleset; empty
+	// keeps replacements in memory.
+	RulesPath string
+	// WASMDir holds analysis.wasm and wasm_exec.js, as built by make wasm,
+	// served at /assets/wasm/ for in-browser pre-screening. Empty serves
+	// neither.
+	WASMDir string
+	// BackupDir receives the audit snapshots taken by POST
+	// /api/admin/backup; empty disables the endpoint.
+	BackupDir string
+	// BackupKeep is how many backups are kept in BackupDir, the oldest being
+	// pruned; zero or less keeps them all.
+	BackupKeep int
+	// StrictAudit fails analyses whose audit could not be written with 503
+	// instead of returning them unaudited.
+	StrictAudit bool
+	// Shutdown is cancelled when the HTTP server starts shutting down, which
+	// closes /api/analyze/ws sockets the server cannot drain itself. Nil
+	// leaves them open until the client leaves.
+	Shutdown context.Context
+}
+
+type server struct {
+	a          *analysis.Analyzer
+	adminToken string
+	rulesPath  string
+	backupDir  string
+	backupKeep int
+	strict     bool
+	shutdown   context.Context
+}
+
+// New returns the HTTP handler for the API and, when configured, the static UI.
+func New(cfg Config) http.Handler {
+	s := &server{a: cfg.Analyzer, adminToken: cfg.AdminToken, rulesPath: cfg.RulesPath, backupDir: cfg.BackupDir, backupKeep: cfg.BackupKeep, strict: cfg.StrictAudit, shutdown: cfg.Shutdown}
+	if s.a == nil {
+		s.a = analysis.Default()
+	}
+	mux := http.NewServeMux()
+	if cfg.Static != nil {
+		app := cfg.AppPage
+		if app == "" {
+			app = DefaultAppPage
+		}
+		mux.Handle("/assets/", serveAssets(cfg.Static))
+
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			// Serve the marketing landing at root.
+			if r.URL.Path != "/" {
+				http.NotFound(w, r)
+				return
+			}
+			serveStatic(w, r, cfg.Static, "landing.html")
+		})
+
+		mux.HandleFunc("/landing", func(w http.ResponseWriter, r *http.Request) {
+			serveStatic(w, r, cfg.Static, "landing.html")
+		})
+
+		mux.HandleFunc("/app", func(w http.ResponseWriter, r *http.Request) {
+			// Serve the clinical assistant UI at /app.
+			serveStatic(w, r, cfg.Static, app)
+		})
+	}
+
+	if cfg.WASMDir != "" {
+		mux.Handle("/assets/wasm/", serveWASM(os.DirFS(cfg.WASMDir)))
+	}
+
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/readyz", s.handleReady)
+	mux.HandleFunc("/api/audit", s.handleAudits)
+	mux.HandleFunc("/api/audit/{id}", s.handleAudit)
+	mux.HandleFunc("/api/audit/compare", s.handleCompare)
+	mux.HandleFunc("/api/audit/llm-divergence", s.handleDivergence)
+	mux.HandleFunc("/api/audit/decision-stats", s.handleDecisionStats)
+	mux.HandleFunc("/api/audit/duration-stats", s.handleDurationStats)
+	mux.HandleFunc("/api/audit/latency-stats", s.handleLatencyStats)
+	mux.HandleFunc("/api/audit/histogram", s.handleHistogram)
+	mux.HandleFunc("/api/audit/reanalyze", s.handleReanalyzeRange)
+	mux.HandleFunc("/api/audit/{id}/reanalyze", s.handleReanalyze)
+	mux.HandleFunc("/api/audit/{auditId}/issues/{issueCode}/ack", s.handleAckIssue)
+	mux.HandleFunc("/api/patients/{patientRef}/analyses", s.handlePatientAnalyses)
+	mux.HandleFunc("/api/report/{auditId}/note", s.handleNote)
+	mux.HandleFunc("/api/admin/audit/{id}", s.handleAdminAudit)
+	mux.HandleFunc("/api/admin/audit/{id}/delete", s.handleDeleteAudit)
+	mux.HandleFunc("/api/admin/audit/{id}/restore", s.handleRestoreAudit)
+	mux.HandleFunc("/api/admin/backup", s.handleBackup)
+	mux.HandleFunc("/api/admin/bundle", s.handleBundle)
+	mux.HandleFunc("/api/admin/prompt", s.handlePrompt)
+	mux.HandleFunc("/api/admin/rules", s.handleRules)
+	mux.HandleFunc("/api/analyze", s.handleAnalyze)
+	mux.HandleFunc("/api/analyze/fhir", s.handleAnalyzeFHIR)
+	mux.HandleFunc("/api/analyze/batch", s.handleBatch)
+	mux.HandleFunc("/api/analyze/import", s.handleImport)
+	mux.HandleFunc("/api/analyze/whatif", s.handleWhatIf)
+	mux.HandleFunc("/api/analyze/ws", s.handleLive)
+	mux.HandleFunc("/api/analyze/{auditId}/decision", s.handleDecision)
+	mux.HandleFunc("/api/demo/intakes", s.handleDemoIntakes)
+	mux.HandleFunc("/api/interactions", s.handleInteractions)
+	mux.HandleFunc("/api/keys/public", s.handlePublicKey)
+	mux.HandleFunc("/api/reminders", s.handleReminders)
+	mux.HandleFunc("/api/reminders/{id}/complete", s.handleCompleteReminder)
+	mux.HandleFunc("/api/triage", s.handleTriage)
+	mux.HandleFunc("/api/validate", s.handleValidate)
+	mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		addCORS(w)
+		writeError(w, r, http.StatusNotFound, "no API route for "+r.URL.Path)
+	})
+	csp := cfg.ContentSecurityPolicy
+	if csp == "" && cfg.WASMDir != "" {
+		csp = WASMContentSecurityPolicy
+	}
+	return securityHeaders(withRequestID(instrument(s.withOrg(mux), newSlowThreshold(cfg.SlowRequest, cfg.SlowRequestPercentile))), csp)
+}
+
+func (s *server) handleReady(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+	if err := s.a.PingAuditStore(ctx); err != nil {
+		log.Printf("readiness check failed: %v", err)
+		writeError(w, r, http.StatusServiceUnavailable, "audit store unavailable")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+// preflight applies CORS headers and handles OPTIONS; it reports whether the
+// request is one of the methods the handler serves.
+func preflight(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	addCORS(w)
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return false
+	}
+	if !slices.Contains(methods, r.Method) {
+		w.Header().Set("Allow", strings.Join(append(methods, http.MethodOptions), ", "))
+		writeError(w, r, http.StatusMethodNotAllowed, r.Method+" is not supported; use "+strings.Join(methods, " or "))
+		return false
+	}
+	return true
+}
+
+// handleAudits lists recent audits as JSON, or as CSV with ?format=csv or an
+// Accept header naming text/csv. Timestamps stay UTC; localTime is in ?tz=.
+func (s *server) handleAudits(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodGet) {
+		return
+	}
+	loc, err := requestLocation(r)
+	if err != nil {
+		writeValidation(w, r, []string{err.Error()})
+		return
+	}
+	write := func(audits []analysis.AuditSummary) {
+		audits = localize(audits, loc)
+		if wantsCSV(r) {
+			writeAuditsCSV(w, audits, r.URL.Query().Get("tz") != "")
+			return
+		}
+		writeJSON(w, http.StatusOK, audits)
+	}
+	limit := 10
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			writeError(w, r, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = n
+	}
+	version := r.URL.Query().Get("rulesetVersion")
+	if r.URL.Query().Get("includeDeleted") == "true" {
+		if version != "" || r.URL.Query().Get("includeInvalid") == "true" {
+			writeValidation(w, r, []string{"includeDeleted cannot be combined with rulesetVersion or includeInvalid"})
+			return
+		}
+		if !s.requireAdmin(w, r) {
+			return
+		}
+		audits, err := s.a.LatestAuditsIncludingDeleted(r.Context(), limit)
+		switch {
+		case errors.Is(err, analysis.ErrSoftDeleteUnsupported):
+			writeError(w, r, http.StatusNotImplemented, "deleted audits unavailable")
+			return
+		case err != nil:
+			log.Printf("deleted audit lookup failed: %v", err)
+			writeError(w, r, http.StatusInternalServerError, "audit lookup unavailable")
+			return
+		}
+		write(audits)
+		return
+	}
+	if version == "" && r.URL.Query().Get("includeInvalid") == "true" {
+		audits, err := s.a.LatestAuditsWithFailures(r.Context(), limit)
+		switch {
+		case errors.Is(err, analysis.ErrValidationFailuresUnsupported):
+			writeError(w, r, http.StatusNotImplemented, "validation failure audit unavailable")
+			return
+		case err != nil:
+			log.Printf("validation failure lookup failed: %v", err)
+			writeError(w, r, http.StatusInternalServerError, "audit lookup unavailable")
+			return
+		}
+		write(audits)
+		return
+	}
+	if version == "" {
+		write(s.a.LatestAuditsContext(r.Context(), limit))
+		return
+	}
+	audits, err := s.a.AuditsByRulesetVersion(r.Context(), version, limit)
+	switch {
+	case errors.Is(err, analysis.ErrRulesetHistoryUnsupported):
+		writeError(w, r, http.StatusNotImplemented, "ruleset version lookup unavailable")
+		return
+	case err != nil:
+		log.Printf("ruleset version lookup failed: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "audit lookup unavailable")
+		return
+	}
+	write(audits)
+}
+
+func (s *server) handleAudit(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodGet) {
+		return
+	}
+	loc, err := requestLocation(r)
+	if err != nil {
+		writeValidation(w, r, []string{err.Error()})
+		return
+	}
+	resp, err := s.a.AuditResponse(r.Context(), r.PathValue("id"))
+	switch {
+	case errors.Is(err, audit.ErrNotFound):
+		writeError(w, r, http.StatusNotFound, "audit not found")
+		return
+	case err != nil:
+		log.Printf("audit lookup failed: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "audit unavailable")
+		return
+	}
+	// Without a zone the response reads back exactly as it was returned.
+	if r.URL.Query().Get("tz") != "" {
+		resp.LocalTime = localTime(resp.AuditAt, loc)
+	}
+	s.writeSigned(w, r, resp)
+}
+
+// noteContentTypes are the media types of the visit note formats.
+var noteContentTypes = map[string]string{
+	analysis.NoteMarkdown: "text/markdown; charset=utf-8",
+	analysis.NoteText:     "text/plain; charset=utf-8",
+}
+
+// handleNote renders an audit as a SOAP visit note, markdown unless
+// ?format=text.
+func (s *server) handleNote(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodGet) {
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = analysis.NoteMarkdown
+	}
+	note, err := s.a.VisitNote(r.Context(), r.PathValue("auditId"), format)
+	switch {
+	case errors.Is(err, analysis.ErrUnknownNoteFormat):
+		writeValidation(w, r, []string{err.Error()})
+		return
+	case errors.Is(err, audit.ErrNotFound):
+		writeError(w, r, http.StatusNotFound, "audit not found")
+		return
+	case errors.Is(err, analysis.ErrNoIntake):
+		writeError(w, r, http.StatusUnprocessableEntity, "audit has no stored intake")
+		return
+	case errors.Is(err, analysis.ErrIntakesUnsupported), errors.Is(err, analysis.ErrResponsesUnsupported):
+		writeError(w, r, http.StatusNotImplemented, "visit notes unavailable")
+		return
+	case err != nil:
+		log.Printf("visit note failed: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "visit note unavailable")
+		return
+	}
+	w.Header().Set("Content-Type", noteContentTypes[format])
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.WriteString(w, note); err != nil {
+		log.Printf("write visit note: %v", err)
+	}
+}
+
+// handleCompare diffs audit b against audit a, which must be of the same
+// patient unless ?force=true.
+func (s *server) handleCompare(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodGet) {
+		return
+	}
+	q := r.URL.Query()
+	var errs []string
+	for _, name := range []string{"a", "b"} {
+		if q.Get(name) == "" {
+			errs = append(errs, name+" must name an audit")
+		}
+	}
+	if len(errs) > 0 {
+		writeValidation(w, r, errs)
+		return
+	}
+	cmp, err := s.a.CompareAudits(r.Context(), q.Get("a"), q.Get("b"), q.Get("force") == "true")
+	switch {
+	case errors.Is(err, audit.ErrNotFound):
+		writeError(w, r, http.StatusNotFound, "audit not found")
+		return
+	case errors.Is(err, analysis.ErrPatientMismatch):
+		writeError(w, r, http.StatusConflict, "audits are not of the same patient; pass force=true to compare anyway")
+		return
+	case errors.Is(err, analysis.ErrResponsesUnsupported):
+		writeError(w, r, http.StatusNotImplemented, "audit comparison unavailable")
+		return
+	case err != nil:
+		log.Printf("audit comparison failed: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "audit comparison unavailable")
+		return
+	}
+	writeJSON(w, http.StatusOK, cmp)
+}
+
+func (s *server) handleDivergence(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodGet) {
+		return
+	}
+	stats, err := s.a.LLMDivergence(r.Context())
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "divergence unavailable")
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+func (s *server) handleDecisionStats(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodGet) {
+		return
+	}
+	stats, err := s.a.DecisionStats(r.Context())
+	switch {
+	case errors.Is(err, analysis.ErrDecisionsUnsupported):
+		writeError(w, r, http.StatusNotImplemented, "decision stats unavailable")
+		return
+	case err != nil:
+		log.Printf("decision stats failed: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "decision stats unavailable")
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// maxDurationStatsDays bounds the window of GET /api/audit/duration-stats.
+const maxDurationStatsDays = 90
+
+func (s *server) handleDurationStats(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodGet) {
+		return
+	}
+	days := 7
+	if raw := r.URL.Query().Get("days"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 || n > maxDurationStatsDays {
+			writeError(w, r, http.StatusBadRequest, "invalid days")
+			return
+		}
+		days = n
+	}
+	stats, err := s.a.DurationStats(r.Context(), days)
+	switch {
+	case errors.Is(err, analysis.ErrDurationsUnsupported):
+		writeError(w, r, http.StatusNotImplemented, "duration stats unavailable")
+		return
+	case err != nil:
+		log.Printf("duration stats failed: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "duration stats unavailable")
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+func (s *server) handleLatencyStats(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodGet) {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.a.Latency())
+}
+
+// Windows of GET /api/audit/histogram, in days.
+const (
+	defaultHistogramDays = 90
+	maxHistogramDays     = 366
+)
+
+// handleHistogram buckets audits between the local dates from and to, both
+// included, in the IANA zone tz.
+func (s *server) handleHistogram(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodGet) {
+		return
+	}
+	q := r.URL.Query()
+	var errs []string
+	loc, err := requestLocation(r)
+	if err != nil {
+		writeValidation(w, r, []string{err.Error()})
+		return
+	}
+	bucket := q.Get("bucket")
+	switch bucket {
+	case "":
+		bucket = audit.BucketDay
+	case audit.BucketDay, audit.BucketWeek:
+	default:
+		errs = append(errs, "bucket must be day or week")
+	}
+	now := time.Now().In(loc)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	from := to.AddDate(0, 0, 1-defaultHistogramDays)
+	for _, bound := range []struct {
+		name string
+		t    *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		if raw := q.Get(bound.name); raw != "" {
+			v, err := time.ParseInLocation(time.DateOnly, raw, loc)
+			if err != nil {
+				errs = append(errs, bound.name+" must be a YYYY-MM-DD date")
+				continue
+			}
+			*bound.t = v
+		}
+	}
+	// to is inclusive; the store takes the midnight after it.
+	to = to.AddDate(0, 0, 1)
+	switch {
+	case !from.Before(to):
+		errs = append(errs, "from must not be after to")
+	case from.AddDate(0, 0, maxHistogramDays).Before(to):
+		errs = append(errs, fmt.Sprintf("the range must not exceed %d days", maxHistogramDays))
+	}
+	if len(errs) > 0 {
+		writeValidation(w, r, errs)
+		return
+	}
+	hist, err := s.a.Histogram(r.Context(), from, to, bucket)
+	switch {
+	case errors.Is(err, analysis.ErrHistogramUnsupported):
+		writeError(w, r, http.StatusNotImplemented, "histogram unavailable")
+		return
+	case err != nil:
+		log.Printf("audit histogram failed: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "histogram unavailable")
+		return
+	}
+	writeJSON(w, http.StatusOK, hist)
+}
+
+func (s *server) handlePatientAnalyses(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodGet) {
+		return
+	}
+	loc, err := requestLocation(r)
+	if err != nil {
+		writeValidation(w, r, []string{err.Error()})
+		return
+	}
+	limit := 0
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			writeError(w, r, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = n
+	}
+	history, err := s.a.PatientAnalyses(r.Context(), r.PathValue("patientRef"), limit)
+	switch {
+	case errors.Is(err, analysis.ErrHistoryUnsupported):
+		writeError(w, r, http.StatusNotImplemented, "patient history unavailable")
+		return
+	case err != nil:
+		log.Printf("patient history lookup failed: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "patient history unavailable")
+		return
+	}
+	for i := range history.Analyses {
+		history.Analyses[i].LocalTime = localTime(history.Analyses[i].At, loc)
+	}
+	writeJSON(w, http.StatusOK, history)
+}
+
+func (s *server) handlePrompt(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodGet) {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.a.ActivePrompt())
+}
+
+func (s *server) handleAnalyze(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodPost) {
+		return
+	}
+
+	var req analysis.Intake
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxIntakeBytes)).Decode(&req); err != nil {
+		writeInvalidPayload(w, r, err)
+		return
+	}
+	s.analyze(w, r, req)
+}
+
+// maxBatchItems bounds the intakes in one batch request.
+const maxBatchItems = 100
+
+// handleBatch analyzes several intakes in order. ?dryRun=true, or dryRun in
+// the body, makes dry run the default; an item's own dryRun wins.
+func (s *server) handleBatch(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodPost) {
+		return
+	}
+
+	var req analysis.BatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeInvalidPayload(w, r, err)
+		return
+	}
+	if len(req.Items) == 0 || len(req.Items) > maxBatchItems {
+		writeValidation(w, r, []string{fmt.Sprintf("items must hold 1 to %d intakes", maxBatchItems)})
+		return
+	}
+	locale := s.a.MatchLocale(localePrefs(r)...)
+	w.Header().Set("Content-Language", locale)
+	req.DryRun = req.DryRun || r.URL.Query().Get("dryRun") == "true"
+	out := s.a.AnalyzeBatch(r.Context(), req, analysis.Options{
+		Debug:             r.URL.Query().Get("debug") == "true",
+		RuleTrace:         s.ruleTrace(r),
+		IncludeNormalized: r.URL.Query().Get("includeNormalized") == "true",
+		Locale:            locale,
+	})
+	for i, resp := range out.Results {
+		if len(resp.ValidationErrors) == 0 {
+			item := req.Items[i].Intake
+			logAnalysis(resp, s.a.PatientRef(item.PatientName), item.Complaint)
+		}
+	}
+	if len(out.AuditFailures) > 0 {
+		log.Printf("batch analysis: %d of %d audits not written", len(out.AuditFailures), len(out.Results))
+		if s.strict {
+			writeUnaudited(w, r)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// maxBundleBytes bounds FHIR import bodies.
+const maxBundleBytes = 4 << 20
+
+// handleAnalyzeFHIR maps a FHIR R4 Bundle to an Intake and analyzes it. The
+// complaint is not carried by the Bundle and comes from ?complaint=.
+func (s *server) handleAnalyzeFHIR(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodPost) {
+		return
+	}
+	raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBundleBytes))
+	if err != nil {
+		writeInvalidPayload(w, r, err)
+		return
+	}
+	bundle, err := fhir.ParseBundle(raw)
+	if err != nil {
+		writeInvalidPayload(w, r, err)
+		return
+	}
+	req, errs := fhir.ToIntake(bundle, time.Now())
+	if len(errs) > 0 {
+		p := validationProblem(fhir.Messages(errs))
+		p.Resources = errs
+		writeProblem(w, r, p)
+		return
+	}
+	req.Complaint = r.URL.Query().Get("complaint")
+	s.analyze(w, r, req)
+}
+
+func (s *server) handleWhatIf(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodPost) {
+		return
+	}
+
+	var req analysis.WhatIfRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeInvalidPayload(w, r, err)
+		return
+	}
+	if req.AuditID == "" {
+		writeValidation(w, r, []string{"auditId is required"})
+		return
+	}
+	locale := s.a.MatchLocale(localePrefs(r)...)
+	w.Header().Set("Content-Language", locale)
+	resp, err := s.a.WhatIf(r.Context(), req, analysis.Options{
+		Debug:             r.URL.Query().Get("debug") == "true",
+		RuleTrace:         s.ruleTrace(r),
+		IncludeNormalized: r.URL.Query().Get("includeNormalized") == "true",
+		Locale:            locale,
+	})
+	var patchErr *analysis.PatchError
+	switch {
+	case errors.As(err, &patchErr):
+		writeProblem(w, r, problem{Problem: types.Problem{
+			Type:   types.ProblemInvalidPatch,
+			Title:  "Invalid patch",
+			Status: http.StatusBadRequest,
+			Detail: patchErr.Error(),
+			Errors: []string{patchErr.Error()},
+			Op:     &patchErr.Index,
+		}})
+		return
+	case errors.Is(err, audit.ErrNotFound):
+		writeError(w, r, http.StatusNotFound, "audit not found")
+		return
+	case errors.Is(err, analysis.ErrNoIntake):
+		writeError(w, r, http.StatusUnprocessableEntity, "audit has no stored intake")
+		return
+	case errors.Is(err, analysis.ErrIntakesUnsupported):
+		writeError(w, r, http.StatusNotImplemented, "what-if analysis unavailable")
+		return
+	case err != nil:
+		log.Printf("what-if analysis failed: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "what-if analysis unavailable")
+		return
+	}
+	if len(resp.Hypothetical.ValidationErrors) > 0 {
+		writeValidation(w, r, resp.Hypothetical.ValidationErrors)
+		return
+	}
+	if s.withheld(resp.Hypothetical) {
+		writeUnaudited(w, r)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *server) handleDecision(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodPost) {
+		return
+	}
+
+	var req analysis.DecisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeInvalidPayload(w, r, err)
+		return
+	}
+	d, err := s.a.RecordDecision(r.Context(), r.PathValue("auditId"), req)
+	switch {
+	case errors.Is(err, analysis.ErrInvalidDecision):
+		writeValidation(w, r, []string{err.Error()})
+		return
+	case errors.Is(err, audit.ErrNotFound):
+		writeError(w, r, http.StatusNotFound, "audit not found")
+		return
+	case errors.Is(err, audit.ErrDecisionExists):
+		writeError(w, r, http.StatusConflict, "decision already recorded")
+		return
+	case errors.Is(err, analysis.ErrDecisionsUnsupported):
+		writeError(w, r, http.StatusNotImplemented, "decisions unavailable")
+		return
+	case err != nil:
+		log.Printf("record decision failed: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "decision not recorded")
+		return
+	}
+	log.Printf("decision audit_id=%s decision=%s revision=%d user=%s", d.AuditID, d.Decision, d.Revision, d.UserID)
+	writeJSON(w, http.StatusCreated, d)
+}
+
+// handleAckIssue records a clinician's acknowledgment of a warning, so later
+// analyses of the patient mark it acknowledged.
+func (s *server) handleAckIssue(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodPost) {
+		return
+	}
+	var req analysis.IssueAckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeInvalidPayload(w, r, err)
+		return
+	}
+	ack, err := s.a.AcknowledgeIssue(r.Context(), r.PathValue("auditId"), r.PathValue("issueCode"), req)
+	switch {
+	case errors.Is(err, analysis.ErrAckReasonRequired):
+		writeValidation(w, r, []string{err.Error()})
+		return
+	case errors.Is(err, audit.ErrNotFound):
+		writeError(w, r, http.StatusNotFound, "audit not found")
+		return
+	case errors.Is(err, analysis.ErrIssueNotFound):
+		writeError(w, r, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, analysis.ErrDangerNotAcknowledgeable):
+		writeError(w, r, http.StatusUnprocessableEntity, err.Error())
+		return
+	case errors.Is(err, audit.ErrAlreadyAcknowledged):
+		writeError(w, r, http.StatusConflict, "issue already acknowledged")
+		return
+	case errors.Is(err, analysis.ErrAcksUnsupported), errors.Is(err, analysis.ErrResponsesUnsupported):
+		writeError(w, r, http.StatusNotImplemented, "issue acknowledgments unavailable")
+		return
+	case err != nil:
+		log.Printf("issue acknowledgment failed: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "issue not acknowledged")
+		return
+	}
+	log.Printf("issue acknowledged audit_id=%s code=%s user=%s", ack.AuditID, ack.IssueCode, ack.UserID)
+	writeJSON(w, http.StatusCreated, ack)
+}
+
+// maxIntakeBytes bounds single-intake bodies for analysis and validation.
+const maxIntakeBytes = 1 << 20
+
+// handleValidate reports field errors, warnings, and a normalization preview
+// for an intake without analyzing it. A well-formed body always gets 200;
+// valid in the report says whether /api/analyze would accept it.
+func (s *server) handleValidate(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodPost) {
+		return
+	}
+	raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIntakeBytes))
+	if err != nil {
+		writeInvalidPayload(w, r, err)
+		return
+	}
+	report, err := s.a.CheckIntake(raw)
+	switch {
+	case errors.Is(err, analysis.ErrMalformedIntake):
+		writeInvalidPayload(w, r, err)
+		return
+	case err != nil:
+		log.Printf("validate intake failed: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "validation unavailable")
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// handleInteractions checks a bare medication list; nothing is audited.
+func (s *server) handleInteractions(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodPost) {
+		return
+	}
+
+	var req analysis.InteractionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeInvalidPayload(w, r, err)
+		return
+	}
+	locale := s.a.MatchLocale(localePrefs(r)...)
+	w.Header().Set("Content-Language", locale)
+	report := s.a.CheckInteractions(req, analysis.Options{Locale: locale})
+	if len(report.ValidationErrors) > 0 {
+		writeValidation(w, r, report.ValidationErrors)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// handleTriage lists the follow-up questions for a partial intake; nothing
+// is validated, planned, or audited.
+func (s *server) handleTriage(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodPost) {
+		return
+	}
+	var in analysis.Intake
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxIntakeBytes)).Decode(&in); err != nil {
+		writeInvalidPayload(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.a.Triage(in))
+}
+
+// handleDemoIntakes lists the demo example intakes for the UI to load. It
+// exists only while the analyzer is in demo mode.
+func (s *server) handleDemoIntakes(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodGet) {
+		return
+	}
+	if !s.a.DemoMode() {
+		writeError(w, r, http.StatusNotFound, "demo mode is off")
+		return
+	}
+	writeJSON(w, http.StatusOK, analysis.DemoCases())
+}
+
+func (s *server) analyze(w http.ResponseWriter, r *http.Request, req analysis.Intake) {
+	locale := s.a.MatchLocale(localePrefs(r)...)
+	w.Header().Set("Content-Language", locale)
+	resp := s.a.AnalyzeContext(r.Context(), req, analysis.Options{
+		Debug:             r.URL.Query().Get("debug") == "true",
+		RuleTrace:         s.ruleTrace(r),
+		IncludeNormalized: r.URL.Query().Get("includeNormalized") == "true",
+		Locale:            locale,
+		DryRun:            r.URL.Query().Get("dryRun") == "true",
+	})
+	if len(resp.ValidationErrors) > 0 {
+		p := validationProblem(resp.ValidationErrors)
+		p.AuditID = resp.AuditID
+		writeProblem(w, r, p)
+		return
+	}
+	if s.withheld(resp) {
+		writeUnaudited(w, r)
+		return
+	}
+
+	ref := s.a.PatientRef(req.PatientName)
+	if wantsFHIR(r) {
+		w.Header().Set("Content-Type", fhirContentType)
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(fhir.ToBundle(resp, ref)); err != nil {
+			log.Printf("encode response: %v", err)
+		}
+	} else if !s.writeSigned(w, r, resp) {
+		return
+	}
+
+	logAnalysis(resp, ref, req.Complaint)
+}
+
+// logAnalysis writes the minimal audit log line of an analysis; ref is the
+// pseudonymized patient name.
+func logAnalysis(resp analysis.Response, ref, complaint string) {
+	log.Printf("analysis audit_id=%s patient=%s complaint=%s risk=%s score=%d dry_run=%t", resp.AuditID, ref, complaint, resp.RiskLevel, resp.RiskScore, resp.DryRun)
+}
+
+// writeSigned writes resp with 200, signed in the signature headers when a
+// signing key is configured. It reports false when signing failed and an
+// error was written instead.
+func (s *server) writeSigned(w http.ResponseWriter, r *http.Request, resp analysis.Response) bool {
+	sig, err := s.a.SignResponse(resp)
+	if err != nil {
+		log.Printf("sign response: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "response signing failed")
+		return false
+	}
+	if sig != nil {
+		w.Header().Set(types.SignatureHeader, sig.Value)
+		w.Header().Set(types.SignatureKeyIDHeader, sig.KeyID)
+	}
+	writeJSON(w, http.StatusOK, resp)
+	return true
+}
+
+// handlePublicKey serves the public half of the response signing key, so
+// consumers can verify signatures and notice a rotation by its key ID.
+func (s *server) handlePublicKey(w http.ResponseWriter, r *http.Request) {
+	if !preflight(w, r, http.MethodGet) {
+		return
+	}
+	key, ok := s.a.SigningKey()
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "response signing is off")
+		return
+	}
+	writeJSON(w, http.StatusOK, key)
+}
+
+// withheld reports whether resp must not be returned: in strict audit mode,
+// an analysis whose audit write failed.
+func (s *server) withheld(resp analysis.Response) bool {
+	return s.strict && resp.AuditRecorded != nil && !*resp.AuditRecorded
+}
+
+func writeUnaudited(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusServiceUnavailable, "the audit log could not be written; no analysis is returned without one")
+}
+
+const fhirContentType = "application/fhir+json"
+
+// wantsFHIR reports whether the caller asked for a FHIR Bundle, via
+// ?format=fhir or an Accept header naming application/fhir+json.
+func wantsFHIR(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "fhir" {
+		return true
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.EqualFold(mediaType, fhirContentType) {
+			return true
+		}
+	}
+	return false
+}
+
+// localePrefs lists the caller's language preferences: ?lang= first, then
+// Accept-Language tags by descending q, skipping "*" and q=0.
+func localePrefs(r *http.Request) []string {
+	var prefs []string
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		prefs = append(prefs, lang)
+	}
+	type tag struct {
+		name string
+		q    float64
+	}
+	var tags []tag
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = f
+		}
+		if name == "" || name == "*" || q <= 0 {
+			continue
+		}
+		tags = append(tags, tag{name, q})
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+	for _, t := range tags {
+		prefs = append(prefs, t.name)
+	}
+	return prefs
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("encode response: %v", err)
+	}
+}
+
+func addCORS(w http.ResponseWriter) {
+	// Allow same-origin plus simple dev usage.
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, PUT, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", strings.Join([]string{
+		"Content-Type",
+		"Authorization",
+		"X-API-Key",
+	}, ", "))
+}
@@ -0,0 +1,1861 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/signing"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/trace"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/ws"
+	"github.com/Skufu/Clinical-AI-Assistant/types"
+)
+
+func postBundle(t *testing.T, h http.Handler, path, url string) *httptest.ResponseRecorder {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, url, f))
+	return rec
+}
+
+func TestAnalyzeFHIR(t *testing.T) {
+	h := New(Config{Analyzer: analysis.New()})
+
+	rec := postBundle(t, h, "../fhir/testdata/complete.bundle.json", "/api/analyze/fhir?complaint=ED")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var resp analysis.Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.AuditID == "" || resp.ComputedBMI == 0 {
+		t.Fatalf("incomplete response: %+v", resp)
+	}
+
+	rec = postBundle(t, h, "../fhir/testdata/invalid.bundle.json", "/api/analyze/fhir?complaint=ED")
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want 400", rec.Code)
+	}
+	var body struct {
+		Type      string   `json:"type"`
+		Errors    []string `json:"errors"`
+		Resources []struct {
+			ResourceType string `json:"resourceType"`
+			ResourceID   string `json:"resourceId"`
+		} `json:"resources"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Type != types.ProblemValidationFailed || len(body.Errors) != len(body.Resources) {
+		t.Fatalf("unexpected body: %s", rec.Body)
+	}
+	if r := body.Resources[0]; r.ResourceType != "Patient" || r.ResourceID != "pat-3" {
+		t.Fatalf("first error references %s/%s, want Patient/pat-3", r.ResourceType, r.ResourceID)
+	}
+
+	// Without a complaint the mapped intake fails normal validation.
+	rec = postBundle(t, h, "../fhir/testdata/complete.bundle.json", "/api/analyze/fhir")
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("missing complaint: status %d, want 400", rec.Code)
+	}
+}
+
+func TestAnalyze_FHIRNegotiation(t *testing.T) {
+	h := New(Config{Analyzer: analysis.New()})
+	body := `{"patientName":"Jane","age":45,"weight":70,"height":170,"bp":"120/80","complaint":"ED"}`
+	for name, prep := range map[string]func(*http.Request){
+		"accept": func(r *http.Request) { r.Header.Set("Accept", "application/fhir+json; fhirVersion=4.0") },
+		"query":  func(r *http.Request) { r.URL.RawQuery = "format=fhir" },
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/analyze", strings.NewReader(body))
+			prep(req)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != fhirContentType {
+				t.Fatalf("status %d content-type %q", rec.Code, rec.Header().Get("Content-Type"))
+			}
+			var b struct {
+				ResourceType string `json:"resourceType"`
+				Entry        []struct {
+					Resource struct {
+						ResourceType string `json:"resourceType"`
+					} `json:"resource"`
+				} `json:"entry"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &b); err != nil {
+				t.Fatal(err)
+			}
+			if b.ResourceType != "Bundle" || len(b.Entry) < 3 || b.Entry[0].Resource.ResourceType != "RiskAssessment" {
+				t.Fatalf("unexpected bundle: %s", rec.Body)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/analyze", strings.NewReader(body)))
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("default content-type %q", ct)
+	}
+}
+
+func TestAnalyze_Locale(t *testing.T) {
+	h := New(Config{Analyzer: analysis.New()})
+	body := `{"patientName":"Jane","age":45,"weight":95,"height":170,"bp":"150/95","complaint":"ED"}`
+	cases := []struct {
+		name, url, accept, want string
+	}{
+		{"default", "/api/analyze", "", "en"},
+		{"accept-language", "/api/analyze", "de;q=0.9, tl-PH;q=0.95, *;q=0.5", "tl"},
+		{"q=0 skipped", "/api/analyze", "tl;q=0, en", "en"},
+		{"query wins", "/api/analyze?lang=tl", "en-US", "tl"},
+		{"unknown", "/api/analyze?lang=xx", "", "en"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tc.url, strings.NewReader(body))
+			if tc.accept != "" {
+				req.Header.Set("Accept-Language", tc.accept)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", rec.Code, rec.Body)
+			}
+			if got := rec.Header().Get("Content-Language"); got != tc.want {
+				t.Fatalf("Content-Language %q, want %q", got, tc.want)
+			}
+			var resp analysis.Response
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			english := strings.HasPrefix(resp.RecommendedPlan.Rationale, "First-line PDE5 inhibitor")
+			if english != (tc.want == "en") {
+				t.Fatalf("rationale %q not in %s", resp.RecommendedPlan.Rationale, tc.want)
+			}
+		})
+	}
+}
+
+func TestPatientAnalyses(t *testing.T) {
+	a := analysis.New()
+	h := New(Config{Analyzer: a})
+	analyze := func(body string) analysis.Response {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/analyze", strings.NewReader(body)))
+		var resp analysis.Response
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	first := analyze(`{"patientName":"Trend Patient","age":40,"weight":70,"height":175,"bp":"120/80","complaint":"ED"}`)
+	if first.PreviousRiskScore != nil || first.RiskTrend != nil {
+		t.Fatalf("first analysis should have no trend: %+v", first.RiskTrend)
+	}
+	second := analyze(`{"patientName":"Trend Patient","age":40,"weight":110,"height":175,"bp":"165/100","complaint":"ED"}`)
+	if second.PreviousRiskScore == nil || *second.PreviousRiskScore != first.RiskScore {
+		t.Fatalf("previousRiskScore = %v, want %d", second.PreviousRiskScore, first.RiskScore)
+	}
+	if second.RiskTrend == nil || second.RiskTrend.Direction != "up" || second.RiskTrend.Delta != second.RiskScore-first.RiskScore {
+		t.Fatalf("riskTrend = %+v", second.RiskTrend)
+	}
+
+	ref := a.PatientRef("Trend Patient")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/patients/"+ref+"/analyses", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var history analysis.PatientHistory
+	if err := json.Unmarshal(rec.Body.Bytes(), &history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Analyses) != 2 || history.Analyses[0].AuditID != first.AuditID || history.Analyses[1].AuditID != second.AuditID {
+		t.Fatalf("analyses not oldest first: %+v", history.Analyses)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/audit?rulesetVersion="+first.RulesetVersion, nil))
+	var audits []analysis.AuditSummary
+	if err := json.Unmarshal(rec.Body.Bytes(), &audits); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("ruleset version filter: status %d: %s", rec.Code, rec.Body)
+	}
+	if len(audits) != 2 || audits[0].RulesetVersion != first.RulesetVersion {
+		t.Fatalf("audits for ruleset %s = %+v", first.RulesetVersion, audits)
+	}
+	if history.Analyses[0].Trend != nil || history.Trend == nil || history.Trend.Arrow != "↑" {
+		t.Fatalf("trend: %+v / %+v", history.Analyses[0].Trend, history.Trend)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/patients/"+ref+"/analyses?limit=x", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("bad limit: status %d", rec.Code)
+	}
+}
+
+func TestAudits_IncludeInvalid(t *testing.T) {
+	h := New(Config{Analyzer: analysis.New()})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/analyze", strings.NewReader(`{"patientName":"Invalid Patient","age":40,"complaint":"ED"}`)))
+	var p types.Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil || rec.Code != http.StatusBadRequest || p.AuditID == "" {
+		t.Fatalf("invalid analysis: status %d: %s", rec.Code, rec.Body)
+	}
+	list := func(query string) []analysis.AuditSummary {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/audit"+query, nil))
+		var audits []analysis.AuditSummary
+		if err := json.Unmarshal(rec.Body.Bytes(), &audits); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("GET /api/audit%s: status %d: %s", query, rec.Code, rec.Body)
+		}
+		return audits
+	}
+	if audits := list(""); len(audits) != 0 {
+		t.Fatalf("default listing should leave out validation failures: %+v", audits)
+	}
+	audits := list("?includeInvalid=true")
+	if len(audits) != 1 || audits[0].AuditID != p.AuditID || audits[0].Type != analysis.AuditTypeValidationFailed || len(audits[0].Errors) == 0 {
+		t.Fatalf("includeInvalid listing = %+v", audits)
+	}
+}
+
+func TestWhatIf(t *testing.T) {
+	h := New(Config{Analyzer: analysis.New()})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/analyze", strings.NewReader(
+		`{"patientName":"What If","age":62,"weight":88,"height":172,"bp":"165/100","complaint":"ED","medications":[{"name":"isosorbide mononitrate"}]}`)))
+	var base analysis.Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &base); err != nil || base.AuditID == "" {
+		t.Fatalf("base analysis: %s", rec.Body)
+	}
+
+	post := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/analyze/whatif", strings.NewReader(body)))
+		return rec
+	}
+	rec = post(`{"auditId":"` + base.AuditID + `","patch":[{"op":"remove","path":"/medications","value":"isosorbide mononitrate"},{"op":"replace","path":"/bp","value":"130/85"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var got analysis.WhatIfResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.BaseAuditID != base.AuditID || got.Diff.RiskScoreBefore != base.RiskScore || got.Diff.RiskScoreDelta >= 0 {
+		t.Fatalf("diff = %+v", got.Diff)
+	}
+
+	cases := []struct {
+		name, body string
+		status     int
+		want       string
+	}{
+		{"bad op", `{"auditId":"` + base.AuditID + `","patch":[{"op":"replace","path":"/bp","value":"1/1"},{"op":"replace","path":"/nope","value":1}]}`, http.StatusBadRequest, `patch[1] replace /nope`},
+		{"invalid result", `{"auditId":"` + base.AuditID + `","patch":[{"op":"replace","path":"/age","value":0}]}`, http.StatusBadRequest, "age must be greater than 0"},
+		{"unknown audit", `{"auditId":"missing","patch":[]}`, http.StatusNotFound, "audit not found"},
+		{"no audit id", `{"patch":[]}`, http.StatusBadRequest, "auditId is required"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := post(tc.body)
+			if rec.Code != tc.status || !strings.Contains(rec.Body.String(), tc.want) {
+				t.Fatalf("status %d body %s, want %d containing %q", rec.Code, rec.Body, tc.status, tc.want)
+			}
+		})
+	}
+}
+
+func TestDecision(t *testing.T) {
+	a := analysis.New()
+	h := New(Config{Analyzer: a})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/analyze", strings.NewReader(
+		`{"patientName":"Decided","age":45,"weight":70,"height":170,"bp":"120/80","complaint":"ED"}`)))
+	var resp analysis.Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.AuditID == "" {
+		t.Fatalf("analysis: %s", rec.Body)
+	}
+
+	decide := func(id, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/analyze/"+id+"/decision", strings.NewReader(body)))
+		return rec
+	}
+	if rec := decide(resp.AuditID, `{"decision":"approved","userId":"dr-a"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("approve: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := decide(resp.AuditID, `{"decision":"rejected","reason":"changed mind"}`); rec.Code != http.StatusConflict {
+		t.Fatalf("second decision: status %d", rec.Code)
+	}
+	if rec := decide(resp.AuditID, `{"decision":"modified","reason":"x"}`); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "modifiedPlan") {
+		t.Fatalf("invalid decision: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := decide("missing", `{"decision":"approved"}`); rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown audit: status %d", rec.Code)
+	}
+
+	a.SetDecisionRevisions(true)
+	if rec := decide(resp.AuditID, `{"decision":"rejected","reason":"new labs"}`); rec.Code != http.StatusCreated || !strings.Contains(rec.Body.String(), `"revision":2`) {
+		t.Fatalf("revision: status %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/audit", nil))
+	var audits []analysis.AuditSummary
+	if err := json.Unmarshal(rec.Body.Bytes(), &audits); err != nil || len(audits) != 1 || audits[0].Decision == nil || audits[0].Decision.Decision != "rejected" {
+		t.Fatalf("listing: %s", rec.Body)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/audit/"+resp.AuditID, nil))
+	var stored analysis.Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &stored); err != nil || len(stored.Decisions) != 2 {
+		t.Fatalf("single audit: %s", rec.Body)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/audit/decision-stats", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"overrideRate":1`) {
+		t.Fatalf("stats: status %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestDurationStats(t *testing.T) {
+	a := analysis.New()
+	h := New(Config{Analyzer: a})
+	a.Analyze(analysis.Intake{PatientName: "Timed", Age: 45, WeightKg: 70, HeightCm: 170, BP: "120/80", Complaint: "ED"})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/audit/duration-stats?days=1", nil))
+	var stats struct {
+		ByDay []struct{ Analyses int }
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil || rec.Code != http.StatusOK || len(stats.ByDay) != 1 || stats.ByDay[0].Analyses != 1 {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/audit/duration-stats?days=0", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("days=0: status %d", rec.Code)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	a := analysis.New(analysis.WithClock(func() time.Time { return time.Date(2025, 3, 4, 23, 30, 0, 0, time.UTC) }))
+	h := New(Config{Analyzer: a})
+	a.Analyze(analysis.Intake{PatientName: "Counted", Age: 45, WeightKg: 70, HeightCm: 170, BP: "120/80", Complaint: "ED"})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/audit/histogram?from=2025-03-04&to=2025-03-06&tz=Asia/Manila", nil))
+	var hist struct {
+		Timezone string
+		Buckets  []struct {
+			Start    string
+			Analyses int
+		}
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &hist); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if hist.Timezone != "Asia/Manila" || len(hist.Buckets) != 3 || hist.Buckets[1].Start != "2025-03-05" || hist.Buckets[1].Analyses != 1 || hist.Buckets[0].Analyses != 0 {
+		t.Fatalf("histogram = %s", rec.Body)
+	}
+	for _, query := range []string{"bucket=month", "tz=Mars/Olympus", "from=2025-03-06&to=2025-03-05", "from=2024-01-01&to=2025-03-05", "from=March"} {
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/audit/histogram?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d", query, rec.Code)
+		}
+	}
+}
+
+func TestAnalyze_DryRun(t *testing.T) {
+	a := analysis.New()
+	h := New(Config{Analyzer: a})
+	const intake = `{"patientName":"Trial","age":45,"weight":70,"height":170,"bp":"120/80","complaint":"ED"}`
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/analyze?dryRun=true", strings.NewReader(intake)))
+	var resp analysis.Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if !resp.DryRun || resp.AuditID != "" {
+		t.Fatalf("dry run: dryRun=%t auditId=%q", resp.DryRun, resp.AuditID)
+	}
+	if got := a.LatestAudits(10); len(got) != 0 {
+		t.Fatalf("dry run was audited: %+v", got)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/analyze/batch?dryRun=true", strings.NewReader(
+		`{"items":[{"intake":`+intake+`},{"intake":`+intake+`,"dryRun":false},{"intake":{"patientName":"Bad"}}]}`)))
+	var batch analysis.BatchResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &batch); err != nil || rec.Code != http.StatusOK || len(batch.Results) != 3 {
+		t.Fatalf("batch: status %d: %s", rec.Code, rec.Body)
+	}
+	if r := batch.Results[0]; !r.DryRun || r.AuditID != "" {
+		t.Errorf("item 0 should inherit the dry run: %+v", r)
+	}
+	if r := batch.Results[1]; r.DryRun || r.AuditID == "" {
+		t.Errorf("item 1 should override to a recorded run: %+v", r)
+	}
+	if r := batch.Results[2]; len(r.ValidationErrors) == 0 {
+		t.Errorf("item 2 should carry validation errors: %+v", r)
+	}
+	if got := a.LatestAudits(10); len(got) != 1 {
+		t.Fatalf("want one audited item, got %d", len(got))
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/analyze/batch", strings.NewReader(`{"items":[]}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("empty batch: status %d", rec.Code)
+	}
+}
+
+func TestAnalyze_IncludeNormalized(t *testing.T) {
+	h := New(Config{Analyzer: analysis.New()})
+	const intake = `{"patientName":"Shown Raw","age":45,"weight":70,"height":170,"bp":"120/80","complaint":"ED",
+		"medications":[{"name":"Tamsulosin","dosage":"0.4 mg","frequency":"nightly"}]}`
+	for _, query := range []string{"", "?includeNormalized=true"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/analyze"+query, strings.NewReader(intake)))
+		var resp map[string]json.RawMessage
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("%q: status %d: %s", query, rec.Code, rec.Body)
+		}
+		n, ok := resp["normalizedIntake"]
+		if ok != (query != "") {
+			t.Fatalf("%q: normalizedIntake present = %t", query, ok)
+		}
+		if ok && (!strings.Contains(string(n), `"doseMg":0.4`) || strings.Contains(string(n), "Shown Raw")) {
+			t.Fatalf("normalizedIntake = %s", n)
+		}
+	}
+}
+
+func TestAnalyze_RuleTrace(t *testing.T) {
+	a := analysis.New()
+	h := New(Config{Analyzer: a, AdminToken: "s3cret"})
+	const intake = `{"patientName":"Trace","age":45,"weight":70,"height":170,"bp":"150/90","complaint":"ED","confirmedNoMedications":true,"confirmedNoConditions":true}`
+	analyze := func(query, auth, key string) map[string]json.RawMessage {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/analyze"+query, strings.NewReader(intake))
+		if auth != "" {
+			req.Header.Set("Authorization", "Bearer "+auth)
+		}
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		var resp map[string]json.RawMessage
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", query, rec.Code, rec.Body)
+		}
+		return resp
+	}
+	if resp := analyze("?debug=true", "s3cret", ""); !strings.Contains(string(resp["ruleTrace"]), `"rule":"bp_elevated"`) {
+		t.Fatalf("admin debug ruleTrace = %s", resp["ruleTrace"])
+	}
+	for name, resp := range map[string]map[string]json.RawMessage{
+		"no debug":    analyze("", "s3cret", ""),
+		"no role":     analyze("?debug=true", "", ""),
+		"wrong token": analyze("?debug=true", "guess", ""),
+	} {
+		if _, ok := resp["ruleTrace"]; ok {
+			t.Errorf("%s: ruleTrace present", name)
+		}
+	}
+	if _, ok := analyze("?debug=true", "", "")["timings"]; !ok {
+		t.Error("debug without a role lost its timings")
+	}
+
+	// A clinician authenticated by an org API key gets the trace too.
+	if err := a.SetOrgs(map[string]analysis.OrgConfig{"clinic": {APIKeys: []string{"clinic-key"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := analyze("?debug=true", "", "clinic-key")["ruleTrace"]; !ok {
+		t.Fatal("org caller debug: ruleTrace missing")
+	}
+}
+
+func TestAnalyze_ConsentRequired(t *testing.T) {
+	a := analysis.New()
+	a.SetConsentRequired(true)
+	h := New(Config{Analyzer: a})
+	const intake = `{"patientName":"Consent","age":45,"weight":70,"height":170,"bp":"120/80","complaint":"ED"`
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/analyze", strings.NewReader(intake+`}`)))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), analysis.ConsentRequired) {
+		t.Fatalf("missing consent: status %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/analyze", strings.NewReader(
+		intake+`,"consent":{"given":true,"timestamp":"2025-03-01T09:00:00Z","method":"verbal"}}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("documented consent: status %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestLive(t *testing.T) {
+	a := analysis.New()
+	shutdown, stop := context.WithCancel(t.Context())
+	defer stop()
+	srv := httptest.NewServer(New(Config{Analyzer: a, Shutdown: shutdown}))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/analyze/ws"
+	conn, err := ws.Dial(t.Context(), url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(ws.CloseNormal, "")
+	send := func(msg string) analysis.LiveReply {
+		t.Helper()
+		if err := conn.WriteMessage(ws.TextMessage, []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+		var reply analysis.LiveReply
+		if err := conn.ReadJSON(&reply); err != nil {
+			t.Fatal(err)
+		}
+		return reply
+	}
+
+	reply := send(`{"type":"intake","intake":{"age":70,"weight":100,"height":170,"bp":"170/105"}}`)
+	if reply.Type != "partial" || reply.Partial == nil {
+		t.Fatalf("partial reply = %+v", reply)
+	}
+	p := reply.Partial
+	if p.Valid || len(p.Errors) == 0 {
+		t.Errorf("incomplete intake reported valid: %+v", p.ValidationReport)
+	}
+	codes := map[string]bool{}
+	for _, f := range p.RiskFactors {
+		codes[f.Code] = true
+	}
+	if p.ComputedBMI < 34 || !codes["bp_uncontrolled"] || !codes["age_over_65"] || !codes["bmi_obesity"] {
+		t.Errorf("provisional risk: bmi %.1f, factors %v", p.ComputedBMI, codes)
+	}
+
+	if reply := send(`{"type":"submit"}`); reply.Type != "result" || len(reply.Result.ValidationErrors) == 0 {
+		t.Fatalf("submitting the incomplete snapshot = %+v", reply)
+	}
+	send(`{"type":"intake","intake":{"patientName":"Live","age":45,"weight":70,"height":170,"bp":"120/80","complaint":"ED"}}`)
+	if got := a.LatestAudits(10); len(got) != 0 {
+		t.Fatalf("snapshots were audited: %+v", got)
+	}
+	reply = send(`{"type":"submit"}`)
+	if reply.Type != "result" || reply.Result.AuditID == "" {
+		t.Fatalf("submit = %+v", reply)
+	}
+	if got := a.LatestAudits(10); len(got) != 1 {
+		t.Fatalf("want the submit audited, got %d audits", len(got))
+	}
+	if reply := send(`{"type":"nope"}`); reply.Type != "error" {
+		t.Fatalf("unknown type = %+v", reply)
+	}
+
+	limited := false
+	for range 2 * liveMessageBurst {
+		if reply := send(`{"type":"intake","intake":{"age":45}}`); reply.Type == "error" && strings.Contains(reply.Error, "rate limit") {
+			limited = true
+		}
+	}
+	if !limited {
+		t.Error("a burst of snapshots was never rate limited")
+	}
+
+	stop()
+	var closed *ws.CloseError
+	if _, _, err := conn.ReadMessage(); !errors.As(err, &closed) || closed.Code != ws.CloseGoingAway {
+		t.Fatalf("after shutdown: err = %v, want close %d", err, ws.CloseGoingAway)
+	}
+
+	// Oversized messages close the socket.
+	srv2 := httptest.NewServer(New(Config{Analyzer: a}))
+	defer srv2.Close()
+	conn, err = ws.Dial(t.Context(), "ws"+strings.TrimPrefix(srv2.URL, "http")+"/api/analyze/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.WriteMessage(ws.TextMessage, bytes.Repeat([]byte(" "), maxLiveMessageBytes+1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := conn.ReadMessage(); !errors.As(err, &closed) || closed.Code != ws.CloseMessageTooBig {
+		t.Fatalf("oversized message: err = %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	New(Config{Analyzer: a}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/analyze/ws", nil))
+	if rec.Code != http.StatusUpgradeRequired {
+		t.Fatalf("plain GET: status %d", rec.Code)
+	}
+}
+
+func TestInteractions(t *testing.T) {
+	a := analysis.New()
+	h := New(Config{Analyzer: a})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/interactions", strings.NewReader(
+		`{"medications":[{"name":"sildenafil"},{"name":"tamsulosin"},{"name":"doxazosin"}]}`)))
+	var report analysis.InteractionReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if len(report.Medications) != 3 || len(report.Pairs) != 3 {
+		t.Fatalf("report = %+v", report)
+	}
+	if got := a.LatestAudits(10); len(got) != 0 {
+		t.Fatalf("interaction check was audited: %+v", got)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/interactions", strings.NewReader(`{"medications":[]}`)))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), types.ProblemValidationFailed) {
+		t.Fatalf("empty list: status %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestTriage(t *testing.T) {
+	a := analysis.New()
+	h := New(Config{Analyzer: a})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/triage", strings.NewReader(
+		`{"patientName":"Partial","age":61,"complaint":"ED","conditions":["heart disease"]}`)))
+	var report analysis.TriageReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var nitrates bool
+	for _, q := range report.Questions {
+		nitrates = nitrates || (q.ID == "ed-cardiac-nitrates" && q.Blocking)
+	}
+	if !nitrates || !report.Questions[0].Blocking {
+		t.Fatalf("questions = %+v", report.Questions)
+	}
+	if got := a.LatestAudits(10); len(got) != 0 {
+		t.Fatalf("triage was audited: %+v", got)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/triage", strings.NewReader(`{"age":"old"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("malformed intake: status %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestAnalyze_SignedResponses(t *testing.T) {
+	a := analysis.New()
+	h := New(Config{Analyzer: a})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/keys/public", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("signing off: status %d", rec.Code)
+	}
+
+	_, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SetSigningKey("2026-10", priv); err != nil {
+		t.Fatal(err)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/keys/public", nil))
+	var key types.PublicKey
+	if err := json.Unmarshal(rec.Body.Bytes(), &key); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("public key: status %d: %s", rec.Code, rec.Body)
+	}
+	pub, err := base64.StdEncoding.DecodeString(key.PublicKey)
+	if err != nil || key.KeyID != "2026-10" || key.Algorithm != signing.Algorithm {
+		t.Fatalf("public key = %+v (err %v)", key, err)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/analyze", strings.NewReader(
+		`{"patientName":"Jane","age":45,"weight":70,"height":170,"bp":"120/80","complaint":"ED"}`)))
+	sig := rec.Header().Get(types.SignatureHeader)
+	if rec.Code != http.StatusOK || rec.Header().Get(types.SignatureKeyIDHeader) != "2026-10" {
+		t.Fatalf("status %d, headers %v", rec.Code, rec.Header())
+	}
+	if err := signing.Verify(pub, rec.Body.Bytes(), sig); err != nil {
+		t.Fatalf("verify analysis: %v", err)
+	}
+	tampered := bytes.Replace(rec.Body.Bytes(), []byte(`"riskScore":`), []byte(`"riskScore":1`), 1)
+	if err := signing.Verify(pub, tampered, sig); !errors.Is(err, signing.ErrBadSignature) {
+		t.Fatalf("tampered analysis: %v, want ErrBadSignature", err)
+	}
+
+	// The audit records the same signature, and reading it back is signed.
+	var resp analysis.Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	audits := a.LatestAudits(1)
+	if len(audits) != 1 || audits[0].Signature == nil || audits[0].Signature.Value != sig {
+		t.Fatalf("audit signature = %+v, want %s", audits, sig)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/audit/"+resp.AuditID, nil))
+	if err := signing.Verify(pub, rec.Body.Bytes(), rec.Header().Get(types.SignatureHeader)); err != nil {
+		t.Fatalf("verify audit read: %v (status %d)", err, rec.Code)
+	}
+}
+
+func TestDemoIntakes(t *testing.T) {
+	a := analysis.New()
+	h := New(Config{Analyzer: a})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/demo/intakes", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("demo mode off: status %d", rec.Code)
+	}
+
+	a.SetDemoMode(true)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/demo/intakes", nil))
+	var cases []analysis.DemoCase
+	if err := json.Unmarshal(rec.Body.Bytes(), &cases); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if len(cases) != len(analysis.DemoCases()) || cases[0].ID == "" || cases[0].Intake.PatientName == "" {
+		t.Fatalf("cases = %+v", cases)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	a := analysis.New()
+	h := New(Config{Analyzer: a})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/validate", strings.NewReader(
+		`{"patientName":"Form","age":45,"weight":70,"height":170,"bp":"120/80","complaint":"ED","medications":[{"name":"Sildenafil"}]}`)))
+	var report analysis.ValidationReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if !report.Valid || report.Preview == nil || report.Preview.ComputedBMI != 24.2 || report.Preview.Medications[0] != "sildenafil" {
+		t.Fatalf("report = %+v", report)
+	}
+	if got := a.LatestAudits(10); len(got) != 0 {
+		t.Fatalf("validation was audited: %+v", got)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/validate", strings.NewReader(`{"age":"x"}`)))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"valid":false`) {
+		t.Fatalf("schema failure: status %d: %s", rec.Code, rec.Body)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/validate", strings.NewReader(`{`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("malformed body: status %d", rec.Code)
+	}
+}
+
+func TestStatic(t *testing.T) {
+	h := New(Config{Static: fstest.MapFS{
+		"landing.html":   {Data: []byte("<p>landing</p>")},
+		"intake.html":    {Data: []byte("<p>intake</p>")},
+		"assets/app.css": {Data: []byte("body{}")},
+	}, AppPage: "intake.html"})
+
+	for path, want := range map[string]int{
+		"/app":            http.StatusOK,
+		"/assets/app.css": http.StatusOK,
+		"/assets/":        http.StatusNotFound,
+		"/assets/missing": http.StatusNotFound,
+		"/unknown":        http.StatusNotFound,
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Errorf("%s: status %d, want %d", path, rec.Code, want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/app", nil))
+	if rec.Body.String() != "<p>intake</p>" || rec.Header().Get("Cache-Control") != "no-cache" {
+		t.Fatalf("/app: %q, headers %v", rec.Body, rec.Header())
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/assets/app.css", nil))
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/css") || !strings.HasPrefix(rec.Header().Get("Cache-Control"), "public") {
+		t.Fatalf("/assets/app.css headers = %v", rec.Header())
+	}
+}
+
+func TestStatic_WASM(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "analysis.wasm"), []byte("\x00asm\x01\x00\x00\x00"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	h := New(Config{WASMDir: dir, Static: fstest.MapFS{"assets/app.css": {Data: []byte("body{}")}}})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/assets/wasm/analysis.wasm", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/wasm" {
+		t.Fatalf("analysis.wasm: status %d, headers %v", rec.Code, rec.Header())
+	}
+	if csp := rec.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "'wasm-unsafe-eval'") {
+		t.Fatalf("CSP %q does not allow compiling wasm", csp)
+	}
+	for _, path := range []string{"/assets/wasm/", "/assets/wasm/missing.js"} {
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: status %d, want 404", path, rec.Code)
+		}
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/assets/app.css", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("/assets/app.css: status %d", rec.Code)
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	h := New(Config{Analyzer: analysis.New(), Static: fstest.MapFS{
+		"landing.html":  {Data: []byte("<p>landing</p>")},
+		"app.html":      {Data: []byte("<p>app</p>")},
+		"assets/app.js": {Data: []byte("void 0")},
+	}})
+	want := map[string]string{
+		"Content-Security-Policy": DefaultContentSecurityPolicy,
+		"X-Content-Type-Options":  "nosniff",
+		"X-Frame-Options":         "DENY",
+		"Referrer-Policy":         "strict-origin-when-cross-origin",
+	}
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/app", nil),
+		httptest.NewRequest(http.MethodGet, "/assets/app.js", nil),
+		httptest.NewRequest(http.MethodGet, "/unknown", nil),
+		httptest.NewRequest(http.MethodPost, "/api/analyze", strings.NewReader("{")),
+		httptest.NewRequest(http.MethodGet, "/readyz", nil),
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		for k, v := range want {
+			if got := rec.Header().Get(k); got != v {
+				t.Errorf("%s %s: %s = %q, want %q", req.Method, req.URL.Path, k, got, v)
+			}
+		}
+		if got := rec.Header().Get("Strict-Transport-Security"); got != "" {
+			t.Errorf("%s over plain HTTP: Strict-Transport-Security = %q", req.URL.Path, got)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "https://example.com/app", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if !strings.HasPrefix(rec.Header().Get("Strict-Transport-Security"), "max-age=") {
+		t.Fatalf("TLS request: headers %v", rec.Header())
+	}
+
+	h = New(Config{ContentSecurityPolicy: "default-src 'none'"})
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if got := rec.Header().Get("Content-Security-Policy"); got != "default-src 'none'" {
+		t.Fatalf("configured CSP = %q", got)
+	}
+}
+
+func TestProblemDetails(t *testing.T) {
+	a := analysis.New()
+	h := New(Config{Analyzer: a, Static: fstest.MapFS{"landing.html": {Data: []byte("<p>landing</p>")}}})
+	base := a.Analyze(analysis.Intake{PatientName: "Problem", Age: 45, WeightKg: 70, HeightCm: 170, BP: "120/80", Complaint: "ED"})
+	cases := []struct {
+		name, method, path, body string
+		status                   int
+		typ                      string
+	}{
+		{"invalid payload", http.MethodPost, "/api/analyze", "{", http.StatusBadRequest, types.ProblemInvalidPayload},
+		{"validation", http.MethodPost, "/api/analyze", `{"age":0}`, http.StatusBadRequest, types.ProblemValidationFailed},
+		{"invalid patch", http.MethodPost, "/api/analyze/whatif", `{"auditId":"` + base.AuditID + `","patch":[{"op":"move","path":"/bp"}]}`, http.StatusBadRequest, types.ProblemInvalidPatch},
+		{"method not allowed", http.MethodGet, "/api/analyze", "", http.StatusMethodNotAllowed, "about:blank"},
+		{"unknown API route", http.MethodGet, "/api/nope", "", http.StatusNotFound, "about:blank"},
+		{"unknown audit", http.MethodGet, "/api/audit/missing", "", http.StatusNotFound, "about:blank"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("X-Request-ID", "req-42")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tc.status || rec.Header().Get("Content-Type") != "application/problem+json" {
+				t.Fatalf("status %d, Content-Type %q: %s", rec.Code, rec.Header().Get("Content-Type"), rec.Body)
+			}
+			var p types.Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+				t.Fatal(err)
+			}
+			if p.Type != tc.typ || p.Status != tc.status || p.Title == "" || !strings.HasSuffix(p.Instance, ":req-42") {
+				t.Fatalf("problem = %+v", p)
+			}
+			if tc.typ == types.ProblemValidationFailed && len(p.Errors) == 0 {
+				t.Fatalf("validation problem has no errors: %+v", p)
+			}
+		})
+	}
+
+	// Unknown non-API paths keep the plain 404; requests without an ID get one.
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/nope", nil))
+	if rec.Code != http.StatusNotFound || rec.Header().Get("Content-Type") == "application/problem+json" || rec.Header().Get("X-Request-ID") == "" {
+		t.Fatalf("/nope: status %d, headers %v", rec.Code, rec.Header())
+	}
+}
+
+func TestRequestLogging(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	h := New(Config{Analyzer: analysis.New(), SlowRequest: time.Nanosecond})
+	before := requestDuration.Count("/api/analyze", http.MethodPost, "400")
+	req := httptest.NewRequest(http.MethodPost, "/api/analyze?complaint=secret", strings.NewReader(`{"patientName":"Jane Doe"`))
+	req.Header.Set("X-Request-ID", "log-1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	out := logs.String()
+	for _, want := range []string{"http method=POST path=/api/analyze status=400", "request_id=log-1", "warn: slow request method=POST route=/api/analyze"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Jane") || strings.Contains(out, "secret") {
+		t.Errorf("log leaks request data:\n%s", out)
+	}
+	if got := requestDuration.Count("/api/analyze", http.MethodPost, "400"); got != before+1 {
+		t.Errorf("histogram count = %d, want %d", got, before+1)
+	}
+}
+
+func TestSlowThreshold_Adaptive(t *testing.T) {
+	now := time.Now()
+	fixed := newSlowThreshold(0, 0)
+	adaptive := newSlowThreshold(100*time.Millisecond, 90)
+	for range slowMinSamples {
+		fixed.observe(now, 2*time.Second)
+		adaptive.observe(now, 800*time.Millisecond)
+	}
+	if got := fixed.observe(now, time.Second); got != DefaultSlowRequest {
+		t.Fatalf("fixed threshold = %v", got)
+	}
+	// Every recent request took 800ms, so only slower outliers are slow.
+	if got := adaptive.observe(now, time.Second); got != 800*time.Millisecond {
+		t.Fatalf("adaptive threshold = %v, want the p90 of recent requests", got)
+	}
+	quiet := newSlowThreshold(time.Second, 90)
+	for range slowMinSamples {
+		quiet.observe(now, time.Millisecond)
+	}
+	if got := quiet.observe(now, time.Millisecond); got != time.Second {
+		t.Fatalf("adaptive threshold = %v, want no lower than the floor", got)
+	}
+}
+
+func TestLatencyStats(t *testing.T) {
+	a := analysis.New()
+	if err := a.SetSLO(analysis.SLO{Percentile: 95, Threshold: 500 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	h := New(Config{Analyzer: a})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/analyze", strings.NewReader(`{"patientName":"A B","age":40,"weight":80,"height":180,"bp":"120/80","complaint":"ED"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("analyze: %d %s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/audit/latency-stats", nil))
+	var stats analysis.LatencyStats
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status %d, err %v", rec.Code, err)
+	}
+	if stats.SLO == nil || stats.SLO.Percentile != 95 || len(stats.Windows) != 3 ||
+		stats.Windows[0].Scope != analysis.LatencyAll || stats.Windows[0].Count != 1 || stats.Windows[1].Key != "ed" {
+		t.Fatalf("stats = %+v", stats)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api/audit/latency-stats", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("DELETE: %d", rec.Code)
+	}
+}
+
+func TestTracing(t *testing.T) {
+	rec := &trace.Recorder{}
+	trace.SetExporter(rec)
+	defer trace.SetExporter(nil)
+
+	h := New(Config{Analyzer: analysis.New()})
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/analyze", strings.NewReader(
+		`{"patientName":"Traced Patient","age":45,"weight":70,"height":170,"bp":"120/80","complaint":"ED"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	byName := map[string]trace.SpanData{}
+	for _, s := range rec.Spans() {
+		byName[s.Name] = s
+		for _, a := range s.Attributes {
+			if v, ok := a.Value.(string); ok && strings.Contains(v, "Traced") {
+				t.Errorf("span %s attribute %s holds the patient name", s.Name, a.Key)
+			}
+		}
+	}
+	root, ok := byName["POST /api/analyze"]
+	if !ok || !root.ParentID.IsZero() || root.Attr("http.response.status_code") != int64(http.StatusOK) {
+		t.Fatalf("server span = %+v (spans %v)", root, rec.Spans())
+	}
+	analyze := byName["analysis.analyze"]
+	if analyze.ParentID != root.SpanID || analyze.Attr("analysis.complaint") != "ED" || analyze.Attr("analysis.risk_level") == nil {
+		t.Fatalf("analyze span = %+v", analyze)
+	}
+	for _, name := range []string{"analysis.validate", "analysis.build_plan", "analysis.interactions", "analysis.llm_score", "audit.insert", "analysis.validate_response"} {
+		s, ok := byName[name]
+		if !ok || s.ParentID != analyze.SpanID || s.TraceID != root.TraceID {
+			t.Errorf("%s: %+v, want child of analysis.analyze", name, s)
+		}
+	}
+}
+
+func TestAdminRules(t *testing.T) {
+	a := analysis.New()
+	path := t.TempDir() + "/rules.json"
+	h := New(Config{Analyzer: a, AdminToken: "s3cret", RulesPath: path})
+	do := func(method, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/admin/rules", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodGet, "", ""); rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+		t.Fatalf("no token: status %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "wrong", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong token: status %d", rec.Code)
+	}
+	rec := do(http.MethodGet, "s3cret", "")
+	var info analysis.RulesInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("get: status %d: %s", rec.Code, rec.Body)
+	}
+	if info.Source != "embedded" || len(info.Interactions) == 0 || len(info.DoseCaps) == 0 || len(info.DrugClasses) == 0 {
+		t.Fatalf("rules = %+v", info)
+	}
+
+	dup := append(info.Interactions, info.Interactions[0])
+	body, _ := json.Marshal(analysis.Ruleset{Interactions: dup, DoseCaps: info.DoseCaps, DrugClasses: info.DrugClasses})
+	if rec := do(http.MethodPut, "s3cret", string(body)); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), types.ProblemValidationFailed) {
+		t.Fatalf("duplicate pair: status %d: %s", rec.Code, rec.Body)
+	}
+
+	body, _ = json.Marshal(analysis.Ruleset{Interactions: info.Interactions[1:], DoseCaps: info.DoseCaps, DrugClasses: info.DrugClasses})
+	rec = do(http.MethodPut, "s3cret", string(body))
+	var replaced analysis.RulesInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &replaced); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("put: status %d: %s", rec.Code, rec.Body)
+	}
+	if replaced.Version == info.Version || replaced.Source != path || a.Rules().Version != replaced.Version {
+		t.Fatalf("replaced = %+v", replaced)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("rules not persisted: %v", err)
+	}
+
+	if rec := do(http.MethodDelete, "s3cret", ""); rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "GET, PUT, OPTIONS" {
+		t.Fatalf("delete: status %d, Allow %q", rec.Code, rec.Header().Get("Allow"))
+	}
+
+	closed := New(Config{Analyzer: a})
+	rec = httptest.NewRecorder()
+	closed.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/admin/rules", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("no admin token configured: status %d", rec.Code)
+	}
+}
+
+func TestAdminAudit(t *testing.T) {
+	h := New(Config{Analyzer: analysis.New(), AdminToken: "s3cret"})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/analyze", strings.NewReader(`{"patientName":"Admin Audit","age":50,"weight":80,"height":175,"bp":"125/80","complaint":"ED"}`)))
+	var resp analysis.Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.AuditID == "" {
+		t.Fatalf("analyze: %s", rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/audit/"+resp.AuditID, nil))
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), `"intake"`) {
+		t.Fatalf("public audit should not carry the intake: status %d: %s", rec.Code, rec.Body)
+	}
+
+	get := func(id, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/audit/"+id, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := get(resp.AuditID, ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("no token: status %d", rec.Code)
+	}
+	rec = get(resp.AuditID, "s3cret")
+	var got analysis.AuditDetail
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("admin audit: status %d: %s", rec.Code, rec.Body)
+	}
+	if got.Response.AuditID != resp.AuditID || got.Intake == nil || got.Intake.PatientRef == "" || got.Intake.BP != "125/80" {
+		t.Fatalf("admin audit = %+v", got)
+	}
+	if strings.Contains(rec.Body.String(), "Admin Audit") {
+		t.Fatalf("admin audit leaks the name: %s", rec.Body)
+	}
+	if rec := get("missing", "s3cret"); rec.Code != http.StatusNotFound {
+		t.Fatalf("missing: status %d", rec.Code)
+	}
+}
+
+func TestSoftDeleteAudit(t *testing.T) {
+	h := New(Config{Analyzer: analysis.New(), AdminToken: "s3cret"})
+	do := func(method, target, body string, admin bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if admin {
+			req.Header.Set("Authorization", "Bearer s3cret")
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	var resp analysis.Response
+	if rec := do(http.MethodPost, "/api/analyze", `{"patientName":"Soft Delete","age":50,"weight":80,"height":175,"bp":"125/80","complaint":"ED"}`, false); json.Unmarshal(rec.Body.Bytes(), &resp) != nil || resp.AuditID == "" {
+		t.Fatalf("analyze: %s", rec.Body)
+	}
+	listed := func(target string, admin bool) int {
+		rec := do(http.MethodGet, target, "", admin)
+		var audits []analysis.AuditSummary
+		if err := json.Unmarshal(rec.Body.Bytes(), &audits); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", target, rec.Code, rec.Body)
+		}
+		return len(audits)
+	}
+
+	deleteURL := "/api/admin/audit/" + resp.AuditID + "/delete"
+	if rec := do(http.MethodPost, deleteURL, `{"reason":"test entry"}`, false); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("no token: status %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, deleteURL, `{"reason":" "}`, true); rec.Code != http.StatusBadRequest {
+		t.Fatalf("blank reason: status %d", rec.Code)
+	}
+	rec := do(http.MethodPost, deleteURL, `{"reason":"test entry"}`, true)
+	var d analysis.AuditDeletion
+	if err := json.Unmarshal(rec.Body.Bytes(), &d); err != nil || rec.Code != http.StatusOK || d.Action != audit.ActionDeleted || d.UserID != "admin" {
+		t.Fatalf("delete: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodPost, deleteURL, `{"reason":"again"}`, true); rec.Code != http.StatusConflict {
+		t.Fatalf("second delete: status %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/admin/audit/missing/delete", `{"reason":"x"}`, true); rec.Code != http.StatusNotFound {
+		t.Fatalf("missing: status %d", rec.Code)
+	}
+
+	if n := listed("/api/audit", false); n != 0 {
+		t.Fatalf("deleted audit listed: %d", n)
+	}
+	if rec := do(http.MethodGet, "/api/audit?includeDeleted=true", "", false); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("includeDeleted without token: status %d", rec.Code)
+	}
+	if n := listed("/api/audit?includeDeleted=true", true); n != 1 {
+		t.Fatalf("includeDeleted listed %d", n)
+	}
+	if rec := do(http.MethodGet, "/api/audit?includeDeleted=true&includeInvalid=true", "", true); rec.Code != http.StatusBadRequest {
+		t.Fatalf("includeDeleted with includeInvalid: status %d", rec.Code)
+	}
+
+	if rec := do(http.MethodPost, "/api/admin/audit/"+resp.AuditID+"/restore", "", true); rec.Code != http.StatusOK {
+		t.Fatalf("restore: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodPost, "/api/admin/audit/"+resp.AuditID+"/restore", "", true); rec.Code != http.StatusConflict {
+		t.Fatalf("second restore: status %d", rec.Code)
+	}
+	if n := listed("/api/audit", false); n != 1 {
+		t.Fatalf("restored audit not listed: %d", n)
+	}
+	var detail analysis.AuditDetail
+	if rec := do(http.MethodGet, "/api/admin/audit/"+resp.AuditID, "", true); json.Unmarshal(rec.Body.Bytes(), &detail) != nil || len(detail.Deletions) != 2 {
+		t.Fatalf("admin audit trail: %s", rec.Body)
+	}
+}
+
+func TestBackup(t *testing.T) {
+	store, err := audit.NewSQLiteStore(filepath.Join(t.TempDir(), "audit.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	dir := filepath.Join(t.TempDir(), "backups")
+	post := func(h http.Handler) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/backup", nil)
+		req.Header.Set("Authorization", "Bearer s3cret")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	h := New(Config{Analyzer: analysis.New(analysis.WithAuditStore(store)), AdminToken: "s3cret", BackupDir: dir, BackupKeep: 1})
+	var info audit.BackupInfo
+	for range 2 {
+		rec := post(h)
+		if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil || rec.Code != http.StatusOK || info.SizeBytes == 0 {
+			t.Fatalf("backup: status %d: %s", rec.Code, rec.Body)
+		}
+	}
+	if len(info.Pruned) != 1 || filepath.Dir(info.Path) != dir {
+		t.Fatalf("second backup = %+v", info)
+	}
+	if rec := post(New(Config{Analyzer: analysis.New(analysis.WithAuditStore(store)), AdminToken: "s3cret"})); rec.Code != http.StatusNotImplemented {
+		t.Fatalf("unconfigured: status %d", rec.Code)
+	}
+	if rec := post(New(Config{Analyzer: analysis.New(), AdminToken: "s3cret", BackupDir: dir})); rec.Code != http.StatusNotImplemented {
+		t.Fatalf("memory store: status %d", rec.Code)
+	}
+}
+
+func TestBundle(t *testing.T) {
+	a := analysis.New()
+	h := New(Config{Analyzer: a, AdminToken: "s3cret"})
+	get := func(target, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := get("/api/admin/bundle", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("no token: status %d", rec.Code)
+	}
+	if rec := get("/api/admin/bundle", "s3cret"); rec.Code != http.StatusNotImplemented {
+		t.Fatalf("signing off: status %d", rec.Code)
+	}
+
+	_, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SetSigningKey("", priv); err != nil {
+		t.Fatal(err)
+	}
+	if rec := get("/api/admin/bundle?version=0", "s3cret"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("version 0: status %d", rec.Code)
+	}
+	rec := get("/api/admin/bundle?version=4", "s3cret")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/gzip" || rec.Header().Get("X-Bundle-Version") != "4" {
+		t.Fatalf("export: status %d headers %v", rec.Code, rec.Header())
+	}
+	m, err := analysis.New().ApplyBundle(rec.Body.Bytes(), []ed25519.PublicKey{priv.Public().(ed25519.PublicKey)}, false)
+	if err != nil || m.Version != 4 || m.Hash != rec.Header().Get("X-Bundle-Hash") {
+		t.Fatalf("apply exported bundle: %+v, %v", m, err)
+	}
+}
+
+func TestReanalyze(t *testing.T) {
+	a := analysis.New()
+	h := New(Config{Analyzer: a})
+	var ids []string
+	for _, body := range []string{
+		`{"patientName":"Replay One","age":50,"weight":80,"height":175,"bp":"125/80","complaint":"ED","medications":[{"name":"aspirin"},{"name":"omeprazole"}]}`,
+		`{"patientName":"Replay Two","age":35,"weight":70,"height":170,"bp":"118/76","complaint":"ED"}`,
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/analyze", strings.NewReader(body)))
+		var resp analysis.Response
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.AuditID == "" {
+			t.Fatalf("analyze: %s", rec.Body)
+		}
+		ids = append(ids, resp.AuditID)
+	}
+	r := a.Rules().Ruleset
+	r.Interactions = append(r.Interactions, analysis.InteractionRule{Code: "DDI_ASPIRIN_OMEPRAZOLE", Drug: "aspirin", With: "omeprazole", Severity: "info"})
+	if _, err := a.ReplaceRules(t.Context(), r, "", "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/audit/"+ids[0]+"/reanalyze", nil))
+	var single analysis.Reanalysis
+	if err := json.Unmarshal(rec.Body.Bytes(), &single); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("single: status %d: %s", rec.Code, rec.Body)
+	}
+	if !single.Changed || single.AuditID != ids[0] || single.Diff == nil || len(single.Diff.IssuesAdded) != 1 {
+		t.Fatalf("single = %+v", single)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/audit/reanalyze?from=2000-01-01T00:00:00Z", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != ndjsonContentType {
+		t.Fatalf("bulk: status %d, type %q: %s", rec.Code, rec.Header().Get("Content-Type"), rec.Body)
+	}
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("bulk lines = %q", lines)
+	}
+	for i, line := range lines {
+		var res analysis.Reanalysis
+		if err := json.Unmarshal([]byte(line), &res); err != nil || res.AuditID != ids[i] || res.Changed != (i == 0) {
+			t.Fatalf("line %d = %s (err %v)", i, line, err)
+		}
+	}
+
+	for path, want := range map[string]int{
+		"/api/audit/missing/reanalyze":        http.StatusNotFound,
+		"/api/audit/reanalyze?from=yesterday": http.StatusBadRequest,
+		"/api/audit/reanalyze?limit=0":        http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+		if rec.Code != want {
+			t.Errorf("%s: status %d, want %d: %s", path, rec.Code, want, rec.Body)
+		}
+	}
+}
+
+// uploadCSV builds a multipart request posting data as the part named file.
+func uploadCSV(t *testing.T, url, data string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", "patients.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(fw, data); err != nil {
+		t.Fatal(err)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, url, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestImport(t *testing.T) {
+	a := analysis.New()
+	h := New(Config{Analyzer: a})
+	const data = "Name,Age,Weight,Height,BP,Conditions,Medications,Complaint\n" +
+		"Row Ok,45,70,170,120/80,none,none,ED\n" +
+		"Row Unreadable,forty,70,170,120/80,none,none,ED\n" +
+		"Row Invalid,45,70,170,,none,none,ED\n"
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, uploadCSV(t, "/api/analyze/import", data))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != ndjsonContentType {
+		t.Fatalf("status %d, type %q: %s", rec.Code, rec.Header().Get("Content-Type"), rec.Body)
+	}
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("lines = %q", lines)
+	}
+	var results []analysis.ImportResult
+	for _, line := range lines {
+		var res analysis.ImportResult
+		if err := json.Unmarshal([]byte(line), &res); err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, res)
+	}
+	if r := results[0]; r.Line != 2 || r.AuditID == "" || r.RiskLevel == "" || len(r.Errors) > 0 {
+		t.Errorf("row 2 = %+v", r)
+	}
+	if r := results[1]; r.Line != 3 || r.AuditID != "" || len(r.Errors) != 1 {
+		t.Errorf("row 3 should not be analyzed: %+v", r)
+	}
+	if r := results[2]; r.Line != 4 || r.RiskLevel != "" || len(r.Errors) == 0 {
+		t.Errorf("row 4 should fail validation: %+v", r)
+	}
+	if got := a.LatestAudits(10); len(got) != 1 {
+		t.Fatalf("want one audit, got %d", len(got))
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, uploadCSV(t, "/api/analyze/import?format=csv&dryRun=true", data+"Row Bad,\"45,70\n"))
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), csvContentType) {
+		t.Fatalf("csv: status %d, type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil || len(records) != 5 {
+		t.Fatalf("csv records = %q (err %v)", records, err)
+	}
+	if got := strings.Join(records[0], ","); got != "line,auditId,riskLevel,dryRun,errors" {
+		t.Errorf("csv header = %s", got)
+	}
+	if r := records[1]; r[0] != "2" || r[1] != "" || r[2] == "" || r[3] != "true" {
+		t.Errorf("dry-run row = %q", r)
+	}
+	if r := records[4]; r[0] != "" || !strings.Contains(r[4], "line 5 is not valid CSV") {
+		t.Errorf("final row should report the malformed line: %q", r)
+	}
+
+	for name, req := range map[string]*http.Request{
+		"unknown column": uploadCSV(t, "/api/analyze/import", "name,age,complaint,notes\n"),
+		"not multipart":  httptest.NewRequest(http.MethodPost, "/api/analyze/import", strings.NewReader(data)),
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400: %s", name, rec.Code, rec.Body)
+		}
+	}
+}
+
+// TestImport_Streaming uploads more rows than one batch over a real
+// connection, so results are written while the upload is still being read.
+func TestImport_Streaming(t *testing.T) {
+	srv := httptest.NewServer(New(Config{Analyzer: analysis.New()}))
+	defer srv.Close()
+	var data strings.Builder
+	data.WriteString("name,age,weight,height,bp,conditions,medications,complaint\n")
+	const rows = maxBatchItems + 50
+	for i := range rows {
+		fmt.Fprintf(&data, "Patient %d,45,70,170,120/80,none,none,hair loss\n", i)
+	}
+	req := uploadCSV(t, srv.URL+"/api/analyze/import?dryRun=true", data.String())
+	req.RequestURI = ""
+	req.Header.Set("Accept", "text/csv")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	records, err := csv.NewReader(resp.Body).ReadAll()
+	if err != nil || len(records) != rows+1 {
+		t.Fatalf("got %d records, want %d (err %v)", len(records), rows+1, err)
+	}
+	if last := records[rows]; last[0] != strconv.Itoa(rows+1) || last[4] != "" {
+		t.Fatalf("last row = %q", last)
+	}
+}
+
+func TestNote(t *testing.T) {
+	h := New(Config{Analyzer: analysis.New()})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/analyze", strings.NewReader(
+		`{"patientName":"Note Patient","age":50,"weight":80,"height":175,"bp":"125/80","complaint":"ED"}`)))
+	var resp analysis.Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.AuditID == "" {
+		t.Fatalf("analyze: %s", rec.Body)
+	}
+
+	for query, want := range map[string]string{"": "text/markdown", "?format=markdown": "text/markdown", "?format=text": "text/plain"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/report/"+resp.AuditID+"/note"+query, nil))
+		if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), want) {
+			t.Fatalf("%q: status %d, type %q: %s", query, rec.Code, rec.Header().Get("Content-Type"), rec.Body)
+		}
+		if body := rec.Body.String(); strings.Contains(body, "Note Patient") || !strings.Contains(body, resp.AuditID) {
+			t.Errorf("%q: note must name the audit but not the patient:\n%s", query, body)
+		}
+	}
+
+	for path, want := range map[string]int{
+		"/api/report/" + resp.AuditID + "/note?format=html": http.StatusBadRequest,
+		"/api/report/missing/note":                          http.StatusNotFound,
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Errorf("%s: status %d, want %d", path, rec.Code, want)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	h := New(Config{Analyzer: analysis.New()})
+	analyze := func(body string) string {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/analyze", strings.NewReader(body)))
+		var resp analysis.Response
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.AuditID == "" {
+			t.Fatalf("analyze: %s", rec.Body)
+		}
+		return resp.AuditID
+	}
+	first := analyze(`{"patientName":"Compare Patient","age":50,"weight":80,"height":175,"bp":"125/80","complaint":"ED"}`)
+	second := analyze(`{"patientName":"Compare Patient","age":50,"weight":90,"height":175,"bp":"150/95","complaint":"ED"}`)
+	other := analyze(`{"patientName":"Someone Else","age":50,"weight":80,"height":175,"bp":"125/80","complaint":"ED"}`)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/audit/compare?a="+first+"&b="+second, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var cmp analysis.AuditComparison
+	if err := json.Unmarshal(rec.Body.Bytes(), &cmp); err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.SamePatient || cmp.A.AuditID != first || cmp.B.AuditID != second {
+		t.Errorf("comparison = %+v", cmp)
+	}
+	if cmp.Diff.BP == nil || cmp.Diff.BP.SystolicDelta != 25 || cmp.Diff.BMI == nil || cmp.Diff.BMI.Delta <= 0 {
+		t.Errorf("diff = %+v, want BP and BMI deltas", cmp.Diff)
+	}
+
+	for path, want := range map[string]int{
+		"/api/audit/compare?a=" + first:                                 http.StatusBadRequest,
+		"/api/audit/compare?a=" + first + "&b=missing":                  http.StatusNotFound,
+		"/api/audit/compare?a=" + first + "&b=" + other:                 http.StatusConflict,
+		"/api/audit/compare?a=" + first + "&b=" + other + "&force=true": http.StatusOK,
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Errorf("%s: status %d, want %d", path, rec.Code, want)
+		}
+	}
+}
+
+// failingStore rejects every audit write.
+type failingStore struct{ *audit.MemoryStore }
+
+func (failingStore) Insert(context.Context, audit.Entry) (audit.Summary, error) {
+	return audit.Summary{}, errors.New("disk I/O error")
+}
+
+func (failingStore) InsertBatch(context.Context, []audit.Entry) ([]audit.Summary, error) {
+	return nil, errors.New("disk I/O error")
+}
+
+func TestAnalyze_AuditWriteFailure(t *testing.T) {
+	const body = `{"patientName":"Unaudited","age":50,"weight":80,"height":175,"bp":"125/80","complaint":"Hair Loss"}`
+	a := analysis.New(analysis.WithAuditStore(failingStore{audit.NewMemoryStore()}))
+
+	rec := httptest.NewRecorder()
+	New(Config{Analyzer: a}).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/analyze", strings.NewReader(body)))
+	var resp analysis.Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if resp.AuditRecorded == nil || *resp.AuditRecorded || resp.AuditID != "" {
+		t.Fatalf("auditRecorded = %v, auditId %q", resp.AuditRecorded, resp.AuditID)
+	}
+
+	strict := New(Config{Analyzer: a, StrictAudit: true})
+	for path, body := range map[string]string{
+		"/api/analyze":       body,
+		"/api/analyze/batch": `{"items":[{"intake":` + body + `}]}`,
+	} {
+		rec := httptest.NewRecorder()
+		strict.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		if rec.Code != http.StatusServiceUnavailable || strings.Contains(rec.Body.String(), "riskLevel") {
+			t.Errorf("%s in strict mode: status %d: %s", path, rec.Code, rec.Body)
+		}
+	}
+	rec = httptest.NewRecorder()
+	strict.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/analyze?dryRun=true", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Errorf("dry run in strict mode: status %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestAudits_LocalTime(t *testing.T) {
+	h := New(Config{Analyzer: analysis.New()})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/analyze", strings.NewReader(
+		`{"patientName":"Manila Patient","age":50,"weight":80,"height":175,"bp":"125/80","complaint":"ED"}`)))
+	var resp analysis.Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.AuditID == "" {
+		t.Fatalf("analyze: %s", rec.Body)
+	}
+	utc, err := time.Parse(time.RFC3339, resp.AuditAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manila := utc.In(time.FixedZone("PHT", 8*60*60)).Format(time.RFC3339)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+	var audits []analysis.AuditSummary
+	if err := json.Unmarshal(get("/api/audit?tz=Asia/Manila").Body.Bytes(), &audits); err != nil || len(audits) != 1 {
+		t.Fatalf("audits: %v %+v", err, audits)
+	}
+	if audits[0].At != resp.AuditAt || audits[0].LocalTime != manila {
+		t.Errorf("at %q, localTime %q; want %q, %q", audits[0].At, audits[0].LocalTime, resp.AuditAt, manila)
+	}
+	if err := json.Unmarshal(get("/api/audit").Body.Bytes(), &audits); err != nil || audits[0].LocalTime != resp.AuditAt {
+		t.Errorf("default localTime = %q, want UTC %q", audits[0].LocalTime, resp.AuditAt)
+	}
+	var one analysis.Response
+	if err := json.Unmarshal(get("/api/audit/"+resp.AuditID+"?tz=Asia/Manila").Body.Bytes(), &one); err != nil || one.LocalTime != manila {
+		t.Errorf("single audit localTime = %q, want %q", one.LocalTime, manila)
+	}
+
+	for query, header := range map[string]string{
+		"format=csv":                "auditId,at,patientRef",
+		"format=csv&tz=Asia/Manila": "auditId,at,localTime,patientRef",
+	} {
+		rec := get("/api/audit?" + query)
+		rows, err := csv.NewReader(rec.Body).ReadAll()
+		if err != nil || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/csv") || len(rows) != 2 {
+			t.Fatalf("%s: %v, type %q, %d rows", query, err, rec.Header().Get("Content-Type"), len(rows))
+		}
+		if got := strings.Join(rows[0], ","); !strings.HasPrefix(got, header) {
+			t.Errorf("%s: header %q, want prefix %q", query, got, header)
+		}
+		if strings.Contains(query, "tz") && (rows[1][1] != resp.AuditAt || rows[1][2] != manila) {
+			t.Errorf("%s: row %q", query, rows[1])
+		}
+	}
+
+	for _, path := range []string{"/api/audit?tz=Mars/Olympus", "/api/audit/" + resp.AuditID + "?tz=Local", "/api/patients/p/analyses?tz=nowhere"} {
+		if rec := get(path); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", path, rec.Code)
+		}
+	}
+}
+
+func TestOrgScoping(t *testing.T) {
+	a := analysis.New(analysis.WithAuditStore(audit.NewMemoryStore(audit.WithCapacity(0))))
+	if err := a.SetOrgs(map[string]analysis.OrgConfig{
+		"clinic-a": {APIKeys: []string{"key-a"}, Disclaimers: []string{"Clinic A pilot."}},
+		"clinic-b": {APIKeys: []string{"key-b"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	h := New(Config{Analyzer: a, AdminToken: "s3cret"})
+	do := func(method, target, body, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	const intake = `{"patientName":"Org Scope","age":50,"weight":80,"height":175,"bp":"125/80","complaint":"ED"}`
+	for _, key := range []string{"", "wrong"} {
+		if rec := do(http.MethodPost, "/api/analyze", intake, key); rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+			t.Fatalf("key %q: status %d", key, rec.Code)
+		}
+	}
+
+	const perOrg = 10
+	ids := map[string][]string{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, key := range []string{"key-a", "key-b"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range perOrg {
+				rec := do(http.MethodPost, "/api/analyze", intake, key)
+				var resp analysis.Response
+				if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+					t.Errorf("%s analyze: status %d: %s", key, rec.Code, rec.Body)
+					return
+				}
+				if (key == "key-a") != slices.Equal(resp.Disclaimers, []string{"Clinic A pilot."}) {
+					t.Errorf("%s disclaimers %q", key, resp.Disclaimers)
+				}
+				if rec := do(http.MethodGet, "/api/audit", "", key); rec.Code != http.StatusOK {
+					t.Errorf("%s list: status %d", key, rec.Code)
+				}
+				mu.Lock()
+				ids[key] = append(ids[key], resp.AuditID)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if t.Failed() {
+		t.FailNow()
+	}
+
+	for key, own := range ids {
+		var audits []analysis.AuditSummary
+		rec := do(http.MethodGet, "/api/audit?limit=100", "", key)
+		if err := json.Unmarshal(rec.Body.Bytes(), &audits); err != nil || len(audits) != perOrg {
+			t.Fatalf("%s lists %d audits: %s", key, len(audits), rec.Body)
+		}
+		for _, sum := range audits {
+			if !slices.Contains(own, sum.AuditID) {
+				t.Errorf("%s listed foreign audit %s", key, sum.AuditID)
+			}
+		}
+		if rec := do(http.MethodGet, "/api/audit/"+own[0], "", key); rec.Code != http.StatusOK {
+			t.Errorf("%s reading its own audit: status %d", key, rec.Code)
+		}
+	}
+	if rec := do(http.MethodGet, "/api/audit/"+ids["key-a"][0], "", "key-b"); rec.Code != http.StatusNotFound {
+		t.Fatalf("cross-org read: status %d, want 404", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/api/report/"+ids["key-a"][0]+"/note", "", "key-b"); rec.Code != http.StatusNotFound {
+		t.Fatalf("cross-org note: status %d, want 404", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/rules", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("global admin route without an org key: status %d", rec.Code)
+	}
+}
+
+func TestReminders(t *testing.T) {
+	h := New(Config{Analyzer: analysis.New()})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/analyze", strings.NewReader(
+		`{"patientName":"Jane","age":45,"weight":70,"height":170,"bp":"120/80","complaint":"ED"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("analyze: status %d: %s", rec.Code, rec.Body)
+	}
+
+	list := func(query string) ([]types.Reminder, int) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/reminders"+query, nil))
+		var out []types.Reminder
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return out, rec.Code
+	}
+	all, code := list("")
+	if code != http.StatusOK || len(all) != 1 || all[0].Status != "pending" || all[0].Complaint != "ED" {
+		t.Fatalf("reminders = %+v (status %d)", all, code)
+	}
+	if soon, code := list("?due=7d"); code != http.StatusOK || len(soon) != 0 {
+		t.Fatalf("due in 7 days = %+v (status %d)", soon, code)
+	}
+	if _, code := list("?due=tomorrow"); code != http.StatusBadRequest {
+		t.Fatalf("bad due filter: status %d", code)
+	}
+
+	complete := func(id, body string) int {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/reminders/"+id+"/complete", strings.NewReader(body)))
+		return rec.Code
+	}
+	if code := complete(all[0].ID, `{"userId":"dr-a"}`); code != http.StatusOK {
+		t.Fatalf("complete: status %d", code)
+	}
+	if code := complete(all[0].ID, ``); code != http.StatusConflict {
+		t.Fatalf("second complete: status %d", code)
+	}
+	if code := complete("missing", ``); code != http.StatusNotFound {
+		t.Fatalf("unknown reminder: status %d", code)
+	}
+	if open, code := list(""); code != http.StatusOK || len(open) != 0 {
+		t.Fatalf("open after completing = %+v (status %d)", open, code)
+	}
+}
+
+func TestAckIssue(t *testing.T) {
+	h := New(Config{Analyzer: analysis.New()})
+	const intake = `{"patientName":"Juan","age":45,"weight":78,"height":175,"bp":"135/88","conditions":["Hypertension"],"medications":[{"name":"Amlodipine","dosage":"5mg","frequency":"Daily"}],"complaint":"ED"}`
+	analyze := func() analysis.Response {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/analyze", strings.NewReader(intake)))
+		var resp analysis.Response
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("analyze: status %d: %s", rec.Code, rec.Body)
+		}
+		return resp
+	}
+	ack := func(auditID, code, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/audit/"+auditID+"/issues/"+code+"/ack", strings.NewReader(body)))
+		return rec
+	}
+
+	first := analyze()
+	for _, c := range []struct {
+		auditID, code, body string
+		want                int
+	}{
+		{first.AuditID, "DDI_PDE5_AMLODIPINE", `{"userId":"dr-a"}`, http.StatusBadRequest},
+		{first.AuditID, "NOT_FLAGGED", `{"reason":"seen"}`, http.StatusNotFound},
+		{"missing", "DDI_PDE5_AMLODIPINE", `{"reason":"seen"}`, http.StatusNotFound},
+		{first.AuditID, "DDI_PDE5_AMLODIPINE", `{"userId":"dr-a","reason":"stable BP"}`, http.StatusCreated},
+		{first.AuditID, "DDI_PDE5_AMLODIPINE", `{"reason":"again"}`, http.StatusConflict},
+	} {
+		if rec := ack(c.auditID, c.code, c.body); rec.Code != c.want {
+			t.Errorf("ack %s on %s: status %d, want %d: %s", c.code, c.auditID, rec.Code, c.want, rec.Body)
+		}
+	}
+
+	for _, is := range analyze().FlaggedIssues {
+		if got := is.Acknowledged; got != (is.Code == "DDI_PDE5_AMLODIPINE") {
+			t.Errorf("%s acknowledged = %v", is.Code, got)
+		}
+	}
+
+	var danger analysis.Response
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/analyze", strings.NewReader(
+		`{"patientName":"Ana","age":60,"weight":80,"height":170,"bp":"130/80","conditions":["Coronary artery disease"],"medications":[{"name":"Isosorbide mononitrate","dosage":"30mg","frequency":"Daily"}],"complaint":"ED"}`)))
+	if err := json.Unmarshal(rec.Body.Bytes(), &danger); err != nil {
+		t.Fatal(err)
+	}
+	for _, is := range danger.FlaggedIssues {
+		if is.Severity == analysis.SeverityDanger {
+			if rec := ack(danger.AuditID, is.Code, `{"reason":"cardiology aware"}`); rec.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("ack danger %s: status %d", is.Code, rec.Code)
+			}
+			return
+		}
+	}
+	t.Fatalf("no danger issue in %+v", danger.FlaggedIssues)
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// DefaultAppPage is the clinical UI page served at /app.
+const DefaultAppPage = "app.html"
+
+// Cache policy for static files. Pages revalidate on every load so a deploy
+// shows up at once; assets are not fingerprinted, so they are cached briefly
+// and then revalidated against their ETag.
+const (
+	pageCacheControl  = "no-cache"
+	assetCacheControl = "public, max-age=300"
+)
+
+// serveAssets serves files under assets/ in fsys. Directories are not listed.
+func serveAssets(fsys fs.FS) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Join("assets", strings.TrimPrefix(r.URL.Path, "/assets/"))
+		if !fs.ValidPath(name) {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Cache-Control", assetCacheControl)
+		serveStatic(w, r, fsys, name)
+	})
+}
+
+// serveWASM serves the wasm build from fsys under /assets/wasm/, cached as
+// assets are. The builtin MIME table types analysis.wasm as
+// application/wasm, which WebAssembly.instantiateStreaming requires.
+func serveWASM(fsys fs.FS) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/assets/wasm/")
+		if !fs.ValidPath(name) || name == "." {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Cache-Control", assetCacheControl)
+		serveStatic(w, r, fsys, name)
+	})
+}
+
+// serveStatic writes the file name from fsys with its Content-Type and a
+// content-hash ETag, answering conditional and range requests through
+// http.ServeContent. Missing files and directories are 404.
+func serveStatic(w http.ResponseWriter, r *http.Request, fsys fs.FS, name string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	info, err := fs.Stat(fsys, name)
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	body, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	sum := sha256.Sum256(body)
+	h := w.Header()
+	h.Set("ETag", `"`+hex.EncodeToString(sum[:8])+`"`)
+	if h.Get("Cache-Control") == "" {
+		h.Set("Cache-Control", pageCacheControl)
+	}
+	if ct := mime.TypeByExtension(path.Ext(name)); ct != "" {
+		h.Set("Content-Type", ct)
+	}
+	// Embedded files have a zero modification time, so ServeContent skips
+	// Last-Modified and relies on the ETag alone.
+	http.ServeContent(w, r, name, info.ModTime(), bytes.NewReader(body))
+}
@@ -0,0 +1,130 @@
+package signing
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+)
+
+// Canonicalize rewrites a JSON document in the form responses are signed
+// over: no insignificant whitespace, object keys sorted by their UTF-8
+// bytes, strings escaping only '"', '\\', and control characters, and
+// numbers as float64 in the shortest form that reads back exactly, plain
+// within [1e-6, 1e21) and in exponent form outside it. Two documents that
+// decode to the same values canonicalize to the same bytes, whichever key
+// order, spacing, or escapes the encoder that wrote them chose.
+func Canonicalize(doc []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("signing: %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("signing: trailing data after JSON document")
+	}
+	var buf bytes.Buffer
+	if err := writeCanonical(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeCanonical(buf *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case json.Number:
+		f, err := strconv.ParseFloat(string(v), 64)
+		if err != nil {
+			return fmt.Errorf("signing: number %s out of range", v)
+		}
+		buf.WriteString(formatNumber(f))
+	case string:
+		writeString(buf, v)
+	case []any:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonical(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeString(buf, k)
+			buf.WriteByte(':')
+			if err := writeCanonical(buf, v[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("signing: unexpected %T in decoded JSON", v)
+	}
+	return nil
+}
+
+// formatNumber formats f the way ECMAScript's Number.prototype.toString
+// does, which is what RFC 8785 canonical JSON uses too.
+func formatNumber(f float64) string {
+	if f == 0 {
+		// Covers -0 as well.
+		return "0"
+	}
+	if abs := math.Abs(f); abs >= 1e-6 && abs < 1e21 {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	s := strconv.FormatFloat(f, 'e', -1, 64)
+	// Go writes e-07 and e+21; ECMAScript writes e-7 and e+21.
+	mant, exp, _ := bytes.Cut([]byte(s), []byte("e"))
+	sign, digits := exp[0], bytes.TrimLeft(exp[1:], "0")
+	return string(mant) + "e" + string(sign) + string(digits)
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"':
+			buf.WriteString(`\"`)
+		case r == '\\':
+			buf.WriteString(`\\`)
+		case r == '\b':
+			buf.WriteString(`\b`)
+		case r == '\f':
+			buf.WriteString(`\f`)
+		case r == '\n':
+			buf.WriteString(`\n`)
+		case r == '\r':
+			buf.WriteString(`\r`)
+		case r == '\t':
+			buf.WriteString(`\t`)
+		case r < 0x20:
+			fmt.Fprintf(buf, `\u%04x`, r)
+		default:
+			// Decoding already replaced invalid UTF-8 with U+FFFD.
+			buf.WriteRune(r)
+		}
+	}
+	buf.WriteByte('"')
+}
@@ -0,0 +1,152 @@
+// Package signing signs analysis responses with Ed25519 over their
+// canonical JSON, so a consumer holding the public key can check a response
+// came from this service unmodified however it was stored or re-encoded.
+package signing
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Algorithm names the signature scheme in keys and signatures.
+const Algorithm = "Ed25519"
+
+// ErrBadSignature is returned by Verify for a signature that does not match.
+var ErrBadSignature = errors.New("signing: signature does not match")
+
+// Key is an Ed25519 private key with the ID published alongside its public
+// half, so verifiers can tell which key to use across a rotation.
+type Key struct {
+	id   string
+	priv ed25519.PrivateKey
+}
+
+// NewKey wraps priv under id; an empty id is KeyID of the public key.
+func NewKey(id string, priv ed25519.PrivateKey) (*Key, error) {
+	if len(priv) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("signing: private key must be %d bytes, got %d", ed25519.PrivateKeySize, len(priv))
+	}
+	if id = strings.TrimSpace(id); id == "" {
+		id = KeyID(priv.Public().(ed25519.PublicKey))
+	}
+	return &Key{id: id, priv: priv}, nil
+}
+
+// KeyID derives a key ID from pub: the first 8 bytes of its SHA-256, in hex.
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// ID returns the key ID.
+func (k *Key) ID() string {
+	return k.id
+}
+
+// Public returns the public key.
+func (k *Key) Public() ed25519.PublicKey {
+	return k.priv.Public().(ed25519.PublicKey)
+}
+
+// Sign signs the canonical form of the JSON document doc and returns the
+// signature in standard base64.
+func (k *Key) Sign(doc []byte) (string, error) {
+	msg, err := Canonicalize(doc)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(k.priv, msg)), nil
+}
+
+// Verify checks sig, standard base64, against the canonical form of doc.
+func Verify(pub ed25519.PublicKey, doc []byte, sig string) error {
+	if len(pub) != ed25519.PublicKeySize {
+		return fmt.Errorf("signing: public key must be %d bytes, got %d", ed25519.PublicKeySize, len(pub))
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(sig))
+	if err != nil {
+		return fmt.Errorf("signing: decode signature: %w", err)
+	}
+	msg, err := Canonicalize(doc)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(pub, msg, raw) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// ParsePrivateKey decodes an Ed25519 key given as a 32-byte seed or 64-byte
+// private key in hex or standard base64, or as a PKCS#8 PEM block such as
+// openssl genpkey -algorithm ed25519 writes.
+func ParsePrivateKey(s string) (ed25519.PrivateKey, error) {
+	s = strings.TrimSpace(s)
+	if block, _ := pem.Decode([]byte(s)); block != nil {
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("signing: parse PEM key: %w", err)
+		}
+		priv, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("signing: PEM key is %T, not Ed25519", key)
+		}
+		return priv, nil
+	}
+	for _, decode := range []func(string) ([]byte, error){hex.DecodeString, base64.StdEncoding.DecodeString} {
+		if raw, err := decode(s); err == nil {
+			if priv, ok := fromRaw(raw); ok {
+				return priv, nil
+			}
+		}
+	}
+	return nil, errors.New("signing: key must be a 32-byte seed or 64-byte Ed25519 key in hex or base64, or a PKCS#8 PEM block")
+}
+
+// ParsePublicKey decodes a 32-byte Ed25519 public key given in standard
+// base64, as SigningKey publishes it, or hex.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	s = strings.TrimSpace(s)
+	for _, decode := range []func(string) ([]byte, error){base64.StdEncoding.DecodeString, hex.DecodeString} {
+		if raw, err := decode(s); err == nil && len(raw) == ed25519.PublicKeySize {
+			return ed25519.PublicKey(raw), nil
+		}
+	}
+	return nil, errors.New("signing: public key must be a 32-byte Ed25519 key in base64 or hex")
+}
+
+// LoadKeyFile reads a key file holding a raw seed or key, or any encoding
+// ParsePrivateKey accepts.
+func LoadKeyFile(path string) (ed25519.PrivateKey, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("signing: read key file: %w", err)
+	}
+	if priv, ok := fromRaw(raw); ok {
+		return priv, nil
+	}
+	return ParsePrivateKey(string(raw))
+}
+
+func fromRaw(raw []byte) (ed25519.PrivateKey, bool) {
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), true
+	case ed25519.PrivateKeySize:
+		// The second half is the public key; a mismatch means raw is not a
+		// key at all, such as a hex-encoded seed.
+		if priv := ed25519.NewKeyFromSeed(raw[:ed25519.SeedSize]); bytes.Equal(priv, raw) {
+			return priv, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,164 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// vectorKey is the key seeded with bytes 0 through 31 that signed the vector.
+func vectorKey(t *testing.T) *Key {
+	t.Helper()
+	seed := make([]byte, ed25519.SeedSize)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+	k, err := NewKey("", ed25519.NewKeyFromSeed(seed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// The vector pins the canonical bytes and the signature, so a Go release
+// that changed JSON decoding or float formatting under us fails here rather
+// than invalidating signatures consumers already hold.
+const (
+	vectorKeyID     = "56475aa75463474c"
+	vectorSignature = "rsqIgWmAG5/wH6ZVxTN03dYjgp2d1eDZU8lwP3jgRrLwpHXKcVwMqybFZflVcrPm9uNH1bs/Y/wtyfXdXz+OAA=="
+)
+
+func TestSign_Vector(t *testing.T) {
+	doc, err := os.ReadFile("testdata/response.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("testdata/response.canonical.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Canonicalize(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Fatalf("canonical form\n got %s\nwant %s", got, want)
+	}
+
+	k := vectorKey(t)
+	if k.ID() != vectorKeyID {
+		t.Fatalf("key ID = %s, want %s", k.ID(), vectorKeyID)
+	}
+	sig, err := k.Sign(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sig != vectorSignature {
+		t.Fatalf("signature = %s, want %s", sig, vectorSignature)
+	}
+	// The canonical form verifies under the same signature as the original.
+	for _, d := range [][]byte{doc, want} {
+		if err := Verify(k.Public(), d, vectorSignature); err != nil {
+			t.Fatalf("verify %.40s: %v", d, err)
+		}
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	cases := []struct{ in, want string }{
+		{`{"b":1,"a":{"d":[1,2],"c":true}}`, `{"a":{"c":true,"d":[1,2]},"b":1}`},
+		{` [ 1.0 , -0, 0.1 , 100 , 1e2, 1E+2 ] `, `[1,0,0.1,100,100,100]`},
+		{`[0.000001, 0.0000001, 1e20, 1e21, 123456789012345678]`, `[0.000001,1e-7,100000000000000000000,1e+21,123456789012345680]`},
+		{`"<b> é \/ \u0007"`, `"<b> é / \u0007"`},
+		{`{"é":1,"z":2,"A":3}`, `{"A":3,"z":2,"é":1}`},
+	}
+	for _, c := range cases {
+		got, err := Canonicalize([]byte(c.in))
+		if err != nil {
+			t.Fatalf("%s: %v", c.in, err)
+		}
+		if string(got) != c.want {
+			t.Errorf("Canonicalize(%s) = %s, want %s", c.in, got, c.want)
+		}
+	}
+	for _, bad := range []string{``, `{"a":1} {}`, `{"a":1}}`, `[1e999]`} {
+		if _, err := Canonicalize([]byte(bad)); err == nil {
+			t.Errorf("Canonicalize(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestVerify_RejectsTampering(t *testing.T) {
+	k := vectorKey(t)
+	doc := []byte(`{"riskLevel":"HIGH","riskScore":7}`)
+	sig, err := k.Sign(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Re-encoding does not matter; changing a value does.
+	if err := Verify(k.Public(), []byte("{ \"riskScore\": 7.0,\n \"riskLevel\": \"HIGH\" }"), sig); err != nil {
+		t.Fatalf("re-encoded document: %v", err)
+	}
+	if err := Verify(k.Public(), []byte(`{"riskLevel":"LOW","riskScore":7}`), sig); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("tampered document: %v, want ErrBadSignature", err)
+	}
+	other, _, _ := ed25519.GenerateKey(rand.Reader)
+	if err := Verify(other, doc, sig); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("other key: %v, want ErrBadSignature", err)
+	}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	k := vectorKey(t)
+	seed := k.priv.Seed()
+	der, err := x509.MarshalPKCS8PrivateKey(k.priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemKey := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	for _, s := range []string{hex.EncodeToString(seed), hex.EncodeToString(k.priv), pemKey} {
+		priv, err := ParsePrivateKey(s)
+		if err != nil {
+			t.Fatalf("%.20q: %v", s, err)
+		}
+		if !priv.Equal(k.priv) {
+			t.Fatalf("%.20q parsed to a different key", s)
+		}
+	}
+	for _, bad := range []string{"", "abcd", hex.EncodeToString(append(seed, seed...))} {
+		if _, err := ParsePrivateKey(bad); err == nil {
+			t.Errorf("ParsePrivateKey(%.20q) succeeded", bad)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "signing.key")
+	if err := os.WriteFile(path, seed, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	priv, err := LoadKeyFile(path)
+	if err != nil || !priv.Equal(k.priv) {
+		t.Fatalf("raw seed file: %v", err)
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	pub := vectorKey(t).Public()
+	for _, s := range []string{base64.StdEncoding.EncodeToString(pub), hex.EncodeToString(pub)} {
+		got, err := ParsePublicKey(s)
+		if err != nil || !got.Equal(pub) {
+			t.Fatalf("%.20q: %v", s, err)
+		}
+	}
+	for _, bad := range []string{"", "abcd", hex.EncodeToString(vectorKey(t).priv)} {
+		if _, err := ParsePublicKey(bad); err == nil {
+			t.Errorf("ParsePublicKey(%.20q) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+// Package testgen produces randomized but clinically plausible intakes for
+// load and fuzz testing. A Generator is deterministic for a given seed.
+package testgen
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+
+	"github.com/Skufu/Clinical-AI-Assistant/types"
+)
+
+// DefaultTypoRate is the share of medication and condition names misspelled.
+const DefaultTypoRate = 0.05
+
+// Generator draws intakes from a seeded source. It is not safe for concurrent
+// use; give each goroutine its own Generator.
+type Generator struct {
+	rng *rand.Rand
+	// TypoRate is the probability that a medication or condition name gets one
+	// transposed or dropped letter, so matchers see names they do not know.
+	TypoRate float64
+}
+
+// New returns a Generator seeded with seed.
+func New(seed int64) *Generator {
+	return &Generator{rng: rand.New(rand.NewSource(seed)), TypoRate: DefaultTypoRate}
+}
+
+type weighted struct {
+	value  string
+	weight int
+}
+
+func (g *Generator) pick(options []weighted) string {
+	total := 0
+	for _, o := range options {
+		total += o.weight
+	}
+	n := g.rng.Intn(total)
+	for _, o := range options {
+		if n < o.weight {
+			return o.value
+		}
+		n -= o.weight
+	}
+	return options[len(options)-1].value
+}
+
+// complaints is weighted toward the programs the clinic runs; the tail hits
+// the general wellness plan, with case variants the engine must accept.
+var complaints = []weighted{
+	{"ED", 35},
+	{"ed", 5},
+	{"Hair Loss", 20},
+	{"Weight Loss", 22},
+	{"weight loss", 3},
+	{"Annual checkup", 8},
+	{"Fatigue", 7},
+}
+
+var (
+	firstNames = []string{"Juan", "Maria", "Jose", "Ana", "Mark", "Grace", "Paolo", "Liza", "Ramon", "Carmen", "Miguel", "Rosa"}
+	lastNames  = []string{"Santos", "Reyes", "Cruz", "Bautista", "Garcia", "Mendoza", "Torres", "Flores", "Ramos", "Aquino"}
+	smoking    = []weighted{{"never", 60}, {"former", 25}, {"current", 15}}
+	alcohol    = []weighted{{"none", 30}, {"moderate", 55}, {"heavy", 15}}
+	exercise   = []weighted{{"none", 25}, {"occasional", 45}, {"regular", 30}}
+)
+
+// drug is a dictionary entry: a name the rule engine or its interaction rules
+// know, with the doses it is usually listed at.
+type drug struct {
+	name      string
+	doses     []string
+	frequency string
+}
+
+var (
+	amlodipine    = drug{"amlodipine", []string{"5mg", "10mg"}, "daily"}
+	lisinopril    = drug{"lisinopril", []string{"10mg", "20mg"}, "daily"}
+	metformin     = drug{"metformin", []string{"500mg", "1000mg"}, "twice daily"}
+	simvastatin   = drug{"simvastatin", []string{"20mg", "40mg"}, "nightly"}
+	atorvastatin  = drug{"atorvastatin", []string{"20mg", "40mg"}, "daily"}
+	nitroglycerin = drug{"nitroglycerin", []string{"0.4mg SL"}, "as needed"}
+	isosorbide    = drug{"isosorbide mononitrate", []string{"30mg", "60mg"}, "daily"}
+	tamsulosin    = drug{"tamsulosin", []string{"0.4mg"}, "daily"}
+	finasteride   = drug{"finasteride", []string{"1mg", "5mg"}, "daily"}
+	sildenafil    = drug{"sildenafil", []string{"25mg", "50mg", "100mg"}, "as needed"}
+	contrast      = drug{"contrast", []string{""}, "once"}
+
+	// background drugs carry no rules and pad realistic medication lists.
+	background = []drug{
+		{"aspirin", []string{"81mg"}, "daily"},
+		{"omeprazole", []string{"20mg"}, "daily"},
+		{"levothyroxine", []string{"50mcg", "100mcg"}, "daily"},
+		{"sertraline", []string{"50mg"}, "daily"},
+		{"losartan", []string{"50mg"}, "daily"},
+		{"vitamin D", []string{"1000 IU"}, "daily"},
+		{"cetirizine", []string{"10mg"}, "as needed"},
+	}
+)
+
+// consentTimestamp is fixed so consent does not perturb the random sequence.
+const consentTimestamp = "2025-01-01T00:00:00Z"
+
+// Intake returns the next generated intake.
+func (g *Generator) Intake() types.Intake {
+	r := g.rng
+	age := 22 + int(math.Abs(r.NormFloat64()*16)) + r.Intn(20)
+	if age > 90 {
+		age = 90
+	}
+	height := clampF(r.NormFloat64()*8+171, 150, 200)
+	// BMI drifts upward with age and drives weight, so obesity, elevated BP,
+	// and diabetes show up together as they do in clinic.
+	bmi := clampF(r.NormFloat64()*4.5+24+float64(age-30)*0.06, 17, 52)
+	weight := bmi * (height / 100) * (height / 100)
+	systolic := int(clampF(100+0.45*float64(age)+0.7*(bmi-24)+r.NormFloat64()*12, 88, 210))
+	diastolic := int(clampF(0.55*float64(systolic)+r.NormFloat64()*7, 50, 130))
+
+	in := types.Intake{
+		PatientName: firstNames[r.Intn(len(firstNames))] + " " + lastNames[r.Intn(len(lastNames))],
+		Age:         age,
+		WeightKg:    round1(weight),
+		HeightCm:    round1(height),
+		BP:          g.formatBP(systolic, diastolic),
+		Conditions:  []string{},
+		Allergies:   []string{},
+		Medications: []types.Medication{},
+		Smoking:     g.pick(smoking),
+		Alcohol:     g.pick(alcohol),
+		Exercise:    g.pick(exercise),
+		Complaint:   g.pick(complaints),
+		Consent:     &types.Consent{Given: true, Timestamp: consentTimestamp, Method: "electronic"},
+	}
+
+	var meds []drug
+	if systolic >= 140 || r.Float64() < 0.1 {
+		in.Conditions = append(in.Conditions, "hypertension")
+		meds = append(meds, g.oneOf(amlodipine, lisinopril))
+	}
+	if r.Float64() < 0.03+math.Max(0, bmi-27)*0.02 {
+		in.Conditions = append(in.Conditions, "diabetes")
+		meds = append(meds, metformin)
+	}
+	if age > 50 && r.Float64() < float64(age-45)*0.006 {
+		in.Conditions = append(in.Conditions, "heart disease")
+		meds = append(meds, g.oneOf(simvastatin, atorvastatin))
+		if r.Float64() < 0.3 {
+			meds = append(meds, g.oneOf(nitroglycerin, isosorbide))
+		}
+	}
+	if r.Float64() < 0.04 {
+		in.Conditions = append(in.Conditions, "kidney disease")
+	}
+	if r.Float64() < 0.03 {
+		in.Conditions = append(in.Conditions, "liver disease")
+	}
+	if age > 50 && r.Float64() < 0.2 {
+		meds = append(meds, tamsulosin)
+	}
+	switch strings.ToLower(in.Complaint) {
+	case "hair loss":
+		if r.Float64() < 0.2 {
+			meds = append(meds, finasteride)
+		}
+	case "ed":
+		if r.Float64() < 0.15 {
+			meds = append(meds, sildenafil)
+		}
+	}
+	if r.Float64() < 0.02 {
+		meds = append(meds, contrast)
+	}
+	for n := r.Intn(4); n > 0; n-- {
+		meds = append(meds, background[r.Intn(len(background))])
+	}
+
+	for _, d := range meds {
+		in.Medications = append(in.Medications, types.Medication{
+			Name:      g.maybeTypo(g.maybeCase(d.name)),
+			Dosage:    d.doses[r.Intn(len(d.doses))],
+			Frequency: d.frequency,
+		})
+	}
+	for i, c := range in.Conditions {
+		in.Conditions[i] = g.maybeTypo(g.maybeCase(c))
+	}
+	// The generated lists are complete, so an empty one is a confirmed none.
+	in.ConfirmedNoConditions = len(in.Conditions) == 0
+	in.ConfirmedNoMedications = len(in.Medications) == 0
+	if r.Float64() < 0.15 {
+		in.Allergies = append(in.Allergies, g.pick([]weighted{{"penicillin", 6}, {"sulfa", 3}, {"tadalafil", 1}, {"metformin", 1}}))
+	}
+	return in
+}
+
+// Intakes returns n generated intakes.
+func (g *Generator) Intakes(n int) []types.Intake {
+	out := make([]types.Intake, 0, n)
+	for range n {
+		out = append(out, g.Intake())
+	}
+	return out
+}
+
+func (g *Generator) oneOf(drugs ...drug) drug {
+	return drugs[g.rng.Intn(len(drugs))]
+}
+
+// formatBP writes the reading the ways clinicians type it.
+func (g *Generator) formatBP(sys, dia int) string {
+	switch g.rng.Intn(6) {
+	case 0:
+		return fmt.Sprintf("%d / %d", sys, dia)
+	case 1:
+		return fmt.Sprintf("%d/%d mmHg", sys, dia)
+	default:
+		return fmt.Sprintf("%d/%d", sys, dia)
+	}
+}
+
+func (g *Generator) maybeCase(s string) string {
+	switch g.rng.Intn(10) {
+	case 0:
+		return strings.ToUpper(s[:1]) + s[1:]
+	case 1:
+		return " " + s + " "
+	}
+	return s
+}
+
+// maybeTypo transposes two adjacent letters or drops one at TypoRate.
+func (g *Generator) maybeTypo(s string) string {
+	if len(s) < 4 || g.rng.Float64() >= g.TypoRate {
+		return s
+	}
+	b := []byte(s)
+	i := 1 + g.rng.Intn(len(b)-2)
+	if g.rng.Intn(2) == 0 {
+		b[i], b[i+1] = b[i+1], b[i]
+		return string(b)
+	}
+	return string(append(b[:i], b[i+1:]...))
+}
+
+func clampF(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, v))
+}
+
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
@@ -0,0 +1,63 @@
+package testgen
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+)
+
+func TestGenerator_DeterministicPerSeed(t *testing.T) {
+	if a, b := New(7).Intakes(50), New(7).Intakes(50); !reflect.DeepEqual(a, b) {
+		t.Fatal("same seed produced different intakes")
+	}
+	if a, b := New(7).Intake(), New(8).Intake(); reflect.DeepEqual(a, b) {
+		t.Fatal("different seeds produced the same intake")
+	}
+}
+
+func TestGenerator_Plausible(t *testing.T) {
+	const n = 2000
+	complaints := map[string]int{}
+	var withMeds, typos int
+	g := New(1)
+	g.TypoRate = 0.5
+	for _, in := range g.Intakes(n) {
+		if errs := analysis.Validate(in); len(errs) > 0 {
+			t.Fatalf("generated invalid intake %+v: %v", in, errs)
+		}
+		bmi := in.WeightKg / (in.HeightCm / 100 * in.HeightCm / 100)
+		if in.Age < 18 || in.Age > 90 || bmi < 16.5 || bmi > 52.5 {
+			t.Fatalf("implausible intake: age %d bmi %.1f", in.Age, bmi)
+		}
+		complaints[strings.ToLower(in.Complaint)]++
+		if len(in.Medications) > 0 {
+			withMeds++
+		}
+		for _, m := range in.Medications {
+			if !knownName(m.Name) {
+				typos++
+			}
+		}
+	}
+	if share := float64(complaints["ed"]) / n; share < 0.3 || share > 0.5 {
+		t.Errorf("ED share %.2f, want about 0.4", share)
+	}
+	if withMeds < n/2 {
+		t.Errorf("only %d of %d intakes list medications", withMeds, n)
+	}
+	if typos == 0 {
+		t.Error("TypoRate 0.5 produced no misspelled medications")
+	}
+}
+
+func knownName(name string) bool {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, d := range append([]drug{amlodipine, lisinopril, metformin, simvastatin, atorvastatin, nitroglycerin, isosorbide, tamsulosin, finasteride, sildenafil, contrast}, background...) {
+		if strings.ToLower(d.name) == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,315 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/metrics"
+)
+
+// DefaultServiceName is the service.name resource attribute when
+// OTEL_SERVICE_NAME is unset.
+const DefaultServiceName = "clinical-ai-assistant"
+
+const (
+	defaultBatchSize     = 256
+	defaultFlushInterval = 5 * time.Second
+	queueSize            = 2048
+	scopeName            = "github.com/Skufu/Clinical-AI-Assistant"
+)
+
+var spansDropped = metrics.NewCounter("trace_spans_dropped_total", "Spans dropped because the export queue was full or the collector rejected them.")
+
+// OTLPConfig configures an OTLPExporter.
+type OTLPConfig struct {
+	// Endpoint is the full traces URL, e.g. http://collector:4318/v1/traces.
+	Endpoint    string
+	Headers     map[string]string
+	ServiceName string
+	// HTTPClient defaults to a client with a 10s timeout.
+	HTTPClient *http.Client
+	// BatchSize and FlushInterval bound how long spans wait before export.
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+// ConfigFromEnv reads the standard OpenTelemetry environment variables:
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT (used as is) or OTEL_EXPORTER_OTLP_ENDPOINT
+// (with /v1/traces appended), OTEL_EXPORTER_OTLP_HEADERS and its TRACES
+// variant, and OTEL_SERVICE_NAME. ok is false when no endpoint is set,
+// OTEL_SDK_DISABLED is true, or OTEL_TRACES_EXPORTER is none. Only the
+// http/json protocol is supported.
+func ConfigFromEnv() (cfg OTLPConfig, ok bool, err error) {
+	if v, _ := strconv.ParseBool(os.Getenv("OTEL_SDK_DISABLED")); v || strings.EqualFold(os.Getenv("OTEL_TRACES_EXPORTER"), "none") {
+		return OTLPConfig{}, false, nil
+	}
+	cfg.Endpoint = strings.TrimSpace(os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"))
+	if cfg.Endpoint == "" {
+		if base := strings.TrimSpace(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")); base != "" {
+			cfg.Endpoint = strings.TrimRight(base, "/") + "/v1/traces"
+		}
+	}
+	if cfg.Endpoint == "" {
+		return OTLPConfig{}, false, nil
+	}
+	protocol := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL")
+	if protocol == "" {
+		protocol = os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+	}
+	if protocol != "" && protocol != "http/json" {
+		return OTLPConfig{}, false, fmt.Errorf("trace: OTLP protocol %q is not supported; use http/json", protocol)
+	}
+	cfg.Headers = map[string]string{}
+	for _, key := range []string{"OTEL_EXPORTER_OTLP_HEADERS", "OTEL_EXPORTER_OTLP_TRACES_HEADERS"} {
+		if err := parseHeaders(os.Getenv(key), cfg.Headers); err != nil {
+			return OTLPConfig{}, false, fmt.Errorf("trace: %s: %w", key, err)
+		}
+	}
+	cfg.ServiceName = strings.TrimSpace(os.Getenv("OTEL_SERVICE_NAME"))
+	return cfg, true, nil
+}
+
+// parseHeaders reads the W3C baggage-style "key=value,key2=value2" list with
+// URL-encoded values into into.
+func parseHeaders(raw string, into map[string]string) error {
+	for _, pair := range strings.Split(raw, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return fmt.Errorf("malformed header %q", pair)
+		}
+		v, err := url.QueryUnescape(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("malformed header %q: %w", pair, err)
+		}
+		into[strings.TrimSpace(k)] = v
+	}
+	return nil
+}
+
+// OTLPExporter batches spans and posts them to a collector as OTLP/HTTP JSON.
+// Spans are dropped, and counted, rather than blocking a request when the
+// queue is full.
+type OTLPExporter struct {
+	cfg   OTLPConfig
+	queue chan SpanData
+	flush chan chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+	once  sync.Once
+}
+
+// NewOTLPExporter starts an exporter; Shutdown flushes and stops it.
+func NewOTLPExporter(cfg OTLPConfig) *OTLPExporter {
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = DefaultServiceName
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultFlushInterval
+	}
+	e := &OTLPExporter{
+		cfg:   cfg,
+		queue: make(chan SpanData, queueSize),
+		flush: make(chan chan struct{}),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+func (e *OTLPExporter) ExportSpan(d SpanData) {
+	select {
+	case e.queue <- d:
+	default:
+		spansDropped.Inc()
+	}
+}
+
+// Flush exports queued spans and waits until they are sent or ctx ends.
+func (e *OTLPExporter) Flush(ctx context.Context) error {
+	ack := make(chan struct{})
+	select {
+	case e.flush <- ack:
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-ack:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown exports queued spans and stops the exporter.
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	e.once.Do(func() { close(e.stop) })
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *OTLPExporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(e.cfg.FlushInterval)
+	defer ticker.Stop()
+	var batch []SpanData
+	send := func() {
+		if len(batch) > 0 {
+			e.post(batch)
+			batch = nil
+		}
+	}
+	drain := func() {
+		for {
+			select {
+			case d := <-e.queue:
+				batch = append(batch, d)
+			default:
+				return
+			}
+		}
+	}
+	for {
+		select {
+		case d := <-e.queue:
+			batch = append(batch, d)
+			if len(batch) >= e.cfg.BatchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case ack := <-e.flush:
+			drain()
+			send()
+			close(ack)
+		case <-e.stop:
+			drain()
+			send()
+			return
+		}
+	}
+}
+
+func (e *OTLPExporter) post(batch []SpanData) {
+	body, err := json.Marshal(otlpRequest(e.cfg.ServiceName, batch))
+	if err != nil {
+		log.Printf("trace export: encode: %v", err)
+		spansDropped.Add(float64(len(batch)))
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, e.cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("trace export: %v", err)
+		spansDropped.Add(float64(len(batch)))
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.cfg.HTTPClient.Do(req)
+	if err != nil {
+		log.Printf("trace export: %v", err)
+		spansDropped.Add(float64(len(batch)))
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		log.Printf("trace export: collector returned %d", resp.StatusCode)
+		spansDropped.Add(float64(len(batch)))
+	}
+}
+
+// The OTLP/HTTP JSON encoding: IDs are hex, times and integers are decimal
+// strings.
+type (
+	otlpKeyValue struct {
+		Key   string         `json:"key"`
+		Value map[string]any `json:"value"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              Kind           `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            *otlpStatus    `json:"status,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+)
+
+func otlpRequest(service string, batch []SpanData) map[string]any {
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, d := range batch {
+		s := otlpSpan{
+			TraceID:           d.TraceID.String(),
+			SpanID:            d.SpanID.String(),
+			Name:              d.Name,
+			Kind:              d.Kind,
+			StartTimeUnixNano: strconv.FormatInt(d.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(d.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(d.Attributes),
+		}
+		if !d.ParentID.IsZero() {
+			s.ParentSpanID = d.ParentID.String()
+		}
+		if d.Error != "" {
+			s.Status = &otlpStatus{Code: 2, Message: d.Error}
+		}
+		spans = append(spans, s)
+	}
+	return map[string]any{"resourceSpans": []any{map[string]any{
+		"resource":   map[string]any{"attributes": otlpAttributes([]Attr{String("service.name", service)})},
+		"scopeSpans": []any{map[string]any{"scope": map[string]any{"name": scopeName}, "spans": spans}},
+	}}}
+}
+
+func otlpAttributes(attrs []Attr) []otlpKeyValue {
+	out := make([]otlpKeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		var v map[string]any
+		switch x := a.Value.(type) {
+		case string:
+			v = map[string]any{"stringValue": x}
+		case int64:
+			v = map[string]any{"intValue": strconv.FormatInt(x, 10)}
+		case bool:
+			v = map[string]any{"boolValue": x}
+		default:
+			v = map[string]any{"stringValue": fmt.Sprint(x)}
+		}
+		out = append(out, otlpKeyValue{Key: a.Key, Value: v})
+	}
+	return out
+}
@@ -0,0 +1,277 @@
+// Package trace provides minimal OpenTelemetry-compatible tracing without
+// external dependencies: spans carried in a context, W3C traceparent
+// propagation, an OTLP/HTTP JSON exporter, and an in-memory Recorder for
+// tests. With no exporter installed every call is a no-op.
+package trace
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TraceID and SpanID are the W3C trace context identifiers.
+type (
+	TraceID [16]byte
+	SpanID  [8]byte
+)
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+func (id SpanID) String() string  { return hex.EncodeToString(id[:]) }
+
+// IsZero reports whether id is unset, as for a root span's parent.
+func (id SpanID) IsZero() bool { return id == SpanID{} }
+
+// Kind is the OTLP span kind.
+type Kind int
+
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+)
+
+// Attr is a span attribute. Values are strings, int64s, or bools.
+type Attr struct {
+	Key   string
+	Value any
+}
+
+func String(key, value string) Attr    { return Attr{key, value} }
+func Int(key string, value int) Attr   { return Attr{key, int64(value)} }
+func Bool(key string, value bool) Attr { return Attr{key, value} }
+
+// SpanData is a finished span as handed to an Exporter.
+type SpanData struct {
+	Name       string
+	Kind       Kind
+	TraceID    TraceID
+	SpanID     SpanID
+	ParentID   SpanID
+	Start, End time.Time
+	Attributes []Attr
+	// Error is set when the span recorded an error; its message never holds
+	// request data.
+	Error string
+}
+
+// Attr returns the value of the attribute key, or nil.
+func (d SpanData) Attr(key string) any {
+	for _, a := range d.Attributes {
+		if a.Key == key {
+			return a.Value
+		}
+	}
+	return nil
+}
+
+// Exporter receives spans as they end. ExportSpan must not block.
+type Exporter interface {
+	ExportSpan(SpanData)
+}
+
+type exporterHolder struct{ e Exporter }
+
+var exporter atomic.Pointer[exporterHolder]
+
+// SetExporter installs e for all spans started afterwards; nil disables
+// tracing.
+func SetExporter(e Exporter) {
+	if e == nil {
+		exporter.Store(nil)
+		return
+	}
+	exporter.Store(&exporterHolder{e})
+}
+
+// Enabled reports whether an exporter is installed.
+func Enabled() bool {
+	return exporter.Load() != nil
+}
+
+// Span is an in-progress span. A nil *Span is valid and ignores every call,
+// which is what Start returns while tracing is disabled.
+type Span struct {
+	exp  Exporter
+	mu   sync.Mutex
+	data SpanData
+	done bool
+}
+
+type spanKey struct{}
+
+// remoteParent is a span context read from an incoming traceparent header.
+type remoteParent struct {
+	trace TraceID
+	span  SpanID
+}
+
+type remoteKey struct{}
+
+// Start begins a span that is a child of the span in ctx, if any, and returns
+// a context carrying it.
+func Start(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	return start(ctx, name, KindInternal, attrs)
+}
+
+// StartServer begins a server span for an incoming request, continuing the
+// caller's trace when h carries a valid traceparent header.
+func StartServer(ctx context.Context, name string, h http.Header, attrs ...Attr) (context.Context, *Span) {
+	if !Enabled() {
+		return ctx, nil
+	}
+	if p, ok := parseTraceparent(h.Get("traceparent")); ok {
+		ctx = context.WithValue(ctx, remoteKey{}, p)
+	}
+	return start(ctx, name, KindServer, attrs)
+}
+
+func start(ctx context.Context, name string, kind Kind, attrs []Attr) (context.Context, *Span) {
+	holder := exporter.Load()
+	if holder == nil {
+		return ctx, nil
+	}
+	s := &Span{exp: holder.e, data: SpanData{
+		Name:       name,
+		Kind:       kind,
+		Start:      time.Now(),
+		Attributes: append([]Attr(nil), attrs...),
+	}}
+	if parent := FromContext(ctx); parent != nil {
+		s.data.TraceID, s.data.ParentID = parent.data.TraceID, parent.data.SpanID
+	} else if p, ok := ctx.Value(remoteKey{}).(remoteParent); ok {
+		s.data.TraceID, s.data.ParentID = p.trace, p.span
+	} else {
+		s.data.TraceID = newTraceID()
+	}
+	s.data.SpanID = newSpanID()
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// FromContext returns the span in ctx, or nil.
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// SetName renames the span, e.g. once the HTTP route is known.
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.data.Name = name
+	s.mu.Unlock()
+}
+
+// SetAttributes adds or replaces attributes.
+func (s *Span) SetAttributes(attrs ...Attr) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+next:
+	for _, a := range attrs {
+		for i := range s.data.Attributes {
+			if s.data.Attributes[i].Key == a.Key {
+				s.data.Attributes[i] = a
+				continue next
+			}
+		}
+		s.data.Attributes = append(s.data.Attributes, a)
+	}
+}
+
+// SetError marks the span failed with msg. Callers pass a fixed description,
+// not an error string that could echo patient data.
+func (s *Span) SetError(msg string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.data.Error = msg
+	s.mu.Unlock()
+}
+
+// End finishes the span and exports it. Later calls do nothing.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.done {
+		s.mu.Unlock()
+		return
+	}
+	s.done = true
+	s.data.End = time.Now()
+	d := s.data
+	d.Attributes = append([]Attr(nil), s.data.Attributes...)
+	s.mu.Unlock()
+	s.exp.ExportSpan(d)
+}
+
+// Traceparent formats the span context as a W3C traceparent header value.
+func (s *Span) Traceparent() string {
+	if s == nil {
+		return ""
+	}
+	return "00-" + s.data.TraceID.String() + "-" + s.data.SpanID.String() + "-01"
+}
+
+func parseTraceparent(v string) (remoteParent, bool) {
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) != 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return remoteParent{}, false
+	}
+	var p remoteParent
+	if _, err := hex.Decode(p.trace[:], []byte(parts[1])); err != nil || p.trace == (TraceID{}) {
+		return remoteParent{}, false
+	}
+	if _, err := hex.Decode(p.span[:], []byte(parts[2])); err != nil || p.span.IsZero() {
+		return remoteParent{}, false
+	}
+	return p, true
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for id == (TraceID{}) {
+		binary.BigEndian.PutUint64(id[:8], rand.Uint64())
+		binary.BigEndian.PutUint64(id[8:], rand.Uint64())
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for id.IsZero() {
+		binary.BigEndian.PutUint64(id[:], rand.Uint64())
+	}
+	return id
+}
+
+// Recorder keeps finished spans in memory, for tests.
+type Recorder struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func (r *Recorder) ExportSpan(d SpanData) {
+	r.mu.Lock()
+	r.spans = append(r.spans, d)
+	r.mu.Unlock()
+}
+
+// Spans returns the spans recorded so far, in the order they ended.
+func (r *Recorder) Spans() []SpanData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]SpanData(nil), r.spans...)
+}
@@ -0,0 +1,98 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStart_Disabled(t *testing.T) {
+	SetExporter(nil)
+	ctx, span := Start(context.Background(), "noop")
+	if span != nil || FromContext(ctx) != nil {
+		t.Fatal("span started with tracing disabled")
+	}
+	span.SetAttributes(String("k", "v"))
+	span.End()
+}
+
+func TestStart_ParentAndTraceparent(t *testing.T) {
+	rec := &Recorder{}
+	SetExporter(rec)
+	defer SetExporter(nil)
+
+	h := http.Header{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}}
+	ctx, root := StartServer(context.Background(), "GET /x", h)
+	_, child := Start(ctx, "child", Int("n", 1))
+	child.End()
+	root.End()
+
+	spans := rec.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("spans = %+v", spans)
+	}
+	c, r := spans[0], spans[1]
+	if r.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || r.ParentID.String() != "00f067aa0ba902b7" || r.Kind != KindServer {
+		t.Fatalf("root = %+v", r)
+	}
+	if c.TraceID != r.TraceID || c.ParentID != r.SpanID || c.Attr("n") != int64(1) {
+		t.Fatalf("child = %+v, root %+v", c, r)
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	bodies := make(chan map[string]any, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		if err := json.Unmarshal(raw, &body); err != nil || r.Header.Get("X-Token") != "abc" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		bodies <- body
+	}))
+	defer srv.Close()
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", srv.URL)
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "X-Token=abc")
+	cfg, ok, err := ConfigFromEnv()
+	if err != nil || !ok || cfg.Endpoint != srv.URL+"/v1/traces" {
+		t.Fatalf("config = %+v, %t, %v", cfg, ok, err)
+	}
+	exp := NewOTLPExporter(cfg)
+	SetExporter(exp)
+	defer SetExporter(nil)
+	_, span := Start(context.Background(), "work", String("analysis.complaint", "ED"))
+	span.SetError("failed")
+	span.End()
+	if err := exp.Shutdown(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	body := <-bodies
+	rs := body["resourceSpans"].([]any)[0].(map[string]any)
+	got := rs["scopeSpans"].([]any)[0].(map[string]any)["spans"].([]any)[0].(map[string]any)
+	if got["name"] != "work" || len(got["traceId"].(string)) != 32 || got["status"].(map[string]any)["code"] != float64(2) {
+		t.Fatalf("span = %v", got)
+	}
+}
+
+func TestConfigFromEnv_Disabled(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	if _, ok, err := ConfigFromEnv(); ok || err != nil {
+		t.Fatalf("no endpoint: ok=%t err=%v", ok, err)
+	}
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318")
+	t.Setenv("OTEL_SDK_DISABLED", "true")
+	if _, ok, _ := ConfigFromEnv(); ok {
+		t.Fatal("OTEL_SDK_DISABLED ignored")
+	}
+	t.Setenv("OTEL_SDK_DISABLED", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "grpc")
+	if _, _, err := ConfigFromEnv(); err == nil {
+		t.Fatal("grpc protocol accepted")
+	}
+}
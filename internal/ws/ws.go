@@ -0,0 +1,431 @@
+// Package ws implements the parts of the WebSocket protocol (RFC 6455) the
+// server needs without external dependencies: the server handshake over a
+// hijacked HTTP connection, text and binary messages with fragmentation,
+// automatic pong replies, the closing handshake, and a minimal client for
+// tests and tools. Extensions and subprotocols are not negotiated.
+package ws
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Message types, as frame opcodes.
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+
+	opContinuation = 0
+	opClose        = 8
+	opPing         = 9
+	opPong         = 10
+)
+
+// Close codes used by this package and its callers.
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseNoStatus        = 1005
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseInternalError   = 1011
+)
+
+// DefaultReadLimit bounds a message until SetReadLimit is called.
+const DefaultReadLimit = 1 << 20
+
+// maxControlPayload is the largest payload RFC 6455 allows a control frame.
+const maxControlPayload = 125
+
+// acceptGUID is appended to the client's key to derive Sec-WebSocket-Accept.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// closeTimeout bounds writing a close frame to a peer that stopped reading.
+const closeTimeout = 5 * time.Second
+
+var (
+	// ErrMessageTooLarge is returned by ReadMessage for a message over the
+	// read limit; the connection is closed with CloseMessageTooBig.
+	ErrMessageTooLarge = errors.New("ws: message exceeds read limit")
+	// ErrClosed is returned for writes after Close.
+	ErrClosed = errors.New("ws: connection closed")
+)
+
+// CloseError is returned by ReadMessage once the peer has closed the
+// connection.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("ws: closed by peer (%d)", e.Code)
+	}
+	return fmt.Sprintf("ws: closed by peer (%d %s)", e.Code, e.Reason)
+}
+
+// IsUpgrade reports whether r asks to switch to the WebSocket protocol.
+func IsUpgrade(r *http.Request) bool {
+	return headerHasToken(r.Header, "Connection", "upgrade") && headerHasToken(r.Header, "Upgrade", "websocket")
+}
+
+// Upgrade completes the server handshake for r and takes over its
+// connection. On error an HTTP error has been written and nothing else may
+// be; on success the caller owns the Conn and must Close it.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "websocket handshake requires GET", http.StatusMethodNotAllowed)
+		return nil, errors.New("ws: handshake method is not GET")
+	}
+	if !IsUpgrade(r) {
+		http.Error(w, "websocket upgrade required", http.StatusUpgradeRequired)
+		return nil, errors.New("ws: request is not a websocket upgrade")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return nil, errors.New("ws: unsupported protocol version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		http.Error(w, "invalid Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, errors.New("ws: invalid Sec-WebSocket-Key")
+	}
+
+	nc, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, "websocket unavailable", http.StatusInternalServerError)
+		return nil, fmt.Errorf("ws: hijack: %w", err)
+	}
+	// The handshake is the last thing sent with the server's deadlines.
+	_ = nc.SetDeadline(time.Time{})
+	resp := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	if _, err := rw.WriteString(resp); err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("ws: write handshake: %w", err)
+	}
+	if err := rw.Flush(); err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("ws: write handshake: %w", err)
+	}
+	return newConn(nc, rw.Reader, false), nil
+}
+
+// acceptKey derives Sec-WebSocket-Accept from the client's key.
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerHasToken reports whether a comma-separated header lists token,
+// ignoring case.
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, part := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Conn is one WebSocket connection. One goroutine may read while others
+// write; writes are serialized.
+type Conn struct {
+	nc     net.Conn
+	br     *bufio.Reader
+	client bool
+	limit  int64
+
+	wmu       sync.Mutex
+	closeSent bool
+}
+
+func newConn(nc net.Conn, br *bufio.Reader, client bool) *Conn {
+	if br == nil {
+		br = bufio.NewReader(nc)
+	}
+	return &Conn{nc: nc, br: br, client: client, limit: DefaultReadLimit}
+}
+
+// SetReadLimit bounds the size of a message, fragments included.
+func (c *Conn) SetReadLimit(n int64) {
+	c.limit = n
+}
+
+// SetReadDeadline bounds the wait for the next frame; a zero t waits
+// forever.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.nc.SetReadDeadline(t)
+}
+
+// SetWriteDeadline bounds writes; a zero t waits forever.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.nc.SetWriteDeadline(t)
+}
+
+// RemoteAddr is the peer's network address.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.nc.RemoteAddr()
+}
+
+// ReadMessage returns the next text or binary message. Pings are answered
+// and pongs skipped while waiting. Once the peer closes, its close frame is
+// echoed and a *CloseError returned; protocol violations, invalid UTF-8 in a
+// text message, and messages over the read limit close the connection with
+// the matching code.
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	var (
+		msgType int
+		msg     []byte
+	)
+	for {
+		h, err := c.readHeader()
+		if err != nil {
+			return 0, nil, err
+		}
+		if h.op >= opClose {
+			payload, err := c.readPayload(h)
+			if err != nil {
+				return 0, nil, err
+			}
+			if err := c.control(h.op, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		}
+		switch {
+		case h.op == opContinuation && msgType == 0:
+			return 0, nil, c.fail(CloseProtocolError, "continuation without a message")
+		case h.op != opContinuation && msgType != 0:
+			return 0, nil, c.fail(CloseProtocolError, "message interrupted by another")
+		case h.op == TextMessage, h.op == BinaryMessage:
+			msgType = int(h.op)
+		case h.op != opContinuation:
+			return 0, nil, c.fail(CloseProtocolError, fmt.Sprintf("unknown opcode %d", h.op))
+		}
+		if c.limit > 0 && int64(len(msg))+int64(h.length) > c.limit {
+			_ = c.fail(CloseMessageTooBig, "message too large")
+			return 0, nil, ErrMessageTooLarge
+		}
+		payload, err := c.readPayload(h)
+		if err != nil {
+			return 0, nil, err
+		}
+		msg = append(msg, payload...)
+		if !h.fin {
+			continue
+		}
+		if msgType == TextMessage && !utf8.Valid(msg) {
+			return 0, nil, c.fail(CloseInvalidPayload, "text message is not UTF-8")
+		}
+		return msgType, msg, nil
+	}
+}
+
+// ReadJSON reads the next message into v.
+func (c *Conn) ReadJSON(v any) error {
+	_, msg, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(msg, v)
+}
+
+// control handles a ping, pong, or close frame.
+func (c *Conn) control(op byte, payload []byte) error {
+	switch op {
+	case opPing:
+		if err := c.writeFrame(opPong, payload); err != nil && !errors.Is(err, ErrClosed) {
+			return err
+		}
+		return nil
+	case opPong:
+		return nil
+	case opClose:
+		code, reason := CloseNoStatus, ""
+		switch {
+		case len(payload) == 1:
+			return c.fail(CloseProtocolError, "truncated close code")
+		case len(payload) >= 2:
+			code, reason = int(binary.BigEndian.Uint16(payload)), string(payload[2:])
+		}
+		echo := code
+		if echo == CloseNoStatus {
+			echo = CloseNormal
+		}
+		_ = c.Close(echo, "")
+		return &CloseError{Code: code, Reason: reason}
+	}
+	return c.fail(CloseProtocolError, fmt.Sprintf("unknown opcode %d", op))
+}
+
+// fail closes the connection with code after a protocol error by the peer.
+func (c *Conn) fail(code int, reason string) error {
+	_ = c.Close(code, reason)
+	return fmt.Errorf("ws: %s", reason)
+}
+
+type frameHeader struct {
+	fin    bool
+	op     byte
+	length uint64
+	masked bool
+	mask   [4]byte
+}
+
+func (c *Conn) readHeader() (frameHeader, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(c.br, b[:2]); err != nil {
+		return frameHeader{}, err
+	}
+	h := frameHeader{fin: b[0]&0x80 != 0, op: b[0] & 0x0f, masked: b[1]&0x80 != 0, length: uint64(b[1] & 0x7f)}
+	if b[0]&0x70 != 0 {
+		return h, c.fail(CloseProtocolError, "reserved bits set")
+	}
+	switch h.length {
+	case 126:
+		if _, err := io.ReadFull(c.br, b[:2]); err != nil {
+			return h, err
+		}
+		h.length = uint64(binary.BigEndian.Uint16(b[:2]))
+	case 127:
+		if _, err := io.ReadFull(c.br, b[:8]); err != nil {
+			return h, err
+		}
+		h.length = binary.BigEndian.Uint64(b[:8])
+		if h.length>>63 != 0 {
+			return h, c.fail(CloseProtocolError, "invalid frame length")
+		}
+	}
+	if h.op >= opClose && (!h.fin || h.length > maxControlPayload) {
+		return h, c.fail(CloseProtocolError, "invalid control frame")
+	}
+	// Clients mask every frame and servers none.
+	if h.masked == c.client {
+		return h, c.fail(CloseProtocolError, "frame masking is wrong for this side")
+	}
+	if h.masked {
+		if _, err := io.ReadFull(c.br, h.mask[:]); err != nil {
+			return h, err
+		}
+	}
+	return h, nil
+}
+
+func (c *Conn) readPayload(h frameHeader) ([]byte, error) {
+	payload := make([]byte, h.length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return nil, err
+	}
+	if h.masked {
+		maskBytes(h.mask, payload)
+	}
+	return payload, nil
+}
+
+func maskBytes(mask [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= mask[i%4]
+	}
+}
+
+// WriteMessage sends data as one text or binary frame.
+func (c *Conn) WriteMessage(msgType int, data []byte) error {
+	if msgType != TextMessage && msgType != BinaryMessage {
+		return fmt.Errorf("ws: invalid message type %d", msgType)
+	}
+	return c.writeFrame(byte(msgType), data)
+}
+
+// WriteJSON sends v as a text message.
+func (c *Conn) WriteJSON(v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(TextMessage, raw)
+}
+
+func (c *Conn) writeFrame(op byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closeSent {
+		return ErrClosed
+	}
+	return c.writeFrameLocked(op, payload)
+}
+
+func (c *Conn) writeFrameLocked(op byte, payload []byte) error {
+	header := make([]byte, 0, 14)
+	header = append(header, 0x80|op)
+	var maskBit byte
+	if c.client {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n <= maxControlPayload:
+		header = append(header, maskBit|byte(n))
+	case n <= 0xffff:
+		header = append(header, maskBit|126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header = append(header, maskBit|127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	frame := payload
+	if c.client {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		header = append(header, mask[:]...)
+		frame = append([]byte(nil), payload...)
+		maskBytes(mask, frame)
+	}
+	if _, err := c.nc.Write(append(header, frame...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Close sends a close frame with code and reason, then closes the
+// connection without waiting for the peer's reply. Closing twice is a no-op.
+func (c *Conn) Close(code int, reason string) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closeSent {
+		return nil
+	}
+	c.closeSent = true
+	if len(reason) > maxControlPayload-2 {
+		reason = reason[:maxControlPayload-2]
+	}
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	_ = c.nc.SetWriteDeadline(time.Now().Add(closeTimeout))
+	err := c.writeFrameLocked(opClose, append(payload, reason...))
+	if cerr := c.nc.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,129 @@
+package ws
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// echoServer echoes every message back until the client closes.
+func echoServer(t *testing.T, limit int64) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer c.Close(CloseNormal, "")
+		c.SetReadLimit(limit)
+		for {
+			typ, msg, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := c.WriteMessage(typ, msg); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+// writeRaw sends one masked client frame with the given FIN bit.
+func writeRaw(t *testing.T, c *Conn, fin bool, op byte, payload []byte) {
+	t.Helper()
+	b0 := op
+	if fin {
+		b0 |= 0x80
+	}
+	mask := [4]byte{1, 2, 3, 4}
+	frame := append([]byte{b0, 0x80 | byte(len(payload))}, mask[:]...)
+	masked := append([]byte(nil), payload...)
+	maskBytes(mask, masked)
+	if _, err := c.nc.Write(append(frame, masked...)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEcho(t *testing.T) {
+	c, err := Dial(t.Context(), echoServer(t, 1<<20), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close(CloseNormal, "")
+
+	long := strings.Repeat("x", 70000)
+	for _, msg := range []string{"hello", strings.Repeat("y", 300), long} {
+		if err := c.WriteMessage(TextMessage, []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+		typ, got, err := c.ReadMessage()
+		if err != nil || typ != TextMessage || string(got) != msg {
+			t.Fatalf("echo of %d bytes = %d, %d bytes, %v", len(msg), typ, len(got), err)
+		}
+	}
+
+	// A fragmented message with a ping between fragments.
+	writeRaw(t, c, false, TextMessage, []byte("frag"))
+	writeRaw(t, c, true, opPing, []byte("p"))
+	writeRaw(t, c, true, opContinuation, []byte("mented"))
+	typ, got, err := c.ReadMessage()
+	if err != nil || typ != TextMessage || string(got) != "fragmented" {
+		t.Fatalf("fragmented echo = %d %q %v", typ, got, err)
+	}
+}
+
+func TestReadLimitAndClose(t *testing.T) {
+	url := echoServer(t, 16)
+	c, err := Dial(t.Context(), url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WriteMessage(TextMessage, []byte(strings.Repeat("z", 17))); err != nil {
+		t.Fatal(err)
+	}
+	var ce *CloseError
+	if _, _, err := c.ReadMessage(); !errors.As(err, &ce) || ce.Code != CloseMessageTooBig {
+		t.Fatalf("oversized message: err = %v, want close %d", err, CloseMessageTooBig)
+	}
+
+	c, err = Dial(t.Context(), url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeRaw(t, c, true, opClose, []byte{0x03, 0xe8})
+	if _, _, err := c.ReadMessage(); !errors.As(err, &ce) || ce.Code != CloseNormal {
+		t.Fatalf("close echo: err = %v", err)
+	}
+	if err := c.WriteMessage(TextMessage, []byte("late")); !errors.Is(err, ErrClosed) {
+		t.Fatalf("write after close = %v, want ErrClosed", err)
+	}
+}
+
+func TestHandshakeRejected(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Set("Sec-WebSocket-Version", "8")
+		_, _ = Upgrade(w, r)
+	}))
+	defer srv.Close()
+	_, err := Dial(t.Context(), "ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	var he *HandshakeError
+	if !errors.As(err, &he) || he.Status != http.StatusUpgradeRequired {
+		t.Fatalf("err = %v, want 426 handshake error", err)
+	}
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUpgradeRequired {
+		t.Fatalf("plain GET status = %d", resp.StatusCode)
+	}
+	if acceptKey("dGhlIHNhbXBsZSBub25jZQ==") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatal("accept key does not match RFC 6455 example")
+	}
+}
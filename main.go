@@ -1,114 +1,724 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/ed25519"
+	"errors"
 	"log"
+	"net"
 	"net/http"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
+	// Embedded so ?tz= zone names resolve on hosts without a zoneinfo
+	// database, such as scratch containers.
+	_ "time/tzdata"
 
 	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/forward"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/hl7"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/llm/openai"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/metrics"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/notify/email"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/server"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/signing"
+	"github.com/Skufu/Clinical-AI-Assistant/internal/trace"
 )
 
 func main() {
-	baseDir, err := os.Getwd()
+	thresholds := analysis.RiskThresholds{
+		Medium:   envInt("RISK_THRESHOLD_MEDIUM", analysis.DefaultRiskThresholds.Medium),
+		High:     envInt("RISK_THRESHOLD_HIGH", analysis.DefaultRiskThresholds.High),
+		Critical: envInt("RISK_THRESHOLD_CRITICAL", analysis.DefaultRiskThresholds.Critical),
+	}
+	if err := analysis.SetRiskThresholds(thresholds); err != nil {
+		log.Fatalf("invalid risk thresholds: %v", err)
+	}
+
+	demo := envBool("DEMO_MODE")
+	backupDir := envString("AUDIT_BACKUP_DIR", "")
+	if demo {
+		// Demo cases must never reach a real audit trail.
+		if path := envString("SQLITE_PATH", ""); path != "" {
+			log.Fatalf("DEMO_MODE=true refuses SQLITE_PATH=%s; demo mode keeps its audits in memory", path)
+		}
+		analysis.SetAuditStore(memoryAuditStore())
+	} else {
+		closeStore := openAuditStore(backupDir)
+		defer closeStore()
+	}
+
+	if path := envString("SYSTEM_PROMPT_PATH", ""); path != "" {
+		if err := analysis.LoadSystemPromptFile(path); err != nil {
+			log.Fatalf("invalid system prompt: %v", err)
+		}
+	}
+	log.Printf("system prompt version=%s source=%s", analysis.PromptVersion(), analysis.ActivePrompt().Source)
+
+	rulesPath := envString("RULES_PATH", "")
+	if rulesPath != "" {
+		// A missing file keeps the embedded rules until the first PUT
+		// /api/admin/rules writes one.
+		if err := analysis.LoadRulesFile(rulesPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Fatalf("invalid rules: %v", err)
+		}
+	}
+
+	if dir := envString("LOCALES_DIR", ""); dir != "" {
+		if err := analysis.LoadLocaleDir(dir); err != nil {
+			log.Fatalf("invalid locales: %v", err)
+		}
+	}
+	log.Printf("locales=%s", strings.Join(analysis.Locales(), ","))
+	if path := envString("EDUCATION_PATH", ""); path != "" {
+		if err := analysis.LoadEducationFile(path); err != nil {
+			log.Fatalf("invalid education catalog: %v", err)
+		}
+		log.Printf("education catalog source=%s", path)
+	}
+	if path := envString("TRIAGE_PATH", ""); path != "" {
+		if err := analysis.LoadTriageFile(path); err != nil {
+			log.Fatalf("invalid triage catalog: %v", err)
+		}
+		log.Printf("triage catalog source=%s", path)
+	}
+	if size := envInt("ANALYSIS_CACHE_SIZE", 0); size > 0 {
+		ttl := time.Duration(envInt("ANALYSIS_CACHE_TTL_SECONDS", 300)) * time.Second
+		analysis.SetResultCache(size, ttl)
+		log.Printf("analysis result cache size=%d ttl=%s", size, ttl)
+	}
+	if path := envString("ORGS_PATH", ""); path != "" {
+		if err := analysis.LoadOrgsFile(path); err != nil {
+			log.Fatalf("invalid orgs: %v", err)
+		}
+		log.Printf("orgs=%s source=%s", strings.Join(analysis.Orgs(), ","), path)
+	}
+	rules := analysis.Rules()
+	log.Printf("ruleset_version=%s rules=%s rules_source=%s prompt=%s build=%s",
+		analysis.RulesetVersion(), rules.Version, rules.Source, analysis.PromptVersion(), analysis.BuildVersion())
+
+	configurePseudonymizer()
+	if envBool("DECISION_REVISIONS") {
+		analysis.SetDecisionRevisions(true)
+		log.Printf("clinician decisions may be revised")
+	}
+	configureConsent()
+	configureListConfirmation()
+	configureHistoryCeiling()
+	configureSigning()
+	configureDisclaimers()
+	configureNoteHeaders()
+	if v, err := strconv.ParseBool(strings.TrimSpace(os.Getenv("AUDIT_STORE_INTAKE"))); err == nil && !v {
+		analysis.SetStoreIntakes(false)
+		log.Printf("intakes not stored with audits; what-if and re-analysis are unavailable for new analyses")
+	}
+	configureBundle()
+	if demo {
+		seedDemo()
+	}
+	// The LLM, notifiers, and forwarders join after the demo seed, so seeded
+	// cases are scored by the rules alone, alert no one, and stay local.
+	configureLLM()
+	configureNotifications()
+	stopForwarding := configureForwarding()
+	stopTracing := configureTracing()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	wasmDir := envString("WASM_DIR", "")
+	csp := server.DefaultContentSecurityPolicy
+	if wasmDir != "" {
+		log.Printf("serving the wasm pre-screen from %s", wasmDir)
+		csp = server.WASMContentSecurityPolicy
+	}
+	addr := ":8080"
+	srv := &http.Server{Addr: addr, Handler: server.New(server.Config{
+		Static:                staticFiles(),
+		AppPage:               envString("APP_PAGE", server.DefaultAppPage),
+		ContentSecurityPolicy: envString("CONTENT_SECURITY_POLICY", csp),
+		SlowRequest:           time.Duration(envInt("SLOW_REQUEST_MS", int(server.DefaultSlowRequest/time.Millisecond))) * time.Millisecond,
+		SlowRequestPercentile: envFloat("SLOW_REQUEST_PERCENTILE", 0),
+		AdminToken:            envString("ADMIN_TOKEN", ""),
+		RulesPath:             rulesPath,
+		BackupDir:             backupDir,
+		BackupKeep:            envInt("AUDIT_BACKUP_KEEP", 7),
+		StrictAudit:           envBool("AUDIT_STRICT"),
+		WASMDir:               wasmDir,
+		Shutdown:              ctx,
+	})}
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("shutdown: %v", err)
+		}
+	}()
+
+	mllpDone := startMLLP(ctx)
+	remindersDone := startReminders(ctx)
+	latencyDone := startLatencyChecks(ctx)
+
+	log.Printf("Clinical AI Assistant backend running on %s", addr)
+	if err := listen(srv); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("server error: %v", err)
+	}
+	<-drained
+	<-mllpDone
+	<-remindersDone
+	<-latencyDone
+	// Let shadow comparisons land before the store is closed by the deferred Close.
+	analysis.WaitShadow()
+	analysis.WaitNotifications()
+	stopForwarding()
+	stopTracing()
+	log.Printf("server stopped")
+}
+
+// startMLLP serves HL7 v2 analyses over MLLP on HL7_MLLP_ADDR until ctx is
+// done. The returned channel closes once the listener has drained; it is
+// closed at once when HL7_MLLP_ADDR is unset.
+func startMLLP(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	addr := envString("HL7_MLLP_ADDR", "")
+	if addr == "" {
+		close(done)
+		return done
+	}
+	s, err := hl7.NewServer(hl7.Config{
+		Complaint:    envString("HL7_DEFAULT_COMPLAINT", ""),
+		AuditIDField: envString("HL7_ACK_AUDIT_FIELD", hl7.DefaultAuditIDField),
+		Org:          envString("HL7_ORG", ""),
+		StrictAudit:  envBool("AUDIT_STRICT"),
+	})
+	if err != nil {
+		log.Fatalf("invalid HL7 listener config: %v", err)
+	}
+	ln, err := net.Listen("tcp", addr)
 	if err != nil {
-		log.Fatalf("failed to resolve working directory: %v", err)
+		log.Fatalf("HL7 listener: %v", err)
 	}
+	log.Printf("HL7 MLLP listener running on %s", ln.Addr())
+	go func() {
+		defer close(done)
+		if err := s.Serve(ctx, ln); err != nil {
+			log.Printf("HL7 listener stopped: %v", err)
+		}
+	}()
+	return done
+}
+
+// startReminders marks overdue follow-up reminders every
+// REMINDER_CHECK_MINUTES until ctx is done, notifying about them when
+// REMINDER_NOTIFY is set; 0 turns the check off.
+func startReminders(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	every := time.Duration(envInt("REMINDER_CHECK_MINUTES", 60)) * time.Minute
+	if every <= 0 {
+		close(done)
+		return done
+	}
+	analysis.SetReminderNotifications(envBool("REMINDER_NOTIFY"))
+	log.Printf("reminder check running every %s", every)
+	go func() {
+		defer close(done)
+		analysis.RunReminders(ctx, every)
+	}()
+	return done
+}
+
+// startLatencyChecks refreshes the analysis latency gauges every
+// LATENCY_CHECK_SECONDS (30 by default, 0 off) until ctx is done. With
+// SLO_THRESHOLD_MS set, each check also judges the rolling windows against
+// an SLO_PERCENTILE (95) objective, alerting the notifiers once a window has
+// been over it for SLO_SUSTAIN_MINUTES (5) with at least SLO_MIN_SAMPLES
+// analyses in view.
+func startLatencyChecks(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	if ms := envInt("SLO_THRESHOLD_MS", 0); ms > 0 {
+		slo := analysis.SLO{
+			Percentile: envFloat("SLO_PERCENTILE", 95),
+			Threshold:  time.Duration(ms) * time.Millisecond,
+			Sustain:    time.Duration(envInt("SLO_SUSTAIN_MINUTES", 5)) * time.Minute,
+			MinSamples: envInt("SLO_MIN_SAMPLES", analysis.DefaultSLOMinSamples),
+		}
+		if err := analysis.SetSLO(slo); err != nil {
+			log.Fatalf("invalid latency SLO: %v", err)
+		}
+		log.Printf("latency SLO p%v <= %s sustained %s", slo.Percentile, slo.Threshold, slo.Sustain)
+	}
+	every := time.Duration(envInt("LATENCY_CHECK_SECONDS", 30)) * time.Second
+	if every <= 0 {
+		close(done)
+		return done
+	}
+	go func() {
+		defer close(done)
+		analysis.RunLatencyChecks(ctx, every)
+	}()
+	return done
+}
+
+// listen serves HTTPS when TLS_CERT_FILE and TLS_KEY_FILE are both set and
+// plain HTTP otherwise, e.g. behind a TLS-terminating proxy.
+func listen(srv *http.Server) error {
+	cert, key := envString("TLS_CERT_FILE", ""), envString("TLS_KEY_FILE", "")
+	if cert != "" && key != "" {
+		log.Printf("serving TLS with certificate %s", cert)
+		return srv.ListenAndServeTLS(cert, key)
+	}
+	return srv.ListenAndServe()
+}
+
+// envInt reads an integer environment variable, falling back to def when unset or malformed.
+func envInt(key string, def int) int {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		log.Printf("ignoring invalid %s=%q: %v", key, raw, err)
+		return def
+	}
+	return v
+}
+
+// envFloat reads a decimal environment variable, falling back to def when unset or malformed.
+func envFloat(key string, def float64) float64 {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		log.Printf("ignoring invalid %s=%q: %v", key, raw, err)
+		return def
+	}
+	return v
+}
 
-	assetsDir := filepath.Join(baseDir, "assets")
-	http.Handle("/assets/", http.StripPrefix("/assets/", http.FileServer(http.Dir(assetsDir))))
+// restoreAuditDB replaces the audit database at path with the newest backup in
+// dir when it is missing or fails its integrity check.
+func restoreAuditDB(path, dir string) {
+	err := audit.CheckSQLite(path)
+	if err == nil {
+		return
+	}
+	backup, lerr := audit.LatestBackup(dir)
+	if lerr != nil {
+		log.Printf("audit database unusable (%v) and no backup to restore: %v", err, lerr)
+		return
+	}
+	if rerr := audit.Restore(path, backup); rerr != nil {
+		log.Fatalf("restore audit database: %v", rerr)
+	}
+	log.Printf("audit database unusable (%v); restored from %s", err, backup)
+}
 
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		// Serve the marketing landing at root.
-		if r.URL.Path != "/" {
-			http.NotFound(w, r)
-			return
+// openAuditStore sets the SQLite store at SQLITE_PATH as the audit store,
+// restoring it from backupDir first when AUDIT_RESTORE_ON_START is set. With
+// SQLITE_PATH unset, or when it cannot be opened, memoryAuditStore is used.
+// The returned func closes it.
+func openAuditStore(backupDir string) func() {
+	var storeOpts []audit.SQLiteOption
+	if c := auditCipher(); c != nil {
+		storeOpts = append(storeOpts, audit.WithCipher(c))
+	}
+	sqlitePath := envString("SQLITE_PATH", "")
+	if sqlitePath == "" {
+		log.Printf("audits kept in memory; set SQLITE_PATH to persist them")
+		analysis.SetAuditStore(memoryAuditStore())
+		return func() {}
+	}
+	if backupDir != "" && envBool("AUDIT_RESTORE_ON_START") {
+		restoreAuditDB(sqlitePath, backupDir)
+	}
+	store, err := audit.NewSQLiteStore(sqlitePath, storeOpts...)
+	if err != nil {
+		log.Printf("sqlite audit store unavailable, using in-memory store: %v", err)
+		analysis.SetAuditStore(memoryAuditStore())
+		return func() {}
+	}
+	if len(storeOpts) > 0 && envBool("AUDIT_ENCRYPT_EXISTING") {
+		n, err := store.EncryptPlaintextRows()
+		if err != nil {
+			log.Fatalf("encrypt existing audit rows: %v", err)
 		}
-		http.ServeFile(w, r, filepath.Join(baseDir, "landing.html"))
+		log.Printf("encrypted %d existing audit rows", n)
+	}
+	analysis.SetAuditStore(store)
+	return func() { _ = store.Close() }
+}
+
+// seedDemo records an analysis of every embedded demo case, so a DEMO_MODE
+// deployment starts with audits, trends, and reports to show.
+func seedDemo() {
+	ids, err := analysis.SeedDemo(context.Background())
+	if err != nil {
+		log.Fatalf("seed demo cases: %v", err)
+	}
+	log.Printf("demo mode: seeded %d example analyses; every response carries the demo watermark", len(ids))
+}
+
+// memoryAuditStore is the fallback audit store, holding AUDIT_MEMORY_CAPACITY
+// audits (0 for all) and logging each one it evicts.
+func memoryAuditStore() *audit.MemoryStore {
+	store := audit.NewMemoryStore(
+		audit.WithCapacity(envInt("AUDIT_MEMORY_CAPACITY", audit.DefaultMemoryCapacity)),
+		audit.WithEviction(func(s audit.Summary) {
+			log.Printf("in-memory audit store full; evicted audit_id=%s at=%s risk=%s", s.AuditID, s.At, s.RiskLevel)
+		}),
+	)
+	metrics.NewGaugeFunc("audit_memory_store_entries", "Audits held by the in-memory fallback store.", func() float64 {
+		return float64(store.Len())
 	})
+	return store
+}
+
+// auditCipher builds the column cipher from AUDIT_ENCRYPTION_KEY (hex or base64)
+// or AUDIT_ENCRYPTION_KEY_FILE; nil means audit columns stay plaintext.
+func auditCipher() *audit.FieldCipher {
+	var key []byte
+	var err error
+	switch {
+	case envString("AUDIT_ENCRYPTION_KEY", "") != "":
+		key, err = audit.ParseKey(envString("AUDIT_ENCRYPTION_KEY", ""))
+	case envString("AUDIT_ENCRYPTION_KEY_FILE", "") != "":
+		key, err = audit.LoadKeyFile(envString("AUDIT_ENCRYPTION_KEY_FILE", ""))
+	default:
+		return nil
+	}
+	if err != nil {
+		log.Fatalf("invalid audit encryption key: %v", err)
+	}
+	c, err := audit.NewFieldCipher(key)
+	if err != nil {
+		log.Fatalf("invalid audit encryption key: %v", err)
+	}
+	return c
+}
+
+// configurePseudonymizer selects how patient names become audit references.
+// PATIENT_REF_MODE=legacy keeps first-letter redaction; otherwise references are
+// HMACs keyed by PATIENT_REF_KEY, or by a per-process key when none is set.
+func configurePseudonymizer() {
+	if strings.EqualFold(envString("PATIENT_REF_MODE", "hmac"), "legacy") {
+		analysis.SetPseudonymizer(analysis.LegacyPseudonymizer{})
+		return
+	}
+	key := envString("PATIENT_REF_KEY", "")
+	if key == "" {
+		log.Printf("PATIENT_REF_KEY not set; patient references will change on restart")
+		return
+	}
+	p, err := analysis.NewHMACPseudonymizer([]byte(key))
+	if err != nil {
+		log.Fatalf("invalid PATIENT_REF_KEY: %v", err)
+	}
+	analysis.SetPseudonymizer(p)
+}
 
-	http.HandleFunc("/landing", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, filepath.Join(baseDir, "landing.html"))
+// configureLLM installs the OpenAI-compatible scorer when an API key is present
+// and LLM_DISABLED is not set; otherwise the deterministic stub stays active.
+func configureLLM() {
+	if envBool("LLM_DISABLED") {
+		log.Printf("LLM scoring disabled; using deterministic stub")
+		return
+	}
+	key := envString("OPENAI_API_KEY", "")
+	if key == "" {
+		return
+	}
+	client, err := openai.New(openai.Config{
+		BaseURL: envString("OPENAI_BASE_URL", openai.DefaultBaseURL),
+		APIKey:  key,
+		Model:   envString("OPENAI_MODEL", openai.DefaultModel),
 	})
+	if err != nil {
+		log.Printf("LLM client unavailable, using deterministic stub: %v", err)
+		return
+	}
+	var scorer analysis.LLMClient = client
+	if size := envInt("LLM_CACHE_SIZE", 256); size > 0 {
+		ttl := time.Duration(envInt("LLM_CACHE_TTL_SECONDS", 600)) * time.Second
+		scorer = analysis.NewCachingLLMClient(client, size, ttl)
+	}
+	analysis.SetLLMClient(scorer)
+	analysis.SetLLMTimeout(time.Duration(envInt("LLM_TIMEOUT_MS", 0)) * time.Millisecond)
+	if envBool("LLM_SHADOW_MODE") {
+		analysis.SetLLMShadowMode(true)
+		log.Printf("LLM shadow mode enabled; responses use the deterministic stub")
+	}
+}
 
-	http.HandleFunc("/app", func(w http.ResponseWriter, r *http.Request) {
-		// Serve the clinical assistant UI at /app.
-		http.ServeFile(w, r, filepath.Join(baseDir, "index (3).html"))
+// configureNotifications mails danger flags to SMTP_TO through SMTP_HOST when
+// both are set.
+func configureNotifications() {
+	host := envString("SMTP_HOST", "")
+	if host == "" {
+		return
+	}
+	n, err := email.New(email.Config{
+		Host:     host,
+		Port:     envInt("SMTP_PORT", email.DefaultPort),
+		Username: envString("SMTP_USERNAME", ""),
+		Password: envString("SMTP_PASSWORD", ""),
+		From:     envString("SMTP_FROM", ""),
+		To:       strings.Split(envString("SMTP_TO", ""), ","),
 	})
+	if err != nil {
+		log.Fatalf("invalid SMTP notifier config: %v", err)
+	}
+	analysis.AddNotifier(n)
+	log.Printf("danger flags mailed via %s", host)
+}
 
-	http.HandleFunc("/api/audit", func(w http.ResponseWriter, r *http.Request) {
-		addCORS(w)
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
-			return
+// configureForwarding ships every written audit to syslog at
+// AUDIT_FORWARD_SYSLOG_ADDR (AUDIT_FORWARD_SYSLOG_NETWORK tcp or udp) and to
+// the HTTPS collector at AUDIT_FORWARD_URL, with AUDIT_FORWARD_TOKEN as its
+// bearer token; either, both, or neither may be set. Each queues up to
+// AUDIT_FORWARD_QUEUE records. The returned func flushes the queues.
+func configureForwarding() func() {
+	var sinks []forward.Sink
+	if addr := envString("AUDIT_FORWARD_SYSLOG_ADDR", ""); addr != "" {
+		s, err := forward.NewSyslog(forward.SyslogConfig{
+			Network: envString("AUDIT_FORWARD_SYSLOG_NETWORK", "tcp"),
+			Addr:    addr,
+			AppName: envString("AUDIT_FORWARD_SYSLOG_APP", forward.DefaultAppName),
+		})
+		if err != nil {
+			log.Fatalf("invalid audit syslog forwarding config: %v", err)
 		}
-		if r.Method != http.MethodGet {
-			w.WriteHeader(http.StatusMethodNotAllowed)
-			return
+		sinks = append(sinks, s)
+		log.Printf("audits forwarded to syslog at %s", addr)
+	}
+	if u := envString("AUDIT_FORWARD_URL", ""); u != "" {
+		h, err := forward.NewHTTP(forward.HTTPConfig{URL: u, Token: envString("AUDIT_FORWARD_TOKEN", "")})
+		if err != nil {
+			log.Fatalf("invalid audit collector forwarding config: %v", err)
 		}
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(analysis.LatestAudits(10))
-	})
-
-	http.HandleFunc("/api/analyze", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			addCORS(w)
-			w.WriteHeader(http.StatusNoContent)
-			return
+		sinks = append(sinks, h)
+		log.Printf("audits forwarded to %s", u)
+	}
+	var forwarders []*forward.Forwarder
+	for _, s := range sinks {
+		f := forward.New(s, forward.Config{QueueSize: envInt("AUDIT_FORWARD_QUEUE", forward.DefaultQueueSize)})
+		analysis.AddAuditForwarder(f)
+		forwarders = append(forwarders, f)
+	}
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		for _, f := range forwarders {
+			if err := f.Close(ctx); err != nil {
+				log.Printf("audit forwarding shutdown: %v", err)
+			}
 		}
+	}
+}
 
-		if r.Method != http.MethodPost {
-			w.WriteHeader(http.StatusMethodNotAllowed)
-			return
-		}
+// configureConsent requires documented patient consent unless
+// CONSENT_REQUIRED=false. CONSENT_GRACE=true logs missing consent instead of
+// rejecting the analysis while clients are updated.
+func configureConsent() {
+	if v, err := strconv.ParseBool(strings.TrimSpace(os.Getenv("CONSENT_REQUIRED"))); err == nil && !v {
+		log.Printf("patient consent not required")
+		return
+	}
+	analysis.SetConsentRequired(true)
+	if envBool("CONSENT_GRACE") {
+		analysis.SetConsentGrace(true)
+		log.Printf("patient consent required in grace mode; missing consent is logged, not rejected")
+	}
+}
 
-		addCORS(w)
+// configureListConfirmation requires ED intakes to list medications and
+// conditions or confirm there are none, unless LIST_CONFIRMATION_REQUIRED=false.
+func configureListConfirmation() {
+	if v, err := strconv.ParseBool(strings.TrimSpace(os.Getenv("LIST_CONFIRMATION_REQUIRED"))); err == nil && !v {
+		log.Printf("medication and condition list confirmation not required")
+		return
+	}
+	analysis.SetListConfirmationRequired(true)
+}
 
-		var req analysis.Intake
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "invalid payload", http.StatusBadRequest)
-			return
-		}
+// configureHistoryCeiling caps plan confidence at HISTORY_CONFIDENCE_CEILING
+// while an intake leaves a history list unconfirmed.
+func configureHistoryCeiling() {
+	raw := strings.TrimSpace(os.Getenv("HISTORY_CONFIDENCE_CEILING"))
+	if raw == "" {
+		return
+	}
+	ceiling, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		log.Fatalf("invalid HISTORY_CONFIDENCE_CEILING=%q: %v", raw, err)
+	}
+	if err := analysis.SetHistoryConfidenceCeiling(ceiling); err != nil {
+		log.Fatalf("invalid HISTORY_CONFIDENCE_CEILING: %v", err)
+	}
+}
+
+// configureSigning signs responses with the Ed25519 key in
+// RESPONSE_SIGNING_KEY (hex or base64 seed, or PEM) or
+// RESPONSE_SIGNING_KEY_FILE, published under RESPONSE_SIGNING_KEY_ID or an ID
+// derived from the key. Neither set leaves responses unsigned.
+func configureSigning() {
+	var key ed25519.PrivateKey
+	var err error
+	switch {
+	case envString("RESPONSE_SIGNING_KEY", "") != "":
+		key, err = signing.ParsePrivateKey(envString("RESPONSE_SIGNING_KEY", ""))
+	case envString("RESPONSE_SIGNING_KEY_FILE", "") != "":
+		key, err = signing.LoadKeyFile(envString("RESPONSE_SIGNING_KEY_FILE", ""))
+	default:
+		return
+	}
+	if err != nil {
+		log.Fatalf("invalid response signing key: %v", err)
+	}
+	if err := analysis.SetSigningKey(envString("RESPONSE_SIGNING_KEY_ID", ""), key); err != nil {
+		log.Fatalf("invalid response signing key: %v", err)
+	}
+	pub, _ := analysis.SigningKey()
+	log.Printf("responses signed key_id=%s", pub.KeyID)
+}
 
-		resp := analysis.Analyze(req)
-		if len(resp.ValidationErrors) > 0 {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusBadRequest)
-			_ = json.NewEncoder(w).Encode(map[string]any{
-				"error":   "validation_failed",
-				"details": resp.ValidationErrors,
-			})
-			return
+// configureDisclaimers replaces the embedded disclaimers with DISCLAIMERS_PATH,
+// one per line. A file with none disables them, which APP_ENV=production
+// refuses so a production response never goes out without one.
+func configureDisclaimers() {
+	path := envString("DISCLAIMERS_PATH", "")
+	if path == "" {
+		log.Printf("disclaimers=%d source=embedded", len(analysis.Disclaimers()))
+		return
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("invalid disclaimers: %v", err)
+	}
+	d := analysis.ParseDisclaimers(string(b))
+	if len(d) == 0 {
+		if strings.EqualFold(envString("APP_ENV", ""), "production") {
+			log.Fatalf("invalid disclaimers: %s lists none, and production responses must carry one", path)
 		}
+		log.Printf("disclaimers disabled: %s lists none", path)
+	}
+	analysis.SetDisclaimers(d)
+	log.Printf("disclaimers=%d source=%s", len(d), path)
+}
+
+// configureNoteHeaders renames the visit note sections from
+// NOTE_HEADER_SUBJECTIVE, NOTE_HEADER_OBJECTIVE, NOTE_HEADER_ASSESSMENT, and
+// NOTE_HEADER_PLAN; unset ones keep the SOAP default.
+func configureNoteHeaders() {
+	h := analysis.NoteHeaders{
+		Subjective: envString("NOTE_HEADER_SUBJECTIVE", ""),
+		Objective:  envString("NOTE_HEADER_OBJECTIVE", ""),
+		Assessment: envString("NOTE_HEADER_ASSESSMENT", ""),
+		Plan:       envString("NOTE_HEADER_PLAN", ""),
+	}
+	if h == (analysis.NoteHeaders{}) {
+		return
+	}
+	if err := analysis.SetNoteHeaders(h); err != nil {
+		log.Fatalf("invalid note headers: %v", err)
+	}
+}
 
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(resp); err != nil {
-			http.Error(w, "failed to encode response", http.StatusInternalServerError)
-			return
+// configureBundle installs the bundle at BUNDLE_APPLY to BUNDLE_PATH, refusing
+// one older than the installed bundle unless BUNDLE_FORCE=true, then applies
+// the bundle at BUNDLE_PATH over the settings above, logging which it
+// replaced. Bundles must be signed by a key in BUNDLE_TRUSTED_KEYS or by the
+// response signing key, and APP_ENV=production refuses one without
+// disclaimers.
+func configureBundle() {
+	path := envString("BUNDLE_PATH", "")
+	apply := envString("BUNDLE_APPLY", "")
+	if path == "" {
+		if apply != "" {
+			log.Fatalf("BUNDLE_APPLY=%s needs BUNDLE_PATH to install it to", apply)
 		}
+		return
+	}
+	var trusted []ed25519.PublicKey
+	for _, s := range strings.Split(envString("BUNDLE_TRUSTED_KEYS", ""), ",") {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		pub, err := signing.ParsePublicKey(s)
+		if err != nil {
+			log.Fatalf("invalid BUNDLE_TRUSTED_KEYS: %v", err)
+		}
+		trusted = append(trusted, pub)
+	}
+	if apply != "" {
+		data, err := os.ReadFile(apply)
+		if err != nil {
+			log.Fatalf("read BUNDLE_APPLY: %v", err)
+		}
+		m, err := analysis.InstallBundleFile(path, data, trusted, envBool("BUNDLE_FORCE"))
+		if err != nil {
+			log.Fatalf("bundle %s not installed: %v", apply, err)
+		}
+		log.Printf("bundle installed version=%d hash=%s path=%s", m.Version, m.Hash, path)
+	}
+	before := analysis.ActiveConfig()
+	m, err := analysis.LoadBundleFile(path, trusted)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return
+	case err != nil:
+		log.Fatalf("invalid bundle: %v", err)
+	}
+	// The bundle replaces what configureDisclaimers checked, so check again.
+	if len(analysis.Disclaimers()) == 0 && strings.EqualFold(envString("APP_ENV", ""), "production") {
+		log.Fatalf("invalid bundle: %s lists no disclaimers, and production responses must carry one", path)
+	}
+	log.Printf("bundle applied version=%d hash=%s created=%s ruleset_version=%s",
+		m.Version, m.Hash, m.CreatedAt.Format(time.RFC3339), analysis.RulesetVersion())
+	if o := analysis.ActiveConfig().Overrides(before); len(o) > 0 {
+		log.Printf("bundle overrode environment settings: %s", strings.Join(o, ", "))
+	}
+}
 
-		// Minimal audit logging (redacted name).
-		ref := req.PatientName
-		if len(ref) > 2 {
-			ref = ref[:1] + "***"
+// configureTracing exports spans over OTLP/HTTP JSON when the standard
+// OTEL_EXPORTER_OTLP_* variables name an endpoint; otherwise tracing stays a
+// no-op. The returned func flushes queued spans.
+func configureTracing() func() {
+	cfg, ok, err := trace.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("invalid tracing config: %v", err)
+	}
+	if !ok {
+		return func() {}
+	}
+	exp := trace.NewOTLPExporter(cfg)
+	trace.SetExporter(exp)
+	log.Printf("tracing enabled; exporting to %s", cfg.Endpoint)
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := exp.Shutdown(ctx); err != nil {
+			log.Printf("trace shutdown: %v", err)
 		}
-		log.Printf("analysis audit_id=%s patient=%s complaint=%s risk=%s score=%d", resp.AuditID, ref, req.Complaint, resp.RiskLevel, resp.RiskScore)
-	})
+	}
+}
 
-	addr := ":8080"
-	log.Printf("Clinical AI Assistant backend running on %s", addr)
-	if err := http.ListenAndServe(addr, nil); err != nil {
-		log.Fatalf("server error: %v", err)
+func envString(key, def string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
 	}
+	return def
 }
 
-func addCORS(w http.ResponseWriter) {
-	// Allow same-origin plus simple dev usage.
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", strings.Join([]string{
-		"Content-Type",
-	}, ", "))
+func envBool(key string) bool {
+	v, err := strconv.ParseBool(strings.TrimSpace(os.Getenv(key)))
+	return err == nil && v
 }
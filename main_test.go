@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/server"
+)
+
+func TestEmbeddedStatic(t *testing.T) {
+	h := server.New(server.Config{Static: embeddedStatic})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/app", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "/assets/js/app.js") {
+		t.Fatalf("/app: status %d", rec.Code)
+	}
+	// The default CSP forbids inline script.
+	if strings.Contains(rec.Body.String(), "onclick=") || strings.Contains(rec.Body.String(), "<script>") {
+		t.Fatal("/app has inline script")
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Fatalf("/app Content-Type = %q", ct)
+	}
+	etag := rec.Header().Get("ETag")
+	if etag == "" || rec.Header().Get("Cache-Control") == "" {
+		t.Fatalf("/app headers = %v", rec.Header())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/app", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("/app revalidation: status %d", rec.Code)
+	}
+
+	for path, ct := range map[string]string{"/": "text/html", "/assets/js/app.js": "text/javascript", "/assets/css/app.css": "text/css"} {
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), ct) {
+			t.Errorf("%s: status %d, Content-Type %q", path, rec.Code, rec.Header().Get("Content-Type"))
+		}
+	}
+}
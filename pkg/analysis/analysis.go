@@ -0,0 +1,368 @@
+// Package analysis embeds the clinical rule engine in other Go programs: the
+// deterministic risk scoring, plan building, interaction, allergy, and
+// dose-cap checks behind the server's POST /api/analyze, without the HTTP
+// layer.
+//
+// The surface is deliberately small. An Analyzer analyzes and validates
+// intakes and checks medication lists; it is configured once, through the
+// Option values passed to New. Scoring uses the deterministic stub, and the
+// system prompt, LLM clients, admin operations, and audit queries stay
+// internal to the server. The request and response types are those of the public types
+// package, so JSON produced here matches the HTTP API.
+package analysis
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"time"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+	"github.com/Skufu/Clinical-AI-Assistant/pkg/audit"
+	"github.com/Skufu/Clinical-AI-Assistant/types"
+)
+
+// The request and response types, shared with the HTTP API.
+type (
+	Intake             = types.Intake
+	Medication         = types.Medication
+	Allergy            = types.Allergy
+	PriorTreatment     = types.PriorTreatment
+	Labs               = types.Labs
+	Consent            = types.Consent
+	Response           = types.Response
+	Plan               = types.Plan
+	FollowUp           = types.FollowUp
+	Alternative        = types.Alternative
+	ComplaintPlan      = types.ComplaintPlan
+	Issue              = types.Issue
+	RiskFactor         = types.RiskFactor
+	RuleTrace          = types.RuleTrace
+	ConfidenceFactors  = types.ConfidenceFactors
+	Resource           = types.Resource
+	AssessedMedication = types.AssessedMedication
+	FieldError         = types.FieldError
+	IntakePreview      = types.IntakePreview
+	ValidationReport   = types.ValidationReport
+	TriageReport       = types.TriageReport
+	FollowUpQuestion   = types.FollowUpQuestion
+	Signature          = types.Signature
+	PublicKey          = types.PublicKey
+	PartialResult      = types.PartialResult
+	InteractionRequest = types.InteractionRequest
+	InteractionPair    = types.InteractionPair
+	InteractionReport  = types.InteractionReport
+)
+
+// The normalized intake types; see CallOptions.
+type (
+	NormalizedIntake     = types.NormalizedIntake
+	NormalizedBP         = types.NormalizedBP
+	NormalizedBMI        = types.NormalizedBMI
+	NormalizedCondition  = types.NormalizedCondition
+	NormalizedMedication = types.NormalizedMedication
+	NormalizedComponent  = types.NormalizedComponent
+	NormalizedComplaint  = types.NormalizedComplaint
+)
+
+type (
+	// CallOptions tunes a single call: Debug adds diagnostic fields, Locale
+	// picks the language of issues and rationales, DryRun skips the audit
+	// write, IncludeNormalized adds the normalized intake, and RuleTrace
+	// adds the trace of every risk rule evaluated.
+	CallOptions = analysis.Options
+	// RiskThresholds are the raw-score cut points of the risk tiers.
+	RiskThresholds = analysis.RiskThresholds
+)
+
+// SchemaVersion is stamped on every Response.
+const SchemaVersion = types.SchemaVersion
+
+// Severity is the seriousness of an Issue and RiskLevel the tier of a
+// Response; both marshal as their constant strings.
+type (
+	Severity  = types.Severity
+	RiskLevel = types.RiskLevel
+)
+
+const (
+	SeverityDanger  = types.SeverityDanger
+	SeverityWarning = types.SeverityWarning
+	SeverityInfo    = types.SeverityInfo
+
+	RiskLow      = types.RiskLow
+	RiskMedium   = types.RiskMedium
+	RiskHigh     = types.RiskHigh
+	RiskCritical = types.RiskCritical
+	RiskInvalid  = types.RiskInvalid
+)
+
+// ComplaintRequirements lists the intake fields a complaint requires or
+// recommends; the fields are the Require constants.
+type ComplaintRequirements = analysis.ComplaintRequirements
+
+const (
+	RequireBP          = analysis.RequireBP
+	RequireWeight      = analysis.RequireWeight
+	RequireHeight      = analysis.RequireHeight
+	RequireMedications = analysis.RequireMedications
+	RequireConditions  = analysis.RequireConditions
+)
+
+// DefaultDisclaimers are stamped on every Response unless WithDisclaimers
+// says otherwise.
+var DefaultDisclaimers = analysis.DefaultDisclaimers
+
+// DefaultHistoryConfidenceCeiling caps plan confidence while a history list
+// is left unconfirmed, unless WithHistoryConfidenceCeiling says otherwise.
+const DefaultHistoryConfidenceCeiling = analysis.DefaultHistoryConfidenceCeiling
+
+// DefaultLocale is the language used when a call names none that is loaded.
+const DefaultLocale = analysis.DefaultLocale
+
+// DefaultRiskThresholds are the cut points used unless WithRiskThresholds
+// says otherwise.
+var DefaultRiskThresholds = analysis.DefaultRiskThresholds
+
+// ErrMalformedIntake is returned by CheckIntake for a body that is not JSON.
+var ErrMalformedIntake = analysis.ErrMalformedIntake
+
+// Analyzer is an independently configured rule engine; safe for concurrent
+// use.
+type Analyzer struct {
+	a *analysis.Analyzer
+}
+
+// Option configures New.
+type Option func(*config)
+
+type config struct {
+	opts       []analysis.Option
+	thresholds *RiskThresholds
+	rulesFile  string
+	localeDir  string
+	consent    bool
+	lists      bool
+	// historyCeiling is nil for DefaultHistoryConfidenceCeiling.
+	historyCeiling *float64
+	signingKeyID   string
+	signingKey     ed25519.PrivateKey
+
+	requirements []complaintRequirement
+}
+
+type complaintRequirement struct {
+	complaint string
+	r         ComplaintRequirements
+}
+
+// WithAuditStore records analyses in store. Without it they are kept in an
+// in-memory store that grows with every recorded analysis, so long-running
+// programs should pass a store or analyze with DryRun.
+func WithAuditStore(store audit.Store) Option {
+	return func(c *config) {
+		c.opts = append(c.opts, analysis.WithAuditStore(store))
+	}
+}
+
+// WithRiskThresholds sets the risk tier cut points; New rejects invalid ones.
+func WithRiskThresholds(t RiskThresholds) Option {
+	return func(c *config) {
+		c.thresholds = &t
+	}
+}
+
+// WithRulesFile replaces the embedded interaction rules, dose caps, drug
+// classes, and risk weights with the JSON ruleset at path.
+func WithRulesFile(path string) Option {
+	return func(c *config) {
+		c.rulesFile = path
+	}
+}
+
+// WithLocaleDir merges the <locale>.json message catalogs in dir into the
+// embedded ones, key by key.
+func WithLocaleDir(dir string) Option {
+	return func(c *config) {
+		c.localeDir = dir
+	}
+}
+
+// WithConsentRequired rejects intakes that do not record patient consent.
+func WithConsentRequired(required bool) Option {
+	return func(c *config) {
+		c.consent = required
+	}
+}
+
+// WithListConfirmationRequired rejects intakes whose complaint requires the
+// medication or condition list (ED does) when the list is empty and not
+// confirmed with ConfirmedNoMedications or ConfirmedNoConditions. Without it
+// such an intake gets a warning and an INTAKE_FIELD_MISSING issue.
+func WithListConfirmationRequired(required bool) Option {
+	return func(c *config) {
+		c.lists = required
+	}
+}
+
+// WithHistoryConfidenceCeiling caps plan confidence at ceiling, instead of
+// DefaultHistoryConfidenceCeiling, while the condition, medication, or
+// allergy list is empty without its ConfirmedNo flag. New fails on a ceiling
+// outside (0, 1].
+func WithHistoryConfidenceCeiling(ceiling float64) Option {
+	return func(c *config) {
+		c.historyCeiling = &ceiling
+	}
+}
+
+// WithSigningKey signs each audited response with key and records the
+// signature with its audit; SignResponse then signs responses for sending.
+// An empty keyID derives one from the public key. New fails on a key that is
+// not a 64-byte Ed25519 private key.
+func WithSigningKey(keyID string, key ed25519.PrivateKey) Option {
+	return func(c *config) {
+		c.signingKeyID, c.signingKey = keyID, key
+	}
+}
+
+// WithComplaintRequirements declares the fields a complaint requires or
+// recommends, replacing the built-in entry for ED, hair loss, or weight loss.
+// New fails on a field ComplaintRequirements.Validate rejects.
+func WithComplaintRequirements(complaint string, r ComplaintRequirements) Option {
+	return func(c *config) {
+		c.requirements = append(c.requirements, complaintRequirement{complaint, r})
+	}
+}
+
+// WithDisclaimers replaces the disclaimer and scope-of-use text stamped on
+// every Response; nil keeps DefaultDisclaimers and an empty slice stamps none.
+func WithDisclaimers(d []string) Option {
+	return func(c *config) {
+		c.opts = append(c.opts, analysis.WithDisclaimers(d))
+	}
+}
+
+// WithClock sets the time source for audit timestamps.
+func WithClock(now func() time.Time) Option {
+	return func(c *config) {
+		c.opts = append(c.opts, analysis.WithClock(now))
+	}
+}
+
+// New builds an Analyzer. It fails on invalid thresholds and on a rules file
+// or locale directory that cannot be loaded.
+func New(opts ...Option) (*Analyzer, error) {
+	var c config
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.thresholds != nil {
+		if err := c.thresholds.Validate(); err != nil {
+			return nil, err
+		}
+		c.opts = append(c.opts, analysis.WithRiskThresholds(*c.thresholds))
+	}
+	for _, cr := range c.requirements {
+		if err := cr.r.Validate(); err != nil {
+			return nil, fmt.Errorf("complaint %q: %w", cr.complaint, err)
+		}
+		c.opts = append(c.opts, analysis.WithComplaintRequirements(cr.complaint, cr.r))
+	}
+	a := analysis.New(c.opts...)
+	if c.rulesFile != "" {
+		if err := a.LoadRulesFile(c.rulesFile); err != nil {
+			return nil, err
+		}
+	}
+	if c.localeDir != "" {
+		if err := a.LoadLocaleDir(c.localeDir); err != nil {
+			return nil, err
+		}
+	}
+	a.SetConsentRequired(c.consent)
+	a.SetListConfirmationRequired(c.lists)
+	if c.historyCeiling != nil {
+		if err := a.SetHistoryConfidenceCeiling(*c.historyCeiling); err != nil {
+			return nil, err
+		}
+	}
+	if c.signingKey != nil {
+		if err := a.SetSigningKey(c.signingKeyID, c.signingKey); err != nil {
+			return nil, err
+		}
+	}
+	return &Analyzer{a: a}, nil
+}
+
+// Analyze runs the full pipeline on in and, unless opts.DryRun is set,
+// records it in the audit store. An intake that fails validation returns a
+// Response with RiskLevel RiskInvalid and its ValidationErrors.
+func (a *Analyzer) Analyze(ctx context.Context, in Intake, opts CallOptions) Response {
+	return a.a.AnalyzeContext(ctx, in, opts)
+}
+
+// Validate lists the reasons Analyze would reject in; nil means none.
+func (a *Analyzer) Validate(in Intake) []string {
+	return a.a.Validate(in)
+}
+
+// CheckIntake validates a raw intake body for form feedback without
+// analyzing it: the intake JSON schema, Validate, and plausibility checks,
+// plus a preview of how the engine reads the intake.
+func (a *Analyzer) CheckIntake(raw []byte) (ValidationReport, error) {
+	return a.a.CheckIntake(raw)
+}
+
+// CheckPartialIntake is CheckIntake for an intake still being filled in,
+// adding the provisional risk factors and issues that need no plan.
+func (a *Analyzer) CheckPartialIntake(raw []byte, opts CallOptions) (PartialResult, error) {
+	return a.a.CheckPartialIntake(raw, opts)
+}
+
+// Triage lists the follow-up questions an intake still needs answered before
+// analysis, blocking ones first.
+func (a *Analyzer) Triage(in Intake) TriageReport {
+	return a.a.Triage(in)
+}
+
+// SigningKey returns the public half of the WithSigningKey key, or false
+// when responses are not signed.
+func (a *Analyzer) SigningKey() (PublicKey, bool) {
+	return a.a.SigningKey()
+}
+
+// SignResponse signs the canonical JSON of resp, to be sent alongside it in
+// the types.SignatureHeader and types.SignatureKeyIDHeader headers. It
+// returns nil without WithSigningKey.
+func (a *Analyzer) SignResponse(resp Response) (*Signature, error) {
+	return a.a.SignResponse(resp)
+}
+
+// CheckInteractions checks a bare medication list against the interaction
+// rules and drug classes; nothing is audited.
+func (a *Analyzer) CheckInteractions(req InteractionRequest, opts CallOptions) InteractionReport {
+	return a.a.CheckInteractions(req, opts)
+}
+
+// Locales lists the loaded message catalogs.
+func (a *Analyzer) Locales() []string {
+	return a.a.Locales()
+}
+
+// MatchLocale picks the loaded locale that best fits prefs, most preferred
+// first, falling back to DefaultLocale.
+func (a *Analyzer) MatchLocale(prefs ...string) string {
+	return a.a.MatchLocale(prefs...)
+}
+
+// RulesetVersion is the short hash of the ruleset, system prompt, and build
+// that every Response and audit entry is stamped with.
+func (a *Analyzer) RulesetVersion() string {
+	return a.a.RulesetVersion()
+}
+
+// ValidateResponse checks resp against the response JSON schema and returns
+// the violations, if any.
+func ValidateResponse(resp Response) []string {
+	return analysis.ValidateResponse(resp)
+}
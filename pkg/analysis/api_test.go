@@ -0,0 +1,148 @@
+package analysis
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the public API golden files")
+
+const module = "github.com/Skufu/Clinical-AI-Assistant"
+
+// TestPublicAPI pins the exported surface of the public packages, with the
+// fields and methods that aliases of internal types bring along, so changes
+// to it are intentional. After one, run go test ./pkg/analysis -update and
+// review the golden diff.
+func TestPublicAPI(t *testing.T) {
+	goTool := filepath.Join(runtime.GOROOT(), "bin", "go")
+	if _, err := os.Stat(goTool); err != nil {
+		t.Skipf("go tool unavailable: %v", err)
+	}
+	public := []string{module + "/pkg/analysis", module + "/pkg/audit"}
+	cmd := exec.Command(goTool, append([]string{"list", "-export", "-deps", "-f", "{{.ImportPath}}\t{{.Export}}"}, public...)...)
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("go list: %v", err)
+	}
+	exports := map[string]string{}
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		if pkg, file, ok := strings.Cut(sc.Text(), "\t"); ok && file != "" {
+			exports[pkg] = file
+		}
+	}
+	imp := importer.ForCompiler(token.NewFileSet(), "gc", func(pkg string) (io.ReadCloser, error) {
+		file, ok := exports[pkg]
+		if !ok {
+			return nil, fmt.Errorf("no export data for %s", pkg)
+		}
+		return os.Open(file)
+	})
+
+	for _, pkgPath := range public {
+		pkg, err := imp.Import(pkgPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := describeAPI(pkg)
+		golden := filepath.Join("testdata", "api", path.Base(pkgPath)+".txt")
+		if *update {
+			if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != string(want) {
+			t.Errorf("public API of %s changed; if intended, rerun with -update.\n--- got\n%s", pkgPath, got)
+		}
+	}
+}
+
+// describeAPI lists pkg's exported identifiers one per line, sorted by name.
+// Types that are declared in pkg or aliased from an internal package also
+// list their exported fields and methods; aliases of other public packages
+// are pinned there.
+func describeAPI(pkg *types.Package) string {
+	qual := func(other *types.Package) string {
+		return strings.TrimPrefix(other.Path(), module+"/")
+	}
+	var b strings.Builder
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if !obj.Exported() {
+			continue
+		}
+		switch obj := obj.(type) {
+		case *types.Const:
+			fmt.Fprintf(&b, "const %s %s\n", name, types.TypeString(obj.Type(), qual))
+		case *types.Var:
+			fmt.Fprintf(&b, "var %s %s\n", name, types.TypeString(obj.Type(), qual))
+		case *types.Func:
+			fmt.Fprintf(&b, "func %s%s\n", name, strings.TrimPrefix(types.TypeString(obj.Type(), qual), "func"))
+		case *types.TypeName:
+			named, ok := types.Unalias(obj.Type()).(*types.Named)
+			if !ok {
+				continue
+			}
+			if obj.IsAlias() {
+				fmt.Fprintf(&b, "type %s = %s\n", name, types.TypeString(named, qual))
+				if !strings.Contains(named.Obj().Pkg().Path(), "/internal/") {
+					continue
+				}
+			} else {
+				fmt.Fprintf(&b, "type %s %s\n", name, kind(named.Underlying(), qual))
+			}
+			describeMembers(&b, name, named, qual)
+		}
+	}
+	return b.String()
+}
+
+// kind summarizes an underlying type without its unexported details.
+func kind(t types.Type, qual types.Qualifier) string {
+	switch t.(type) {
+	case *types.Struct:
+		return "struct"
+	case *types.Interface:
+		return "interface"
+	}
+	return types.TypeString(t, qual)
+}
+
+func describeMembers(b *strings.Builder, name string, named *types.Named, qual types.Qualifier) {
+	if st, ok := named.Underlying().(*types.Struct); ok {
+		for i := range st.NumFields() {
+			if f := st.Field(i); f.Exported() {
+				fmt.Fprintf(b, "    field %s.%s %s\n", name, f.Name(), types.TypeString(f.Type(), qual))
+			}
+		}
+	}
+	var recv types.Type = types.NewPointer(named)
+	if types.IsInterface(named) {
+		recv = named
+	}
+	mset := types.NewMethodSet(recv)
+	for i := range mset.Len() {
+		if m := mset.At(i).Obj(); m.Exported() {
+			fmt.Fprintf(b, "    method %s.%s%s\n", name, m.Name(), strings.TrimPrefix(types.TypeString(m.Type(), qual), "func"))
+		}
+	}
+}
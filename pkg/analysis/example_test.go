@@ -0,0 +1,78 @@
+package analysis_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/Skufu/Clinical-AI-Assistant/pkg/analysis"
+	"github.com/Skufu/Clinical-AI-Assistant/pkg/audit"
+)
+
+func Example() {
+	a, err := analysis.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	resp := a.Analyze(context.Background(), analysis.Intake{
+		PatientName: "Juan Dela Cruz",
+		Age:         58,
+		WeightKg:    92,
+		HeightCm:    170,
+		BP:          "150/95",
+		Medications: []analysis.Medication{{Name: "Amlodipine", Dosage: "5mg"}},
+		Complaint:   "ED",
+		// ED plans need the condition list; confirm that it and the allergy
+		// list are empty, or confidence is capped as an incomplete history.
+		ConfirmedNoConditions: true,
+		ConfirmedNoAllergies:  true,
+	}, analysis.CallOptions{DryRun: true})
+
+	fmt.Println(resp.RiskLevel, resp.RiskScore)
+	fmt.Println(resp.RecommendedPlan.Medication, resp.RecommendedPlan.Dosage)
+	for _, is := range resp.FlaggedIssues {
+		fmt.Println(is.Severity, is.Code)
+	}
+	// Output:
+	// MEDIUM 7
+	// Tadalafil 10mg
+	// warning BMI_OBESITY
+	// warning BP_ELEVATED
+	// warning DDI_PDE5_AMLODIPINE
+}
+
+func ExampleWithAuditStore() {
+	store := audit.NewMemoryStore()
+	a, err := analysis.New(analysis.WithAuditStore(store))
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx := context.Background()
+	resp := a.Analyze(ctx, analysis.Intake{PatientName: "Audited", Age: 45, WeightKg: 70, HeightCm: 170, BP: "120/80", Complaint: "Hair loss"}, analysis.CallOptions{})
+
+	latest, err := store.Latest(ctx, 10)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(len(latest), latest[0].AuditID == resp.AuditID, latest[0].Complaint)
+	// Output:
+	// 1 true Hair loss
+}
+
+func ExampleAnalyzer_CheckIntake() {
+	a, err := analysis.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	report, err := a.CheckIntake([]byte(`{"patientName": "Form", "age": 45, "weight": 70, "height": 1.7, "bp": "120/80", "complaint": "ED", "confirmedNoMedications": true, "confirmedNoConditions": true, "confirmedNoAllergies": true}`))
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(report.Valid)
+	for _, w := range report.Warnings {
+		fmt.Println(w.Field, w.Code)
+	}
+	// Output:
+	// true
+	// height implausible
+}
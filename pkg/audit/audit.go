@@ -0,0 +1,98 @@
+// Package audit is the public face of the audit log for programs that embed
+// the analysis engine: the Store interface an embedder implements or picks,
+// the records it is handed, and the two stores the server itself uses.
+//
+// The names are aliases of the server's internal audit package, so a Store
+// from here plugs straight into analysis.WithAuditStore. Capability
+// interfaces the HTTP API needs (clinician decisions, patient history,
+// re-analysis, shadow comparisons) are not part of this surface yet; the
+// stores below implement them, and a custom Store may leave them out.
+package audit
+
+import "github.com/Skufu/Clinical-AI-Assistant/internal/audit"
+
+type (
+	// Store persists audit entries. Methods honor ctx cancellation; Close
+	// releases the underlying resources.
+	Store = audit.Store
+	// Entry is what the engine records for one analysis.
+	Entry = audit.Entry
+	// Summary is the read-friendly view of an Entry that Store.Insert and
+	// Store.Latest return.
+	Summary = audit.Summary
+	// LLMUsage records the cost of a scoring call.
+	LLMUsage = audit.LLMUsage
+	// Consent is the patient consent recorded with an Entry.
+	Consent = audit.Consent
+	// Decision is a clinician's sign-off, as carried by Summary.
+	Decision = audit.Decision
+	// Signature is the response signature recorded with an Entry.
+	Signature = audit.Signature
+	// Reminder is a follow-up reminder kept against an audit.
+	Reminder = audit.Reminder
+	// IssueAck is a clinician's acknowledgment of an issue on an audit.
+	IssueAck = audit.IssueAck
+
+	// MemoryStore keeps entries in memory, for tests and offline use.
+	MemoryStore = audit.MemoryStore
+	// MemoryOption configures NewMemoryStore.
+	MemoryOption = audit.MemoryOption
+	// SQLiteStore keeps entries in a SQLite database, migrating its schema
+	// on open. It is unavailable in js/wasm builds.
+	SQLiteStore = audit.SQLiteStore
+	// SQLiteOption configures NewSQLiteStore.
+	SQLiteOption = audit.SQLiteOption
+	// FieldCipher encrypts patient-identifying columns at rest.
+	FieldCipher = audit.FieldCipher
+)
+
+var (
+	// ErrNotFound is returned for an audit ID the store does not hold.
+	ErrNotFound = audit.ErrNotFound
+	// ErrDecrypt is returned when an encrypted column cannot be decrypted.
+	ErrDecrypt = audit.ErrDecrypt
+	// ErrNoKey is returned when an encrypted column is read without a key.
+	ErrNoKey = audit.ErrNoKey
+)
+
+// DefaultMemoryCapacity is how many entries a MemoryStore keeps unless
+// WithCapacity says otherwise.
+const DefaultMemoryCapacity = audit.DefaultMemoryCapacity
+
+// NewMemoryStore returns an empty MemoryStore that evicts its oldest entries
+// past DefaultMemoryCapacity unless opts say otherwise.
+func NewMemoryStore(opts ...MemoryOption) *MemoryStore {
+	return audit.NewMemoryStore(opts...)
+}
+
+// WithCapacity sets how many entries a MemoryStore keeps; 0 keeps them all.
+func WithCapacity(n int) MemoryOption {
+	return audit.WithCapacity(n)
+}
+
+// WithEviction calls fn with each entry a MemoryStore evicts, oldest first.
+func WithEviction(fn func(Summary)) MemoryOption {
+	return audit.WithEviction(fn)
+}
+
+// NewSQLiteStore opens, and if needed creates and migrates, the database at
+// path.
+func NewSQLiteStore(path string, opts ...SQLiteOption) (*SQLiteStore, error) {
+	return audit.NewSQLiteStore(path, opts...)
+}
+
+// WithCipher encrypts patient_ref, complaint, the stored response and intake,
+// and decision reasons and plans at rest.
+func WithCipher(c *FieldCipher) SQLiteOption {
+	return audit.WithCipher(c)
+}
+
+// NewFieldCipher builds a cipher from a 32-byte key.
+func NewFieldCipher(key []byte) (*FieldCipher, error) {
+	return audit.NewFieldCipher(key)
+}
+
+// ParseKey decodes a 32-byte key given as hex or standard base64.
+func ParseKey(s string) ([]byte, error) {
+	return audit.ParseKey(s)
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"log"
+	"os"
+)
+
+// embeddedStatic is the UI: the landing page, the app page, and its assets.
+//
+//go:embed landing.html app.html assets
+var embeddedStatic embed.FS
+
+// staticFiles serves the UI from STATIC_DIR when set, so pages and assets can
+// be edited without a rebuild, and from the binary otherwise.
+func staticFiles() fs.FS {
+	if dir := envString("STATIC_DIR", ""); dir != "" {
+		if _, err := os.Stat(dir); err != nil {
+			log.Fatalf("invalid STATIC_DIR: %v", err)
+		}
+		log.Printf("serving static files from %s", dir)
+		return os.DirFS(dir)
+	}
+	return embeddedStatic
+}
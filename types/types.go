@@ -0,0 +1,962 @@
+// Package types holds the request and response types of the analysis and audit
+// APIs, shared by the server and the client SDK.
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Intake is the clinician-entered case submitted to POST /api/analyze.
+type Intake struct {
+	PatientName string       `json:"patientName"`
+	Age         int          `json:"age"`
+	WeightKg    float64      `json:"weight"`
+	HeightCm    float64      `json:"height"`
+	BP          string       `json:"bp"`
+	BMI         float64      `json:"bmi"`
+	Conditions  []string     `json:"conditions"`
+	Allergies   []string     `json:"allergies"`
+	Medications []Medication `json:"medications"`
+	Smoking     string       `json:"smoking"`
+	Alcohol     string       `json:"alcohol"`
+	Exercise    string       `json:"exercise"` // none, occasional, or regular
+	Complaint   string       `json:"complaint"`
+	UserID      string       `json:"userId,omitempty"`
+	// Consent records the patient's consent to processing; it is required
+	// when the analyzer is configured to enforce consent.
+	Consent *Consent `json:"consent,omitempty"`
+	// ConditionCodes are ICD-10 codes (e.g. I25.10, N18.3), merged with
+	// Conditions before the rules run.
+	ConditionCodes []string `json:"conditionCodes,omitempty"`
+	// AllergyDetails are allergies with a recorded severity, checked
+	// alongside Allergies; severity scales the risk of a matching plan.
+	AllergyDetails []Allergy `json:"allergyDetails,omitempty"`
+	// Complaints lists further presenting complaints, merged with Complaint;
+	// each recognized complaint gets its own plan.
+	Complaints []string `json:"complaints,omitempty"`
+	// ComplaintSeverity (mild, moderate, or severe) and
+	// ComplaintDurationWeeks describe the primary complaint and adjust its
+	// plan; both are optional.
+	ComplaintSeverity      string `json:"complaintSeverity,omitempty"`
+	ComplaintDurationWeeks int    `json:"complaintDurationWeeks,omitempty"`
+	// PriorTreatments records what the patient already tried; plans skip
+	// medications that were ineffective or not tolerated.
+	PriorTreatments []PriorTreatment `json:"priorTreatments,omitempty"`
+	// ConfirmedNoMedications, ConfirmedNoConditions, and
+	// ConfirmedNoAllergies record that the clinician checked and the lists
+	// are empty, which complaints that require the lists (such as ED) accept
+	// in place of entries. A list left empty without its flag counts as not
+	// provided and caps plan confidence; see HISTORY_INCOMPLETE.
+	ConfirmedNoMedications bool `json:"confirmedNoMedications,omitempty"`
+	ConfirmedNoConditions  bool `json:"confirmedNoConditions,omitempty"`
+	ConfirmedNoAllergies   bool `json:"confirmedNoAllergies,omitempty"`
+	// SmokingPackYears (packs a day times years smoked) and
+	// SmokingQuitYearsAgo qualify Smoking; both are optional. A quit time
+	// marks a former smoker and contradicts a current or never status.
+	SmokingPackYears    float64  `json:"smokingPackYears,omitempty"`
+	SmokingQuitYearsAgo *float64 `json:"smokingQuitYearsAgo,omitempty"`
+	// AlcoholDrinksPerWeek is the weekly count of standard drinks; when set
+	// it decides over Alcohol whether drinking is heavy (more than 14).
+	AlcoholDrinksPerWeek *float64 `json:"alcoholDrinksPerWeek,omitempty"`
+	// SleepHours is the usual nightly sleep; optional.
+	SleepHours float64 `json:"sleepHours,omitempty"`
+	// FamilyHistory lists conditions in the patient's relatives, such as
+	// premature CAD or prostate cancer, normalized like Conditions.
+	FamilyHistory []string `json:"familyHistory,omitempty"`
+	// WaistCircumferenceCm and Labs feed the metabolic syndrome criteria;
+	// both are optional.
+	WaistCircumferenceCm float64 `json:"waistCircumferenceCm,omitempty"`
+	Labs                 *Labs   `json:"labs,omitempty"`
+}
+
+// Labs are recent laboratory results. Zero means not measured.
+type Labs struct {
+	TriglyceridesMgDl float64 `json:"triglyceridesMgDl,omitempty"`
+	HDLMgDl           float64 `json:"hdlMgDl,omitempty"`
+	A1cPercent        float64 `json:"a1cPercent,omitempty"`
+}
+
+// PriorTreatment is a medication the patient tried before. Outcome is
+// effective, ineffective, or intolerant; MaxDose is the highest dose tried.
+type PriorTreatment struct {
+	Medication string `json:"medication"`
+	MaxDose    string `json:"maxDose,omitempty"`
+	Outcome    string `json:"outcome"`
+	Notes      string `json:"notes,omitempty"`
+}
+
+// Allergy is an allergy with its reaction severity: anaphylaxis, severe,
+// moderate, mild, or intolerance. An empty Severity means it is not recorded.
+type Allergy struct {
+	Substance string `json:"substance"`
+	Severity  string `json:"severity,omitempty"`
+}
+
+// Validation codes prefixed ("CODE: message") to consent validation errors so
+// callers can tell them apart from other failures.
+const (
+	// ConsentRequired marks an intake with no consent or with given=false.
+	ConsentRequired = "CONSENT_REQUIRED"
+	// ConsentInvalid marks consent that was given but not documented.
+	ConsentInvalid = "CONSENT_INVALID"
+)
+
+// Consent documents how and when a patient agreed to have their health data
+// processed. Timestamp is RFC3339; Method is how consent was obtained, e.g.
+// "verbal", "written", or "electronic".
+type Consent struct {
+	Given     bool   `json:"given"`
+	Timestamp string `json:"timestamp"`
+	Method    string `json:"method"`
+}
+
+// Medication is a current medication listed on the intake.
+type Medication struct {
+	Name      string `json:"name"`
+	Dosage    string `json:"dosage"`
+	Frequency string `json:"frequency"`
+}
+
+// Severity is how serious an Issue is. The wire values are the lower-case
+// constant strings.
+type Severity string
+
+const (
+	SeverityDanger  Severity = "danger"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+// Severities lists the valid severities, most serious first.
+var Severities = []Severity{SeverityDanger, SeverityWarning, SeverityInfo}
+
+// Valid reports whether s is one of Severities.
+func (s Severity) Valid() bool {
+	return s == SeverityDanger || s == SeverityWarning || s == SeverityInfo
+}
+
+// ParseSeverity returns the Severity named by s, ignoring case and
+// surrounding space.
+func ParseSeverity(s string) (Severity, error) {
+	if v := Severity(strings.ToLower(strings.TrimSpace(s))); v.Valid() {
+		return v, nil
+	}
+	return "", fmt.Errorf("severity must be danger, warning, or info, not %q", s)
+}
+
+// RiskLevel is the risk tier of an analysis. INVALID marks an intake that
+// failed validation and was not scored.
+type RiskLevel string
+
+const (
+	RiskLow      RiskLevel = "LOW"
+	RiskMedium   RiskLevel = "MEDIUM"
+	RiskHigh     RiskLevel = "HIGH"
+	RiskCritical RiskLevel = "CRITICAL"
+	RiskInvalid  RiskLevel = "INVALID"
+)
+
+// RiskLevels lists the valid risk levels, lowest tier first.
+var RiskLevels = []RiskLevel{RiskLow, RiskMedium, RiskHigh, RiskCritical, RiskInvalid}
+
+// Valid reports whether l is one of RiskLevels.
+func (l RiskLevel) Valid() bool {
+	switch l {
+	case RiskLow, RiskMedium, RiskHigh, RiskCritical, RiskInvalid:
+		return true
+	}
+	return false
+}
+
+// ParseRiskLevel returns the RiskLevel named by s, ignoring case and
+// surrounding space.
+func ParseRiskLevel(s string) (RiskLevel, error) {
+	if v := RiskLevel(strings.ToUpper(strings.TrimSpace(s))); v.Valid() {
+		return v, nil
+	}
+	return "", fmt.Errorf("risk level must be LOW, MEDIUM, HIGH, CRITICAL, or INVALID, not %q", s)
+}
+
+// Issue is a rule finding flagged during analysis.
+type Issue struct {
+	Code               string   `json:"code"`
+	Type               string   `json:"type"`
+	Severity           Severity `json:"severity"`
+	Description        string   `json:"description"`
+	Reference          string   `json:"reference,omitempty"`
+	RelatedMedications []string `json:"relatedMedications,omitempty"`
+	// Acknowledged marks a warning a clinician acknowledged on an earlier
+	// analysis of the patient, with the same related medications;
+	// Acknowledgment is that acknowledgment. The issue still counts toward
+	// the risk score.
+	Acknowledged   bool                 `json:"acknowledged,omitempty"`
+	Acknowledgment *IssueAcknowledgment `json:"acknowledgment,omitempty"`
+}
+
+// Plan is the recommended treatment.
+type Plan struct {
+	Medication string `json:"medication"`
+	Dosage     string `json:"dosage"`
+	Frequency  string `json:"frequency"`
+	Duration   string `json:"duration"`
+	Rationale  string `json:"rationale"`
+	// Monitoring lists checks to schedule while on the plan, and FollowUp
+	// when to review it, so they can be tracked as tasks.
+	Monitoring []string  `json:"monitoring,omitempty"`
+	FollowUp   *FollowUp `json:"followUp,omitempty"`
+	// Review marks a plan that reviews a medication the patient already
+	// takes rather than starting one.
+	Review bool `json:"review,omitempty"`
+}
+
+// FollowUp is the review visit a plan calls for, IntervalDays after it starts.
+type FollowUp struct {
+	IntervalDays int    `json:"intervalDays"`
+	Instructions string `json:"instructions"`
+}
+
+// Alternative is another treatment option with its trade-offs. Confidence is
+// its suitability for the patient, capped by the scorer's confidence;
+// responses list alternatives by suitability, highest first.
+type Alternative struct {
+	Medication string   `json:"medication"`
+	Dosage     string   `json:"dosage"`
+	Pros       []string `json:"pros"`
+	Cons       []string `json:"cons"`
+	Confidence float64  `json:"confidence,omitempty"`
+
+	// Suitability explains the alternative's score: the findings that lowered
+	// it, or a note that none applied.
+	Suitability string `json:"suitability,omitempty"`
+}
+
+// SchemaVersion is the Response format version. The minor number grows when
+// fields are added; the major number changes only when an existing field is
+// removed or changes type or meaning.
+const SchemaVersion = "1.11"
+
+// Response is the analysis result. ValidationErrors is set when the intake
+// was rejected.
+type Response struct {
+	SchemaVersion       string             `json:"schemaVersion"`
+	RiskLevel           RiskLevel          `json:"riskLevel"`
+	RiskScore           int                `json:"riskScore"`
+	RiskScoreNormalized int                `json:"riskScoreNormalized"`
+	RiskFactors         []RiskFactor       `json:"riskFactors,omitempty"`
+	FlaggedIssues       []Issue            `json:"flaggedIssues"`
+	RecommendedPlan     Plan               `json:"recommendedPlan"`
+	PlanConfidence      float64            `json:"planConfidence,omitempty"`
+	Alternatives        []Alternative      `json:"alternatives"`
+	ComputedBMI         float64            `json:"computedBmi"`
+	ProvidedBMI         float64            `json:"providedBmi,omitempty"`
+	Conditions          []string           `json:"conditions,omitempty"`
+	ConfidenceFactors   *ConfidenceFactors `json:"confidenceFactors,omitempty"`
+	LLMCacheHit         bool               `json:"llmCacheHit,omitempty"`
+	PromptVersion       string             `json:"promptVersion,omitempty"`
+	RulesetVersion      string             `json:"rulesetVersion,omitempty"`
+	ValidationErrors    []string           `json:"validationErrors,omitempty"`
+	AuditID             string             `json:"auditId,omitempty"`
+	AuditAt             string             `json:"auditAt,omitempty"`
+	// AuditRecorded is false when the audit write failed, so the analysis
+	// has no AuditID; true once written, and unset for dry runs and rejected
+	// intakes.
+	AuditRecorded *bool `json:"auditRecorded,omitempty"`
+	// DryRun marks an analysis that was not written to the audit log.
+	DryRun            bool       `json:"dryRun,omitempty"`
+	PreviousRiskScore *int       `json:"previousRiskScore,omitempty"`
+	RiskTrend         *RiskTrend `json:"riskTrend,omitempty"`
+	// Decisions lists the clinician decisions on this analysis, oldest first.
+	// It is set only on responses read back from the audit log.
+	Decisions []Decision `json:"decisions,omitempty"`
+	// LocalTime is AuditAt in the zone the reader asked for, set only on
+	// responses read back from the audit log with ?tz=.
+	LocalTime string `json:"localTime,omitempty"`
+	// Cached marks a response served from the analyzer's result cache for an
+	// intake analyzed before; its audit fields are still its own.
+	Cached bool `json:"cached,omitempty"`
+	// UnmappedConditionCodes echoes the intake's ICD-10 codes that map to no
+	// condition the rules read.
+	UnmappedConditionCodes []string `json:"unmappedConditionCodes,omitempty"`
+	// FamilyHistory lists the canonical family history entries the rules
+	// read, sorted.
+	FamilyHistory []string `json:"familyHistory,omitempty"`
+	// Education lists patient education material matched to the complaint,
+	// plan, and flagged issues, in the response locale where available.
+	Education []Resource `json:"education,omitempty"`
+	// MedicationsAssessed echoes the intake medications in order, each with
+	// how the rules read it and the codes of the flagged issues involving it.
+	MedicationsAssessed []AssessedMedication `json:"medicationsAssessed,omitempty"`
+	// Plans holds a plan per recognized complaint, highest priority first;
+	// the first is also RecommendedPlan and Alternatives.
+	Plans []ComplaintPlan `json:"plans,omitempty"`
+	// ComplaintSeverity and ComplaintDurationWeeks echo the intake values
+	// the primary plan was built with.
+	ComplaintSeverity      string `json:"complaintSeverity,omitempty"`
+	ComplaintDurationWeeks int    `json:"complaintDurationWeeks,omitempty"`
+	// Timings holds per-stage durations in milliseconds plus their total; it
+	// is set only for debug requests.
+	Timings map[string]float64 `json:"timings,omitempty"`
+	// RuleTrace lists every risk rule evaluated, in evaluation order; it is
+	// set only when requested and is never written to the audit log.
+	RuleTrace []RuleTrace `json:"ruleTrace,omitempty"`
+	// Disclaimers is the deployment's disclaimer and scope-of-use text, to be
+	// shown with the response wherever it is displayed or exported.
+	Disclaimers []string `json:"disclaimers,omitempty"`
+	// NormalizedIntake shows how the rules read the intake; it is set only
+	// when requested and is never written to the audit log.
+	NormalizedIntake *NormalizedIntake `json:"normalizedIntake,omitempty"`
+}
+
+// AssessedMedication is one intake medication as the rules read it. Generic
+// names the generic, or a combination product's components joined by " + ";
+// Class lists their drug classes, comma-separated. Recognized is false for a
+// drug no class, combination, ruleset entry, or dose cap knows. Flags holds
+// the codes of the flagged issues that involve the drug, in issue order, e.g.
+// DDI_PDE5_AMLODIPINE for one that interacts with the plan.
+type AssessedMedication struct {
+	Input        Medication `json:"input"`
+	Generic      string     `json:"generic"`
+	Class        string     `json:"class,omitempty"`
+	ParsedDoseMg float64    `json:"parsedDoseMg,omitempty"`
+	Recognized   bool       `json:"recognized"`
+	Flags        []string   `json:"flags"`
+}
+
+// NormalizedIntake pairs each intake value the rules read with the form they
+// read it in. It never carries the patient's name.
+type NormalizedIntake struct {
+	BloodPressure NormalizedBP           `json:"bloodPressure"`
+	BMI           NormalizedBMI          `json:"bmi"`
+	Conditions    []NormalizedCondition  `json:"conditions"`
+	Medications   []NormalizedMedication `json:"medications"`
+	Complaints    []NormalizedComplaint  `json:"complaints"`
+}
+
+// NormalizedBP is the intake's blood pressure reading and its parsed values.
+type NormalizedBP struct {
+	Raw       string `json:"raw"`
+	Systolic  int    `json:"systolic"`
+	Diastolic int    `json:"diastolic"`
+}
+
+// NormalizedBMI is the BMI computed from weight and height, which the rules
+// use, beside the intake values it came from.
+type NormalizedBMI struct {
+	WeightKg float64 `json:"weightKg"`
+	HeightCm float64 `json:"heightCm"`
+	Provided float64 `json:"provided,omitempty"`
+	Computed float64 `json:"computed"`
+}
+
+// NormalizedCondition is a condition entry or ICD-10 code and the canonical
+// conditions it maps to; Canonical is empty for an unmapped entry. Source is
+// "conditions" or "conditionCodes".
+type NormalizedCondition struct {
+	Raw       string   `json:"raw"`
+	Source    string   `json:"source"`
+	Canonical []string `json:"canonical"`
+}
+
+// NormalizedMedication is an intake medication and the name, dose, and
+// frequency the rules match on. DoseMg is omitted when no milligram amount
+// is found in the dosage; Classes lists the drug classes the name belongs to.
+// Components lists the generics of a combination product, which the rules
+// check in its place.
+type NormalizedMedication struct {
+	Raw        Medication            `json:"raw"`
+	Name       string                `json:"name"`
+	DoseMg     float64               `json:"doseMg,omitempty"`
+	Frequency  string                `json:"frequency"`
+	Classes    []string              `json:"classes"`
+	Components []NormalizedComponent `json:"components,omitempty"`
+}
+
+// NormalizedComponent is one generic of a combination product, with its
+// dose when the entry gives one per component.
+type NormalizedComponent struct {
+	Name    string   `json:"name"`
+	DoseMg  float64  `json:"doseMg,omitempty"`
+	Classes []string `json:"classes"`
+}
+
+// NormalizedComplaint is a presenting complaint; Recognized reports whether
+// it has a dedicated plan rather than the general wellness plan.
+type NormalizedComplaint struct {
+	Raw        string `json:"raw"`
+	Complaint  string `json:"complaint"`
+	Recognized bool   `json:"recognized"`
+}
+
+// ComplaintPlan is the plan for one presenting complaint.
+type ComplaintPlan struct {
+	Complaint    string        `json:"complaint"`
+	Plan         Plan          `json:"plan"`
+	Alternatives []Alternative `json:"alternatives"`
+}
+
+// Resource is a patient education link. Language is the locale of the
+// material, which may differ from the response locale when no translation is
+// catalogued.
+type Resource struct {
+	Title    string `json:"title"`
+	URL      string `json:"url"`
+	Language string `json:"language"`
+}
+
+// RiskFactor records a single contribution to the overall risk score.
+type RiskFactor struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+	Points      int    `json:"points"`
+}
+
+// RuleTrace is one risk rule as the engine evaluated it. Rule is the risk
+// factor code (or interaction rule code, lowercased) and Inputs the
+// normalized values it read, never the patient's name or free text. Points
+// is what a match added to the risk score: a rule sharing a group or an
+// issue with an earlier match adds only what raises it, so the points of a
+// trace sum to RiskScore. Issue is the code of the issue a match flags, if
+// any.
+type RuleTrace struct {
+	Rule    string         `json:"rule"`
+	Inputs  map[string]any `json:"inputs,omitempty"`
+	Matched bool           `json:"matched"`
+	Points  int            `json:"points"`
+	Issue   string         `json:"issue,omitempty"`
+}
+
+// ConfidenceFactors exposes the inputs of the plan confidence formula for debugging.
+type ConfidenceFactors struct {
+	Coverage            float64 `json:"coverage"`
+	Base                float64 `json:"base"`
+	RiskPenalty         float64 `json:"riskPenalty"`
+	IssuePenalty        float64 `json:"issuePenalty"`
+	SubstitutionPenalty float64 `json:"substitutionPenalty"`
+	Floor               float64 `json:"floor"`
+	Ceiling             float64 `json:"ceiling"`
+	DangerIssues        int     `json:"dangerIssues"`
+	WarningIssues       int     `json:"warningIssues"`
+	InfoIssues          int     `json:"infoIssues"`
+	// FactorCap is Base without the coverage of lists that name entries,
+	// which caps it so adding one never raises confidence.
+	FactorCap float64 `json:"factorCap,omitempty"`
+	// UnconfirmedSections are the history sections left empty without
+	// confirmation, which cap plan confidence at HistoryCeiling.
+	UnconfirmedSections []string `json:"unconfirmedSections,omitempty"`
+	HistoryCeiling      float64  `json:"historyCeiling,omitempty"`
+}
+
+// AuditSummary is one entry of GET /api/audit.
+type AuditSummary struct {
+	AuditID    string    `json:"auditId"`
+	PatientRef string    `json:"patientRef"`
+	Complaint  string    `json:"complaint"`
+	RiskLevel  RiskLevel `json:"riskLevel"`
+	RiskScore  int       `json:"riskScore"`
+	At         string    `json:"at"`
+	// LocalTime is At in the zone the request named with ?tz=, UTC by
+	// default, for display; At stays UTC.
+	LocalTime     string `json:"localTime,omitempty"`
+	PromptVersion string `json:"promptVersion,omitempty"`
+	// RulesetVersion identifies the rules, prompt, and build behind the
+	// analysis; see GET /api/audit?rulesetVersion=.
+	RulesetVersion string `json:"rulesetVersion,omitempty"`
+	// Consent is the consent recorded with the intake, if any.
+	Consent *Consent `json:"consent,omitempty"`
+	// Decision is the current clinician decision, if one was recorded.
+	Decision *Decision `json:"decision,omitempty"`
+	// DurationMs is how long the analysis took before its audit write and
+	// LLMDurationMs the part spent scoring; absent for untimed audits.
+	DurationMs    float64 `json:"durationMs,omitempty"`
+	LLMDurationMs float64 `json:"llmDurationMs,omitempty"`
+	// Signature signs the response as it was stored with the audit; absent
+	// when response signing was off.
+	Signature *Signature `json:"signature,omitempty"`
+	// Type is AuditTypeValidationFailed for an analysis attempt rejected by
+	// intake validation, listed only with includeInvalid=true, and empty for
+	// an analysis.
+	Type string `json:"type,omitempty"`
+	// Errors are the field and code of each validation error of a rejected
+	// attempt, without the messages or values.
+	Errors []FieldCode `json:"errors,omitempty"`
+	// DeletedAt is when the audit was soft-deleted; deleted audits are
+	// listed only with includeDeleted=true.
+	DeletedAt string `json:"deletedAt,omitempty"`
+}
+
+// AuditTypeValidationFailed is the AuditSummary type of a rejected analysis
+// attempt.
+const AuditTypeValidationFailed = "validation_failed"
+
+// The headers carrying a signed response's Signature.Value and
+// Signature.KeyID.
+const (
+	SignatureHeader      = "X-Signature"
+	SignatureKeyIDHeader = "X-Signature-Key-Id"
+)
+
+// Signature is a detached Ed25519 signature over a response's canonical
+// JSON, sent in SignatureHeader and SignatureKeyIDHeader.
+type Signature struct {
+	KeyID     string `json:"keyId"`
+	Algorithm string `json:"algorithm"`
+	// Value is the signature in standard base64.
+	Value string `json:"value"`
+}
+
+// PublicKey is the response signing key as GET /api/keys/public serves it.
+type PublicKey struct {
+	KeyID     string `json:"keyId"`
+	Algorithm string `json:"algorithm"`
+	// PublicKey is the 32-byte Ed25519 public key in standard base64.
+	PublicKey string `json:"publicKey"`
+}
+
+// FieldCode names a failed intake field and its FieldError code.
+type FieldCode struct {
+	Field string `json:"field"`
+	Code  string `json:"code"`
+}
+
+// RiskTrend compares a risk score with the patient's previous analysis.
+type RiskTrend struct {
+	Delta     int    `json:"delta"`
+	Direction string `json:"direction"` // up | down | flat
+	Arrow     string `json:"arrow"`     // ↑ | ↓ | →
+}
+
+// PatientAnalysis is one entry of GET /api/patients/{patientRef}/analyses.
+// Trend is nil for the oldest analysis listed.
+type PatientAnalysis struct {
+	AuditSummary
+	Trend *RiskTrend `json:"trend,omitempty"`
+}
+
+// PatientHistory is the body of GET /api/patients/{patientRef}/analyses,
+// oldest analysis first. Trend compares the last two analyses.
+type PatientHistory struct {
+	PatientRef string            `json:"patientRef"`
+	Analyses   []PatientAnalysis `json:"analyses"`
+	Trend      *RiskTrend        `json:"trend,omitempty"`
+}
+
+// PatchOp is one change in a what-if request, modeled on JSON Patch. Path is
+// a JSON Pointer into the intake: "/bp" for a field, "/medications/1" for a
+// list element, or "/medications/-" to append. Remove on a list path without
+// an index drops the elements matching Value (a name, case-insensitive).
+type PatchOp struct {
+	Op    string `json:"op"` // add | remove | replace
+	Path  string `json:"path"`
+	Value any    `json:"value,omitempty"`
+}
+
+// WhatIfRequest is the body of POST /api/analyze/whatif.
+type WhatIfRequest struct {
+	AuditID string    `json:"auditId"`
+	Patch   []PatchOp `json:"patch"`
+	// Record writes an audit entry for the hypothetical analysis; by default
+	// it is a dry run.
+	Record bool `json:"record,omitempty"`
+}
+
+// AnalysisDiff reports how one analysis differs from an earlier one. Issues
+// match by code and related medications; one in both whose severity moved is
+// in IssuesChanged, while reworded text alone is not a change. PlanBefore and
+// PlanAfter are set only when PlanChanged, which likewise ignores the
+// rationale. BMI is set when both analyses computed one, and BP when both
+// intakes are known and carry a reading.
+type AnalysisDiff struct {
+	RiskScoreBefore int           `json:"riskScoreBefore"`
+	RiskScoreAfter  int           `json:"riskScoreAfter"`
+	RiskScoreDelta  int           `json:"riskScoreDelta"`
+	RiskLevelBefore RiskLevel     `json:"riskLevelBefore"`
+	RiskLevelAfter  RiskLevel     `json:"riskLevelAfter"`
+	IssuesAdded     []Issue       `json:"issuesAdded"`
+	IssuesRemoved   []Issue       `json:"issuesRemoved"`
+	IssuesChanged   []IssueChange `json:"issuesChanged"`
+	PlanChanged     bool          `json:"planChanged"`
+	PlanBefore      *Plan         `json:"planBefore,omitempty"`
+	PlanAfter       *Plan         `json:"planAfter,omitempty"`
+	BMI             *BMIDelta     `json:"bmi,omitempty"`
+	BP              *BPDelta      `json:"bp,omitempty"`
+}
+
+// IssueChange is an issue flagged by both analyses at different severities.
+type IssueChange struct {
+	Before Issue `json:"before"`
+	After  Issue `json:"after"`
+}
+
+// BMIDelta compares two BMIs, rounded to one decimal.
+type BMIDelta struct {
+	Before float64 `json:"before"`
+	After  float64 `json:"after"`
+	Delta  float64 `json:"delta"`
+}
+
+// BPDelta compares two blood pressure readings in mmHg.
+type BPDelta struct {
+	Before         string `json:"before"`
+	After          string `json:"after"`
+	SystolicDelta  int    `json:"systolicDelta"`
+	DiastolicDelta int    `json:"diastolicDelta"`
+}
+
+// WhatIfDiff summarizes how the patch changed the analysis.
+type WhatIfDiff = AnalysisDiff
+
+// WhatIfResponse is the result of POST /api/analyze/whatif. Original is the
+// base intake re-analyzed under the current rules, so Diff reflects only the
+// patch.
+type WhatIfResponse struct {
+	BaseAuditID  string     `json:"baseAuditId"`
+	Original     Response   `json:"original"`
+	Hypothetical Response   `json:"hypothetical"`
+	Diff         WhatIfDiff `json:"diff"`
+}
+
+// ReanalysisDiff compares a stored analysis with a replay of its intake.
+type ReanalysisDiff = AnalysisDiff
+
+// Reanalysis is the result of replaying an audited intake under the current
+// rules. The original audit is never modified; ID names the re-analysis
+// record kept against AuditID, empty when the store keeps none. In the bulk
+// stream, Error reports an audit that could not be replayed.
+type Reanalysis struct {
+	ID                   string          `json:"id,omitempty"`
+	AuditID              string          `json:"auditId"`
+	At                   string          `json:"at,omitempty"`
+	RulesetVersionBefore string          `json:"rulesetVersionBefore,omitempty"`
+	RulesetVersionAfter  string          `json:"rulesetVersionAfter,omitempty"`
+	Changed              bool            `json:"changed"`
+	Diff                 *ReanalysisDiff `json:"diff,omitempty"`
+	Response             *Response       `json:"response,omitempty"`
+	Error                string          `json:"error,omitempty"`
+}
+
+// StoredIntake is the intake kept with an audit. PatientName is cleared
+// before it is serialized and the pseudonymous PatientRef stands in for it,
+// so the store learns nothing the audit row did not already hold.
+type StoredIntake struct {
+	Intake
+	PatientRef string `json:"patientRef"`
+}
+
+// ComparedAudit is one side of an audit comparison. PatientRef is empty when
+// the store cannot tell whose audit it is.
+type ComparedAudit struct {
+	AuditID    string `json:"auditId"`
+	At         string `json:"at,omitempty"`
+	PatientRef string `json:"patientRef,omitempty"`
+}
+
+// AuditComparison is the body of GET /api/audit/compare: how B differs from
+// A, usually the earlier visit.
+type AuditComparison struct {
+	A           ComparedAudit `json:"a"`
+	B           ComparedAudit `json:"b"`
+	SamePatient bool          `json:"samePatient"`
+	Diff        AnalysisDiff  `json:"diff"`
+}
+
+// AuditDetail is the body of GET /api/admin/audit/{id}: the stored response
+// and, when one was kept, the redacted intake it was computed from.
+type AuditDetail struct {
+	Response Response      `json:"response"`
+	Intake   *StoredIntake `json:"intake,omitempty"`
+	// Deletions is the soft deletion trail of the audit, oldest first.
+	Deletions []AuditDeletion `json:"deletions,omitempty"`
+}
+
+// AuditDeletion is one soft deletion or restore of an audit, the body of
+// POST /api/admin/audit/{id}/delete and /restore.
+type AuditDeletion struct {
+	AuditID string `json:"auditId"`
+	Seq     int    `json:"seq"`
+	Action  string `json:"action"` // deleted | restored
+	Reason  string `json:"reason,omitempty"`
+	UserID  string `json:"userId,omitempty"`
+	At      string `json:"at"`
+}
+
+// DeleteRequest is the body of POST /api/admin/audit/{id}/delete. Reason is
+// required.
+type DeleteRequest struct {
+	Reason string `json:"reason"`
+	UserID string `json:"userId,omitempty"`
+}
+
+// IssueAcknowledgment is a clinician's review of a warning flagged on an
+// audited analysis, the body of POST /api/audit/{auditId}/issues/{code}/ack.
+type IssueAcknowledgment struct {
+	AuditID            string   `json:"auditId"`
+	IssueCode          string   `json:"issueCode"`
+	RelatedMedications []string `json:"relatedMedications,omitempty"`
+	UserID             string   `json:"userId,omitempty"`
+	Reason             string   `json:"reason"`
+	At                 string   `json:"at"`
+}
+
+// IssueAckRequest is the body of POST /api/audit/{auditId}/issues/{code}/ack.
+// Reason is required.
+type IssueAckRequest struct {
+	UserID string `json:"userId,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// Reminder is a follow-up visit scheduled from the plan of an audited
+// analysis, listed by GET /api/reminders. It names the patient by the
+// pseudonymous reference the audit was recorded under.
+type Reminder struct {
+	ID           string `json:"id"`
+	AuditID      string `json:"auditId"`
+	PatientRef   string `json:"patientRef,omitempty"`
+	Complaint    string `json:"complaint,omitempty"`
+	Instructions string `json:"instructions,omitempty"`
+	DueAt        string `json:"dueAt"`
+	Status       string `json:"status"` // pending | overdue | completed
+	CreatedAt    string `json:"createdAt,omitempty"`
+	CompletedAt  string `json:"completedAt,omitempty"`
+	CompletedBy  string `json:"completedBy,omitempty"`
+}
+
+// CompleteReminderRequest is the body of POST /api/reminders/{id}/complete.
+type CompleteReminderRequest struct {
+	UserID string `json:"userId,omitempty"`
+}
+
+// LatencyStats is the body of GET /api/audit/latency-stats: analysis
+// latency over the rolling window since the server started, overall and per
+// complaint pathway and risk level.
+type LatencyStats struct {
+	WindowSeconds int `json:"windowSeconds"`
+	// SLO is the latency objective the windows are judged against; absent
+	// when none is set.
+	SLO     *LatencySLO     `json:"slo,omitempty"`
+	Windows []LatencyWindow `json:"windows"`
+}
+
+// LatencySLO is a latency objective: the Percentile-th percentile stays at
+// or under ThresholdMs in every window.
+type LatencySLO struct {
+	Percentile     float64 `json:"percentile"`
+	ThresholdMs    float64 `json:"thresholdMs"`
+	SustainSeconds float64 `json:"sustainSeconds"`
+	MinSamples     int     `json:"minSamples"`
+}
+
+// LatencyWindow summarizes one rolling window. Key is the complaint pathway
+// (ed, weight loss, hair loss, or other) or risk level, empty for scope all.
+type LatencyWindow struct {
+	Scope string  `json:"scope"` // all | complaint | risk
+	Key   string  `json:"key,omitempty"`
+	Count int     `json:"count"`
+	P50Ms float64 `json:"p50Ms"`
+	P95Ms float64 `json:"p95Ms"`
+	P99Ms float64 `json:"p99Ms"`
+	MaxMs float64 `json:"maxMs"`
+	// Breaching is whether the last SLO check found the window over the
+	// objective, and BreachingSince when checks first did.
+	Breaching      bool   `json:"breaching,omitempty"`
+	BreachingSince string `json:"breachingSince,omitempty"`
+}
+
+// InteractionRequest is the body of POST /api/interactions: a medication
+// list checked on its own, without a patient.
+type InteractionRequest struct {
+	Medications []Medication `json:"medications"`
+}
+
+// InteractionPair holds the findings involving one pair of drugs, named as
+// in InteractionReport.Medications. Single-drug findings such as dose caps
+// have one entry in Drugs.
+type InteractionPair struct {
+	Drugs  []string `json:"drugs"`
+	Issues []Issue  `json:"issues"`
+}
+
+// InteractionReport is the result of POST /api/interactions. Medications
+// lists the normalized drug names, sorted, so a client can lay out a matrix
+// and look each pair up in Pairs.
+type InteractionReport struct {
+	Medications      []string          `json:"medications"`
+	Pairs            []InteractionPair `json:"pairs"`
+	ValidationErrors []string          `json:"validationErrors,omitempty"`
+}
+
+// FieldError is one problem with an intake field. Field is a dotted path
+// such as "age" or "medications.0.name"; Code is a stable identifier such as
+// "required", a JSON-schema error type, or ConsentRequired.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Complaint names the complaint that requires or recommends the field,
+	// for errors with code required or recommended that depend on it.
+	Complaint string `json:"complaint,omitempty"`
+}
+
+// IntakePreview shows how the engine will read an intake: the parsed blood
+// pressure, the BMI computed from weight and height, and the normalized
+// medication names, in input order.
+type IntakePreview struct {
+	Systolic    int      `json:"systolic"`
+	Diastolic   int      `json:"diastolic"`
+	ComputedBMI float64  `json:"computedBmi"`
+	Medications []string `json:"medications"`
+}
+
+// ValidationReport is the result of POST /api/validate. Warnings flag values
+// that are accepted but implausible; Preview is omitted when the body does
+// not match the intake schema.
+type ValidationReport struct {
+	Valid    bool           `json:"valid"`
+	Errors   []FieldError   `json:"errors"`
+	Warnings []FieldError   `json:"warnings"`
+	Preview  *IntakePreview `json:"preview,omitempty"`
+}
+
+// TriageReport is the result of POST /api/triage: what to ask next about a
+// partial intake, most urgent first.
+type TriageReport struct {
+	Questions []FollowUpQuestion `json:"questions"`
+}
+
+// FollowUpQuestion is one thing to ask the patient. Field is the intake field
+// the answer fills, such as "bp" or "medications.frequency". Blocking
+// questions must be answered before the intake can be analyzed safely: a
+// field validation requires, or a finding whose rules cannot be evaluated
+// without the answer. Source says where the question came from:
+// "requirement" for the complaint's required and recommended fields,
+// "red_flag" for screening prompted by a finding, "rule" for a rule's
+// prerequisite, and "history" for a list left unconfirmed.
+type FollowUpQuestion struct {
+	ID       string `json:"id"`
+	Field    string `json:"field"`
+	Question string `json:"question"`
+	Reason   string `json:"reason"`
+	Blocking bool   `json:"blocking"`
+	Source   string `json:"source"`
+}
+
+// PartialResult is the incremental result for an intake still being filled
+// in on the /api/analyze/ws socket: the ValidationReport plus the risk factors
+// and issues that need no plan. RiskScore and RiskLevel are provisional;
+// plan-dependent rules can still raise them.
+type PartialResult struct {
+	ValidationReport
+	ComputedBMI   float64      `json:"computedBmi"`
+	RiskScore     int          `json:"riskScore"`
+	RiskLevel     RiskLevel    `json:"riskLevel"`
+	RiskFactors   []RiskFactor `json:"riskFactors"`
+	FlaggedIssues []Issue      `json:"flaggedIssues"`
+}
+
+// LiveMessage is a client message on the /api/analyze/ws socket. Type
+// "intake" sends a partial intake snapshot; "submit" analyzes Intake, or the
+// last snapshot when Intake is empty, and records the audit.
+type LiveMessage struct {
+	Type   string          `json:"type"`
+	Intake json.RawMessage `json:"intake,omitempty"`
+}
+
+// LiveReply is a server message on the /api/analyze/ws socket: "partial"
+// answers a snapshot, "result" a submit, and "error" a message that was
+// rejected, e.g. for exceeding the rate limit.
+type LiveReply struct {
+	Type    string         `json:"type"`
+	Partial *PartialResult `json:"partial,omitempty"`
+	Result  *Response      `json:"result,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// BatchRequest is the body of POST /api/analyze/batch. DryRun applies to
+// every item that does not set its own.
+type BatchRequest struct {
+	DryRun bool        `json:"dryRun,omitempty"`
+	Items  []BatchItem `json:"items"`
+}
+
+// BatchItem is one intake in a batch; a non-nil DryRun overrides the batch
+// default.
+type BatchItem struct {
+	Intake Intake `json:"intake"`
+	DryRun *bool  `json:"dryRun,omitempty"`
+}
+
+// BatchResponse holds one Response per BatchItem, in request order. Items
+// that fail validation carry their validationErrors instead of failing the
+// batch. AuditFailures lists the items analyzed but not audited; the audits
+// of the others are written all the same.
+type BatchResponse struct {
+	Results       []Response     `json:"results"`
+	AuditFailures []BatchFailure `json:"auditFailures,omitempty"`
+}
+
+// BatchFailure identifies a batch item by its index in the request.
+type BatchFailure struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+// ImportResult is the outcome of one row of POST /api/analyze/import. Line is
+// where the row starts in the uploaded file, the header being line 1. A row
+// that could not be read or failed validation carries Errors and no audit.
+type ImportResult struct {
+	Line      int       `json:"line"`
+	AuditID   string    `json:"auditId,omitempty"`
+	RiskLevel RiskLevel `json:"riskLevel,omitempty"`
+	DryRun    bool      `json:"dryRun,omitempty"`
+	Errors    []string  `json:"errors,omitempty"`
+}
+
+// Decision is a clinician's sign-off on an analysis. When revisions are
+// enabled the highest Revision is current.
+type Decision struct {
+	AuditID      string `json:"auditId"`
+	Revision     int    `json:"revision"`
+	Decision     string `json:"decision"` // approved | modified | rejected
+	ModifiedPlan *Plan  `json:"modifiedPlan,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+	UserID       string `json:"userId,omitempty"`
+	At           string `json:"at"`
+}
+
+// DecisionRequest is the body of POST /api/analyze/{auditId}/decision.
+// ModifiedPlan is required for "modified"; Reason is required unless the plan
+// was approved.
+type DecisionRequest struct {
+	Decision     string `json:"decision"`
+	ModifiedPlan *Plan  `json:"modifiedPlan,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+	UserID       string `json:"userId,omitempty"`
+}
+
+// Problem types the API sends in Problem.Type. Other errors use
+// "about:blank", whose title is the HTTP status text.
+const (
+	ProblemValidationFailed = "urn:clinical-ai-assistant:problem:validation-failed"
+	ProblemInvalidPatch     = "urn:clinical-ai-assistant:problem:invalid-patch"
+	ProblemInvalidPayload   = "urn:clinical-ai-assistant:problem:invalid-payload"
+)
+
+// Problem is an RFC 7807 problem details body. Every API error is sent as
+// application/problem+json in this shape.
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Instance identifies the failed request by its X-Request-ID.
+	Instance string `json:"instance,omitempty"`
+	// Errors lists the individual failures of a validation-failed or
+	// invalid-patch problem.
+	Errors []string `json:"errors,omitempty"`
+	// Op is the index of the rejected operation in an invalid-patch problem.
+	Op *int `json:"op,omitempty"`
+	// AuditID references the validation failure recorded for a rejected
+	// analysis, when the audit store keeps them.
+	AuditID string `json:"auditId,omitempty"`
+}
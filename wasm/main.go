@@ -0,0 +1,48 @@
+//go:build js && wasm
+
+// Command wasm exposes the deterministic rule engine to the browser for
+// offline pre-screening. Build it with
+//
+//	GOOS=js GOARCH=wasm go build -o analysis.wasm ./wasm
+//
+// and load it with Go's wasm_exec.js; it then defines analyze(jsonString)
+// on the global object.
+package main
+
+import (
+	"encoding/json"
+	"syscall/js"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+)
+
+func main() {
+	js.Global().Set("analyze", js.FuncOf(analyze))
+	// The exported function lives as long as the program does.
+	select {}
+}
+
+// analyze takes an intake as a JSON string and returns the Response as a JSON
+// string, or {"error": "..."} when the argument is not an intake. Nothing is
+// audited: the pre-screen runs as a dry run with the stub scorer, since the
+// browser has neither the audit store nor the LLM.
+func analyze(_ js.Value, args []js.Value) any {
+	if len(args) != 1 || args[0].Type() != js.TypeString {
+		return errorJSON("analyze takes one argument, the intake as a JSON string")
+	}
+	var in analysis.Intake
+	if err := json.Unmarshal([]byte(args[0].String()), &in); err != nil {
+		return errorJSON("invalid intake: " + err.Error())
+	}
+	resp := analysis.AnalyzeWithOptions(in, analysis.Options{DryRun: true})
+	out, err := json.Marshal(resp)
+	if err != nil {
+		return errorJSON(err.Error())
+	}
+	return string(out)
+}
+
+func errorJSON(msg string) string {
+	out, _ := json.Marshal(map[string]string{"error": msg})
+	return string(out)
+}
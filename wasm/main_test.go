@@ -0,0 +1,28 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"encoding/json"
+	"syscall/js"
+	"testing"
+
+	"github.com/Skufu/Clinical-AI-Assistant/internal/analysis"
+)
+
+// Run with make test-wasm; the test only builds for js/wasm.
+func TestAnalyze(t *testing.T) {
+	out := analyze(js.Undefined(), []js.Value{js.ValueOf(`{"patientName":"Offline","age":45,"weight":70,"height":170,"bp":"120/80","complaint":"ED"}`)})
+	var resp analysis.Response
+	if err := json.Unmarshal([]byte(out.(string)), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.RiskLevel == "" || resp.RecommendedPlan.Medication == "" || !resp.DryRun || resp.AuditID != "" {
+		t.Fatalf("pre-screen = %+v", resp)
+	}
+
+	var failure struct{ Error string }
+	if err := json.Unmarshal([]byte(analyze(js.Undefined(), []js.Value{js.ValueOf("{")}).(string)), &failure); err != nil || failure.Error == "" {
+		t.Fatalf("malformed intake: %+v, %v", failure, err)
+	}
+}